@@ -16,32 +16,226 @@
 package container
 
 import (
+	"bytes"
 	"context"
 	"io"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
+
+	"github.com/google/oss-rebuild/build/binary"
+	"github.com/pkg/errors"
 )
 
+// Image is a node in the project's container build graph.
+type Image struct {
+	// Name identifies the image and its Dockerfile at build/package/Dockerfile.<Name>.
+	Name string
+	// Base is the name of the image on which this one is built, if any.
+	Base string
+	// Binary indicates whether the image packages the binary built from cmd/<Name>.
+	Binary bool
+}
+
+// Graph is the full set of images built by the project.
+//
+// NOTE: Images sharing a base reuse its cached layers rather than each re-running package installs.
+var Graph = []Image{
+	{Name: "base"},
+	{Name: "base_npm", Base: "base"},
+	{Name: "analyzer", Base: "base", Binary: true},
+	{Name: "api", Base: "base", Binary: true},
+	{Name: "dashboard", Base: "base", Binary: true},
+	{Name: "gateway", Base: "base", Binary: true},
+	{Name: "git_cache", Base: "base", Binary: true},
+	{Name: "gsutil_writeonly", Base: "base", Binary: true},
+	{Name: "inference", Base: "base_npm", Binary: true},
+	{Name: "query", Base: "base", Binary: true},
+	{Name: "rebuilder", Base: "base_npm", Binary: true},
+}
+
+// Services returns the names of the images in Graph that package a service binary.
+func Services() []string {
+	var names []string
+	for _, img := range Graph {
+		if img.Binary {
+			names = append(names, img.Name)
+		}
+	}
+	return names
+}
+
+func lookup(name string) (Image, error) {
+	for _, img := range Graph {
+		if img.Name == name {
+			return img, nil
+		}
+	}
+	return Image{}, errors.Errorf("unknown image: %s", name)
+}
+
+// order returns the images required to build names with each image preceded by its base.
+func order(names []string) ([]Image, error) {
+	var ordered []Image
+	seen := make(map[string]bool)
+	var visit func(name string) error
+	visit = func(name string) error {
+		if seen[name] {
+			return nil
+		}
+		seen[name] = true
+		img, err := lookup(name)
+		if err != nil {
+			return err
+		}
+		if img.Base != "" {
+			if err := visit(img.Base); err != nil {
+				return err
+			}
+		}
+		ordered = append(ordered, img)
+		return nil
+	}
+	for _, name := range names {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+// Options configures a build of the image graph.
+type Options struct {
+	// Repository is prefixed to the image names to produce their tags e.g. "us-docker.pkg.dev/project/repo".
+	Repository string
+	// Push uploads each built image to Repository so its registry digest can be recorded.
+	Push bool
+}
+
+func (o Options) tag(name string) string {
+	if o.Repository == "" {
+		return name
+	}
+	return strings.TrimSuffix(o.Repository, "/") + "/" + name
+}
+
+// Digest identifies a built image.
+type Digest struct {
+	// Image is the tag applied to the image.
+	Image string `json:"image"`
+	// ID is the local image ID.
+	ID string `json:"id"`
+	// Digest is the registry manifest digest, present only when the image was pushed.
+	Digest string `json:"digest,omitempty"`
+}
+
+// Manifest maps image names to their built digests.
+type Manifest map[string]Digest
+
+// BuildGraph builds the named images along with all the base images they depend on.
+//
+// The returned Manifest contains only the requested images.
+func BuildGraph(ctx context.Context, names []string, opts Options) (Manifest, error) {
+	images, err := order(names)
+	if err != nil {
+		return nil, err
+	}
+	requested := make(map[string]bool)
+	for _, name := range names {
+		requested[name] = true
+	}
+	m := make(Manifest)
+	for _, img := range images {
+		var bin string
+		if img.Binary {
+			if bin, err = binary.Build(ctx, img.Name); err != nil {
+				return nil, errors.Wrapf(err, "building %s binary", img.Name)
+			}
+		}
+		d, err := build(ctx, img, bin, opts)
+		if err != nil {
+			return nil, errors.Wrapf(err, "building %s image", img.Name)
+		}
+		if requested[img.Name] {
+			m[img.Name] = d
+		}
+	}
+	return m, nil
+}
+
 // Build constructs a container for one of the project's microservices.
 func Build(ctx context.Context, name, binary string) error {
-	tempDir, err := os.MkdirTemp("", "oss-rebuild")
+	img, err := lookup(name)
 	if err != nil {
 		return err
 	}
-	defer os.RemoveAll(tempDir)
+	if img.Base != "" {
+		if _, err := BuildGraph(ctx, []string{img.Base}, Options{}); err != nil {
+			return err
+		}
+	}
+	_, err = build(ctx, img, binary, Options{})
+	return err
+}
 
-	err = copyFile(filepath.Join(tempDir, name), binary)
+func build(ctx context.Context, img Image, binary string, opts Options) (Digest, error) {
+	tempDir, err := os.MkdirTemp("", "oss-rebuild")
 	if err != nil {
-		return err
+		return Digest{}, err
+	}
+	defer os.RemoveAll(tempDir)
+
+	args := []string{"build", "--tag", opts.tag(img.Name)}
+	if binary != "" {
+		err = copyFile(filepath.Join(tempDir, img.Name), binary)
+		if err != nil {
+			return Digest{}, err
+		}
+		args = append(args, "--build-arg", "BINARY="+img.Name)
+	}
+	if img.Base != "" {
+		args = append(args, "--build-arg", "BASE="+opts.tag(img.Base))
 	}
+	iidfile := filepath.Join(tempDir, ".iid")
+	args = append(args, "--iidfile", iidfile)
 
 	// Build the Docker image.
-	relpath := "build/package/Dockerfile." + name
+	relpath := "build/package/Dockerfile." + img.Name
 	dockerfile, _ := filepath.Abs(relpath)
-	cmd := exec.CommandContext(ctx, "docker", "build", "--build-arg", "BINARY="+name, "--tag", name, "--file", dockerfile, tempDir)
-	cmd.Stdout = log.Writer()
+	args = append(args, "--file", dockerfile, tempDir)
+	if err := run(exec.CommandContext(ctx, "docker", args...)); err != nil {
+		return Digest{}, err
+	}
+	id, err := os.ReadFile(iidfile)
+	if err != nil {
+		return Digest{}, errors.Wrap(err, "reading image ID")
+	}
+	d := Digest{Image: opts.tag(img.Name), ID: strings.TrimSpace(string(id))}
+	if opts.Push {
+		if err := run(exec.CommandContext(ctx, "docker", "push", d.Image)); err != nil {
+			return Digest{}, err
+		}
+		var out bytes.Buffer
+		cmd := exec.CommandContext(ctx, "docker", "inspect", "--format", "{{index .RepoDigests 0}}", d.Image)
+		cmd.Stdout = &out
+		if err := run(cmd); err != nil {
+			return Digest{}, err
+		}
+		_, digest, found := strings.Cut(strings.TrimSpace(out.String()), "@")
+		if !found {
+			return Digest{}, errors.Errorf("unexpected repo digest: %s", out.String())
+		}
+		d.Digest = digest
+	}
+	return d, nil
+}
+
+func run(cmd *exec.Cmd) error {
+	if cmd.Stdout == nil {
+		cmd.Stdout = log.Writer()
+	}
 	cmd.Stderr = log.Writer()
 	log.Print(cmd.String())
 	return cmd.Run()
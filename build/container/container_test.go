@@ -0,0 +1,91 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package container
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		images  []string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:   "base only",
+			images: []string{"base"},
+			want:   []string{"base"},
+		},
+		{
+			name:   "base precedes image",
+			images: []string{"api"},
+			want:   []string{"base", "api"},
+		},
+		{
+			name:   "transitive base",
+			images: []string{"rebuilder"},
+			want:   []string{"base", "base_npm", "rebuilder"},
+		},
+		{
+			name:   "shared bases built once",
+			images: []string{"inference", "query", "rebuilder"},
+			want:   []string{"base", "base_npm", "inference", "query", "rebuilder"},
+		},
+		{
+			name:   "base requested after dependent",
+			images: []string{"api", "base"},
+			want:   []string{"base", "api"},
+		},
+		{
+			name:    "unknown image",
+			images:  []string{"api", "unknown"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			images, err := order(tt.images)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("order() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var got []string
+			for _, img := range images {
+				got = append(got, img.Name)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("order() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGraph(t *testing.T) {
+	for _, img := range Graph {
+		if _, err := os.Stat(filepath.Join("..", "package", "Dockerfile."+img.Name)); err != nil {
+			t.Errorf("image %s has no Dockerfile: %v", img.Name, err)
+		}
+		if img.Binary {
+			if _, err := os.Stat(filepath.Join("..", "..", "cmd", img.Name)); err != nil {
+				t.Errorf("image %s has no binary: %v", img.Name, err)
+			}
+		}
+	}
+}
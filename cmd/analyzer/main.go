@@ -0,0 +1,182 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	kms "cloud.google.com/go/kms/apiv1"
+	"cloud.google.com/go/kms/apiv1/kmspb"
+	"github.com/google/oss-rebuild/internal/api"
+	"github.com/google/oss-rebuild/internal/api/analyzerservice"
+	"github.com/google/oss-rebuild/internal/config"
+	"github.com/google/oss-rebuild/internal/httpegress"
+	"github.com/google/oss-rebuild/internal/ratex"
+	"github.com/google/oss-rebuild/internal/taskqueue"
+	"github.com/google/oss-rebuild/internal/tracing"
+	"github.com/google/oss-rebuild/pkg/analyzer"
+	"github.com/google/oss-rebuild/pkg/analyzer/network"
+	"github.com/google/oss-rebuild/pkg/kmsdsse"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	cratesreg "github.com/google/oss-rebuild/pkg/registry/cratesio"
+	npmreg "github.com/google/oss-rebuild/pkg/registry/npm"
+	pypireg "github.com/google/oss-rebuild/pkg/registry/pypi"
+	"github.com/pkg/errors"
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+)
+
+var (
+	signingKeyVersion = flag.String("signing-key-version", "", "Resource name of the signing CryptoKeyVersion")
+	metadataBucket    = flag.String("metadata-bucket", "", "GCS bucket for rebuild artifacts")
+	taskQueuePath     = flag.String("task-queue", "", "the path identifier of the task queue to which to dispatch analyses")
+	taskQueueEmail    = flag.String("task-queue-email", "", "the email address of the service account Cloud Tasks should authorize as")
+	serviceURL        = flag.String("service-url", "", "URL of this service, to which dispatch and analysis tasks are posted")
+	pollInterval      = flag.Duration("poll-interval", 0, "if provided, the interval at which to poll for an analyzer's required assets")
+	awaitTimeout      = flag.Duration("await-timeout", 0, "if provided, the time allowed for an analyzer's required assets to appear")
+	drainTimeout      = flag.Duration("drain-timeout", 10*time.Second, "the time allowed for in-flight requests to complete on shutdown")
+	otlpEndpoint      = flag.String("otlp-endpoint", os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"), "if provided, the OTLP/HTTP endpoint to which to export trace spans")
+)
+
+var httpcfg = httpegress.Config{}
+
+var cfgLoader = config.Loader{EnvPrefix: "OSS_REBUILD_ANALYZER"}
+
+// registryLimiter is shared across requests so all outbound calls to a host observe the same limits.
+var registryLimiter = ratex.NewLimiter(0)
+
+// analyzers are the analyzers served and dispatched by this service.
+var analyzers []analyzer.Analyzer
+
+func makeAnalyzers(ctx context.Context) ([]analyzer.Analyzer, error) {
+	client, err := httpegress.MakeClient(ctx, httpcfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "making http client")
+	}
+	rc := &ratex.Client{BasicClient: client, Limiter: registryLimiter, Retries: 2}
+	regs := network.Registries{
+		NPM:      npmreg.HTTPRegistry{Client: rc},
+		PyPI:     pypireg.HTTPRegistry{Client: rc},
+		CratesIO: cratesreg.HTTPRegistry{Client: rc},
+	}
+	return []analyzer.Analyzer{network.DownloadDigestsAnalyzer(regs)}, nil
+}
+
+func makeKMSSigner(ctx context.Context, cryptoKeyVersion string) (*dsse.EnvelopeSigner, error) {
+	kc, err := kms.NewKeyManagementClient(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating KMS client")
+	}
+	ckv, err := kc.GetCryptoKeyVersion(ctx, &kmspb.GetCryptoKeyVersionRequest{Name: cryptoKeyVersion})
+	if err != nil {
+		return nil, errors.Wrap(err, "fetching CryptoKeyVersion")
+	}
+	kmsSigner, err := kmsdsse.NewCloudKMSSignerVerifier(ctx, kc, ckv)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating Cloud KMS signer")
+	}
+	dsseSigner, err := dsse.NewEnvelopeSigner(kmsSigner)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating envelope signer")
+	}
+	return dsseSigner, nil
+}
+
+func metadataStore(ctx context.Context, runID string) (rebuild.AssetStore, error) {
+	return rebuild.NewGCSStore(context.WithValue(ctx, rebuild.RunID, runID), "gs://"+*metadataBucket)
+}
+
+func AnalyzeInit(a analyzer.Analyzer) api.InitT[*analyzerservice.AnalyzeDeps] {
+	return func(ctx context.Context) (*analyzerservice.AnalyzeDeps, error) {
+		var d analyzerservice.AnalyzeDeps
+		var err error
+		d.Analyzer = a
+		d.Signer, err = makeKMSSigner(ctx, *signingKeyVersion)
+		if err != nil {
+			return nil, errors.Wrap(err, "creating signer")
+		}
+		d.AssetStoreBuilder = metadataStore
+		return &d, nil
+	}
+}
+
+func DispatchInit(ctx context.Context) (*analyzerservice.DispatchDeps, error) {
+	var d analyzerservice.DispatchDeps
+	var err error
+	d.Analyzers = analyzers
+	d.AssetStoreBuilder = metadataStore
+	d.Queue, err = taskqueue.NewQueue(ctx, *taskQueuePath, *taskQueueEmail)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating task queue")
+	}
+	u, err := url.Parse(*serviceURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing service URL")
+	}
+	d.AnalyzerURL = u.JoinPath("analyze")
+	d.PollInterval = *pollInterval
+	d.Timeout = *awaitTimeout
+	return &d, nil
+}
+
+func NotifyInit(ctx context.Context) (*analyzerservice.NotifyDeps, error) {
+	var d analyzerservice.NotifyDeps
+	var err error
+	d.Queue, err = taskqueue.NewQueue(ctx, *taskQueuePath, *taskQueueEmail)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating task queue")
+	}
+	u, err := url.Parse(*serviceURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing service URL")
+	}
+	d.DispatchURL = u.JoinPath("dispatch")
+	return &d, nil
+}
+
+func main() {
+	httpcfg.RegisterFlags(flag.CommandLine)
+	cfgLoader.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	cfgLoader.MustLoad(flag.CommandLine)
+	go registryLimiter.LogStats(context.Background(), 5*time.Minute)
+	var err error
+	analyzers, err = makeAnalyzers(context.Background())
+	if err != nil {
+		log.Fatalln(errors.Wrap(err, "creating analyzers"))
+	}
+	for _, a := range analyzers {
+		http.HandleFunc("/analyze/"+a.Name, api.Handler(AnalyzeInit(a), analyzerservice.Analyze))
+	}
+	http.HandleFunc("/dispatch", api.Handler(DispatchInit, analyzerservice.Dispatch))
+	http.HandleFunc("/notify", analyzerservice.HandleNotification(NotifyInit))
+	flushTraces, err := tracing.Setup(context.Background(), "analyzer", *otlpEndpoint)
+	if err != nil {
+		log.Fatalln(errors.Wrap(err, "configuring tracing"))
+	}
+	srv := &api.Server{Addr: ":8080", Handler: tracing.Handler(http.DefaultServeMux), DrainTimeout: *drainTimeout}
+	err = srv.ListenAndServe()
+	if err := flushTraces(context.Background()); err != nil {
+		log.Println(errors.Wrap(err, "flushing traces"))
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
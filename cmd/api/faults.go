@@ -0,0 +1,42 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build faults
+
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/google/oss-rebuild/internal/faults"
+	"github.com/pkg/errors"
+)
+
+// NOTE: Fault injection is only compiled into binaries built with the "faults"
+// tag for use in integration testing.
+
+var faultInjection = flag.String("fault-injection", os.Getenv("OSS_REBUILD_FAULT_INJECTION"), "TESTING ONLY: comma-separated fault=probability pairs to inject e.g. 'gcb-timeout=0.1,registry-429=0.2'")
+
+// setupFaults configures the injector from the fault injection flag, which
+// defaults to the value of OSS_REBUILD_FAULT_INJECTION.
+func setupFaults() {
+	if fc, err := faults.ParseConfig(*faultInjection); err != nil {
+		log.Fatalln(errors.Wrap(err, "parsing fault injection config"))
+	} else if fc.Enabled() {
+		log.Printf("WARNING: Injecting faults: %+v", fc)
+		injector = faults.NewInjector(fc)
+	}
+}
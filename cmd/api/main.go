@@ -17,29 +17,46 @@ package main
 import (
 	"context"
 	"flag"
+	"io"
 	"log"
 	"net/http"
 	"net/url"
+	"os"
 	"path"
+	"strings"
+	"sync"
+	"time"
 
+	"cloud.google.com/go/compute/metadata"
 	"cloud.google.com/go/firestore"
 	kms "cloud.google.com/go/kms/apiv1"
 	"cloud.google.com/go/kms/apiv1/kmspb"
+	gcs "cloud.google.com/go/storage"
 	"github.com/go-git/go-billy/v5/memfs"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/google/oss-rebuild/internal/api"
 	"github.com/google/oss-rebuild/internal/api/apiservice"
 	"github.com/google/oss-rebuild/internal/api/inferenceservice"
 	"github.com/google/oss-rebuild/internal/api/rebuilderservice"
+	"github.com/google/oss-rebuild/internal/config"
+	"github.com/google/oss-rebuild/internal/faults"
 	"github.com/google/oss-rebuild/internal/gcb"
 	"github.com/google/oss-rebuild/internal/httpegress"
+	"github.com/google/oss-rebuild/internal/ratex"
+	"github.com/google/oss-rebuild/internal/taskqueue"
+	"github.com/google/oss-rebuild/internal/tracing"
 	"github.com/google/oss-rebuild/internal/uri"
+	"github.com/google/oss-rebuild/pkg/feed"
 	"github.com/google/oss-rebuild/pkg/kmsdsse"
+	"github.com/google/oss-rebuild/pkg/rebuild/alias"
 	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+	"github.com/google/oss-rebuild/tools/ctl/rundex"
 	"github.com/pkg/errors"
 	"github.com/secure-systems-lab/go-securesystemslib/dsse"
 	"google.golang.org/api/cloudbuild/v1"
 	"google.golang.org/api/idtoken"
+	run "google.golang.org/api/run/v2"
 )
 
 var (
@@ -53,13 +70,37 @@ var (
 	logsBucket            = flag.String("logs-bucket", "", "GCS bucket for rebuild logs")
 	debugStorage          = flag.String("debug-storage", "", "if provided, the location in which rebuild debug info should be stored")
 	prebuildBucket        = flag.String("prebuild-bucket", "", "GCS bucket from which prebuilt build tools are stored")
+	windowsWorkerPool     = flag.String("windows-worker-pool", "", "if provided, the resource name of the GCB private pool on which to execute Windows builds")
+	windowsDockerHost     = flag.String("windows-docker-host", "", "the address of the Windows Docker daemon reachable from the Windows worker pool")
 	buildDefRepo          = flag.String("build-def-repo", "", "repository for build definitions")
 	buildDefRepoDir       = flag.String("build-def-repo-dir", ".", "relpath within the build definitions repository")
 	overwriteAttestations = flag.Bool("overwrite-attestations", false, "whether to overwrite existing attestations when writing to GCS")
+	feedBucket            = flag.String("feed-bucket", "", "GCS bucket to which to publish the verdict feed")
+	overrideAllowlist     = flag.String("strategy-override-allowlist", "", "comma-separated identities permitted to supply a smoketest strategy in place of inference. if empty, all callers are permitted")
+	serviceURL            = flag.String("service-url", "", "the URL of this service. callers are identified by the ID tokens they present for this audience. if empty, callers are unknown")
+	callbackHosts         = flag.String("callback-hosts", "", "comma-separated hosts permitted to receive async rebuild callbacks. if empty, callbacks are rejected")
+	asyncTimeout          = flag.Duration("async-timeout", apiservice.DefaultOperationTimeout, "the time allowed for an async rebuild to complete before its operation is abandoned")
+	drainTimeout          = flag.Duration("drain-timeout", 10*time.Second, "the time allowed for in-flight requests to complete on shutdown")
+	otlpEndpoint          = flag.String("otlp-endpoint", os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"), "if provided, the OTLP/HTTP endpoint to which to export trace spans")
+	schedulerConfig       = flag.String("scheduler-config", "", "if provided, path to the YAML config of per-ecosystem rebuild quotas. quotas are held in memory so the service must be limited to a single instance")
 )
 
 var httpcfg = httpegress.Config{}
 
+var cfgLoader = config.Loader{EnvPrefix: "OSS_REBUILD_API"}
+
+// injector, if non-nil, injects faults into the rebuild dependencies.
+var injector *faults.Injector
+
+// scheduler, if non-nil, limits the rebuilds admitted per ecosystem.
+var scheduler *taskqueue.Scheduler
+
+// registryLimiter is shared across requests so all outbound calls to a host observe the same limits.
+var registryLimiter = ratex.NewLimiter(0)
+
+// attemptWriter is shared across requests so concurrent smoketests commit their attempts in batches.
+var attemptWriter *rundex.BatchWriter
+
 func RebuildSmoketestInit(ctx context.Context) (*apiservice.RebuildSmoketestDeps, error) {
 	var d apiservice.RebuildSmoketestDeps
 	var err error
@@ -75,8 +116,13 @@ func RebuildSmoketestInit(ctx context.Context) (*apiservice.RebuildSmoketestDeps
 	if err != nil {
 		return nil, errors.Wrap(err, "initializing build local client")
 	}
-	d.SmoketestStub = api.StubFromHandler(runclient, *u.JoinPath("smoketest"), rebuilderservice.RebuildSmoketest)
-	d.VersionStub = api.StubFromHandler(runclient, *u.JoinPath("version"), rebuilderservice.Version)
+	d.SmoketestStub = api.StubFromHandler(tracing.Client(runclient), *u.JoinPath("smoketest"), rebuilderservice.RebuildSmoketest)
+	d.VersionStub = api.StubFromHandler(tracing.Client(runclient), *u.JoinPath("version"), rebuilderservice.Version)
+	d.Scheduler = scheduler
+	d.AttemptWriter = attemptWriter
+	if *overrideAllowlist != "" {
+		d.OverridePolicy.Allowed = strings.Split(*overrideAllowlist, ",")
+	}
 	return &d, nil
 }
 
@@ -103,10 +149,14 @@ func makeKMSSigner(ctx context.Context, cryptoKeyVersion string) (*dsse.Envelope
 func RebuildPackageInit(ctx context.Context) (*apiservice.RebuildPackageDeps, error) {
 	var d apiservice.RebuildPackageDeps
 	var err error
-	d.HTTPClient, err = httpegress.MakeClient(ctx, httpcfg)
+	client, err := httpegress.MakeClient(ctx, httpcfg)
 	if err != nil {
 		return nil, errors.Wrap(err, "making http client")
 	}
+	if injector != nil {
+		client = injector.HTTP(client)
+	}
+	d.HTTPClient = &ratex.Client{BasicClient: client, Limiter: registryLimiter, Retries: 2}
 	d.FirestoreClient, err = firestore.NewClient(ctx, *project)
 	if err != nil {
 		return nil, errors.Wrap(err, "creating firestore client")
@@ -120,10 +170,22 @@ func RebuildPackageInit(ctx context.Context) (*apiservice.RebuildPackageDeps, er
 		return nil, errors.Wrap(err, "creating CloudBuild service")
 	}
 	d.GCBClient = gcb.NewClient(svc)
+	if injector != nil {
+		d.GCBClient = injector.GCB(d.GCBClient)
+	}
 	d.BuildProject = *project
 	d.BuildServiceAccount = *buildRemoteIdentity
 	d.UtilPrebuildBucket = *prebuildBucket
 	d.BuildLogsBucket = *logsBucket
+	gcsClient, err := gcs.NewClient(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating gcs client")
+	}
+	d.BuildLogReader = func(ctx context.Context, buildID string) (io.ReadCloser, error) {
+		return gcsClient.Bucket(*logsBucket).Object(gcb.MergedLogFile(buildID)).NewReader(ctx)
+	}
+	d.WindowsWorkerPool = *windowsWorkerPool
+	d.WindowsDockerHost = *windowsDockerHost
 	repo, err := uri.CanonicalizeRepoURI(*buildDefRepo)
 	if err != nil {
 		return nil, errors.Wrap(err, "canonicalizing build def repo")
@@ -133,10 +195,14 @@ func RebuildPackageInit(ctx context.Context) (*apiservice.RebuildPackageDeps, er
 		Ref:  plumbing.Main.String(),
 		Dir:  path.Clean(*buildDefRepoDir),
 	}
-	d.AttestationStore, err = rebuild.NewGCSStore(context.WithValue(ctx, rebuild.RunID, ""), "gs://"+*attestationBucket)
+	attestationStore, err := rebuild.NewGCSStore(context.WithValue(ctx, rebuild.RunID, ""), "gs://"+*attestationBucket)
 	if err != nil {
 		return nil, errors.Wrap(err, "creating attestation uploader")
 	}
+	d.AttestationStore = attestationStore
+	if injector != nil {
+		d.AttestationStore = injector.Store(attestationStore)
+	}
 	d.LocalMetadataStore = rebuild.NewFilesystemAssetStore(memfs.New())
 	// TODO: This can be optional once LocalMetadata and DebugStore are combined into a cached store.
 	if *debugStorage == "" {
@@ -149,7 +215,11 @@ func RebuildPackageInit(ctx context.Context) (*apiservice.RebuildPackageDeps, er
 		return rebuild.DebugStoreFromContext(context.WithValue(ctx, rebuild.DebugStoreID, *debugStorage))
 	}
 	d.RemoteMetadataStoreBuilder = func(ctx context.Context, uuid string) (rebuild.LocatableAssetStore, error) {
-		return rebuild.NewGCSStore(context.WithValue(ctx, rebuild.RunID, uuid), "gs://"+*metadataBucket)
+		store, err := rebuild.NewGCSStore(context.WithValue(ctx, rebuild.RunID, uuid), "gs://"+*metadataBucket)
+		if err != nil || injector == nil {
+			return store, err
+		}
+		return injector.Store(store), nil
 	}
 	d.OverwriteAttestations = *overwriteAttestations
 	u, err := url.Parse(*inferenceURL)
@@ -161,7 +231,8 @@ func RebuildPackageInit(ctx context.Context) (*apiservice.RebuildPackageDeps, er
 	if err != nil {
 		return nil, errors.Wrap(err, "initializing inference client")
 	}
-	d.InferStub = api.StubFromHandler(runclient, *u, inferenceservice.Infer)
+	d.InferStub = api.StubFromHandler(tracing.Client(runclient), *u, inferenceservice.Infer)
+	d.Scheduler = scheduler
 	return &d, nil
 }
 
@@ -181,7 +252,7 @@ func VersionInit(ctx context.Context) (*apiservice.VersionDeps, error) {
 		if err != nil {
 			return nil, errors.Wrap(err, "initializing build local client")
 		}
-		d.BuildLocalVersionStub = api.StubFromHandler(runclient, *u.JoinPath("version"), rebuilderservice.Version)
+		d.BuildLocalVersionStub = api.StubFromHandler(tracing.Client(runclient), *u.JoinPath("version"), rebuilderservice.Version)
 	}
 	{
 		u, err := url.Parse(*inferenceURL)
@@ -192,7 +263,7 @@ func VersionInit(ctx context.Context) (*apiservice.VersionDeps, error) {
 		if err != nil {
 			return nil, errors.Wrap(err, "initializing inference client")
 		}
-		d.InferenceVersionStub = api.StubFromHandler(runclient, *u.JoinPath("version"), inferenceservice.Version)
+		d.InferenceVersionStub = api.StubFromHandler(tracing.Client(runclient), *u.JoinPath("version"), inferenceservice.Version)
 	}
 	return &d, nil
 }
@@ -207,14 +278,194 @@ func CreateRunInit(ctx context.Context) (*apiservice.CreateRunDeps, error) {
 	return &d, nil
 }
 
+// background tracks async work that should complete before shutdown.
+var background sync.WaitGroup
+
+// backgroundCtx is cancelled on shutdown to interrupt async work.
+var backgroundCtx, interruptBackground = context.WithCancel(context.Background())
+
+func RebuildPackageAsyncInit(ctx context.Context) (*apiservice.RebuildPackageAsyncDeps, error) {
+	// NOTE: These dependencies outlive the request that initializes them.
+	ctx = context.WithoutCancel(ctx)
+	var d apiservice.RebuildPackageAsyncDeps
+	client, err := firestore.NewClient(ctx, *project)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating firestore client")
+	}
+	d.Operations = apiservice.FirestoreOperationStore{Client: client}
+	if *callbackHosts != "" {
+		d.CallbackPolicy.AllowedHosts = strings.Split(*callbackHosts, ",")
+	}
+	d.CallbackClient, err = httpegress.MakeClient(ctx, httpcfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "making http client")
+	}
+	d.Rebuild = func(ctx context.Context, req schema.RebuildPackageRequest) (*schema.Verdict, error) {
+		deps, err := RebuildPackageInit(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "initializing dependencies")
+		}
+		return apiservice.RebuildPackage(ctx, req, deps)
+	}
+	d.Background = func(f func(context.Context)) {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			f(backgroundCtx)
+		}()
+	}
+	d.Timeout = *asyncTimeout
+	return &d, nil
+}
+
+func GetOperationInit(ctx context.Context) (*apiservice.GetOperationDeps, error) {
+	client, err := firestore.NewClient(ctx, *project)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating firestore client")
+	}
+	return &apiservice.GetOperationDeps{Operations: apiservice.FirestoreOperationStore{Client: client}}, nil
+}
+
+func PublishVerdictFeedInit(ctx context.Context) (*apiservice.PublishVerdictFeedDeps, error) {
+	var d apiservice.PublishVerdictFeedDeps
+	if *feedBucket == "" {
+		return nil, errors.New("feed-bucket must be set")
+	}
+	client, err := firestore.NewClient(ctx, *project)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating firestore client")
+	}
+	d.Attempts = apiservice.FirestoreAttemptSource{Client: client}
+	d.Signer, err = makeKMSSigner(ctx, *signingKeyVersion)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating signer")
+	}
+	gcsClient, err := gcs.NewClient(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating gcs client")
+	}
+	d.Feed = feed.GCSStore{Bucket: gcsClient.Bucket(*feedBucket)}
+	// NOTE: Aliases are loaded on each request so edits to the Firestore
+	// collection take effect without a redeploy.
+	d.Aliases, err = alias.Load(ctx, alias.FirestoreSource{Client: client})
+	if err != nil {
+		return nil, errors.Wrap(err, "loading package aliases")
+	}
+	return &d, nil
+}
+
+func PackageHistoryInit(ctx context.Context) (*apiservice.PackageHistoryDeps, error) {
+	var d apiservice.PackageHistoryDeps
+	client, err := firestore.NewClient(ctx, *project)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating firestore client")
+	}
+	d.Attempts = apiservice.FirestoreAttemptSource{Client: client}
+	d.AttestationStore, err = rebuild.NewGCSStore(context.WithValue(ctx, rebuild.RunID, ""), "gs://"+*attestationBucket)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating attestation store")
+	}
+	d.Aliases, err = alias.Load(ctx, alias.FirestoreSource{Client: client})
+	if err != nil {
+		return nil, errors.Wrap(err, "loading package aliases")
+	}
+	return &d, nil
+}
+
+// requireSingleInstance returns an error if the Cloud Run service hosting
+// this process may scale beyond a single instance.
+func requireSingleInstance(ctx context.Context) error {
+	service := os.Getenv("K_SERVICE")
+	if service == "" {
+		// Not running on Cloud Run.
+		return nil
+	}
+	region, err := metadata.GetWithContext(ctx, "instance/region")
+	if err != nil {
+		return errors.Wrap(err, "fetching region")
+	}
+	svc, err := run.NewService(ctx)
+	if err != nil {
+		return errors.Wrap(err, "creating Cloud Run client")
+	}
+	name := path.Join("projects", *project, "locations", path.Base(region), "services", service)
+	s, err := svc.Projects.Locations.Services.Get(name).Context(ctx).Do()
+	if err != nil {
+		return errors.Wrap(err, "fetching service")
+	}
+	if s.Template == nil || s.Template.Scaling == nil || s.Template.Scaling.MaxInstanceCount != 1 {
+		return errors.Errorf("service %s must have a max instance count of 1", service)
+	}
+	return nil
+}
+
 func main() {
 	httpcfg.RegisterFlags(flag.CommandLine)
+	cfgLoader.RegisterFlags(flag.CommandLine)
 	flag.Parse()
-	http.HandleFunc("/smoketest", api.Handler(RebuildSmoketestInit, apiservice.RebuildSmoketest))
+	cfgLoader.MustLoad(flag.CommandLine)
+	go registryLimiter.LogStats(context.Background(), 5*time.Minute)
+	setupFaults()
+	if *schedulerConfig != "" {
+		f, err := os.Open(*schedulerConfig)
+		if err != nil {
+			log.Fatalln(errors.Wrap(err, "opening scheduler config"))
+		}
+		cfg, err := taskqueue.ParseSchedulerConfig(f)
+		f.Close()
+		if err != nil {
+			log.Fatalln(errors.Wrap(err, "parsing scheduler config"))
+		}
+		if err := requireSingleInstance(context.Background()); err != nil {
+			log.Fatalln(errors.Wrap(err, "scheduler quotas require a single instance"))
+		}
+		scheduler = taskqueue.NewScheduler(*cfg)
+	}
+	{
+		client, err := firestore.NewClient(context.Background(), *project)
+		if err != nil {
+			log.Fatalln(errors.Wrap(err, "creating firestore client"))
+		}
+		attemptWriter = rundex.NewBatchWriter(rundex.FirestoreCommitter(client), rundex.DefaultBatchWriterOpts)
+	}
+	http.HandleFunc("/smoketest", api.Handler(RebuildSmoketestInit, apiservice.RebuildSmoketest, api.WithCallerAudience(*serviceURL)))
 	http.HandleFunc("/rebuild", api.Handler(RebuildPackageInit, apiservice.RebuildPackage))
+	http.HandleFunc("/rebuild/async", api.Handler(RebuildPackageAsyncInit, apiservice.RebuildPackageAsync))
+	http.HandleFunc("/operations/{id}", api.WithPathValues(api.Handler(GetOperationInit, apiservice.GetOperation), "id"))
 	http.HandleFunc("/version", api.Handler(VersionInit, apiservice.Version))
 	http.HandleFunc("/runs", api.Handler(CreateRunInit, apiservice.CreateRun))
-	if err := http.ListenAndServe(":8080", nil); err != nil {
+	http.HandleFunc("/feed/publish", api.Handler(PublishVerdictFeedInit, apiservice.PublishVerdictFeed))
+	http.HandleFunc("/history", api.Handler(PackageHistoryInit, apiservice.PackageHistory))
+	http.HandleFunc("/capabilities", api.Handler(api.NoDepsInit, apiservice.Capabilities))
+	flushTraces, err := tracing.Setup(context.Background(), "api", *otlpEndpoint)
+	if err != nil {
+		log.Fatalln(errors.Wrap(err, "configuring tracing"))
+	}
+	srv := &api.Server{Addr: ":8080", Handler: tracing.Handler(http.DefaultServeMux), DrainTimeout: *drainTimeout}
+	srv.OnShutdown(func(ctx context.Context) error {
+		// NOTE: Async rebuilds cannot complete within the drain timeout so are
+		// interrupted immediately, leaving them time to record their outcome.
+		interruptBackground()
+		done := make(chan struct{})
+		go func() {
+			background.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "awaiting async operations")
+		}
+	})
+	srv.OnShutdown(func(ctx context.Context) error {
+		return errors.Wrap(attemptWriter.Close(ctx), "flushing attempt writes")
+	})
+	err = srv.ListenAndServe()
+	if err := flushTraces(context.Background()); err != nil {
+		log.Println(errors.Wrap(err, "flushing traces"))
+	}
+	if err != nil {
 		log.Fatalln(err)
 	}
 }
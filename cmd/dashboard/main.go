@@ -0,0 +1,53 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"time"
+
+	"github.com/google/oss-rebuild/internal/api"
+	"github.com/google/oss-rebuild/internal/dashboard"
+	"github.com/google/oss-rebuild/tools/ctl/rundex"
+)
+
+var (
+	project      = flag.String("project", "", "GCP Project ID from which to read the rundex")
+	debugStorage = flag.String("debug-storage", "", "if provided, the gs:// location of rebuild debug assets to link to")
+	addr         = flag.String("addr", ":8080", "the address on which to serve")
+	drainTimeout = flag.Duration("drain-timeout", 10*time.Second, "the time allowed for in-flight requests to complete on shutdown")
+)
+
+func main() {
+	flag.Parse()
+	if *project == "" {
+		log.Fatalln("--project is required")
+	}
+	ctx := context.Background()
+	fire, err := rundex.NewFirestore(ctx, *project)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	s, err := dashboard.NewServer(fire, *debugStorage)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	srv := &api.Server{Addr: *addr, Handler: s.Handler(), DrainTimeout: *drainTimeout}
+	if err := srv.ListenAndServe(); err != nil {
+		log.Fatalln(err)
+	}
+}
@@ -23,6 +23,8 @@ import (
 	"net/http"
 	"net/url"
 	"time"
+
+	"github.com/google/oss-rebuild/internal/api"
 )
 
 func consumeEvery(d time.Duration) chan<- func() {
@@ -42,6 +44,8 @@ func waitOn(c chan<- func()) {
 	return
 }
 
+var drainTimeout = flag.Duration("drain-timeout", 10*time.Second, "the time allowed for in-flight requests to complete on shutdown")
+
 var queues = map[string]chan<- func(){
 	"crates.io":  consumeEvery(time.Second),
 	"github.com": consumeEvery(200 * time.Millisecond),
@@ -74,7 +78,8 @@ func Handle(rw http.ResponseWriter, req *http.Request) {
 func main() {
 	flag.Parse()
 	http.HandleFunc("/", Handle)
-	if err := http.ListenAndServe(":8080", nil); err != nil {
+	srv := &api.Server{Addr: ":8080", Handler: http.DefaultServeMux, DrainTimeout: *drainTimeout}
+	if err := srv.ListenAndServe(); err != nil {
 		log.Fatalln(err)
 	}
 }
@@ -0,0 +1,292 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/sync/singleflight"
+	secretmanager "google.golang.org/api/secretmanager/v1"
+	"gopkg.in/yaml.v3"
+)
+
+// credentialConfig configures the credentials used to clone from each host.
+type credentialConfig struct {
+	Hosts map[string]hostCredentials `yaml:"hosts"`
+}
+
+// hostCredentials configures a single authentication method for a host.
+type hostCredentials struct {
+	GitHubApp *githubAppConfig `yaml:"github_app"`
+	DeployKey *deployKeyConfig `yaml:"deploy_key"`
+}
+
+// githubAppConfig authenticates as a GitHub App installation.
+type githubAppConfig struct {
+	AppID          int64 `yaml:"app_id"`
+	InstallationID int64 `yaml:"installation_id"`
+	// PrivateKeySecret is the Secret Manager version holding the App's PEM-encoded private key.
+	PrivateKeySecret string `yaml:"private_key_secret"`
+	// APIURL is the GitHub API endpoint. Defaults to https://api.github.com.
+	APIURL string `yaml:"api_url"`
+}
+
+// deployKeyConfig authenticates over SSH using a deploy key.
+type deployKeyConfig struct {
+	// PrivateKeySecret is the Secret Manager version holding the PEM-encoded private key.
+	PrivateKeySecret string `yaml:"private_key_secret"`
+	// HostKey is the host's public key in authorized_keys format.
+	HostKey string `yaml:"host_key"`
+}
+
+func parseCredentialConfig(r io.Reader) (*credentialConfig, error) {
+	var cfg credentialConfig
+	d := yaml.NewDecoder(r)
+	d.KnownFields(true)
+	if err := d.Decode(&cfg); err != nil {
+		return nil, errors.Wrap(err, "decoding credential config")
+	}
+	for host, c := range cfg.Hosts {
+		switch {
+		case (c.GitHubApp == nil) == (c.DeployKey == nil):
+			return nil, errors.Errorf("exactly one credential required for %s", host)
+		case c.GitHubApp != nil && (c.GitHubApp.AppID == 0 || c.GitHubApp.InstallationID == 0 || c.GitHubApp.PrivateKeySecret == ""):
+			return nil, errors.Errorf("incomplete github_app for %s", host)
+		case c.DeployKey != nil && (c.DeployKey.PrivateKeySecret == "" || c.DeployKey.HostKey == ""):
+			return nil, errors.Errorf("incomplete deploy_key for %s", host)
+		}
+	}
+	return &cfg, nil
+}
+
+// secretFetcher returns the payload of a Secret Manager secret version.
+type secretFetcher func(ctx context.Context, name string) ([]byte, error)
+
+func newSecretManagerFetcher(ctx context.Context) (secretFetcher, error) {
+	svc, err := secretmanager.NewService(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating Secret Manager client")
+	}
+	return func(ctx context.Context, name string) ([]byte, error) {
+		resp, err := svc.Projects.Secrets.Versions.Access(name).Context(ctx).Do()
+		if err != nil {
+			return nil, errors.Wrapf(err, "accessing %s", name)
+		}
+		return base64.StdEncoding.DecodeString(resp.Payload.Data)
+	}, nil
+}
+
+// cachedToken is a GitHub App installation token.
+type cachedToken struct {
+	token   string
+	expires time.Time
+}
+
+// authenticator resolves the credentials used to clone a repo.
+//
+// NOTE: Credentials are provided to the git transport and never embedded in
+// the remote URL so they are not persisted to the cached .git/config.
+type authenticator struct {
+	cfg    credentialConfig
+	secret secretFetcher
+	client *http.Client
+	now    func() time.Time
+
+	mu      sync.Mutex
+	tokens  map[string]cachedToken
+	fetches singleflight.Group
+}
+
+func newAuthenticator(cfg credentialConfig, secret secretFetcher) *authenticator {
+	return &authenticator{
+		cfg:    cfg,
+		secret: secret,
+		client: http.DefaultClient,
+		now:    time.Now,
+		tokens: make(map[string]cachedToken),
+	}
+}
+
+// Auth returns the clone URL and AuthMethod for repo, of the form <host>/<org>/<repo>.
+//
+// Hosts without configured credentials are cloned anonymously over HTTPS.
+func (a *authenticator) Auth(ctx context.Context, repo string) (string, transport.AuthMethod, error) {
+	host, path, _ := strings.Cut(repo, "/")
+	var c hostCredentials
+	if a != nil {
+		c = a.cfg.Hosts[host]
+	}
+	switch {
+	case c.GitHubApp != nil:
+		token, err := a.installationToken(ctx, host, *c.GitHubApp)
+		if err != nil {
+			return "", nil, errors.Wrap(err, "fetching installation token")
+		}
+		return "https://" + repo, &githttp.BasicAuth{Username: "x-access-token", Password: token}, nil
+	case c.DeployKey != nil:
+		key, err := a.secret(ctx, c.DeployKey.PrivateKeySecret)
+		if err != nil {
+			return "", nil, errors.Wrap(err, "fetching deploy key")
+		}
+		auth, err := gitssh.NewPublicKeys("git", key, "")
+		if err != nil {
+			return "", nil, errors.Wrap(err, "parsing deploy key")
+		}
+		hostKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(c.DeployKey.HostKey))
+		if err != nil {
+			return "", nil, errors.Wrap(err, "parsing host key")
+		}
+		auth.HostKeyCallback = ssh.FixedHostKey(hostKey)
+		return fmt.Sprintf("ssh://git@%s/%s", host, path), auth, nil
+	default:
+		return "https://" + repo, nil, nil
+	}
+}
+
+// tokenFetchTimeout bounds a shared installation token fetch.
+const tokenFetchTimeout = 30 * time.Second
+
+// installationToken returns a GitHub App installation token, minting a new
+// one when the cached token is near expiry.
+//
+// Concurrent requests for the same host share a single fetch which is made
+// without holding the cache lock.
+func (a *authenticator) installationToken(ctx context.Context, host string, app githubAppConfig) (string, error) {
+	a.mu.Lock()
+	t, ok := a.tokens[host]
+	a.mu.Unlock()
+	if ok && a.now().Add(5*time.Minute).Before(t.expires) {
+		return t.token, nil
+	}
+	v, err, _ := a.fetches.Do(host, func() (any, error) {
+		// NOTE: The fetch is shared so it must not be cancelled with the caller that started it.
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), tokenFetchTimeout)
+		defer cancel()
+		t, err := a.mintToken(ctx, app)
+		if err != nil {
+			return nil, err
+		}
+		a.mu.Lock()
+		a.tokens[host] = t
+		a.mu.Unlock()
+		return t.token, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return v.(string), nil
+}
+
+// mintToken requests a new installation token from the GitHub API.
+func (a *authenticator) mintToken(ctx context.Context, app githubAppConfig) (cachedToken, error) {
+	keyPEM, err := a.secret(ctx, app.PrivateKeySecret)
+	if err != nil {
+		return cachedToken{}, errors.Wrap(err, "fetching app private key")
+	}
+	jwt, err := appJWT(app.AppID, keyPEM, a.now())
+	if err != nil {
+		return cachedToken{}, errors.Wrap(err, "creating app JWT")
+	}
+	api := app.APIURL
+	if api == "" {
+		api = "https://api.github.com"
+	}
+	u := fmt.Sprintf("%s/app/installations/%d/access_tokens", strings.TrimSuffix(api, "/"), app.InstallationID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, nil)
+	if err != nil {
+		return cachedToken{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return cachedToken{}, errors.Wrap(err, "requesting installation token")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return cachedToken{}, errors.Errorf("requesting installation token: %s", resp.Status)
+	}
+	var body struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return cachedToken{}, errors.Wrap(err, "decoding installation token")
+	}
+	return cachedToken{token: body.Token, expires: body.ExpiresAt}, nil
+}
+
+// appJWT creates the RS256-signed JWT used to authenticate as a GitHub App.
+// See https://docs.github.com/en/apps/creating-github-apps/authenticating-with-a-github-app/generating-a-json-web-token-jwt-for-a-github-app
+func appJWT(appID int64, keyPEM []byte, now time.Time) (string, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return "", errors.New("no PEM block found")
+	}
+	var key *rsa.PrivateKey
+	if k, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		key = k
+	} else if k, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		var ok bool
+		if key, ok = k.(*rsa.PrivateKey); !ok {
+			return "", errors.New("private key is not RSA")
+		}
+	} else {
+		return "", errors.Wrap(err, "parsing private key")
+	}
+	enc := base64.RawURLEncoding
+	header := enc.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]any{
+		// NOTE: Backdate issuance to allow for clock drift.
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": fmt.Sprint(appID),
+	})
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	buf.WriteString(header)
+	buf.WriteByte('.')
+	buf.WriteString(enc.EncodeToString(claims))
+	digest := sha256.Sum256(buf.Bytes())
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", errors.Wrap(err, "signing JWT")
+	}
+	buf.WriteByte('.')
+	buf.WriteString(enc.EncodeToString(sig))
+	return buf.String(), nil
+}
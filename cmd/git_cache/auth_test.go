@@ -0,0 +1,393 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseCredentialConfig(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		config  string
+		want    *credentialConfig
+		wantErr bool
+	}{
+		{
+			name: "github_app",
+			config: `
+hosts:
+  github.com:
+    github_app:
+      app_id: 1
+      installation_id: 2
+      private_key_secret: projects/p/secrets/s/versions/1
+`,
+			want: &credentialConfig{Hosts: map[string]hostCredentials{
+				"github.com": {GitHubApp: &githubAppConfig{AppID: 1, InstallationID: 2, PrivateKeySecret: "projects/p/secrets/s/versions/1"}},
+			}},
+		},
+		{
+			name: "deploy_key",
+			config: `
+hosts:
+  gitlab.com:
+    deploy_key:
+      private_key_secret: projects/p/secrets/s/versions/1
+      host_key: ssh-ed25519 AAAA
+`,
+			want: &credentialConfig{Hosts: map[string]hostCredentials{
+				"gitlab.com": {DeployKey: &deployKeyConfig{PrivateKeySecret: "projects/p/secrets/s/versions/1", HostKey: "ssh-ed25519 AAAA"}},
+			}},
+		},
+		{
+			name:    "no credential",
+			config:  "hosts:\n  github.com: {}\n",
+			wantErr: true,
+		},
+		{
+			name: "both credentials",
+			config: `
+hosts:
+  github.com:
+    github_app: {app_id: 1, installation_id: 2, private_key_secret: s}
+    deploy_key: {private_key_secret: s, host_key: k}
+`,
+			wantErr: true,
+		},
+		{
+			name:    "incomplete github_app",
+			config:  "hosts:\n  github.com:\n    github_app: {app_id: 1, private_key_secret: s}\n",
+			wantErr: true,
+		},
+		{
+			name:    "incomplete deploy_key",
+			config:  "hosts:\n  gitlab.com:\n    deploy_key: {private_key_secret: s}\n",
+			wantErr: true,
+		},
+		{
+			name:    "unknown field",
+			config:  "hosts:\n  github.com:\n    token: abc\n",
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseCredentialConfig(strings.NewReader(tc.config))
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("parseCredentialConfig() = %+v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseCredentialConfig() error = %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("parseCredentialConfig() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAppJWT(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecPKCS8, err := x509.MarshalPKCS8PrivateKey(ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	for _, tc := range []struct {
+		name    string
+		keyPEM  []byte
+		wantErr bool
+	}{
+		{
+			name:   "PKCS1",
+			keyPEM: pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+		},
+		{
+			name:   "PKCS8",
+			keyPEM: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}),
+		},
+		{
+			name:    "not PEM",
+			keyPEM:  []byte("not a key"),
+			wantErr: true,
+		},
+		{
+			name:    "not RSA",
+			keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: ecPKCS8}),
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			jwt, err := appJWT(42, tc.keyPEM, now)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("appJWT() expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("appJWT() error = %v", err)
+			}
+			parts := strings.Split(jwt, ".")
+			if len(parts) != 3 {
+				t.Fatalf("appJWT() = %q, want 3 parts", jwt)
+			}
+			enc := base64.RawURLEncoding
+			var header, claims map[string]any
+			for i, v := range []*map[string]any{&header, &claims} {
+				b, err := enc.DecodeString(parts[i])
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := json.Unmarshal(b, v); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if diff := cmp.Diff(map[string]any{"alg": "RS256", "typ": "JWT"}, header); diff != "" {
+				t.Errorf("header mismatch (-want +got):\n%s", diff)
+			}
+			wantClaims := map[string]any{
+				"iat": float64(now.Add(-time.Minute).Unix()),
+				"exp": float64(now.Add(9 * time.Minute).Unix()),
+				"iss": "42",
+			}
+			if diff := cmp.Diff(wantClaims, claims); diff != "" {
+				t.Errorf("claims mismatch (-want +got):\n%s", diff)
+			}
+			sig, err := enc.DecodeString(parts[2])
+			if err != nil {
+				t.Fatal(err)
+			}
+			digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+			if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], sig); err != nil {
+				t.Errorf("signature verification failed: %v", err)
+			}
+		})
+	}
+}
+
+// tokenServer serves installation tokens, counting the requests made.
+type tokenServer struct {
+	*httptest.Server
+	requests atomic.Int32
+}
+
+func newTokenServer(t *testing.T, status int, expires time.Time, release <-chan struct{}) *tokenServer {
+	t.Helper()
+	s := &tokenServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		n := s.requests.Add(1)
+		if release != nil {
+			<-release
+		}
+		if r.URL.Path != "/app/installations/2/access_tokens" || !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+			http.Error(rw, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		rw.WriteHeader(status)
+		json.NewEncoder(rw).Encode(map[string]any{"token": fmt.Sprintf("token-%d", n), "expires_at": expires})
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func testAuthenticator(t *testing.T, apiURL string, now time.Time) (*authenticator, githubAppConfig) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	app := githubAppConfig{AppID: 1, InstallationID: 2, PrivateKeySecret: "secret", APIURL: apiURL}
+	a := newAuthenticator(credentialConfig{Hosts: map[string]hostCredentials{"github.com": {GitHubApp: &app}}}, func(context.Context, string) ([]byte, error) {
+		return keyPEM, nil
+	})
+	a.now = func() time.Time { return now }
+	return a, app
+}
+
+func TestInstallationToken(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0)
+	for _, tc := range []struct {
+		name         string
+		cached       *cachedToken
+		status       int
+		want         string
+		wantRequests int32
+		wantErr      bool
+	}{
+		{
+			name:         "fetch",
+			status:       http.StatusCreated,
+			want:         "token-1",
+			wantRequests: 1,
+		},
+		{
+			name:         "cached",
+			cached:       &cachedToken{token: "cached", expires: now.Add(time.Hour)},
+			status:       http.StatusCreated,
+			want:         "cached",
+			wantRequests: 0,
+		},
+		{
+			name:         "refresh near expiry",
+			cached:       &cachedToken{token: "cached", expires: now.Add(4 * time.Minute)},
+			status:       http.StatusCreated,
+			want:         "token-1",
+			wantRequests: 1,
+		},
+		{
+			name:         "refresh expired",
+			cached:       &cachedToken{token: "cached", expires: now.Add(-time.Minute)},
+			status:       http.StatusCreated,
+			want:         "token-1",
+			wantRequests: 1,
+		},
+		{
+			name:         "error",
+			status:       http.StatusUnauthorized,
+			wantRequests: 1,
+			wantErr:      true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newTokenServer(t, tc.status, now.Add(time.Hour), nil)
+			a, app := testAuthenticator(t, s.URL, now)
+			if tc.cached != nil {
+				a.tokens["github.com"] = *tc.cached
+			}
+			got, err := a.installationToken(ctx, "github.com", app)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("installationToken() = %q, want error", got)
+				}
+			} else if err != nil {
+				t.Errorf("installationToken() error = %v", err)
+			} else if got != tc.want {
+				t.Errorf("installationToken() = %q, want %q", got, tc.want)
+			}
+			if n := s.requests.Load(); n != tc.wantRequests {
+				t.Errorf("requests = %d, want %d", n, tc.wantRequests)
+			}
+			if !tc.wantErr {
+				// A valid token should be served from the cache.
+				if again, err := a.installationToken(ctx, "github.com", app); err != nil || again != got {
+					t.Errorf("installationToken() = %q, %v, want %q", again, err, got)
+				}
+				if n := s.requests.Load(); n != tc.wantRequests {
+					t.Errorf("requests after reuse = %d, want %d", n, tc.wantRequests)
+				}
+			}
+		})
+	}
+}
+
+func TestInstallationTokenConcurrent(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0)
+	release := make(chan struct{})
+	s := newTokenServer(t, http.StatusCreated, now.Add(time.Hour), release)
+	a, app := testAuthenticator(t, s.URL, now)
+	// Seed an expired token so each caller checks its expiry before fetching.
+	a.tokens["github.com"] = cachedToken{token: "expired", expires: now.Add(-time.Minute)}
+	var checks atomic.Int32
+	a.now = func() time.Time {
+		checks.Add(1)
+		return now
+	}
+	const callers = 10
+	var wg sync.WaitGroup
+	tokens := make([]string, callers)
+	errs := make([]error, callers)
+	for i := range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tokens[i], errs[i] = a.installationToken(ctx, "github.com", app)
+		}()
+	}
+	// Wait for every caller to miss the cache and for the fetch to start, then
+	// verify the cache remains readable while it is in flight.
+	// NOTE: The fetch itself calls now() once to issue the JWT.
+	deadline := time.Now().Add(5 * time.Second)
+	for checks.Load() < callers+1 || s.requests.Load() == 0 {
+		if time.Now().After(deadline) {
+			close(release)
+			t.Fatalf("callers blocked before fetching: %d expiry checks", checks.Load())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	a.mu.Lock()
+	a.tokens["other.com"] = cachedToken{token: "other", expires: now.Add(time.Hour)}
+	a.mu.Unlock()
+	read := make(chan string, 1)
+	go func() {
+		got, _ := a.installationToken(ctx, "other.com", app)
+		read <- got
+	}()
+	select {
+	case got := <-read:
+		if got != "other" {
+			t.Errorf("installationToken(other.com) = %q, want %q", got, "other")
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("installationToken(other.com) blocked on in-flight fetch")
+	}
+	close(release)
+	wg.Wait()
+	for i := range callers {
+		if errs[i] != nil || tokens[i] != "token-1" {
+			t.Errorf("installationToken() = %q, %v, want %q", tokens[i], errs[i], "token-1")
+		}
+	}
+	if n := s.requests.Load(); n != 1 {
+		t.Errorf("requests = %d, want 1", n)
+	}
+}
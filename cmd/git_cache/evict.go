@@ -0,0 +1,171 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/pkg/errors"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
+)
+
+// lastServedKey is the object metadata key recording when an entry was last served.
+const lastServedKey = "last-served"
+
+// lastServedGranularity bounds how often an entry's last-served time is updated.
+var lastServedGranularity = time.Hour
+
+// evictionPolicy describes the limits enforced on the cache.
+type evictionPolicy struct {
+	// TTL is the duration after which an entry not served is evicted.
+	TTL time.Duration
+	// MaxBytes is the total cache size above which the least recently served entries are evicted.
+	MaxBytes int64
+}
+
+func (p evictionPolicy) Enabled() bool {
+	return p.TTL > 0 || p.MaxBytes > 0
+}
+
+// cacheEntry is a cache object and the last time it was served.
+type cacheEntry struct {
+	Name       string
+	Size       int64
+	LastServed time.Time
+	// Generation and Metageneration identify the listed version of the object
+	// so it is not deleted if rewritten or served after being listed.
+	Generation     int64
+	Metageneration int64
+}
+
+func newCacheEntry(a *storage.ObjectAttrs) cacheEntry {
+	return cacheEntry{
+		Name:           a.Name,
+		Size:           a.Size,
+		LastServed:     lastServed(a),
+		Generation:     a.Generation,
+		Metageneration: a.Metageneration,
+	}
+}
+
+// isPreconditionFailed returns whether err reports that an object no longer matched the conditions of a request.
+func isPreconditionFailed(err error) bool {
+	var gerr *googleapi.Error
+	return errors.As(err, &gerr) && gerr.Code == http.StatusPreconditionFailed
+}
+
+// lastServed returns the time the object was last served, falling back to
+// the time it was written.
+func lastServed(a *storage.ObjectAttrs) time.Time {
+	if v, ok := a.Metadata[lastServedKey]; ok {
+		if t, err := time.Parse(time.RFC3339, v); err == nil && t.After(a.Updated) {
+			return t
+		}
+	}
+	return a.Updated
+}
+
+// touch records that the object was served at now, if it was not already
+// recorded within lastServedGranularity.
+func touch(ctx context.Context, o *storage.ObjectHandle, a *storage.ObjectAttrs, now time.Time) error {
+	if now.Sub(lastServed(a)) < lastServedGranularity {
+		return nil
+	}
+	// NOTE: Metadata updates do not change the object generation so served
+	// redirects remain valid.
+	_, err := o.If(storage.Conditions{MetagenerationMatch: a.Metageneration}).Update(ctx, storage.ObjectAttrsToUpdate{
+		Metadata: map[string]string{lastServedKey: now.UTC().Format(time.RFC3339)},
+	})
+	return err
+}
+
+// selectEvictions returns the entries to evict under the policy, least recently served first.
+func selectEvictions(entries []cacheEntry, p evictionPolicy, now time.Time) []cacheEntry {
+	sorted := make([]cacheEntry, len(entries))
+	copy(sorted, entries)
+	sort.Slice(sorted, func(i, j int) bool {
+		if !sorted[i].LastServed.Equal(sorted[j].LastServed) {
+			return sorted[i].LastServed.Before(sorted[j].LastServed)
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+	var total int64
+	for _, e := range sorted {
+		total += e.Size
+	}
+	var evict []cacheEntry
+	for _, e := range sorted {
+		expired := p.TTL > 0 && now.Sub(e.LastServed) > p.TTL
+		oversize := p.MaxBytes > 0 && total > p.MaxBytes
+		if !expired && !oversize {
+			break
+		}
+		evict = append(evict, e)
+		total -= e.Size
+	}
+	return evict
+}
+
+// evict deletes the cache entries in bucket selected by the policy.
+func evict(ctx context.Context, bucket *storage.BucketHandle, p evictionPolicy, now time.Time) (int, error) {
+	var entries []cacheEntry
+	it := bucket.Objects(ctx, &storage.Query{Projection: storage.ProjectionNoACL})
+	for {
+		a, err := it.Next()
+		if err == iterator.Done {
+			break
+		} else if err != nil {
+			return 0, errors.Wrap(err, "listing cache entries")
+		}
+		entries = append(entries, newCacheEntry(a))
+	}
+	var evicted int
+	for _, e := range selectEvictions(entries, p, now) {
+		o := bucket.Object(e.Name).If(storage.Conditions{GenerationMatch: e.Generation, MetagenerationMatch: e.Metageneration})
+		if err := o.Delete(ctx); isPreconditionFailed(err) {
+			log.Printf("Skipped evicting %s: modified since listed\n", e.Name)
+			continue
+		} else if err != nil && err != storage.ErrObjectNotExist {
+			return evicted, errors.Wrapf(err, "deleting %s", e.Name)
+		}
+		log.Printf("Evicted %s: last served %s\n", e.Name, e.LastServed.Format(time.RFC3339))
+		evicted++
+	}
+	return evicted, nil
+}
+
+// evictLoop periodically applies the eviction policy to the bucket until ctx is cancelled.
+func evictLoop(ctx context.Context, bucket *storage.BucketHandle, p evictionPolicy, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		n, err := evict(ctx, bucket, p, time.Now())
+		if err != nil {
+			log.Printf("Failed to evict cache entries: %v\n", err)
+		}
+		stats.Evict(int64(n))
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"google.golang.org/api/googleapi"
+)
+
+func TestLastServed(t *testing.T) {
+	updated := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		served string
+		want   time.Time
+	}{
+		{"", updated}, // Never served
+		{"2024-01-02T00:00:00Z", updated.Add(24 * time.Hour)}, // Served after write
+		{"2023-12-31T00:00:00Z", updated},                     // Served before rewrite
+		{"yesterday", updated},                                // Malformed
+	}
+	for _, test := range tests {
+		a := &storage.ObjectAttrs{Updated: updated}
+		if test.served != "" {
+			a.Metadata = map[string]string{lastServedKey: test.served}
+		}
+		if got := lastServed(a); !got.Equal(test.want) {
+			t.Errorf("lastServed(%q) = %v, want %v", test.served, got, test.want)
+		}
+	}
+}
+
+func TestNewCacheEntry(t *testing.T) {
+	updated := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	got := newCacheEntry(&storage.ObjectAttrs{
+		Name:           "github.com/org/repo/repo.tgz",
+		Size:           42,
+		Updated:        updated,
+		Generation:     7,
+		Metageneration: 3,
+	})
+	want := cacheEntry{Name: "github.com/org/repo/repo.tgz", Size: 42, LastServed: updated, Generation: 7, Metageneration: 3}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("newCacheEntry() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestIsPreconditionFailed(t *testing.T) {
+	if !isPreconditionFailed(errors.Wrap(&googleapi.Error{Code: http.StatusPreconditionFailed}, "deleting")) {
+		t.Error("isPreconditionFailed(412) = false, want true")
+	}
+	if isPreconditionFailed(&googleapi.Error{Code: http.StatusForbidden}) {
+		t.Error("isPreconditionFailed(403) = true, want false")
+	}
+	if isPreconditionFailed(storage.ErrObjectNotExist) {
+		t.Error("isPreconditionFailed(ErrObjectNotExist) = true, want false")
+	}
+}
+
+func TestSelectEvictions(t *testing.T) {
+	now := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	entries := []cacheEntry{
+		{Name: "recent", Size: 30, LastServed: now.Add(-time.Hour)},
+		{Name: "old", Size: 20, LastServed: now.Add(-5 * day)},
+		{Name: "older", Size: 10, LastServed: now.Add(-7 * day)},
+		{Name: "tied", Size: 10, LastServed: now.Add(-7 * day)},
+	}
+	tests := []struct {
+		policy evictionPolicy
+		want   []string
+	}{
+		{evictionPolicy{}, nil},
+		{evictionPolicy{TTL: 6 * day}, []string{"older", "tied"}},
+		{evictionPolicy{TTL: 30 * day}, nil},
+		{evictionPolicy{MaxBytes: 50}, []string{"older", "tied"}},
+		// Entries are evicted until the total is within the limit.
+		{evictionPolicy{MaxBytes: 30}, []string{"older", "tied", "old"}},
+		{evictionPolicy{MaxBytes: 100}, nil},
+		{evictionPolicy{TTL: 4 * day, MaxBytes: 100}, []string{"older", "tied", "old"}},
+	}
+	for _, test := range tests {
+		var got []string
+		for _, e := range selectEvictions(entries, test.policy, now) {
+			got = append(got, e.Name)
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("selectEvictions(%+v) mismatch (-want +got):\n%s", test.policy, diff)
+		}
+		if got := test.policy.Enabled(); got != (test.policy != evictionPolicy{}) {
+			t.Errorf("%+v.Enabled() = %v", test.policy, got)
+		}
+	}
+	if entries[0].Name != "recent" {
+		t.Error("selectEvictions() modified its input")
+	}
+}
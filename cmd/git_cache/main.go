@@ -19,6 +19,9 @@
 //	/get: Redirect to the GCS repo metadata cache object, populating the cache if necessary.
 //	  - uri: Git repo URI e.g. github.com/org/repo
 //	  - contains: The RFC3339-formatted time after which a cache entry must have been created.
+//	/healthz: Report the server is serving.
+//	/stats: Report cache request outcomes and in-flight clones per git host,
+//	  the most requested repos, the size of the cache bucket, and evictions.
 //
 // # Object Format
 //
@@ -27,21 +30,44 @@
 //
 // # Data Races
 //
-// Racing requests for the same resource will write and return different copies
-// of the repo but these are expected to be ~identical and, given the GCS
-// object versioning scheme, subsequent requests will converge to return the
-// latest version of the archive.
+// Concurrent cache misses for the same resource are coalesced within an
+// instance: a single request populates the cache entry while the others block
+// on its result.
 //
-// The current behavior could be improved by coalescing like requests and
-// blocking on a single writer.
+// Racing requests across instances will write and return different copies of
+// the repo but these are expected to be ~identical and, given the GCS object
+// versioning scheme, subsequent requests will converge to return the latest
+// version of the archive.
 //
 // # Cache Lifecycle
 //
 // If the caller provides the "contains" parameter that is more recent than the
 // most recent cache entry, it will be re-fetched and overwritten.
 //
-// There is currently no TTL for cache entries nor a size limitation for the
-// backing storage. These are areas for future work.
+// Entries record the time they were last served in their object metadata.
+// When --ttl or --max-size are provided, a background task evicts entries not
+// served within the TTL and then, least recently served first, those in excess
+// of the total size limit. Eviction runs every --evict-interval.
+//
+// # Authentication
+//
+// Private repos may be cached by providing a YAML credential config via the
+// --credentials flag. Credentials are configured per-host and reference
+// secrets stored in Secret Manager:
+//
+//	hosts:
+//	  github.com:
+//	    github_app:
+//	      app_id: 1234
+//	      installation_id: 5678
+//	      private_key_secret: projects/<project>/secrets/<secret>/versions/latest
+//	  git.example.com:
+//	    deploy_key:
+//	      private_key_secret: projects/<project>/secrets/<secret>/versions/latest
+//	      host_key: ssh-ed25519 AAAA...
+//
+// Credentials are never written to the cached archive: the cached remote
+// always refers to the repo's anonymous HTTPS URL.
 package main
 
 import (
@@ -55,6 +81,7 @@ import (
 	"log"
 	"net/http"
 	"net/url"
+	"os"
 	"path/filepath"
 	"strings"
 	"time"
@@ -66,14 +93,49 @@ import (
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/go-git/go-git/v5/storage/filesystem"
+	"github.com/google/oss-rebuild/internal/api"
 	"github.com/google/oss-rebuild/internal/uri"
 	"github.com/pkg/errors"
+	"golang.org/x/sync/singleflight"
 )
 
 var (
-	bucket = flag.String("bucket", "", "the bucket to use as the git cache")
+	bucket          = flag.String("bucket", "", "the bucket to use as the git cache")
+	credentials     = flag.String("credentials", "", "a YAML file configuring per-host credentials for private repos")
+	ttl             = flag.Duration("ttl", 0, "the duration after which a cache entry not served is evicted. 0 disables")
+	maxSize         = flag.Int64("max-size", 0, "the total cache size in bytes above which least recently served entries are evicted. 0 disables")
+	evictEvery      = flag.Duration("evict-interval", time.Hour, "the interval at which the eviction policy is applied")
+	drainTimeout    = flag.Duration("drain-timeout", 10*time.Second, "the time allowed for in-flight requests to complete on shutdown")
+	populateTimeout = flag.Duration("populate-timeout", 10*time.Minute, "the time allowed to populate a cache entry")
 )
 
+var auth *authenticator
+
+// populates coalesces concurrent cache populations, keyed by object path.
+var populates singleflight.Group
+
+// populateOnce populates the cache entry for repo using populate, sharing the
+// result with concurrent requests for the same key.
+//
+// NOTE: The populate is detached from the cancellation of ctx so the
+// disconnect of the request that started it does not fail those sharing it.
+func populateOnce(ctx context.Context, g *singleflight.Group, s *cacheStats, key, repo string, populate func(context.Context) error) error {
+	var leader bool
+	_, err, _ := g.Do(key, func() (any, error) {
+		leader = true
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), *populateTimeout)
+		defer cancel()
+		done := s.Populate(repo)
+		err := populate(ctx)
+		done(err)
+		return nil, err
+	})
+	if !leader {
+		s.Coalesce(repo)
+	}
+	return err
+}
+
 var thresholdFudgeFactor = 24 * time.Hour
 
 type getRequest struct {
@@ -133,13 +195,24 @@ func HandleGet(rw http.ResponseWriter, req *http.Request) {
 	}
 	// Normalize repo URI to provide the following interface:
 	// gs://<bucket>/<host>/<org>/<repo>/repo.tgz
-	p := filepath.Join(strings.ToLower(u), "repo.tgz")
+	repo := strings.ToLower(u)
+	p := filepath.Join(repo, "repo.tgz")
 	o := c.Bucket(*bucket).Object(p)
 	a, err := o.Attrs(ctx)
-	if err == nil && a.Updated.Before(r.Threshold) {
+	switch {
+	case err == nil && a.Updated.Before(r.Threshold):
 		// Overwrite cache entry that isn't sufficiently recent.
 		log.Printf("Refreshing cache for %s: entry fetched %s before requested %s\n", p, a.Updated.Format(time.RFC3339), r.Threshold.Format(time.RFC3339))
+		stats.Refresh(repo)
 		err = storage.ErrObjectNotExist
+	case err == nil:
+		stats.Hit(repo)
+		if err := touch(ctx, o, a, time.Now()); err != nil {
+			// Failing to record the access only affects eviction order.
+			log.Printf("Failed to update last-served for %s: %v\n", p, err)
+		}
+	case err == storage.ErrObjectNotExist:
+		stats.Miss(repo)
 	}
 	if err != nil {
 		switch err {
@@ -148,7 +221,10 @@ func HandleGet(rw http.ResponseWriter, req *http.Request) {
 			http.Error(rw, "Internal Error", 500)
 			return
 		case storage.ErrObjectNotExist:
-			if err := populateCache(ctx, u, o); err != nil {
+			err := populateOnce(ctx, &populates, stats, p, repo, func(ctx context.Context) error {
+				return populateCache(ctx, u, o)
+			})
+			if err != nil {
 				log.Printf("Failed to populate cache: %v\n", err)
 				if errors.Is(err, transport.ErrAuthenticationRequired) {
 					http.Error(rw, err.Error(), 400)
@@ -194,10 +270,25 @@ func populateCache(ctx context.Context, repo string, o *storage.ObjectHandle) er
 		return errors.Wrap(err, "failure allocating .git/")
 	}
 	s := filesystem.NewStorage(dotGit, nilCache{})
-	_, err = git.CloneContext(ctx, s, nil, &git.CloneOptions{URL: "https://" + repo, NoCheckout: true})
+	cloneURL, method, err := auth.Auth(ctx, repo)
+	if err != nil {
+		return errors.Wrapf(err, "failure authenticating for %s", repo)
+	}
+	r, err := git.CloneContext(ctx, s, nil, &git.CloneOptions{URL: cloneURL, Auth: method, NoCheckout: true})
 	if err != nil {
 		return errors.Wrapf(err, "failure cloning %s", repo)
 	}
+	if cloneURL != "https://"+repo {
+		// Replace the SSH remote so the cached repo is consumable without credentials.
+		cfg, err := r.Config()
+		if err != nil {
+			return errors.Wrapf(err, "failure reading config for %s", repo)
+		}
+		cfg.Remotes[git.DefaultRemoteName].URLs = []string{"https://" + repo}
+		if err := r.SetConfig(cfg); err != nil {
+			return errors.Wrapf(err, "failure writing config for %s", repo)
+		}
+	}
 	w := o.NewWriter(ctx)
 	gw := gzip.NewWriter(w)
 	tw := tar.NewWriter(gw)
@@ -255,8 +346,41 @@ func populateCache(ctx context.Context, repo string, o *storage.ObjectHandle) er
 
 func main() {
 	flag.Parse()
+	if *credentials != "" {
+		ctx := context.Background()
+		f, err := os.Open(*credentials)
+		if err != nil {
+			log.Fatalf("Failed to open credential config: %v", err)
+		}
+		cfg, err := parseCredentialConfig(f)
+		f.Close()
+		if err != nil {
+			log.Fatalf("Failed to parse credential config: %v", err)
+		}
+		fetcher, err := newSecretManagerFetcher(ctx)
+		if err != nil {
+			log.Fatalf("Failed to initialize Secret Manager: %v", err)
+		}
+		auth = newAuthenticator(*cfg, fetcher)
+	}
 	http.HandleFunc("/get", HandleGet)
-	if err := http.ListenAndServe(":8080", nil); err != nil {
+	http.HandleFunc("/healthz", HandleHealthz)
+	http.HandleFunc("/stats", HandleStats)
+	srv := &api.Server{Addr: ":8080", Handler: http.DefaultServeMux, DrainTimeout: *drainTimeout}
+	if p := (evictionPolicy{TTL: *ttl, MaxBytes: *maxSize}); p.Enabled() {
+		ctx, cancel := context.WithCancel(context.Background())
+		c, err := storage.NewClient(ctx)
+		if err != nil {
+			log.Fatalf("Failed to initialize GCS client: %v", err)
+		}
+		go evictLoop(ctx, c.Bucket(*bucket), p, *evictEvery)
+		// NOTE: Stop evicting once draining begins so a pass doesn't race shutdown.
+		srv.OnShutdown(func(context.Context) error {
+			cancel()
+			return nil
+		})
+	}
+	if err := srv.ListenAndServe(); err != nil {
 		log.Fatalln(err)
 	}
 }
@@ -0,0 +1,79 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+func TestPopulateOnce(t *testing.T) {
+	const repo = "github.com/org/repo"
+	const n = 5
+	var g singleflight.Group
+	s := newCacheStats()
+	var calls atomic.Int32
+	started := make(chan struct{}, n)
+	release := make(chan struct{})
+	populate := func(ctx context.Context) error {
+		calls.Add(1)
+		started <- struct{}{}
+		<-release
+		return ctx.Err()
+	}
+	leaderCtx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errs <- populateOnce(leaderCtx, &g, s, "key", repo, populate)
+	}()
+	<-started
+	for range n - 1 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- populateOnce(context.Background(), &g, s, "key", repo, populate)
+		}()
+	}
+	// NOTE: Allow the other requests to join the in-flight populate.
+	time.Sleep(50 * time.Millisecond)
+	// Simulate the client that started the populate disconnecting.
+	cancel()
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("populateOnce() error = %v", err)
+		}
+	}
+	b := s.Snapshot().Backends["github.com"]
+	if b.Populates != int64(calls.Load()) {
+		t.Errorf("Populates = %d, want %d", b.Populates, calls.Load())
+	}
+	if b.Populates+b.Coalesced != n {
+		t.Errorf("Populates + Coalesced = %d + %d, want %d", b.Populates, b.Coalesced, n)
+	}
+	if b.InFlight != 0 {
+		t.Errorf("InFlight = %d, want 0", b.InFlight)
+	}
+}
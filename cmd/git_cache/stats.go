@@ -0,0 +1,291 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/singleflight"
+	"google.golang.org/api/iterator"
+)
+
+// storageRefreshInterval bounds how often the bucket is listed to compute storage stats.
+var storageRefreshInterval = time.Minute
+
+// maxReportedRepos bounds the number of repos reported in /stats.
+const maxReportedRepos = 20
+
+// maxTrackedRepos bounds the number of repos for which stats are retained.
+const maxTrackedRepos = 1000
+
+// requestStats counts the outcomes of cache requests.
+type requestStats struct {
+	// Hits are requests served from an existing cache entry.
+	Hits int64 `json:"hits"`
+	// Misses are requests for which no cache entry existed.
+	Misses int64 `json:"misses"`
+	// Refreshes are requests for which the cache entry was too old.
+	Refreshes int64 `json:"refreshes"`
+}
+
+// backendStats describes the cache activity for a single git host.
+type backendStats struct {
+	requestStats
+	Populates        int64   `json:"populates"`
+	PopulateFailures int64   `json:"populate_failures"`
+	Coalesced        int64   `json:"coalesced"`
+	InFlight         int64   `json:"in_flight"`
+	HitRate          float64 `json:"hit_rate"`
+}
+
+// repoStats describes the cache activity for a single repo.
+type repoStats struct {
+	Repo string `json:"repo"`
+	requestStats
+}
+
+// storageStats describes the contents of the cache bucket.
+type storageStats struct {
+	Entries    int64     `json:"entries"`
+	TotalBytes int64     `json:"total_bytes"`
+	Updated    time.Time `json:"updated"`
+}
+
+// statsResponse is the /stats response body.
+type statsResponse struct {
+	UptimeSeconds int64                    `json:"uptime_seconds"`
+	Backends      map[string]*backendStats `json:"backends"`
+	TopRepos      []repoStats              `json:"top_repos"`
+	Storage       *storageStats            `json:"storage,omitempty"`
+	Evictions     int64                    `json:"evictions"`
+}
+
+// cacheStats accumulates request and clone statistics in memory.
+//
+// NOTE: Counters are per-instance and reset on restart.
+type cacheStats struct {
+	start time.Time
+
+	mu       sync.Mutex
+	backends map[string]*backendStats
+	repos    map[string]*requestStats
+	evicted  int64
+
+	storageMu    sync.Mutex
+	storage      *storageStats
+	storageFetch singleflight.Group
+}
+
+func newCacheStats() *cacheStats {
+	return &cacheStats{
+		start:    time.Now(),
+		backends: make(map[string]*backendStats),
+		repos:    make(map[string]*requestStats),
+	}
+}
+
+var stats = newCacheStats()
+
+func (s *cacheStats) get(repo string) (*backendStats, *requestStats) {
+	host, _, _ := strings.Cut(repo, "/")
+	b, ok := s.backends[host]
+	if !ok {
+		b = &backendStats{}
+		s.backends[host] = b
+	}
+	r, ok := s.repos[repo]
+	if !ok {
+		if len(s.repos) >= maxTrackedRepos {
+			s.evictRepo()
+		}
+		r = &requestStats{}
+		s.repos[repo] = r
+	}
+	return b, r
+}
+
+// evictRepo drops the least requested repo to make room for another.
+//
+// NOTE: Repos are only reported if among the most requested so evicting the
+// least requested bounds memory without affecting established leaders.
+func (s *cacheStats) evictRepo() {
+	var victim string
+	var least int64 = -1
+	for repo, r := range s.repos {
+		if total := r.Hits + r.Misses + r.Refreshes; least < 0 || total < least || (total == least && repo > victim) {
+			victim, least = repo, total
+		}
+	}
+	delete(s.repos, victim)
+}
+
+func (s *cacheStats) Hit(repo string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, r := s.get(repo)
+	b.Hits++
+	r.Hits++
+}
+
+func (s *cacheStats) Miss(repo string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, r := s.get(repo)
+	b.Misses++
+	r.Misses++
+}
+
+func (s *cacheStats) Refresh(repo string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, r := s.get(repo)
+	b.Refreshes++
+	r.Refreshes++
+}
+
+// Coalesce records a request that shared the result of a concurrent populate.
+func (s *cacheStats) Coalesce(repo string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, _ := s.get(repo)
+	b.Coalesced++
+}
+
+// Evict records the eviction of n cache entries.
+func (s *cacheStats) Evict(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.evicted += n
+}
+
+// Populate records the start of a clone of repo. The returned func must be
+// called with the clone's result upon completion.
+func (s *cacheStats) Populate(repo string) func(error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, _ := s.get(repo)
+	b.InFlight++
+	return func(err error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		b.InFlight--
+		b.Populates++
+		if err != nil {
+			b.PopulateFailures++
+		}
+	}
+}
+
+// Storage returns the storage stats for the bucket, listing it if the
+// previous result is older than storageRefreshInterval.
+//
+// NOTE: The bucket is listed without holding storageMu so a slow listing does
+// not block readers of the previous result. Concurrent listings are coalesced.
+func (s *cacheStats) Storage(ctx context.Context, bucket *storage.BucketHandle) (*storageStats, error) {
+	s.storageMu.Lock()
+	cached := s.storage
+	s.storageMu.Unlock()
+	if cached != nil && time.Since(cached.Updated) < storageRefreshInterval {
+		return cached, nil
+	}
+	v, err, _ := s.storageFetch.Do("", func() (any, error) {
+		st := &storageStats{Updated: time.Now()}
+		it := bucket.Objects(ctx, &storage.Query{Projection: storage.ProjectionNoACL})
+		for {
+			a, err := it.Next()
+			if err == iterator.Done {
+				break
+			} else if err != nil {
+				return nil, errors.Wrap(err, "listing cache entries")
+			}
+			st.Entries++
+			st.TotalBytes += a.Size
+		}
+		s.storageMu.Lock()
+		defer s.storageMu.Unlock()
+		s.storage = st
+		return st, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*storageStats), nil
+}
+
+// Snapshot returns the current request and clone statistics.
+func (s *cacheStats) Snapshot() statsResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp := statsResponse{
+		UptimeSeconds: int64(time.Since(s.start).Seconds()),
+		Backends:      make(map[string]*backendStats, len(s.backends)),
+		Evictions:     s.evicted,
+	}
+	for host, b := range s.backends {
+		c := *b
+		if total := c.Hits + c.Misses + c.Refreshes; total > 0 {
+			c.HitRate = float64(c.Hits) / float64(total)
+		}
+		resp.Backends[host] = &c
+	}
+	for repo, r := range s.repos {
+		resp.TopRepos = append(resp.TopRepos, repoStats{Repo: repo, requestStats: *r})
+	}
+	total := func(r repoStats) int64 { return r.Hits + r.Misses + r.Refreshes }
+	sort.Slice(resp.TopRepos, func(i, j int) bool {
+		if ti, tj := total(resp.TopRepos[i]), total(resp.TopRepos[j]); ti != tj {
+			return ti > tj
+		}
+		return resp.TopRepos[i].Repo < resp.TopRepos[j].Repo
+	})
+	if len(resp.TopRepos) > maxReportedRepos {
+		resp.TopRepos = resp.TopRepos[:maxReportedRepos]
+	}
+	return resp
+}
+
+func HandleHealthz(rw http.ResponseWriter, req *http.Request) {
+	rw.Write([]byte("ok"))
+}
+
+func HandleStats(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	resp := stats.Snapshot()
+	c, err := storage.NewClient(ctx)
+	if err != nil {
+		http.Error(rw, "Internal Error", 500)
+		return
+	}
+	defer c.Close()
+	if st, err := stats.Storage(ctx, c.Bucket(*bucket)); err != nil {
+		// Request stats remain useful in the absence of storage stats.
+		log.Printf("Failed to compute storage stats: %v\n", err)
+	} else {
+		resp.Storage = st
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(resp); err != nil {
+		log.Printf("Failed to encode stats: %v\n", err)
+	}
+}
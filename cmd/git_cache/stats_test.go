@@ -0,0 +1,135 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestCacheStatsSnapshot(t *testing.T) {
+	s := newCacheStats()
+	s.Hit("github.com/org/a")
+	s.Hit("github.com/org/a")
+	s.Miss("github.com/org/b")
+	s.Refresh("gitlab.com/org/c")
+	s.Coalesce("github.com/org/b")
+	s.Evict(3)
+	s.Evict(2)
+	s.Populate("github.com/org/b")(nil)
+	s.Populate("gitlab.com/org/c")(errors.New("clone failed"))
+	s.Populate("gitlab.com/org/c") // in flight
+	want := statsResponse{
+		Backends: map[string]*backendStats{
+			"github.com": {
+				requestStats: requestStats{Hits: 2, Misses: 1},
+				Populates:    1,
+				Coalesced:    1,
+				HitRate:      2. / 3,
+			},
+			"gitlab.com": {
+				requestStats:     requestStats{Refreshes: 1},
+				Populates:        1,
+				PopulateFailures: 1,
+				InFlight:         1,
+			},
+		},
+		TopRepos: []repoStats{
+			{Repo: "github.com/org/a", requestStats: requestStats{Hits: 2}},
+			{Repo: "github.com/org/b", requestStats: requestStats{Misses: 1}},
+			{Repo: "gitlab.com/org/c", requestStats: requestStats{Refreshes: 1}},
+		},
+		Evictions: 5,
+	}
+	got := s.Snapshot()
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(backendStats{}, repoStats{}), cmpopts.IgnoreFields(statsResponse{}, "UptimeSeconds")); diff != "" {
+		t.Errorf("Snapshot() mismatch (-want +got):\n%s", diff)
+	}
+	// The snapshot must not alias the live counters.
+	got.Backends["github.com"].Hits = 100
+	if h := s.Snapshot().Backends["github.com"].Hits; h != 2 {
+		t.Errorf("Snapshot() aliased counters: hits = %d, want 2", h)
+	}
+}
+
+func TestCacheStatsTopReposTruncated(t *testing.T) {
+	s := newCacheStats()
+	for i := 0; i < maxReportedRepos+5; i++ {
+		repo := fmt.Sprintf("github.com/org/repo%02d", i)
+		for j := 0; j <= i; j++ {
+			s.Hit(repo)
+		}
+	}
+	got := s.Snapshot().TopRepos
+	if len(got) != maxReportedRepos {
+		t.Fatalf("len(TopRepos) = %d, want %d", len(got), maxReportedRepos)
+	}
+	if want := fmt.Sprintf("github.com/org/repo%02d", maxReportedRepos+4); got[0].Repo != want {
+		t.Errorf("TopRepos[0] = %s, want %s", got[0].Repo, want)
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i].Hits > got[i-1].Hits {
+			t.Errorf("TopRepos not sorted by requests: %s (%d) after %s (%d)", got[i].Repo, got[i].Hits, got[i-1].Repo, got[i-1].Hits)
+		}
+	}
+}
+
+func TestCacheStatsTrackedReposBounded(t *testing.T) {
+	s := newCacheStats()
+	for i := 0; i < maxTrackedRepos; i++ {
+		repo := fmt.Sprintf("github.com/org/repo%04d", i)
+		for j := 0; j <= i; j++ {
+			s.Hit(repo)
+		}
+	}
+	s.Miss("github.com/org/new1")
+	s.Miss("github.com/org/new2")
+	if len(s.repos) != maxTrackedRepos {
+		t.Fatalf("len(repos) = %d, want %d", len(s.repos), maxTrackedRepos)
+	}
+	for _, tc := range []struct {
+		repo    string
+		tracked bool
+	}{
+		// The least requested repo is evicted to make room for the next.
+		{repo: "github.com/org/repo0000", tracked: false},
+		{repo: "github.com/org/new1", tracked: false},
+		{repo: "github.com/org/repo0001", tracked: true},
+		{repo: "github.com/org/repo0999", tracked: true},
+		{repo: "github.com/org/new2", tracked: true},
+	} {
+		if _, ok := s.repos[tc.repo]; ok != tc.tracked {
+			t.Errorf("repos[%s] tracked = %t, want %t", tc.repo, ok, tc.tracked)
+		}
+	}
+	// Host-level counters are unaffected by repo eviction.
+	wantHits := int64(maxTrackedRepos * (maxTrackedRepos + 1) / 2)
+	if b := s.backends["github.com"]; b.Hits != wantHits || b.Misses != 2 {
+		t.Errorf("backend counters = %+v, want %d hits and 2 misses", b.requestStats, wantHits)
+	}
+}
+
+func TestHandleHealthz(t *testing.T) {
+	rw := httptest.NewRecorder()
+	HandleHealthz(rw, httptest.NewRequest("GET", "/healthz", nil))
+	if rw.Code != 200 || rw.Body.String() != "ok" {
+		t.Errorf("HandleHealthz() = %d %q, want 200 %q", rw.Code, rw.Body.String(), "ok")
+	}
+}
@@ -20,29 +20,42 @@ import (
 	"log"
 	"net/http"
 	"net/url"
+	"os"
+	"time"
 
 	"github.com/google/oss-rebuild/internal/api"
 	"github.com/google/oss-rebuild/internal/api/inferenceservice"
+	"github.com/google/oss-rebuild/internal/config"
 	"github.com/google/oss-rebuild/internal/gitx"
 	"github.com/google/oss-rebuild/internal/httpegress"
+	"github.com/google/oss-rebuild/internal/ratex"
+	"github.com/google/oss-rebuild/internal/tracing"
 	"github.com/pkg/errors"
 	"google.golang.org/api/idtoken"
 	gapihttp "google.golang.org/api/transport/http"
 )
 
 var (
-	gitCacheURL = flag.String("git-cache-url", "", "if provided, the git-cache service to use to fetch repos")
+	gitCacheURL  = flag.String("git-cache-url", "", "if provided, the git-cache service to use to fetch repos")
+	drainTimeout = flag.Duration("drain-timeout", 10*time.Second, "the time allowed for in-flight requests to complete on shutdown")
+	otlpEndpoint = flag.String("otlp-endpoint", os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"), "if provided, the OTLP/HTTP endpoint to which to export trace spans")
 )
 
 var httpcfg = httpegress.Config{}
 
+var cfgLoader = config.Loader{EnvPrefix: "OSS_REBUILD_INFERENCE"}
+
+// registryLimiter is shared across requests so all outbound calls to a host observe the same limits.
+var registryLimiter = ratex.NewLimiter(0)
+
 func InferInit(ctx context.Context) (*inferenceservice.InferDeps, error) {
 	var d inferenceservice.InferDeps
 	var err error
-	d.HTTPClient, err = httpegress.MakeClient(ctx, httpcfg)
+	client, err := httpegress.MakeClient(ctx, httpcfg)
 	if err != nil {
 		return nil, errors.Wrap(err, "making http client")
 	}
+	d.HTTPClient = &ratex.Client{BasicClient: client, Limiter: registryLimiter, Retries: 2}
 	if *gitCacheURL != "" {
 		c, err := idtoken.NewClient(ctx, *gitCacheURL)
 		if err != nil {
@@ -63,10 +76,22 @@ func InferInit(ctx context.Context) (*inferenceservice.InferDeps, error) {
 
 func main() {
 	httpcfg.RegisterFlags(flag.CommandLine)
+	cfgLoader.RegisterFlags(flag.CommandLine)
 	flag.Parse()
+	cfgLoader.MustLoad(flag.CommandLine)
+	go registryLimiter.LogStats(context.Background(), 5*time.Minute)
 	http.HandleFunc("/infer", api.Handler(InferInit, inferenceservice.Infer))
 	http.HandleFunc("/version", api.Handler(api.NoDepsInit, inferenceservice.Version))
-	if err := http.ListenAndServe(":8080", nil); err != nil {
+	flushTraces, err := tracing.Setup(context.Background(), "inference", *otlpEndpoint)
+	if err != nil {
+		log.Fatalln(errors.Wrap(err, "configuring tracing"))
+	}
+	srv := &api.Server{Addr: ":8080", Handler: tracing.Handler(http.DefaultServeMux), DrainTimeout: *drainTimeout}
+	err = srv.ListenAndServe()
+	if err := flushTraces(context.Background()); err != nil {
+		log.Println(errors.Wrap(err, "flushing traces"))
+	}
+	if err != nil {
 		log.Fatalln(err)
 	}
 }
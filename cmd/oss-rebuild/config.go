@@ -0,0 +1,149 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the CLI configuration file.
+//
+// Values provided on the command line take precedence over the config file.
+type Config struct {
+	// Bucket overrides the GCS bucket from which attestations are read.
+	Bucket string `yaml:"bucket"`
+	// StorageEndpoint overrides the GCS API endpoint, for mirrored stores.
+	StorageEndpoint string `yaml:"storage_endpoint"`
+	// Output is the default output format for the get command.
+	Output string `yaml:"output"`
+	// Proxy is the URL of the HTTP(S) proxy through which to send requests.
+	Proxy string `yaml:"proxy"`
+	// CABundle is the path to a PEM file of additional trusted root CAs.
+	CABundle string `yaml:"ca_bundle"`
+	// TrustBundle is the path to a signed trust bundle of attestation keys.
+	TrustBundle string `yaml:"trust_bundle"`
+}
+
+// defaultConfigPath returns the path of the config file when none is specified.
+func defaultConfigPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "oss-rebuild", "config.yaml"), nil
+}
+
+// defaultTrustPinPath returns the path of the pinned trust bundle when none is specified.
+func defaultTrustPinPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "oss-rebuild", "trust-bundle.json"), nil
+}
+
+// loadConfig reads the config at path, or from the default path if empty.
+//
+// A missing file at the default path is treated as an empty config.
+func loadConfig(path string) (*Config, error) {
+	explicit := path != ""
+	if !explicit {
+		var err error
+		if path, err = defaultConfigPath(); err != nil {
+			return &Config{}, nil
+		}
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) && !explicit {
+		return &Config{}, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "opening config")
+	}
+	defer f.Close()
+	var cfg Config
+	d := yaml.NewDecoder(f)
+	d.KnownFields(true)
+	if err := d.Decode(&cfg); err != nil {
+		return nil, errors.Wrapf(err, "decoding %s", path)
+	}
+	return &cfg, nil
+}
+
+// applyFlags sets the flags of cmd not provided on the command line from cfg.
+func (cfg *Config) applyFlags(cmd *cobra.Command) error {
+	for name, val := range map[string]string{"bucket": cfg.Bucket, "output": cfg.Output, "trust-bundle": cfg.TrustBundle} {
+		if val == "" || cmd.Flags().Lookup(name) == nil || cmd.Flags().Changed(name) {
+			continue
+		}
+		if err := cmd.Flags().Set(name, val); err != nil {
+			return errors.Wrapf(err, "setting %s from config", name)
+		}
+	}
+	return nil
+}
+
+// clientOptions returns the GCS and KMS client options described by cfg.
+func (cfg *Config) clientOptions() (gcsOpts, kmsOpts []option.ClientOption, err error) {
+	if cfg.Proxy == "" && cfg.CABundle == "" && cfg.StorageEndpoint == "" {
+		return nil, nil, nil
+	}
+	tlsConfig := &tls.Config{}
+	if cfg.CABundle != "" {
+		pem, err := os.ReadFile(cfg.CABundle)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "reading CA bundle")
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, nil, errors.Errorf("no certificates found in %s", cfg.CABundle)
+		}
+		tlsConfig.RootCAs = pool
+		kmsOpts = append(kmsOpts, option.WithGRPCDialOption(grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))))
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	if cfg.Proxy != "" {
+		u, err := url.Parse(cfg.Proxy)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "parsing proxy URL")
+		}
+		transport.Proxy = http.ProxyURL(u)
+		// NOTE: gRPC only supports proxies configured via the environment.
+		if os.Getenv("HTTPS_PROXY") == "" && os.Getenv("https_proxy") == "" {
+			os.Setenv("HTTPS_PROXY", cfg.Proxy)
+		}
+	}
+	// NOTE: Attestations are public so GCS requests are unauthenticated.
+	gcsOpts = append(gcsOpts, option.WithHTTPClient(&http.Client{Transport: transport}))
+	if cfg.StorageEndpoint != "" {
+		gcsOpts = append(gcsOpts, option.WithEndpoint(cfg.StorageEndpoint))
+	}
+	return gcsOpts, kmsOpts, nil
+}
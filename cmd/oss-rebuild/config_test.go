@@ -0,0 +1,259 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/spf13/cobra"
+)
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		// explicit is whether the config path is passed rather than defaulted.
+		explicit bool
+		// content is written to the config path if non-empty.
+		content string
+		want    *Config
+		wantErr bool
+	}{
+		{
+			name:     "explicit",
+			explicit: true,
+			content:  "bucket: mirror\nproxy: http://proxy:3128\n",
+			want:     &Config{Bucket: "mirror", Proxy: "http://proxy:3128"},
+		},
+		{
+			name:     "explicit missing",
+			explicit: true,
+			wantErr:  true,
+		},
+		{
+			name:     "unknown field",
+			explicit: true,
+			content:  "bukcet: mirror\n",
+			wantErr:  true,
+		},
+		{
+			name:    "default",
+			content: "output: bundle\n",
+			want:    &Config{Output: "bundle"},
+		},
+		{
+			name: "default missing",
+			want: &Config{},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			t.Setenv("XDG_CONFIG_HOME", dir)
+			path := filepath.Join(dir, "oss-rebuild", "config.yaml")
+			if tc.explicit {
+				path = filepath.Join(dir, "explicit.yaml")
+			}
+			if tc.content != "" {
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(tc.content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			arg := ""
+			if tc.explicit {
+				arg = path
+			}
+			got, err := loadConfig(arg)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("loadConfig() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("loadConfig() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestApplyFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		cfg  Config
+		want map[string]string
+	}{
+		{
+			name: "empty config",
+			want: map[string]string{"bucket": "default-bucket", "output": "summary"},
+		},
+		{
+			name: "from config",
+			cfg:  Config{Bucket: "config-bucket", Output: "bundle"},
+			want: map[string]string{"bucket": "config-bucket", "output": "bundle"},
+		},
+		{
+			name: "command line precedence",
+			args: []string{"--bucket=flag-bucket"},
+			cfg:  Config{Bucket: "config-bucket", Output: "bundle"},
+			want: map[string]string{"bucket": "flag-bucket", "output": "bundle"},
+		},
+		{
+			// trust-bundle is not registered so is ignored.
+			name: "unregistered flag",
+			cfg:  Config{TrustBundle: "bundle.json"},
+			want: map[string]string{"bucket": "default-bucket", "output": "summary"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd := &cobra.Command{}
+			cmd.Flags().String("bucket", "default-bucket", "")
+			cmd.Flags().String("output", "summary", "")
+			if err := cmd.Flags().Parse(tc.args); err != nil {
+				t.Fatal(err)
+			}
+			if err := tc.cfg.applyFlags(cmd); err != nil {
+				t.Fatalf("applyFlags() error = %v", err)
+			}
+			got := make(map[string]string)
+			for name := range tc.want {
+				got[name] = cmd.Flags().Lookup(name).Value.String()
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("applyFlags() flags mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func selfSignedPEM(t *testing.T) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test CA"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IsCA:         true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestClientOptions(t *testing.T) {
+	type optionCounts struct{ GCS, KMS int }
+	ca := selfSignedPEM(t)
+	tests := []struct {
+		name string
+		cfg  Config
+		// caBundle is written to the path in cfg.CABundle if non-nil.
+		caBundle []byte
+		// env is the value of HTTPS_PROXY prior to the call.
+		env     string
+		want    optionCounts
+		wantEnv string
+		wantErr bool
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:    "proxy",
+			cfg:     Config{Proxy: "http://proxy:3128"},
+			want:    optionCounts{GCS: 1},
+			wantEnv: "http://proxy:3128",
+		},
+		{
+			name:    "proxy environment precedence",
+			cfg:     Config{Proxy: "http://proxy:3128"},
+			env:     "http://env:3128",
+			want:    optionCounts{GCS: 1},
+			wantEnv: "http://env:3128",
+		},
+		{
+			name:    "bad proxy",
+			cfg:     Config{Proxy: "://proxy"},
+			wantErr: true,
+		},
+		{
+			name: "storage endpoint",
+			cfg:  Config{StorageEndpoint: "https://storage.example.com"},
+			want: optionCounts{GCS: 2},
+		},
+		{
+			name:     "CA bundle",
+			cfg:      Config{CABundle: "ca.pem"},
+			caBundle: ca,
+			want:     optionCounts{GCS: 1, KMS: 1},
+		},
+		{
+			name:     "empty CA bundle",
+			cfg:      Config{CABundle: "ca.pem"},
+			caBundle: []byte{},
+			wantErr:  true,
+		},
+		{
+			name:    "missing CA bundle",
+			cfg:     Config{CABundle: "ca.pem"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("HTTPS_PROXY", tc.env)
+			t.Setenv("https_proxy", "")
+			cfg := tc.cfg
+			if cfg.CABundle != "" {
+				cfg.CABundle = filepath.Join(t.TempDir(), cfg.CABundle)
+				if tc.caBundle != nil {
+					if err := os.WriteFile(cfg.CABundle, tc.caBundle, 0644); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			gcsOpts, kmsOpts, err := cfg.clientOptions()
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("clientOptions() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			if diff := cmp.Diff(tc.want, optionCounts{GCS: len(gcsOpts), KMS: len(kmsOpts)}); diff != "" {
+				t.Errorf("clientOptions() option counts mismatch (-want +got):\n%s", diff)
+			}
+			if got := os.Getenv("HTTPS_PROXY"); got != tc.wantEnv {
+				t.Errorf("HTTPS_PROXY = %q, want %q", got, tc.wantEnv)
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log"
+	"path"
+	"time"
+
+	gcs "cloud.google.com/go/storage"
+	"github.com/google/oss-rebuild/pkg/attestation"
+	"github.com/google/oss-rebuild/pkg/attestation/verify"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export <ecosystem> [<package> [<version>]]",
+	Short: "Export a report of the rebuild attestations for a given query",
+	Long: `Export a machine-readable report of the rebuild attestations for an ecosystem, package, or version.
+Each attestation bundle is fetched and verified and one record is emitted per artifact describing its status, upstream, rebuild, and stabilized digests, and build timestamps. Bundles that fail verification or are stored at a path other than that of the artifact they attest are reported with an "invalid" status rather than aborting the export.`,
+	Args: cobra.RangeArgs(1, 3),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		var w exportWriter
+		switch *format {
+		case "jsonl":
+			w = &jsonlExportWriter{json.NewEncoder(cmd.OutOrStdout())}
+		case "csv":
+			w = &csvExportWriter{w: csv.NewWriter(cmd.OutOrStdout())}
+		default:
+			log.Fatal(errors.New("unsupported format: " + *format))
+		}
+		opts, err := verifyOptions(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		gcsClient, err := gcs.NewClient(ctx, append([]option.ClientOption{option.WithoutAuthentication()}, gcsOpts...)...)
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "initializing GCS client"))
+		}
+		// NOTE: The trailing separator prevents matching packages sharing a name prefix.
+		query := &gcs.Query{Prefix: path.Join(args...) + "/"}
+		query.SetAttrSelection([]string{"Name"})
+		it := gcsClient.Bucket(*bucket).Objects(ctx, query)
+		for {
+			obj, err := it.Next()
+			if err == iterator.Done {
+				break
+			}
+			if err != nil {
+				log.Fatal(errors.Wrap(err, "listing objects"))
+			}
+			if path.Base(obj.Name) != string(rebuild.AttestationBundleAsset) {
+				continue
+			}
+			rec := exportRecord{Object: obj.Name}
+			r, err := gcsClient.Bucket(*bucket).Object(obj.Name).NewReader(ctx)
+			if err != nil {
+				log.Fatal(errors.Wrapf(err, "opening %s", obj.Name))
+			}
+			data, err := io.ReadAll(r)
+			r.Close()
+			if err != nil {
+				log.Fatal(errors.Wrapf(err, "reading %s", obj.Name))
+			}
+			if bundle, err := verify.VerifyBundle(ctx, data, opts); err != nil {
+				rec.Status, rec.Error = "invalid", errors.Wrap(err, "verifying bundle").Error()
+			} else if err := rec.populate(bundle); err != nil {
+				rec.Status, rec.Error = "invalid", err.Error()
+			} else if *verifyFlag {
+				rec.Status = "verified"
+			} else {
+				rec.Status = "unverified"
+			}
+			if err := w.Write(rec); err != nil {
+				log.Fatal(errors.Wrap(err, "writing record"))
+			}
+		}
+		if err := w.Flush(); err != nil {
+			log.Fatal(errors.Wrap(err, "writing report"))
+		}
+	},
+}
+
+// exportRecord describes the rebuild attestation of a single artifact.
+type exportRecord struct {
+	Object           string     `json:"object"`
+	Status           string     `json:"status"`
+	Error            string     `json:"error,omitempty"`
+	Ecosystem        string     `json:"ecosystem,omitempty"`
+	Package          string     `json:"package,omitempty"`
+	Version          string     `json:"version,omitempty"`
+	Artifact         string     `json:"artifact,omitempty"`
+	UpstreamURI      string     `json:"upstream_uri,omitempty"`
+	UpstreamSHA256   string     `json:"upstream_sha256,omitempty"`
+	RebuildSHA256    string     `json:"rebuild_sha256,omitempty"`
+	StabilizedSHA256 string     `json:"stabilized_sha256,omitempty"`
+	InvocationID     string     `json:"invocation_id,omitempty"`
+	BuildStarted     *time.Time `json:"build_started,omitempty"`
+	BuildFinished    *time.Time `json:"build_finished,omitempty"`
+}
+
+var exportColumns = []string{"object", "status", "error", "ecosystem", "package", "version", "artifact", "upstream_uri", "upstream_sha256", "rebuild_sha256", "stabilized_sha256", "invocation_id", "build_started", "build_finished"}
+
+func (r exportRecord) row() []string {
+	ts := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+	return []string{r.Object, r.Status, r.Error, r.Ecosystem, r.Package, r.Version, r.Artifact, r.UpstreamURI, r.UpstreamSHA256, r.RebuildSHA256, r.StabilizedSHA256, r.InvocationID, ts(r.BuildStarted), ts(r.BuildFinished)}
+}
+
+// populate fills the record from the attestations in bundle.
+func (r *exportRecord) populate(bundle *attestation.Bundle) error {
+	rb, err := bundle.RebuildAttestation()
+	if err != nil {
+		return err
+	}
+	params, ok := rb.Predicate.BuildDefinition.ExternalParameters.(map[string]any)
+	if !ok {
+		return errors.New("rebuild attestation has malformed external parameters")
+	}
+	// NOTE: The target is read from the attestation rather than the object name
+	// since package names may themselves contain path separators.
+	str := func(k string) string { s, _ := params[k].(string); return s }
+	r.Ecosystem, r.Package, r.Version, r.Artifact = str("ecosystem"), str("package"), str("version"), str("artifact")
+	if len(rb.Subject) == 1 {
+		r.RebuildSHA256 = rb.Subject[0].Digest["sha256"]
+	}
+	r.InvocationID = rb.Predicate.RunDetails.BuildMetadata.InvocationID
+	r.BuildStarted = rb.Predicate.RunDetails.BuildMetadata.StartedOn
+	r.BuildFinished = rb.Predicate.RunDetails.BuildMetadata.FinishedOn
+	eq, err := bundle.EquivalenceAttestation()
+	if err != nil {
+		return err
+	}
+	if r.UpstreamURI, err = upstreamURI(eq); err != nil {
+		return err
+	}
+	if r.UpstreamSHA256, err = subjectDigest(eq); err != nil {
+		return err
+	}
+	t := rebuild.Target{Ecosystem: rebuild.Ecosystem(r.Ecosystem), Package: r.Package, Version: r.Version, Artifact: r.Artifact}
+	// NOTE: A bundle published at another target's path must not be reported
+	// as attesting the artifact at that path.
+	if want := bundleObject(t); r.Object != want {
+		return errors.Errorf("bundle attests %s but is stored at %s", want, r.Object)
+	}
+	if r.StabilizedSHA256, err = stabilizedDigest(eq, t); err != nil {
+		return err
+	}
+	return nil
+}
+
+// bundleObject returns the name of the object at which the bundle for t is published.
+func bundleObject(t rebuild.Target) string {
+	return path.Join(string(t.Ecosystem), t.Package, t.Version, t.Artifact, string(rebuild.AttestationBundleAsset))
+}
+
+type exportWriter interface {
+	Write(exportRecord) error
+	Flush() error
+}
+
+type jsonlExportWriter struct {
+	e *json.Encoder
+}
+
+func (w *jsonlExportWriter) Write(r exportRecord) error { return w.e.Encode(r) }
+func (w *jsonlExportWriter) Flush() error               { return nil }
+
+type csvExportWriter struct {
+	w       *csv.Writer
+	started bool
+}
+
+// header writes the column names ahead of the first record.
+func (w *csvExportWriter) header() error {
+	if w.started {
+		return nil
+	}
+	w.started = true
+	return w.w.Write(exportColumns)
+}
+
+func (w *csvExportWriter) Write(r exportRecord) error {
+	if err := w.header(); err != nil {
+		return err
+	}
+	return w.w.Write(r.row())
+}
+
+func (w *csvExportWriter) Flush() error {
+	// NOTE: The header is written even when no attestations match.
+	if err := w.header(); err != nil {
+		return err
+	}
+	w.w.Flush()
+	return w.w.Error()
+}
@@ -0,0 +1,208 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/internal/verifier"
+	"github.com/google/oss-rebuild/pkg/attestation"
+	"github.com/in-toto/in-toto-golang/in_toto"
+	slsa1 "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v1"
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+)
+
+type trustAll struct{}
+
+func (v *trustAll) Verify(ctx context.Context, data, sig []byte) error { return nil }
+func (v *trustAll) KeyID() (string, error)                             { return "", nil }
+func (v *trustAll) Public() crypto.PublicKey                           { return nil }
+
+// testBundle returns a bundle of unsigned envelopes wrapping stmts.
+func testBundle(t *testing.T, stmts ...in_toto.ProvenanceStatementSLSA1) *attestation.Bundle {
+	t.Helper()
+	var buf bytes.Buffer
+	for _, stmt := range stmts {
+		payload, err := json.Marshal(stmt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := json.NewEncoder(&buf).Encode(dsse.Envelope{
+			PayloadType: in_toto.PayloadType,
+			Payload:     base64.StdEncoding.EncodeToString(payload),
+			Signatures:  []dsse.Signature{{Sig: "c2ln"}},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	v, err := dsse.NewEnvelopeVerifier(&trustAll{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := attestation.NewBundle(context.Background(), buf.Bytes(), v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func statement(buildType string, params map[string]any, subject string, meta slsa1.BuildMetadata, byproducts ...slsa1.ResourceDescriptor) in_toto.ProvenanceStatementSLSA1 {
+	stmt := in_toto.ProvenanceStatementSLSA1{
+		StatementHeader: in_toto.StatementHeader{Type: in_toto.StatementInTotoV1, PredicateType: slsa1.PredicateSLSAProvenance},
+		Predicate: slsa1.ProvenancePredicate{
+			BuildDefinition: slsa1.ProvenanceBuildDefinition{BuildType: buildType, ExternalParameters: params},
+			RunDetails:      slsa1.ProvenanceRunDetails{BuildMetadata: meta, Byproducts: byproducts},
+		},
+	}
+	if subject != "" {
+		stmt.Subject = []in_toto.Subject{{Name: "artifact", Digest: map[string]string{"sha256": subject}}}
+	}
+	return stmt
+}
+
+func TestExportRecordPopulate(t *testing.T) {
+	started := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	finished := started.Add(time.Minute)
+	rebuildParams := map[string]any{"ecosystem": "npm", "package": "@scope/pkg", "version": "1.0.0", "artifact": "pkg-1.0.0.tgz"}
+	rb := statement(verifier.RebuildBuildType, rebuildParams, "rebuilt", slsa1.BuildMetadata{InvocationID: "inv", StartedOn: &started, FinishedOn: &finished})
+	eq := statement(verifier.ArtifactEquivalenceBuildType, map[string]any{"target": "https://registry.npmjs.org/@scope/pkg/-/pkg-1.0.0.tgz"}, "upstream", slsa1.BuildMetadata{},
+		slsa1.ResourceDescriptor{Name: "stabilized/upstream/pkg-1.0.0.tgz", Digest: map[string]string{"sha256": "stabilized"}})
+	eqUnstabilized := statement(verifier.ArtifactEquivalenceBuildType, map[string]any{"target": "https://example.com"}, "upstream", slsa1.BuildMetadata{})
+	const object = "npm/@scope/pkg/1.0.0/pkg-1.0.0.tgz/rebuild.intoto.jsonl"
+	tests := []struct {
+		name    string
+		object  string
+		stmts   []in_toto.ProvenanceStatementSLSA1
+		want    exportRecord
+		wantErr bool
+	}{
+		{
+			name:   "complete",
+			object: object,
+			stmts:  []in_toto.ProvenanceStatementSLSA1{rb, eq},
+			want: exportRecord{
+				Object:           object,
+				Ecosystem:        "npm",
+				Package:          "@scope/pkg",
+				Version:          "1.0.0",
+				Artifact:         "pkg-1.0.0.tgz",
+				UpstreamURI:      "https://registry.npmjs.org/@scope/pkg/-/pkg-1.0.0.tgz",
+				UpstreamSHA256:   "upstream",
+				RebuildSHA256:    "rebuilt",
+				StabilizedSHA256: "stabilized",
+				InvocationID:     "inv",
+				BuildStarted:     &started,
+				BuildFinished:    &finished,
+			},
+		},
+		{name: "missing equivalence", object: object, stmts: []in_toto.ProvenanceStatementSLSA1{rb}, wantErr: true},
+		{name: "missing rebuild", object: object, stmts: []in_toto.ProvenanceStatementSLSA1{eq}, wantErr: true},
+		{name: "missing stabilized digest", object: object, stmts: []in_toto.ProvenanceStatementSLSA1{rb, eqUnstabilized}, wantErr: true},
+		{name: "other package", object: "npm/pkg/1.0.0/pkg-1.0.0.tgz/rebuild.intoto.jsonl", stmts: []in_toto.ProvenanceStatementSLSA1{rb, eq}, wantErr: true},
+		{name: "other version", object: "npm/@scope/pkg/1.0.1/pkg-1.0.0.tgz/rebuild.intoto.jsonl", stmts: []in_toto.ProvenanceStatementSLSA1{rb, eq}, wantErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := exportRecord{Object: tc.object}
+			err := got.populate(testBundle(t, tc.stmts...))
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("populate() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("populate() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCSVExportWriter(t *testing.T) {
+	started := time.Date(2024, 1, 1, 0, 0, 0, 0, time.FixedZone("EST", -5*60*60))
+	tests := []struct {
+		name    string
+		records []exportRecord
+		want    [][]string
+	}{
+		{
+			name: "empty",
+			want: [][]string{exportColumns},
+		},
+		{
+			name: "records",
+			records: []exportRecord{
+				{Object: "npm/a/1.0.0/a-1.0.0.tgz/rebuild.intoto.jsonl", Status: "verified", Ecosystem: "npm", BuildStarted: &started},
+				{Object: "npm/b/1.0.0/b-1.0.0.tgz/rebuild.intoto.jsonl", Status: "invalid", Error: "verifying bundle: bad signature"},
+			},
+			want: [][]string{
+				exportColumns,
+				{"npm/a/1.0.0/a-1.0.0.tgz/rebuild.intoto.jsonl", "verified", "", "npm", "", "", "", "", "", "", "", "", "2024-01-01T05:00:00Z", ""},
+				{"npm/b/1.0.0/b-1.0.0.tgz/rebuild.intoto.jsonl", "invalid", "verifying bundle: bad signature", "", "", "", "", "", "", "", "", "", "", ""},
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := &csvExportWriter{w: csv.NewWriter(&buf)}
+			for _, r := range tc.records {
+				if err := w.Write(r); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			got, err := csv.NewReader(&buf).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("csv output mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestJSONLExportWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := &jsonlExportWriter{json.NewEncoder(&buf)}
+	for _, r := range []exportRecord{
+		{Object: "a", Status: "verified", Package: "a"},
+		{Object: "b", Status: "invalid", Error: "bad"},
+	} {
+		if err := w.Write(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"object":"a","status":"verified","package":"a"}
+{"object":"b","status":"invalid","error":"bad"}
+`
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("jsonl output mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -17,232 +17,233 @@ package main
 import (
 	"bytes"
 	"context"
-	"crypto"
-	"encoding/base64"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"log"
+	"os"
 	"path"
+	"path/filepath"
 	"strings"
+	"time"
 
-	kms "cloud.google.com/go/kms/apiv1"
-	"cloud.google.com/go/kms/apiv1/kmspb"
 	gcs "cloud.google.com/go/storage"
-	"github.com/google/oss-rebuild/internal/verifier"
-	"github.com/google/oss-rebuild/pkg/kmsdsse"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/google/oss-rebuild/pkg/attestation"
+	"github.com/google/oss-rebuild/pkg/attestation/verify"
 	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
-	"github.com/in-toto/in-toto-golang/in_toto"
 	"github.com/pkg/errors"
-	"github.com/secure-systems-lab/go-securesystemslib/dsse"
 	"github.com/spf13/cobra"
 	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 )
 
 var (
-	output = flag.String("output", "payload", "Output format [bundle, payload, dockerfile, build, steps]")
-	bucket = flag.String("bucket", "google-rebuild-attestations", "GCS bucket from which to pull rebuild attestations")
-	verify = flag.Bool("verify", true, "whether to verify attestation signatures using the default OSS Rebuild keys")
+	output     = flag.String("output", "payload", "Output format [bundle, payload, dockerfile, build, steps]")
+	bucket     = flag.String("bucket", "google-rebuild-attestations", "GCS bucket from which to pull rebuild attestations")
+	verifyFlag = flag.Bool("verify", true, "whether to verify attestation signatures using the default OSS Rebuild keys")
+	configPath = flag.String("config", "", "path to the config file. defaults to <user config dir>/oss-rebuild/config.yaml")
+	trustPath  = flag.String("trust-bundle", "", "path to a signed trust bundle of attestation keys. enables offline verification in place of Cloud KMS")
+	trustPin   = flag.String("trust-pin", "", "path at which the last trusted trust bundle is pinned. defaults to <user config dir>/oss-rebuild/trust-bundle.json")
+	upstream   = flag.String("upstream", "", "path to a local copy of the upstream artifact. if unset, the artifact is fetched from the attested upstream URL")
+	outDir     = flag.String("artifact-dir", ".", "directory to which the reproduced artifact is written")
+	format     = flag.String("format", "jsonl", "Export format [jsonl, csv]")
+	fromFile   = flag.String("from-file", "", "if provided, path to a downloaded attestation bundle to read in place of GCS")
+	fromDir    = flag.String("from-dir", "", "if provided, directory mirroring the attestation bucket layout from which to read bundles in place of GCS")
 )
 
+// Client options derived from the config file.
+var gcsOpts, kmsOpts []option.ClientOption
+
 var rootCmd = &cobra.Command{
 	Use:   "oss-rebuild [subcommand]",
 	Short: "A CLI tool for OSS Rebuild",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig(*configPath)
+		if err != nil {
+			return errors.Wrap(err, "loading config")
+		}
+		if err := cfg.applyFlags(cmd); err != nil {
+			return err
+		}
+		gcsOpts, kmsOpts, err = cfg.clientOptions()
+		return errors.Wrap(err, "configuring clients")
+	},
 }
 
-type VerifiedEnvelope struct {
-	Raw     *dsse.Envelope
-	Payload *in_toto.ProvenanceStatementSLSA1
-}
-
-type Bundle struct {
-	envelopes []VerifiedEnvelope
+func writeIndentedJson(out io.Writer, b []byte) error {
+	var decoded any
+	if err := json.NewDecoder(bytes.NewBuffer(b)).Decode(&decoded); err != nil {
+		return errors.Wrap(err, "decoding json")
+	}
+	e := json.NewEncoder(out)
+	e.SetIndent("", "  ")
+	if err := e.Encode(decoded); err != nil {
+		return errors.Wrap(err, "encoding json")
+	}
+	return nil
 }
 
-func decodeEnvelopePayload(e *dsse.Envelope) (*in_toto.ProvenanceStatementSLSA1, error) {
-	if e.Payload == "" {
-		return nil, errors.New("empty payload")
-	}
-	b, err := base64.StdEncoding.DecodeString(e.Payload)
-	if err != nil {
-		return nil, errors.Wrap(err, "decoding base64 payload")
+// inferTarget returns the target described by the <ecosystem> <package> <version> [<artifact>] args.
+//
+// When the artifact is omitted, it is inferred from the ecosystem's conventional artifact name.
+func inferTarget(cmd *cobra.Command, args []string) rebuild.Target {
+	ecosystem := rebuild.Ecosystem(args[0])
+	pkg := args[1]
+	version := args[2]
+	var artifact string
+	if len(args) < 4 {
+		switch ecosystem {
+		case rebuild.CratesIO:
+			artifact = fmt.Sprintf("%s-%s.crate", pkg, version)
+		case rebuild.PyPI:
+			artifact = fmt.Sprintf("%s-%s-py3-none-any.whl", strings.ReplaceAll(pkg, "-", "_"), version)
+			l := log.New(cmd.OutOrStderr(), "", 0)
+			l.Printf("pypi artifact is being inferred as %s\n", artifact)
+		case rebuild.NPM:
+			artifact = fmt.Sprintf("%s-%s.tgz", pkg, version)
+		case rebuild.GoMod:
+			artifact = fmt.Sprintf("%s.zip", version)
+		case rebuild.RubyGems:
+			artifact = fmt.Sprintf("%s-%s.gem", pkg, version)
+		default:
+			log.Fatalf("Unsupported ecosystem: \"%s\"", ecosystem)
+		}
+	} else {
+		artifact = args[3]
 	}
-	var decoded in_toto.ProvenanceStatementSLSA1
-	if err := json.Unmarshal(b, &decoded); err != nil {
-		return nil, errors.Wrap(err, "unmarshaling payload")
+	return rebuild.Target{
+		Ecosystem: ecosystem,
+		Package:   pkg,
+		Version:   version,
+		Artifact:  artifact,
 	}
-	return &decoded, nil
 }
 
-func NewBundle(ctx context.Context, data []byte, verifier *dsse.EnvelopeVerifier) (*Bundle, error) {
-	d := json.NewDecoder(bytes.NewBuffer(data))
-	var envelopes []VerifiedEnvelope
-	for {
-		var env dsse.Envelope
-		if err := d.Decode(&env); err != nil {
-			if err == io.EOF {
-				break
+// verifyOptions returns the bundle verification options described by the flags.
+func verifyOptions(ctx context.Context) (verify.Options, error) {
+	opts := verify.Options{TrustAll: !*verifyFlag, ClientOptions: kmsOpts}
+	if *trustPath != "" && *verifyFlag {
+		data, err := os.ReadFile(*trustPath)
+		if err != nil {
+			return opts, errors.Wrap(err, "reading trust bundle")
+		}
+		pin := *trustPin
+		if pin == "" {
+			if pin, err = defaultTrustPinPath(); err != nil {
+				return opts, errors.Wrap(err, "locating pinned trust bundle")
 			}
-			return nil, errors.Wrap(err, "decoding envelope")
 		}
-		if _, err := verifier.Verify(ctx, &env); err != nil {
-			return nil, errors.Wrap(err, "verifying envelope")
+		pinned, err := os.ReadFile(pin)
+		if err != nil && !os.IsNotExist(err) {
+			return opts, errors.Wrap(err, "reading pinned trust bundle")
 		}
-		payload, err := decodeEnvelopePayload(&env)
+		// NOTE: Absent a pin, the provided bundle is trusted on first use to authorize its own root keys.
+		opts.TrustBundle, err = verify.ParsePinnedTrustBundle(ctx, data, pinned, time.Now())
 		if err != nil {
-			return nil, errors.Wrap(err, "decoding payload")
+			return opts, errors.Wrap(err, "loading trust bundle")
+		}
+		if err := os.MkdirAll(filepath.Dir(pin), 0o755); err != nil {
+			return opts, errors.Wrap(err, "creating pin directory")
+		}
+		if err := os.WriteFile(pin, data, 0o644); err != nil {
+			return opts, errors.Wrap(err, "pinning trust bundle")
 		}
-		envelopes = append(envelopes, VerifiedEnvelope{
-			Raw:     &env,
-			Payload: payload,
-		})
 	}
-	return &Bundle{envelopes: envelopes}, nil
+	return opts, nil
 }
 
-func (b *Bundle) Payloads() []*in_toto.ProvenanceStatementSLSA1 {
-	result := make([]*in_toto.ProvenanceStatementSLSA1, len(b.envelopes))
-	for i, env := range b.envelopes {
-		result[i] = env.Payload
+// readBundle reads the attestation bundle for t from GCS or, if configured, the local filesystem.
+func readBundle(ctx context.Context, t rebuild.Target) ([]byte, error) {
+	if *fromFile != "" {
+		b, err := os.ReadFile(*fromFile)
+		return b, errors.Wrap(err, "reading attestation bundle")
 	}
-	return result
-}
-
-func (b *Bundle) RebuildAttestation() (*in_toto.ProvenanceStatementSLSA1, error) {
-	for _, env := range b.envelopes {
-		if env.Payload.Predicate.BuildDefinition.BuildType == verifier.RebuildBuildType {
-			return env.Payload, nil
+	var store rebuild.AssetStore
+	if *fromDir != "" {
+		store = rebuild.NewFilesystemAssetStore(osfs.New(*fromDir))
+	} else {
+		ctx = context.WithValue(ctx, rebuild.RunID, "")
+		ctx = context.WithValue(ctx, rebuild.GCSClientOptionsID, append([]option.ClientOption{option.WithoutAuthentication()}, gcsOpts...))
+		gcsStore, err := rebuild.NewGCSStore(ctx, "gs://"+*bucket)
+		if err != nil {
+			return nil, errors.Wrap(err, "initializing GCS store")
 		}
+		store = gcsStore
+	}
+	r, err := store.Reader(ctx, rebuild.AttestationBundleAsset.For(t))
+	if err != nil {
+		return nil, errors.Wrap(err, "creating attestation reader")
 	}
-	return nil, errors.New("no rebuild attestation found")
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	return b, errors.Wrap(err, "reading attestation bundle")
 }
 
-func (b *Bundle) Byproduct(name string) ([]byte, error) {
-	att, err := b.RebuildAttestation()
+// checkTarget returns an error if the rebuild attested in bundle is not of t.
+func checkTarget(bundle *attestation.Bundle, t rebuild.Target) error {
+	att, err := bundle.RebuildAttestation()
 	if err != nil {
-		return nil, errors.Wrap(err, "getting rebuild attestation")
+		return err
 	}
-	for _, b := range att.Predicate.RunDetails.Byproducts {
-		if b.Name == name {
-			return b.Content, nil
+	params, ok := att.Predicate.BuildDefinition.ExternalParameters.(map[string]any)
+	if !ok {
+		return errors.New("rebuild attestation has malformed external parameters")
+	}
+	for _, kv := range [][2]string{{"ecosystem", string(t.Ecosystem)}, {"package", t.Package}, {"version", t.Version}, {"artifact", t.Artifact}} {
+		if got, _ := params[kv[0]].(string); got != kv[1] {
+			return errors.Errorf("bundle attests to %s %q, want %q", kv[0], got, kv[1])
 		}
 	}
-	return nil, errors.Errorf("byproduct %q not found", name)
+	return nil
 }
 
-func makeKMSVerifier(ctx context.Context, cryptoKeyVersion string) (dsse.Verifier, error) {
-	kc, err := kms.NewKeyManagementClient(ctx)
-	if err != nil {
-		return nil, errors.Wrap(err, "creating KMS client")
+// fetchBundle reads and verifies the attestation bundle for t.
+func fetchBundle(ctx context.Context, t rebuild.Target) (*attestation.Bundle, []byte, error) {
+	if *fromFile != "" && *fromDir != "" {
+		return nil, nil, errors.New("--from-file and --from-dir are mutually exclusive")
+	}
+	local := *fromFile != "" || *fromDir != ""
+	if local && *verifyFlag && *trustPath == "" {
+		log.Println("NOTE: verifying with Cloud KMS requires network access. Provide --trust-bundle to verify offline.")
 	}
-	ckv, err := kc.GetCryptoKeyVersion(ctx, &kmspb.GetCryptoKeyVersionRequest{Name: cryptoKeyVersion})
+	bundleBytes, err := readBundle(ctx, t)
 	if err != nil {
-		return nil, errors.Wrap(err, "fetching CryptoKeyVersion")
+		return nil, nil, err
 	}
-	kmsVerifier, err := kmsdsse.NewCloudKMSSignerVerifier(ctx, kc, ckv)
+	opts, err := verifyOptions(ctx)
 	if err != nil {
-		return nil, errors.Wrap(err, "creating Cloud KMS verifier")
+		return nil, nil, err
 	}
-	return kmsVerifier, nil
-}
-
-type trustAllVerifier struct{}
-
-func (v *trustAllVerifier) Verify(ctx context.Context, data, sig []byte) error { return nil }
-func (v *trustAllVerifier) KeyID() (string, error)                             { return "", nil }
-func (v *trustAllVerifier) Public() crypto.PublicKey                           { return nil }
-
-func writeIndentedJson(out io.Writer, b []byte) error {
-	var decoded any
-	if err := json.NewDecoder(bytes.NewBuffer(b)).Decode(&decoded); err != nil {
-		return errors.Wrap(err, "decoding json")
+	bundle, err := verify.VerifyBundle(ctx, bundleBytes, opts)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "verifying bundle")
 	}
-	e := json.NewEncoder(out)
-	e.SetIndent("", "  ")
-	if err := e.Encode(decoded); err != nil {
-		return errors.Wrap(err, "encoding json")
+	// NOTE: Bundles read from GCS are bound to t by their location but a local
+	// bundle could have been produced for any target.
+	if local {
+		if err := checkTarget(bundle, t); err != nil {
+			return nil, nil, err
+		}
 	}
-	return nil
+	return bundle, bundleBytes, nil
 }
 
-const ossRebuildKey = "projects/oss-rebuild/locations/global/keyRings/ring/cryptoKeys/signing-key/cryptoKeyVersions/1"
-
 var getCmd = &cobra.Command{
 	Use:   "get <ecosystem> <package> <version> [<artifact>]",
 	Short: "Get rebuild attestation for a specific artifact.",
 	Long: `Get rebuild attestation for a specific ecosystem/package/version/artifact.
-The ecosystem is one of npm, pypi, or cratesio. For npm the artifact is the <package>-<version>.tar.gz file. For pypi the artifact is the wheel file. For cratesio the artifact is the <package>-<version>.crate file.`,
+The ecosystem is one of npm, pypi, cratesio, gomod, or rubygems. For npm the artifact is the <package>-<version>.tar.gz file. For pypi the artifact is the wheel file. For cratesio the artifact is the <package>-<version>.crate file. For gomod the artifact is the <version>.zip file. For rubygems the artifact is the <package>-<version>.gem file.`,
 	Args: cobra.MinimumNArgs(3),
 	Run: func(cmd *cobra.Command, args []string) {
 		if len(args) > 4 {
 			log.Fatal("Too many arguments")
 		}
-		var t rebuild.Target
-		{
-			ecosystem := rebuild.Ecosystem(args[0])
-			pkg := args[1]
-			version := args[2]
-			var artifact string
-			if len(args) < 4 {
-				switch ecosystem {
-				case rebuild.CratesIO:
-					artifact = fmt.Sprintf("%s-%s.crate", pkg, version)
-				case rebuild.PyPI:
-					artifact = fmt.Sprintf("%s-%s-py3-none-any.whl", strings.ReplaceAll(pkg, "-", "_"), version)
-					l := log.New(cmd.OutOrStderr(), "", 0)
-					l.Printf("pypi artifact is being inferred as %s\n", artifact)
-				case rebuild.NPM:
-					artifact = fmt.Sprintf("%s-%s.tgz", pkg, version)
-				default:
-					log.Fatalf("Unsupported ecosystem: \"%s\"", ecosystem)
-				}
-			} else {
-				artifact = args[3]
-			}
-			t = rebuild.Target{
-				Ecosystem: ecosystem,
-				Package:   pkg,
-				Version:   version,
-				Artifact:  artifact,
-			}
-		}
-		var bundle *Bundle
-		var bundleBytes []byte
-		{
-			ctx := cmd.Context()
-			ctx = context.WithValue(ctx, rebuild.RunID, "")
-			ctx = context.WithValue(ctx, rebuild.GCSClientOptionsID, []option.ClientOption{option.WithoutAuthentication()})
-			attestation, err := rebuild.NewGCSStore(ctx, "gs://"+*bucket)
-			if err != nil {
-				log.Fatal(errors.Wrap(err, "initializing GCS store"))
-			}
-			var verifier dsse.Verifier
-			if *verify {
-				verifier, err = makeKMSVerifier(ctx, ossRebuildKey)
-				if err != nil {
-					log.Fatal(err)
-				}
-			} else {
-				verifier = &trustAllVerifier{}
-			}
-			dsseVerifier, err := dsse.NewEnvelopeVerifier(verifier)
-			if err != nil {
-				log.Fatal(errors.Wrap(err, "creating EnvelopeVerifier"))
-			}
-			r, err := attestation.Reader(ctx, rebuild.AttestationBundleAsset.For(t))
-			if err != nil {
-				log.Fatal(errors.Wrap(err, "creating attestation reader"))
-			}
-			bundleBytes, err = io.ReadAll(r)
-			if err != nil {
-				log.Fatal(errors.Wrap(err, "creating attestation reader"))
-			}
-			bundle, err = NewBundle(ctx, bundleBytes, dsseVerifier)
-			if err != nil {
-				log.Fatal(errors.Wrap(err, "creating bundle"))
-			}
+		t := inferTarget(cmd, args)
+		bundle, bundleBytes, err := fetchBundle(cmd.Context(), t)
+		if err != nil {
+			log.Fatal(err)
 		}
 		switch *output {
 		case "bundle":
@@ -295,7 +296,7 @@ var listCmd = &cobra.Command{
 		if len(args) < 2 {
 			log.Fatal("Please include at least an ecosystem and package")
 		}
-		gcsClient, err := gcs.NewClient(cmd.Context(), option.WithoutAuthentication())
+		gcsClient, err := gcs.NewClient(cmd.Context(), append([]option.ClientOption{option.WithoutAuthentication()}, gcsOpts...)...)
 		if err != nil {
 			log.Fatal(errors.Wrap(err, "initializing GCS client"))
 		}
@@ -318,15 +319,50 @@ var listCmd = &cobra.Command{
 }
 
 func init() {
+	rootCmd.PersistentFlags().AddGoFlag(flag.Lookup("config"))
+
 	rootCmd.AddCommand(getCmd)
 
 	getCmd.Flags().AddGoFlag(flag.Lookup("output"))
 	getCmd.Flags().AddGoFlag(flag.Lookup("bucket"))
 	getCmd.Flags().AddGoFlag(flag.Lookup("verify"))
+	getCmd.Flags().AddGoFlag(flag.Lookup("trust-bundle"))
+	getCmd.Flags().AddGoFlag(flag.Lookup("trust-pin"))
+	getCmd.Flags().AddGoFlag(flag.Lookup("from-file"))
+	getCmd.Flags().AddGoFlag(flag.Lookup("from-dir"))
 
 	rootCmd.AddCommand(listCmd)
 
 	listCmd.Flags().AddGoFlag(flag.Lookup("bucket"))
+
+	rootCmd.AddCommand(verifyCmd)
+
+	verifyCmd.Flags().AddGoFlag(flag.Lookup("bucket"))
+	verifyCmd.Flags().AddGoFlag(flag.Lookup("verify"))
+	verifyCmd.Flags().AddGoFlag(flag.Lookup("trust-bundle"))
+	verifyCmd.Flags().AddGoFlag(flag.Lookup("trust-pin"))
+	verifyCmd.Flags().AddGoFlag(flag.Lookup("from-file"))
+	verifyCmd.Flags().AddGoFlag(flag.Lookup("from-dir"))
+	verifyCmd.Flags().AddGoFlag(flag.Lookup("upstream"))
+
+	rootCmd.AddCommand(reproduceCmd)
+
+	reproduceCmd.Flags().AddGoFlag(flag.Lookup("bucket"))
+	reproduceCmd.Flags().AddGoFlag(flag.Lookup("verify"))
+	reproduceCmd.Flags().AddGoFlag(flag.Lookup("trust-bundle"))
+	reproduceCmd.Flags().AddGoFlag(flag.Lookup("trust-pin"))
+	reproduceCmd.Flags().AddGoFlag(flag.Lookup("from-file"))
+	reproduceCmd.Flags().AddGoFlag(flag.Lookup("from-dir"))
+	reproduceCmd.Flags().AddGoFlag(flag.Lookup("upstream"))
+	reproduceCmd.Flags().AddGoFlag(flag.Lookup("artifact-dir"))
+
+	rootCmd.AddCommand(exportCmd)
+
+	exportCmd.Flags().AddGoFlag(flag.Lookup("bucket"))
+	exportCmd.Flags().AddGoFlag(flag.Lookup("verify"))
+	exportCmd.Flags().AddGoFlag(flag.Lookup("trust-bundle"))
+	exportCmd.Flags().AddGoFlag(flag.Lookup("trust-pin"))
+	exportCmd.Flags().AddGoFlag(flag.Lookup("format"))
 }
 
 func main() {
@@ -0,0 +1,113 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/google/oss-rebuild/pkg/attestation"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var reproduceCmd = &cobra.Command{
+	Use:   "reproduce <ecosystem> <package> <version> [<artifact>]",
+	Short: "Reproduce an attested rebuild locally using Docker.",
+	Long: `Reproduce the attested rebuild of a specific ecosystem/package/version/artifact locally using Docker.
+The Dockerfile is extracted from the verified attestation bundle, built, and run as in the attested build. The resulting artifact is written to --artifact-dir and verified against the upstream artifact as in the verify command. The artifact is inferred as in the get command.`,
+	Args: cobra.RangeArgs(3, 4),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		t := inferTarget(cmd, args)
+		bundle, _, err := fetchBundle(ctx, t)
+		if err != nil {
+			log.Fatal(err)
+		}
+		dockerfile, err := bundle.Byproduct("Dockerfile")
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "getting dockerfile"))
+		}
+		hermetic, err := attestedHermetic(bundle)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := os.MkdirAll(*outDir, 0755); err != nil {
+			log.Fatal(errors.Wrap(err, "creating artifact dir"))
+		}
+		dst := filepath.Join(*outDir, path.Base(t.Artifact))
+		if err := reproduce(ctx, cmd.ErrOrStderr(), dockerfile, hermetic, path.Base(t.Artifact), dst); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "reproduced %s\n", dst)
+		if err := verifyArtifact(cmd, bundle, t, dst); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+// reproduce builds and runs dockerfile, copying the artifact it produces to dst.
+func reproduce(ctx context.Context, logs io.Writer, dockerfile []byte, hermetic bool, artifact, dst string) error {
+	// NOTE: A unique name avoids collisions with concurrent or prior runs.
+	name := fmt.Sprintf("oss-rebuild-reproduce-%d", time.Now().UnixNano())
+	if err := docker(ctx, bytes.NewReader(dockerfile), logs, "buildx", "build", "--tag="+name, "-"); err != nil {
+		return errors.Wrap(err, "building image")
+	}
+	defer docker(context.Background(), nil, io.Discard, "rmi", name)
+	runArgs := []string{"run", "--name=" + name}
+	if hermetic {
+		runArgs = append(runArgs, "--network=none")
+	}
+	runArgs = append(runArgs, name)
+	err := docker(ctx, nil, logs, runArgs...)
+	defer docker(context.Background(), nil, io.Discard, "rm", name)
+	if err != nil {
+		return errors.Wrap(err, "running build")
+	}
+	if err := docker(ctx, nil, logs, "cp", name+":"+path.Join("/out", artifact), dst); err != nil {
+		return errors.Wrap(err, "copying artifact")
+	}
+	return nil
+}
+
+func docker(ctx context.Context, stdin io.Reader, logs io.Writer, args ...string) error {
+	c := exec.CommandContext(ctx, "docker", args...)
+	c.Stdin = stdin
+	c.Stdout = logs
+	c.Stderr = logs
+	return errors.Wrapf(c.Run(), "docker %s", args[0])
+}
+
+// attestedHermetic returns whether the attested build was run without network access.
+func attestedHermetic(bundle *attestation.Bundle) (bool, error) {
+	att, err := bundle.RebuildAttestation()
+	if err != nil {
+		return false, err
+	}
+	params, ok := att.Predicate.BuildDefinition.ExternalParameters.(map[string]any)
+	if !ok {
+		return false, errors.New("rebuild attestation has malformed external parameters")
+	}
+	hermetic, _ := params["hermetic"].(bool)
+	return hermetic, nil
+}
@@ -0,0 +1,136 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/internal/verifier"
+	slsa1 "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v1"
+)
+
+// fakeDocker installs a docker executable on PATH which logs its invocations
+// and fails when invoked with the subcommand failOn.
+func fakeDocker(t *testing.T, failOn string) (log string) {
+	t.Helper()
+	dir := t.TempDir()
+	log = filepath.Join(dir, "calls")
+	script := `#!/bin/sh
+echo "$*" | sed 's/oss-rebuild-reproduce-[0-9]*/NAME/g' >> ` + log + `
+[ "$1" = "` + failOn + `" ] && exit 1
+case "$1" in
+  buildx) cat > /dev/null ;;
+  cp) echo "artifact" > "$3" ;;
+esac
+exit 0
+`
+	if err := os.WriteFile(filepath.Join(dir, "docker"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return log
+}
+
+func TestReproduce(t *testing.T) {
+	const (
+		build = "buildx build --tag=NAME -"
+		run   = "run --name=NAME NAME"
+		cp    = "cp NAME:/out/pkg-1.0.0.tgz DST"
+		rm    = "rm NAME"
+		rmi   = "rmi NAME"
+	)
+	tests := []struct {
+		name string
+		// buildType is that of the attestation carrying params. Defaults to the rebuild attestation.
+		buildType string
+		params    map[string]any
+		failOn    string
+		wantCalls []string
+		wantErr   bool
+	}{
+		{
+			name:      "hermetic",
+			params:    map[string]any{"hermetic": true},
+			wantCalls: []string{build, "run --name=NAME --network=none NAME", cp, rm, rmi},
+		},
+		{
+			name:      "not hermetic",
+			params:    map[string]any{"hermetic": false},
+			wantCalls: []string{build, run, cp, rm, rmi},
+		},
+		{
+			name:      "hermetic unspecified",
+			params:    map[string]any{"ecosystem": "npm"},
+			wantCalls: []string{build, run, cp, rm, rmi},
+		},
+		{
+			name:      "missing rebuild attestation",
+			buildType: verifier.ArtifactEquivalenceBuildType,
+			params:    map[string]any{"hermetic": true},
+			wantErr:   true,
+		},
+		{
+			name:      "build failure",
+			params:    map[string]any{},
+			failOn:    "buildx",
+			wantCalls: []string{build},
+			wantErr:   true,
+		},
+		{
+			name:      "run failure cleans up",
+			params:    map[string]any{},
+			failOn:    "run",
+			wantCalls: []string{build, run, rm, rmi},
+			wantErr:   true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			log := fakeDocker(t, tc.failOn)
+			dst := filepath.Join(t.TempDir(), "pkg-1.0.0.tgz")
+			buildType := tc.buildType
+			if buildType == "" {
+				buildType = verifier.RebuildBuildType
+			}
+			hermetic, err := attestedHermetic(testBundle(t, statement(buildType, tc.params, "", slsa1.BuildMetadata{})))
+			if err == nil {
+				err = reproduce(context.Background(), io.Discard, []byte("FROM alpine"), hermetic, "pkg-1.0.0.tgz", dst)
+			}
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("reproduce error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if !tc.wantErr {
+				if b, err := os.ReadFile(dst); err != nil || string(b) != "artifact\n" {
+					t.Errorf("reproduced artifact = %q, %v, want %q", b, err, "artifact\n")
+				}
+			}
+			var got []string
+			if b, err := os.ReadFile(log); err == nil {
+				got = strings.Split(strings.TrimSpace(strings.ReplaceAll(string(b), dst, "DST")), "\n")
+			} else if !os.IsNotExist(err) {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.wantCalls, got); diff != "" {
+				t.Errorf("docker calls mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+
+	"github.com/google/oss-rebuild/pkg/archive"
+	"github.com/google/oss-rebuild/pkg/attestation"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/in-toto/in-toto-golang/in_toto"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify <artifact-file> <ecosystem> <package> <version> [<artifact>]",
+	Short: "Verify a local artifact against its rebuild attestation.",
+	Long: `Verify a local artifact against the rebuild attestation for a specific ecosystem/package/version/artifact.
+The attestation bundle is fetched and verified, and the upstream artifact is fetched from the attested URL (or read from --upstream) and checked against the attested digest. Both artifacts are then stabilized locally using the attested stabilizers and the resulting digests are compared to the attested stabilized digest. The artifact is inferred as in the get command.`,
+	Args: cobra.RangeArgs(4, 5),
+	Run: func(cmd *cobra.Command, args []string) {
+		t := inferTarget(cmd, args[1:])
+		bundle, _, err := fetchBundle(cmd.Context(), t)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := verifyArtifact(cmd, bundle, t, args[0]); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+// verifyArtifact confirms that the artifact at path stabilizes to the digest attested in bundle.
+//
+// The upstream artifact is first checked against the attestation to ensure
+// the local stabilizers reproduce the attested stabilized digest.
+func verifyArtifact(cmd *cobra.Command, bundle *attestation.Bundle, t rebuild.Target, path string) error {
+	ctx := cmd.Context()
+	eq, err := bundle.EquivalenceAttestation()
+	if err != nil {
+		return err
+	}
+	upstreamDigest, err := subjectDigest(eq)
+	if err != nil {
+		return err
+	}
+	attested, err := stabilizedDigest(eq, t)
+	if err != nil {
+		return err
+	}
+	opts, err := attestedStabilizeOpts(bundle, t)
+	if err != nil {
+		return err
+	}
+	l := log.New(cmd.OutOrStderr(), "", 0)
+	var upstreamReader io.ReadCloser
+	if *upstream != "" {
+		upstreamReader, err = os.Open(*upstream)
+		if err != nil {
+			return errors.Wrap(err, "opening upstream artifact")
+		}
+	} else {
+		uri, err := upstreamURI(eq)
+		if err != nil {
+			return err
+		}
+		l.Printf("fetching upstream artifact from %s\n", uri)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+		if err != nil {
+			return errors.Wrap(err, "creating upstream request")
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return errors.Wrap(err, "fetching upstream artifact")
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return errors.Errorf("fetching upstream artifact: %s", resp.Status)
+		}
+		upstreamReader = resp.Body
+	}
+	up, err := summarize(upstreamReader, t, opts)
+	upstreamReader.Close()
+	if err != nil {
+		return errors.Wrap(err, "stabilizing upstream artifact")
+	}
+	if up.digest != upstreamDigest {
+		return errors.Errorf("upstream artifact digest sha256:%s does not match attested sha256:%s", up.digest, upstreamDigest)
+	}
+	// NOTE: A mismatch here implicates the local stabilizers rather than the
+	// artifact since the upstream input was confirmed to be the attested one.
+	if up.stabilized != attested {
+		return errors.Errorf("stabilized upstream digest sha256:%s does not match attested sha256:%s. the local stabilizers may differ from those used by the rebuilder", up.stabilized, attested)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Wrap(err, "opening artifact")
+	}
+	local, err := summarize(f, t, opts)
+	f.Close()
+	if err != nil {
+		return errors.Wrap(err, "stabilizing artifact")
+	}
+	if local.stabilized != attested {
+		return errors.Errorf("FAILED: stabilized artifact digest sha256:%s does not match attested sha256:%s", local.stabilized, attested)
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "OK: %s stabilizes to attested digest sha256:%s\n", path, attested)
+	if local.digest == upstreamDigest {
+		fmt.Fprintf(cmd.OutOrStdout(), "%s is identical to the upstream artifact\n", path)
+	}
+	return nil
+}
+
+type artifactDigests struct {
+	digest     string
+	stabilized string
+}
+
+// summarize returns the sha256 digests of the artifact read from r before and after stabilization.
+func summarize(r io.Reader, t rebuild.Target, opts archive.StabilizeOpts) (artifactDigests, error) {
+	raw, stabilized := sha256.New(), sha256.New()
+	if err := archive.StabilizeWithOpts(stabilized, io.TeeReader(r, raw), t.ArchiveType(), opts); err != nil {
+		return artifactDigests{}, err
+	}
+	// NOTE: Drain any trailing bytes not consumed by stabilization so the raw digest covers the whole artifact.
+	if _, err := io.Copy(raw, r); err != nil {
+		return artifactDigests{}, errors.Wrap(err, "reading artifact")
+	}
+	return artifactDigests{digest: hex.EncodeToString(raw.Sum(nil)), stabilized: hex.EncodeToString(stabilized.Sum(nil))}, nil
+}
+
+// attestedStabilizeOpts returns the stabilizers for t restricted to those recorded in the bundle.
+//
+// Bundles published before stabilizations were attested fall back to the full set for t.
+func attestedStabilizeOpts(bundle *attestation.Bundle, t rebuild.Target) (archive.StabilizeOpts, error) {
+	opts := rebuild.StabilizeOpts(t)
+	if _, err := bundle.StabilizationAttestation(); err != nil {
+		return opts, nil
+	}
+	names, err := bundle.Stabilizers()
+	if err != nil {
+		return opts, errors.Wrap(err, "reading attested stabilizers")
+	}
+	opts.Stabilizers = slices.DeleteFunc(slices.Clone(opts.Stabilizers), func(s any) bool {
+		return !slices.Contains(names, archive.StabilizerName(s))
+	})
+	return opts, nil
+}
+
+func subjectDigest(eq *in_toto.ProvenanceStatementSLSA1) (string, error) {
+	if len(eq.Subject) != 1 || eq.Subject[0].Digest["sha256"] == "" {
+		return "", errors.New("equivalence attestation has no sha256 subject digest")
+	}
+	return eq.Subject[0].Digest["sha256"], nil
+}
+
+// stabilizedDigest returns the attested sha256 digest of the stabilized upstream artifact.
+func stabilizedDigest(eq *in_toto.ProvenanceStatementSLSA1, t rebuild.Target) (string, error) {
+	// NOTE: Older attestations only include the "normalized" byproduct.
+	for _, name := range []string{path.Join("stabilized", "upstream", t.Artifact), path.Join("normalized", t.Artifact)} {
+		for _, bp := range eq.Predicate.RunDetails.Byproducts {
+			if bp.Name == name && bp.Digest["sha256"] != "" {
+				return bp.Digest["sha256"], nil
+			}
+		}
+	}
+	return "", errors.New("equivalence attestation has no sha256 stabilized digest")
+}
+
+func upstreamURI(eq *in_toto.ProvenanceStatementSLSA1) (string, error) {
+	params, ok := eq.Predicate.BuildDefinition.ExternalParameters.(map[string]any)
+	if !ok {
+		return "", errors.New("equivalence attestation has malformed external parameters")
+	}
+	uri, ok := params["target"].(string)
+	if !ok || uri == "" {
+		return "", errors.New("equivalence attestation has no upstream target")
+	}
+	return uri, nil
+}
@@ -3,6 +3,7 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"flag"
 	"log"
@@ -33,13 +34,31 @@ var (
 	dockerProxySocket = flag.Bool("docker_recursive_proxy", false, "whether to patch containers with a unix domain socket which proxies docker requests from created containers")
 	policyMode        = flag.String("policy_mode", "disabled", "mode to run the proxy in. Options: disabled, enforce")
 	policyFile        = flag.String("policy_file", "", "path to a json file specifying the policy to apply to the proxy")
+	phasePolicyFile   = flag.String("phase_policy_file", "", "path to a json file mapping build phase names to the policy applied upon entering that phase")
+	caCertFile        = flag.String("ca_cert_file", "", "path to a PEM-encoded CA certificate to use in place of an ephemeral one. requires ca_key_file")
+	caKeyFile         = flag.String("ca_key_file", "", "path to the PEM-encoded private key of ca_cert_file")
 )
 
 func main() {
 	flag.Parse()
 
-	// Configure ephemeral CA for proxy.
-	ca := cert.GenerateCA()
+	// Configure CA for proxy, ephemeral unless one is provided.
+	var ca *tls.Certificate
+	if *caCertFile == "" && *caKeyFile == "" {
+		ca = cert.GenerateCA()
+	} else {
+		certPEM, err := os.ReadFile(*caCertFile)
+		if err != nil {
+			log.Fatalf("Error reading CA cert: %v", err)
+		}
+		keyPEM, err := os.ReadFile(*caKeyFile)
+		if err != nil {
+			log.Fatalf("Error reading CA key: %v", err)
+		}
+		if ca, err = cert.LoadCA(certPEM, keyPEM); err != nil {
+			log.Fatalf("Error loading CA: %v", err)
+		}
+	}
 	proxy.ConfigureGoproxyCA(ca)
 
 	// Create and configure proxy server.
@@ -59,8 +78,19 @@ func main() {
 			log.Fatalf("Error unmarshaling policy file content: %v", err)
 		}
 	}
+	var phasePolicies map[string]*policy.Policy
+	if *phasePolicyFile != "" {
+		content, err := os.ReadFile(*phasePolicyFile)
+		if err != nil {
+			log.Fatalf("Error reading phase policy file: %v", err)
+		}
+		if err := json.Unmarshal(content, &phasePolicies); err != nil {
+			log.Fatalf("Error unmarshaling phase policy file content: %v", err)
+		}
+	}
 	proxyService := proxy.NewTransparentProxyService(p, ca, proxy.PolicyMode(*policyMode), proxy.TransparentProxyServiceOpts{
-		Policy: &pl,
+		Policy:        &pl,
+		PhasePolicies: phasePolicies,
 	})
 	proxyService.Proxy.OnRequest().DoFunc(
 		func(req *http.Request, ctx *goproxy.ProxyCtx) (*http.Request, *http.Response) {
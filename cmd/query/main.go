@@ -0,0 +1,58 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// query serves a public, read-only API and status badges describing published rebuild attestations.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"time"
+
+	gcs "cloud.google.com/go/storage"
+	"github.com/google/oss-rebuild/internal/api"
+	"github.com/google/oss-rebuild/internal/query"
+	"google.golang.org/api/option"
+)
+
+var (
+	bucket       = flag.String("bucket", "google-rebuild-attestations", "GCS bucket from which to read rebuild attestations")
+	publicURL    = flag.String("public-url", "", "the public URL of the bucket used in bundle links. Defaults to https://storage.googleapis.com/<bucket>/")
+	maxAge       = flag.Duration("max-age", 15*time.Minute, "the duration for which responses may be cached")
+	addr         = flag.String("addr", ":8080", "the address on which to serve")
+	drainTimeout = flag.Duration("drain-timeout", 10*time.Second, "the time allowed for in-flight requests to complete on shutdown")
+)
+
+func main() {
+	flag.Parse()
+	ctx := context.Background()
+	// NOTE: Attestations are public so GCS requests are unauthenticated.
+	client, err := gcs.NewClient(ctx, option.WithoutAuthentication())
+	if err != nil {
+		log.Fatalln(err)
+	}
+	base := *publicURL
+	if base == "" {
+		base = "https://storage.googleapis.com/" + *bucket + "/"
+	}
+	s, err := query.NewServer(&query.GCSLister{Bucket: client.Bucket(*bucket)}, base, *maxAge)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	srv := &api.Server{Addr: *addr, Handler: s.Handler(), DrainTimeout: *drainTimeout}
+	if err := srv.ListenAndServe(); err != nil {
+		log.Fatalln(err)
+	}
+}
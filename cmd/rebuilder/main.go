@@ -22,12 +22,17 @@ import (
 	"log"
 	"net/http"
 	"net/url"
+	"os"
+	"time"
 
 	"github.com/google/oss-rebuild/internal/api"
 	"github.com/google/oss-rebuild/internal/api/rebuilderservice"
+	"github.com/google/oss-rebuild/internal/config"
 	"github.com/google/oss-rebuild/internal/gitx"
 	"github.com/google/oss-rebuild/internal/httpegress"
+	"github.com/google/oss-rebuild/internal/ratex"
 	"github.com/google/oss-rebuild/internal/timewarp"
+	"github.com/google/oss-rebuild/internal/tracing"
 	"github.com/pkg/errors"
 	"google.golang.org/api/idtoken"
 	gapihttp "google.golang.org/api/transport/http"
@@ -40,17 +45,25 @@ var (
 	useTimewarp         = flag.Bool("timewarp", true, "whether to use launch an instance of the timewarp server")
 	timewarpPort        = flag.Int("timewarp-port", 8081, "the port for timewarp to serve on")
 	localAssetDir       = flag.String("asset-dir", "assets", "the directory into which local assets will be stored")
+	drainTimeout        = flag.Duration("drain-timeout", 10*time.Second, "the time allowed for in-flight requests to complete on shutdown")
+	otlpEndpoint        = flag.String("otlp-endpoint", os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"), "if provided, the OTLP/HTTP endpoint to which to export trace spans")
 )
 
 var httpcfg = httpegress.Config{}
 
+var cfgLoader = config.Loader{EnvPrefix: "OSS_REBUILD_REBUILDER"}
+
+// registryLimiter is shared across requests so all outbound calls to a host observe the same limits.
+var registryLimiter = ratex.NewLimiter(0)
+
 func RebuildSmoketestInit(ctx context.Context) (*rebuilderservice.RebuildSmoketestDeps, error) {
 	var d rebuilderservice.RebuildSmoketestDeps
 	var err error
-	d.HTTPClient, err = httpegress.MakeClient(ctx, httpcfg)
+	client, err := httpegress.MakeClient(ctx, httpcfg)
 	if err != nil {
 		return nil, errors.Wrap(err, "creating http client")
 	}
+	d.HTTPClient = &ratex.Client{BasicClient: client, Limiter: registryLimiter, Retries: 2}
 	if *gitCacheURL != "" {
 		c, err := idtoken.NewClient(ctx, *gitCacheURL)
 		if err != nil {
@@ -80,7 +93,10 @@ func RebuildSmoketestInit(ctx context.Context) (*rebuilderservice.RebuildSmokete
 
 func main() {
 	httpcfg.RegisterFlags(flag.CommandLine)
+	cfgLoader.RegisterFlags(flag.CommandLine)
 	flag.Parse()
+	cfgLoader.MustLoad(flag.CommandLine)
+	go registryLimiter.LogStats(context.Background(), 5*time.Minute)
 	if *useTimewarp {
 		go func() {
 			if err := http.ListenAndServe(fmt.Sprintf(":%d", *timewarpPort), timewarp.Handler{}); err != nil {
@@ -90,7 +106,16 @@ func main() {
 	}
 	http.HandleFunc("/smoketest", api.Handler(RebuildSmoketestInit, rebuilderservice.RebuildSmoketest))
 	http.HandleFunc("/version", api.Handler(api.NoDepsInit, rebuilderservice.Version))
-	if err := http.ListenAndServe(":8080", nil); err != nil {
+	flushTraces, err := tracing.Setup(context.Background(), "rebuilder", *otlpEndpoint)
+	if err != nil {
+		log.Fatalln(errors.Wrap(err, "configuring tracing"))
+	}
+	srv := &api.Server{Addr: ":8080", Handler: tracing.Handler(http.DefaultServeMux), DrainTimeout: *drainTimeout}
+	err = srv.ListenAndServe()
+	if err := flushTraces(context.Background()); err != nil {
+		log.Println(errors.Wrap(err, "flushing traces"))
+	}
+	if err != nil {
 		log.Fatalln(err)
 	}
 }
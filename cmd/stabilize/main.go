@@ -1,13 +1,16 @@
 package main
 
 import (
+	"bytes"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
 	"slices"
 	"strings"
+	"text/tabwriter"
 
 	"github.com/google/oss-rebuild/pkg/archive"
 	"github.com/pkg/errors"
@@ -18,24 +21,9 @@ var (
 	outfile       = flag.String("outfile", "", "Output path to which the stabilized file will be written.")
 	enablePasses  = flag.String("enable-passes", "all", "Enable the comma-separated set of stabilizers or 'all'. -help for full list of options")
 	disablePasses = flag.String("disable-passes", "none", "Disable only the comma-separated set of stabilizers or 'none'. -help for full list of options")
+	report        = flag.Bool("report", false, "Report the entries each stabilizer would modify and its effect on the output size rather than writing the output.")
 )
 
-func getName(san any) string {
-	switch san.(type) {
-	case archive.TarArchiveStabilizer:
-		return san.(archive.TarArchiveStabilizer).Name
-	case archive.TarEntryStabilizer:
-		return san.(archive.TarEntryStabilizer).Name
-	case archive.ZipArchiveStabilizer:
-		return san.(archive.ZipArchiveStabilizer).Name
-	case archive.ZipEntryStabilizer:
-		return san.(archive.ZipEntryStabilizer).Name
-	default:
-		log.Fatalf("unknown stabilizer type: %T", san)
-		return "" // unreachable
-	}
-}
-
 func filetype(path string) archive.Format {
 	ext := filepath.Ext(path)
 	switch ext {
@@ -43,6 +31,10 @@ func filetype(path string) archive.Format {
 		return archive.TarFormat
 	case ".tgz", ".crate", ".gz", ".Z":
 		return archive.TarGzFormat
+	case ".xz":
+		return archive.TarXzFormat
+	case ".gem":
+		return archive.GemFormat
 	case ".zip", ".whl", ".egg", ".jar":
 		return archive.ZipFormat
 	default:
@@ -59,7 +51,7 @@ func NewStabilizerRegistry(stabs ...any) StabilizerRegistry {
 	reg := StabilizerRegistry{stabilizers: stabs}
 	reg.byName = make(map[string]any)
 	for _, san := range reg.stabilizers {
-		reg.byName[getName(san)] = san
+		reg.byName[archive.StabilizerName(san)] = san
 	}
 	return reg
 }
@@ -85,7 +77,7 @@ func determinePasses(reg StabilizerRegistry, enableSpec, disableSpec string) ([]
 	case "all":
 		for _, pass := range reg.GetAll() {
 			toRun = append(toRun, pass)
-			enabled[getName(pass)] = true
+			enabled[archive.StabilizerName(pass)] = true
 		}
 	case "", "none":
 		// No passes enabled.
@@ -118,12 +110,104 @@ func determinePasses(reg StabilizerRegistry, enableSpec, disableSpec string) ([]
 	}
 	// Apply deletions from "enabled" map.
 	toRun = slices.DeleteFunc(toRun, func(san any) bool {
-		_, ok := enabled[getName(san)]
+		_, ok := enabled[archive.StabilizerName(san)]
 		return !ok
 	})
 	return toRun, nil
 }
 
+// passReport summarizes the modifications made by a single stabilizer.
+type passReport struct {
+	Name string
+	// Entries are the archive entries the stabilizer modified.
+	Entries []string
+	// Delta is the change in output size attributable to the stabilizer.
+	Delta int64
+}
+
+type countingWriter struct{ n int64 }
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+// stabilizedSize returns the size of the output of stabilizing data with opts.
+func stabilizedSize(data []byte, f archive.Format, opts archive.StabilizeOpts) (int64, error) {
+	var w countingWriter
+	if err := archive.StabilizeWithOpts(&w, bytes.NewReader(data), f, opts); err != nil {
+		return 0, err
+	}
+	return w.n, nil
+}
+
+// reportPasses applies passes to data without retaining the output and
+// reports the effect of each, along with the size of the output when
+// rewritten with no passes.
+//
+// The size delta of each pass is measured relative to the passes preceding
+// it so the deltas reflect the order in which passes are applied.
+func reportPasses(data []byte, f archive.Format, passes []any) ([]passReport, int64, error) {
+	base, err := stabilizedSize(data, f, archive.StabilizeOpts{})
+	if err != nil {
+		return nil, 0, err
+	}
+	modified := make(archive.StabilizationLog)
+	if _, err := stabilizedSize(data, f, archive.StabilizeOpts{Stabilizers: passes, Log: modified}); err != nil {
+		return nil, 0, err
+	}
+	entries := make(map[string][]string)
+	for entry, names := range modified {
+		for _, name := range names {
+			entries[name] = append(entries[name], entry)
+		}
+	}
+	var reports []passReport
+	prev := base
+	for i, pass := range passes {
+		size, err := stabilizedSize(data, f, archive.StabilizeOpts{Stabilizers: passes[:i+1]})
+		if err != nil {
+			return nil, 0, err
+		}
+		name := archive.StabilizerName(pass)
+		slices.Sort(entries[name])
+		reports = append(reports, passReport{Name: name, Entries: entries[name], Delta: size - prev})
+		prev = size
+	}
+	return reports, base, nil
+}
+
+// writeReport writes a human-readable report of the effect of passes on data to w.
+func writeReport(w io.Writer, data []byte, f archive.Format, passes []any) error {
+	reports, base, err := reportPasses(data, f, passes)
+	if err != nil {
+		return errors.Wrap(err, "stabilizing file")
+	}
+	fmt.Fprintf(w, "Input: %d bytes\n", len(data))
+	fmt.Fprintf(w, "Rewritten without stabilizers: %d bytes\n\n", base)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "STABILIZER\tENTRIES\tBYTE DELTA")
+	size := base
+	for _, r := range reports {
+		fmt.Fprintf(tw, "%s\t%d\t%+d\n", r.Name, len(r.Entries), r.Delta)
+		size += r.Delta
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\nStabilized: %d bytes\n", size)
+	for _, r := range reports {
+		if len(r.Entries) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s:\n", r.Name)
+		for _, e := range r.Entries {
+			fmt.Fprintf(w, "  %s\n", e)
+		}
+	}
+	return nil
+}
+
 func run() error {
 	stabilizers := NewStabilizerRegistry(archive.AllStabilizers...)
 
@@ -133,13 +217,13 @@ func run() error {
 		flag.PrintDefaults()
 		fmt.Fprintf(os.Stderr, "\nAvailable stabilizers (in default order of application):\n")
 		for _, san := range archive.AllStabilizers {
-			fmt.Fprintf(os.Stderr, "  - %s\n", getName(san))
+			fmt.Fprintf(os.Stderr, "  - %s\n", archive.StabilizerName(san))
 		}
 	}
 
 	flag.Parse()
 
-	if *infile == "" || *outfile == "" {
+	if *infile == "" || (*outfile == "" && !*report) {
 		flag.Usage()
 		return errors.New("both -infile and -outfile are required")
 	}
@@ -148,6 +232,13 @@ func run() error {
 		flag.Usage()
 		return err
 	}
+	if *report {
+		data, err := os.ReadFile(*infile)
+		if err != nil {
+			return errors.Wrap(err, "reading input file")
+		}
+		return writeReport(os.Stdout, data, filetype(*infile), toRun)
+	}
 
 	in, err := os.Open(*infile)
 	if err != nil {
@@ -163,7 +254,7 @@ func run() error {
 
 	var names []string
 	for _, stab := range toRun {
-		names = append(names, getName(stab))
+		names = append(names, archive.StabilizerName(stab))
 	}
 	log.Printf("Applying stablizers: {%s}", strings.Join(names, ", "))
 	err = archive.StabilizeWithOpts(out, in, filetype(*infile), archive.StabilizeOpts{Stabilizers: toRun})
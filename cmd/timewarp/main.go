@@ -16,20 +16,62 @@
 package main
 
 import (
+	"crypto/tls"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 
 	"github.com/google/oss-rebuild/internal/timewarp"
+	"github.com/google/oss-rebuild/pkg/proxy/cert"
 )
 
 var (
-	port = flag.Int("port", 8081, "port on which to serve")
+	port          = flag.Int("port", 8081, "port on which to serve")
+	interceptPort = flag.Int("intercept-port", 0, "if provided, the port on which to serve the HTTPS-intercepting proxy")
+	caCert        = flag.String("ca-cert", "", "path to the PEM-encoded CA certificate with which to intercept HTTPS. e.g. that of the network proxy")
+	caKey         = flag.String("ca-key", "", "path to the PEM-encoded private key of --ca-cert")
+	caOut         = flag.String("ca-out", "", "if provided, the path to which to write the interception CA certificate for installation in clients")
 )
 
+func loadCA() (*tls.Certificate, error) {
+	if *caCert == "" && *caKey == "" {
+		return cert.GenerateCA(), nil
+	}
+	certPEM, err := os.ReadFile(*caCert)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := os.ReadFile(*caKey)
+	if err != nil {
+		return nil, err
+	}
+	return cert.LoadCA(certPEM, keyPEM)
+}
+
 func main() {
 	flag.Parse()
+	if *interceptPort != 0 {
+		ca, err := loadCA()
+		if err != nil {
+			log.Fatalf("Error loading CA: %v", err)
+		}
+		if *caOut != "" {
+			if err := os.WriteFile(*caOut, cert.ToPEM(ca.Leaf), 0644); err != nil {
+				log.Fatalf("Error writing CA: %v", err)
+			}
+		}
+		// NOTE: Upstream requests must not be sent through a proxy configured in the environment.
+		client := &http.Client{Transport: &http.Transport{}}
+		p := timewarp.NewInterceptor(timewarp.Handler{Client: client}, ca)
+		go func() {
+			log.Printf("Intercepting proxy listening on port %d", *interceptPort)
+			if err := http.ListenAndServe(fmt.Sprintf(":%d", *interceptPort), p); err != nil {
+				log.Fatalf("Intercepting proxy error: %v", err)
+			}
+		}()
+	}
 	log.Printf("Server listening on port %d", *port)
 	if err := http.ListenAndServe(fmt.Sprintf(":%d", *port), timewarp.Handler{Client: http.DefaultClient}); err != nil {
 		log.Fatalf("Server error: %v", err)
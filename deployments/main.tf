@@ -495,6 +495,15 @@ resource "google_cloud_run_v2_service_iam_binding" "api-and-local-build-and-infe
     "serviceAccount:${google_service_account.orchestrator.email}",
   ]
 }
+resource "google_cloud_run_v2_service_iam_binding" "api-views-api" {
+  provider = google-beta
+  location = google_cloud_run_v2_service.orchestrator.location
+  project  = google_cloud_run_v2_service.orchestrator.project
+  name     = google_cloud_run_v2_service.orchestrator.name
+  # Required to verify the instance limit when scheduler quotas are enabled. See cmd/api/main.go
+  role     = "roles/run.viewer"
+  members  = ["serviceAccount:${google_service_account.orchestrator.email}"]
+}
 resource "google_pubsub_topic_iam_binding" "can-publish-to-attestation-topic" {
   provider = google-beta
   topic    = google_pubsub_topic.attestation-topic.id
@@ -0,0 +1,171 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package analyzerservice runs analyzers against stored rebuild assets and
+// dispatches them as rebuilds complete.
+package analyzerservice
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/oss-rebuild/internal/api"
+	"github.com/google/oss-rebuild/internal/taskqueue"
+	"github.com/google/oss-rebuild/pkg/analyzer"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema/form"
+	"github.com/pkg/errors"
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+	"google.golang.org/grpc/codes"
+)
+
+// AssetStoreBuilder returns the store holding the assets of the given run.
+type AssetStoreBuilder func(ctx context.Context, runID string) (rebuild.AssetStore, error)
+
+type AnalyzeDeps struct {
+	Analyzer          analyzer.Analyzer
+	Signer            *dsse.EnvelopeSigner
+	AssetStoreBuilder AssetStoreBuilder
+}
+
+// Analyze runs the analyzer against the assets of the requested run and
+// stores its signed findings alongside them.
+func Analyze(ctx context.Context, req analyzer.Request, deps *AnalyzeDeps) (*api.NoReturn, error) {
+	store, err := deps.AssetStoreBuilder(ctx, req.RunID)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating asset store")
+	}
+	r := analyzer.Runner{Analyzer: deps.Analyzer, Signer: deps.Signer, Assets: store, Output: store}
+	if _, err := r.Run(ctx, req.Target()); errors.Is(err, rebuild.ErrAssetNotFound) {
+		return nil, api.AsStatus(codes.FailedPrecondition, err)
+	} else if err != nil {
+		return nil, err
+	}
+	return &api.NoReturn{}, nil
+}
+
+// enqueue adds a task to the queue posting the request for the target and run to u.
+func enqueue(ctx context.Context, q taskqueue.Queue, u *url.URL, t rebuild.Target, runID string) error {
+	values, err := form.Marshal(analyzer.Request{
+		Ecosystem: t.Ecosystem,
+		Package:   t.Package,
+		Version:   t.Version,
+		Artifact:  t.Artifact,
+		RunID:     runID,
+	})
+	if err != nil {
+		return errors.Wrap(err, "serializing request")
+	}
+	_, err = q.Add(ctx, u.String(), values.Encode())
+	return err
+}
+
+type DispatchDeps struct {
+	Analyzers         []analyzer.Analyzer
+	AssetStoreBuilder AssetStoreBuilder
+	Queue             taskqueue.Queue
+	// AnalyzerURL is the base URL under which each analyzer is served at /<name>.
+	AnalyzerURL *url.URL
+	// PollInterval and Timeout configure the wait for each analyzer's required assets.
+	PollInterval time.Duration
+	Timeout      time.Duration
+}
+
+// Dispatch enqueues each analyzer for the requested run once its required assets are available.
+func Dispatch(ctx context.Context, req analyzer.Request, deps *DispatchDeps) (*api.NoReturn, error) {
+	store, err := deps.AssetStoreBuilder(ctx, req.RunID)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating asset store")
+	}
+	s := analyzer.Subscriber{
+		Analyzers: deps.Analyzers,
+		Assets:    store,
+		Enqueue: func(ctx context.Context, a analyzer.Analyzer, t rebuild.Target) error {
+			return enqueue(ctx, deps.Queue, deps.AnalyzerURL.JoinPath(a.Name), t, req.RunID)
+		},
+		PollInterval: deps.PollInterval,
+		Timeout:      deps.Timeout,
+	}
+	if err := s.Dispatch(ctx, req.Target()); err != nil {
+		return nil, err
+	}
+	return &api.NoReturn{}, nil
+}
+
+type NotifyDeps struct {
+	Queue taskqueue.Queue
+	// DispatchURL is the URL at which Dispatch is served.
+	DispatchURL *url.URL
+}
+
+// pushRequest is a Pub/Sub push delivery of a GCS object notification.
+// See https://cloud.google.com/storage/docs/pubsub-notifications
+type pushRequest struct {
+	Message struct {
+		Attributes map[string]string `json:"attributes"`
+	} `json:"message"`
+}
+
+// HandleNotification returns a handler for the Pub/Sub push subscription
+// delivering metadata bucket notifications.
+//
+// Only the rebuilt artifact triggers analysis so each run is dispatched once.
+// Dispatch awaits the assets required by each analyzer so it is run from the
+// task queue, allowing the notification to be acknowledged immediately.
+//
+// NOTE: Non-2xx responses cause Pub/Sub to redeliver the notification so
+// malformed and irrelevant notifications are acknowledged.
+func HandleNotification(initDeps api.InitT[*NotifyDeps]) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		var push pushRequest
+		if err := json.NewDecoder(r.Body).Decode(&push); err != nil {
+			log.Printf("Dropping malformed notification: %v\n", err)
+			rw.WriteHeader(http.StatusNoContent)
+			return
+		}
+		attrs := push.Message.Attributes
+		if attrs["eventType"] != "OBJECT_FINALIZE" {
+			rw.WriteHeader(http.StatusNoContent)
+			return
+		}
+		object := attrs["objectId"]
+		a, runID, err := analyzer.ParseObjectName(object)
+		if err != nil {
+			log.Printf("Dropping notification for %s: %v\n", object, err)
+			rw.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if a.Type != rebuild.RebuildAsset {
+			rw.WriteHeader(http.StatusNoContent)
+			return
+		}
+		deps, err := initDeps(ctx)
+		if err != nil {
+			log.Printf("Failed to initialize dependencies: %v\n", err)
+			http.Error(rw, "Internal Error", http.StatusInternalServerError)
+			return
+		}
+		if err := enqueue(ctx, deps.Queue, deps.DispatchURL, a.Target, runID); err != nil {
+			log.Printf("Failed to enqueue dispatch for %s: %v\n", object, err)
+			http.Error(rw, "Internal Error", http.StatusInternalServerError)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,255 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analyzerservice
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	taskspb "cloud.google.com/go/cloudtasks/apiv2/cloudtaskspb"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/pkg/analyzer"
+	"github.com/google/oss-rebuild/pkg/analyzer/analyzertest"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/pkg/errors"
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var target = rebuild.Target{Ecosystem: rebuild.NPM, Package: "@scope/pkg", Version: "1.0.0", Artifact: "pkg-1.0.0.tgz"}
+
+// fakeAnalyzer requires the netlog and reports a constant finding.
+var fakeAnalyzer = analyzer.Analyzer{
+	Name:          "fake",
+	PredicateType: "https://example.com/Fake@v0.1",
+	Requires:      []rebuild.AssetType{rebuild.ProxyNetlogAsset},
+	Func: func(ctx context.Context, in analyzer.Input) (*analyzer.Finding, error) {
+		r, err := in.Assets.Reader(ctx, rebuild.ProxyNetlogAsset.For(in.Target))
+		if err != nil {
+			return nil, err
+		}
+		r.Close()
+		return &analyzer.Finding{Predicate: map[string]string{"result": "ok"}}, nil
+	},
+}
+
+func put(t *testing.T, store rebuild.AssetStore, a rebuild.Asset) {
+	t.Helper()
+	w, err := store.Writer(context.Background(), a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func storeBuilder(t *testing.T, store rebuild.AssetStore, wantRunID string) AssetStoreBuilder {
+	return func(ctx context.Context, runID string) (rebuild.AssetStore, error) {
+		if runID != wantRunID {
+			t.Errorf("AssetStoreBuilder() runID = %q, want %q", runID, wantRunID)
+		}
+		return store, nil
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	ctx := context.Background()
+	signer, err := dsse.NewEnvelopeSigner(analyzertest.FakeSigner{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := analyzer.Request{Ecosystem: target.Ecosystem, Package: target.Package, Version: target.Version, Artifact: target.Artifact, RunID: "run"}
+	t.Run("Success", func(t *testing.T) {
+		store := rebuild.NewFilesystemAssetStore(memfs.New())
+		put(t, store, rebuild.ProxyNetlogAsset.For(target))
+		deps := &AnalyzeDeps{Analyzer: fakeAnalyzer, Signer: signer, AssetStoreBuilder: storeBuilder(t, store, "run")}
+		if _, err := Analyze(ctx, req, deps); err != nil {
+			t.Fatalf("Analyze() error = %v", err)
+		}
+		r, err := store.Reader(ctx, fakeAnalyzer.Asset().For(target))
+		if err != nil {
+			t.Fatalf("reading finding: %v", err)
+		}
+		defer r.Close()
+		var env dsse.Envelope
+		if err := json.NewDecoder(r).Decode(&env); err != nil {
+			t.Fatal(err)
+		}
+		if len(env.Signatures) != 1 {
+			t.Errorf("len(Signatures) = %d, want 1", len(env.Signatures))
+		}
+	})
+	t.Run("MissingAsset", func(t *testing.T) {
+		store := rebuild.NewFilesystemAssetStore(memfs.New())
+		deps := &AnalyzeDeps{Analyzer: fakeAnalyzer, Signer: signer, AssetStoreBuilder: storeBuilder(t, store, "run")}
+		_, err := Analyze(ctx, req, deps)
+		if status.Code(err) != codes.FailedPrecondition {
+			t.Errorf("Analyze() error = %v, want FailedPrecondition", err)
+		}
+	})
+}
+
+type task struct {
+	URL  string
+	Body url.Values
+}
+
+type fakeQueue struct {
+	tasks []task
+	err   error
+}
+
+func (q *fakeQueue) Add(ctx context.Context, u, body string) (*taskspb.Task, error) {
+	if q.err != nil {
+		return nil, q.err
+	}
+	values, err := url.ParseQuery(body)
+	if err != nil {
+		return nil, err
+	}
+	q.tasks = append(q.tasks, task{URL: u, Body: values})
+	return &taskspb.Task{}, nil
+}
+
+var targetValues = url.Values{
+	"ecosystem": {"npm"},
+	"package":   {"@scope/pkg"},
+	"version":   {"1.0.0"},
+	"artifact":  {"pkg-1.0.0.tgz"},
+	"runid":     {"run"},
+}
+
+func TestDispatch(t *testing.T) {
+	ctx := context.Background()
+	req := analyzer.Request{Ecosystem: target.Ecosystem, Package: target.Package, Version: target.Version, Artifact: target.Artifact, RunID: "run"}
+	for _, tc := range []struct {
+		name    string
+		assets  []rebuild.Asset
+		want    []task
+		wantErr bool
+	}{
+		{
+			name:   "assets available",
+			assets: []rebuild.Asset{rebuild.ProxyNetlogAsset.For(target)},
+			want:   []task{{URL: "https://analyzer.example.com/analyze/fake", Body: targetValues}},
+		},
+		{
+			name:    "assets missing",
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			store := rebuild.NewFilesystemAssetStore(memfs.New())
+			for _, a := range tc.assets {
+				put(t, store, a)
+			}
+			q := &fakeQueue{}
+			deps := &DispatchDeps{
+				Analyzers:         []analyzer.Analyzer{fakeAnalyzer},
+				AssetStoreBuilder: storeBuilder(t, store, "run"),
+				Queue:             q,
+				AnalyzerURL:       &url.URL{Scheme: "https", Host: "analyzer.example.com", Path: "/analyze"},
+				PollInterval:      time.Millisecond,
+				Timeout:           10 * time.Millisecond,
+			}
+			_, err := Dispatch(ctx, req, deps)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Dispatch() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, q.tasks); diff != "" {
+				t.Errorf("Dispatch() tasks mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestHandleNotification(t *testing.T) {
+	push := func(eventType, object string) string {
+		var p pushRequest
+		p.Message.Attributes = map[string]string{"eventType": eventType, "objectId": object}
+		b, err := json.Marshal(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+	const artifact = "npm/@scope/pkg/1.0.0/pkg-1.0.0.tgz/run/pkg-1.0.0.tgz"
+	for _, tc := range []struct {
+		name     string
+		body     string
+		queueErr error
+		want     []task
+		wantCode int
+	}{
+		{
+			name:     "rebuilt artifact",
+			body:     push("OBJECT_FINALIZE", artifact),
+			want:     []task{{URL: "https://analyzer.example.com/dispatch", Body: targetValues}},
+			wantCode: http.StatusNoContent,
+		},
+		{
+			name:     "other asset",
+			body:     push("OBJECT_FINALIZE", "npm/@scope/pkg/1.0.0/pkg-1.0.0.tgz/run/netlog.json"),
+			wantCode: http.StatusNoContent,
+		},
+		{
+			name:     "delete",
+			body:     push("OBJECT_DELETE", artifact),
+			wantCode: http.StatusNoContent,
+		},
+		{
+			name:     "malformed object",
+			body:     push("OBJECT_FINALIZE", "npm/pkg/1.0.0"),
+			wantCode: http.StatusNoContent,
+		},
+		{
+			name:     "malformed body",
+			body:     "{",
+			wantCode: http.StatusNoContent,
+		},
+		{
+			name:     "enqueue failure",
+			body:     push("OBJECT_FINALIZE", artifact),
+			queueErr: errors.New("unavailable"),
+			wantCode: http.StatusInternalServerError,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			q := &fakeQueue{err: tc.queueErr}
+			deps := &NotifyDeps{Queue: q, DispatchURL: &url.URL{Scheme: "https", Host: "analyzer.example.com", Path: "/dispatch"}}
+			h := HandleNotification(func(context.Context) (*NotifyDeps, error) { return deps, nil })
+			rw := httptest.NewRecorder()
+			h(rw, httptest.NewRequest(http.MethodPost, "/notify", strings.NewReader(tc.body)))
+			if rw.Code != tc.wantCode {
+				t.Errorf("HandleNotification() status = %d, want %d", rw.Code, tc.wantCode)
+			}
+			if diff := cmp.Diff(tc.want, q.tasks); diff != "" {
+				t.Errorf("HandleNotification() tasks mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apiservice
+
+import (
+	"context"
+
+	"github.com/google/oss-rebuild/internal/api"
+	"github.com/google/oss-rebuild/pkg/rebuild/meta"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+)
+
+// Capabilities describes the rebuild support available for each ecosystem.
+func Capabilities(ctx context.Context, req schema.CapabilitiesRequest, _ *api.NoDeps) (*schema.CapabilitiesResponse, error) {
+	if req.Ecosystem == "" {
+		return &schema.CapabilitiesResponse{Ecosystems: meta.All()}, nil
+	}
+	c, ok := meta.Lookup(req.Ecosystem)
+	if !ok {
+		return nil, api.AsStatus(codes.NotFound, errors.Errorf("unknown ecosystem: %s", req.Ecosystem))
+	}
+	return &schema.CapabilitiesResponse{Ecosystems: []meta.Capabilities{c}}, nil
+}
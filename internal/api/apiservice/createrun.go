@@ -21,13 +21,37 @@ func CreateRun(ctx context.Context, req schema.CreateRunRequest, deps *CreateRun
 		BenchmarkName: req.BenchmarkName,
 		BenchmarkHash: req.BenchmarkHash,
 		Type:          req.Type,
+		ExternalID:    req.ExternalID,
+		Labels:        req.Labels,
 	}
+	runs := deps.FirestoreClient.Collection("runs")
+	var conflict error
 	err := deps.FirestoreClient.RunTransaction(ctx, func(ctx context.Context, t *firestore.Transaction) error {
+		conflict = nil
+		if req.ExternalID != "" {
+			docs, err := t.Documents(runs.Where("external_id", "==", req.ExternalID).Limit(1)).GetAll()
+			if err != nil {
+				return errors.Wrap(err, "querying external ID")
+			}
+			if len(docs) > 0 {
+				var existing schema.Run
+				if err := docs[0].DataTo(&existing); err != nil {
+					return errors.Wrap(err, "decoding existing run")
+				}
+				if existing.BenchmarkHash != req.BenchmarkHash || existing.Type != req.Type {
+					conflict = errors.Errorf("external ID %s used by run %s with different parameters", req.ExternalID, existing.ID)
+				}
+				run = existing
+				return nil
+			}
+		}
 		run.Created = time.Now().UTC().UnixMilli()
-		return t.Create(deps.FirestoreClient.Collection("runs").Doc(run.ID), run)
+		return t.Create(runs.Doc(run.ID), run)
 	})
 	if err != nil {
 		return nil, api.AsStatus(codes.Internal, errors.Wrap(err, "firestore write"))
+	} else if conflict != nil {
+		return nil, api.AsStatus(codes.AlreadyExists, conflict)
 	}
 	return &run, nil
 }
@@ -0,0 +1,131 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apiservice
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/google/oss-rebuild/internal/api"
+	"github.com/google/oss-rebuild/pkg/feed"
+	"github.com/google/oss-rebuild/pkg/rebuild/alias"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+	"github.com/pkg/errors"
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+)
+
+// AttemptSource provides the rebuild attempts created within a time range.
+type AttemptSource interface {
+	Attempts(ctx context.Context, start, end time.Time) ([]schema.RebuildAttempt, error)
+}
+
+// FirestoreAttemptSource reads attempts from the "attempts" collection group.
+type FirestoreAttemptSource struct {
+	Client *firestore.Client
+}
+
+func (s FirestoreAttemptSource) Attempts(ctx context.Context, start, end time.Time) ([]schema.RebuildAttempt, error) {
+	iter := s.Client.CollectionGroup("attempts").
+		Where("created", ">=", start.UnixMilli()).
+		Where("created", "<", end.UnixMilli()).
+		Documents(ctx)
+	return readAttempts(iter)
+}
+
+func readAttempts(iter *firestore.DocumentIterator) ([]schema.RebuildAttempt, error) {
+	defer iter.Stop()
+	var attempts []schema.RebuildAttempt
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		var a schema.RebuildAttempt
+		if err := doc.DataTo(&a); err != nil {
+			return nil, errors.Wrapf(err, "decoding attempt %s", doc.Ref.Path)
+		}
+		attempts = append(attempts, a)
+	}
+	return attempts, nil
+}
+
+var _ AttemptSource = FirestoreAttemptSource{}
+
+type PublishVerdictFeedDeps struct {
+	Attempts AttemptSource
+	Signer   *dsse.EnvelopeSigner
+	Feed     feed.Store
+	// Aliases, if provided, resolve entries for renamed packages to their canonical names.
+	Aliases *alias.Table
+}
+
+// PublishVerdictFeed publishes a signed feed partition of all verdicts, successful or not, created on the requested day.
+func PublishVerdictFeed(ctx context.Context, req schema.PublishVerdictFeedRequest, deps *PublishVerdictFeedDeps) (*schema.VerdictFeedPartition, error) {
+	start, err := time.Parse(time.DateOnly, req.Date)
+	if err != nil {
+		return nil, api.AsStatus(codes.InvalidArgument, errors.Wrap(err, "parsing date"))
+	}
+	attempts, err := deps.Attempts.Attempts(ctx, start, start.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, api.AsStatus(codes.Internal, errors.Wrap(err, "fetching attempts"))
+	}
+	entries := make([]feed.Entry, 0, len(attempts))
+	for _, a := range attempts {
+		entries = append(entries, feed.Entry{
+			Ecosystem:       a.Ecosystem,
+			Package:         a.Package,
+			Version:         a.Version,
+			Artifact:        a.Artifact,
+			Success:         a.Success,
+			Message:         a.Message,
+			ExecutorVersion: a.ExecutorVersion,
+			RunID:           a.RunID,
+			Created:         time.UnixMilli(a.Created).UTC(),
+		})
+		if canonical := deps.Aliases.Canonical(rebuild.Ecosystem(a.Ecosystem), a.Package); canonical != a.Package {
+			entries[len(entries)-1].CanonicalPackage = canonical
+		}
+	}
+	p, err := feed.NewPartition(start, entries)
+	if err != nil {
+		return nil, api.AsStatus(codes.Internal, errors.Wrap(err, "creating partition"))
+	}
+	stmt := p.Statement()
+	b, err := json.Marshal(stmt)
+	if err != nil {
+		return nil, api.AsStatus(codes.Internal, errors.Wrap(err, "marshalling statement"))
+	}
+	env, err := deps.Signer.SignPayload(ctx, stmt.Type, b)
+	if err != nil {
+		return nil, api.AsStatus(codes.Internal, errors.Wrap(err, "signing statement"))
+	}
+	envBytes, err := json.Marshal(env)
+	if err != nil {
+		return nil, api.AsStatus(codes.Internal, errors.Wrap(err, "marshalling envelope"))
+	}
+	if err := feed.Publish(ctx, deps.Feed, p, envBytes); errors.Is(err, feed.ErrPartitionExists) {
+		return nil, api.AsStatus(codes.AlreadyExists, err)
+	} else if err != nil {
+		return nil, api.AsStatus(codes.Internal, errors.Wrap(err, "publishing partition"))
+	}
+	return &schema.VerdictFeedPartition{Name: p.Name(), Count: p.Count, Successes: p.Successes}, nil
+}
@@ -0,0 +1,96 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apiservice
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/pkg/rebuild/alias"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type attemptsFunc func(ctx context.Context, start, end time.Time) ([]schema.RebuildAttempt, error)
+
+func (f attemptsFunc) Attempts(ctx context.Context, start, end time.Time) ([]schema.RebuildAttempt, error) {
+	return f(ctx, start, end)
+}
+
+type memoryFeedStore map[string][]byte
+
+func (s memoryFeedStore) Exists(ctx context.Context, name string) (bool, error) {
+	_, ok := s[name]
+	return ok, nil
+}
+
+func (s memoryFeedStore) Write(ctx context.Context, name string, data []byte) error {
+	s[name] = data
+	return nil
+}
+
+func TestPublishVerdictFeed(t *testing.T) {
+	ctx := context.Background()
+	day := must(time.Parse(time.DateOnly, "2024-01-02"))
+	var gotStart, gotEnd time.Time
+	store := memoryFeedStore{}
+	deps := &PublishVerdictFeedDeps{
+		Attempts: attemptsFunc(func(ctx context.Context, start, end time.Time) ([]schema.RebuildAttempt, error) {
+			gotStart, gotEnd = start, end
+			return []schema.RebuildAttempt{
+				{Ecosystem: "npm", Package: "left-pad", Version: "1.3.0", Artifact: "left-pad-1.3.0.tgz", Message: "content mismatch", Created: day.Add(2 * time.Hour).UnixMilli()},
+				{Ecosystem: "pypi", Package: "absl-py", Version: "2.0.0", Artifact: "absl_py-2.0.0-py3-none-any.whl", Success: true, Created: day.Add(time.Hour).UnixMilli()},
+			}, nil
+		}),
+		Signer:  must(dsse.NewEnvelopeSigner(&FakeSigner{})),
+		Feed:    store,
+		Aliases: must(alias.NewTable([]alias.Alias{{Ecosystem: rebuild.NPM, From: "left-pad", To: "@left-pad/left-pad"}})),
+	}
+	got, err := PublishVerdictFeed(ctx, schema.PublishVerdictFeedRequest{Date: "2024-01-02"}, deps)
+	if err != nil {
+		t.Fatalf("PublishVerdictFeed() error = %v", err)
+	}
+	if !gotStart.Equal(day) || !gotEnd.Equal(day.AddDate(0, 0, 1)) {
+		t.Errorf("Attempts() range = [%v, %v), want the requested day", gotStart, gotEnd)
+	}
+	want := &schema.VerdictFeedPartition{Name: "verdicts/2024-01-02.jsonl", Count: 2, Successes: 1}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("PublishVerdictFeed() mismatch (-want +got):\n%s", diff)
+	}
+	wantEntries := `{"ecosystem":"pypi","package":"absl-py","version":"2.0.0","artifact":"absl_py-2.0.0-py3-none-any.whl","success":true,"created":"2024-01-02T01:00:00Z"}
+{"ecosystem":"npm","package":"left-pad","version":"1.3.0","artifact":"left-pad-1.3.0.tgz","success":false,"message":"content mismatch","created":"2024-01-02T02:00:00Z","canonical_package":"@left-pad/left-pad"}
+`
+	if diff := cmp.Diff(wantEntries, string(store["verdicts/2024-01-02.jsonl"])); diff != "" {
+		t.Errorf("Feed entries mismatch (-want +got):\n%s", diff)
+	}
+	var env dsse.Envelope
+	if err := json.Unmarshal(store["verdicts/2024-01-02.jsonl.intoto"], &env); err != nil {
+		t.Fatalf("Decoding envelope: %v", err)
+	}
+	if len(env.Signatures) != 1 {
+		t.Errorf("Envelope signatures = %d, want 1", len(env.Signatures))
+	}
+	// Republishing the same day must not overwrite the published partition.
+	_, err = PublishVerdictFeed(ctx, schema.PublishVerdictFeedRequest{Date: "2024-01-02"}, deps)
+	if status.Code(err) != codes.AlreadyExists {
+		t.Errorf("PublishVerdictFeed() republish error = %v, want AlreadyExists", err)
+	}
+}
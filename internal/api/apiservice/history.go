@@ -0,0 +1,194 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apiservice
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/google/oss-rebuild/internal/api"
+	"github.com/google/oss-rebuild/pkg/rebuild/alias"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+)
+
+// PackageAttemptSource provides the rebuild attempts of a single package.
+type PackageAttemptSource interface {
+	// PackageAttempts returns up to limit attempts of pkg created before the
+	// provided time, newest first. A zero time or limit is unbounded.
+	PackageAttempts(ctx context.Context, ecosystem rebuild.Ecosystem, pkg string, before time.Time, limit int) ([]schema.RebuildAttempt, error)
+	// LatestAttestation returns the most recent attempt of t that published an
+	// attestation bundle or nil if there is none.
+	LatestAttestation(ctx context.Context, t rebuild.Target) (*schema.RebuildAttempt, error)
+}
+
+func (s FirestoreAttemptSource) PackageAttempts(ctx context.Context, ecosystem rebuild.Ecosystem, pkg string, before time.Time, limit int) ([]schema.RebuildAttempt, error) {
+	// NOTE: The ordering matches the (ecosystem, package, created desc) index used by rundex.
+	q := s.Client.CollectionGroup("attempts").
+		Where("ecosystem", "==", string(ecosystem)).
+		Where("package", "==", pkg).
+		OrderBy("created", firestore.Desc)
+	if !before.IsZero() {
+		q = q.Where("created", "<", before.UnixMilli())
+	}
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+	return readAttempts(q.Documents(ctx))
+}
+
+func (s FirestoreAttemptSource) LatestAttestation(ctx context.Context, t rebuild.Target) (*schema.RebuildAttempt, error) {
+	// NOTE: Successes are filtered client-side to avoid requiring another index.
+	iter := s.Client.Collection("ecosystem").Doc(string(t.Ecosystem)).Collection("packages").Doc(sanitize(t.Package)).Collection("versions").Doc(t.Version).Collection("artifacts").Doc(t.Artifact).Collection("attempts").
+		Where("success", "==", true).
+		Documents(ctx)
+	attempts, err := readAttempts(iter)
+	if err != nil {
+		return nil, err
+	}
+	var latest *schema.RebuildAttempt
+	for i, a := range attempts {
+		if attested(a) && (latest == nil || a.Created > latest.Created) {
+			latest = &attempts[i]
+		}
+	}
+	return latest, nil
+}
+
+var _ PackageAttemptSource = FirestoreAttemptSource{}
+
+// attested returns whether the attempt published an attestation bundle.
+//
+// NOTE: Smoketest attempts are not attested and do not record an ObliviousID.
+func attested(a schema.RebuildAttempt) bool {
+	return a.Success && a.ObliviousID != ""
+}
+
+const (
+	defaultHistoryPageSize = 100
+	maxHistoryPageSize     = 1000
+)
+
+type PackageHistoryDeps struct {
+	Attempts         PackageAttemptSource
+	AttestationStore rebuild.LocatableAssetStore
+	// Aliases, if provided, merge the history of a package's previous names.
+	Aliases *alias.Table
+}
+
+// PackageHistory returns a page of the chronological rebuild history of a package across all runs.
+//
+// The history is reported under the package's canonical name and includes the
+// attempts made under any of its aliases. Pages proceed from the most recent
+// attempts to the oldest.
+func PackageHistory(ctx context.Context, req schema.PackageHistoryRequest, deps *PackageHistoryDeps) (*schema.PackageHistory, error) {
+	type namedAttempt struct {
+		pkg string
+		schema.RebuildAttempt
+	}
+	limit := req.Limit
+	if limit == 0 {
+		limit = defaultHistoryPageSize
+	}
+	limit = min(limit, maxHistoryPageSize)
+	var before time.Time
+	if req.PageToken != "" {
+		ms, err := strconv.ParseInt(req.PageToken, 10, 64)
+		if err != nil {
+			return nil, api.AsStatus(codes.InvalidArgument, errors.Wrap(err, "parsing page token"))
+		}
+		before = time.UnixMilli(ms)
+	}
+	var attempts []namedAttempt
+	names := deps.Aliases.Names(req.Ecosystem, req.Package)
+	for _, name := range names {
+		// NOTE: One extra attempt is requested to detect whether more remain.
+		as, err := deps.Attempts.PackageAttempts(ctx, req.Ecosystem, name, before, limit+1)
+		if err != nil {
+			return nil, api.AsStatus(codes.Internal, errors.Wrap(err, "fetching attempts"))
+		}
+		for _, a := range as {
+			attempts = append(attempts, namedAttempt{name, a})
+		}
+	}
+	sort.SliceStable(attempts, func(i, j int) bool { return attempts[i].Created > attempts[j].Created })
+	canonical := names[0]
+	resp := schema.PackageHistory{Ecosystem: req.Ecosystem, Package: canonical}
+	if len(attempts) > limit {
+		attempts = attempts[:limit]
+		// NOTE: Attempts sharing the creation time of the last one on the page
+		// are skipped by the next page.
+		resp.NextPageToken = strconv.FormatInt(attempts[limit-1].Created, 10)
+	}
+	slices.Reverse(attempts)
+	type artifactKey struct{ pkg, version, artifact string }
+	// Only the latest attested attempt of each artifact has its bundle published.
+	published := make(map[artifactKey]string)
+	for _, a := range attempts {
+		if attested(a.RebuildAttempt) {
+			published[artifactKey{a.pkg, a.Version, a.Artifact}] = a.RunID
+		}
+	}
+	if req.PageToken != "" {
+		// Newer pages may have republished the bundle.
+		for key := range published {
+			t := rebuild.Target{Ecosystem: req.Ecosystem, Package: key.pkg, Version: key.version, Artifact: key.artifact}
+			latest, err := deps.Attempts.LatestAttestation(ctx, t)
+			if err != nil {
+				return nil, api.AsStatus(codes.Internal, errors.Wrap(err, "fetching latest attestation"))
+			}
+			if latest == nil {
+				delete(published, key)
+			} else {
+				published[key] = latest.RunID
+			}
+		}
+	}
+	prev := make(map[artifactKey]schema.HistoryEntry)
+	resp.Entries = make([]schema.HistoryEntry, 0, len(attempts))
+	for _, a := range attempts {
+		key := artifactKey{a.pkg, a.Version, a.Artifact}
+		e := schema.HistoryEntry{
+			Version:         a.Version,
+			Artifact:        a.Artifact,
+			RunID:           a.RunID,
+			ExecutorVersion: a.ExecutorVersion,
+			Created:         time.UnixMilli(a.Created).UTC(),
+			Success:         a.Success,
+			Message:         a.Message,
+			StrategyDigest:  a.Strategy.Digest(),
+		}
+		if a.pkg != canonical {
+			e.Package = a.pkg
+		}
+		if p, ok := prev[key]; ok {
+			e.StrategyChanged = p.StrategyDigest != e.StrategyDigest
+			e.VerdictChanged = p.Success != e.Success
+		}
+		if attested(a.RebuildAttempt) && published[key] == a.RunID {
+			t := rebuild.Target{Ecosystem: req.Ecosystem, Package: a.pkg, Version: a.Version, Artifact: a.Artifact}
+			e.AttestationURL = deps.AttestationStore.URL(rebuild.AttestationBundleAsset.For(t)).String()
+		}
+		resp.Entries = append(resp.Entries, e)
+		prev[key] = e
+	}
+	return &resp, nil
+}
@@ -0,0 +1,182 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apiservice
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/pkg/rebuild/alias"
+	"github.com/google/oss-rebuild/pkg/rebuild/npm"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+)
+
+// fakeAttemptSource serves the attempts of each package from memory.
+type fakeAttemptSource map[string][]schema.RebuildAttempt
+
+func (f fakeAttemptSource) PackageAttempts(ctx context.Context, ecosystem rebuild.Ecosystem, pkg string, before time.Time, limit int) ([]schema.RebuildAttempt, error) {
+	var as []schema.RebuildAttempt
+	for _, a := range f[pkg] {
+		if before.IsZero() || a.Created < before.UnixMilli() {
+			as = append(as, a)
+		}
+	}
+	sort.Slice(as, func(i, j int) bool { return as[i].Created > as[j].Created })
+	if limit > 0 && len(as) > limit {
+		as = as[:limit]
+	}
+	return as, nil
+}
+
+func (f fakeAttemptSource) LatestAttestation(ctx context.Context, t rebuild.Target) (*schema.RebuildAttempt, error) {
+	var latest *schema.RebuildAttempt
+	for i, a := range f[t.Package] {
+		if a.Version == t.Version && a.Artifact == t.Artifact && attested(a) && (latest == nil || a.Created > latest.Created) {
+			latest = &f[t.Package][i]
+		}
+	}
+	return latest, nil
+}
+
+func TestPackageHistory(t *testing.T) {
+	day := must(time.Parse(time.DateOnly, "2024-01-02"))
+	hour := func(n int) int64 { return day.Add(time.Duration(n) * time.Hour).UnixMilli() }
+	at := func(n int) time.Time { return day.Add(time.Duration(n) * time.Hour) }
+	pack := schema.NewStrategyOneOf(&npm.NPMPackBuild{Location: rebuild.Location{Repo: "https://github.com/left-pad/left-pad", Ref: "abc"}, NPMVersion: "6.0.0"})
+	packNewer := schema.NewStrategyOneOf(&npm.NPMPackBuild{Location: rebuild.Location{Repo: "https://github.com/left-pad/left-pad", Ref: "abc"}, NPMVersion: "10.0.0"})
+	var none schema.StrategyOneOf
+	const leftPadBundle = "file:///npm/left-pad/1.3.0/left-pad-1.3.0.tgz/rebuild.intoto.jsonl"
+	republished := fakeAttemptSource{"left-pad": {
+		{Version: "1.3.0", Artifact: "left-pad-1.3.0.tgz", RunID: "run-1", Success: true, ObliviousID: "id-1", Strategy: pack, Created: hour(1)},
+		{Version: "1.3.0", Artifact: "left-pad-1.3.0.tgz", RunID: "run-2", Success: true, ObliviousID: "id-2", Strategy: pack, Created: hour(2)},
+		{Version: "1.3.0", Artifact: "left-pad-1.3.0.tgz", RunID: "run-3", Success: true, Strategy: pack, Created: hour(3)},
+	}}
+	tests := []struct {
+		name     string
+		attempts fakeAttemptSource
+		aliases  []alias.Alias
+		req      schema.PackageHistoryRequest
+		want     *schema.PackageHistory
+		wantErr  bool
+	}{
+		{
+			name: "verdict and strategy changes",
+			attempts: fakeAttemptSource{"left-pad": {
+				{Version: "1.3.0", Artifact: "left-pad-1.3.0.tgz", RunID: "run-3", Message: "content mismatch", Strategy: packNewer, Created: hour(3)},
+				{Version: "1.3.0", Artifact: "left-pad-1.3.0.tgz", RunID: "run-1", Success: true, ObliviousID: "id-1", Strategy: pack, Created: hour(1)},
+				{Version: "1.2.0", Artifact: "left-pad-1.2.0.tgz", RunID: "run-2", Success: true, ObliviousID: "id-2", Strategy: pack, Created: hour(2)},
+			}},
+			req: schema.PackageHistoryRequest{Ecosystem: rebuild.NPM, Package: "left-pad"},
+			want: &schema.PackageHistory{
+				Ecosystem: rebuild.NPM,
+				Package:   "left-pad",
+				Entries: []schema.HistoryEntry{
+					{Version: "1.3.0", Artifact: "left-pad-1.3.0.tgz", RunID: "run-1", Created: at(1), Success: true, StrategyDigest: pack.Digest(), AttestationURL: leftPadBundle},
+					{Version: "1.2.0", Artifact: "left-pad-1.2.0.tgz", RunID: "run-2", Created: at(2), Success: true, StrategyDigest: pack.Digest(), AttestationURL: "file:///npm/left-pad/1.2.0/left-pad-1.2.0.tgz/rebuild.intoto.jsonl"},
+					{Version: "1.3.0", Artifact: "left-pad-1.3.0.tgz", RunID: "run-3", Created: at(3), Message: "content mismatch", StrategyDigest: packNewer.Digest(), StrategyChanged: true, VerdictChanged: true},
+				},
+			},
+		},
+		{
+			// Smoketest successes are not attested so run-2 published the current bundle.
+			name:     "republished",
+			attempts: republished,
+			req:      schema.PackageHistoryRequest{Ecosystem: rebuild.NPM, Package: "left-pad"},
+			want: &schema.PackageHistory{
+				Ecosystem: rebuild.NPM,
+				Package:   "left-pad",
+				Entries: []schema.HistoryEntry{
+					{Version: "1.3.0", Artifact: "left-pad-1.3.0.tgz", RunID: "run-1", Created: at(1), Success: true, StrategyDigest: pack.Digest()},
+					{Version: "1.3.0", Artifact: "left-pad-1.3.0.tgz", RunID: "run-2", Created: at(2), Success: true, StrategyDigest: pack.Digest(), AttestationURL: leftPadBundle},
+					{Version: "1.3.0", Artifact: "left-pad-1.3.0.tgz", RunID: "run-3", Created: at(3), Success: true, StrategyDigest: pack.Digest()},
+				},
+			},
+		},
+		{
+			name:     "first page",
+			attempts: republished,
+			req:      schema.PackageHistoryRequest{Ecosystem: rebuild.NPM, Package: "left-pad", Limit: 2},
+			want: &schema.PackageHistory{
+				Ecosystem: rebuild.NPM,
+				Package:   "left-pad",
+				Entries: []schema.HistoryEntry{
+					{Version: "1.3.0", Artifact: "left-pad-1.3.0.tgz", RunID: "run-2", Created: at(2), Success: true, StrategyDigest: pack.Digest(), AttestationURL: leftPadBundle},
+					{Version: "1.3.0", Artifact: "left-pad-1.3.0.tgz", RunID: "run-3", Created: at(3), Success: true, StrategyDigest: pack.Digest()},
+				},
+				NextPageToken: strconv.FormatInt(hour(2), 10),
+			},
+		},
+		{
+			name:     "last page",
+			attempts: republished,
+			req:      schema.PackageHistoryRequest{Ecosystem: rebuild.NPM, Package: "left-pad", Limit: 2, PageToken: strconv.FormatInt(hour(2), 10)},
+			want: &schema.PackageHistory{
+				Ecosystem: rebuild.NPM,
+				Package:   "left-pad",
+				Entries: []schema.HistoryEntry{
+					{Version: "1.3.0", Artifact: "left-pad-1.3.0.tgz", RunID: "run-1", Created: at(1), Success: true, StrategyDigest: pack.Digest()},
+				},
+			},
+		},
+		{
+			name:     "bad page token",
+			attempts: republished,
+			req:      schema.PackageHistoryRequest{Ecosystem: rebuild.NPM, Package: "left-pad", PageToken: "run-2"},
+			wantErr:  true,
+		},
+		{
+			name: "aliases",
+			attempts: fakeAttemptSource{
+				"@babel/core": {{Version: "7.0.0", Artifact: "babel-core-7.0.0.tgz", RunID: "run-2", Created: hour(2)}},
+				"babel-core":  {{Version: "6.26.3", Artifact: "babel-core-6.26.3.tgz", RunID: "run-1", Success: true, ObliviousID: "id-1", Created: hour(1)}},
+			},
+			aliases: []alias.Alias{{Ecosystem: rebuild.NPM, From: "babel-core", To: "@babel/core"}},
+			req:     schema.PackageHistoryRequest{Ecosystem: rebuild.NPM, Package: "babel-core"},
+			want: &schema.PackageHistory{
+				Ecosystem: rebuild.NPM,
+				Package:   "@babel/core",
+				Entries: []schema.HistoryEntry{
+					{Version: "6.26.3", Artifact: "babel-core-6.26.3.tgz", RunID: "run-1", Created: at(1), Success: true, StrategyDigest: none.Digest(), Package: "babel-core", AttestationURL: "file:///npm/babel-core/6.26.3/babel-core-6.26.3.tgz/rebuild.intoto.jsonl"},
+					{Version: "7.0.0", Artifact: "babel-core-7.0.0.tgz", RunID: "run-2", Created: at(2), StrategyDigest: none.Digest()},
+				},
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			deps := &PackageHistoryDeps{
+				Attempts:         tc.attempts,
+				AttestationStore: rebuild.NewFilesystemAssetStore(memfs.New()),
+				Aliases:          must(alias.NewTable(tc.aliases)),
+			}
+			got, err := PackageHistory(context.Background(), tc.req, deps)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("PackageHistory() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("PackageHistory() returned diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+	if pack.Digest() == packNewer.Digest() {
+		t.Errorf("Digest() did not distinguish strategies")
+	}
+}
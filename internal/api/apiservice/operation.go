@@ -0,0 +1,200 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apiservice
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/google/oss-rebuild/internal/api"
+	"github.com/google/oss-rebuild/internal/httpx"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrOperationNotFound indicates the requested operation does not exist.
+var ErrOperationNotFound = errors.New("operation not found")
+
+// OperationStore persists the state of long-running operations.
+type OperationStore interface {
+	Get(ctx context.Context, id string) (*schema.Operation, error)
+	Put(ctx context.Context, op *schema.Operation) error
+}
+
+// FirestoreOperationStore stores operations in the "operations" collection.
+type FirestoreOperationStore struct {
+	Client *firestore.Client
+}
+
+func (s FirestoreOperationStore) Get(ctx context.Context, id string) (*schema.Operation, error) {
+	doc, err := s.Client.Collection("operations").Doc(id).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, ErrOperationNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	var op schema.Operation
+	if err := doc.DataTo(&op); err != nil {
+		return nil, errors.Wrap(err, "decoding operation")
+	}
+	return &op, nil
+}
+
+func (s FirestoreOperationStore) Put(ctx context.Context, op *schema.Operation) error {
+	_, err := s.Client.Collection("operations").Doc(op.ID).Set(ctx, op)
+	return err
+}
+
+var _ OperationStore = FirestoreOperationStore{}
+
+// CallbackPolicy determines the URLs to which completed operations may be delivered.
+type CallbackPolicy struct {
+	// AllowedHosts lists the hosts permitted to receive callbacks.
+	// If empty, callbacks are not permitted.
+	AllowedHosts []string
+}
+
+// Permits returns whether the completed operation may be POSTed to callback.
+func (p CallbackPolicy) Permits(callback string) bool {
+	u, err := url.Parse(callback)
+	return err == nil && u.Scheme == "https" && u.User == nil && slices.Contains(p.AllowedHosts, u.Host)
+}
+
+// DefaultOperationTimeout is the default time allowed for an async rebuild to complete.
+const DefaultOperationTimeout = 2 * time.Hour
+
+// operationWriteTimeout bounds recording the outcome of an operation.
+const operationWriteTimeout = time.Minute
+
+type RebuildPackageAsyncDeps struct {
+	Operations OperationStore
+	// CallbackPolicy restricts the URLs to which completions are delivered.
+	CallbackPolicy CallbackPolicy
+	// Rebuild executes the rebuild to completion.
+	Rebuild func(context.Context, schema.RebuildPackageRequest) (*schema.Verdict, error)
+	// CallbackClient is used to deliver completion notifications.
+	CallbackClient httpx.BasicClient
+	// Background runs the provided function after the request returns.
+	// The provided context is cancelled if the function must be interrupted.
+	Background func(func(context.Context))
+	// Timeout bounds the rebuild, after which the operation is abandoned.
+	// If zero, DefaultOperationTimeout is used.
+	Timeout time.Duration
+}
+
+// RebuildPackageAsync starts a rebuild and immediately returns an Operation that can be polled for its result.
+//
+// NOTE: The serving environment must continue to allocate CPU after the
+// response is returned for the background rebuild to make progress. If it
+// does not, the operation is failed by GetOperation once its deadline passes.
+func RebuildPackageAsync(ctx context.Context, req schema.RebuildPackageRequest, deps *RebuildPackageAsyncDeps) (*schema.Operation, error) {
+	if req.Callback != "" && !deps.CallbackPolicy.Permits(req.Callback) {
+		return nil, api.AsStatus(codes.InvalidArgument, errors.Errorf("callback %q is not permitted", req.Callback))
+	}
+	timeout := deps.Timeout
+	if timeout == 0 {
+		timeout = DefaultOperationTimeout
+	}
+	now := time.Now()
+	op := &schema.Operation{ID: uuid.New().String(), Created: now.UnixMilli(), Updated: now.UnixMilli(), Deadline: now.Add(timeout).UnixMilli()}
+	if err := deps.Operations.Put(ctx, op); err != nil {
+		return nil, api.AsStatus(codes.Internal, errors.Wrap(err, "storing operation"))
+	}
+	started := *op
+	deps.Background(func(ctx context.Context) {
+		ctx, cancel := context.WithDeadline(ctx, time.UnixMilli(started.Deadline))
+		defer cancel()
+		result := started
+		v, err := deps.Rebuild(ctx, req)
+		result.Done = true
+		result.Verdict = v
+		if err != nil {
+			result.Error = err.Error()
+		} else if ctx.Err() != nil {
+			result.Error = errors.Wrap(ctx.Err(), "rebuild interrupted").Error()
+		}
+		result.Updated = time.Now().UnixMilli()
+		// NOTE: The outcome of an interrupted rebuild is still recorded.
+		ctx, cancel = context.WithTimeout(context.WithoutCancel(ctx), operationWriteTimeout)
+		defer cancel()
+		if err := deps.Operations.Put(ctx, &result); err != nil {
+			log.Println(errors.Wrapf(err, "storing operation %s", result.ID))
+		}
+		if req.Callback != "" {
+			if err := notify(ctx, deps.CallbackClient, req.Callback, &result); err != nil {
+				log.Println(errors.Wrapf(err, "notifying callback for operation %s", result.ID))
+			}
+		}
+	})
+	return op, nil
+}
+
+// notify POSTs the JSON-encoded operation to the callback URL.
+func notify(ctx context.Context, client httpx.BasicClient, callback string, op *schema.Operation) error {
+	b, err := json.Marshal(op)
+	if err != nil {
+		return errors.Wrap(err, "marshalling operation")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, callback, bytes.NewReader(b))
+	if err != nil {
+		return errors.Wrap(err, "building request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "making request")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return errors.Errorf("callback error: %s", resp.Status)
+	}
+	return nil
+}
+
+type GetOperationDeps struct {
+	Operations OperationStore
+}
+
+// GetOperation returns the current state of an Operation.
+func GetOperation(ctx context.Context, req schema.GetOperationRequest, deps *GetOperationDeps) (*schema.Operation, error) {
+	op, err := deps.Operations.Get(ctx, req.ID)
+	if errors.Is(err, ErrOperationNotFound) {
+		return nil, api.AsStatus(codes.NotFound, err)
+	} else if err != nil {
+		return nil, api.AsStatus(codes.Internal, errors.Wrap(err, "reading operation"))
+	}
+	// NOTE: An operation still incomplete after its deadline (and the time
+	// allowed to record its outcome) was lost with the instance running it.
+	now := time.Now()
+	if !op.Done && op.Deadline != 0 && now.After(time.UnixMilli(op.Deadline).Add(operationWriteTimeout)) {
+		op.Done = true
+		op.Error = "operation abandoned before completion"
+		op.Updated = now.UnixMilli()
+		if err := deps.Operations.Put(ctx, op); err != nil {
+			log.Println(errors.Wrapf(err, "storing operation %s", op.ID))
+		}
+	}
+	return op, nil
+}
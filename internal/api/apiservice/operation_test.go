@@ -0,0 +1,223 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apiservice
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// memoryOperationStore stores operations in memory.
+type memoryOperationStore struct {
+	mu  sync.Mutex
+	ops map[string]schema.Operation
+}
+
+func (s *memoryOperationStore) Get(ctx context.Context, id string) (*schema.Operation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	op, ok := s.ops[id]
+	if !ok {
+		return nil, ErrOperationNotFound
+	}
+	return &op, nil
+}
+
+func (s *memoryOperationStore) Put(ctx context.Context, op *schema.Operation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ops == nil {
+		s.ops = make(map[string]schema.Operation)
+	}
+	s.ops[op.ID] = *op
+	return nil
+}
+
+var _ OperationStore = &memoryOperationStore{}
+
+type clientFunc func(*http.Request) (*http.Response, error)
+
+func (f clientFunc) Do(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestRebuildPackageAsync(t *testing.T) {
+	ctx := context.Background()
+	target := rebuild.Target{Ecosystem: rebuild.NPM, Package: "absl", Version: "1.0.0", Artifact: "absl-1.0.0.tgz"}
+	ops := &memoryOperationStore{}
+	var pending []func(context.Context)
+	var callback []byte
+	deps := &RebuildPackageAsyncDeps{
+		Operations:     ops,
+		CallbackPolicy: CallbackPolicy{AllowedHosts: []string{"example.com"}},
+		Rebuild: func(ctx context.Context, req schema.RebuildPackageRequest) (*schema.Verdict, error) {
+			return &schema.Verdict{Target: target}, nil
+		},
+		CallbackClient: clientFunc(func(req *http.Request) (*http.Response, error) {
+			if req.URL.String() != "https://example.com/callback" {
+				t.Errorf("Unexpected callback URL: %s", req.URL)
+			}
+			callback, _ = io.ReadAll(req.Body)
+			return &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewReader(nil))}, nil
+		}),
+		Background: func(f func(context.Context)) { pending = append(pending, f) },
+	}
+	req := schema.RebuildPackageRequest{Ecosystem: target.Ecosystem, Package: target.Package, Version: target.Version, ID: "run-id", Callback: "https://example.com/callback"}
+	op, err := RebuildPackageAsync(ctx, req, deps)
+	if err != nil {
+		t.Fatalf("RebuildPackageAsync(): %v", err)
+	}
+	if op.Done {
+		t.Error("Operation completed before rebuild ran")
+	}
+	got, err := GetOperation(ctx, schema.GetOperationRequest{ID: op.ID}, &GetOperationDeps{Operations: ops})
+	if err != nil {
+		t.Fatalf("GetOperation(): %v", err)
+	}
+	if got.Done {
+		t.Error("Stored operation completed before rebuild ran")
+	}
+	for _, f := range pending {
+		f(ctx)
+	}
+	got, err = GetOperation(ctx, schema.GetOperationRequest{ID: op.ID}, &GetOperationDeps{Operations: ops})
+	if err != nil {
+		t.Fatalf("GetOperation(): %v", err)
+	}
+	want := &schema.Operation{ID: op.ID, Done: true, Verdict: &schema.Verdict{Target: target}, Created: op.Created, Updated: got.Updated, Deadline: op.Deadline}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Operation diff: %s", diff)
+	}
+	var notified schema.Operation
+	if err := json.Unmarshal(callback, &notified); err != nil {
+		t.Fatalf("Decoding callback: %v", err)
+	}
+	if diff := cmp.Diff(*want, notified); diff != "" {
+		t.Errorf("Callback diff: %s", diff)
+	}
+}
+
+func TestRebuildPackageAsyncCallbackPolicy(t *testing.T) {
+	policy := CallbackPolicy{AllowedHosts: []string{"example.com"}}
+	for _, tc := range []struct {
+		callback string
+		want     bool
+	}{
+		{callback: "https://example.com/callback", want: true},
+		{callback: "http://example.com/callback", want: false},
+		{callback: "https://example.com:8443/callback", want: false},
+		{callback: "https://user@example.com/callback", want: false},
+		{callback: "https://metadata.google.internal/computeMetadata/v1/", want: false},
+		{callback: "https://example.com.attacker.test/callback", want: false},
+	} {
+		if got := policy.Permits(tc.callback); got != tc.want {
+			t.Errorf("Permits(%q) = %v, want %v", tc.callback, got, tc.want)
+		}
+	}
+	if (CallbackPolicy{}).Permits("https://example.com/callback") {
+		t.Error("Permits() with empty allowlist = true, want false")
+	}
+	ops := &memoryOperationStore{}
+	deps := &RebuildPackageAsyncDeps{
+		Operations:     ops,
+		CallbackPolicy: policy,
+		Background:     func(func(context.Context)) { t.Error("rebuild started for rejected request") },
+	}
+	req := schema.RebuildPackageRequest{Ecosystem: rebuild.NPM, Package: "absl", Version: "1.0.0", ID: "run-id", Callback: "https://attacker.test/callback"}
+	if _, err := RebuildPackageAsync(context.Background(), req, deps); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("RebuildPackageAsync(): want InvalidArgument, got %v", err)
+	}
+	if len(ops.ops) != 0 {
+		t.Errorf("RebuildPackageAsync() stored operations for rejected request: %v", ops.ops)
+	}
+}
+
+func TestRebuildPackageAsyncInterrupted(t *testing.T) {
+	ops := &memoryOperationStore{}
+	var pending func(context.Context)
+	deps := &RebuildPackageAsyncDeps{
+		Operations: ops,
+		Rebuild: func(ctx context.Context, req schema.RebuildPackageRequest) (*schema.Verdict, error) {
+			<-ctx.Done()
+			return &schema.Verdict{Message: ctx.Err().Error()}, nil
+		},
+		Background: func(f func(context.Context)) { pending = f },
+		Timeout:    time.Hour,
+	}
+	req := schema.RebuildPackageRequest{Ecosystem: rebuild.NPM, Package: "absl", Version: "1.0.0", ID: "run-id"}
+	op, err := RebuildPackageAsync(context.Background(), req, deps)
+	if err != nil {
+		t.Fatalf("RebuildPackageAsync(): %v", err)
+	}
+	if want := op.Created + time.Hour.Milliseconds(); op.Deadline != want {
+		t.Errorf("Operation deadline = %d, want %d", op.Deadline, want)
+	}
+	// Simulate the instance shutting down during the rebuild.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	pending(ctx)
+	got := must(ops.Get(context.Background(), op.ID))
+	if !got.Done || !strings.Contains(got.Error, "interrupted") {
+		t.Errorf("Operation = %+v, want done with interruption error", got)
+	}
+}
+
+func TestGetOperationAbandoned(t *testing.T) {
+	ctx := context.Background()
+	created := time.Now().Add(-3 * time.Hour).UnixMilli()
+	ops := &memoryOperationStore{}
+	for _, op := range []schema.Operation{
+		{ID: "abandoned", Created: created, Updated: created, Deadline: created + time.Hour.Milliseconds()},
+		{ID: "running", Created: created, Updated: created, Deadline: time.Now().Add(time.Hour).UnixMilli()},
+	} {
+		must1(ops.Put(ctx, &op))
+	}
+	deps := &GetOperationDeps{Operations: ops}
+	got, err := GetOperation(ctx, schema.GetOperationRequest{ID: "abandoned"}, deps)
+	if err != nil {
+		t.Fatalf("GetOperation(): %v", err)
+	}
+	if !got.Done || got.Error == "" {
+		t.Errorf("GetOperation() = %+v, want done with error", got)
+	}
+	if stored := must(ops.Get(ctx, "abandoned")); !stored.Done {
+		t.Errorf("Stored operation = %+v, want done", stored)
+	}
+	got, err = GetOperation(ctx, schema.GetOperationRequest{ID: "running"}, deps)
+	if err != nil {
+		t.Fatalf("GetOperation(): %v", err)
+	}
+	if got.Done {
+		t.Errorf("GetOperation() = %+v, want incomplete", got)
+	}
+}
+
+func TestGetOperationNotFound(t *testing.T) {
+	_, err := GetOperation(context.Background(), schema.GetOperationRequest{ID: "missing"}, &GetOperationDeps{Operations: &memoryOperationStore{}})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("GetOperation(): want NotFound, got %v", err)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"log"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"cloud.google.com/go/firestore"
@@ -18,75 +19,189 @@ import (
 	"github.com/google/oss-rebuild/internal/api"
 	"github.com/google/oss-rebuild/internal/cache"
 	"github.com/google/oss-rebuild/internal/gcb"
+	"github.com/google/oss-rebuild/internal/hashext"
 	"github.com/google/oss-rebuild/internal/httpx"
+	"github.com/google/oss-rebuild/internal/scrub"
+	"github.com/google/oss-rebuild/internal/taskqueue"
+	"github.com/google/oss-rebuild/internal/tracing"
 	"github.com/google/oss-rebuild/internal/verifier"
 	"github.com/google/oss-rebuild/pkg/builddef"
+	"github.com/google/oss-rebuild/pkg/proxy/netlog"
 	cratesrb "github.com/google/oss-rebuild/pkg/rebuild/cratesio"
 	debianrb "github.com/google/oss-rebuild/pkg/rebuild/debian"
+	gomodrb "github.com/google/oss-rebuild/pkg/rebuild/gomod"
 	npmrb "github.com/google/oss-rebuild/pkg/rebuild/npm"
 	pypirb "github.com/google/oss-rebuild/pkg/rebuild/pypi"
 	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	rubygemsrb "github.com/google/oss-rebuild/pkg/rebuild/rubygems"
 	"github.com/google/oss-rebuild/pkg/rebuild/schema"
 	cratesreg "github.com/google/oss-rebuild/pkg/registry/cratesio"
 	debianreg "github.com/google/oss-rebuild/pkg/registry/debian"
+	gomodreg "github.com/google/oss-rebuild/pkg/registry/gomod"
 	npmreg "github.com/google/oss-rebuild/pkg/registry/npm"
 	pypireg "github.com/google/oss-rebuild/pkg/registry/pypi"
+	rubygemsreg "github.com/google/oss-rebuild/pkg/registry/rubygems"
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
 	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+	"go.opentelemetry.io/otel/attribute"
 	"google.golang.org/grpc/codes"
 )
 
 func doDebianRebuild(ctx context.Context, t rebuild.Target, id string, mux rebuild.RegistryMux, s rebuild.Strategy, opts rebuild.RemoteOptions) (upstreamURL string, err error) {
-	component, name, err := debianrb.ParseComponent(t.Package)
+	upstreamURL, err = UpstreamURL(ctx, mux, t)
 	if err != nil {
 		return "", err
 	}
 	if err := debianrb.RebuildRemote(ctx, rebuild.Input{Target: t, Strategy: s}, id, opts); err != nil {
 		return "", errors.Wrap(err, "rebuild failed")
 	}
-	return debianreg.PoolURL(component, name, t.Artifact), nil
+	return upstreamURL, nil
 }
 
 func doNPMRebuild(ctx context.Context, t rebuild.Target, id string, mux rebuild.RegistryMux, s rebuild.Strategy, opts rebuild.RemoteOptions) (upstreamURL string, err error) {
 	if err := npmrb.RebuildRemote(ctx, rebuild.Input{Target: t, Strategy: s}, id, opts); err != nil {
 		return "", errors.Wrap(err, "rebuild failed")
 	}
-	vmeta, err := mux.NPM.Version(ctx, t.Package, t.Version)
-	if err != nil {
-		return "", errors.Wrap(err, "fetching metadata failed")
-	}
-	return vmeta.Dist.URL, nil
+	return UpstreamURL(ctx, mux, t)
 }
 
 func doCratesRebuild(ctx context.Context, t rebuild.Target, id string, mux rebuild.RegistryMux, s rebuild.Strategy, opts rebuild.RemoteOptions) (upstreamURL string, err error) {
 	if err := cratesrb.RebuildRemote(ctx, rebuild.Input{Target: t, Strategy: s}, id, opts); err != nil {
 		return "", errors.Wrap(err, "rebuild failed")
 	}
-	vmeta, err := mux.CratesIO.Version(ctx, t.Package, t.Version)
-	if err != nil {
-		return "", errors.Wrap(err, "fetching metadata failed")
+	return UpstreamURL(ctx, mux, t)
+}
+
+func doGoModRebuild(ctx context.Context, t rebuild.Target, id string, mux rebuild.RegistryMux, s rebuild.Strategy, opts rebuild.RemoteOptions) (upstreamURL string, err error) {
+	if err := gomodrb.RebuildRemote(ctx, rebuild.Input{Target: t, Strategy: s}, id, opts); err != nil {
+		return "", errors.Wrap(err, "rebuild failed")
 	}
-	return vmeta.DownloadURL, nil
+	return UpstreamURL(ctx, mux, t)
+}
+
+func doRubyGemsRebuild(ctx context.Context, t rebuild.Target, id string, mux rebuild.RegistryMux, s rebuild.Strategy, opts rebuild.RemoteOptions) (upstreamURL string, err error) {
+	if err := rubygemsrb.RebuildRemote(ctx, rebuild.Input{Target: t, Strategy: s}, id, opts); err != nil {
+		return "", errors.Wrap(err, "rebuild failed")
+	}
+	return UpstreamURL(ctx, mux, t)
 }
 
 func doPyPIRebuild(ctx context.Context, t rebuild.Target, id string, mux rebuild.RegistryMux, s rebuild.Strategy, opts rebuild.RemoteOptions) (upstreamURL string, err error) {
-	release, err := mux.PyPI.Release(ctx, t.Package, t.Version)
+	upstreamURL, err = UpstreamURL(ctx, mux, t)
 	if err != nil {
-		return "", errors.Wrap(err, "fetching metadata failed")
+		return "", err
+	}
+	if err := pypirb.RebuildRemote(ctx, rebuild.Input{Target: t, Strategy: s}, id, opts); err != nil {
+		return "", errors.Wrap(err, "rebuild failed")
 	}
-	for _, r := range release.Artifacts {
-		if r.Filename == t.Artifact {
-			upstreamURL = r.URL
+	return upstreamURL, nil
+}
+
+// UpstreamURL returns the location of the upstream artifact t.
+func UpstreamURL(ctx context.Context, mux rebuild.RegistryMux, t rebuild.Target) (string, error) {
+	switch t.Ecosystem {
+	case rebuild.NPM:
+		vmeta, err := mux.NPM.Version(ctx, t.Package, t.Version)
+		if err != nil {
+			return "", errors.Wrap(err, "fetching metadata failed")
 		}
+		return vmeta.Dist.URL, nil
+	case rebuild.CratesIO:
+		if cratesreg.IsDistArtifact(t.Artifact) {
+			rel, err := mux.CratesIO.DistRelease(ctx, t.Package, t.Version)
+			if err != nil {
+				return "", errors.Wrap(err, "fetching dist release failed")
+			}
+			return cratesreg.ReleaseURL(rel.Repo, rel.Tag, t.Artifact), nil
+		}
+		vmeta, err := mux.CratesIO.Version(ctx, t.Package, t.Version)
+		if err != nil {
+			return "", errors.Wrap(err, "fetching metadata failed")
+		}
+		return vmeta.DownloadURL, nil
+	case rebuild.GoMod:
+		return gomodreg.ArtifactURL(t.Package, t.Version)
+	case rebuild.RubyGems:
+		return rubygemsreg.ArtifactURL(t.Artifact), nil
+	case rebuild.PyPI, rebuild.Debian:
+		return upstreamArtifactURL(ctx, mux, t)
+	default:
+		return "", errors.Errorf("unsupported ecosystem: %s", t.Ecosystem)
 	}
-	if upstreamURL == "" {
+}
+
+// upstreamArtifactURL returns the location of the upstream artifact t for
+// ecosystems that publish multiple artifacts per version.
+func upstreamArtifactURL(ctx context.Context, mux rebuild.RegistryMux, t rebuild.Target) (string, error) {
+	switch t.Ecosystem {
+	case rebuild.PyPI:
+		release, err := mux.PyPI.Release(ctx, t.Package, t.Version)
+		if err != nil {
+			return "", errors.Wrap(err, "fetching metadata failed")
+		}
+		for _, r := range release.Artifacts {
+			if r.Filename == t.Artifact {
+				return r.URL, nil
+			}
+		}
 		return "", errors.New("artifact not found in release")
+	case rebuild.Debian:
+		component, name, err := debianrb.ParseComponent(t.Package)
+		if err != nil {
+			return "", err
+		}
+		return debianreg.PoolURL(component, name, t.Artifact), nil
+	default:
+		return "", errors.Errorf("%s versions have a single artifact", t.Ecosystem)
 	}
-	if err := pypirb.RebuildRemote(ctx, rebuild.Input{Target: t, Strategy: s}, id, opts); err != nil {
-		return "", errors.Wrap(err, "rebuild failed")
+}
+
+// readDependencyClosure parses the dependency closure reported by the rebuild of t, if any.
+func readDependencyClosure(ctx context.Context, metadata rebuild.AssetStore, t rebuild.Target) ([]rebuild.Dependency, error) {
+	r, err := metadata.Reader(ctx, rebuild.DependencyClosureAsset.For(t))
+	if err != nil {
+		return nil, errors.Wrap(err, "reading dependency closure")
 	}
-	return upstreamURL, nil
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading dependency closure")
+	}
+	if len(bytes.TrimSpace(b)) == 0 {
+		return nil, nil
+	}
+	switch t.Ecosystem {
+	case rebuild.NPM:
+		return npmrb.ParseClosure(b)
+	case rebuild.PyPI:
+		return pypirb.ParseClosure(b)
+	case rebuild.CratesIO:
+		return cratesrb.ParseClosure(b)
+	default:
+		return nil, nil
+	}
+}
+
+// summarizeSBOM summarizes the SBOM of the rebuild container, returning nil if none was generated.
+//
+// NOTE: An empty SBOM is the placeholder uploaded when the SBOM step fails.
+func summarizeSBOM(ctx context.Context, metadata rebuild.LocatableAssetStore, t rebuild.Target) (*verifier.ArtifactSummary, error) {
+	a := rebuild.SBOMAsset.For(t)
+	r, err := metadata.Reader(ctx, a)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading SBOM")
+	}
+	defer r.Close()
+	s := verifier.ArtifactSummary{URI: metadata.URL(a).String(), Hash: hashext.NewMultiHash(crypto.SHA256)}
+	n, err := io.Copy(s.Hash, r)
+	if err != nil {
+		return nil, errors.Wrap(err, "hashing SBOM")
+	}
+	if n == 0 {
+		return nil, nil
+	}
+	return &s, nil
 }
 
 func sanitize(key string) string {
@@ -102,6 +217,10 @@ func populateArtifact(ctx context.Context, t *rebuild.Target, mux rebuild.Regist
 		t.Artifact = fmt.Sprintf("%s-%s.tgz", sanitize(t.Package), t.Version)
 	case rebuild.CratesIO:
 		t.Artifact = fmt.Sprintf("%s-%s.crate", sanitize(t.Package), t.Version)
+	case rebuild.GoMod:
+		t.Artifact = gomodrb.ArtifactName(*t)
+	case rebuild.RubyGems:
+		t.Artifact = rubygemsrb.ArtifactName(*t)
 	case rebuild.PyPI:
 		release, err := mux.PyPI.Release(ctx, t.Package, t.Version)
 		if err != nil {
@@ -120,16 +239,35 @@ func populateArtifact(ctx context.Context, t *rebuild.Target, mux rebuild.Regist
 	return nil
 }
 
+// AttemptWriter records the outcome of a rebuild attempt.
+type AttemptWriter interface {
+	WriteAttempt(ctx context.Context, id string, attempt schema.RebuildAttempt) error
+}
+
+// FirestoreAttempts records attempts in the "attempts" collection of each artifact.
+type FirestoreAttempts struct {
+	Client *firestore.Client
+}
+
+func (f FirestoreAttempts) WriteAttempt(ctx context.Context, id string, a schema.RebuildAttempt) error {
+	_, err := f.Client.Collection("ecosystem").Doc(a.Ecosystem).Collection("packages").Doc(sanitize(a.Package)).Collection("versions").Doc(a.Version).Collection("artifacts").Doc(a.Artifact).Collection("attempts").Doc(id).Set(ctx, a)
+	return err
+}
+
 // TODO: LocalMetadataStore and DebugStoreBuilder can be combined into a layered AssetStore.
 type RebuildPackageDeps struct {
-	HTTPClient                 httpx.BasicClient
-	FirestoreClient            *firestore.Client
-	Signer                     *dsse.EnvelopeSigner
-	GCBClient                  gcb.Client
-	BuildProject               string
-	BuildServiceAccount        string
-	UtilPrebuildBucket         string
-	BuildLogsBucket            string
+	HTTPClient      httpx.BasicClient
+	FirestoreClient *firestore.Client
+	// AttemptWriter, if provided, records attempts in place of FirestoreClient.
+	AttemptWriter       AttemptWriter
+	Signer              *dsse.EnvelopeSigner
+	GCBClient           gcb.Client
+	BuildProject        string
+	BuildServiceAccount string
+	UtilPrebuildBucket  string
+	BuildLogsBucket     string
+	// BuildLogReader, if provided, opens the logs of the remote build with the given ID.
+	BuildLogReader             func(ctx context.Context, buildID string) (io.ReadCloser, error)
 	BuildDefRepo               rebuild.Location
 	AttestationStore           rebuild.AssetStore
 	LocalMetadataStore         rebuild.AssetStore
@@ -137,6 +275,11 @@ type RebuildPackageDeps struct {
 	RemoteMetadataStoreBuilder func(ctx context.Context, uuid string) (rebuild.LocatableAssetStore, error)
 	OverwriteAttestations      bool
 	InferStub                  api.StubT[schema.InferenceRequest, schema.StrategyOneOf]
+	// Scheduler, if provided, limits the rebuilds admitted per ecosystem.
+	Scheduler *taskqueue.Scheduler
+	// WindowsWorkerPool and WindowsDockerHost, if provided, enable Windows builds.
+	WindowsWorkerPool string
+	WindowsDockerHost string
 }
 
 type repoEntry struct {
@@ -147,8 +290,9 @@ type repoEntry struct {
 }
 
 // getStrategy determines which strategy we should execute. If a build def repo was used, that data will be included as repoEntry.
-func getStrategy(ctx context.Context, deps *RebuildPackageDeps, t rebuild.Target, fromRepo bool) (rebuild.Strategy, *repoEntry, error) {
+func getStrategy(ctx context.Context, deps *RebuildPackageDeps, t rebuild.Target, fromRepo bool) (rebuild.Strategy, rebuild.FieldProvenance, *repoEntry, error) {
 	var strategy rebuild.Strategy
+	var provenance rebuild.FieldProvenance
 	var entry *repoEntry
 	ireq := schema.InferenceRequest{
 		Ecosystem: t.Ecosystem,
@@ -169,7 +313,7 @@ func getStrategy(ctx context.Context, deps *RebuildPackageDeps, t rebuild.Target
 			SparseCheckoutDirs: []string{deps.BuildDefRepo.Dir},
 		})
 		if err != nil {
-			return nil, nil, errors.Wrap(err, "creating build definition repo reader")
+			return nil, nil, nil, errors.Wrap(err, "creating build definition repo reader")
 		}
 		pth, _ := defs.Path(ctx, t)
 		entry = &repoEntry{
@@ -181,7 +325,7 @@ func getStrategy(ctx context.Context, deps *RebuildPackageDeps, t rebuild.Target
 		}
 		entry.Strategy, err = defs.Get(ctx, t)
 		if err != nil {
-			return nil, nil, errors.Wrap(err, "accessing build definition")
+			return nil, nil, nil, errors.Wrap(err, "accessing build definition")
 		}
 		if hint, ok := entry.Strategy.(*rebuild.LocationHint); ok && hint != nil {
 			ireq.StrategyHint = &schema.StrategyOneOf{LocationHint: hint}
@@ -193,25 +337,29 @@ func getStrategy(ctx context.Context, deps *RebuildPackageDeps, t rebuild.Target
 		s, err := deps.InferStub(ctx, ireq)
 		if err != nil {
 			// TODO: Surface better error than Internal.
-			return nil, nil, errors.Wrap(err, "fetching inference")
+			return nil, nil, nil, errors.Wrap(err, "fetching inference")
 		}
 		strategy, err = s.Strategy()
+		provenance = s.Provenance
 		if err != nil {
-			return nil, nil, errors.Wrap(err, "reading strategy")
+			return nil, nil, nil, errors.Wrap(err, "reading strategy")
 		}
 	}
-	return strategy, entry, nil
+	return strategy, provenance, entry, nil
 }
 
-func buildAndAttest(ctx context.Context, deps *RebuildPackageDeps, mux rebuild.RegistryMux, a verifier.Attestor, t rebuild.Target, strategy rebuild.Strategy, entry *repoEntry, useProxy bool, useSyscallMonitor bool) (err error) {
+// buildAndAttest rebuilds and attests t along with any additional artifacts
+// produced by the build, returning the verdicts for the latter and the number
+// of credentials found in the build's logs.
+func buildAndAttest(ctx context.Context, deps *RebuildPackageDeps, mux rebuild.RegistryMux, a verifier.Attestor, t rebuild.Target, siblings []rebuild.Target, strategy rebuild.Strategy, entry *repoEntry, useProxy bool, useSyscallMonitor bool, syscallPolicyPacks []string, hermetic bool) (additional []schema.Verdict, findings int, err error) {
 	debugStore, err := deps.DebugStoreBuilder(ctx)
 	if err != nil {
-		return errors.Wrap(err, "creating debug store")
+		return nil, 0, errors.Wrap(err, "creating debug store")
 	}
 	id := uuid.New().String()
 	remoteMetadata, err := deps.RemoteMetadataStoreBuilder(ctx, id)
 	if err != nil {
-		return errors.Wrap(err, "creating rebuild store")
+		return nil, 0, errors.Wrap(err, "creating rebuild store")
 	}
 	hashes := []crypto.Hash{crypto.SHA256}
 	opts := rebuild.RemoteOptions{
@@ -224,33 +372,148 @@ func buildAndAttest(ctx context.Context, deps *RebuildPackageDeps, mux rebuild.R
 		DebugStore:          debugStore,
 		RemoteMetadataStore: remoteMetadata,
 		UseSyscallMonitor:   useSyscallMonitor,
+		SyscallPolicyPacks:  syscallPolicyPacks,
 		UseNetworkProxy:     useProxy,
+		Hermetic:            hermetic,
+		WindowsWorkerPool:   deps.WindowsWorkerPool,
+		WindowsDockerHost:   deps.WindowsDockerHost,
 	}
 	var upstreamURI string
+	rebuildCtx, span := tracing.Start(ctx, "rebuild")
 	switch t.Ecosystem {
 	case rebuild.NPM:
 		hashes = append(hashes, crypto.SHA512)
-		upstreamURI, err = doNPMRebuild(ctx, t, id, mux, strategy, opts)
+		upstreamURI, err = doNPMRebuild(rebuildCtx, t, id, mux, strategy, opts)
 	case rebuild.CratesIO:
-		upstreamURI, err = doCratesRebuild(ctx, t, id, mux, strategy, opts)
+		upstreamURI, err = doCratesRebuild(rebuildCtx, t, id, mux, strategy, opts)
 	case rebuild.PyPI:
-		upstreamURI, err = doPyPIRebuild(ctx, t, id, mux, strategy, opts)
+		upstreamURI, err = doPyPIRebuild(rebuildCtx, t, id, mux, strategy, opts)
 	case rebuild.Debian:
-		upstreamURI, err = doDebianRebuild(ctx, t, id, mux, strategy, opts)
+		upstreamURI, err = doDebianRebuild(rebuildCtx, t, id, mux, debianrb.WithSiblings(strategy, siblings), opts)
+	case rebuild.GoMod:
+		upstreamURI, err = doGoModRebuild(rebuildCtx, t, id, mux, strategy, opts)
+	case rebuild.RubyGems:
+		upstreamURI, err = doRubyGemsRebuild(rebuildCtx, t, id, mux, strategy, opts)
 	default:
-		return api.AsStatus(codes.InvalidArgument, errors.New("unsupported ecosystem"))
+		span.End()
+		return nil, 0, api.AsStatus(codes.InvalidArgument, errors.New("unsupported ecosystem"))
+	}
+	tracing.End(span, err)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "rebuilding")
 	}
+	findings, err = countSecretFindings(ctx, deps, remoteMetadata, t, useProxy)
 	if err != nil {
-		return errors.Wrap(err, "rebuilding")
+		log.Println(errors.Wrap(err, "counting secret findings"))
+	}
+	// NOTE: Observations describe the build as a whole so are shared by all its artifacts.
+	observe := sync.OnceValues(func() (obs verifier.BuildObservations, err error) {
+		if useSyscallMonitor {
+			syscallLog, err := verifier.SummarizeAsset(ctx, remoteMetadata, rebuild.TetragonLogAsset.For(t), []crypto.Hash{crypto.SHA256})
+			if err != nil {
+				return obs, errors.Wrap(err, "summarizing syscall log")
+			}
+			obs.SyscallLog = &syscallLog
+		}
+		// NOTE: The SBOM and closure are informational so failure to collect them does not block attestation.
+		if sbom, err := summarizeSBOM(ctx, remoteMetadata, t); err != nil {
+			log.Println(errors.Wrap(err, "summarizing SBOM"))
+		} else {
+			obs.SBOM = sbom
+		}
+		if closure, err := readDependencyClosure(ctx, remoteMetadata, t); err != nil {
+			log.Println(errors.Wrap(err, "collecting dependency closure"))
+		} else {
+			obs.Dependencies = closure
+		}
+		return obs, nil
+	})
+	// NOTE: The outcome of the requested artifact is returned only once all
+	// outputs have been attested so its failure does not block the others.
+	primaryErr := attestArtifact(ctx, deps, a, t, strategy, entry, id, remoteMetadata, upstreamURI, hashes, observe)
+	extras, err := rebuild.AdditionalOutputTargets(ctx, remoteMetadata, t)
+	if err != nil {
+		// NOTE: Builds predating additional outputs do not produce the listing.
+		if !errors.Is(err, rebuild.ErrAssetNotFound) {
+			log.Println(errors.Wrap(err, "reading additional outputs"))
+		}
+		return nil, findings, primaryErr
+	}
+	// NOTE: Each additional artifact is attested independently so a mismatch
+	// of one does not prevent attestation of the others.
+	for _, et := range extras {
+		v := schema.Verdict{Target: et}
+		if err := attestAdditionalArtifact(ctx, deps, mux, a, t, et, strategy, entry, id, remoteMetadata, hashes, observe); err != nil {
+			v.Message = errors.Wrap(err, "attesting additional artifact").Error()
+		}
+		additional = append(additional, v)
+	}
+	return additional, findings, primaryErr
+}
+
+// countSecretFindings returns the number of credentials found in the build
+// and network logs of the remote build of t.
+//
+// NOTE: GCB writes build logs directly to the logs bucket so, unlike local
+// builds, they cannot be scrubbed before storage. Credentials are instead
+// counted once the build completes so they are surfaced on the attempt.
+func countSecretFindings(ctx context.Context, deps *RebuildPackageDeps, remoteMetadata rebuild.AssetStore, t rebuild.Target, useProxy bool) (int, error) {
+	var findings int
+	if deps.BuildLogReader != nil {
+		r, err := deps.LocalMetadataStore.Reader(ctx, rebuild.BuildInfoAsset.For(t))
+		if err != nil {
+			return findings, errors.Wrap(err, "opening build info")
+		}
+		defer r.Close()
+		var bi rebuild.BuildInfo
+		if err := json.NewDecoder(r).Decode(&bi); err != nil {
+			return findings, errors.Wrap(err, "parsing build info")
+		}
+		lr, err := deps.BuildLogReader(ctx, bi.BuildID)
+		if err != nil {
+			return findings, errors.Wrap(err, "opening build logs")
+		}
+		defer lr.Close()
+		logs, err := io.ReadAll(lr)
+		if err != nil {
+			return findings, errors.Wrap(err, "reading build logs")
+		}
+		_, n := scrub.Bytes(logs)
+		findings += n
 	}
-	rb, up, err := verifier.SummarizeArtifacts(ctx, remoteMetadata, t, upstreamURI, hashes)
+	if useProxy {
+		r, err := remoteMetadata.Reader(ctx, rebuild.ProxyNetlogAsset.For(t))
+		if err != nil {
+			return findings, errors.Wrap(err, "opening netlog")
+		}
+		defer r.Close()
+		var nl netlog.NetworkActivityLog
+		if err := json.NewDecoder(r).Decode(&nl); err != nil {
+			return findings, errors.Wrap(err, "parsing netlog")
+		}
+		findings += nl.Redactions
+	}
+	return findings, nil
+}
+
+// attestArtifact compares the rebuild of t to upstream and publishes the resulting attestations.
+func attestArtifact(ctx context.Context, deps *RebuildPackageDeps, a verifier.Attestor, t rebuild.Target, strategy rebuild.Strategy, entry *repoEntry, id string, remoteMetadata rebuild.LocatableAssetStore, upstreamURI string, hashes []crypto.Hash, observe func() (verifier.BuildObservations, error)) error {
+	compareCtx, span := tracing.Start(ctx, "compare")
+	rb, up, err := verifier.SummarizeArtifacts(compareCtx, remoteMetadata, t, upstreamURI, hashes)
+	tracing.End(span, err)
 	if err != nil {
 		return errors.Wrap(err, "comparing artifacts")
 	}
 	exactMatch := bytes.Equal(rb.Hash.Sum(nil), up.Hash.Sum(nil))
 	stabilizedMatch := bytes.Equal(rb.StabilizedHash.Sum(nil), up.StabilizedHash.Sum(nil))
 	if !exactMatch && !stabilizedMatch {
-		return api.AsStatus(codes.FailedPrecondition, errors.New("rebuild content mismatch"))
+		mismatch := errors.New("rebuild content mismatch")
+		publishVEX(ctx, a, t, id, up, false, mismatch.Error())
+		return api.AsStatus(codes.FailedPrecondition, mismatch)
+	}
+	obs, err := observe()
+	if err != nil {
+		return err
 	}
 	input := rebuild.Input{Target: t}
 	var loc rebuild.Location
@@ -258,34 +521,121 @@ func buildAndAttest(ctx context.Context, deps *RebuildPackageDeps, mux rebuild.R
 		input.Strategy = entry.Strategy
 		loc = entry.BuildDefLoc
 	}
-	eqStmt, buildStmt, err := verifier.CreateAttestations(ctx, input, strategy, id, rb, up, deps.LocalMetadataStore, loc)
+	eqStmt, buildStmt, err := verifier.CreateAttestations(ctx, input, strategy, id, rb, up, deps.LocalMetadataStore, loc, obs)
 	if err != nil {
 		return errors.Wrap(err, "creating attestations")
 	}
-	if err := a.PublishBundle(ctx, t, eqStmt, buildStmt); err != nil {
+	stabilizationStmt, err := verifier.CreateStabilizationAttestation(t, id, rb, up)
+	if err != nil {
+		return errors.Wrap(err, "creating stabilization attestation")
+	}
+	if err := a.PublishBundle(ctx, t, eqStmt, buildStmt, stabilizationStmt); err != nil {
 		return errors.Wrap(err, "publishing bundle")
 	}
+	// NOTE: The bundle is published so the artifact is attested regardless of the VEX statement.
+	publishVEX(ctx, a, t, id, up, true, "")
 	return nil
 }
 
+// publishVEX publishes the VEX statement describing the rebuild of t.
+//
+// NOTE: VEX statements supplement the verdict so failures are logged and
+// recorded on the trace rather than returned.
+func publishVEX(ctx context.Context, a verifier.Attestor, t rebuild.Target, id string, up verifier.ArtifactSummary, reproducible bool, notes string) {
+	ctx, span := tracing.Start(ctx, "vex")
+	err := func() error {
+		stmt, err := verifier.CreateVEXStatement(t, id, up, reproducible, notes, time.Now())
+		if err != nil {
+			return errors.Wrap(err, "creating VEX statement")
+		}
+		return errors.Wrap(a.PublishVEX(ctx, t, stmt), "publishing VEX statement")
+	}()
+	tracing.End(span, err)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// attestAdditionalArtifact attests the artifact et produced by the rebuild of t.
+func attestAdditionalArtifact(ctx context.Context, deps *RebuildPackageDeps, mux rebuild.RegistryMux, a verifier.Attestor, t, et rebuild.Target, strategy rebuild.Strategy, entry *repoEntry, id string, remoteMetadata rebuild.LocatableAssetStore, hashes []crypto.Hash, observe func() (verifier.BuildObservations, error)) error {
+	if !deps.OverwriteAttestations {
+		if exists, err := a.BundleExists(ctx, et); err != nil {
+			return errors.Wrap(err, "checking existing bundle")
+		} else if exists {
+			return api.AsStatus(codes.AlreadyExists, errors.New("conflict with existing attestation bundle"))
+		}
+	}
+	upstreamURI, err := upstreamArtifactURL(ctx, mux, et)
+	if err != nil {
+		return errors.Wrap(err, "locating upstream artifact")
+	}
+	// NOTE: The build metadata is shared with t but the build info identifies
+	// the attested artifact so it must be rewritten for et.
+	{
+		r, err := deps.LocalMetadataStore.Reader(ctx, rebuild.DockerfileAsset.For(t))
+		if err != nil {
+			return errors.Wrap(err, "opening Dockerfile")
+		}
+		defer r.Close()
+		w, err := deps.LocalMetadataStore.Writer(ctx, rebuild.DockerfileAsset.For(et))
+		if err != nil {
+			return errors.Wrap(err, "creating writer for Dockerfile")
+		}
+		defer w.Close()
+		if _, err := io.Copy(w, r); err != nil {
+			return errors.Wrap(err, "copying Dockerfile")
+		}
+	}
+	{
+		r, err := deps.LocalMetadataStore.Reader(ctx, rebuild.BuildInfoAsset.For(t))
+		if err != nil {
+			return errors.Wrap(err, "opening build info")
+		}
+		defer r.Close()
+		var bi rebuild.BuildInfo
+		if err := json.NewDecoder(r).Decode(&bi); err != nil {
+			return errors.Wrap(err, "parsing build info")
+		}
+		bi.Target = et
+		w, err := deps.LocalMetadataStore.Writer(ctx, rebuild.BuildInfoAsset.For(et))
+		if err != nil {
+			return errors.Wrap(err, "creating writer for build info")
+		}
+		defer w.Close()
+		if err := json.NewEncoder(w).Encode(bi); err != nil {
+			return errors.Wrap(err, "writing build info")
+		}
+	}
+	return attestArtifact(ctx, deps, a, et, strategy, entry, id, remoteMetadata, upstreamURI, hashes, observe)
+}
+
 func rebuildPackage(ctx context.Context, req schema.RebuildPackageRequest, deps *RebuildPackageDeps) (*schema.Verdict, error) {
 	t := rebuild.Target{Ecosystem: req.Ecosystem, Package: req.Package, Version: req.Version, Artifact: req.Artifact}
-	if req.Ecosystem == rebuild.Debian && strings.TrimSpace(req.Artifact) == "" {
-		return nil, api.AsStatus(codes.InvalidArgument, errors.New("debian requires artifact"))
-	}
 	ctx = context.WithValue(ctx, rebuild.HTTPBasicClientID, deps.HTTPClient)
 	regclient := httpx.NewCachedClient(deps.HTTPClient, &cache.CoalescingMemoryCache{})
 	mux := rebuild.RegistryMux{
 		Debian:   debianreg.HTTPRegistry{Client: regclient},
 		CratesIO: cratesreg.HTTPRegistry{Client: regclient},
+		GoMod:    gomodreg.HTTPRegistry{Client: regclient},
 		NPM:      npmreg.HTTPRegistry{Client: regclient},
 		PyPI:     pypireg.HTTPRegistry{Client: regclient},
+		RubyGems: rubygemsreg.HTTPRegistry{Client: regclient},
 	}
 	if err := populateArtifact(ctx, &t, mux); err != nil {
 		// If we fail to populate artifact, the verdict has an incomplete target, which might prevent the storage of the verdict.
 		// For this reason, we don't return a nil error and expect no verdict to be written.
 		return nil, api.AsStatus(codes.InvalidArgument, errors.Wrap(err, "selecting artifact"))
 	}
+	// NOTE: A source package target is rebuilt as its first binary package and
+	// the remaining binary packages are attested as additional outputs.
+	var siblings []rebuild.Target
+	if t.Ecosystem == rebuild.Debian && debianrb.IsSourceArtifact(t.Artifact) {
+		outputs, err := debianrb.Rebuilder{}.Outputs(ctx, t, mux)
+		if err != nil {
+			return nil, api.AsStatus(codes.InvalidArgument, errors.Wrap(err, "listing source package outputs"))
+		}
+		t, siblings = outputs[0], outputs[1:]
+	}
 	v := schema.Verdict{
 		Target: t,
 	}
@@ -300,15 +650,24 @@ func rebuildPackage(ctx context.Context, req schema.RebuildPackageRequest, deps
 			return &v, nil
 		}
 	}
-	strategy, entry, err := getStrategy(ctx, deps, t, req.StrategyFromRepo)
+	inferCtx, span := tracing.Start(ctx, "infer")
+	strategy, provenance, entry, err := getStrategy(inferCtx, deps, t, req.StrategyFromRepo)
+	tracing.End(span, err)
 	if err != nil {
 		v.Message = errors.Wrap(err, "getting strategy").Error()
 		return &v, nil
 	}
 	if strategy != nil {
 		v.StrategyOneof = schema.NewStrategyOneOf(strategy)
+		v.StrategyOneof.Provenance = provenance
 	}
-	err = buildAndAttest(ctx, deps, mux, a, t, strategy, entry, req.UseNetworkProxy, req.UseSyscallMonitor)
+	additional, findings, err := buildAndAttest(ctx, deps, mux, a, t, siblings, strategy, entry, req.UseNetworkProxy, req.UseSyscallMonitor, req.SyscallPolicyPacks, req.Hermetic)
+	v.SecretFindings = findings
+	for i := range additional {
+		additional[i].StrategyOneof = v.StrategyOneof
+		additional[i].SecretFindings = findings
+	}
+	v.Additional = additional
 	if err != nil {
 		v.Message = errors.Wrap(err, "executing rebuild").Error()
 		return &v, nil
@@ -318,10 +677,37 @@ func rebuildPackage(ctx context.Context, req schema.RebuildPackageRequest, deps
 
 func RebuildPackage(ctx context.Context, req schema.RebuildPackageRequest, deps *RebuildPackageDeps) (*schema.Verdict, error) {
 	ctx = context.WithValue(ctx, rebuild.RunID, req.ID)
+	ctx, span := tracing.Start(ctx, "RebuildPackage",
+		attribute.String("ecosystem", string(req.Ecosystem)),
+		attribute.String("package", req.Package),
+		attribute.String("version", req.Version),
+		attribute.String("run_id", req.ID))
+	defer span.End()
+	if deps.Scheduler != nil {
+		release, err := deps.Scheduler.Admit(req.Ecosystem)
+		if err != nil {
+			return nil, api.AsStatus(codes.ResourceExhausted, err)
+		}
+		defer release()
+	}
 	v, err := rebuildPackage(ctx, req, deps)
 	if err != nil {
 		return nil, err
 	}
+	span.SetAttributes(attribute.String("verdict", v.Message))
+	cancelled := ctx.Err() != nil
+	if cancelled {
+		// NOTE: The outcome of an abandoned request is still recorded.
+		ctx = context.WithoutCancel(ctx)
+	}
+	attempts := deps.AttemptWriter
+	if attempts == nil {
+		if deps.FirestoreClient == nil {
+			// NOTE: Attempts are only recorded when a Firestore client is provided.
+			return v, nil
+		}
+		attempts = FirestoreAttempts{Client: deps.FirestoreClient}
+	}
 	var dockerfile string
 	r, err := deps.LocalMetadataStore.Reader(ctx, rebuild.DockerfileAsset.For(v.Target))
 	if err == nil {
@@ -338,7 +724,7 @@ func RebuildPackage(ctx context.Context, req schema.RebuildPackageRequest, deps
 			log.Println("Failed to load build info:", err)
 		}
 	}
-	_, err = deps.FirestoreClient.Collection("ecosystem").Doc(string(v.Target.Ecosystem)).Collection("packages").Doc(sanitize(v.Target.Package)).Collection("versions").Doc(v.Target.Version).Collection("artifacts").Doc(v.Target.Artifact).Collection("attempts").Doc(req.ID).Set(ctx, schema.RebuildAttempt{
+	err = attempts.WriteAttempt(ctx, req.ID, schema.RebuildAttempt{
 		Ecosystem:       string(v.Target.Ecosystem),
 		Package:         v.Target.Package,
 		Version:         v.Target.Version,
@@ -352,6 +738,8 @@ func RebuildPackage(ctx context.Context, req schema.RebuildPackageRequest, deps
 		BuildID:         bi.BuildID,
 		ObliviousID:     bi.ID,
 		Created:         time.Now().UnixMilli(),
+		SecretFindings:  v.SecretFindings,
+		Cancelled:       cancelled,
 	})
 	if err != nil {
 		log.Print(errors.Wrap(err, "storing results in firestore"))
@@ -4,12 +4,15 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"slices"
 	"time"
 
 	"cloud.google.com/go/firestore"
 	"github.com/google/oss-rebuild/internal/api"
+	"github.com/google/oss-rebuild/internal/taskqueue"
 	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
 	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+	"github.com/google/oss-rebuild/tools/ctl/rundex"
 	"github.com/pkg/errors"
 	"google.golang.org/grpc/codes"
 )
@@ -18,6 +21,45 @@ type RebuildSmoketestDeps struct {
 	FirestoreClient *firestore.Client
 	SmoketestStub   api.StubT[schema.SmoketestRequest, schema.SmoketestResponse]
 	VersionStub     api.StubT[schema.VersionRequest, schema.VersionResponse]
+	// Scheduler, if provided, limits the smoketests admitted per ecosystem.
+	Scheduler *taskqueue.Scheduler
+	// OverridePolicy restricts which callers may supply a strategy in place of inference.
+	OverridePolicy OverridePolicy
+	// AttemptWriter, if provided, batches attempt writes with those of concurrent requests.
+	AttemptWriter *rundex.BatchWriter
+}
+
+// OverridePolicy determines which callers may supply a strategy in place of inference.
+type OverridePolicy struct {
+	// Allowed lists the identities permitted to supply a strategy.
+	// If empty, all callers are permitted.
+	Allowed []string
+}
+
+// Permits returns whether the caller may supply a strategy.
+func (p OverridePolicy) Permits(caller string) bool {
+	return len(p.Allowed) == 0 || (caller != "" && slices.Contains(p.Allowed, caller))
+}
+
+// strategyOverride describes a caller-supplied strategy.
+type strategyOverride struct {
+	Caller string
+	Digest string
+}
+
+// authorizeOverride checks that the caller may supply the request's strategy, if any.
+func authorizeOverride(ctx context.Context, sreq schema.SmoketestRequest, policy OverridePolicy) (*strategyOverride, error) {
+	if sreq.Strategy == nil {
+		return nil, nil
+	}
+	caller := api.CallerFromContext(ctx)
+	if !policy.Permits(caller) {
+		return nil, api.AsStatus(codes.PermissionDenied, errors.Errorf("caller %q may not override strategy inference", caller))
+	}
+	if caller == "" {
+		caller = "unknown"
+	}
+	return &strategyOverride{Caller: caller, Digest: sreq.Strategy.Digest()}, nil
 }
 
 func rebuildSmoketest(ctx context.Context, sreq schema.SmoketestRequest, deps *RebuildSmoketestDeps) (*schema.SmoketestResponse, error) {
@@ -37,7 +79,7 @@ func rebuildSmoketest(ctx context.Context, sreq schema.SmoketestRequest, deps *R
 	switch {
 	case stuberr == nil:
 		return stubresp, nil
-	case !errors.Is(stuberr, api.ErrNotOK):
+	case !errors.Is(stuberr, api.ErrNotOK) && ctx.Err() == nil:
 		return nil, api.AsStatus(codes.Internal, errors.Wrap(stuberr, "making smoketest request"))
 	default:
 		var resp schema.SmoketestResponse
@@ -61,12 +103,40 @@ func rebuildSmoketest(ctx context.Context, sreq schema.SmoketestRequest, deps *R
 	}
 }
 func RebuildSmoketest(ctx context.Context, sreq schema.SmoketestRequest, deps *RebuildSmoketestDeps) (*schema.SmoketestResponse, error) {
+	override, err := authorizeOverride(ctx, sreq, deps.OverridePolicy)
+	if err != nil {
+		return nil, err
+	}
+	if override != nil {
+		log.Printf("Strategy override %s supplied by %s", override.Digest, override.Caller)
+	}
+	if deps.Scheduler != nil {
+		release, err := deps.Scheduler.Admit(sreq.Ecosystem)
+		if err != nil {
+			return nil, api.AsStatus(codes.ResourceExhausted, err)
+		}
+		defer release()
+	}
 	if sreq.ID == "" {
 		sreq.ID = time.Now().UTC().Format(time.RFC3339)
 	}
 	resp, err := rebuildSmoketest(ctx, sreq, deps)
+	if err != nil {
+		return nil, err
+	}
+	cancelled := ctx.Err() != nil
+	if cancelled {
+		// NOTE: The outcome of an abandoned request is still recorded.
+		ctx = context.WithoutCancel(ctx)
+	}
+	var pending []*rundex.PendingWrite
 	for _, v := range resp.Verdicts {
-		_, err := deps.FirestoreClient.Collection("ecosystem").Doc(string(v.Target.Ecosystem)).Collection("packages").Doc(sanitize(sreq.Package)).Collection("versions").Doc(v.Target.Version).Collection("artifacts").Doc(v.Target.Artifact).Collection("attempts").Doc(sreq.ID).Set(ctx, schema.RebuildAttempt{
+		var overrideCaller, overrideDigest string
+		if override != nil {
+			overrideCaller, overrideDigest = override.Caller, override.Digest
+		}
+		doc := deps.FirestoreClient.Collection("ecosystem").Doc(string(v.Target.Ecosystem)).Collection("packages").Doc(sanitize(sreq.Package)).Collection("versions").Doc(v.Target.Version).Collection("artifacts").Doc(v.Target.Artifact).Collection("attempts").Doc(sreq.ID)
+		attempt := schema.RebuildAttempt{
 			Ecosystem:       string(v.Target.Ecosystem),
 			Package:         v.Target.Package,
 			Version:         v.Target.Version,
@@ -78,13 +148,24 @@ func RebuildSmoketest(ctx context.Context, sreq schema.SmoketestRequest, deps *R
 			ExecutorVersion: resp.Executor,
 			RunID:           sreq.ID,
 			Created:         time.Now().UnixMilli(),
-		})
-		if err != nil {
+			SecretFindings:  v.SecretFindings,
+			LogSummary:      v.LogSummary,
+			OverrideCaller:  overrideCaller,
+			OverrideDigest:  overrideDigest,
+			Cancelled:       cancelled,
+		}
+		if deps.AttemptWriter != nil {
+			pending = append(pending, deps.AttemptWriter.Set(doc, attempt))
+			continue
+		}
+		if _, err := doc.Set(ctx, attempt); err != nil {
 			return nil, api.AsStatus(codes.Internal, errors.Wrapf(err, "writing record for %s@%s", sreq.Package, v.Target.Version))
 		}
 	}
-	if err != nil {
-		return nil, api.AsStatus(codes.Internal, errors.Wrap(err, "executing smoketest"))
+	for i, p := range pending {
+		if err := p.Wait(ctx); err != nil {
+			return nil, api.AsStatus(codes.Internal, errors.Wrapf(err, "writing record for %s@%s", sreq.Package, resp.Verdicts[i].Target.Version))
+		}
 	}
 	return resp, nil
 }
@@ -10,6 +10,7 @@ import (
 	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
 	"github.com/google/oss-rebuild/pkg/rebuild/schema"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestRebuildSmoketest(t *testing.T) {
@@ -21,6 +22,7 @@ func TestRebuildSmoketest(t *testing.T) {
 		expectedResp   *schema.SmoketestResponse
 		expectedErr    error
 		expectedErrMsg string
+		cancelled      bool
 	}{
 		{
 			name: "Successful smoketest",
@@ -115,6 +117,34 @@ func TestRebuildSmoketest(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "Cancelled smoketest",
+			request: schema.SmoketestRequest{
+				Ecosystem: "npm",
+				Package:   "test-package",
+				Versions:  []string{"1.0.0"},
+			},
+			smoketestStub: func(ctx context.Context, req schema.SmoketestRequest) (*schema.SmoketestResponse, error) {
+				return nil, ctx.Err()
+			},
+			versionStub: func(ctx context.Context, req schema.VersionRequest) (*schema.VersionResponse, error) {
+				return nil, ctx.Err()
+			},
+			cancelled: true,
+			expectedResp: &schema.SmoketestResponse{
+				Executor: "unknown",
+				Verdicts: []schema.Verdict{
+					{
+						Target: rebuild.Target{
+							Ecosystem: rebuild.NPM,
+							Package:   "test-package",
+							Version:   "1.0.0",
+						},
+						Message: "build-local failed: context canceled",
+					},
+				},
+			},
+		},
 		{
 			name: "Internal error",
 			request: schema.SmoketestRequest{
@@ -141,7 +171,11 @@ func TestRebuildSmoketest(t *testing.T) {
 				VersionStub:   tt.versionStub,
 			}
 
-			ctx := context.Background()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancelled {
+				cancel()
+			}
 			resp, err := rebuildSmoketest(ctx, tt.request, deps)
 
 			if tt.expectedErr != nil {
@@ -161,3 +195,38 @@ func TestRebuildSmoketest(t *testing.T) {
 		})
 	}
 }
+
+func TestAuthorizeOverride(t *testing.T) {
+	strategy := &schema.StrategyOneOf{LocationHint: &rebuild.LocationHint{Location: rebuild.Location{Repo: "https://github.com/org/repo", Ref: "main"}}}
+	policy := OverridePolicy{Allowed: []string{"ci@example.iam.gserviceaccount.com"}}
+	tests := []struct {
+		name     string
+		caller   string
+		strategy *schema.StrategyOneOf
+		policy   OverridePolicy
+		want     *strategyOverride
+		wantCode codes.Code
+	}{
+		{name: "no strategy", caller: "other@example.com", policy: policy},
+		{name: "allowed caller", caller: "ci@example.iam.gserviceaccount.com", strategy: strategy, policy: policy, want: &strategyOverride{Caller: "ci@example.iam.gserviceaccount.com", Digest: strategy.Digest()}},
+		{name: "disallowed caller", caller: "other@example.com", strategy: strategy, policy: policy, wantCode: codes.PermissionDenied},
+		{name: "unknown caller", strategy: strategy, policy: policy, wantCode: codes.PermissionDenied},
+		{name: "unrestricted", strategy: strategy, want: &strategyOverride{Caller: "unknown", Digest: strategy.Digest()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.caller != "" {
+				ctx = api.WithCaller(ctx, tt.caller)
+			}
+			req := schema.SmoketestRequest{Ecosystem: rebuild.NPM, Package: "pkg", Versions: []string{"1.0.0"}, Strategy: tt.strategy}
+			got, err := authorizeOverride(ctx, req, tt.policy)
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("authorizeOverride() code = %v, want %v (err=%v)", code, tt.wantCode, err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("authorizeOverride() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"google.golang.org/api/idtoken"
+)
+
+type callerKey struct{}
+
+// WithCaller returns a context identifying the caller of the request being served.
+func WithCaller(ctx context.Context, caller string) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// CallerFromContext returns the identity of the caller of the request being served or the empty string if unknown.
+func CallerFromContext(ctx context.Context) string {
+	caller, _ := ctx.Value(callerKey{}).(string)
+	return caller
+}
+
+// validateIDToken validates an ID token, returning its claims. It is replaced in tests.
+var validateIDToken = idtoken.Validate
+
+// callerFromRequest returns the identity asserted by the request's bearer ID token.
+//
+// The token must be a valid Google-signed ID token issued for audience. The
+// token's email claim is preferred, falling back to its subject. If the token
+// is absent or invalid, the caller is unknown.
+func callerFromRequest(r *http.Request, audience string) string {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return ""
+	}
+	payload, err := validateIDToken(r.Context(), token, audience)
+	if err != nil {
+		log.Println(errors.Wrap(err, "validating caller ID token"))
+		return ""
+	}
+	if email, _ := payload.Claims["email"].(string); email != "" {
+		return email
+	}
+	return payload.Subject
+}
@@ -0,0 +1,106 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+	"google.golang.org/api/idtoken"
+)
+
+const testAudience = "https://api.example.com"
+
+// fakeValidator accepts the tokens in payloads when presented for testAudience.
+func fakeValidator(payloads map[string]*idtoken.Payload) func(context.Context, string, string) (*idtoken.Payload, error) {
+	return func(_ context.Context, token, audience string) (*idtoken.Payload, error) {
+		p, ok := payloads[token]
+		if !ok {
+			return nil, errors.New("invalid token")
+		}
+		if audience != testAudience {
+			return nil, errors.Errorf("audience provided does not match aud claim in the JWT: %s", audience)
+		}
+		return p, nil
+	}
+}
+
+func withValidator(t *testing.T, payloads map[string]*idtoken.Payload) {
+	t.Helper()
+	orig := validateIDToken
+	validateIDToken = fakeValidator(payloads)
+	t.Cleanup(func() { validateIDToken = orig })
+}
+
+func TestCallerFromRequest(t *testing.T) {
+	withValidator(t, map[string]*idtoken.Payload{
+		"email-token":   {Subject: "123", Claims: map[string]any{"email": "ci@example.iam.gserviceaccount.com"}},
+		"subject-token": {Subject: "123"},
+	})
+	for _, tc := range []struct {
+		name     string
+		header   string
+		audience string
+		want     string
+	}{
+		{name: "email", header: "Bearer email-token", audience: testAudience, want: "ci@example.iam.gserviceaccount.com"},
+		{name: "subject", header: "Bearer subject-token", audience: testAudience, want: "123"},
+		{name: "wrong audience", header: "Bearer email-token", audience: "https://other.example.com", want: ""},
+		{name: "invalid token", header: "Bearer forged-token", audience: testAudience, want: ""},
+		{name: "no header", header: "", audience: testAudience, want: ""},
+		{name: "not bearer", header: "Basic Zm9vOmJhcg==", audience: testAudience, want: ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", nil)
+			if tc.header != "" {
+				r.Header.Set("Authorization", tc.header)
+			}
+			if got := callerFromRequest(r, tc.audience); got != tc.want {
+				t.Errorf("callerFromRequest() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestHandlerCaller(t *testing.T) {
+	withValidator(t, map[string]*idtoken.Payload{
+		"user-token": {Subject: "456", Claims: map[string]any{"email": "user@example.com"}},
+	})
+	for _, tc := range []struct {
+		name string
+		opts []HandlerOption
+		want string
+	}{
+		{name: "audience configured", opts: []HandlerOption{WithCallerAudience(testAudience)}, want: "user@example.com"},
+		{name: "no audience", want: ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := "unset"
+			handler := func(ctx context.Context, req FooRequest, _ *NoDeps) (*FooResponse, error) {
+				got = CallerFromContext(ctx)
+				return &FooResponse{}, nil
+			}
+			r := httptest.NewRequest(http.MethodPost, "/?foo=foo", nil)
+			r.Header.Set("Authorization", "Bearer user-token")
+			Handler(NoDepsInit, handler, tc.opts...)(httptest.NewRecorder(), r)
+			if got != tc.want {
+				t.Errorf("CallerFromContext() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
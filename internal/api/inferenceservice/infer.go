@@ -11,14 +11,18 @@ import (
 	"github.com/google/oss-rebuild/internal/httpx"
 	"github.com/google/oss-rebuild/pkg/rebuild/cratesio"
 	"github.com/google/oss-rebuild/pkg/rebuild/debian"
+	"github.com/google/oss-rebuild/pkg/rebuild/gomod"
 	"github.com/google/oss-rebuild/pkg/rebuild/npm"
 	"github.com/google/oss-rebuild/pkg/rebuild/pypi"
 	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/pkg/rebuild/rubygems"
 	"github.com/google/oss-rebuild/pkg/rebuild/schema"
 	cratesreg "github.com/google/oss-rebuild/pkg/registry/cratesio"
 	debianreg "github.com/google/oss-rebuild/pkg/registry/debian"
+	gomodreg "github.com/google/oss-rebuild/pkg/registry/gomod"
 	npmreg "github.com/google/oss-rebuild/pkg/registry/npm"
 	pypireg "github.com/google/oss-rebuild/pkg/registry/pypi"
+	rubygemsreg "github.com/google/oss-rebuild/pkg/registry/rubygems"
 	"github.com/pkg/errors"
 	"google.golang.org/grpc/codes"
 )
@@ -29,6 +33,7 @@ func doInfer(ctx context.Context, rebuilder rebuild.Rebuilder, t rebuild.Target,
 	var repo string
 	if lh, ok := hint.(*rebuild.LocationHint); ok && lh != nil {
 		repo = lh.Location.Repo
+		rebuild.RecordProvenance(ctx, "repo", rebuild.HeuristicHint)
 	} else {
 		var err error
 		repo, err = rebuilder.InferRepo(ctx, t, mux)
@@ -40,11 +45,7 @@ func doInfer(ctx context.Context, rebuilder rebuild.Rebuilder, t rebuild.Target,
 	if err != nil {
 		return nil, err
 	}
-	strategy, err := rebuilder.InferStrategy(ctx, t, mux, &rcfg, hint)
-	if err != nil {
-		return nil, err
-	}
-	return strategy, nil
+	return rebuild.InferStrategy(ctx, rebuilder, t, mux, &rcfg, hint)
 }
 
 type InferDeps struct {
@@ -60,11 +61,14 @@ func Infer(ctx context.Context, req schema.InferenceRequest, deps *InferDeps) (*
 		ctx = context.WithValue(ctx, rebuild.RepoCacheClientID, *deps.GitCache)
 	}
 	ctx = context.WithValue(ctx, rebuild.HTTPBasicClientID, deps.HTTPClient)
+	ctx, provenance := rebuild.WithFieldProvenance(ctx)
 	mux := rebuild.RegistryMux{
 		CratesIO: cratesreg.HTTPRegistry{Client: deps.HTTPClient},
+		GoMod:    gomodreg.HTTPRegistry{Client: deps.HTTPClient},
 		NPM:      npmreg.HTTPRegistry{Client: deps.HTTPClient},
 		PyPI:     pypireg.HTTPRegistry{Client: deps.HTTPClient},
 		Debian:   debianreg.HTTPRegistry{Client: deps.HTTPClient},
+		RubyGems: rubygemsreg.HTTPRegistry{Client: deps.HTTPClient},
 	}
 	var s rebuild.Strategy
 	t := rebuild.Target{
@@ -84,6 +88,10 @@ func Infer(ctx context.Context, req schema.InferenceRequest, deps *InferDeps) (*
 		s, err = doInfer(ctx, cratesio.Rebuilder{}, t, mux, req.LocationHint())
 	case rebuild.Debian:
 		s, err = doInfer(ctx, debian.Rebuilder{}, t, mux, req.LocationHint())
+	case rebuild.GoMod:
+		s, err = doInfer(ctx, gomod.Rebuilder{}, t, mux, req.LocationHint())
+	case rebuild.RubyGems:
+		s, err = doInfer(ctx, rubygems.Rebuilder{}, t, mux, req.LocationHint())
 	default:
 		return nil, api.AsStatus(codes.InvalidArgument, errors.New("unsupported ecosystem"))
 	}
@@ -92,5 +100,6 @@ func Infer(ctx context.Context, req schema.InferenceRequest, deps *InferDeps) (*
 		return nil, api.AsStatus(codes.Internal, errors.Wrap(err, "failed to infer strategy"))
 	}
 	oneof := schema.NewStrategyOneOf(s)
+	oneof.Provenance = provenance
 	return &oneof, nil
 }
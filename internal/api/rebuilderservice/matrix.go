@@ -0,0 +1,76 @@
+package rebuilderservice
+
+import (
+	"context"
+	"log"
+
+	cratesrb "github.com/google/oss-rebuild/pkg/rebuild/cratesio"
+	debianrb "github.com/google/oss-rebuild/pkg/rebuild/debian"
+	gomodrb "github.com/google/oss-rebuild/pkg/rebuild/gomod"
+	npmrb "github.com/google/oss-rebuild/pkg/rebuild/npm"
+	pypirb "github.com/google/oss-rebuild/pkg/rebuild/pypi"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	rubygemsrb "github.com/google/oss-rebuild/pkg/rebuild/rubygems"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+	"github.com/pkg/errors"
+)
+
+type rebuildManyFunc func(context.Context, []rebuild.Input, rebuild.RegistryMux) ([]rebuild.Verdict, error)
+
+func matrixRebuilder(e rebuild.Ecosystem) (rebuildManyFunc, error) {
+	switch e {
+	case rebuild.NPM:
+		return npmrb.RebuildMany, nil
+	case rebuild.PyPI:
+		return pypirb.RebuildMany, nil
+	case rebuild.CratesIO:
+		return cratesrb.RebuildMany, nil
+	case rebuild.Debian:
+		return debianrb.RebuildMany, nil
+	case rebuild.GoMod:
+		return gomodrb.RebuildMany, nil
+	case rebuild.RubyGems:
+		return rubygemsrb.RebuildMany, nil
+	default:
+		return nil, errors.Errorf("toolchain matrix unsupported for %s", e)
+	}
+}
+
+// rebuildMatrix rebuilds each verdict's strategy with each of the provided toolchain versions.
+//
+// The returned verdicts replace those provided: a verdict that did not achieve
+// equivalence is replaced by that of the first toolchain version that did,
+// so the successful configuration is recorded alongside the attempt.
+//
+// NOTE: Rebuilds of a package share a single workdir so variants are executed
+// sequentially.
+func rebuildMatrix(ctx context.Context, rebuildMany rebuildManyFunc, verdicts []rebuild.Verdict, toolchains []string, mux rebuild.RegistryMux) ([]rebuild.Verdict, [][]schema.MatrixVerdict, error) {
+	// NOTE: Without a precise toolchain, some strategies install the latest
+	// toolchain regardless of the requested version.
+	ctx = context.WithValue(ctx, rebuild.PreferPreciseToolchainID, true)
+	results := make([][]schema.MatrixVerdict, len(verdicts))
+	for i, v := range verdicts {
+		tv, ok := v.Strategy.(rebuild.ToolchainVariant)
+		if !ok {
+			log.Printf("Skipping toolchain matrix for %s: unsupported strategy %T", v.Target.Version, v.Strategy)
+			continue
+		}
+		best := v
+		for _, tc := range toolchains {
+			input := rebuild.Input{Target: v.Target, Strategy: tv.WithToolchain(tc)}
+			mvs, err := rebuildMany(ctx, []rebuild.Input{input}, mux)
+			if err != nil {
+				return nil, nil, errors.Wrapf(err, "rebuilding %s with toolchain %s", v.Target.Version, tc)
+			}
+			if len(mvs) != 1 {
+				return nil, nil, errors.Errorf("unexpected number of results [want=1,got=%d]", len(mvs))
+			}
+			results[i] = append(results[i], schema.MatrixVerdict{Toolchain: tc, Message: mvs[0].Message})
+			if best.Message != "" && mvs[0].Message == "" {
+				best = mvs[0]
+			}
+		}
+		verdicts[i] = best
+	}
+	return verdicts, results, nil
+}
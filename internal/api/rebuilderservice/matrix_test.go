@@ -0,0 +1,90 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rebuilderservice
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/pkg/rebuild/cratesio"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+)
+
+func TestRebuildMatrix(t *testing.T) {
+	loc := rebuild.Location{Repo: "https://github.com/foo/bar", Ref: "abcdef", Dir: "."}
+	target := func(version string) rebuild.Target {
+		return rebuild.Target{Ecosystem: rebuild.CratesIO, Package: "bar", Version: version, Artifact: "bar-" + version + ".crate"}
+	}
+	verdicts := []rebuild.Verdict{
+		{Target: target("1.0.0"), Message: "content differences found", Strategy: &cratesio.CratesIOCargoPackage{Location: loc, RustVersion: "1.60.0"}},
+		{Target: target("1.1.0"), Strategy: &cratesio.CratesIOCargoPackage{Location: loc, RustVersion: "1.60.0"}},
+		// LocationHint cannot be rebuilt with another toolchain so is skipped.
+		{Target: target("1.2.0"), Message: "no strategy", Strategy: &rebuild.LocationHint{Location: loc}},
+	}
+	// outcomes maps a version and toolchain to the message of its rebuild.
+	outcomes := map[string]string{
+		"1.0.0@1.65.0": "content differences found",
+		"1.0.0@1.70.0": "",
+		"1.1.0@1.65.0": "content differences found",
+		"1.1.0@1.70.0": "",
+	}
+	var rebuilt []string
+	rebuildMany := func(ctx context.Context, inputs []rebuild.Input, _ rebuild.RegistryMux) ([]rebuild.Verdict, error) {
+		var out []rebuild.Verdict
+		for _, in := range inputs {
+			precise, _ := ctx.Value(rebuild.PreferPreciseToolchainID).(bool)
+			inst, err := in.Strategy.GenerateFor(in.Target, rebuild.BuildEnv{HasRepo: true, PreferPreciseToolchain: precise})
+			if err != nil {
+				t.Fatalf("GenerateFor() error = %v", err)
+			}
+			toolchain := in.Strategy.(*cratesio.CratesIOCargoPackage).RustVersion
+			if !strings.Contains(inst.Deps, "--default-toolchain "+toolchain) {
+				t.Errorf("rebuild of %s with %s does not install the toolchain:\n%s", in.Target.Version, toolchain, inst.Deps)
+			}
+			key := in.Target.Version + "@" + toolchain
+			rebuilt = append(rebuilt, key)
+			out = append(out, rebuild.Verdict{Target: in.Target, Message: outcomes[key], Strategy: in.Strategy})
+		}
+		return out, nil
+	}
+	got, matrix, err := rebuildMatrix(context.Background(), rebuildMany, verdicts, []string{"1.65.0", "1.70.0"}, rebuild.RegistryMux{})
+	if err != nil {
+		t.Fatalf("rebuildMatrix() error = %v", err)
+	}
+	if diff := cmp.Diff([]string{"1.0.0@1.65.0", "1.0.0@1.70.0", "1.1.0@1.65.0", "1.1.0@1.70.0"}, rebuilt); diff != "" {
+		t.Errorf("rebuildMatrix() rebuilds mismatch (-want +got):\n%s", diff)
+	}
+	wantMatrix := [][]schema.MatrixVerdict{
+		{{Toolchain: "1.65.0", Message: "content differences found"}, {Toolchain: "1.70.0", Message: ""}},
+		{{Toolchain: "1.65.0", Message: "content differences found"}, {Toolchain: "1.70.0", Message: ""}},
+		nil,
+	}
+	if diff := cmp.Diff(wantMatrix, matrix); diff != "" {
+		t.Errorf("rebuildMatrix() matrix mismatch (-want +got):\n%s", diff)
+	}
+	wantVerdicts := []rebuild.Verdict{
+		// The failed verdict is replaced by that of the first successful toolchain.
+		{Target: target("1.0.0"), Strategy: &cratesio.CratesIOCargoPackage{Location: loc, RustVersion: "1.70.0"}},
+		// The successful verdict is retained.
+		{Target: target("1.1.0"), Strategy: &cratesio.CratesIOCargoPackage{Location: loc, RustVersion: "1.60.0"}},
+		{Target: target("1.2.0"), Message: "no strategy", Strategy: &rebuild.LocationHint{Location: loc}},
+	}
+	if diff := cmp.Diff(wantVerdicts, got); diff != "" {
+		t.Errorf("rebuildMatrix() verdicts mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -10,16 +10,20 @@ import (
 	"github.com/google/oss-rebuild/internal/httpx"
 	cratesrb "github.com/google/oss-rebuild/pkg/rebuild/cratesio"
 	debianrb "github.com/google/oss-rebuild/pkg/rebuild/debian"
+	gomodrb "github.com/google/oss-rebuild/pkg/rebuild/gomod"
 	mavenrb "github.com/google/oss-rebuild/pkg/rebuild/maven"
 	npmrb "github.com/google/oss-rebuild/pkg/rebuild/npm"
 	pypirb "github.com/google/oss-rebuild/pkg/rebuild/pypi"
 	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	rubygemsrb "github.com/google/oss-rebuild/pkg/rebuild/rubygems"
 	"github.com/google/oss-rebuild/pkg/rebuild/schema"
 	cratesreg "github.com/google/oss-rebuild/pkg/registry/cratesio"
 	debianreg "github.com/google/oss-rebuild/pkg/registry/debian"
+	gomodreg "github.com/google/oss-rebuild/pkg/registry/gomod"
 	mavenreg "github.com/google/oss-rebuild/pkg/registry/maven"
 	npmreg "github.com/google/oss-rebuild/pkg/registry/npm"
 	pypireg "github.com/google/oss-rebuild/pkg/registry/pypi"
+	rubygemsreg "github.com/google/oss-rebuild/pkg/registry/rubygems"
 	"github.com/pkg/errors"
 	"google.golang.org/grpc/codes"
 )
@@ -29,11 +33,20 @@ func doDebianRebuildSmoketest(ctx context.Context, req schema.SmoketestRequest,
 		return nil, errors.New("Debian smoketest versions must not be empty")
 	}
 	rbctx := ctx
-	// TODO: Require artifacts and populate those for debian.
 	inputs, err := req.ToInputs()
 	if err != nil {
 		return nil, errors.Wrap(err, "convert smoketest request to inputs")
 	}
+	// NOTE: Absent an artifact, the source package is rebuilt and a verdict is
+	// returned for each of its binary packages.
+	for i := range inputs {
+		if inputs[i].Target.Artifact == "" {
+			inputs[i].Target.Artifact, err = debianrb.SourceArtifact(inputs[i].Target)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
 	return debianrb.RebuildMany(rbctx, inputs, mux)
 }
 
@@ -97,6 +110,44 @@ func doCratesIORebuildSmoketest(ctx context.Context, req schema.SmoketestRequest
 	return cratesrb.RebuildMany(rbctx, inputs, mux)
 }
 
+func doGoModRebuildSmoketest(ctx context.Context, req schema.SmoketestRequest, mux rebuild.RegistryMux, versionCount int) ([]rebuild.Verdict, error) {
+	if len(req.Versions) == 0 {
+		var err error
+		req.Versions, err = gomodrb.GetVersions(ctx, req.Package, mux)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to fetch versions")
+		}
+		if len(req.Versions) > versionCount {
+			req.Versions = req.Versions[:versionCount]
+		}
+	}
+	rbctx := ctx
+	inputs, err := req.ToInputs()
+	if err != nil {
+		return nil, errors.Wrap(err, "converting smoketest request to inputs")
+	}
+	return gomodrb.RebuildMany(rbctx, inputs, mux)
+}
+
+func doRubyGemsRebuildSmoketest(ctx context.Context, req schema.SmoketestRequest, mux rebuild.RegistryMux, versionCount int) ([]rebuild.Verdict, error) {
+	if len(req.Versions) == 0 {
+		var err error
+		req.Versions, err = rubygemsrb.GetVersions(ctx, req.Package, mux)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to fetch versions")
+		}
+		if len(req.Versions) > versionCount {
+			req.Versions = req.Versions[:versionCount]
+		}
+	}
+	rbctx := ctx
+	inputs, err := req.ToInputs()
+	if err != nil {
+		return nil, errors.Wrap(err, "converting smoketest request to inputs")
+	}
+	return rubygemsrb.RebuildMany(rbctx, inputs, mux)
+}
+
 func doMavenRebuildSmoketest(ctx context.Context, req schema.SmoketestRequest, versionCount int) ([]rebuild.Verdict, error) {
 	if len(req.Versions) == 0 {
 		var meta mavenreg.MavenPackage
@@ -136,8 +187,10 @@ func RebuildSmoketest(ctx context.Context, sreq schema.SmoketestRequest, deps *R
 	mux := rebuild.RegistryMux{
 		Debian:   debianreg.HTTPRegistry{Client: deps.HTTPClient},
 		CratesIO: cratesreg.HTTPRegistry{Client: deps.HTTPClient},
+		GoMod:    gomodreg.HTTPRegistry{Client: deps.HTTPClient},
 		NPM:      npmreg.HTTPRegistry{Client: deps.HTTPClient},
 		PyPI:     pypireg.HTTPRegistry{Client: deps.HTTPClient},
+		RubyGems: rubygemsreg.HTTPRegistry{Client: deps.HTTPClient},
 	}
 	if deps.TimewarpURL != nil {
 		ctx = context.WithValue(ctx, rebuild.TimewarpID, *deps.TimewarpURL)
@@ -157,6 +210,10 @@ func RebuildSmoketest(ctx context.Context, sreq schema.SmoketestRequest, deps *R
 		verdicts, err = doPypiRebuildSmoketest(ctx, sreq, mux, deps.DefaultVersionCount)
 	case rebuild.CratesIO:
 		verdicts, err = doCratesIORebuildSmoketest(ctx, sreq, mux, deps.DefaultVersionCount)
+	case rebuild.GoMod:
+		verdicts, err = doGoModRebuildSmoketest(ctx, sreq, mux, deps.DefaultVersionCount)
+	case rebuild.RubyGems:
+		verdicts, err = doRubyGemsRebuildSmoketest(ctx, sreq, mux, deps.DefaultVersionCount)
 	case rebuild.Maven:
 		verdicts, err = doMavenRebuildSmoketest(ctx, sreq, deps.DefaultVersionCount)
 	default:
@@ -165,17 +222,35 @@ func RebuildSmoketest(ctx context.Context, sreq schema.SmoketestRequest, deps *R
 	if err != nil {
 		return nil, api.AsStatus(codes.Internal, err)
 	}
-	if len(verdicts) != len(sreq.Versions) {
+	// NOTE: Debian source package targets produce a verdict per binary package.
+	if sreq.Ecosystem != rebuild.Debian && len(verdicts) != len(sreq.Versions) {
 		return nil, api.AsStatus(codes.Internal, errors.Errorf("unexpected number of results [want=%d,got=%d]", len(sreq.Versions), len(verdicts)))
 	}
+	var matrix [][]schema.MatrixVerdict
+	if len(sreq.ToolchainMatrix) > 0 {
+		rebuildMany, err := matrixRebuilder(sreq.Ecosystem)
+		if err != nil {
+			return nil, api.AsStatus(codes.InvalidArgument, err)
+		}
+		verdicts, matrix, err = rebuildMatrix(ctx, rebuildMany, verdicts, sreq.ToolchainMatrix, mux)
+		if err != nil {
+			return nil, api.AsStatus(codes.Internal, errors.Wrap(err, "rebuilding toolchain matrix"))
+		}
+	}
 	smkVerdicts := make([]schema.Verdict, len(verdicts))
 	for i, v := range verdicts {
 		smkVerdicts[i] = schema.Verdict{
-			Target:        v.Target,
-			Message:       v.Message,
-			StrategyOneof: schema.NewStrategyOneOf(v.Strategy),
-			Timings:       v.Timings,
+			Target:         v.Target,
+			Message:        v.Message,
+			StrategyOneof:  schema.NewStrategyOneOf(v.Strategy),
+			Timings:        v.Timings,
+			SecretFindings: v.SecretFindings,
+			LogSummary:     v.LogSummary,
+		}
+		if matrix != nil {
+			smkVerdicts[i].Matrix = matrix[i]
 		}
+		smkVerdicts[i].StrategyOneof.Provenance = v.Provenance
 	}
 	return &schema.SmoketestResponse{Verdicts: smkVerdicts, Executor: os.Getenv("K_REVISION")}, nil
 }
@@ -1,8 +1,12 @@
 package api
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"net/url"
@@ -50,6 +54,14 @@ func Stub[I schema.Message, O any](client httpx.BasicClient, u url.URL) StubT[I,
 		}
 		defer resp.Body.Close()
 		if resp.StatusCode != http.StatusOK {
+			var verr schema.ValidationError
+			if resp.StatusCode == http.StatusBadRequest && json.NewDecoder(resp.Body).Decode(&verr) == nil && verr.Code != "" {
+				return nil, errors.Wrapf(ErrNotOK, "%s: %s", resp.Status, verr.Error())
+			}
+			if resp.StatusCode == http.StatusRequestEntityTooLarge {
+				msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+				return nil, errors.Wrapf(ErrNotOK, "%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+			}
 			return nil, errors.Wrap(ErrNotOK, resp.Status)
 		}
 		var o O
@@ -88,20 +100,143 @@ var grpcToHTTP = map[codes.Code]int{
 	codes.Unauthenticated:    http.StatusUnauthorized,
 }
 
-func Handler[I schema.Message, O any, D Dependencies](initDeps InitT[D], handler HandlerT[I, O, D]) http.HandlerFunc {
+// parseError converts a form decoding error to a ValidationError.
+func parseError(err error) *schema.ValidationError {
+	var ferr *form.FieldError
+	switch {
+	case errors.As(err, &ferr) && errors.Is(ferr.Err, form.ErrMissingRequired):
+		return &schema.ValidationError{Code: schema.CodeMissingField, Field: ferr.Field, Message: "required"}
+	case errors.As(err, &ferr):
+		return &schema.ValidationError{Code: schema.CodeInvalidValue, Field: ferr.Field, Message: ferr.Err.Error()}
+	default:
+		return &schema.ValidationError{Code: schema.CodeMalformedRequest, Message: err.Error()}
+	}
+}
+
+// writeValidationError responds with a JSON-encoded ValidationError.
+func writeValidationError(rw http.ResponseWriter, verr *schema.ValidationError) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusBadRequest)
+	if err := json.NewEncoder(rw).Encode(verr); err != nil {
+		log.Println(errors.Wrap(err, "encoding error response"))
+	}
+}
+
+// DefaultMaxRequestBytes is the default limit on the size of a request body.
+//
+// NOTE: This matches the limit net/http applies when parsing url-encoded forms.
+const DefaultMaxRequestBytes int64 = 10 << 20
+
+// gzipMinBytes is the smallest response that will be compressed.
+const gzipMinBytes = 1 << 10
+
+type handlerConfig struct {
+	maxRequestBytes int64
+	callerAudience  string
+}
+
+// HandlerOption configures the behavior of a Handler.
+type HandlerOption func(*handlerConfig)
+
+// WithMaxRequestBytes sets the largest request body the Handler will accept.
+//
+// Larger requests are rejected with 413 Request Entity Too Large.
+func WithMaxRequestBytes(n int64) HandlerOption {
+	return func(c *handlerConfig) {
+		c.maxRequestBytes = n
+	}
+}
+
+// WithCallerAudience identifies the callers of the Handler by the ID tokens they present.
+//
+// Tokens must be Google-signed and issued for aud. Absent this option, or for
+// requests without a valid token, the caller is unknown.
+func WithCallerAudience(aud string) HandlerOption {
+	return func(c *handlerConfig) {
+		c.callerAudience = aud
+	}
+}
+
+// acceptsGzip returns whether the client indicated it accepts gzip-encoded responses.
+func acceptsGzip(r *http.Request) bool {
+	for _, enc := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(enc), ";")
+		if strings.EqualFold(strings.TrimSpace(name), "gzip") && strings.ReplaceAll(params, " ", "") != "q=0" {
+			return true
+		}
+	}
+	return false
+}
+
+// writeResponse writes the JSON-encoded response, compressing it if the client accepts gzip.
+//
+// Only encoding errors are returned. Once the body has started, the status can
+// no longer be changed so write errors are logged.
+func writeResponse(rw http.ResponseWriter, r *http.Request, o any) error {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(o); err != nil {
+		return errors.Wrap(err, "encoding response")
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Header().Add("Vary", "Accept-Encoding")
+	if buf.Len() < gzipMinBytes || !acceptsGzip(r) {
+		if _, err := rw.Write(buf.Bytes()); err != nil {
+			log.Println(errors.Wrap(err, "writing response"))
+		}
+		return nil
+	}
+	rw.Header().Set("Content-Encoding", "gzip")
+	gw := gzip.NewWriter(rw)
+	if _, err := gw.Write(buf.Bytes()); err != nil {
+		log.Println(errors.Wrap(err, "writing response"))
+	} else if err := gw.Close(); err != nil {
+		log.Println(errors.Wrap(err, "writing response"))
+	}
+	return nil
+}
+
+func Handler[I schema.Message, O any, D Dependencies](initDeps InitT[D], handler HandlerT[I, O, D], opts ...HandlerOption) http.HandlerFunc {
+	cfg := handlerConfig{maxRequestBytes: DefaultMaxRequestBytes}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
 	return func(rw http.ResponseWriter, r *http.Request) {
-		ctx := context.Background()
-		r.ParseForm()
+		// NOTE: The request context carries any propagated trace context and is
+		// cancelled when the client disconnects.
+		ctx := r.Context()
+		if cfg.callerAudience != "" {
+			if caller := callerFromRequest(r, cfg.callerAudience); caller != "" {
+				ctx = WithCaller(ctx, caller)
+			}
+		}
+		if r.Body != nil {
+			r.Body = http.MaxBytesReader(rw, r.Body, cfg.maxRequestBytes)
+		}
+		if err := r.ParseForm(); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				log.Println(errors.Wrap(err, "reading request"))
+				http.Error(rw, fmt.Sprintf("request body exceeds the %d byte limit", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+				return
+			}
+			log.Println(errors.Wrap(err, "parsing request"))
+			writeValidationError(rw, parseError(err))
+			return
+		}
 		var req I
 		if err := form.Unmarshal(r.Form, &req); err != nil {
 			log.Println(errors.Wrap(err, "parsing request"))
-			http.Error(rw, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			writeValidationError(rw, parseError(err))
 			return
 		}
 		log.Printf("received request: %+v", req)
 		if err := req.Validate(); err != nil {
 			log.Println(errors.Wrap(err, "validating request"))
-			http.Error(rw, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			var verr *schema.ValidationError
+			if !errors.As(err, &verr) {
+				verr = &schema.ValidationError{Code: schema.CodeInvalidValue, Message: err.Error()}
+			}
+			writeValidationError(rw, verr)
 			return
 		}
 		deps, err := initDeps(ctx)
@@ -123,10 +258,22 @@ func Handler[I schema.Message, O any, D Dependencies](initDeps InitT[D], handler
 			return
 		}
 		if o != nil {
-			if err := json.NewEncoder(rw).Encode(o); err != nil {
-				log.Println(errors.Wrap(err, "encoding response"))
+			if err := writeResponse(rw, r, o); err != nil {
+				log.Println(err)
 				http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 			}
 		}
 	}
 }
+
+// WithPathValues exposes the named path wildcards of the request pattern as form values.
+func WithPathValues(h http.HandlerFunc, names ...string) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		for _, name := range names {
+			q.Set(name, r.PathValue(name))
+		}
+		r.URL.RawQuery = q.Encode()
+		h(rw, r)
+	}
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"errors"
@@ -9,9 +10,13 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/google/oss-rebuild/internal/tracing"
 	"github.com/google/oss-rebuild/internal/urlx"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
@@ -162,3 +167,211 @@ func TestHandlerWithError(t *testing.T) {
 		t.Errorf("Expected body '%s', got '%s'", expectedBody, string(b))
 	}
 }
+
+func TestHandlerWithInvalidRequest(t *testing.T) {
+	handler := func(ctx context.Context, req FooRequest, _ *NoDeps) (*FooResponse, error) {
+		t.Error("handler should not be called")
+		return nil, nil
+	}
+
+	server := httptest.NewServer(Handler(NoDepsInit, handler))
+	defer server.Close()
+
+	resp, err := http.PostForm(server.URL, url.Values{"bar": {"bar"}})
+	if err != nil {
+		t.Fatalf("Request returned an error: %v", err)
+	}
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+	var got schema.ValidationError
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("Error unmarshaling response: %v", err)
+	}
+	expected := schema.ValidationError{Code: schema.CodeMissingField, Field: "foo", Message: "required"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+
+	stub := Stub[FooRequest, FooResponse](server.Client(), *urlx.MustParse(server.URL))
+	if _, err := stub(context.Background(), FooRequest{}); !errors.Is(err, ErrNotOK) {
+		t.Errorf("Expected ErrNotOK, got %v", err)
+	}
+}
+
+func TestHandlerWithOversizedRequest(t *testing.T) {
+	handler := func(ctx context.Context, req FooRequest, _ *NoDeps) (*FooResponse, error) {
+		t.Error("handler should not be called")
+		return nil, nil
+	}
+
+	server := httptest.NewServer(Handler(NoDepsInit, handler, WithMaxRequestBytes(16)))
+	defer server.Close()
+
+	resp, err := http.PostForm(server.URL, url.Values{"foo": {strings.Repeat("a", 32)}})
+	if err != nil {
+		t.Fatalf("Request returned an error: %v", err)
+	}
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status code %d, got %d", http.StatusRequestEntityTooLarge, resp.StatusCode)
+	}
+	expectedBody := "request body exceeds the 16 byte limit\n"
+	b, _ := io.ReadAll(resp.Body)
+	if string(b) != expectedBody {
+		t.Errorf("Expected body '%s', got '%s'", expectedBody, string(b))
+	}
+
+	stub := Stub[FooRequest, FooResponse](server.Client(), *urlx.MustParse(server.URL))
+	_, err = stub(context.Background(), FooRequest{Foo: strings.Repeat("a", 32)})
+	if !errors.Is(err, ErrNotOK) || !strings.Contains(err.Error(), "16 byte limit") {
+		t.Errorf("Expected ErrNotOK describing the limit, got %v", err)
+	}
+}
+
+func TestHandlerTraceContext(t *testing.T) {
+	if _, err := tracing.Setup(context.Background(), "test", ""); err != nil {
+		t.Fatal(err)
+	}
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	var got trace.SpanContext
+	handler := func(ctx context.Context, req FooRequest, _ *NoDeps) (*FooResponse, error) {
+		got = trace.SpanContextFromContext(ctx)
+		return &FooResponse{Bar: "Bar"}, nil
+	}
+
+	server := httptest.NewServer(tracing.Handler(Handler(NoDepsInit, handler)))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(url.Values{"foo": {"foo"}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("Request returned an error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	if got.TraceID().String() != traceID {
+		t.Errorf("Expected handler context in trace %s, got %s", traceID, got.TraceID())
+	}
+}
+
+func TestHandlerCompression(t *testing.T) {
+	long := strings.Repeat("bar", gzipMinBytes)
+	handler := func(ctx context.Context, req FooRequest, _ *NoDeps) (*FooResponse, error) {
+		if req.Foo == "long" {
+			return &FooResponse{Bar: long}, nil
+		}
+		return &FooResponse{Bar: "Bar"}, nil
+	}
+	server := httptest.NewServer(Handler(NoDepsInit, handler))
+	defer server.Close()
+
+	for _, tc := range []struct {
+		name         string
+		foo          string
+		encoding     string
+		wantEncoding string
+		want         string
+	}{
+		{name: "large response", foo: "long", encoding: "gzip", wantEncoding: "gzip", want: long},
+		{name: "small response", foo: "short", encoding: "gzip", want: "Bar"},
+		{name: "gzip not accepted", foo: "long", encoding: "identity", want: long},
+		{name: "gzip refused", foo: "long", encoding: "gzip;q=0", want: long},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(url.Values{"foo": {tc.foo}}.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.Header.Set("Accept-Encoding", tc.encoding)
+			resp, err := server.Client().Do(req)
+			if err != nil {
+				t.Fatalf("Request returned an error: %v", err)
+			}
+			defer resp.Body.Close()
+			if got := resp.Header.Get("Content-Encoding"); got != tc.wantEncoding {
+				t.Errorf("Expected Content-Encoding '%s', got '%s'", tc.wantEncoding, got)
+			}
+			var body io.Reader = resp.Body
+			if tc.wantEncoding == "gzip" {
+				if body, err = gzip.NewReader(resp.Body); err != nil {
+					t.Fatalf("Error creating gzip reader: %v", err)
+				}
+			}
+			var result FooResponse
+			if err := json.NewDecoder(body).Decode(&result); err != nil {
+				t.Fatalf("Error unmarshaling response: %v", err)
+			}
+			if result.Bar != tc.want {
+				t.Errorf("Expected Bar of length %d, got %d", len(tc.want), len(result.Bar))
+			}
+		})
+	}
+
+	stub := Stub[FooRequest, FooResponse](server.Client(), *urlx.MustParse(server.URL))
+	result, err := stub(context.Background(), FooRequest{Foo: "long"})
+	if err != nil {
+		t.Fatalf("Stub returned an error: %v", err)
+	}
+	if result.Bar != long {
+		t.Errorf("Expected Bar of length %d, got %d", len(long), len(result.Bar))
+	}
+}
+
+// brokenWriter is a ResponseWriter whose body writes fail.
+type brokenWriter struct {
+	header   http.Header
+	statuses []int
+}
+
+func (w *brokenWriter) Header() http.Header { return w.header }
+
+func (w *brokenWriter) WriteHeader(status int) { w.statuses = append(w.statuses, status) }
+
+func (w *brokenWriter) Write([]byte) (int, error) {
+	if len(w.statuses) == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	return 0, errors.New("connection reset")
+}
+
+func TestHandlerWriteFailure(t *testing.T) {
+	handler := func(ctx context.Context, req FooRequest, _ *NoDeps) (*FooResponse, error) {
+		return &FooResponse{Bar: strings.Repeat("bar", gzipMinBytes)}, nil
+	}
+	for _, encoding := range []string{"gzip", "identity"} {
+		t.Run(encoding, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(url.Values{"foo": {"foo"}}.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.Header.Set("Accept-Encoding", encoding)
+			rw := &brokenWriter{header: make(http.Header)}
+			Handler(NoDepsInit, handler)(rw, req)
+			if !reflect.DeepEqual(rw.statuses, []int{http.StatusOK}) {
+				t.Errorf("Expected a single %d status, got %v", http.StatusOK, rw.statuses)
+			}
+		})
+	}
+}
+
+func TestWithPathValues(t *testing.T) {
+	handler := func(ctx context.Context, req FooRequest, _ *NoDeps) (*FooResponse, error) {
+		return &FooResponse{Bar: req.Foo}, nil
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/foos/{foo}", WithPathValues(Handler(NoDepsInit, handler), "foo"))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	resp, err := server.Client().Get(server.URL + "/foos/baz")
+	if err != nil {
+		t.Fatalf("Request returned an error: %v", err)
+	}
+	var result FooResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("Error unmarshaling response: %v", err)
+	}
+	if result.Bar != "baz" {
+		t.Errorf("Expected Bar 'baz', got '%s'", result.Bar)
+	}
+}
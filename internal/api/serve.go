@@ -0,0 +1,95 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ShutdownHook is run when the server begins draining, allowing long-running handlers to checkpoint their progress.
+type ShutdownHook func(context.Context) error
+
+// Server is an HTTP server that drains in-flight requests on termination.
+type Server struct {
+	Addr    string
+	Handler http.Handler
+	// DrainTimeout bounds the time allowed for in-flight requests and hooks to complete.
+	DrainTimeout time.Duration
+
+	mu    sync.Mutex
+	hooks []ShutdownHook
+}
+
+// OnShutdown registers a hook to be run when the server begins draining.
+func (s *Server) OnShutdown(hook ShutdownHook) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hooks = append(s.hooks, hook)
+}
+
+// ListenAndServe serves until SIGINT or SIGTERM is received, then drains in-flight requests.
+func (s *Server) ListenAndServe() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ln, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return errors.Wrap(err, "listening")
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve serves on ln until ctx is done, then drains in-flight requests.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	srv := &http.Server{Handler: s.Handler}
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(ln) }()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	log.Printf("Draining in-flight requests (timeout %v)...", s.DrainTimeout)
+	drainCtx, cancel := context.WithTimeout(context.Background(), s.DrainTimeout)
+	defer cancel()
+	s.mu.Lock()
+	hooks := s.hooks
+	s.mu.Unlock()
+	var wg sync.WaitGroup
+	for _, hook := range hooks {
+		wg.Add(1)
+		go func(hook ShutdownHook) {
+			defer wg.Done()
+			if err := hook(drainCtx); err != nil {
+				log.Println(errors.Wrap(err, "running shutdown hook"))
+			}
+		}(hook)
+	}
+	err := srv.Shutdown(drainCtx)
+	wg.Wait()
+	if err != nil {
+		return errors.Wrap(err, "draining requests")
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestServerDrainsInflightRequests(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	s := &Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+			w.Write([]byte("done"))
+		}),
+		DrainTimeout: 5 * time.Second,
+	}
+	hookCalled := make(chan struct{})
+	s.OnShutdown(func(ctx context.Context) error {
+		close(hookCalled)
+		// Allow the in-flight request to complete once checkpointed.
+		close(release)
+		return nil
+	})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(ctx, ln) }()
+	body := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String())
+		if err != nil {
+			t.Errorf("Request returned an error: %v", err)
+			body <- ""
+			return
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		body <- string(b)
+	}()
+	<-started
+	cancel()
+	if err := <-served; err != nil {
+		t.Errorf("Serve() error = %v", err)
+	}
+	select {
+	case <-hookCalled:
+	default:
+		t.Error("Shutdown hook not called")
+	}
+	if got := <-body; got != "done" {
+		t.Errorf("Expected in-flight request to complete, got body %q", got)
+	}
+}
+
+func TestServerDrainTimeout(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	s := &Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+		}),
+		DrainTimeout: 10 * time.Millisecond,
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(ctx, ln) }()
+	go http.Get("http://" + ln.Addr().String())
+	<-started
+	cancel()
+	if err := <-served; err == nil {
+		t.Error("Serve() expected drain timeout error")
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package config resolves process configuration from command-line flags,
+// environment variables, and a YAML config file.
+//
+// Each flag is resolved with the following precedence: the command line, the
+// environment variable derived from its name, the config file, and finally
+// the flag's default.
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Names of the flags registered by the Loader.
+const (
+	configFlag      = "config"
+	printConfigFlag = "print-config"
+)
+
+// Loader applies environment and config file values to a FlagSet.
+type Loader struct {
+	// EnvPrefix is prepended to the environment variable of each flag.
+	// For example, with the prefix "OSS_REBUILD_API", the "metadata-bucket"
+	// flag is read from OSS_REBUILD_API_METADATA_BUCKET.
+	EnvPrefix string
+	// LookupEnv reads an environment variable. Defaults to os.LookupEnv.
+	LookupEnv func(string) (string, bool)
+
+	path  string
+	print bool
+}
+
+// RegisterFlags registers the flags controlling config loading.
+func (l *Loader) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&l.path, configFlag, "", "if provided, path to a YAML file mapping flag names to values")
+	fs.BoolVar(&l.print, printConfigFlag, false, "whether to print the resolved configuration as YAML and exit")
+}
+
+// EnvVar returns the environment variable from which the named flag is read.
+func (l *Loader) EnvVar(name string) string {
+	name = strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name))
+	if l.EnvPrefix == "" {
+		return name
+	}
+	return l.EnvPrefix + "_" + name
+}
+
+// Load sets the flags of fs not provided on the command line from the
+// environment and the config file.
+//
+// NOTE: fs must already have been parsed.
+func (l *Loader) Load(fs *flag.FlagSet) error {
+	lookup := l.LookupEnv
+	if lookup == nil {
+		lookup = os.LookupEnv
+	}
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	path := l.path
+	if !explicit[configFlag] {
+		if v, ok := lookup(l.EnvVar(configFlag)); ok {
+			path = v
+		}
+	}
+	fromFile := make(map[string]string)
+	if path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return errors.Wrap(err, "opening config file")
+		}
+		defer f.Close()
+		fromFile, err = readFile(f, fs)
+		if err != nil {
+			return errors.Wrapf(err, "reading %s", path)
+		}
+	}
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || explicit[f.Name] || f.Name == configFlag || f.Name == printConfigFlag {
+			return
+		}
+		if v, ok := lookup(l.EnvVar(f.Name)); ok {
+			err = errors.Wrapf(fs.Set(f.Name, v), "setting %s from %s", f.Name, l.EnvVar(f.Name))
+		} else if v, ok := fromFile[f.Name]; ok {
+			err = errors.Wrapf(fs.Set(f.Name, v), "setting %s from config file", f.Name)
+		}
+	})
+	return err
+}
+
+// MustLoad loads the config into fs, exiting on error. If -print-config was
+// provided, the resolved config is written to stdout and the process exits.
+func (l *Loader) MustLoad(fs *flag.FlagSet) {
+	if err := l.Load(fs); err != nil {
+		log.Fatalln(errors.Wrap(err, "loading config"))
+	}
+	if l.print {
+		if err := Dump(os.Stdout, fs); err != nil {
+			log.Fatalln(errors.Wrap(err, "printing config"))
+		}
+		os.Exit(0)
+	}
+}
+
+// readFile decodes a YAML mapping of flag names to values.
+//
+// Sequences are joined with commas to match the convention of list-valued flags.
+func readFile(r io.Reader, fs *flag.FlagSet) (map[string]string, error) {
+	var raw map[string]any
+	if err := yaml.NewDecoder(r).Decode(&raw); err != nil && err != io.EOF {
+		return nil, errors.Wrap(err, "decoding YAML")
+	}
+	values := make(map[string]string)
+	for k, v := range raw {
+		if fs.Lookup(k) == nil || k == configFlag || k == printConfigFlag {
+			return nil, errors.Errorf("unknown key %q", k)
+		}
+		switch v := v.(type) {
+		case nil:
+			values[k] = ""
+		case []any:
+			var items []string
+			for _, item := range v {
+				items = append(items, fmt.Sprint(item))
+			}
+			values[k] = strings.Join(items, ",")
+		case map[string]any:
+			return nil, errors.Errorf("unsupported mapping value for %q", k)
+		default:
+			values[k] = fmt.Sprint(v)
+		}
+	}
+	return values, nil
+}
+
+// Dump writes the current values of the flags of fs as a YAML config file.
+func Dump(w io.Writer, fs *flag.FlagSet) error {
+	values := make(map[string]any)
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Name == configFlag || f.Name == printConfigFlag {
+			return
+		}
+		// NOTE: Booleans and numbers are emitted unquoted for readability.
+		if g, ok := f.Value.(flag.Getter); ok {
+			switch v := g.Get().(type) {
+			case bool, int, int64, uint, uint64, float64:
+				values[f.Name] = v
+				return
+			}
+		}
+		values[f.Name] = f.Value.String()
+	})
+	e := yaml.NewEncoder(w)
+	e.SetIndent(2)
+	if err := e.Encode(values); err != nil {
+		return errors.Wrap(err, "encoding YAML")
+	}
+	return e.Close()
+}
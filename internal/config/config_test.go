@@ -0,0 +1,210 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type testFlags struct {
+	bucket  *string
+	port    *int
+	enabled *bool
+	timeout *time.Duration
+	allow   *string
+}
+
+func newFlagSet(l *Loader) (*flag.FlagSet, testFlags) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	tf := testFlags{
+		bucket:  fs.String("metadata-bucket", "default-bucket", ""),
+		port:    fs.Int("port", 8080, ""),
+		enabled: fs.Bool("enabled", false, ""),
+		timeout: fs.Duration("timeout", time.Second, ""),
+		allow:   fs.String("allow", "", ""),
+	}
+	l.RegisterFlags(fs)
+	return fs, tf
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(cfgPath, []byte("metadata-bucket: file-bucket\nport: 9000\nenabled: true\ntimeout: 5s\nallow: [a, b]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name        string
+		args        []string
+		env         map[string]string
+		wantBucket  string
+		wantPort    int
+		wantEnabled bool
+		wantTimeout time.Duration
+		wantAllow   string
+	}{
+		{
+			name:        "defaults",
+			wantBucket:  "default-bucket",
+			wantPort:    8080,
+			wantTimeout: time.Second,
+		},
+		{
+			name:        "file",
+			args:        []string{"-config", cfgPath},
+			wantBucket:  "file-bucket",
+			wantPort:    9000,
+			wantEnabled: true,
+			wantTimeout: 5 * time.Second,
+			wantAllow:   "a,b",
+		},
+		{
+			name:        "file from env",
+			env:         map[string]string{"SVC_CONFIG": cfgPath},
+			wantBucket:  "file-bucket",
+			wantPort:    9000,
+			wantEnabled: true,
+			wantTimeout: 5 * time.Second,
+			wantAllow:   "a,b",
+		},
+		{
+			name:        "env overrides file",
+			args:        []string{"-config", cfgPath},
+			env:         map[string]string{"SVC_METADATA_BUCKET": "env-bucket", "SVC_ENABLED": "false"},
+			wantBucket:  "env-bucket",
+			wantPort:    9000,
+			wantTimeout: 5 * time.Second,
+			wantAllow:   "a,b",
+		},
+		{
+			name:        "flag overrides env and file",
+			args:        []string{"-config", cfgPath, "-metadata-bucket", "flag-bucket", "-port", "1"},
+			env:         map[string]string{"SVC_METADATA_BUCKET": "env-bucket", "SVC_PORT": "2"},
+			wantBucket:  "flag-bucket",
+			wantPort:    1,
+			wantEnabled: true,
+			wantTimeout: 5 * time.Second,
+			wantAllow:   "a,b",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			l := &Loader{EnvPrefix: "SVC", LookupEnv: func(k string) (string, bool) {
+				v, ok := tc.env[k]
+				return v, ok
+			}}
+			fs, tf := newFlagSet(l)
+			if err := fs.Parse(tc.args); err != nil {
+				t.Fatal(err)
+			}
+			if err := l.Load(fs); err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if *tf.bucket != tc.wantBucket || *tf.port != tc.wantPort || *tf.enabled != tc.wantEnabled || *tf.timeout != tc.wantTimeout || *tf.allow != tc.wantAllow {
+				t.Errorf("Load() = {%q, %d, %v, %v, %q}, want {%q, %d, %v, %v, %q}", *tf.bucket, *tf.port, *tf.enabled, *tf.timeout, *tf.allow, tc.wantBucket, tc.wantPort, tc.wantEnabled, tc.wantTimeout, tc.wantAllow)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+	for _, tc := range []struct {
+		name    string
+		content string
+		env     map[string]string
+	}{
+		{name: "unknown key", content: "bucket: x\n"},
+		{name: "loader key", content: "print-config: true\n"},
+		{name: "mapping value", content: "allow:\n  a: b\n"},
+		{name: "invalid value", content: "port: eighty\n"},
+		{name: "invalid env", env: map[string]string{"PORT": "eighty"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			l := &Loader{LookupEnv: func(k string) (string, bool) {
+				v, ok := tc.env[k]
+				return v, ok
+			}}
+			fs, _ := newFlagSet(l)
+			var args []string
+			if tc.content != "" {
+				path := filepath.Join(dir, "config.yaml")
+				if err := os.WriteFile(path, []byte(tc.content), 0644); err != nil {
+					t.Fatal(err)
+				}
+				args = []string{"-config", path}
+			}
+			if err := fs.Parse(args); err != nil {
+				t.Fatal(err)
+			}
+			if err := l.Load(fs); err == nil {
+				t.Error("Load() expected error")
+			}
+		})
+	}
+}
+
+func TestEnvVar(t *testing.T) {
+	for _, tc := range []struct {
+		prefix, name, want string
+	}{
+		{"OSS_REBUILD_API", "metadata-bucket", "OSS_REBUILD_API_METADATA_BUCKET"},
+		{"", "gateway-url", "GATEWAY_URL"},
+		{"SVC", "a.b-c", "SVC_A_B_C"},
+	} {
+		l := &Loader{EnvPrefix: tc.prefix}
+		if got := l.EnvVar(tc.name); got != tc.want {
+			t.Errorf("EnvVar(%q) = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestDump(t *testing.T) {
+	l := &Loader{}
+	fs, _ := newFlagSet(l)
+	if err := fs.Parse([]string{"-allow", "a,b", "-enabled"}); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := Dump(&buf, fs); err != nil {
+		t.Fatalf("Dump() error = %v", err)
+	}
+	want := "allow: a,b\nenabled: true\nmetadata-bucket: default-bucket\nport: 8080\ntimeout: 1s\n"
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("Dump() mismatch (-want +got):\n%s", diff)
+	}
+	// The dumped config round-trips through Load.
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	l2 := &Loader{LookupEnv: func(string) (string, bool) { return "", false }}
+	fs2, tf := newFlagSet(l2)
+	if err := fs2.Parse([]string{"-config", path}); err != nil {
+		t.Fatal(err)
+	}
+	if err := l2.Load(fs2); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if *tf.allow != "a,b" || !*tf.enabled {
+		t.Errorf("Load() of dumped config = {%q, %v}, want {%q, %v}", *tf.allow, *tf.enabled, "a,b", true)
+	}
+}
@@ -0,0 +1,278 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dashboard implements a read-only web UI over the rundex.
+package dashboard
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/oss-rebuild/tools/ctl/rundex"
+	"github.com/pkg/errors"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+const (
+	// maxRuns is the number of most recent runs listed on the index page.
+	maxRuns = 100
+	// maxRunAttempts bounds the number of attempts summarized for a single run.
+	maxRunAttempts = 10000
+	// maxExamples is the number of attempts listed for each failure category.
+	maxExamples = 10
+	runPageSize = 500
+	historySize = 50
+)
+
+// Index is the subset of the rundex used to serve the dashboard.
+type Index interface {
+	FetchRuns(context.Context, rundex.FetchRunsOpts) ([]rundex.Run, error)
+	QueryRebuilds(context.Context, rundex.RebuildQuery) (*rundex.RebuildPage, error)
+}
+
+var _ Index = &rundex.FirestoreClient{}
+
+// Server serves the dashboard pages.
+type Server struct {
+	idx Index
+	// debugStorage is the gs:// location of rebuild debug assets, if any.
+	debugStorage *url.URL
+	tmpl         *template.Template
+}
+
+// NewServer creates a dashboard Server backed by the provided Index.
+func NewServer(idx Index, debugStorage string) (*Server, error) {
+	s := &Server{idx: idx}
+	if debugStorage != "" {
+		u, err := url.Parse(debugStorage)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing debug storage")
+		}
+		if u.Scheme != "gs" {
+			return nil, errors.New("debug storage must be a gs:// location")
+		}
+		s.debugStorage = u
+	}
+	var err error
+	s.tmpl, err = template.New("").Funcs(template.FuncMap{
+		"debugURL":   s.debugURL,
+		"packageURL": packageURL,
+		"runURL":     runURL,
+		"percent":    percent,
+		"timestamp":  func(t time.Time) string { return t.UTC().Format(time.DateTime) },
+	}).ParseFS(templateFS, "templates/*.html")
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing templates")
+	}
+	return s, nil
+}
+
+// Handler returns the http.Handler serving all dashboard routes.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", s.runs)
+	mux.HandleFunc("GET /runs/{id}", s.run)
+	mux.HandleFunc("GET /packages/{ecosystem}/{name...}", s.pkg)
+	mux.HandleFunc("GET /search", s.search)
+	return mux
+}
+
+// debugURL returns a link to the debug assets stored for the rebuild, if configured.
+func (s *Server) debugURL(r rundex.Rebuild) string {
+	if s.debugStorage == nil {
+		return ""
+	}
+	// NOTE: This mirrors the object layout used by rebuild.GCSStore.
+	p := path.Join(s.debugStorage.Host, s.debugStorage.Path, r.Ecosystem, r.Package, r.Version, r.Artifact, r.RunID)
+	return "https://console.cloud.google.com/storage/browser/" + p
+}
+
+func runURL(id string) string {
+	return "/runs/" + url.PathEscape(id)
+}
+
+func packageURL(ecosystem, name string) string {
+	parts := strings.Split(name, "/")
+	for i, p := range parts {
+		parts[i] = url.PathEscape(p)
+	}
+	return "/packages/" + url.PathEscape(ecosystem) + "/" + strings.Join(parts, "/")
+}
+
+func percent(n, total int) string {
+	if total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(n)/float64(total))
+}
+
+func (s *Server) render(w http.ResponseWriter, name string, data any) {
+	// Render to a buffer so template errors don't produce partial pages.
+	var buf bytes.Buffer
+	if err := s.tmpl.ExecuteTemplate(&buf, name, data); err != nil {
+		log.Printf("rendering %s: %v", name, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	buf.WriteTo(w)
+}
+
+func (s *Server) fail(w http.ResponseWriter, err error) {
+	log.Println(err)
+	http.Error(w, "internal error", http.StatusInternalServerError)
+}
+
+func (s *Server) runs(w http.ResponseWriter, r *http.Request) {
+	runs, err := s.idx.FetchRuns(r.Context(), rundex.FetchRunsOpts{})
+	if err != nil {
+		s.fail(w, errors.Wrap(err, "fetching runs"))
+		return
+	}
+	slices.SortFunc(runs, func(a, b rundex.Run) int {
+		return b.Created.Compare(a.Created)
+	})
+	if len(runs) > maxRuns {
+		runs = runs[:maxRuns]
+	}
+	s.render(w, "runs.html", runs)
+}
+
+// FailureCategory is a group of failed attempts sharing a cleaned verdict.
+type FailureCategory struct {
+	Message  string
+	Count    int
+	Examples []rundex.Rebuild
+}
+
+// RunSummary aggregates the attempts made as part of a run.
+type RunSummary struct {
+	Run        rundex.Run
+	Total      int
+	Successes  int
+	Truncated  bool
+	Categories []FailureCategory
+}
+
+func (s *Server) run(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	runs, err := s.idx.FetchRuns(r.Context(), rundex.FetchRunsOpts{IDs: []string{id}})
+	if err != nil {
+		s.fail(w, errors.Wrap(err, "fetching run"))
+		return
+	}
+	if len(runs) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	summary, err := s.summarize(r.Context(), runs[0])
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+	s.render(w, "run.html", summary)
+}
+
+func (s *Server) summarize(ctx context.Context, run rundex.Run) (*RunSummary, error) {
+	summary := &RunSummary{Run: run}
+	failures := make(map[string]rundex.Rebuild)
+	q := rundex.RebuildQuery{Run: run.ID, Limit: runPageSize}
+	for {
+		page, err := s.idx.QueryRebuilds(ctx, q)
+		if err != nil {
+			return nil, errors.Wrap(err, "querying rebuilds")
+		}
+		for _, rb := range page.Rebuilds {
+			summary.Total++
+			if rb.Success {
+				summary.Successes++
+			} else {
+				failures[rb.ID()] = rb
+			}
+		}
+		if page.NextPageToken == "" {
+			break
+		}
+		if summary.Total >= maxRunAttempts {
+			summary.Truncated = true
+			break
+		}
+		q.PageToken = page.NextPageToken
+	}
+	groups := rundex.GroupRebuilds(failures)
+	slices.Reverse(groups)
+	for _, g := range groups {
+		c := FailureCategory{Message: g.Msg, Count: g.Count, Examples: g.Examples}
+		if len(c.Examples) > maxExamples {
+			c.Examples = c.Examples[:maxExamples]
+		}
+		summary.Categories = append(summary.Categories, c)
+	}
+	return summary, nil
+}
+
+// PackageHistory is a page of attempts for a single package.
+type PackageHistory struct {
+	Ecosystem     string
+	Package       string
+	Rebuilds      []rundex.Rebuild
+	NextPageToken string
+}
+
+func (s *Server) pkg(w http.ResponseWriter, r *http.Request) {
+	h := PackageHistory{Ecosystem: r.PathValue("ecosystem"), Package: r.PathValue("name")}
+	q := rundex.RebuildQuery{
+		Ecosystem:     h.Ecosystem,
+		PackagePrefix: h.Package,
+		Limit:         historySize,
+		PageToken:     r.URL.Query().Get("page"),
+	}
+	page, err := s.idx.QueryRebuilds(r.Context(), q)
+	if err != nil {
+		s.fail(w, errors.Wrap(err, "querying rebuilds"))
+		return
+	}
+	h.NextPageToken = page.NextPageToken
+	for _, rb := range page.Rebuilds {
+		// Prefix results are ordered by package so once another package is
+		// encountered there are no further results for this one.
+		if rb.Package != h.Package {
+			h.NextPageToken = ""
+			break
+		}
+		h.Rebuilds = append(h.Rebuilds, rb)
+	}
+	s.render(w, "package.html", h)
+}
+
+func (s *Server) search(w http.ResponseWriter, r *http.Request) {
+	ecosystem, name := r.URL.Query().Get("ecosystem"), strings.TrimSpace(r.URL.Query().Get("package"))
+	if ecosystem == "" || name == "" {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, packageURL(ecosystem, name), http.StatusSeeOther)
+}
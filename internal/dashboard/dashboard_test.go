@@ -0,0 +1,191 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dashboard
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+	"github.com/google/oss-rebuild/tools/ctl/rundex"
+)
+
+type fakeIndex struct {
+	runs     []rundex.Run
+	rebuilds []rundex.Rebuild
+	queries  []rundex.RebuildQuery
+}
+
+func (f *fakeIndex) FetchRuns(_ context.Context, opts rundex.FetchRunsOpts) ([]rundex.Run, error) {
+	var runs []rundex.Run
+	for _, r := range f.runs {
+		if len(opts.IDs) == 0 || r.ID == opts.IDs[0] {
+			runs = append(runs, r)
+		}
+	}
+	return runs, nil
+}
+
+// QueryRebuilds pages through the matching rebuilds using the result offset as the page token.
+func (f *fakeIndex) QueryRebuilds(_ context.Context, q rundex.RebuildQuery) (*rundex.RebuildPage, error) {
+	f.queries = append(f.queries, q)
+	var matches []rundex.Rebuild
+	for _, r := range f.rebuilds {
+		if q.Run != "" && r.RunID != q.Run {
+			continue
+		}
+		if q.Ecosystem != "" && r.Ecosystem != q.Ecosystem {
+			continue
+		}
+		if !strings.HasPrefix(r.Package, q.PackagePrefix) {
+			continue
+		}
+		matches = append(matches, r)
+	}
+	start, _ := strconv.Atoi(q.PageToken)
+	end := min(start+q.Limit, len(matches))
+	page := &rundex.RebuildPage{Rebuilds: matches[start:end]}
+	if end < len(matches) {
+		page.NextPageToken = strconv.Itoa(end)
+	}
+	return page, nil
+}
+
+func attempt(run, pkg, version string, success bool, msg string) rundex.Rebuild {
+	return rundex.Rebuild{RebuildAttempt: schema.RebuildAttempt{
+		Ecosystem: "npm",
+		Package:   pkg,
+		Version:   version,
+		Artifact:  pkg + "-" + version + ".tgz",
+		Success:   success,
+		Message:   msg,
+		RunID:     run,
+	}}
+}
+
+func TestSummarize(t *testing.T) {
+	idx := &fakeIndex{rebuilds: []rundex.Rebuild{
+		attempt("run1", "a", "1.0.0", true, ""),
+		attempt("run1", "b", "1.0.0", false, "rebuild content mismatch"),
+		attempt("run1", "c", "1.0.0", false, "rebuild content mismatch"),
+		attempt("run1", "d", "1.0.0", false, "build failed"),
+		attempt("run2", "e", "1.0.0", false, "build failed"),
+	}}
+	s, err := NewServer(idx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.summarize(context.Background(), rundex.Run{Run: schema.Run{ID: "run1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Total != 4 || got.Successes != 1 || got.Truncated {
+		t.Errorf("summarize() = {Total: %d, Successes: %d, Truncated: %v}, want {4, 1, false}", got.Total, got.Successes, got.Truncated)
+	}
+	var cats []string
+	for _, c := range got.Categories {
+		cats = append(cats, strconv.Itoa(c.Count)+" "+c.Message)
+	}
+	want := []string{"2 rebuild content mismatch", "1 build failed"}
+	if diff := cmp.Diff(want, cats); diff != "" {
+		t.Errorf("summarize() categories mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	idx := &fakeIndex{
+		runs: []rundex.Run{
+			{Run: schema.Run{ID: "run1", BenchmarkName: "top-npm"}, Created: time.Unix(1, 0)},
+			{Run: schema.Run{ID: "run2", BenchmarkName: "top-pypi"}, Created: time.Unix(2, 0)},
+		},
+		rebuilds: []rundex.Rebuild{
+			attempt("run1", "@scope/pkg", "1.0.0", false, "build failed"),
+			attempt("run2", "@scope/pkg", "2.0.0", true, ""),
+			attempt("run2", "@scope/pkg-extra", "1.0.0", true, ""),
+		},
+	}
+	s, err := NewServer(idx, "gs://debug-bucket/prefix")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := s.Handler()
+	for _, tc := range []struct {
+		name     string
+		path     string
+		code     int
+		contains []string
+		excludes []string
+	}{
+		{
+			name:     "runs",
+			path:     "/",
+			code:     http.StatusOK,
+			contains: []string{`href="/runs/run1"`, "top-pypi"},
+		},
+		{
+			name: "run",
+			path: "/runs/run1",
+			code: http.StatusOK,
+			contains: []string{
+				"0 of 1 attempts succeeded",
+				"build failed",
+				`href="/packages/npm/@scope/pkg"`,
+				`href="https://console.cloud.google.com/storage/browser/debug-bucket/prefix/npm/@scope/pkg/1.0.0/@scope/pkg-1.0.0.tgz/run1"`,
+			},
+		},
+		{
+			name: "missing run",
+			path: "/runs/run3",
+			code: http.StatusNotFound,
+		},
+		{
+			name:     "package",
+			path:     "/packages/npm/@scope/pkg",
+			code:     http.StatusOK,
+			contains: []string{"1.0.0", "2.0.0"},
+			excludes: []string{"pkg-extra", "Older attempts"},
+		},
+		{
+			name: "search",
+			path: "/search?ecosystem=npm&package=@scope/pkg",
+			code: http.StatusSeeOther,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			if rec.Code != tc.code {
+				t.Fatalf("GET %s = %d, want %d", tc.path, rec.Code, tc.code)
+			}
+			body := rec.Body.String()
+			for _, s := range tc.contains {
+				if !strings.Contains(body, s) {
+					t.Errorf("GET %s missing %q", tc.path, s)
+				}
+			}
+			for _, s := range tc.excludes {
+				if strings.Contains(body, s) {
+					t.Errorf("GET %s unexpectedly contains %q", tc.path, s)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package faults injects failures into external dependencies for testing.
+//
+// Injection is intended to exercise retry and failure handling end-to-end in
+// non-production deployments and must never be enabled in production.
+package faults
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/oss-rebuild/internal/gcb"
+	"github.com/google/oss-rebuild/internal/httpx"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/pkg/errors"
+	"google.golang.org/api/cloudbuild/v1"
+)
+
+// ErrInjected is wrapped by all errors produced by fault injection.
+var ErrInjected = errors.New("injected fault")
+
+// Config specifies the probability, in [0, 1], with which each fault occurs.
+type Config struct {
+	// GCBTimeout is the probability that waiting on a GCB operation times out.
+	GCBTimeout float64
+	// RegistryThrottle is the probability that a registry request receives a 429.
+	RegistryThrottle float64
+	// RegistryError is the probability that a registry request receives a 500.
+	RegistryError float64
+	// StorageWrite is the probability that a storage write fails.
+	StorageWrite float64
+}
+
+// Enabled returns whether any fault has a non-zero probability.
+func (c Config) Enabled() bool {
+	return c != Config{}
+}
+
+// ParseConfig parses a comma-separated list of fault=probability pairs.
+//
+// Supported faults are "gcb-timeout", "registry-429", "registry-500", and
+// "storage-write" e.g. "gcb-timeout=0.1,registry-429=0.2".
+func ParseConfig(spec string) (Config, error) {
+	var c Config
+	if spec == "" {
+		return c, nil
+	}
+	for _, pair := range strings.Split(spec, ",") {
+		name, val, found := strings.Cut(strings.TrimSpace(pair), "=")
+		if !found {
+			return c, errors.Errorf("malformed fault spec: %q", pair)
+		}
+		p, err := strconv.ParseFloat(val, 64)
+		if err != nil || p < 0 || p > 1 {
+			return c, errors.Errorf("invalid probability for %s: %q", name, val)
+		}
+		switch name {
+		case "gcb-timeout":
+			c.GCBTimeout = p
+		case "registry-429":
+			c.RegistryThrottle = p
+		case "registry-500":
+			c.RegistryError = p
+		case "storage-write":
+			c.StorageWrite = p
+		default:
+			return c, errors.Errorf("unknown fault: %s", name)
+		}
+	}
+	return c, nil
+}
+
+// Injector wraps dependencies to inject the configured faults.
+type Injector struct {
+	Config
+	// rand returns values in [0, 1). Overridable for testing.
+	rand func() float64
+}
+
+// NewInjector returns an Injector for the provided Config.
+func NewInjector(c Config) *Injector {
+	return &Injector{Config: c, rand: rand.Float64}
+}
+
+func (i *Injector) trigger(p float64) bool {
+	return p > 0 && i.rand() < p
+}
+
+// GCB returns a gcb.Client that injects operation timeouts.
+func (i *Injector) GCB(c gcb.Client) gcb.Client {
+	return &gcbClient{Client: c, i: i}
+}
+
+type gcbClient struct {
+	gcb.Client
+	i *Injector
+}
+
+func (c *gcbClient) WaitForOperation(ctx context.Context, op *cloudbuild.Operation) (*cloudbuild.Operation, error) {
+	if c.i.trigger(c.i.GCBTimeout) {
+		return nil, errors.Wrapf(ErrInjected, "waiting for %s: %v", op.Name, context.DeadlineExceeded)
+	}
+	return c.Client.WaitForOperation(ctx, op)
+}
+
+// HTTP returns a client that injects registry throttling and server errors.
+func (i *Injector) HTTP(c httpx.BasicClient) httpx.BasicClient {
+	return &httpClient{BasicClient: c, i: i}
+}
+
+type httpClient struct {
+	httpx.BasicClient
+	i *Injector
+}
+
+func (c *httpClient) Do(req *http.Request) (*http.Response, error) {
+	switch {
+	case c.i.trigger(c.i.RegistryThrottle):
+		resp := fakeResponse(req, http.StatusTooManyRequests)
+		resp.Header.Set("Retry-After", "1")
+		return resp, nil
+	case c.i.trigger(c.i.RegistryError):
+		return fakeResponse(req, http.StatusInternalServerError), nil
+	}
+	return c.BasicClient.Do(req)
+}
+
+func fakeResponse(req *http.Request, code int) *http.Response {
+	body := ErrInjected.Error()
+	return &http.Response{
+		Status:        strconv.Itoa(code) + " " + http.StatusText(code),
+		StatusCode:    code,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"text/plain"}},
+		Body:          io.NopCloser(bytes.NewBufferString(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// Store returns an asset store that injects write failures.
+func (i *Injector) Store(s rebuild.LocatableAssetStore) rebuild.LocatableAssetStore {
+	return &store{LocatableAssetStore: s, i: i}
+}
+
+type store struct {
+	rebuild.LocatableAssetStore
+	i *Injector
+}
+
+func (s *store) Writer(ctx context.Context, a rebuild.Asset) (io.WriteCloser, error) {
+	if s.i.trigger(s.i.StorageWrite) {
+		return nil, errors.Wrapf(ErrInjected, "writing %s", a.Type)
+	}
+	return s.LocatableAssetStore.Writer(ctx, a)
+}
@@ -0,0 +1,114 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package faults
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/google/oss-rebuild/internal/gcb/gcbtest"
+	"github.com/google/oss-rebuild/internal/httpx/httpxtest"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/pkg/errors"
+	"google.golang.org/api/cloudbuild/v1"
+)
+
+func TestParseConfig(t *testing.T) {
+	for _, tc := range []struct {
+		spec    string
+		want    Config
+		wantErr bool
+	}{
+		{spec: "", want: Config{}},
+		{spec: "gcb-timeout=0.5, registry-429=1,registry-500=0.25,storage-write=0", want: Config{GCBTimeout: 0.5, RegistryThrottle: 1, RegistryError: 0.25}},
+		{spec: "gcb-timeout", wantErr: true},
+		{spec: "gcb-timeout=2", wantErr: true},
+		{spec: "dns=0.1", wantErr: true},
+	} {
+		got, err := ParseConfig(tc.spec)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("ParseConfig(%q) error = %v, want error: %v", tc.spec, err, tc.wantErr)
+		} else if got != tc.want {
+			t.Errorf("ParseConfig(%q) = %+v, want %+v", tc.spec, got, tc.want)
+		}
+	}
+}
+
+// fixed returns an Injector whose random draws are always v.
+func fixed(c Config, v float64) *Injector {
+	i := NewInjector(c)
+	i.rand = func() float64 { return v }
+	return i
+}
+
+func TestHTTP(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		cfg  Config
+		want int
+	}{
+		{"throttle", Config{RegistryThrottle: 0.5}, http.StatusTooManyRequests},
+		{"error", Config{RegistryError: 0.5}, http.StatusInternalServerError},
+		{"unlikely", Config{RegistryError: 0.1}, http.StatusOK},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ok := &httpxtest.MockClient{
+				Calls: []httpxtest.Call{{URL: "https://registry.npmjs.org/foo", Response: &http.Response{StatusCode: http.StatusOK}}},
+			}
+			c := fixed(tc.cfg, 0.25).HTTP(ok)
+			req, _ := http.NewRequest(http.MethodGet, "https://registry.npmjs.org/foo", nil)
+			resp, err := c.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tc.want {
+				t.Errorf("Do() status = %d, want %d", resp.StatusCode, tc.want)
+			}
+		})
+	}
+}
+
+func TestGCB(t *testing.T) {
+	mock := &gcbtest.MockClient{
+		WaitForOperationFunc: func(_ context.Context, op *cloudbuild.Operation) (*cloudbuild.Operation, error) {
+			return op, nil
+		},
+	}
+	c := fixed(Config{GCBTimeout: 1}, 0.99).GCB(mock)
+	if _, err := c.WaitForOperation(context.Background(), &cloudbuild.Operation{Name: "op"}); !errors.Is(err, ErrInjected) {
+		t.Errorf("WaitForOperation() error = %v, want injected fault", err)
+	}
+	c = fixed(Config{}, 0).GCB(mock)
+	if _, err := c.WaitForOperation(context.Background(), &cloudbuild.Operation{Name: "op"}); err != nil {
+		t.Errorf("WaitForOperation() error = %v, want nil", err)
+	}
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	a := rebuild.DebugLogsAsset.For(rebuild.Target{Ecosystem: rebuild.NPM, Package: "foo", Version: "1.0.0", Artifact: "foo-1.0.0.tgz"})
+	s := fixed(Config{StorageWrite: 0.5}, 0.25).Store(rebuild.NewFilesystemAssetStore(memfs.New()))
+	if _, err := s.Writer(ctx, a); !errors.Is(err, ErrInjected) {
+		t.Errorf("Writer() error = %v, want injected fault", err)
+	}
+	s = fixed(Config{StorageWrite: 0.5}, 0.75).Store(rebuild.NewFilesystemAssetStore(memfs.New()))
+	w, err := s.Writer(ctx, a)
+	if err != nil {
+		t.Fatalf("Writer() error = %v, want nil", err)
+	}
+	w.Close()
+}
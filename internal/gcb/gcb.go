@@ -16,9 +16,14 @@ package gcb
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log"
+	"regexp"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
@@ -31,6 +36,7 @@ import (
 type Client interface {
 	CreateBuild(ctx context.Context, project string, build *cloudbuild.Build) (*cloudbuild.Operation, error)
 	WaitForOperation(ctx context.Context, op *cloudbuild.Operation) (*cloudbuild.Operation, error)
+	CancelOperation(ctx context.Context, op *cloudbuild.Operation) error
 }
 
 // clientImpl is a concrete implementation of the Client interface using the Cloud Build service.
@@ -50,9 +56,26 @@ func NewClient(s *cloudbuild.Service) Client {
 
 // CreateBuild creates and starts a GCB Build.
 func (c *clientImpl) CreateBuild(ctx context.Context, project string, build *cloudbuild.Build) (*cloudbuild.Operation, error) {
+	if build.Options != nil && build.Options.Pool != nil {
+		// NOTE: Builds using a private pool must be created in the pool's region.
+		parent, err := PoolLocation(build.Options.Pool.Name)
+		if err != nil {
+			return nil, err
+		}
+		return c.service.Projects.Locations.Builds.Create(parent, build).ProjectId(project).Context(ctx).Do()
+	}
 	return c.service.Projects.Builds.Create(project, build).Context(ctx).Do()
 }
 
+// PoolLocation returns the location resource name of the provided worker pool.
+func PoolLocation(pool string) (string, error) {
+	parts := strings.Split(pool, "/")
+	if len(parts) != 6 || parts[0] != "projects" || parts[2] != "locations" || parts[4] != "workerPools" {
+		return "", errors.Errorf("malformed worker pool name: %s", pool)
+	}
+	return strings.Join(parts[:4], "/"), nil
+}
+
 // WaitForOperation polls and waits for the operation to complete.
 func (c *clientImpl) WaitForOperation(ctx context.Context, op *cloudbuild.Operation) (*cloudbuild.Operation, error) {
 	for !op.Done {
@@ -70,16 +93,36 @@ func (c *clientImpl) WaitForOperation(ctx context.Context, op *cloudbuild.Operat
 	return op, nil
 }
 
+// CancelOperation requests cancellation of the operation's build.
+func (c *clientImpl) CancelOperation(ctx context.Context, op *cloudbuild.Operation) error {
+	_, err := c.service.Operations.Cancel(op.Name, &cloudbuild.CancelOperationRequest{}).Context(ctx).Do()
+	return err
+}
+
+// cancelTimeout bounds the cancellation of a build whose caller has gone away.
+const cancelTimeout = 30 * time.Second
+
 // DoBuild executes a build on Cloud Build, waits for completion and returns the Build.
+//
+// If ctx is done before the build completes, the build is cancelled.
 func DoBuild(ctx context.Context, client Client, project string, build *cloudbuild.Build) (*cloudbuild.Build, error) {
 	op, err := client.CreateBuild(ctx, project, build)
 	if err != nil {
 		return nil, err
 	}
-	op, err = client.WaitForOperation(ctx, op)
+	done, err := client.WaitForOperation(ctx, op)
 	if err != nil {
+		if ctx.Err() != nil {
+			// NOTE: The build would otherwise run to completion with no one to observe the result.
+			cctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cancelTimeout)
+			defer cancel()
+			if cerr := client.CancelOperation(cctx, op); cerr != nil {
+				log.Printf("Failed to cancel %s: %v", op.Name, cerr)
+			}
+		}
 		return nil, errors.Wrap(err, "fetching operation")
 	}
+	op = done
 	// NOTE: Build status check will handle failures with better error messages.
 	if op.Error != nil {
 		log.Printf("Cloud Build error: %v", status.Error(codes.Code(op.Error.Code), op.Error.Message))
@@ -109,3 +152,89 @@ func ToError(build *cloudbuild.Build) error {
 func MergedLogFile(buildID string) string {
 	return fmt.Sprintf("log-%s.txt", buildID)
 }
+
+// runTagPrefix identifies the build tag recording the run for which a build was created.
+const runTagPrefix = "run-"
+
+var disallowedTagChars = regexp.MustCompile(`[^\w.-]`)
+
+// RunTag returns the build tag recording the provided run ID.
+//
+// NOTE: Build tags are restricted to [\w.-] so other characters in the run ID
+// (e.g. the colons of a timestamp) are replaced with underscores.
+func RunTag(runID string) string {
+	return runTagPrefix + disallowedTagChars.ReplaceAllString(runID, "_")
+}
+
+// IsActive returns whether the build has yet to complete.
+func IsActive(build *cloudbuild.Build) bool {
+	switch build.Status {
+	case "PENDING", "QUEUED", "WORKING":
+		return true
+	default:
+		return false
+	}
+}
+
+// targetTagPrefix identifies the build tag recording the target for which a build was created.
+const targetTagPrefix = "target-"
+
+// TargetTag returns the build tag recording the provided target.
+//
+// NOTE: Package names may contain characters disallowed in build tags so the
+// target is recorded as a truncated digest of its components.
+func TargetTag(ecosystem, pkg, version, artifact string) string {
+	h := sha256.Sum256([]byte(strings.Join([]string{ecosystem, pkg, version, artifact}, "!")))
+	return targetTagPrefix + hex.EncodeToString(h[:8])
+}
+
+func tagWithPrefix(b *cloudbuild.Build, prefix string) string {
+	for _, tag := range b.Tags {
+		if strings.HasPrefix(tag, prefix) {
+			return tag
+		}
+	}
+	return ""
+}
+
+// Orphaned returns the active builds created for a run whose requests are no longer awaiting them.
+//
+// recorded maps the TargetTag of each target to the time its latest attempt
+// was recorded for the run. Since a request records its attempt only once it
+// has stopped waiting on its build, a build created before that time is
+// orphaned. A build superseded by a later build for the same target in the
+// same run is likewise orphaned, as its request was retried.
+func Orphaned(builds []*cloudbuild.Build, runID string, recorded map[string]time.Time) ([]*cloudbuild.Build, error) {
+	runTag := RunTag(runID)
+	created := make(map[string]time.Time)
+	latest := make(map[string]time.Time)
+	for _, b := range builds {
+		if !slices.Contains(b.Tags, runTag) {
+			continue
+		}
+		target := tagWithPrefix(b, targetTagPrefix)
+		if target == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, b.CreateTime)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing create time of build %s", b.Id)
+		}
+		created[b.Id] = t
+		if t.After(latest[target]) {
+			latest[target] = t
+		}
+	}
+	var orphaned []*cloudbuild.Build
+	for _, b := range builds {
+		t, ok := created[b.Id]
+		if !ok || !IsActive(b) {
+			continue
+		}
+		target := tagWithPrefix(b, targetTagPrefix)
+		if at, ok := recorded[target]; (ok && at.After(t)) || latest[target].After(t) {
+			orphaned = append(orphaned, b)
+		}
+	}
+	return orphaned, nil
+}
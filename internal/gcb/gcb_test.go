@@ -0,0 +1,158 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcb
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/cloudbuild/v1"
+)
+
+func TestRunTag(t *testing.T) {
+	for _, tc := range []struct {
+		runID string
+		want  string
+	}{
+		{"2024-01-02T03:04:05Z", "run-2024-01-02T03_04_05Z"},
+		{"run_1.a", "run-run_1.a"},
+	} {
+		if got := RunTag(tc.runID); got != tc.want {
+			t.Errorf("RunTag(%q) = %q, want %q", tc.runID, got, tc.want)
+		}
+	}
+}
+
+func TestTargetTag(t *testing.T) {
+	tag := TargetTag("npm", "@scope/pkg", "1.0.0", "pkg-1.0.0.tgz")
+	if !regexp.MustCompile(`^target-[0-9a-f]{16}$`).MatchString(tag) {
+		t.Errorf("TargetTag() = %q, want target- followed by 16 hex characters", tag)
+	}
+	if other := TargetTag("npm", "@scope/pkg", "1.0.1", "pkg-1.0.1.tgz"); other == tag {
+		t.Errorf("TargetTag() = %q for distinct targets", tag)
+	}
+}
+
+func TestOrphaned(t *testing.T) {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) string { return base.Add(d).Format(time.RFC3339) }
+	run := RunTag("2024-01-01T10:00:00Z")
+	otherRun := RunTag("2024-01-01T11:00:00Z")
+	foo := TargetTag("npm", "foo", "1.0.0", "foo-1.0.0.tgz")
+	bar := TargetTag("npm", "bar", "1.0.0", "bar-1.0.0.tgz")
+	baz := TargetTag("npm", "baz", "1.0.0", "baz-1.0.0.tgz")
+	for _, tc := range []struct {
+		name     string
+		builds   []*cloudbuild.Build
+		recorded map[string]time.Time
+		want     []string
+	}{
+		{
+			name: "attempt recorded after build",
+			builds: []*cloudbuild.Build{
+				{Id: "orphaned", Status: "WORKING", CreateTime: at(0), Tags: []string{"traceparent-00", run, foo}},
+				{Id: "awaited", Status: "QUEUED", CreateTime: at(0), Tags: []string{run, bar}},
+			},
+			recorded: map[string]time.Time{foo: base.Add(time.Hour)},
+			want:     []string{"orphaned"},
+		},
+		{
+			name: "build created after attempt",
+			builds: []*cloudbuild.Build{
+				{Id: "retried", Status: "WORKING", CreateTime: at(time.Hour), Tags: []string{run, foo}},
+			},
+			recorded: map[string]time.Time{foo: base},
+		},
+		{
+			name: "superseded by retry",
+			builds: []*cloudbuild.Build{
+				{Id: "first", Status: "WORKING", CreateTime: at(0), Tags: []string{run, foo}},
+				{Id: "second", Status: "WORKING", CreateTime: at(time.Minute), Tags: []string{run, foo}},
+			},
+			want: []string{"first"},
+		},
+		{
+			name: "superseded by completed retry",
+			builds: []*cloudbuild.Build{
+				{Id: "first", Status: "PENDING", CreateTime: at(0), Tags: []string{run, foo}},
+				{Id: "second", Status: "SUCCESS", CreateTime: at(time.Minute), Tags: []string{run, foo}},
+			},
+			want: []string{"first"},
+		},
+		{
+			name: "ignores inactive, untagged, and other runs",
+			builds: []*cloudbuild.Build{
+				{Id: "done", Status: "SUCCESS", CreateTime: at(0), Tags: []string{run, foo}},
+				{Id: "untargeted", Status: "WORKING", CreateTime: at(0), Tags: []string{run}},
+				{Id: "untagged", Status: "WORKING", CreateTime: at(0)},
+				{Id: "other", Status: "WORKING", CreateTime: at(0), Tags: []string{otherRun, baz}},
+			},
+			recorded: map[string]time.Time{foo: base.Add(time.Hour), baz: base.Add(time.Hour)},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Orphaned(tc.builds, "2024-01-01T10:00:00Z", tc.recorded)
+			if err != nil {
+				t.Fatalf("Orphaned() error = %v", err)
+			}
+			var ids []string
+			for _, b := range got {
+				ids = append(ids, b.Id)
+			}
+			if diff := cmp.Diff(tc.want, ids); diff != "" {
+				t.Errorf("Orphaned() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+	if _, err := Orphaned([]*cloudbuild.Build{{Id: "bad", Status: "WORKING", Tags: []string{run, foo}}}, "2024-01-01T10:00:00Z", nil); err == nil {
+		t.Error("Orphaned() expected error for malformed create time")
+	}
+}
+
+type fakeClient struct {
+	cancelled []string
+}
+
+func (c *fakeClient) CreateBuild(ctx context.Context, project string, build *cloudbuild.Build) (*cloudbuild.Operation, error) {
+	return &cloudbuild.Operation{Name: "operations/build"}, nil
+}
+
+func (c *fakeClient) WaitForOperation(ctx context.Context, op *cloudbuild.Operation) (*cloudbuild.Operation, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (c *fakeClient) CancelOperation(ctx context.Context, op *cloudbuild.Operation) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	c.cancelled = append(c.cancelled, op.Name)
+	return nil
+}
+
+func TestDoBuildCancelled(t *testing.T) {
+	client := &fakeClient{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := DoBuild(ctx, client, "project", &cloudbuild.Build{}); err == nil {
+		t.Fatal("DoBuild() expected error")
+	}
+	if diff := cmp.Diff([]string{"operations/build"}, client.cancelled); diff != "" {
+		t.Errorf("cancelled operations mismatch (-want +got):\n%s", diff)
+	}
+}
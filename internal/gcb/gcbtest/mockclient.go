@@ -11,6 +11,7 @@ import (
 type MockClient struct {
 	CreateBuildFunc      func(ctx context.Context, project string, build *cloudbuild.Build) (*cloudbuild.Operation, error)
 	WaitForOperationFunc func(ctx context.Context, op *cloudbuild.Operation) (*cloudbuild.Operation, error)
+	CancelOperationFunc  func(ctx context.Context, op *cloudbuild.Operation) error
 }
 
 var _ gcb.Client = &MockClient{}
@@ -22,3 +23,7 @@ func (mc *MockClient) CreateBuild(ctx context.Context, project string, build *cl
 func (mc *MockClient) WaitForOperation(ctx context.Context, op *cloudbuild.Operation) (*cloudbuild.Operation, error) {
 	return mc.WaitForOperationFunc(ctx, op)
 }
+
+func (mc *MockClient) CancelOperation(ctx context.Context, op *cloudbuild.Operation) error {
+	return mc.CancelOperationFunc(ctx, op)
+}
@@ -0,0 +1,115 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/oss-rebuild/internal/gcb"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/pkg/errors"
+	"google.golang.org/api/cloudbuild/v1"
+)
+
+// LocalGCB is a Cloud Build client that executes the rebuild on the host.
+//
+// Rather than running the submitted container build, the strategy for Input
+// is executed directly in a temporary directory and the resulting artifact is
+// written to Store as the remote build would have uploaded it.
+type LocalGCB struct {
+	Input rebuild.Input
+	Store rebuild.LocatableAssetStore
+	// Dir is the directory beneath which builds are executed.
+	Dir string
+
+	build *cloudbuild.Build
+}
+
+var _ gcb.Client = &LocalGCB{}
+
+// CreateBuild executes the build to completion.
+func (c *LocalGCB) CreateBuild(ctx context.Context, project string, build *cloudbuild.Build) (*cloudbuild.Operation, error) {
+	b := *build
+	b.Id = "local-build"
+	b.Status = "SUCCESS"
+	if err := c.execute(ctx); err != nil {
+		b.Status = "FAILURE"
+		b.StatusDetail = err.Error()
+	}
+	b.FinishTime = time.Now().UTC().Format(time.RFC3339)
+	b.Results = &cloudbuild.Results{}
+	for range b.Steps {
+		b.Results.BuildStepImages = append(b.Results.BuildStepImages, "sha256:local")
+	}
+	c.build = &b
+	return c.operation(false)
+}
+
+// WaitForOperation returns the completed build.
+func (c *LocalGCB) WaitForOperation(ctx context.Context, op *cloudbuild.Operation) (*cloudbuild.Operation, error) {
+	if c.build == nil {
+		return nil, errors.New("no build created")
+	}
+	return c.operation(true)
+}
+
+// CancelOperation is a no-op as builds complete within CreateBuild.
+func (c *LocalGCB) CancelOperation(ctx context.Context, op *cloudbuild.Operation) error {
+	return nil
+}
+
+func (c *LocalGCB) operation(done bool) (*cloudbuild.Operation, error) {
+	md, err := json.Marshal(cloudbuild.BuildOperationMetadata{Build: c.build})
+	if err != nil {
+		return nil, err
+	}
+	return &cloudbuild.Operation{Name: "operations/" + c.build.Id, Done: done, Metadata: md}, nil
+}
+
+func (c *LocalGCB) execute(ctx context.Context) error {
+	inst, err := c.Input.Strategy.GenerateFor(c.Input.Target, rebuild.BuildEnv{})
+	if err != nil {
+		return errors.Wrap(err, "generating instructions")
+	}
+	dir, err := os.MkdirTemp(c.Dir, "build")
+	if err != nil {
+		return errors.Wrap(err, "creating build dir")
+	}
+	defer os.RemoveAll(dir)
+	for _, script := range []string{inst.Source, inst.Deps, inst.Build} {
+		if output, err := rebuild.ExecuteScript(ctx, dir, script); err != nil {
+			return errors.Wrapf(err, "executing script:\n%s", output)
+		}
+	}
+	f, err := os.Open(filepath.Join(dir, inst.OutputPath))
+	if err != nil {
+		return errors.Wrap(err, "opening artifact")
+	}
+	defer f.Close()
+	w, err := c.Store.Writer(ctx, rebuild.RebuildAsset.For(c.Input.Target))
+	if err != nil {
+		return errors.Wrap(err, "creating artifact writer")
+	}
+	if _, err := io.Copy(w, f); err != nil {
+		w.Close()
+		return errors.Wrap(err, "uploading artifact")
+	}
+	return w.Close()
+}
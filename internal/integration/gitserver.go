@@ -0,0 +1,94 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package integration
+
+import (
+	"net/http/cgi"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/pkg/errors"
+)
+
+// GitServer serves local repositories over the git smart HTTP protocol.
+type GitServer struct {
+	root string
+	srv  *httptest.Server
+}
+
+// NewGitServer starts a server for repositories created beneath root.
+// It requires the git binary which provides the http-backend CGI program.
+func NewGitServer(root string) (*GitServer, error) {
+	gitPath, err := exec.LookPath("git")
+	if err != nil {
+		return nil, errors.Wrap(err, "locating git")
+	}
+	h := &cgi.Handler{
+		Path: gitPath,
+		Args: []string{"http-backend"},
+		Env: []string{
+			"GIT_PROJECT_ROOT=" + root,
+			"GIT_HTTP_EXPORT_ALL=1",
+		},
+	}
+	return &GitServer{root: root, srv: httptest.NewServer(h)}, nil
+}
+
+// URL returns the clone URL for the named repository.
+func (s *GitServer) URL(name string) string {
+	return s.srv.URL + "/" + name
+}
+
+// Close shuts down the server.
+func (s *GitServer) Close() {
+	s.srv.Close()
+}
+
+// CreateRepo creates the named repository with a single commit containing
+// files and returns the commit hash.
+func (s *GitServer) CreateRepo(name string, files map[string]string) (string, error) {
+	dir := filepath.Join(s.root, name)
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		return "", errors.Wrap(err, "initializing repo")
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return "", errors.Wrap(err, "opening worktree")
+	}
+	for name, content := range files {
+		pth := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(pth), 0755); err != nil {
+			return "", errors.Wrap(err, "creating dir")
+		}
+		if err := os.WriteFile(pth, []byte(content), 0644); err != nil {
+			return "", errors.Wrap(err, "writing file")
+		}
+		if _, err := wt.Add(name); err != nil {
+			return "", errors.Wrap(err, "staging file")
+		}
+	}
+	sig := &object.Signature{Name: "Fixture", Email: "fixture@example.com", When: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	h, err := wt.Commit("Initial commit", &git.CommitOptions{Author: sig, Committer: sig})
+	if err != nil {
+		return "", errors.Wrap(err, "committing")
+	}
+	return h.String(), nil
+}
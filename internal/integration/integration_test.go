@@ -0,0 +1,376 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package integration
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/internal/api/apiservice"
+	"github.com/google/oss-rebuild/internal/api/rebuilderservice"
+	"github.com/google/oss-rebuild/internal/verifier"
+	"github.com/google/oss-rebuild/pkg/archive"
+	"github.com/google/oss-rebuild/pkg/archive/archivetest"
+	"github.com/google/oss-rebuild/pkg/attestation"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+	slsa1 "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v1"
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+)
+
+type env struct {
+	Registry *Registry
+	Git      *GitServer
+	Dir      string
+}
+
+func setup(t *testing.T, tools ...string) *env {
+	t.Helper()
+	for _, tool := range append([]string{"git"}, tools...) {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not available: %v", tool, err)
+		}
+	}
+	dir := t.TempDir()
+	repos := dir + "/repos"
+	must1(os.Mkdir(repos, 0755))
+	git := must(NewGitServer(repos))
+	t.Cleanup(git.Close)
+	// NOTE: The local rebuilder operates relative to the working directory.
+	wd := must(os.Getwd())
+	must1(os.Chdir(dir))
+	t.Cleanup(func() { os.Chdir(wd) })
+	return &env{Registry: &Registry{}, Git: git, Dir: dir}
+}
+
+func tgz(files map[string]string) []byte {
+	var entries []archive.TarEntry
+	for _, name := range sortedKeys(files) {
+		entries = append(entries, archive.TarEntry{Header: &tar.Header{Name: name, Mode: 0644, Typeflag: tar.TypeReg}, Body: []byte(files[name])})
+	}
+	return must(archivetest.TgzFile(entries)).Bytes()
+}
+
+func wheel(files map[string]string) []byte {
+	var entries []archive.ZipEntry
+	for _, name := range sortedKeys(files) {
+		entries = append(entries, archive.ZipEntry{FileHeader: &zip.FileHeader{Name: name, Modified: time.UnixMilli(0)}, Body: []byte(files[name])})
+	}
+	return must(archivetest.ZipFile(entries)).Bytes()
+}
+
+func sortedKeys(m map[string]string) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+var (
+	npmSource = map[string]string{
+		"package.json": `{"name": "left-pad", "main": "index.js"}` + "\n",
+		"index.js":     "module.exports = (s, n) => s.padStart(n);\n",
+	}
+	npmBuild = `mkdir -p out/package && cp package.json index.js out/package/ && tar -C out -czf left-pad.tgz package/package.json package/index.js`
+
+	pypiSource = map[string]string{
+		"pad/__init__.py":              "def pad(s, n):\n    return s.rjust(n)\n",
+		"pad-1.0.0.dist-info/METADATA": "Metadata-Version: 2.1\nName: pad\nVersion: 1.0.0\n",
+	}
+	pypiBuild = `mkdir -p dist && python3 -c "import zipfile; z = zipfile.ZipFile('dist/pad-1.0.0-py3-none-any.whl', 'w'); z.write('pad/__init__.py'); z.write('pad-1.0.0.dist-info/METADATA'); z.close()"`
+
+	crateSource = map[string]string{
+		"Cargo.toml": "[package]\nname = \"pad\"\nversion = \"1.0.0\"\n",
+		"src/lib.rs": "pub fn pad(s: &str, n: usize) -> String { format!(\"{s:>n$}\") }\n",
+	}
+	crateBuild = `mkdir -p out/pad-1.0.0/src && cp Cargo.toml out/pad-1.0.0/Cargo.toml && cp Cargo.toml out/pad-1.0.0/Cargo.toml.orig && cp src/lib.rs out/pad-1.0.0/src/ && tar -C out -czf pad.crate pad-1.0.0/Cargo.toml pad-1.0.0/Cargo.toml.orig pad-1.0.0/src/lib.rs`
+)
+
+func TestSmoketest(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		ecosystem rebuild.Ecosystem
+		tools     []string
+		source    map[string]string
+		build     string
+		output    string
+		upstream  Package
+		want      string
+	}{
+		{
+			name:      "npm",
+			ecosystem: rebuild.NPM,
+			tools:     []string{"tar"},
+			source:    npmSource,
+			build:     npmBuild,
+			output:    "left-pad.tgz",
+			upstream: Package{Name: "left-pad", Version: "1.0.0", Artifact: tgz(map[string]string{
+				"package/package.json": npmSource["package.json"],
+				"package/index.js":     npmSource["index.js"],
+			})},
+		},
+		{
+			name:      "npm upstream dist",
+			ecosystem: rebuild.NPM,
+			tools:     []string{"tar"},
+			source:    npmSource,
+			build:     npmBuild,
+			output:    "left-pad.tgz",
+			upstream: Package{Name: "left-pad", Version: "1.0.1", Artifact: tgz(map[string]string{
+				"package/package.json":      npmSource["package.json"],
+				"package/index.js":          npmSource["index.js"],
+				"package/dist/index.min.js": "module.exports=(s,n)=>s.padStart(n);",
+			})},
+			want: "dist/ file(s) found in upstream but not rebuild",
+		},
+		{
+			name:      "pypi",
+			ecosystem: rebuild.PyPI,
+			tools:     []string{"python3"},
+			source:    pypiSource,
+			build:     pypiBuild,
+			output:    "dist/pad-1.0.0-py3-none-any.whl",
+			upstream:  Package{Name: "pad", Version: "1.0.0", Filename: "pad-1.0.0-py3-none-any.whl", Artifact: wheel(pypiSource)},
+		},
+		{
+			name:      "crates",
+			ecosystem: rebuild.CratesIO,
+			tools:     []string{"tar"},
+			source:    crateSource,
+			build:     crateBuild,
+			output:    "pad.crate",
+			upstream: Package{Name: "pad", Version: "1.0.0", Artifact: tgz(map[string]string{
+				"pad-1.0.0/Cargo.toml":      crateSource["Cargo.toml"],
+				"pad-1.0.0/Cargo.toml.orig": crateSource["Cargo.toml"],
+				"pad-1.0.0/src/lib.rs":      crateSource["src/lib.rs"],
+			})},
+		},
+		{
+			name:      "crates content diff",
+			ecosystem: rebuild.CratesIO,
+			tools:     []string{"tar"},
+			source:    crateSource,
+			build:     crateBuild,
+			output:    "pad.crate",
+			upstream: Package{Name: "pad", Version: "1.0.0", Artifact: tgz(map[string]string{
+				"pad-1.0.0/Cargo.toml":      crateSource["Cargo.toml"],
+				"pad-1.0.0/Cargo.toml.orig": crateSource["Cargo.toml"],
+				"pad-1.0.0/src/lib.rs":      "pub fn pad() {}\n",
+			})},
+			want: "content differences found",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			e := setup(t, tc.tools...)
+			pkg := tc.upstream.Name
+			ref := must(e.Git.CreateRepo(pkg, tc.source))
+			tc.upstream.Ecosystem, tc.upstream.Repo = tc.ecosystem, e.Git.URL(pkg)
+			e.Registry.Add(tc.upstream)
+			strategy := schema.NewStrategyOneOf(&rebuild.ManualStrategy{
+				Location:   rebuild.Location{Repo: e.Git.URL(pkg), Ref: ref, Dir: "."},
+				Build:      tc.build,
+				OutputPath: tc.output,
+			})
+			resp, err := rebuilderservice.RebuildSmoketest(context.Background(), schema.SmoketestRequest{
+				Ecosystem: tc.ecosystem,
+				Package:   pkg,
+				Versions:  []string{tc.upstream.Version},
+				ID:        "integration",
+				Strategy:  &strategy,
+			}, &rebuilderservice.RebuildSmoketestDeps{
+				HTTPClient: e.Registry.Client(),
+				AssetDir:   "assets",
+			})
+			if err != nil {
+				t.Fatalf("RebuildSmoketest() error: %v", err)
+			}
+			if len(resp.Verdicts) != 1 {
+				t.Fatalf("RebuildSmoketest() verdicts = %d, want 1", len(resp.Verdicts))
+			}
+			if got := resp.Verdicts[0].Message; got != tc.want {
+				t.Errorf("RebuildSmoketest() verdict = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+// fakeKey signs payloads with their digest so envelopes can be verified without key material.
+type fakeKey struct{}
+
+func (fakeKey) Sign(_ context.Context, data []byte) ([]byte, error) {
+	h := sha256.Sum256(data)
+	return h[:], nil
+}
+
+func (fakeKey) Verify(_ context.Context, data, sig []byte) error {
+	if h := sha256.Sum256(data); !bytes.Equal(h[:], sig) {
+		return os.ErrInvalid
+	}
+	return nil
+}
+
+func (fakeKey) KeyID() (string, error)   { return "fake", nil }
+func (fakeKey) Public() crypto.PublicKey { return nil }
+
+// fakeAttempts records attempts in memory, keyed by run ID.
+type fakeAttempts map[string]schema.RebuildAttempt
+
+func (f fakeAttempts) WriteAttempt(_ context.Context, id string, a schema.RebuildAttempt) error {
+	f[id] = a
+	return nil
+}
+
+func TestRebuildPackageAttestations(t *testing.T) {
+	e := setup(t, "tar")
+	ctx := context.Background()
+	attempts := fakeAttempts{}
+	ref := must(e.Git.CreateRepo("left-pad", npmSource))
+	upstream := tgz(map[string]string{
+		"package/package.json": npmSource["package.json"],
+		"package/index.js":     npmSource["index.js"],
+	})
+	e.Registry.Add(Package{Ecosystem: rebuild.NPM, Name: "left-pad", Version: "1.0.0", Repo: e.Git.URL("left-pad"), Artifact: upstream})
+	target := rebuild.Target{Ecosystem: rebuild.NPM, Package: "left-pad", Version: "1.0.0", Artifact: "left-pad-1.0.0.tgz"}
+	strategy := &rebuild.ManualStrategy{
+		Location:   rebuild.Location{Repo: e.Git.URL("left-pad"), Ref: ref, Dir: "."},
+		Build:      npmBuild,
+		OutputPath: "left-pad.tgz",
+	}
+	fs := memfs.New()
+	remoteMetadata := rebuild.NewFilesystemAssetStore(must(fs.Chroot("remote-metadata")))
+	deps := &apiservice.RebuildPackageDeps{
+		AttemptWriter:       attempts,
+		HTTPClient:          e.Registry.Client(),
+		Signer:              must(dsse.NewEnvelopeSigner(fakeKey{})),
+		GCBClient:           &LocalGCB{Input: rebuild.Input{Target: target, Strategy: strategy}, Store: remoteMetadata, Dir: e.Dir},
+		BuildProject:        "integration-project",
+		BuildServiceAccount: "integration-role",
+		UtilPrebuildBucket:  "integration-prebuild-bucket",
+		BuildLogsBucket:     "integration-logs-bucket",
+		AttestationStore:    rebuild.NewFilesystemAssetStore(must(fs.Chroot("attestations"))),
+		LocalMetadataStore:  rebuild.NewFilesystemAssetStore(must(fs.Chroot("local-metadata"))),
+		DebugStoreBuilder: func(ctx context.Context) (rebuild.AssetStore, error) {
+			return rebuild.NewFilesystemAssetStore(must(fs.Chroot("debug-metadata"))), nil
+		},
+		RemoteMetadataStoreBuilder: func(ctx context.Context, id string) (rebuild.LocatableAssetStore, error) {
+			return remoteMetadata, nil
+		},
+		InferStub: func(context.Context, schema.InferenceRequest) (*schema.StrategyOneOf, error) {
+			oneof := schema.NewStrategyOneOf(strategy)
+			return &oneof, nil
+		},
+	}
+	v, err := apiservice.RebuildPackage(ctx, schema.RebuildPackageRequest{
+		Ecosystem: target.Ecosystem,
+		Package:   target.Package,
+		Version:   target.Version,
+		Artifact:  target.Artifact,
+		ID:        "integration",
+	}, deps)
+	if err != nil {
+		t.Fatalf("RebuildPackage() error: %v", err)
+	}
+	if v.Message != "" {
+		t.Fatalf("RebuildPackage() verdict: %s", v.Message)
+	}
+	attempt := attempts["integration"]
+	if !attempt.Success || attempt.RunID != "integration" || attempt.Artifact != target.Artifact || attempt.Dockerfile == "" {
+		t.Errorf("recorded attempt = %+v, want successful attempt for run integration with dockerfile", attempt)
+	}
+	r := must(deps.AttestationStore.Reader(ctx, rebuild.AttestationBundleAsset.For(target)))
+	bundle, err := attestation.NewBundle(ctx, must(io.ReadAll(r)), must(dsse.NewEnvelopeVerifier(fakeKey{})))
+	if err != nil {
+		t.Fatalf("NewBundle() error: %v", err)
+	}
+	var buildTypes []string
+	upstreamDigest := sha256.Sum256(upstream)
+	for _, stmt := range bundle.Payloads() {
+		bt := stmt.Predicate.BuildDefinition.BuildType
+		buildTypes = append(buildTypes, bt)
+		if bt == verifier.RebuildBuildType {
+			continue
+		}
+		// Equivalence claims are made about the upstream artifact.
+		if len(stmt.Subject) != 1 || stmt.Subject[0].Name != target.Artifact {
+			t.Errorf("%s subject = %v, want %s", bt, stmt.Subject, target.Artifact)
+		} else if got := stmt.Subject[0].Digest["sha256"]; got != hex.EncodeToString(upstreamDigest[:]) {
+			t.Errorf("%s subject digest = %s, want upstream digest", bt, got)
+		}
+	}
+	want := []string{verifier.ArtifactEquivalenceBuildType, verifier.RebuildBuildType, verifier.StabilizationBuildType}
+	if diff := cmp.Diff(want, buildTypes); diff != "" {
+		t.Errorf("attestation build types mismatch (-want +got):\n%s", diff)
+	}
+	rbAtt := must(bundle.RebuildAttestation())
+	if !slices.ContainsFunc(rbAtt.Predicate.BuildDefinition.ResolvedDependencies, func(rd slsa1.ResourceDescriptor) bool {
+		return rd.Name == "git+"+e.Git.URL("left-pad")
+	}) {
+		t.Errorf("rebuild attestation missing source dependency: %v", rbAtt.Predicate.BuildDefinition.ResolvedDependencies)
+	}
+	for _, stmt := range bundle.Payloads() {
+		if stmt.Predicate.BuildDefinition.BuildType != verifier.StabilizationBuildType {
+			continue
+		}
+		var record verifier.StabilizationRecord
+		must1(json.Unmarshal(stmt.Predicate.RunDetails.Byproducts[0].Content, &record))
+		// The rebuild is produced with host timestamps and ownership which must be stabilized.
+		if _, ok := record.Rebuild["package/index.js"]; !ok {
+			t.Errorf("stabilizations missing rebuilt package/index.js: %v", record.Rebuild)
+		}
+	}
+	var env dsse.Envelope
+	must1(json.NewDecoder(must(deps.AttestationStore.Reader(ctx, rebuild.VEXAsset.For(target)))).Decode(&env))
+	if _, err := must(dsse.NewEnvelopeVerifier(fakeKey{})).Verify(ctx, &env); err != nil {
+		t.Fatalf("verifying VEX statement: %v", err)
+	}
+	var vex struct {
+		Predicate verifier.VEXDocument `json:"predicate"`
+	}
+	must1(json.Unmarshal(must(base64.StdEncoding.DecodeString(env.Payload)), &vex))
+	if len(vex.Predicate.Statements) != 1 {
+		t.Fatalf("VEX statements = %v, want 1", vex.Predicate.Statements)
+	}
+	if got := vex.Predicate.Statements[0]; got.Status != verifier.VEXNotAffected || got.Products[0].ID != "pkg:npm/left-pad@1.0.0" {
+		t.Errorf("VEX statement = %+v, want not_affected for pkg:npm/left-pad@1.0.0", got)
+	}
+}
+
+func must1(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
+
+func must[T any](t T, err error) T {
+	must1(err)
+	return t
+}
@@ -0,0 +1,269 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package integration provides hermetic fakes for exercising full rebuilds.
+package integration
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/oss-rebuild/internal/httpx"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+)
+
+// Package is a fixture package version served by a Registry.
+type Package struct {
+	Ecosystem rebuild.Ecosystem
+	Name      string
+	Version   string
+	// Repo is the source repository advertised in the package metadata.
+	Repo string
+	// Filename is the name of the artifact. It is required only for PyPI.
+	Filename string
+	// Artifact is the content of the published artifact.
+	Artifact []byte
+	// Published is the upload time reported for the version.
+	Published time.Time
+}
+
+// Registry serves fixture packages using the npm, PyPI, and crates.io APIs.
+type Registry struct {
+	mu       sync.Mutex
+	packages []Package
+}
+
+var _ http.Handler = &Registry{}
+
+// Add registers a package version to be served.
+func (r *Registry) Add(p Package) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if p.Published.IsZero() {
+		p.Published = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(len(r.packages)) * time.Hour)
+	}
+	r.packages = append(r.packages, p)
+}
+
+// Client returns a client that serves all requests from the Registry in-process.
+// Requests are routed by host so registry clients may use their canonical URLs.
+func (r *Registry) Client() httpx.BasicClient {
+	return handlerClient{r}
+}
+
+type handlerClient struct {
+	h http.Handler
+}
+
+func (c handlerClient) Do(req *http.Request) (*http.Response, error) {
+	rec := httptest.NewRecorder()
+	c.h.ServeHTTP(rec, req)
+	resp := rec.Result()
+	resp.Request = req
+	return resp, nil
+}
+
+func (r *Registry) versions(eco rebuild.Ecosystem, name string) []Package {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var ps []Package
+	for _, p := range r.packages {
+		if p.Ecosystem == eco && p.Name == name {
+			ps = append(ps, p)
+		}
+	}
+	return ps
+}
+
+func (r *Registry) find(eco rebuild.Ecosystem, match func(Package) bool) (Package, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, p := range r.packages {
+		if p.Ecosystem == eco && match(p) {
+			return p, true
+		}
+	}
+	return Package{}, false
+}
+
+// ServeHTTP dispatches the request to the fake for the requested registry host.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var resp any
+	var ok bool
+	switch req.URL.Host {
+	case "registry.npmjs.org":
+		resp, ok = r.serveNPM(req.URL.Path)
+	case "pypi.org", "files.pythonhosted.org":
+		resp, ok = r.servePyPI(req.URL.Host, req.URL.Path)
+	case "crates.io":
+		resp, ok = r.serveCratesIO(req.URL.Path)
+	}
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	if b, isArtifact := resp.([]byte); isArtifact {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(b)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func npmTarballURL(p Package) string {
+	// NOTE: Scoped packages omit the scope from the tarball filename.
+	return fmt.Sprintf("https://registry.npmjs.org/%s/-/%s-%s.tgz", p.Name, path.Base(p.Name), p.Version)
+}
+
+func npmVersion(p Package) map[string]any {
+	return map[string]any{
+		"name":       p.Name,
+		"version":    p.Version,
+		"dist":       map[string]any{"tarball": npmTarballURL(p)},
+		"repository": map[string]any{"type": "git", "url": p.Repo},
+	}
+}
+
+func (r *Registry) serveNPM(pth string) (any, bool) {
+	if p, ok := r.find(rebuild.NPM, func(p Package) bool { return pth == strings.TrimPrefix(npmTarballURL(p), "https://registry.npmjs.org") }); ok {
+		return p.Artifact, true
+	}
+	if ps := r.versions(rebuild.NPM, strings.TrimPrefix(pth, "/")); len(ps) > 0 {
+		versions := make(map[string]any)
+		times := make(map[string]time.Time)
+		for _, p := range ps {
+			versions[p.Version] = npmVersion(p)
+			times[p.Version] = p.Published
+		}
+		return map[string]any{
+			"name":      ps[0].Name,
+			"dist-tags": map[string]string{"latest": ps[len(ps)-1].Version},
+			"versions":  versions,
+			"time":      times,
+		}, true
+	}
+	if p, ok := r.find(rebuild.NPM, func(p Package) bool { return pth == path.Join("/", p.Name, p.Version) }); ok {
+		return npmVersion(p), true
+	}
+	return nil, false
+}
+
+func pypiArtifactURL(p Package) string {
+	return "https://files.pythonhosted.org/packages/" + p.Filename
+}
+
+func pypiArtifact(p Package) map[string]any {
+	return map[string]any{
+		"filename":             p.Filename,
+		"url":                  pypiArtifactURL(p),
+		"size":                 len(p.Artifact),
+		"packagetype":          "bdist_wheel",
+		"upload_time_iso_8601": p.Published,
+	}
+}
+
+func pypiInfo(p Package) map[string]any {
+	return map[string]any{
+		"name":         p.Name,
+		"version":      p.Version,
+		"project_urls": map[string]string{"Source": p.Repo},
+	}
+}
+
+func (r *Registry) servePyPI(host, pth string) (any, bool) {
+	if host == "files.pythonhosted.org" {
+		p, ok := r.find(rebuild.PyPI, func(p Package) bool { return pth == "/packages/"+p.Filename })
+		return p.Artifact, ok
+	}
+	parts := strings.Split(strings.Trim(pth, "/"), "/")
+	if len(parts) < 3 || parts[0] != "pypi" || parts[len(parts)-1] != "json" {
+		return nil, false
+	}
+	switch len(parts) {
+	case 3:
+		ps := r.versions(rebuild.PyPI, parts[1])
+		if len(ps) == 0 {
+			return nil, false
+		}
+		releases := make(map[string][]any)
+		for _, p := range ps {
+			releases[p.Version] = append(releases[p.Version], pypiArtifact(p))
+		}
+		return map[string]any{"info": pypiInfo(ps[len(ps)-1]), "releases": releases}, true
+	case 4:
+		var artifacts []any
+		var info map[string]any
+		for _, p := range r.versions(rebuild.PyPI, parts[1]) {
+			if p.Version == parts[2] {
+				artifacts = append(artifacts, pypiArtifact(p))
+				info = pypiInfo(p)
+			}
+		}
+		return map[string]any{"info": info, "urls": artifacts}, len(artifacts) > 0
+	}
+	return nil, false
+}
+
+func cratesVersion(p Package) map[string]any {
+	return map[string]any{
+		"num":        p.Version,
+		"dl_path":    path.Join("/api/v1/crates", p.Name, p.Version, "download"),
+		"created_at": p.Published,
+		"updated_at": p.Published,
+	}
+}
+
+func (r *Registry) serveCratesIO(pth string) (any, bool) {
+	parts := strings.Split(strings.TrimPrefix(pth, "/api/v1/crates/"), "/")
+	if !strings.HasPrefix(pth, "/api/v1/crates/") || len(parts) > 3 {
+		return nil, false
+	}
+	ps := r.versions(rebuild.CratesIO, parts[0])
+	if len(ps) == 0 {
+		return nil, false
+	}
+	if len(parts) == 1 {
+		var versions []any
+		for _, p := range ps {
+			versions = append(versions, cratesVersion(p))
+		}
+		last := ps[len(ps)-1]
+		return map[string]any{
+			"crate": map[string]any{
+				"id":         last.Name,
+				"repository": last.Repo,
+				"created_at": ps[0].Published,
+				"updated_at": last.Published,
+			},
+			"versions": versions,
+		}, true
+	}
+	for _, p := range ps {
+		if p.Version != parts[1] {
+			continue
+		}
+		if len(parts) == 3 {
+			return p.Artifact, parts[2] == "download"
+		}
+		return map[string]any{"version": cratesVersion(p)}, true
+	}
+	return nil, false
+}
@@ -3,8 +3,11 @@ package llm
 import (
 	"context"
 	"encoding/json"
+	"regexp"
+	"strings"
 
 	"cloud.google.com/go/vertexai/genai"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
 	"github.com/pkg/errors"
 )
 
@@ -33,18 +36,119 @@ func WithSystemPrompt(model genai.GenerativeModel, prompt ...genai.Part) *genai.
 	return &model
 }
 
+// Phases of a build in which a Command may be executed.
+const (
+	DepsPhase  = "deps"
+	BuildPhase = "build"
+)
+
+var CommandSchema = &genai.Schema{
+	Type: genai.TypeObject,
+	Properties: map[string]*genai.Schema{
+		"program": {Type: genai.TypeString, Description: "The executable to run e.g. npm"},
+		"args":    {Type: genai.TypeArray, Items: &genai.Schema{Type: genai.TypeString}, Description: "The unquoted arguments to the program"},
+		"env":     {Type: genai.TypeArray, Items: &genai.Schema{Type: genai.TypeString}, Description: "NAME=value environment variable assignments"},
+		"cwd":     {Type: genai.TypeString, Description: "The directory, relative to the source root, in which to run the program"},
+		"phase":   {Type: genai.TypeString, Enum: []string{DepsPhase, BuildPhase}},
+	},
+	Required: []string{"program", "phase"},
+}
+
 var ScriptResponseSchema = &genai.Schema{
 	Type: genai.TypeObject,
 	Properties: map[string]*genai.Schema{
 		"reason":   {Type: genai.TypeString},
-		"commands": {Type: genai.TypeArray, Items: &genai.Schema{Type: genai.TypeString}},
+		"commands": {Type: genai.TypeArray, Items: CommandSchema},
 	},
 	Required: []string{"reason", "commands"},
 }
 
+// Command is a single program invocation within a script.
+type Command struct {
+	Program string
+	Args    []string
+	Env     []string
+	Cwd     string
+	Phase   string
+}
+
+var envAssignmentPat = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*=`)
+
+// Validate returns an error if the command cannot be rendered as a shell command.
+func (c Command) Validate() error {
+	if c.Program == "" {
+		return errors.New("missing program")
+	}
+	if c.Phase != DepsPhase && c.Phase != BuildPhase {
+		return errors.Errorf("unknown phase: %q", c.Phase)
+	}
+	for _, e := range c.Env {
+		if !envAssignmentPat.MatchString(e) {
+			return errors.Errorf("malformed env assignment: %q", e)
+		}
+	}
+	return nil
+}
+
+// String renders the command as a line of shell.
+//
+// NOTE: Commands with a working directory are run in a subshell so the
+// directory change does not apply to subsequent commands.
+func (c Command) String() string {
+	var parts []string
+	for _, e := range c.Env {
+		name, value, _ := strings.Cut(e, "=")
+		parts = append(parts, name+"="+quote(value))
+	}
+	parts = append(parts, quote(c.Program))
+	for _, a := range c.Args {
+		parts = append(parts, quote(a))
+	}
+	line := strings.Join(parts, " ")
+	if c.Cwd != "" {
+		return "(cd " + quote(c.Cwd) + " && " + line + ")"
+	}
+	return line
+}
+
+var shellSafePat = regexp.MustCompile(`^[\w@%+=:,./-]+$`)
+
+func quote(s string) string {
+	if shellSafePat.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
 type ScriptResponse struct {
 	Reason   string
-	Commands []string
+	Commands []Command
+}
+
+// Script returns the shell script comprising the commands of the provided phase.
+func (r ScriptResponse) Script(phase string) string {
+	var lines []string
+	for _, c := range r.Commands {
+		if c.Phase == phase {
+			lines = append(lines, c.String())
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Strategy returns a ManualStrategy executing the response's commands.
+func (r ScriptResponse) Strategy(loc rebuild.Location, outputPath string) (*rebuild.ManualStrategy, error) {
+	for i, c := range r.Commands {
+		if err := c.Validate(); err != nil {
+			return nil, errors.Wrapf(err, "validating command %d", i)
+		}
+	}
+	return &rebuild.ManualStrategy{
+		Location:   loc,
+		Deps:       r.Script(DepsPhase),
+		Build:      r.Script(BuildPhase),
+		OutputPath: outputPath,
+	}, nil
 }
 
 func GenerateTextContent(ctx context.Context, model *genai.GenerativeModel, prompt ...genai.Part) (string, error) {
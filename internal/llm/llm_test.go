@@ -0,0 +1,66 @@
+package llm
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+)
+
+func TestCommandString(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		cmd  Command
+		want string
+	}{
+		{"simple", Command{Program: "npm", Args: []string{"install", "--force"}}, "npm install --force"},
+		{"quoted", Command{Program: "sh", Args: []string{"-c", "echo 'hi' $HOME"}}, `sh -c 'echo '\''hi'\'' $HOME'`},
+		{"env", Command{Program: "make", Env: []string{"CC=gcc -O2", "V=1"}}, "CC='gcc -O2' V=1 make"},
+		{"cwd", Command{Program: "npm", Args: []string{"pack"}, Cwd: "packages/a b"}, "(cd 'packages/a b' && npm pack)"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.cmd.String(); got != tc.want {
+				t.Errorf("String() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestScriptResponseStrategy(t *testing.T) {
+	var resp ScriptResponse
+	err := json.Unmarshal([]byte(`{
+		"reason": "uses yarn",
+		"commands": [
+			{"program": "npm", "args": ["install", "-g", "yarn@1.22.19"], "phase": "deps"},
+			{"program": "yarn", "args": ["install"], "env": ["CI=true"], "phase": "build"},
+			{"program": "npm", "args": ["pack"], "cwd": "pkg", "phase": "build"}
+		]
+	}`), &resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loc := rebuild.Location{Repo: "https://github.com/example/repo", Ref: "abc123", Dir: "."}
+	got, err := resp.Strategy(loc, "pkg/pkg-1.0.0.tgz")
+	if err != nil {
+		t.Fatalf("Strategy() error = %v", err)
+	}
+	want := &rebuild.ManualStrategy{
+		Location:   loc,
+		Deps:       "npm install -g yarn@1.22.19",
+		Build:      "CI=true yarn install\n(cd pkg && npm pack)",
+		OutputPath: "pkg/pkg-1.0.0.tgz",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Strategy() mismatch (-want +got):\n%s", diff)
+	}
+	for _, c := range []Command{
+		{Program: "", Phase: BuildPhase},
+		{Program: "make", Phase: "test"},
+		{Program: "make", Phase: BuildPhase, Env: []string{"1BAD=x"}},
+	} {
+		if _, err := (ScriptResponse{Commands: []Command{c}}).Strategy(loc, ""); err == nil {
+			t.Errorf("Strategy() with %+v expected error", c)
+		}
+	}
+}
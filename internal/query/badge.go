@@ -0,0 +1,123 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+// BadgeState is the summarized rebuild status of a package version.
+type BadgeState string
+
+const (
+	BadgeVerified     BadgeState = "rebuilt & verified"
+	BadgeMismatch     BadgeState = "mismatch"
+	BadgeNotAttempted BadgeState = "not attempted"
+)
+
+var badgeColors = map[BadgeState]string{
+	BadgeVerified:     "#4c1",
+	BadgeMismatch:     "#e05d44",
+	BadgeNotAttempted: "#9f9f9f",
+}
+
+// badgeLabel is the text on the left-hand side of the badge.
+const badgeLabel = "oss-rebuild"
+
+// StateOf summarizes the artifacts of a version response.
+//
+// A version is only reported as verified when none of its artifacts failed to reproduce.
+func StateOf(resp *VersionResponse) BadgeState {
+	if len(resp.Artifacts) == 0 {
+		return BadgeNotAttempted
+	}
+	for _, a := range resp.Artifacts {
+		if a.Status != Verified {
+			return BadgeMismatch
+		}
+	}
+	return BadgeVerified
+}
+
+// NOTE: The layout follows the "flat" style popularized by shields.io.
+var badgeTpl = template.Must(template.New("badge").Parse(`<svg xmlns="http://www.w3.org/2000/svg" width="{{.Width}}" height="20" role="img" aria-label="{{.Label}}: {{.Message}}">
+<title>{{.Label}}: {{.Message}}</title>
+<linearGradient id="s" x2="0" y2="100%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>
+<clipPath id="r"><rect width="{{.Width}}" height="20" rx="3" fill="#fff"/></clipPath>
+<g clip-path="url(#r)"><rect width="{{.LabelWidth}}" height="20" fill="#555"/><rect x="{{.LabelWidth}}" width="{{.MessageWidth}}" height="20" fill="{{.Color}}"/><rect width="{{.Width}}" height="20" fill="url(#s)"/></g>
+<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">
+<text x="{{.LabelX}}" y="15" fill="#010101" fill-opacity=".3">{{.Label}}</text><text x="{{.LabelX}}" y="14">{{.Label}}</text>
+<text x="{{.MessageX}}" y="15" fill="#010101" fill-opacity=".3">{{.Message}}</text><text x="{{.MessageX}}" y="14">{{.Message}}</text>
+</g>
+</svg>
+`))
+
+// textWidth approximates the rendered width of s in 11px Verdana.
+func textWidth(s string) int {
+	return len(s)*7 + 10
+}
+
+// RenderBadge renders the SVG badge for the given state.
+func RenderBadge(state BadgeState) ([]byte, error) {
+	// NOTE: The message is the only text with characters requiring escaping.
+	msg := strings.ReplaceAll(string(state), "&", "&amp;")
+	lw, mw := textWidth(badgeLabel), textWidth(string(state))
+	var buf bytes.Buffer
+	err := badgeTpl.Execute(&buf, map[string]any{
+		"Label":        badgeLabel,
+		"Message":      msg,
+		"Color":        badgeColors[state],
+		"Width":        lw + mw,
+		"LabelWidth":   lw,
+		"MessageWidth": mw,
+		"LabelX":       lw / 2,
+		"MessageX":     lw + mw/2,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "rendering badge")
+	}
+	return buf.Bytes(), nil
+}
+
+// badge serves /badge/{ecosystem}/{package}/{version}.svg where package may contain slashes.
+func (s *Server) badge(w http.ResponseWriter, r *http.Request) {
+	ecosystem := r.PathValue("ecosystem")
+	rest, isSVG := strings.CutSuffix(r.PathValue("rest"), ".svg")
+	pkg, version, ok := parseVersionPath(rest)
+	if !knownEcosystem(ecosystem) || !ok || !isSVG {
+		http.Error(w, "expected /badge/{ecosystem}/{package}/{version}.svg", http.StatusBadRequest)
+		return
+	}
+	resp, err := s.resolve(r.Context(), ecosystem, pkg, version)
+	if err != nil {
+		log.Println(errors.Wrap(err, "querying attestations"))
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	svg, err := RenderBadge(StateOf(resp))
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	// NOTE: Badges are always served successfully so they render when embedded.
+	s.write(w, r, http.StatusOK, "image/svg+xml", svg)
+}
@@ -0,0 +1,282 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package query implements a public, read-only API over published attestation metadata.
+package query
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	gcs "cloud.google.com/go/storage"
+	"github.com/google/oss-rebuild/pkg/rebuild/meta"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/pkg/errors"
+	"google.golang.org/api/iterator"
+)
+
+// Object is a stored attestation object.
+type Object struct {
+	// Name is the object's path within the bucket.
+	Name    string
+	Updated time.Time
+}
+
+// Lister enumerates the attestation objects under a prefix.
+type Lister interface {
+	List(ctx context.Context, prefix string) ([]Object, error)
+}
+
+// GCSLister lists objects from a GCS bucket.
+type GCSLister struct {
+	Bucket *gcs.BucketHandle
+}
+
+var _ Lister = &GCSLister{}
+
+// List returns the objects whose names begin with prefix.
+func (l *GCSLister) List(ctx context.Context, prefix string) ([]Object, error) {
+	q := &gcs.Query{Prefix: prefix}
+	if err := q.SetAttrSelection([]string{"Name", "Updated"}); err != nil {
+		return nil, err
+	}
+	var objs []Object
+	it := l.Bucket.Objects(ctx, q)
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		} else if err != nil {
+			return nil, errors.Wrap(err, "listing objects")
+		}
+		objs = append(objs, Object{Name: attrs.Name, Updated: attrs.Updated})
+	}
+	return objs, nil
+}
+
+// Status is the verification status of an artifact.
+type Status string
+
+const (
+	// Verified indicates the artifact was rebuilt and an attestation bundle published.
+	Verified Status = "verified"
+	// Unverified indicates a rebuild was attempted but did not reproduce the artifact.
+	Unverified Status = "unverified"
+)
+
+// Artifact describes the published attestation metadata for a single artifact.
+type Artifact struct {
+	Artifact  string    `json:"artifact"`
+	Status    Status    `json:"status"`
+	BundleURL string    `json:"bundle_url,omitempty"`
+	VEXURL    string    `json:"vex_url,omitempty"`
+	Updated   time.Time `json:"updated"`
+}
+
+// VersionResponse is the response for a package version query.
+type VersionResponse struct {
+	Ecosystem string     `json:"ecosystem"`
+	Package   string     `json:"package"`
+	Version   string     `json:"version"`
+	Artifacts []Artifact `json:"artifacts"`
+}
+
+// maxCacheEntries bounds the number of responses retained in memory.
+const maxCacheEntries = 10000
+
+// cached is a looked-up response retained until expiry.
+type cached struct {
+	resp    *VersionResponse
+	expires time.Time
+}
+
+// Server serves the query API.
+type Server struct {
+	lister Lister
+	// baseURL is the public URL of the bucket against which object names are resolved.
+	baseURL *url.URL
+	// maxAge is the duration for which responses may be cached by clients and CDNs.
+	maxAge time.Duration
+	now    func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]cached
+}
+
+// NewServer creates a Server listing objects with l and linking to them relative to baseURL.
+func NewServer(l Lister, baseURL string, maxAge time.Duration) (*Server, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing base URL")
+	}
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+	return &Server{lister: l, baseURL: u, maxAge: maxAge, now: time.Now, cache: make(map[string]cached)}, nil
+}
+
+// Handler returns the http.Handler serving all query routes.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/{ecosystem}/{rest...}", s.version)
+	mux.HandleFunc("GET /badge/{ecosystem}/{rest...}", s.badge)
+	return mux
+}
+
+func knownEcosystem(e string) bool {
+	_, ok := meta.Lookup(rebuild.Ecosystem(e))
+	return ok
+}
+
+func (s *Server) objectURL(name string) string {
+	return s.baseURL.ResolveReference(&url.URL{Path: name}).String()
+}
+
+// lookup collates the attestation objects for a package version.
+func (s *Server) lookup(ctx context.Context, ecosystem, pkg, version string) (*VersionResponse, error) {
+	prefix := path.Join(ecosystem, pkg, version) + "/"
+	objs, err := s.lister.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	byArtifact := make(map[string]*Artifact)
+	for _, o := range objs {
+		artifact, asset, ok := strings.Cut(strings.TrimPrefix(o.Name, prefix), "/")
+		// NOTE: Objects nested more deeply are not published attestations.
+		if !ok || strings.Contains(asset, "/") {
+			continue
+		}
+		a, ok := byArtifact[artifact]
+		if !ok {
+			a = &Artifact{Artifact: artifact}
+		}
+		switch rebuild.AssetType(asset) {
+		case rebuild.AttestationBundleAsset:
+			a.BundleURL = s.objectURL(o.Name)
+		case rebuild.VEXAsset:
+			a.VEXURL = s.objectURL(o.Name)
+		default:
+			continue
+		}
+		if o.Updated.After(a.Updated) {
+			a.Updated = o.Updated
+		}
+		byArtifact[artifact] = a
+	}
+	resp := &VersionResponse{Ecosystem: ecosystem, Package: pkg, Version: version, Artifacts: []Artifact{}}
+	for _, a := range byArtifact {
+		if a.BundleURL != "" {
+			a.Status = Verified
+		} else {
+			a.Status = Unverified
+		}
+		resp.Artifacts = append(resp.Artifacts, *a)
+	}
+	sort.Slice(resp.Artifacts, func(i, j int) bool { return resp.Artifacts[i].Artifact < resp.Artifacts[j].Artifact })
+	return resp, nil
+}
+
+// resolve returns the possibly-cached response for a package version.
+func (s *Server) resolve(ctx context.Context, ecosystem, pkg, version string) (*VersionResponse, error) {
+	key := path.Join(ecosystem, pkg, version)
+	now := s.now()
+	s.mu.Lock()
+	c, ok := s.cache[key]
+	s.mu.Unlock()
+	if ok && now.Before(c.expires) {
+		return c.resp, nil
+	}
+	resp, err := s.lookup(ctx, ecosystem, pkg, version)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.cache) >= maxCacheEntries {
+		for k, v := range s.cache {
+			if !now.Before(v.expires) {
+				delete(s.cache, k)
+			}
+		}
+		if len(s.cache) >= maxCacheEntries {
+			s.cache = make(map[string]cached)
+		}
+	}
+	s.cache[key] = cached{resp: resp, expires: now.Add(s.maxAge)}
+	return resp, nil
+}
+
+// write serves body with the headers permitting clients and CDNs to cache it.
+func (s *Server) write(w http.ResponseWriter, r *http.Request, status int, contentType string, body []byte) {
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	h := w.Header()
+	h.Set("Access-Control-Allow-Origin", "*")
+	h.Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(s.maxAge.Seconds())))
+	h.Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	h.Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// parseVersionPath splits the {package}/{version} suffix of a request path.
+func parseVersionPath(rest string) (pkg, version string, ok bool) {
+	idx := strings.LastIndex(rest, "/")
+	if idx <= 0 || idx == len(rest)-1 {
+		return "", "", false
+	}
+	return rest[:idx], rest[idx+1:], true
+}
+
+// version serves /v1/{ecosystem}/{package}/{version} where package may contain slashes.
+func (s *Server) version(w http.ResponseWriter, r *http.Request) {
+	ecosystem := r.PathValue("ecosystem")
+	pkg, version, ok := parseVersionPath(r.PathValue("rest"))
+	if !knownEcosystem(ecosystem) || !ok {
+		http.Error(w, "expected /v1/{ecosystem}/{package}/{version}", http.StatusBadRequest)
+		return
+	}
+	resp, err := s.resolve(r.Context(), ecosystem, pkg, version)
+	if err != nil {
+		log.Println(errors.Wrap(err, "querying attestations"))
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	body, err := json.Marshal(resp)
+	if err != nil {
+		log.Println(errors.Wrap(err, "marshalling response"))
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	status := http.StatusOK
+	if len(resp.Artifacts) == 0 {
+		status = http.StatusNotFound
+	}
+	s.write(w, r, status, "application/json", body)
+}
@@ -0,0 +1,171 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type fakeLister struct {
+	objs  []Object
+	calls int
+}
+
+func (l *fakeLister) List(_ context.Context, prefix string) ([]Object, error) {
+	l.calls++
+	var objs []Object
+	for _, o := range l.objs {
+		if strings.HasPrefix(o.Name, prefix) {
+			objs = append(objs, o)
+		}
+	}
+	return objs, nil
+}
+
+func TestServer(t *testing.T) {
+	t1 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	t2 := t1.Add(time.Hour)
+	l := &fakeLister{objs: []Object{
+		{Name: "npm/@scope/pkg/1.0.0/pkg-1.0.0.tgz/rebuild.intoto.jsonl", Updated: t1},
+		{Name: "npm/@scope/pkg/1.0.0/pkg-1.0.0.tgz/rebuild.openvex.intoto.json", Updated: t2},
+		{Name: "npm/@scope/pkg/1.0.0/pkg-1.0.0.tgz/run-id/logs", Updated: t2},
+		{Name: "npm/@scope/pkg/1.0.0/other.tgz/rebuild.openvex.intoto.json", Updated: t1},
+		{Name: "npm/@scope/pkg/1.0.01/pkg-1.0.01.tgz/rebuild.intoto.jsonl", Updated: t1},
+	}}
+	s, err := NewServer(l, "https://storage.googleapis.com/bucket", time.Minute)
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	now := t2
+	s.now = func() time.Time { return now }
+	h := s.Handler()
+	get := func(path string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	t.Run("found", func(t *testing.T) {
+		rec := get("/v1/npm/@scope/pkg/1.0.0", nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200", rec.Code)
+		}
+		if got := rec.Header().Get("Cache-Control"); got != "public, max-age=60" {
+			t.Errorf("Cache-Control = %q", got)
+		}
+		var got VersionResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatalf("decoding response: %v", err)
+		}
+		want := VersionResponse{
+			Ecosystem: "npm",
+			Package:   "@scope/pkg",
+			Version:   "1.0.0",
+			Artifacts: []Artifact{
+				{
+					Artifact: "other.tgz",
+					Status:   Unverified,
+					VEXURL:   "https://storage.googleapis.com/bucket/npm/@scope/pkg/1.0.0/other.tgz/rebuild.openvex.intoto.json",
+					Updated:  t1,
+				},
+				{
+					Artifact:  "pkg-1.0.0.tgz",
+					Status:    Verified,
+					BundleURL: "https://storage.googleapis.com/bucket/npm/@scope/pkg/1.0.0/pkg-1.0.0.tgz/rebuild.intoto.jsonl",
+					VEXURL:    "https://storage.googleapis.com/bucket/npm/@scope/pkg/1.0.0/pkg-1.0.0.tgz/rebuild.openvex.intoto.json",
+					Updated:   t2,
+				},
+			},
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("response mismatch (-want +got):\n%s", diff)
+		}
+		if rec := get("/v1/npm/@scope/pkg/1.0.0", http.Header{"If-None-Match": {rec.Header().Get("ETag")}}); rec.Code != http.StatusNotModified {
+			t.Errorf("conditional status = %d, want 304", rec.Code)
+		}
+		if l.calls != 1 {
+			t.Errorf("List() calls = %d, want 1", l.calls)
+		}
+		now = now.Add(2 * time.Minute)
+		get("/v1/npm/@scope/pkg/1.0.0", nil)
+		if l.calls != 2 {
+			t.Errorf("List() calls after expiry = %d, want 2", l.calls)
+		}
+	})
+	t.Run("not found", func(t *testing.T) {
+		if rec := get("/v1/pypi/absl-py/2.0.0", nil); rec.Code != http.StatusNotFound {
+			t.Errorf("status = %d, want 404", rec.Code)
+		}
+	})
+	t.Run("bad request", func(t *testing.T) {
+		for _, path := range []string{"/v1/npm/pkg", "/v1/unknown/pkg/1.0.0", "/v1/npm/pkg/"} {
+			if rec := get(path, nil); rec.Code != http.StatusBadRequest {
+				t.Errorf("%s status = %d, want 400", path, rec.Code)
+			}
+		}
+	})
+}
+
+func TestBadge(t *testing.T) {
+	l := &fakeLister{objs: []Object{
+		{Name: "npm/good/1.0.0/good-1.0.0.tgz/rebuild.intoto.jsonl"},
+		{Name: "npm/bad/1.0.0/bad-1.0.0.tgz/rebuild.openvex.intoto.json"},
+	}}
+	s, err := NewServer(l, "https://storage.googleapis.com/bucket", time.Minute)
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	h := s.Handler()
+	for _, tc := range []struct {
+		path string
+		want BadgeState
+	}{
+		{"/badge/npm/good/1.0.0.svg", BadgeVerified},
+		{"/badge/npm/bad/1.0.0.svg", BadgeMismatch},
+		{"/badge/npm/missing/1.0.0.svg", BadgeNotAttempted},
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s status = %d, want 200", tc.path, rec.Code)
+		}
+		if got := rec.Header().Get("Content-Type"); got != "image/svg+xml" {
+			t.Errorf("%s Content-Type = %q", tc.path, got)
+		}
+		want, err := RenderBadge(tc.want)
+		if err != nil {
+			t.Fatalf("RenderBadge() error = %v", err)
+		}
+		if diff := cmp.Diff(string(want), rec.Body.String()); diff != "" {
+			t.Errorf("%s badge mismatch (-want +got):\n%s", tc.path, diff)
+		}
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/badge/npm/good/1.0.0", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status without extension = %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,248 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ratex provides adaptive, per-host rate limiting for HTTP clients.
+package ratex
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/oss-rebuild/internal/httpx"
+)
+
+// HostStats describes the rate limiting applied to requests for a single host.
+type HostStats struct {
+	Requests  int
+	Throttled int
+	Waited    time.Duration
+}
+
+type hostState struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+	stats    HostStats
+}
+
+// Limiter spaces requests to each host and backs off when servers indicate throttling.
+//
+// A single Limiter is intended to be shared across goroutines and clients so
+// that all requests to a host observe the same limits.
+type Limiter struct {
+	// Interval is the minimum time between requests to a host when not throttled.
+	Interval time.Duration
+	// MaxInterval bounds the adaptive backoff applied after throttled responses.
+	MaxInterval time.Duration
+	// MaxWait bounds the delay honored from a server-provided hint.
+	MaxWait time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]*hostState
+	now   func() time.Time
+}
+
+// NewLimiter returns a Limiter with the provided base interval between requests to a host.
+func NewLimiter(interval time.Duration) *Limiter {
+	return &Limiter{Interval: interval, MaxInterval: 10 * time.Second, MaxWait: 5 * time.Minute}
+}
+
+func (l *Limiter) host(h string) *hostState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.hosts == nil {
+		l.hosts = make(map[string]*hostState)
+	}
+	s, ok := l.hosts[h]
+	if !ok {
+		s = &hostState{interval: l.Interval}
+		l.hosts[h] = s
+	}
+	return s
+}
+
+func (l *Limiter) clock() time.Time {
+	if l.now != nil {
+		return l.now()
+	}
+	return time.Now()
+}
+
+// Wait blocks until a request to the host is permitted or ctx is done.
+func (l *Limiter) Wait(ctx context.Context, host string) error {
+	s := l.host(host)
+	s.mu.Lock()
+	now := l.clock()
+	start := now
+	if s.next.After(now) {
+		start = s.next
+	}
+	wait := start.Sub(now)
+	s.next = start.Add(s.interval)
+	s.stats.Requests++
+	s.stats.Waited += wait
+	s.mu.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Observe updates the host's limits from the server's response.
+func (l *Limiter) Observe(host string, resp *http.Response) {
+	s := l.host(host)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := l.clock()
+	wait, hinted := l.hint(resp, now)
+	throttled := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable
+	switch {
+	case throttled:
+		s.stats.Throttled++
+		// Back off multiplicatively until the server stops throttling.
+		s.interval = min(max(2*s.interval, time.Second), l.MaxInterval)
+		if !hinted {
+			wait = s.interval
+		}
+	case s.interval > l.Interval:
+		// Recover gradually toward the base interval.
+		s.interval = max(s.interval*9/10, l.Interval)
+	}
+	if until := now.Add(min(wait, l.MaxWait)); wait > 0 && until.After(s.next) {
+		s.next = until
+	}
+}
+
+// hint returns the server-requested delay before the next request, if any.
+func (l *Limiter) hint(resp *http.Response, now time.Time) (time.Duration, bool) {
+	if v := resp.Header.Get("Retry-After"); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil {
+			return time.Duration(secs) * time.Second, true
+		}
+		if t, err := http.ParseTime(v); err == nil {
+			return t.Sub(now), true
+		}
+	}
+	// Registries variously use the GitHub-style and IETF draft headers.
+	for _, prefix := range []string{"X-RateLimit-", "RateLimit-"} {
+		if resp.Header.Get(prefix+"Remaining") != "0" {
+			continue
+		}
+		reset, err := strconv.ParseInt(resp.Header.Get(prefix+"Reset"), 10, 64)
+		if err != nil {
+			continue
+		}
+		// NOTE: Large values are epoch timestamps rather than relative delays.
+		if reset > 1e9 {
+			return time.Unix(reset, 0).Sub(now), true
+		}
+		return time.Duration(reset) * time.Second, true
+	}
+	return 0, false
+}
+
+// Stats returns the rate limiting statistics for each host.
+func (l *Limiter) Stats() map[string]HostStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	stats := make(map[string]HostStats, len(l.hosts))
+	for h, s := range l.hosts {
+		s.mu.Lock()
+		stats[h] = s.stats
+		s.mu.Unlock()
+	}
+	return stats
+}
+
+// LogStats logs the statistics of each host throttled since the previous
+// report every interval until ctx is done.
+func (l *Limiter) LogStats(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	var prev map[string]HostStats
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		cur := l.Stats()
+		for _, h := range throttledSince(prev, cur) {
+			s := cur[h]
+			log.Printf("Rate limited by %s: %d of %d requests throttled, waited %v", h, s.Throttled, s.Requests, s.Waited)
+		}
+		prev = cur
+	}
+}
+
+// throttledSince returns the hosts in cur throttled since prev in sorted order.
+func throttledSince(prev, cur map[string]HostStats) []string {
+	var hosts []string
+	for h, s := range cur {
+		if s.Throttled > prev[h].Throttled {
+			hosts = append(hosts, h)
+		}
+	}
+	slices.Sort(hosts)
+	return hosts
+}
+
+// Client is a BasicClient that applies a Limiter to its requests.
+type Client struct {
+	httpx.BasicClient
+	Limiter *Limiter
+	// Retries is the number of times a throttled request without a body is retried.
+	Retries int
+}
+
+var _ httpx.BasicClient = &Client{}
+
+// Do waits for the request's host to be available and sends the request.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	for attempt := 0; ; attempt++ {
+		if err := c.Limiter.Wait(req.Context(), host); err != nil {
+			return nil, err
+		}
+		resp, err := c.BasicClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		c.Limiter.Observe(host, resp)
+		throttled := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable
+		if !throttled || attempt >= c.Retries || req.Body != nil && req.GetBody == nil {
+			return resp, nil
+		}
+		resp.Body.Close()
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+	}
+}
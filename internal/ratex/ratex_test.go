@@ -0,0 +1,177 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratex
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/internal/httpx/httpxtest"
+)
+
+func response(code int, headers map[string]string) *http.Response {
+	h := make(http.Header)
+	for k, v := range headers {
+		h.Set(k, v)
+	}
+	return &http.Response{StatusCode: code, Header: h, Body: io.NopCloser(strings.NewReader(""))}
+}
+
+func TestObserve(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name     string
+		resp     *http.Response
+		wantNext time.Time
+	}{
+		{
+			name:     "RetryAfterSeconds",
+			resp:     response(http.StatusTooManyRequests, map[string]string{"Retry-After": "30"}),
+			wantNext: now.Add(30 * time.Second),
+		},
+		{
+			name:     "RetryAfterDate",
+			resp:     response(http.StatusServiceUnavailable, map[string]string{"Retry-After": now.Add(time.Minute).Format(http.TimeFormat)}),
+			wantNext: now.Add(time.Minute),
+		},
+		{
+			name:     "RateLimitResetEpoch",
+			resp:     response(http.StatusOK, map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": "1704067220"}),
+			wantNext: now.Add(20 * time.Second),
+		},
+		{
+			name:     "RateLimitResetDelta",
+			resp:     response(http.StatusOK, map[string]string{"RateLimit-Remaining": "0", "RateLimit-Reset": "5"}),
+			wantNext: now.Add(5 * time.Second),
+		},
+		{
+			name:     "RemainingQuota",
+			resp:     response(http.StatusOK, map[string]string{"X-RateLimit-Remaining": "10", "X-RateLimit-Reset": "5"}),
+			wantNext: time.Time{},
+		},
+		{
+			name:     "ThrottledWithoutHint",
+			resp:     response(http.StatusTooManyRequests, nil),
+			wantNext: now.Add(time.Second),
+		},
+		{
+			name:     "HintBoundedByMaxWait",
+			resp:     response(http.StatusTooManyRequests, map[string]string{"Retry-After": "86400"}),
+			wantNext: now.Add(5 * time.Minute),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			l := NewLimiter(0)
+			l.now = func() time.Time { return now }
+			l.Observe("example.com", tc.resp)
+			if got := l.host("example.com").next; !got.Equal(tc.wantNext) {
+				t.Errorf("next = %v, want %v", got, tc.wantNext)
+			}
+		})
+	}
+}
+
+func TestAdaptiveInterval(t *testing.T) {
+	l := NewLimiter(100 * time.Millisecond)
+	l.Observe("a.com", response(http.StatusTooManyRequests, nil))
+	l.Observe("a.com", response(http.StatusTooManyRequests, nil))
+	if got := l.host("a.com").interval; got != 2*time.Second {
+		t.Errorf("throttled interval = %v, want 2s", got)
+	}
+	if got := l.host("b.com").interval; got != 100*time.Millisecond {
+		t.Errorf("unrelated host interval = %v, want 100ms", got)
+	}
+	for range 100 {
+		l.Observe("a.com", response(http.StatusOK, nil))
+	}
+	if got := l.host("a.com").interval; got != 100*time.Millisecond {
+		t.Errorf("recovered interval = %v, want 100ms", got)
+	}
+	if got := l.Stats()["a.com"].Throttled; got != 2 {
+		t.Errorf("Throttled = %d, want 2", got)
+	}
+}
+
+func TestThrottledSince(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		prev, cur map[string]HostStats
+		want      []string
+	}{
+		{
+			name: "first report",
+			cur:  map[string]HostStats{"b.com": {Requests: 3, Throttled: 1}, "a.com": {Requests: 2, Throttled: 2}, "c.com": {Requests: 5}},
+			want: []string{"a.com", "b.com"},
+		},
+		{
+			name: "newly throttled",
+			prev: map[string]HostStats{"a.com": {Requests: 2, Throttled: 2}, "b.com": {Requests: 3, Throttled: 1}},
+			cur:  map[string]HostStats{"a.com": {Requests: 9, Throttled: 2}, "b.com": {Requests: 4, Throttled: 2}},
+			want: []string{"b.com"},
+		},
+		{
+			name: "unchanged",
+			prev: map[string]HostStats{"a.com": {Requests: 2, Throttled: 2}},
+			cur:  map[string]HostStats{"a.com": {Requests: 5, Throttled: 2}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, throttledSince(tc.prev, tc.cur)); diff != "" {
+				t.Errorf("throttledSince() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestClientRetry(t *testing.T) {
+	mock := &httpxtest.MockClient{
+		Calls: []httpxtest.Call{
+			{URL: "https://registry.example/pkg", Response: response(http.StatusTooManyRequests, map[string]string{"Retry-After": "0"})},
+			{URL: "https://registry.example/pkg", Response: response(http.StatusOK, nil)},
+		},
+	}
+	l := NewLimiter(0)
+	l.MaxInterval = 10 * time.Millisecond
+	c := &Client{BasicClient: mock, Limiter: l, Retries: 1}
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, "https://registry.example/pkg", nil)
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Do() status = %d, want 200", resp.StatusCode)
+	}
+	stats := l.Stats()["registry.example"]
+	if stats.Requests != 2 || stats.Throttled != 1 {
+		t.Errorf("Stats() = %+v, want 2 requests and 1 throttled", stats)
+	}
+}
+
+func TestWaitCanceled(t *testing.T) {
+	l := NewLimiter(time.Hour)
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := l.Wait(ctx, "a.com"); err != nil {
+		t.Fatalf("first Wait() error = %v", err)
+	}
+	cancel()
+	if err := l.Wait(ctx, "a.com"); err != context.Canceled {
+		t.Errorf("Wait() error = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package scrub redacts credentials from build output prior to storage.
+//
+// Build scripts are user-controlled and may print tokens, e.g. by echoing
+// an .npmrc or running curl with --verbose. Output is scanned for well-known
+// credential formats and each occurrence is replaced with a marker naming the
+// kind of credential found.
+package scrub
+
+import (
+	"bytes"
+	"regexp"
+)
+
+type rule struct {
+	kind string
+	// re matches the credential. If re has a subexpression named "secret",
+	// only that portion of the match is redacted.
+	re *regexp.Regexp
+}
+
+var rules = []rule{
+	{"private_key", regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`)},
+	{"authorization", regexp.MustCompile(`(?i)\b(?:proxy-)?authorization:\s*(?:(?:bearer|basic|token|bot)\s+)?(?P<secret>[^\s"']+)`)},
+	{"url_credentials", regexp.MustCompile(`\b[a-zA-Z][a-zA-Z0-9+.-]*://[^\s/:@"']+:(?P<secret>[^\s/@"']+)@`)},
+	{"npm_auth", regexp.MustCompile(`\b_(?:authToken|auth|password)\s*=\s*(?P<secret>[^\s"']+)`)},
+	{"github_token", regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})`)},
+	{"npm_token", regexp.MustCompile(`\bnpm_[A-Za-z0-9]{36}\b`)},
+	{"pypi_token", regexp.MustCompile(`\bpypi-AgE[A-Za-z0-9_-]{50,}`)},
+	{"aws_access_key", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"google_api_key", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}`)},
+	{"google_oauth_token", regexp.MustCompile(`\bya29\.[0-9A-Za-z_-]{20,}`)},
+	{"slack_token", regexp.MustCompile(`\bxox[abposr]-[0-9A-Za-z-]{10,}`)},
+}
+
+// Bytes returns b with all detected credentials redacted along with the
+// number of credentials found.
+func Bytes(b []byte) ([]byte, int) {
+	var found int
+	for _, r := range rules {
+		secret := r.re.SubexpIndex("secret")
+		marker := []byte("[REDACTED:" + r.kind + "]")
+		var out bytes.Buffer
+		var last int
+		for _, m := range r.re.FindAllSubmatchIndex(b, -1) {
+			start, end := m[0], m[1]
+			if secret >= 0 {
+				start, end = m[2*secret], m[2*secret+1]
+			}
+			if bytes.HasPrefix(b[start:end], []byte("[REDACTED:")) {
+				continue
+			}
+			out.Write(b[last:start])
+			out.Write(marker)
+			last = end
+			found++
+		}
+		if last > 0 {
+			out.Write(b[last:])
+			b = out.Bytes()
+		}
+	}
+	return b, found
+}
+
+// String returns s with all detected credentials redacted along with the
+// number of credentials found.
+func String(s string) (string, int) {
+	b, n := Bytes([]byte(s))
+	return string(b), n
+}
@@ -0,0 +1,195 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package semver
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type comparator struct {
+	op string
+	v  Semver
+}
+
+func (c comparator) matches(v Semver) bool {
+	o := v.Compare(c.v)
+	switch c.op {
+	case ">":
+		return o > 0
+	case ">=":
+		return o >= 0
+	case "<":
+		return o < 0
+	case "<=":
+		return o <= 0
+	default:
+		return o == 0
+	}
+}
+
+// Range is a set of version constraints expressed in the npm range syntax
+// e.g. ">=14 <18 || ^20.1".
+//
+// NOTE: Unlike npm, prerelease versions are not excluded from ranges that do
+// not explicitly reference them.
+type Range struct {
+	// sets is a disjunction of conjunctions of comparators.
+	sets [][]comparator
+}
+
+var (
+	// opSpaceRE matches whitespace separating an operator from its version.
+	opSpaceRE = regexp.MustCompile(`(>=|<=|>|<|=|~>|~|\^)\s+`)
+	partialRE = regexp.MustCompile(`^(>=|<=|>|<|=|~>|~|\^)?v?(\d+|[xX*])(?:\.(\d+|[xX*]))?(?:\.(\d+|[xX*]))?(?:-([0-9A-Za-z.-]+))?(?:\+[0-9A-Za-z.-]+)?$`)
+)
+
+// ParseRange parses an npm version range.
+func ParseRange(s string) (Range, error) {
+	var r Range
+	for _, set := range strings.Split(s, "||") {
+		set = strings.TrimSpace(opSpaceRE.ReplaceAllString(set, "$1"))
+		var cs []comparator
+		if lo, hi, ok := strings.Cut(set, " - "); ok {
+			l, err := expand(">=", strings.TrimSpace(lo))
+			if err != nil {
+				return Range{}, err
+			}
+			h, err := expand("<=", strings.TrimSpace(hi))
+			if err != nil {
+				return Range{}, err
+			}
+			cs = append(l, h...)
+		} else {
+			for _, tok := range strings.Fields(set) {
+				c, err := expand("", tok)
+				if err != nil {
+					return Range{}, err
+				}
+				cs = append(cs, c...)
+			}
+		}
+		r.sets = append(r.sets, cs)
+	}
+	return r, nil
+}
+
+// expand converts a possibly-partial version constraint to its comparators.
+func expand(op, tok string) ([]comparator, error) {
+	m := partialRE.FindStringSubmatch(tok)
+	if m == nil {
+		return nil, errors.Errorf("invalid range constraint: %q", tok)
+	}
+	if m[1] != "" {
+		op = m[1]
+	}
+	// parts holds the numeric components up to the first wildcard or omission.
+	var parts []int
+	for _, p := range m[2:5] {
+		if p == "" || p == "x" || p == "X" || p == "*" {
+			break
+		}
+		n, _ := strconv.Atoi(p)
+		parts = append(parts, n)
+	}
+	lo := Semver{}
+	for i, n := range parts {
+		switch i {
+		case 0:
+			lo.Major = n
+		case 1:
+			lo.Minor = n
+		case 2:
+			lo.Patch = n
+		}
+	}
+	if len(parts) == 3 {
+		lo.Prerelease = m[5]
+	}
+	// next returns the lowest version exceeding all those matching the first n parts.
+	next := func(n int) Semver {
+		switch n {
+		case 1:
+			return Semver{Major: lo.Major + 1}
+		case 2:
+			return Semver{Major: lo.Major, Minor: lo.Minor + 1}
+		default:
+			return Semver{Major: lo.Major, Minor: lo.Minor, Patch: lo.Patch + 1}
+		}
+	}
+	switch {
+	case len(parts) == 0:
+		if op == "<" || op == ">" {
+			// Nothing can be outside of the set of all versions.
+			return []comparator{{"<", Semver{}}}, nil
+		}
+		return nil, nil
+	case op == "^":
+		// Allow changes not modifying the left-most non-zero component.
+		n := 1
+		if lo.Major == 0 && len(parts) > 1 {
+			n = 2
+			if lo.Minor == 0 && len(parts) > 2 {
+				n = 3
+			}
+		}
+		return []comparator{{">=", lo}, {"<", next(n)}}, nil
+	case op == "~" || op == "~>":
+		return []comparator{{">=", lo}, {"<", next(min(len(parts), 2))}}, nil
+	case op == ">=":
+		return []comparator{{">=", lo}}, nil
+	case op == "<":
+		return []comparator{{"<", lo}}, nil
+	case op == ">":
+		if len(parts) == 3 {
+			return []comparator{{">", lo}}, nil
+		}
+		return []comparator{{">=", next(len(parts))}}, nil
+	case op == "<=":
+		if len(parts) == 3 {
+			return []comparator{{"<=", lo}}, nil
+		}
+		return []comparator{{"<", next(len(parts))}}, nil
+	default:
+		if len(parts) == 3 {
+			return []comparator{{"=", lo}}, nil
+		}
+		return []comparator{{">=", lo}, {"<", next(len(parts))}}, nil
+	}
+}
+
+// Contains returns whether the version satisfies the range.
+func (r Range) Contains(v string) bool {
+	sv, err := New(v)
+	if err != nil {
+		return false
+	}
+	for _, set := range r.sets {
+		ok := true
+		for _, c := range set {
+			if !c.matches(sv) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
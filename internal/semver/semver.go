@@ -101,11 +101,16 @@ func Cmp(a, b string) int {
 	if err != nil {
 		return 1
 	}
+	return av.Compare(bv)
+}
+
+// Compare returns the ordering of s relative to o.
+func (s Semver) Compare(o Semver) int {
 	// Build metadata does not participate in ordering.
 	return cmp.Or(
-		cmp.Compare(av.Major, bv.Major),
-		cmp.Compare(av.Minor, bv.Minor),
-		cmp.Compare(av.Patch, bv.Patch),
-		prereleaseCmp(av.Prerelease, bv.Prerelease),
+		cmp.Compare(s.Major, o.Major),
+		cmp.Compare(s.Minor, o.Minor),
+		cmp.Compare(s.Patch, o.Patch),
+		prereleaseCmp(s.Prerelease, o.Prerelease),
 	)
 }
@@ -74,3 +74,54 @@ func TestCmp(t *testing.T) {
 		}
 	}
 }
+
+func TestRange(t *testing.T) {
+	tests := []struct {
+		rng      string
+		version  string
+		expected bool
+	}{
+		{"", "1.2.3", true},
+		{"*", "1.2.3", true},
+		{">=14", "14.0.0", true},
+		{">=14", "12.22.12", false},
+		{">= 14.17.0", "14.16.1", false},
+		{">=14 <18", "16.20.2", true},
+		{">=14 <18", "18.0.0", false},
+		{"^12.20.0 || ^14.13.1 || >=16.0.0", "13.0.0", false},
+		{"^12.20.0 || ^14.13.1 || >=16.0.0", "14.21.3", true},
+		{"^12.20.0 || ^14.13.1 || >=16.0.0", "22.1.0", true},
+		{"^0.2.3", "0.2.9", true},
+		{"^0.2.3", "0.3.0", false},
+		{"^0.0.3", "0.0.4", false},
+		{"~1.2", "1.2.9", true},
+		{"~1.2", "1.3.0", false},
+		{"~1.2.3", "1.2.2", false},
+		{"16.x", "16.20.2", true},
+		{"16", "17.0.0", false},
+		{">16", "16.20.2", false},
+		{">16", "17.0.0", true},
+		{"<=16", "16.20.2", true},
+		{"<=16.1", "16.2.0", false},
+		{"12.22.0 - 16", "16.20.2", true},
+		{"12.22.0 - 16", "12.21.0", false},
+		{"=18.1.0", "18.1.0", true},
+		{"v18.1.0", "18.1.1", false},
+		{">=14", "not-a-version", false},
+	}
+	for _, tt := range tests {
+		r, err := ParseRange(tt.rng)
+		if err != nil {
+			t.Errorf("ParseRange(%q) error: %v", tt.rng, err)
+			continue
+		}
+		if actual := r.Contains(tt.version); actual != tt.expected {
+			t.Errorf("ParseRange(%q).Contains(%q) = %v, expected %v", tt.rng, tt.version, actual, tt.expected)
+		}
+	}
+	for _, invalid := range []string{"node >= 0.4", ">=1.2.3.4", "latest"} {
+		if _, err := ParseRange(invalid); err == nil {
+			t.Errorf("ParseRange(%q) expected error", invalid)
+		}
+	}
+}
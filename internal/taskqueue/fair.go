@@ -0,0 +1,191 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package taskqueue
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Quota controls the share of rebuild capacity available to an ecosystem.
+type Quota struct {
+	// Weight is the ecosystem's relative share of Capacity when contended.
+	Weight int `yaml:"weight"`
+	// MaxConcurrency bounds the ecosystem's in-flight tasks. Zero is unbounded.
+	MaxConcurrency int `yaml:"max_concurrency"`
+	// DailyBudget bounds the tasks admitted per UTC day. Zero is unbounded.
+	DailyBudget int `yaml:"daily_budget"`
+}
+
+// SchedulerConfig is the central scheduling configuration for all ecosystems.
+type SchedulerConfig struct {
+	// Capacity bounds the total in-flight tasks. Zero is unbounded.
+	Capacity int `yaml:"capacity"`
+	// DemandWindow is the period following a task for which the ecosystem's
+	// fair share remains reserved. Defaults to 5 minutes.
+	DemandWindow time.Duration `yaml:"demand_window"`
+	// Ecosystems configures the Quota for each ecosystem. Those unlisted are
+	// given a Weight of 1 with no further limits.
+	Ecosystems map[rebuild.Ecosystem]Quota `yaml:"ecosystems"`
+}
+
+// ParseSchedulerConfig reads a YAML-encoded SchedulerConfig.
+func ParseSchedulerConfig(r io.Reader) (*SchedulerConfig, error) {
+	var cfg SchedulerConfig
+	d := yaml.NewDecoder(r)
+	d.KnownFields(true)
+	if err := d.Decode(&cfg); err != nil {
+		return nil, errors.Wrap(err, "decoding scheduler config")
+	}
+	for e, q := range cfg.Ecosystems {
+		if q.Weight < 0 || q.MaxConcurrency < 0 || q.DailyBudget < 0 {
+			return nil, errors.Errorf("negative quota for %s", e)
+		}
+	}
+	return &cfg, nil
+}
+
+var (
+	// ErrConcurrencyLimit is returned when an ecosystem's MaxConcurrency is reached.
+	ErrConcurrencyLimit = errors.New("ecosystem concurrency limit reached")
+	// ErrBudgetExhausted is returned when an ecosystem's DailyBudget is spent.
+	ErrBudgetExhausted = errors.New("ecosystem daily budget exhausted")
+	// ErrOverShare is returned when admitting a task would consume capacity
+	// reserved for other ecosystems.
+	ErrOverShare = errors.New("ecosystem exceeds fair share of capacity")
+)
+
+// Scheduler admits tasks according to per-ecosystem quotas.
+//
+// Cloud Tasks dispatches tasks to handlers as fast as the queue allows. By
+// rejecting tasks beyond an ecosystem's quota, the handler causes them to be
+// retried with backoff, draining the queue in weighted fair order.
+//
+// Capacity is divided between ecosystems in proportion to their Weight. An
+// ecosystem may borrow beyond its share only from ecosystems without recent
+// demand so a burst from one ecosystem cannot starve the others.
+//
+// NOTE: State is held in memory so limits apply per instance. Services
+// enforcing quotas across all requests must be limited to a single instance.
+type Scheduler struct {
+	cfg SchedulerConfig
+	now func() time.Time
+
+	mu       sync.Mutex
+	day      time.Time
+	inflight map[rebuild.Ecosystem]int
+	used     map[rebuild.Ecosystem]int
+	demand   map[rebuild.Ecosystem]time.Time
+}
+
+// NewScheduler returns a Scheduler enforcing cfg.
+func NewScheduler(cfg SchedulerConfig) *Scheduler {
+	if cfg.DemandWindow == 0 {
+		cfg.DemandWindow = 5 * time.Minute
+	}
+	return &Scheduler{
+		cfg:      cfg,
+		now:      time.Now,
+		inflight: make(map[rebuild.Ecosystem]int),
+		used:     make(map[rebuild.Ecosystem]int),
+		demand:   make(map[rebuild.Ecosystem]time.Time),
+	}
+}
+
+func (s *Scheduler) quota(e rebuild.Ecosystem) Quota {
+	if q, ok := s.cfg.Ecosystems[e]; ok {
+		return q
+	}
+	return Quota{Weight: 1}
+}
+
+// Admit reserves capacity for a task from ecosystem e.
+//
+// The returned func must be called once the task completes.
+func (s *Scheduler) Admit(e rebuild.Ecosystem) (release func(), err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if day := now.UTC().Truncate(24 * time.Hour); !day.Equal(s.day) {
+		s.day = day
+		clear(s.used)
+	}
+	s.demand[e] = now
+	q := s.quota(e)
+	if q.DailyBudget > 0 && s.used[e] >= q.DailyBudget {
+		return nil, errors.Wrapf(ErrBudgetExhausted, "%s: %d/%d", e, s.used[e], q.DailyBudget)
+	}
+	if q.MaxConcurrency > 0 && s.inflight[e] >= q.MaxConcurrency {
+		return nil, errors.Wrapf(ErrConcurrencyLimit, "%s: %d/%d", e, s.inflight[e], q.MaxConcurrency)
+	}
+	if s.cfg.Capacity > 0 && !s.withinShare(e, now) {
+		return nil, errors.Wrapf(ErrOverShare, "%s: %d in flight", e, s.inflight[e])
+	}
+	s.inflight[e]++
+	s.used[e]++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.inflight[e]--
+		})
+	}, nil
+}
+
+// withinShare returns whether a task from e can be admitted without using
+// capacity reserved for other active ecosystems.
+func (s *Scheduler) withinShare(e rebuild.Ecosystem, now time.Time) bool {
+	var total, weights int
+	active := make(map[rebuild.Ecosystem]bool)
+	for x, n := range s.inflight {
+		total += n
+		if n > 0 {
+			active[x] = true
+		}
+	}
+	for x, t := range s.demand {
+		if now.Sub(t) < s.cfg.DemandWindow {
+			active[x] = true
+		}
+	}
+	if total >= s.cfg.Capacity {
+		return false
+	}
+	for x := range active {
+		weights += s.quota(x).Weight
+	}
+	if weights == 0 {
+		return true
+	}
+	share := func(x rebuild.Ecosystem) float64 {
+		return float64(s.cfg.Capacity*s.quota(x).Weight) / float64(weights)
+	}
+	if float64(s.inflight[e]) < share(e) {
+		return true
+	}
+	var reserved float64
+	for x := range active {
+		if x != e {
+			reserved += max(0, share(x)-float64(s.inflight[x]))
+		}
+	}
+	return float64(total)+reserved < float64(s.cfg.Capacity)
+}
@@ -0,0 +1,139 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package taskqueue
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/pkg/errors"
+)
+
+func TestParseSchedulerConfig(t *testing.T) {
+	cfg, err := ParseSchedulerConfig(strings.NewReader(`
+capacity: 100
+demand_window: 10m
+ecosystems:
+  npm:
+    weight: 2
+    daily_budget: 5000
+  debian:
+    weight: 1
+    max_concurrency: 10
+`))
+	if err != nil {
+		t.Fatalf("ParseSchedulerConfig() error = %v", err)
+	}
+	want := &SchedulerConfig{
+		Capacity:     100,
+		DemandWindow: 10 * time.Minute,
+		Ecosystems: map[rebuild.Ecosystem]Quota{
+			rebuild.NPM:    {Weight: 2, DailyBudget: 5000},
+			rebuild.Debian: {Weight: 1, MaxConcurrency: 10},
+		},
+	}
+	if diff := cmp.Diff(want, cfg); diff != "" {
+		t.Errorf("ParseSchedulerConfig() mismatch (-want +got):\n%s", diff)
+	}
+	if _, err := ParseSchedulerConfig(strings.NewReader("capacity: 1\nunknown: 2\n")); err == nil {
+		t.Error("ParseSchedulerConfig() with unknown field succeeded, want error")
+	}
+	if _, err := ParseSchedulerConfig(strings.NewReader("ecosystems: {npm: {weight: -1}}\n")); err == nil {
+		t.Error("ParseSchedulerConfig() with negative weight succeeded, want error")
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	newScheduler := func(cfg SchedulerConfig) *Scheduler {
+		s := NewScheduler(cfg)
+		s.now = func() time.Time { return now }
+		return s
+	}
+	admit := func(t *testing.T, s *Scheduler, e rebuild.Ecosystem, n int) []func() {
+		t.Helper()
+		var releases []func()
+		for i := 0; i < n; i++ {
+			release, err := s.Admit(e)
+			if err != nil {
+				t.Fatalf("Admit(%s) #%d error = %v", e, i, err)
+			}
+			releases = append(releases, release)
+		}
+		return releases
+	}
+	t.Run("concurrency", func(t *testing.T) {
+		s := newScheduler(SchedulerConfig{Ecosystems: map[rebuild.Ecosystem]Quota{rebuild.Maven: {Weight: 1, MaxConcurrency: 2}}})
+		releases := admit(t, s, rebuild.Maven, 2)
+		if _, err := s.Admit(rebuild.Maven); !errors.Is(err, ErrConcurrencyLimit) {
+			t.Fatalf("Admit() error = %v, want %v", err, ErrConcurrencyLimit)
+		}
+		releases[0]()
+		releases[0]() // Repeated release is a no-op.
+		admit(t, s, rebuild.Maven, 1)
+		if _, err := s.Admit(rebuild.Maven); !errors.Is(err, ErrConcurrencyLimit) {
+			t.Fatalf("Admit() error = %v, want %v", err, ErrConcurrencyLimit)
+		}
+	})
+	t.Run("budget", func(t *testing.T) {
+		s := newScheduler(SchedulerConfig{Ecosystems: map[rebuild.Ecosystem]Quota{rebuild.NPM: {Weight: 1, DailyBudget: 2}}})
+		for _, release := range admit(t, s, rebuild.NPM, 2) {
+			release()
+		}
+		if _, err := s.Admit(rebuild.NPM); !errors.Is(err, ErrBudgetExhausted) {
+			t.Fatalf("Admit() error = %v, want %v", err, ErrBudgetExhausted)
+		}
+		// Other ecosystems are unaffected.
+		admit(t, s, rebuild.PyPI, 3)
+		now = now.Add(24 * time.Hour)
+		admit(t, s, rebuild.NPM, 1)
+	})
+	t.Run("fair share", func(t *testing.T) {
+		s := newScheduler(SchedulerConfig{
+			Capacity: 10,
+			Ecosystems: map[rebuild.Ecosystem]Quota{
+				rebuild.NPM:    {Weight: 3},
+				rebuild.Debian: {Weight: 1},
+			},
+		})
+		// With no competing demand, npm may use all capacity.
+		npm := admit(t, s, rebuild.NPM, 10)
+		if _, err := s.Admit(rebuild.NPM); !errors.Is(err, ErrOverShare) {
+			t.Fatalf("Admit() error = %v, want %v", err, ErrOverShare)
+		}
+		// Debian's demand is registered but capacity is full.
+		if _, err := s.Admit(rebuild.Debian); !errors.Is(err, ErrOverShare) {
+			t.Fatalf("Admit() error = %v, want %v", err, ErrOverShare)
+		}
+		// Freed capacity is reserved for debian's share until it is used.
+		for _, release := range npm[:5] {
+			release()
+		}
+		admit(t, s, rebuild.NPM, 3)
+		if _, err := s.Admit(rebuild.NPM); !errors.Is(err, ErrOverShare) {
+			t.Fatalf("Admit() error = %v, want %v", err, ErrOverShare)
+		}
+		debian := admit(t, s, rebuild.Debian, 2)
+		// Once debian's tasks complete and its demand lapses, npm may borrow its capacity.
+		for _, release := range debian {
+			release()
+		}
+		now = now.Add(time.Hour)
+		admit(t, s, rebuild.NPM, 2)
+	})
+}
@@ -0,0 +1,108 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timewarp
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/elazarl/goproxy"
+	"github.com/pkg/errors"
+)
+
+// interceptHosts maps the registry hosts intercepted by the Interceptor to their platform.
+var interceptHosts = map[string]string{
+	"registry.npmjs.org":   "npm",
+	"registry.yarnpkg.com": "npm",
+	"pypi.org":             "pypi",
+}
+
+// proxyTime returns the time warp provided in the request's Proxy-Authorization header.
+//
+// The header is populated by clients configured with a proxy URL of the form:
+// http://<ignored>:<RFC3339>@<hostname>/
+func proxyTime(r *http.Request) (*time.Time, error) {
+	auth, ok := strings.CutPrefix(r.Header.Get("Proxy-Authorization"), "Basic ")
+	if !ok {
+		return nil, errors.New("no time set")
+	}
+	b, err := base64.StdEncoding.DecodeString(auth)
+	if err != nil {
+		return nil, errors.New("invalid proxy authorization")
+	}
+	_, ts, _ := strings.Cut(string(b), ":")
+	if unescaped, err := url.QueryUnescape(ts); err == nil {
+		ts = unescaped
+	}
+	return parseTime(ts)
+}
+
+func isRegistry(r *http.Request, _ *goproxy.ProxyCtx) bool {
+	_, ok := interceptHosts[r.URL.Hostname()]
+	return ok
+}
+
+// NewInterceptor returns an HTTP proxy that time-warps requests to known
+// registries, terminating TLS for those hosts using certificates issued by ca.
+// Traffic to all other hosts is forwarded unmodified.
+//
+// NOTE: Clients must trust ca. When used alongside the network proxy, providing
+// the network proxy's CA avoids installing an additional root in the build.
+func NewInterceptor(h Handler, ca *tls.Certificate) *goproxy.ProxyHttpServer {
+	p := goproxy.NewProxyHttpServer()
+	// Ignore pre-existing http(s) proxy env vars to avoid proxying to ourselves.
+	p.Tr = &http.Transport{}
+	p.ConnectDial = nil
+	mitm := &goproxy.ConnectAction{Action: goproxy.ConnectMitm, TLSConfig: goproxy.TLSConfigFromCA(ca)}
+	p.OnRequest(goproxy.ReqConditionFunc(isRegistry)).HandleConnectFunc(func(host string, ctx *goproxy.ProxyCtx) (*goproxy.ConnectAction, string) {
+		t, err := proxyTime(ctx.Req)
+		if err != nil {
+			log.Println("error", err.Error(), "[", host, "]")
+			return goproxy.RejectConnect, host
+		}
+		// NOTE: UserData is propagated to the requests made over the connection.
+		ctx.UserData = *t
+		return mitm, host
+	})
+	p.OnRequest(goproxy.ReqConditionFunc(isRegistry)).DoFunc(func(r *http.Request, ctx *goproxy.ProxyCtx) (*http.Request, *http.Response) {
+		t, ok := ctx.UserData.(time.Time)
+		if !ok {
+			// Plain HTTP requests are not preceded by a CONNECT.
+			pt, err := proxyTime(r)
+			if err != nil {
+				return r, goproxy.NewResponse(r, goproxy.ContentTypeText, http.StatusProxyAuthRequired, err.Error())
+			}
+			t = *pt
+		}
+		r.Header.Del("Proxy-Authorization")
+		// Registries are served from the default port of the scheme.
+		r.URL.Host = r.URL.Hostname()
+		rec := httptest.NewRecorder()
+		h.warp(rec, r, interceptHosts[r.URL.Hostname()], t)
+		resp := rec.Result()
+		resp.Request = r
+		// NOTE: Warped responses differ in length from those of the registry.
+		resp.ContentLength = int64(rec.Body.Len())
+		resp.Header.Del("Content-Length")
+		return r, resp
+	})
+	return p
+}
@@ -0,0 +1,104 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timewarp
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/internal/httpx/httpxtest"
+	"github.com/google/oss-rebuild/pkg/proxy/cert"
+)
+
+func TestInterceptor(t *testing.T) {
+	ca := cert.GenerateCA()
+	mock := &httpxtest.MockClient{
+		Calls: []httpxtest.Call{
+			{
+				Method: "GET",
+				URL:    "https://registry.yarnpkg.com/some-package",
+				Response: &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"Content-Type": []string{"application/json"}},
+					Body: io.NopCloser(bytes.NewBufferString(`{
+						"time": {
+							"created": "2021-01-01T00:00:00Z",
+							"modified": "2023-01-01T00:00:00Z",
+							"1.0.0": "2021-06-01T00:00:00Z",
+							"2.0.0": "2022-06-01T00:00:00Z"
+						},
+						"versions": {
+							"1.0.0": {"version": "1.0.0"},
+							"2.0.0": {"version": "2.0.0"}
+						}
+					}`)),
+				},
+			},
+		},
+		URLValidator: func(expected, actual string) {
+			if diff := cmp.Diff(expected, actual); diff != "" {
+				t.Errorf("URL mismatch (-want +got):\n%s", diff)
+			}
+		},
+	}
+	srv := httptest.NewServer(NewInterceptor(Handler{Client: mock}, ca))
+	defer srv.Close()
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Leaf)
+	newClient := func(proxyUser *url.Userinfo) *http.Client {
+		proxyURL, _ := url.Parse(srv.URL)
+		proxyURL.User = proxyUser
+		return &http.Client{Transport: &http.Transport{
+			Proxy:           http.ProxyURL(proxyURL),
+			TLSClientConfig: &tls.Config{RootCAs: pool},
+		}}
+	}
+	t.Run("warped", func(t *testing.T) {
+		resp, err := newClient(url.UserPassword("_", "2022-01-01T00:00:00Z")).Get("https://registry.yarnpkg.com/some-package")
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Get() status = %d, want 200", resp.StatusCode)
+		}
+		var got map[string]any
+		if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+			t.Fatalf("Decode() error = %v", err)
+		}
+		if diff := cmp.Diff(map[string]any{"latest": "1.0.0"}, got["dist-tags"]); diff != "" {
+			t.Errorf("dist-tags mismatch (-want +got):\n%s", diff)
+		}
+		if _, ok := got["versions"].(map[string]any)["2.0.0"]; ok {
+			t.Errorf("versions contains 2.0.0 published after time warp")
+		}
+	})
+	t.Run("no time", func(t *testing.T) {
+		if _, err := newClient(nil).Get("https://registry.npmjs.org/some-package"); err == nil {
+			t.Errorf("Get() without time warp succeeded, want error")
+		}
+		if mock.CallCount() != 1 {
+			t.Errorf("CallCount() = %d, want 1", mock.CallCount())
+		}
+	})
+}
@@ -21,6 +21,11 @@
 // When run on a local port, an example invocation for NPM would be:
 //
 //	npm --registry "http://npm:2015-05-13T10:31:26.370Z@localhost:8081" install
+//
+// For clients with hardcoded https registry URLs, the Interceptor instead
+// serves as an HTTPS proxy that intercepts requests to known registries:
+//
+//	HTTPS_PROXY="http://_:2015-05-13T10:31:26.370Z@localhost:8082" yarn install
 package timewarp
 
 import (
@@ -97,6 +102,12 @@ func (h Handler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 		http.Error(rw, err.Error(), http.StatusBadRequest)
 		return
 	}
+	h.warp(rw, r, platform, *t)
+}
+
+// warp serves the upstream registry response to r, filtered to reflect the
+// state of the platform's registry at t.
+func (h Handler) warp(rw http.ResponseWriter, r *http.Request, platform string, t time.Time) {
 	// Create a new request based on the provided method, path, and body but
 	// directed at the upstream registry.
 	nr, _ := http.NewRequest(r.Method, r.URL.String(), r.Body)
@@ -166,7 +177,7 @@ func (h Handler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 		// Reference: https://github.com/npm/registry/blob/master/docs/REGISTRY-API.md
 		// TODO: Find a better (path-based?) heuristic for identifying package API.
 		if obj["time"] != nil {
-			if err := timeWarpNPMPackageRequest(obj, *t); err != nil {
+			if err := timeWarpNPMPackageRequest(obj, t); err != nil {
 				err = errors.Wrap(err, "warping response")
 				log.Println("error", err.Error(), "[", nr.URL.String(), "]")
 				http.Error(rw, err.Error(), http.StatusBadGateway)
@@ -179,7 +190,7 @@ func (h Handler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 		// Reference: https://warehouse.pypa.io/api-reference/json.html
 		// TODO: Find a better (path-based?) heuristic for identifying project API.
 		if obj["releases"] != nil {
-			if err := timeWarpPyPIProjectRequest(h.Client, obj, *t); err != nil {
+			if err := timeWarpPyPIProjectRequest(h.Client, obj, t); err != nil {
 				err = errors.Wrap(err, "warping response")
 				log.Println("error", err.Error(), "[", nr.URL.String(), "]")
 				http.Error(rw, errors.Wrap(err, "warping response").Error(), http.StatusBadGateway)
@@ -0,0 +1,117 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tracing instruments the rebuild request path with OpenTelemetry spans.
+//
+// Trace context is propagated between services using W3C Trace Context headers
+// and to Cloud Build using build tags. Spans are exported over OTLP which may
+// be sent to Cloud Trace directly or via a collector.
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/google/oss-rebuild"
+
+// Setup configures the global tracer provider to export spans to endpoint.
+//
+// If endpoint is empty, spans are not exported but trace context continues to
+// be propagated. The returned func flushes pending spans and should be called
+// on shutdown.
+func Setup(ctx context.Context, service, endpoint string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	if endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	exp, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, errors.Wrap(err, "creating OTLP exporter")
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(service)))
+	if err != nil {
+		return nil, errors.Wrap(err, "creating resource")
+	}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exp), sdktrace.WithResource(res))
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+// Start creates a span as a child of any span in ctx.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records err, if any, on the span and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Handler extracts propagated trace context and creates a server span for each request.
+func Handler(h http.Handler) http.Handler {
+	return otelhttp.NewHandler(h, "", otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+		return r.Method + " " + r.URL.Path
+	}))
+}
+
+// Client returns a copy of c which propagates trace context on outbound requests.
+func Client(c *http.Client) *http.Client {
+	instrumented := *c
+	instrumented.Transport = otelhttp.NewTransport(c.Transport)
+	return &instrumented
+}
+
+// buildTagPrefix identifies the Cloud Build tag carrying the traceparent.
+const buildTagPrefix = "traceparent-"
+
+// BuildTags returns Cloud Build tags carrying the trace context in ctx.
+//
+// NOTE: Build tags are restricted to [\w.-] so the traceparent header value,
+// which contains only hex digits and hyphens, can be used verbatim.
+func BuildTags(ctx context.Context) []string {
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	if tp := carrier.Get("traceparent"); tp != "" {
+		return []string{buildTagPrefix + tp}
+	}
+	return nil
+}
+
+// FromBuildTags returns a context carrying the trace context encoded in tags, if any.
+func FromBuildTags(ctx context.Context, tags []string) context.Context {
+	for _, tag := range tags {
+		if tp, ok := strings.CutPrefix(tag, buildTagPrefix); ok {
+			return propagation.TraceContext{}.Extract(ctx, propagation.MapCarrier{"traceparent": tp})
+		}
+	}
+	return ctx
+}
@@ -0,0 +1,98 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func setupRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	sr := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+	if _, err := Setup(context.Background(), "test", ""); err != nil {
+		t.Fatal(err)
+	}
+	return sr
+}
+
+func TestBuildTags(t *testing.T) {
+	setupRecorder(t)
+	if tags := BuildTags(context.Background()); tags != nil {
+		t.Errorf("BuildTags() without span = %v, want nil", tags)
+	}
+	ctx, span := Start(context.Background(), "parent")
+	defer span.End()
+	tags := BuildTags(ctx)
+	if len(tags) != 1 {
+		t.Fatalf("BuildTags() = %v, want single tag", tags)
+	}
+	// See https://cloud.google.com/build/docs/api/reference/rest/v1/projects.builds#Build.FIELDS.tags
+	if !regexp.MustCompile(`^[\w][\w.-]{0,127}$`).MatchString(tags[0]) {
+		t.Errorf("BuildTags() = %q, not a valid build tag", tags[0])
+	}
+	got := trace.SpanContextFromContext(FromBuildTags(context.Background(), append([]string{"unrelated"}, tags...)))
+	if got.TraceID() != span.SpanContext().TraceID() || got.SpanID() != span.SpanContext().SpanID() {
+		t.Errorf("FromBuildTags() = %v, want %v", got, span.SpanContext())
+	}
+}
+
+func TestPropagation(t *testing.T) {
+	sr := setupRecorder(t)
+	srv := httptest.NewServer(Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, span := Start(r.Context(), "handler")
+		End(span, errors.New("failed"))
+	})))
+	defer srv.Close()
+	ctx, span := Start(context.Background(), "client")
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/infer", nil)
+	resp, err := Client(srv.Client()).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	span.End()
+	byName := make(map[string]sdktrace.ReadOnlySpan)
+	for _, s := range sr.Ended() {
+		byName[s.Name()] = s
+	}
+	server, ok := byName["GET /infer"]
+	if !ok {
+		t.Fatalf("missing server span: %v", byName)
+	}
+	if server.SpanContext().TraceID() != span.SpanContext().TraceID() {
+		t.Errorf("server span trace = %v, want %v", server.SpanContext().TraceID(), span.SpanContext().TraceID())
+	}
+	handler := byName["handler"]
+	if handler == nil || handler.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Fatalf("handler span not parented by server span")
+	}
+	if handler.Status().Code != codes.Error {
+		t.Errorf("handler span status = %v, want %v", handler.Status().Code, codes.Error)
+	}
+}
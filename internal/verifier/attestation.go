@@ -21,6 +21,7 @@ import (
 	"path"
 	"strings"
 
+	"github.com/google/oss-rebuild/pkg/archive"
 	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
 	"github.com/google/oss-rebuild/pkg/rebuild/schema"
 	"github.com/in-toto/in-toto-golang/in_toto"
@@ -34,10 +35,27 @@ const (
 	RebuildBuildType = "https://docs.oss-rebuild.dev/builds/Rebuild@v0.1"
 	// ArtifactEquivalenceBuildType is the SLSA build type used for artifact equivalence attestations.
 	ArtifactEquivalenceBuildType = "https://docs.oss-rebuild.dev/builds/ArtifactEquivalence@v0.1"
+	// StabilizationBuildType is the SLSA build type used for attestations of the stabilizations applied during equivalence checking.
+	StabilizationBuildType = "https://docs.oss-rebuild.dev/builds/Stabilization@v0.1"
 )
 
+// BuildObservations are records of a build's observed behavior to be linked from its attestation.
+type BuildObservations struct {
+	// SyscallLog is the syscall monitor log collected during the build, if any.
+	SyscallLog *ArtifactSummary
+	// Dependencies is the resolved dependency closure reported by the build, if any.
+	Dependencies []rebuild.Dependency
+	// SBOM is the SBOM of the rebuild container, if any.
+	SBOM *ArtifactSummary
+}
+
+// scriptPolicy is implemented by strategies that control the execution of package lifecycle scripts.
+type scriptPolicy interface {
+	ScriptPolicy() string
+}
+
 // CreateAttestations creates the SLSA attestations associated with a rebuild.
-func CreateAttestations(ctx context.Context, input rebuild.Input, finalStrategy rebuild.Strategy, id string, rb, up ArtifactSummary, metadata rebuild.AssetStore, buildDef rebuild.Location) (equivalence, build *in_toto.ProvenanceStatementSLSA1, err error) {
+func CreateAttestations(ctx context.Context, input rebuild.Input, finalStrategy rebuild.Strategy, id string, rb, up ArtifactSummary, metadata rebuild.AssetStore, buildDef rebuild.Location, obs BuildObservations) (equivalence, build *in_toto.ProvenanceStatementSLSA1, err error) {
 	t, manualStrategy := input.Target, input.Strategy
 	var dockerfile []byte
 	{
@@ -96,8 +114,21 @@ func CreateAttestations(ctx context.Context, input rebuild.Input, finalStrategy
 				BuildMetadata: slsa1.BuildMetadata{
 					InvocationID: id,
 				},
+				// NOTE: Stabilized digests of both artifacts are published so the
+				// equivalence claim can be independently recomputed. The normalized
+				// entry is retained for compatibility with existing consumers.
 				Byproducts: []slsa1.ResourceDescriptor{
 					{Name: publicNormalizedURI, Digest: makeDigestSet(up.StabilizedHash...)},
+					{
+						Name:        path.Join("stabilized", "rebuild", buildInfo.Target.Artifact),
+						Digest:      makeDigestSet(rb.StabilizedHash...),
+						Annotations: map[string]any{"source": publicRebuildURI},
+					},
+					{
+						Name:        path.Join("stabilized", "upstream", buildInfo.Target.Artifact),
+						Digest:      makeDigestSet(up.StabilizedHash...),
+						Annotations: map[string]any{"source": up.URI},
+					},
 				},
 			},
 		},
@@ -110,6 +141,13 @@ func CreateAttestations(ctx context.Context, input rebuild.Input, finalStrategy
 	if inst.Location.Ref != "" {
 		rd = append(rd, slsa1.ResourceDescriptor{Name: "git+" + inst.Location.Repo, Digest: gitDigestSet(inst.Location)})
 	}
+	if a := inst.SourceArchive; a != nil {
+		rd = append(rd, slsa1.ResourceDescriptor{
+			Name:        a.URL,
+			Digest:      common.DigestSet{"sha256": a.SHA256},
+			Annotations: map[string]any{"caveat": rebuild.SourceArchiveCaveat},
+		})
+	}
 	for n, s := range buildInfo.BuildImages {
 		if !strings.HasPrefix(s, "sha256:") {
 			return nil, nil, errors.New("buildInfo.BuildImages contains non-sha256 digest")
@@ -136,6 +174,17 @@ func CreateAttestations(ctx context.Context, input rebuild.Input, finalStrategy
 		"version":   t.Version,
 		"artifact":  t.Artifact,
 	}
+	// NOTE: Lifecycle scripts execute arbitrary package code so the policy is surfaced explicitly.
+	if sp, ok := finalStrategy.(scriptPolicy); ok {
+		externalParams["lifecycleScripts"] = sp.ScriptPolicy()
+	}
+	if buildInfo.Hermetic {
+		externalParams["hermetic"] = true
+	}
+	// NOTE: Source archive rebuilds do not demonstrate correspondence with the source repo.
+	if inst.SourceArchive != nil {
+		externalParams["caveat"] = rebuild.SourceArchiveCaveat
+	}
 	// Only add manual strategy field if it was used.
 	if manualStrategy != nil {
 		rawStrategy, err := json.Marshal(schema.NewStrategyOneOf(manualStrategy))
@@ -149,6 +198,33 @@ func CreateAttestations(ctx context.Context, input rebuild.Input, finalStrategy
 			"path":       buildDef.Dir,
 		}
 	}
+	byproducts := []slsa1.ResourceDescriptor{
+		// NOTE: We use "build" externally instead of "strategy".
+		{Name: "build.json", Content: finalStrategyBytes},
+		{Name: "Dockerfile", Content: dockerfile},
+		{Name: "steps.json", Content: stepsBytes},
+	}
+	if obs.SyscallLog != nil {
+		// NOTE: The log itself is too large to inline so we link to its storage location.
+		byproducts = append(byproducts, slsa1.ResourceDescriptor{Name: string(rebuild.TetragonLogAsset), URI: obs.SyscallLog.URI, Digest: makeDigestSet(obs.SyscallLog.Hash...)})
+	}
+	if obs.SBOM != nil {
+		byproducts = append(byproducts, slsa1.ResourceDescriptor{Name: string(rebuild.SBOMAsset), URI: obs.SBOM.URI, Digest: makeDigestSet(obs.SBOM.Hash...), MediaType: "application/spdx+json"})
+	}
+	if len(buildInfo.LintFindings) > 0 {
+		lintBytes, err := json.Marshal(buildInfo.LintFindings)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "marshalling lint findings")
+		}
+		byproducts = append(byproducts, slsa1.ResourceDescriptor{Name: "lint.json", Content: lintBytes})
+	}
+	if len(obs.Dependencies) > 0 {
+		depsBytes, err := json.Marshal(obs.Dependencies)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "marshalling dependencies")
+		}
+		byproducts = append(byproducts, slsa1.ResourceDescriptor{Name: "dependencies.json", Content: depsBytes})
+	}
 	stmt := &in_toto.ProvenanceStatementSLSA1{
 		StatementHeader: in_toto.StatementHeader{
 			Type:          in_toto.StatementInTotoV1,
@@ -169,18 +245,63 @@ func CreateAttestations(ctx context.Context, input rebuild.Input, finalStrategy
 					StartedOn:    &buildInfo.BuildStart,
 					FinishedOn:   &buildInfo.BuildEnd,
 				},
-				Byproducts: []slsa1.ResourceDescriptor{
-					// NOTE: We use "build" externally instead of "strategy".
-					{Name: "build.json", Content: finalStrategyBytes},
-					{Name: "Dockerfile", Content: dockerfile},
-					{Name: "steps.json", Content: stepsBytes},
-				},
+				Byproducts: byproducts,
 			},
 		},
 	}
 	return eqStmt, stmt, nil
 }
 
+// StabilizationRecord enumerates the stabilizers that modified each entry of the compared artifacts.
+type StabilizationRecord struct {
+	Rebuild  archive.StabilizationLog `json:"rebuild"`
+	Upstream archive.StabilizationLog `json:"upstream"`
+}
+
+// CreateStabilizationAttestation creates an attestation enumerating the
+// stabilizations on which the equivalence of a rebuild depended.
+//
+// NOTE: This is distinct from the equivalence attestation so downstream policy
+// can evaluate the stabilizations applied independent of the equivalence claim.
+func CreateStabilizationAttestation(t rebuild.Target, id string, rb, up ArtifactSummary) (*in_toto.ProvenanceStatementSLSA1, error) {
+	record, err := json.Marshal(StabilizationRecord{Rebuild: rb.Stabilizations, Upstream: up.Stabilizations})
+	if err != nil {
+		return nil, errors.Wrap(err, "marshalling stabilizations")
+	}
+	publicRebuildURI := path.Join("rebuild", t.Artifact)
+	return &in_toto.ProvenanceStatementSLSA1{
+		StatementHeader: in_toto.StatementHeader{
+			Type:          in_toto.StatementInTotoV1,
+			Subject:       []in_toto.Subject{{Name: t.Artifact, Digest: makeDigestSet(up.Hash...)}},
+			PredicateType: slsa1.PredicateSLSAProvenance,
+		},
+		Predicate: slsa1.ProvenancePredicate{
+			BuildDefinition: slsa1.ProvenanceBuildDefinition{
+				BuildType: StabilizationBuildType,
+				ExternalParameters: map[string]string{
+					"candidate": publicRebuildURI,
+					"target":    up.URI,
+				},
+				ResolvedDependencies: []slsa1.ResourceDescriptor{
+					{Name: publicRebuildURI, Digest: makeDigestSet(rb.Hash...)},
+					{Name: up.URI, Digest: makeDigestSet(up.Hash...)},
+				},
+			},
+			RunDetails: slsa1.ProvenanceRunDetails{
+				Builder: slsa1.Builder{
+					ID: "https://docs.oss-rebuild.dev/hosts/Google",
+				},
+				BuildMetadata: slsa1.BuildMetadata{
+					InvocationID: id,
+				},
+				Byproducts: []slsa1.ResourceDescriptor{
+					{Name: "stabilizations.json", Content: record},
+				},
+			},
+		},
+	}, nil
+}
+
 func checkClose(closer io.Closer) {
 	if err := closer.Close(); err != nil {
 		panic(errors.Wrap(err, "deferred close failed"))
@@ -25,6 +25,8 @@ import (
 	"github.com/go-git/go-billy/v5/memfs"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/oss-rebuild/internal/hashext"
+	"github.com/google/oss-rebuild/pkg/archive"
+	"github.com/google/oss-rebuild/pkg/rebuild/npm"
 	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
 	"google.golang.org/api/cloudbuild/v1"
 )
@@ -78,7 +80,7 @@ func TestCreateAttestations(t *testing.T) {
 		strategy := &rebuild.ManualStrategy{Location: inputStrategy.Location, Deps: "echo deps", Build: "echo build", SystemDeps: []string{"git"}, OutputPath: "foo/bar"}
 		input := rebuild.Input{Target: target, Strategy: inputStrategy}
 		loc := rebuild.Location{Repo: "https://github.com/google/oss-rebuild", Ref: "b33eec7134eff8a16cb902b80e434de58bf37e2c", Dir: "definitions/cratesio/bytes/1.0.0/bytes-1.0.0.crate/build.yaml"}
-		eqStmt, buildStmt, err := CreateAttestations(ctx, input, strategy, "test-id", rbSummary, upSummary, metadata, loc)
+		eqStmt, buildStmt, err := CreateAttestations(ctx, input, strategy, "test-id", rbSummary, upSummary, metadata, loc, BuildObservations{})
 		if err != nil {
 			t.Fatalf("Unexpected error: %v", err)
 		}
@@ -130,6 +132,24 @@ func TestCreateAttestations(t *testing.T) {
             "sha256": "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
           },
           "name": "normalized/bytes-1.0.0.crate"
+        },
+        {
+          "digest": {
+            "sha256": "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+          },
+          "name": "stabilized/rebuild/bytes-1.0.0.crate",
+          "annotations": {
+            "source": "rebuild/bytes-1.0.0.crate"
+          }
+        },
+        {
+          "digest": {
+            "sha256": "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+          },
+          "name": "stabilized/upstream/bytes-1.0.0.crate",
+          "annotations": {
+            "source": "https://up.stream/bytes-1.0.0.crate"
+          }
         }
       ]
     }
@@ -214,4 +234,235 @@ func TestCreateAttestations(t *testing.T) {
 			t.Fatalf("Unexpected buildStmt: %v", diff)
 		}
 	})
+
+	t.Run("WithSyscallLog", func(t *testing.T) {
+		metadata := rebuild.NewFilesystemAssetStore(memfs.New())
+		{
+			w := must(metadata.Writer(ctx, rebuild.DockerfileAsset.For(target)))
+			must(w.Write([]byte("FROM alpine:latest")))
+			orDie(w.Close())
+		}
+		{
+			w := must(metadata.Writer(ctx, rebuild.BuildInfoAsset.For(target)))
+			must(w.Write(must(json.Marshal(buildInfo))))
+			orDie(w.Close())
+		}
+		strategy := &rebuild.ManualStrategy{Location: rebuild.Location{Repo: "http://github.com/foo/bar", Ref: "0beec7b5ea3f0fdbc95d0dd47f3c5bc275da8a33"}, OutputPath: "foo/bar"}
+		input := rebuild.Input{Target: target}
+		obs := BuildObservations{SyscallLog: &ArtifactSummary{URI: "gs://metadata.bucket/tetragon.jsonl", Hash: hashext.NewMultiHash(crypto.SHA256)}}
+		_, buildStmt, err := CreateAttestations(ctx, input, strategy, "test-id", rbSummary, upSummary, metadata, rebuild.Location{}, obs)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		byproducts := buildStmt.Predicate.RunDetails.Byproducts
+		got := byproducts[len(byproducts)-1]
+		if got.Name != "tetragon.jsonl" || got.URI != "gs://metadata.bucket/tetragon.jsonl" {
+			t.Errorf("Unexpected syscall log byproduct: %+v", got)
+		}
+		if got.Digest["sha256"] != "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855" {
+			t.Errorf("Unexpected syscall log digest: %v", got.Digest)
+		}
+	})
+
+	t.Run("WithSBOM", func(t *testing.T) {
+		metadata := rebuild.NewFilesystemAssetStore(memfs.New())
+		{
+			w := must(metadata.Writer(ctx, rebuild.DockerfileAsset.For(target)))
+			must(w.Write([]byte("FROM alpine:latest")))
+			orDie(w.Close())
+		}
+		{
+			w := must(metadata.Writer(ctx, rebuild.BuildInfoAsset.For(target)))
+			must(w.Write(must(json.Marshal(buildInfo))))
+			orDie(w.Close())
+		}
+		strategy := &rebuild.ManualStrategy{Location: rebuild.Location{Repo: "http://github.com/foo/bar", Ref: "0beec7b5ea3f0fdbc95d0dd47f3c5bc275da8a33"}, OutputPath: "foo/bar"}
+		input := rebuild.Input{Target: target}
+		obs := BuildObservations{SBOM: &ArtifactSummary{URI: "gs://metadata.bucket/sbom.spdx.json", Hash: hashext.NewMultiHash(crypto.SHA256)}}
+		_, buildStmt, err := CreateAttestations(ctx, input, strategy, "test-id", rbSummary, upSummary, metadata, rebuild.Location{}, obs)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		byproducts := buildStmt.Predicate.RunDetails.Byproducts
+		got := byproducts[len(byproducts)-1]
+		if got.Name != "sbom.spdx.json" || got.URI != "gs://metadata.bucket/sbom.spdx.json" || got.MediaType != "application/spdx+json" {
+			t.Errorf("Unexpected SBOM byproduct: %+v", got)
+		}
+		if got.Digest["sha256"] != "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855" {
+			t.Errorf("Unexpected SBOM digest: %v", got.Digest)
+		}
+	})
+
+	t.Run("WithScriptPolicy", func(t *testing.T) {
+		metadata := rebuild.NewFilesystemAssetStore(memfs.New())
+		{
+			w := must(metadata.Writer(ctx, rebuild.DockerfileAsset.For(target)))
+			must(w.Write([]byte("FROM alpine:latest")))
+			orDie(w.Close())
+		}
+		{
+			w := must(metadata.Writer(ctx, rebuild.BuildInfoAsset.For(target)))
+			must(w.Write(must(json.Marshal(buildInfo))))
+			orDie(w.Close())
+		}
+		strategy := &npm.NPMPackBuild{
+			Location:         rebuild.Location{Repo: "http://github.com/foo/bar", Ref: "0beec7b5ea3f0fdbc95d0dd47f3c5bc275da8a33", Dir: "."},
+			NPMVersion:       "10.0.0",
+			LifecycleScripts: &npm.ScriptPolicy{Ignore: true, Allow: []string{"prepare"}},
+		}
+		input := rebuild.Input{Target: target}
+		_, buildStmt, err := CreateAttestations(ctx, input, strategy, "test-id", rbSummary, upSummary, metadata, rebuild.Location{}, BuildObservations{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		params := buildStmt.Predicate.BuildDefinition.ExternalParameters.(map[string]any)
+		if got := params["lifecycleScripts"]; got != "prepare" {
+			t.Errorf("Unexpected lifecycleScripts: %v", got)
+		}
+	})
+
+	t.Run("WithDependencies", func(t *testing.T) {
+		metadata := rebuild.NewFilesystemAssetStore(memfs.New())
+		{
+			w := must(metadata.Writer(ctx, rebuild.DockerfileAsset.For(target)))
+			must(w.Write([]byte("FROM alpine:latest")))
+			orDie(w.Close())
+		}
+		{
+			w := must(metadata.Writer(ctx, rebuild.BuildInfoAsset.For(target)))
+			must(w.Write(must(json.Marshal(buildInfo))))
+			orDie(w.Close())
+		}
+		strategy := &npm.NPMPackBuild{
+			Location:   rebuild.Location{Repo: "http://github.com/foo/bar", Ref: "0beec7b5ea3f0fdbc95d0dd47f3c5bc275da8a33", Dir: "."},
+			NPMVersion: "10.0.0",
+		}
+		deps := []rebuild.Dependency{{Name: "lodash", Version: "4.17.21", URI: "https://registry.npmjs.org/lodash/-/lodash-4.17.21.tgz", Digest: map[string]string{"sha1": "679591c564c3bffaae8454cf0b3df370c3d6911c"}}}
+		input := rebuild.Input{Target: target}
+		_, buildStmt, err := CreateAttestations(ctx, input, strategy, "test-id", rbSummary, upSummary, metadata, rebuild.Location{}, BuildObservations{Dependencies: deps})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		var found bool
+		for _, bp := range buildStmt.Predicate.RunDetails.Byproducts {
+			if bp.Name != "dependencies.json" {
+				continue
+			}
+			found = true
+			var got []rebuild.Dependency
+			orDie(json.Unmarshal(bp.Content, &got))
+			if diff := cmp.Diff(deps, got); diff != "" {
+				t.Errorf("Unexpected dependencies (-want +got):\n%s", diff)
+			}
+		}
+		if !found {
+			t.Error("Missing dependencies.json byproduct")
+		}
+	})
+
+	t.Run("WithLintFindings", func(t *testing.T) {
+		metadata := rebuild.NewFilesystemAssetStore(memfs.New())
+		{
+			w := must(metadata.Writer(ctx, rebuild.DockerfileAsset.For(target)))
+			must(w.Write([]byte("FROM alpine:latest")))
+			orDie(w.Close())
+		}
+		findings := rebuild.LintFindings{{Rule: "latest-tag", Severity: rebuild.LintWarning, Phase: "container", Message: "image is not pinned to a version: alpine:latest"}}
+		{
+			bi := *buildInfo
+			bi.LintFindings = findings
+			w := must(metadata.Writer(ctx, rebuild.BuildInfoAsset.For(target)))
+			must(w.Write(must(json.Marshal(bi))))
+			orDie(w.Close())
+		}
+		strategy := &npm.NPMPackBuild{
+			Location:   rebuild.Location{Repo: "http://github.com/foo/bar", Ref: "0beec7b5ea3f0fdbc95d0dd47f3c5bc275da8a33", Dir: "."},
+			NPMVersion: "10.0.0",
+		}
+		input := rebuild.Input{Target: target}
+		_, buildStmt, err := CreateAttestations(ctx, input, strategy, "test-id", rbSummary, upSummary, metadata, rebuild.Location{}, BuildObservations{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		var found bool
+		for _, bp := range buildStmt.Predicate.RunDetails.Byproducts {
+			if bp.Name != "lint.json" {
+				continue
+			}
+			found = true
+			var got rebuild.LintFindings
+			orDie(json.Unmarshal(bp.Content, &got))
+			if diff := cmp.Diff(findings, got); diff != "" {
+				t.Errorf("Unexpected lint findings (-want +got):\n%s", diff)
+			}
+		}
+		if !found {
+			t.Error("Missing lint.json byproduct")
+		}
+	})
+
+	t.Run("WithSourceArchive", func(t *testing.T) {
+		metadata := rebuild.NewFilesystemAssetStore(memfs.New())
+		{
+			w := must(metadata.Writer(ctx, rebuild.DockerfileAsset.For(target)))
+			must(w.Write([]byte("FROM alpine:latest")))
+			orDie(w.Close())
+		}
+		{
+			w := must(metadata.Writer(ctx, rebuild.BuildInfoAsset.For(target)))
+			must(w.Write(must(json.Marshal(buildInfo))))
+			orDie(w.Close())
+		}
+		strategy := &npm.NPMPackBuild{
+			Location:      rebuild.Location{Dir: "."},
+			NPMVersion:    "10.0.0",
+			SourceArchive: &rebuild.SourceArchive{URL: "https://registry.npmjs.org/foo/-/foo-1.0.0.tgz", SHA256: "deadbeef"},
+		}
+		input := rebuild.Input{Target: target}
+		_, buildStmt, err := CreateAttestations(ctx, input, strategy, "test-id", rbSummary, upSummary, metadata, rebuild.Location{}, BuildObservations{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		params := buildStmt.Predicate.BuildDefinition.ExternalParameters.(map[string]any)
+		if got := params["caveat"]; got != rebuild.SourceArchiveCaveat {
+			t.Errorf("Unexpected caveat: %v", got)
+		}
+		rd := buildStmt.Predicate.BuildDefinition.ResolvedDependencies
+		if len(rd) == 0 || rd[0].Name != strategy.SourceArchive.URL || rd[0].Digest["sha256"] != "deadbeef" || rd[0].Annotations["caveat"] != rebuild.SourceArchiveCaveat {
+			t.Errorf("Unexpected resolvedDependencies: %+v", rd)
+		}
+	})
+}
+
+func TestCreateStabilizationAttestation(t *testing.T) {
+	target := rebuild.Target{Ecosystem: rebuild.NPM, Package: "foo", Version: "1.0.0", Artifact: "foo-1.0.0.tgz"}
+	rb := ArtifactSummary{
+		URI:            "gs://rebuild.bucket/foo-1.0.0.tgz",
+		Hash:           hashext.NewMultiHash(crypto.SHA256),
+		Stabilizations: archive.StabilizationLog{"package/index.js": {"tar-time"}},
+	}
+	up := ArtifactSummary{
+		URI:            "https://up.stream/foo-1.0.0.tgz",
+		Hash:           hashext.NewMultiHash(crypto.SHA256),
+		Stabilizations: archive.StabilizationLog{archive.ArchiveScope: {"gzip-compression"}, "package/index.js": {"tar-time", "tar-build-paths"}},
+	}
+	stmt, err := CreateStabilizationAttestation(target, "test-id", rb, up)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if stmt.Predicate.BuildDefinition.BuildType != StabilizationBuildType {
+		t.Errorf("Unexpected build type: %s", stmt.Predicate.BuildDefinition.BuildType)
+	}
+	if stmt.Subject[0].Name != "foo-1.0.0.tgz" {
+		t.Errorf("Unexpected subject: %+v", stmt.Subject)
+	}
+	byproducts := stmt.Predicate.RunDetails.Byproducts
+	if len(byproducts) != 1 || byproducts[0].Name != "stabilizations.json" {
+		t.Fatalf("Unexpected byproducts: %+v", byproducts)
+	}
+	var got StabilizationRecord
+	orDie(json.Unmarshal(byproducts[0].Content, &got))
+	if diff := cmp.Diff(StabilizationRecord{Rebuild: rb.Stabilizations, Upstream: up.Stabilizations}, got); diff != "" {
+		t.Errorf("Unexpected stabilizations (-want +got):\n%s", diff)
+	}
 }
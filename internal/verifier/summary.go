@@ -32,12 +32,14 @@ type ArtifactSummary struct {
 	URI            string
 	Hash           hashext.MultiHash
 	StabilizedHash hashext.MultiHash
+	// Stabilizations records the stabilizers that modified each entry.
+	Stabilizations archive.StabilizationLog
 }
 
 // SummarizeArtifacts fetches and summarizes the rebuild and upstream artifacts.
 func SummarizeArtifacts(ctx context.Context, metadata rebuild.LocatableAssetStore, t rebuild.Target, upstreamURI string, hashes []crypto.Hash) (rb, up ArtifactSummary, err error) {
-	rb = ArtifactSummary{Hash: hashext.NewMultiHash(hashes...), StabilizedHash: hashext.NewMultiHash(hashes...)}
-	up = ArtifactSummary{Hash: hashext.NewMultiHash(hashes...), StabilizedHash: hashext.NewMultiHash(hashes...), URI: upstreamURI}
+	rb = ArtifactSummary{Hash: hashext.NewMultiHash(hashes...), StabilizedHash: hashext.NewMultiHash(hashes...), Stabilizations: archive.StabilizationLog{}}
+	up = ArtifactSummary{Hash: hashext.NewMultiHash(hashes...), StabilizedHash: hashext.NewMultiHash(hashes...), Stabilizations: archive.StabilizationLog{}, URI: upstreamURI}
 	// Fetch and process rebuild.
 	var r io.ReadCloser
 	rbAsset := rebuild.RebuildAsset.For(t)
@@ -48,7 +50,9 @@ func SummarizeArtifacts(ctx context.Context, metadata rebuild.LocatableAssetStor
 		return
 	}
 	defer checkClose(r)
-	err = archive.Stabilize(rb.StabilizedHash, io.TeeReader(r, rb.Hash), t.ArchiveType())
+	opts := rebuild.StabilizeOpts(t)
+	opts.Log = rb.Stabilizations
+	err = archive.StabilizeWithOpts(rb.StabilizedHash, io.TeeReader(r, rb.Hash), t.ArchiveType(), opts)
 	if err != nil {
 		err = errors.Wrap(err, "fingerprinting rebuild")
 		return
@@ -64,7 +68,8 @@ func SummarizeArtifacts(ctx context.Context, metadata rebuild.LocatableAssetStor
 		err = errors.Errorf("non-OK status fetching upstream artifact")
 		return
 	}
-	err = archive.Stabilize(up.StabilizedHash, io.TeeReader(resp.Body, up.Hash), t.ArchiveType())
+	opts.Log = up.Stabilizations
+	err = archive.StabilizeWithOpts(up.StabilizedHash, io.TeeReader(resp.Body, up.Hash), t.ArchiveType(), opts)
 	checkClose(resp.Body)
 	if err != nil {
 		err = errors.Wrap(err, "fingerprinting upstream")
@@ -72,3 +77,22 @@ func SummarizeArtifacts(ctx context.Context, metadata rebuild.LocatableAssetStor
 	}
 	return
 }
+
+// SummarizeAsset fetches and hashes a build byproduct from the metadata store.
+//
+// NOTE: The StabilizedHash of the result is left unset as byproducts are not
+// subject to stabilization.
+func SummarizeAsset(ctx context.Context, metadata rebuild.LocatableAssetStore, a rebuild.Asset, hashes []crypto.Hash) (s ArtifactSummary, err error) {
+	s = ArtifactSummary{URI: metadata.URL(a).String(), Hash: hashext.NewMultiHash(hashes...)}
+	r, err := metadata.Reader(ctx, a)
+	if err != nil {
+		err = errors.Wrap(err, "reading asset")
+		return
+	}
+	defer checkClose(r)
+	if _, err = io.Copy(s.Hash, r); err != nil {
+		err = errors.Wrap(err, "hashing asset")
+		return
+	}
+	return
+}
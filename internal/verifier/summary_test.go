@@ -98,6 +98,31 @@ func TestSummarizeArtifacts(t *testing.T) {
 	})
 }
 
+func TestSummarizeAsset(t *testing.T) {
+	ctx := context.Background()
+	metadata := rebuild.NewFilesystemAssetStore(memfs.New())
+	target := rebuild.Target{Ecosystem: rebuild.NPM, Package: "foo", Version: "1.0.0", Artifact: "foo-1.0.0.tgz"}
+	asset := rebuild.TetragonLogAsset.For(target)
+	w := must(metadata.Writer(ctx, asset))
+	must(w.Write([]byte(`{"process_exec":{}}`)))
+	orDie(w.Close())
+	wantHash := hashext.NewMultiHash(crypto.SHA256)
+	must(wantHash.Write([]byte(`{"process_exec":{}}`)))
+	s, err := SummarizeAsset(ctx, metadata, asset, []crypto.Hash{crypto.SHA256})
+	if err != nil {
+		t.Fatalf("SummarizeAsset() returned error: %v", err)
+	}
+	if want := metadata.URL(asset).String(); s.URI != want {
+		t.Errorf("SummarizeAsset() returned diff for URI: want %q, got %q", want, s.URI)
+	}
+	if diff := cmp.Diff(wantHash.Sum(nil), s.Hash.Sum(nil)); diff != "" {
+		t.Errorf("SummarizeAsset() returned diff for Hash (-want +got):\n%s", diff)
+	}
+	if _, err := SummarizeAsset(ctx, metadata, rebuild.ProxyNetlogAsset.For(target), []crypto.Hash{crypto.SHA256}); err == nil {
+		t.Error("SummarizeAsset() on missing asset: expected error")
+	}
+}
+
 func must[T any](t T, err error) T {
 	orDie(err)
 	return t
@@ -0,0 +1,240 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package verifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/in-toto/in-toto-golang/in_toto"
+	"github.com/pkg/errors"
+)
+
+const (
+	// VEXContext is the OpenVEX specification version of generated documents.
+	VEXContext = "https://openvex.dev/ns/v0.2.0"
+	// VEXAuthor is the author of generated OpenVEX documents.
+	VEXAuthor = "OSS Rebuild"
+	// UnverifiedSourceID identifies the pseudo-vulnerability of an artifact
+	// containing content not derived from its claimed source.
+	UnverifiedSourceID = "https://docs.oss-rebuild.dev/vex/UnverifiedSource@v0.1"
+)
+
+// VEXStatus is the OpenVEX status of a product with respect to a vulnerability.
+type VEXStatus string
+
+// OpenVEX statuses used to describe rebuild results.
+const (
+	// VEXNotAffected indicates the artifact was reproduced from its source.
+	VEXNotAffected VEXStatus = "not_affected"
+	// VEXUnderInvestigation indicates the artifact could not be reproduced.
+	//
+	// NOTE: A failed rebuild does not establish that an artifact was not built
+	// from its source so "affected" is never asserted.
+	VEXUnderInvestigation VEXStatus = "under_investigation"
+)
+
+// VEXDocument is an OpenVEX document.
+// See https://github.com/openvex/spec/blob/main/OPENVEX-SPEC.md
+type VEXDocument struct {
+	Context    string         `json:"@context"`
+	ID         string         `json:"@id"`
+	Author     string         `json:"author"`
+	Timestamp  time.Time      `json:"timestamp"`
+	Version    int            `json:"version"`
+	Statements []VEXStatement `json:"statements"`
+}
+
+// VEXStatement asserts the status of products with respect to a vulnerability.
+type VEXStatement struct {
+	Vulnerability   VEXVulnerability `json:"vulnerability"`
+	Products        []VEXProduct     `json:"products"`
+	Status          VEXStatus        `json:"status"`
+	Justification   string           `json:"justification,omitempty"`
+	ImpactStatement string           `json:"impact_statement,omitempty"`
+	StatusNotes     string           `json:"status_notes,omitempty"`
+}
+
+// VEXVulnerability identifies the subject of a VEXStatement.
+type VEXVulnerability struct {
+	ID          string `json:"@id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// VEXProduct identifies an artifact covered by a VEXStatement.
+type VEXProduct struct {
+	ID          string            `json:"@id"`
+	Identifiers map[string]string `json:"identifiers,omitempty"`
+	Hashes      map[string]string `json:"hashes,omitempty"`
+}
+
+// vexHashNames maps in-toto digest names to their OpenVEX equivalents.
+var vexHashNames = map[string]string{
+	"sha256": "sha-256",
+	"sha384": "sha-384",
+	"sha512": "sha-512",
+	"sha1":   "sha1",
+}
+
+// PackageURL returns the purl identifying the package version of t.
+func PackageURL(t rebuild.Target) (string, error) {
+	switch t.Ecosystem {
+	case rebuild.NPM:
+		if scope, name, ok := strings.Cut(t.Package, "/"); ok {
+			// NOTE: purl requires the scope's leading '@' be percent-encoded.
+			scope = strings.ReplaceAll(url.PathEscape(scope), "@", "%40")
+			return fmt.Sprintf("pkg:npm/%s/%s@%s", scope, url.PathEscape(name), url.PathEscape(t.Version)), nil
+		}
+		return fmt.Sprintf("pkg:npm/%s@%s", url.PathEscape(t.Package), url.PathEscape(t.Version)), nil
+	case rebuild.PyPI:
+		name := strings.ToLower(strings.ReplaceAll(t.Package, "_", "-"))
+		return fmt.Sprintf("pkg:pypi/%s@%s", url.PathEscape(name), url.PathEscape(t.Version)), nil
+	case rebuild.CratesIO:
+		return fmt.Sprintf("pkg:cargo/%s@%s", url.PathEscape(t.Package), url.PathEscape(t.Version)), nil
+	case rebuild.Maven:
+		group, artifact, ok := strings.Cut(t.Package, ":")
+		if !ok {
+			return "", errors.Errorf("malformed maven package: %s", t.Package)
+		}
+		return fmt.Sprintf("pkg:maven/%s/%s@%s", url.PathEscape(group), url.PathEscape(artifact), url.PathEscape(t.Version)), nil
+	case rebuild.Debian:
+		// Debian packages are qualified by their archive component (e.g. main/xz-utils).
+		_, name, ok := strings.Cut(t.Package, "/")
+		if !ok {
+			name = t.Package
+		}
+		return fmt.Sprintf("pkg:deb/debian/%s@%s", url.PathEscape(name), url.PathEscape(t.Version)), nil
+	case rebuild.GoMod:
+		// NOTE: Each element of the module path is a purl namespace segment.
+		elems := strings.Split(t.Package, "/")
+		for i, e := range elems {
+			elems[i] = url.PathEscape(e)
+		}
+		return fmt.Sprintf("pkg:golang/%s@%s", strings.Join(elems, "/"), url.PathEscape(t.Version)), nil
+	case rebuild.RubyGems:
+		return fmt.Sprintf("pkg:gem/%s@%s", url.PathEscape(t.Package), url.PathEscape(t.Version)), nil
+	default:
+		return "", errors.Errorf("unsupported ecosystem: %s", t.Ecosystem)
+	}
+}
+
+// CreateVEXStatement creates an in-toto statement wrapping an OpenVEX
+// document that describes whether the upstream artifact of t was reproduced.
+//
+// notes is included as the rationale for a non-reproducible result.
+func CreateVEXStatement(t rebuild.Target, id string, up ArtifactSummary, reproducible bool, notes string, now time.Time) (*in_toto.Statement, error) {
+	purl, err := PackageURL(t)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating package URL")
+	}
+	digests := makeDigestSet(up.Hash...)
+	hashes := make(map[string]string, len(digests))
+	for name, digest := range digests {
+		if vexName, ok := vexHashNames[name]; ok {
+			hashes[vexName] = digest
+		}
+	}
+	stmt := VEXStatement{
+		Vulnerability: VEXVulnerability{
+			ID:          UnverifiedSourceID,
+			Name:        "OSS-REBUILD-UNVERIFIED-SOURCE",
+			Description: "The artifact may contain content not derived from its claimed source.",
+		},
+		Products: []VEXProduct{{
+			ID:          purl,
+			Identifiers: map[string]string{"purl": purl},
+			Hashes:      hashes,
+		}},
+	}
+	if reproducible {
+		stmt.Status = VEXNotAffected
+		stmt.Justification = "vulnerable_code_not_present"
+		stmt.ImpactStatement = fmt.Sprintf("%s was reproduced from its claimed source by OSS Rebuild.", t.Artifact)
+	} else {
+		stmt.Status = VEXUnderInvestigation
+		stmt.StatusNotes = notes
+	}
+	doc := VEXDocument{
+		Context:    VEXContext,
+		ID:         "https://docs.oss-rebuild.dev/vex/" + id,
+		Author:     VEXAuthor,
+		Timestamp:  now.UTC(),
+		Version:    1,
+		Statements: []VEXStatement{stmt},
+	}
+	return &in_toto.Statement{
+		StatementHeader: in_toto.StatementHeader{
+			Type:          in_toto.StatementInTotoV1,
+			Subject:       []in_toto.Subject{{Name: t.Artifact, Digest: digests}},
+			PredicateType: VEXContext,
+		},
+		Predicate: doc,
+	}, nil
+}
+
+// PublishVEX signs and publishes an OpenVEX statement.
+//
+// NOTE: Existing statements are overwritten as a VEX document reflects the
+// most recent known status of an artifact. The exception is while an
+// attestation bundle exists for t: the artifact has been reproduced so its
+// not_affected statement is retained and any other statement is skipped.
+func (a Attestor) PublishVEX(ctx context.Context, t rebuild.Target, stmt *in_toto.Statement) error {
+	if !notAffected(stmt) {
+		if exists, err := a.BundleExists(ctx, t); err != nil {
+			return errors.Wrap(err, "checking for existing bundle")
+		} else if exists {
+			return nil
+		}
+	}
+	b, err := json.Marshal(stmt)
+	if err != nil {
+		return errors.Wrap(err, "marshalling statement")
+	}
+	envelope, err := a.Signer.SignPayload(ctx, stmt.Type, b)
+	if err != nil {
+		return errors.Wrap(err, "signing statement")
+	}
+	w, err := a.Store.Writer(ctx, rebuild.VEXAsset.For(t))
+	if err != nil {
+		return errors.Wrap(err, "creating writer for VEX statement")
+	}
+	if err := json.NewEncoder(w).Encode(envelope); err != nil {
+		return errors.Wrap(err, "uploading VEX statement")
+	}
+	if err := w.Close(); err != nil {
+		return errors.Wrap(err, "closing VEX statement upload")
+	}
+	return nil
+}
+
+// notAffected returns whether every statement in the VEX document asserts not_affected.
+func notAffected(stmt *in_toto.Statement) bool {
+	doc, ok := stmt.Predicate.(VEXDocument)
+	if !ok || len(doc.Statements) == 0 {
+		return false
+	}
+	for _, s := range doc.Statements {
+		if s.Status != VEXNotAffected {
+			return false
+		}
+	}
+	return true
+}
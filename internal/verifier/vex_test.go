@@ -0,0 +1,160 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package verifier
+
+import (
+	"context"
+	"crypto"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/internal/hashext"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+)
+
+func TestPackageURL(t *testing.T) {
+	for _, tc := range []struct {
+		target  rebuild.Target
+		want    string
+		wantErr bool
+	}{
+		{target: rebuild.Target{Ecosystem: rebuild.NPM, Package: "left-pad", Version: "1.0.0"}, want: "pkg:npm/left-pad@1.0.0"},
+		{target: rebuild.Target{Ecosystem: rebuild.NPM, Package: "@types/node", Version: "20.0.0"}, want: "pkg:npm/%40types/node@20.0.0"},
+		{target: rebuild.Target{Ecosystem: rebuild.PyPI, Package: "Typing_Extensions", Version: "4.0.0"}, want: "pkg:pypi/typing-extensions@4.0.0"},
+		{target: rebuild.Target{Ecosystem: rebuild.CratesIO, Package: "serde", Version: "1.0.0"}, want: "pkg:cargo/serde@1.0.0"},
+		{target: rebuild.Target{Ecosystem: rebuild.Maven, Package: "junit:junit", Version: "4.13"}, want: "pkg:maven/junit/junit@4.13"},
+		{target: rebuild.Target{Ecosystem: rebuild.Debian, Package: "main/xz-utils", Version: "5.2.4-1+b1"}, want: "pkg:deb/debian/xz-utils@5.2.4-1+b1"},
+		{target: rebuild.Target{Ecosystem: rebuild.GoMod, Package: "github.com/google/go-cmp", Version: "v0.6.0"}, want: "pkg:golang/github.com/google/go-cmp@v0.6.0"},
+		{target: rebuild.Target{Ecosystem: rebuild.RubyGems, Package: "rake", Version: "13.2.1"}, want: "pkg:gem/rake@13.2.1"},
+		{target: rebuild.Target{Ecosystem: rebuild.Maven, Package: "junit", Version: "4.13"}, wantErr: true},
+	} {
+		t.Run(tc.want, func(t *testing.T) {
+			got, err := PackageURL(tc.target)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("PackageURL() error = %v, wantErr %t", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("PackageURL() = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCreateVEXStatement(t *testing.T) {
+	target := rebuild.Target{Ecosystem: rebuild.NPM, Package: "left-pad", Version: "1.0.0", Artifact: "left-pad-1.0.0.tgz"}
+	up := ArtifactSummary{Hash: hashext.NewMultiHash(crypto.SHA256)}
+	must(up.Hash.Write([]byte("upstream")))
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	t.Run("reproducible", func(t *testing.T) {
+		stmt, err := CreateVEXStatement(target, "id", up, true, "", now)
+		if err != nil {
+			t.Fatalf("CreateVEXStatement() error = %v", err)
+		}
+		if stmt.PredicateType != VEXContext || stmt.Subject[0].Name != target.Artifact {
+			t.Errorf("CreateVEXStatement() header = %+v", stmt.StatementHeader)
+		}
+		doc := stmt.Predicate.(VEXDocument)
+		want := VEXProduct{
+			ID:          "pkg:npm/left-pad@1.0.0",
+			Identifiers: map[string]string{"purl": "pkg:npm/left-pad@1.0.0"},
+			Hashes:      map[string]string{"sha-256": stmt.Subject[0].Digest["sha256"]},
+		}
+		if diff := cmp.Diff([]VEXProduct{want}, doc.Statements[0].Products); diff != "" {
+			t.Errorf("CreateVEXStatement() products mismatch (-want +got):\n%s", diff)
+		}
+		if got := doc.Statements[0]; got.Status != VEXNotAffected || got.Justification == "" {
+			t.Errorf("CreateVEXStatement() statement = %+v, want justified %s", got, VEXNotAffected)
+		}
+	})
+	t.Run("not reproducible", func(t *testing.T) {
+		stmt, err := CreateVEXStatement(target, "id", up, false, "rebuild content mismatch", now)
+		if err != nil {
+			t.Fatalf("CreateVEXStatement() error = %v", err)
+		}
+		got := stmt.Predicate.(VEXDocument).Statements[0]
+		if got.Status != VEXUnderInvestigation || got.StatusNotes != "rebuild content mismatch" || got.Justification != "" {
+			t.Errorf("CreateVEXStatement() statement = %+v, want %s with notes", got, VEXUnderInvestigation)
+		}
+	})
+}
+
+type fakeSigner struct{}
+
+func (fakeSigner) Sign(context.Context, []byte) ([]byte, error) { return []byte("sig"), nil }
+func (fakeSigner) KeyID() (string, error)                       { return "fake", nil }
+
+func TestPublishVEX(t *testing.T) {
+	ctx := context.Background()
+	target := rebuild.Target{Ecosystem: rebuild.NPM, Package: "left-pad", Version: "1.0.0", Artifact: "left-pad-1.0.0.tgz"}
+	signer, err := dsse.NewEnvelopeSigner(fakeSigner{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := Attestor{Store: rebuild.NewFilesystemAssetStore(memfs.New()), Signer: InTotoEnvelopeSigner{signer}}
+	up := ArtifactSummary{Hash: hashext.NewMultiHash(crypto.SHA256)}
+	publish := func(reproducible bool) {
+		t.Helper()
+		stmt, err := CreateVEXStatement(target, "id", up, reproducible, "rebuild content mismatch", time.Now())
+		if err != nil {
+			t.Fatalf("CreateVEXStatement() error = %v", err)
+		}
+		if err := a.PublishVEX(ctx, target, stmt); err != nil {
+			t.Fatalf("PublishVEX() error = %v", err)
+		}
+	}
+	status := func() VEXStatus {
+		t.Helper()
+		r, err := a.Store.Reader(ctx, rebuild.VEXAsset.For(target))
+		if err != nil {
+			t.Fatalf("reading VEX statement: %v", err)
+		}
+		defer r.Close()
+		var env dsse.Envelope
+		if err := json.NewDecoder(r).Decode(&env); err != nil {
+			t.Fatalf("decoding envelope: %v", err)
+		}
+		payload, err := base64.StdEncoding.DecodeString(env.Payload)
+		if err != nil {
+			t.Fatalf("decoding payload: %v", err)
+		}
+		var stmt struct {
+			Predicate VEXDocument `json:"predicate"`
+		}
+		if err := json.Unmarshal(payload, &stmt); err != nil {
+			t.Fatalf("decoding statement: %v", err)
+		}
+		return stmt.Predicate.Statements[0].Status
+	}
+	publish(false)
+	if got := status(); got != VEXUnderInvestigation {
+		t.Errorf("status without bundle = %s, want %s", got, VEXUnderInvestigation)
+	}
+	publish(true)
+	w, err := a.Store.Writer(ctx, rebuild.AttestationBundleAsset.For(target))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("{}\n"))
+	w.Close()
+	publish(false)
+	if got := status(); got != VEXNotAffected {
+		t.Errorf("status with bundle = %s, want %s", got, VEXNotAffected)
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package analyzer provides the common plumbing for services that analyze rebuild assets and publish signed findings.
+package analyzer
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+	"github.com/in-toto/in-toto-golang/in_toto"
+	"github.com/pkg/errors"
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+)
+
+// Input provides an analyzer with access to a single rebuild's assets.
+type Input struct {
+	Target rebuild.Target
+	Assets rebuild.AssetStore
+}
+
+// Finding is the result of an analysis.
+type Finding struct {
+	// Subject identifies the resources the finding is about.
+	Subject []in_toto.Subject
+	// Predicate is the JSON-serializable body of the finding.
+	Predicate any
+}
+
+// Analyzer is a named analysis over rebuild assets.
+type Analyzer struct {
+	Name string
+	// PredicateType is the in-toto predicate type of the analyzer's findings.
+	PredicateType string
+	// Requires are the assets that must be available before the analyzer runs.
+	// These may include the outputs of other analyzers.
+	Requires []rebuild.AssetType
+	Func     func(context.Context, Input) (*Finding, error)
+}
+
+// Asset returns the asset type under which the analyzer's signed findings are stored.
+func (a Analyzer) Asset() rebuild.AssetType {
+	return rebuild.AssetType(a.Name + ".intoto.jsonl")
+}
+
+// Statement wraps a finding in an in-toto statement.
+func (a Analyzer) Statement(f *Finding) *in_toto.Statement {
+	return &in_toto.Statement{
+		StatementHeader: in_toto.StatementHeader{
+			Type:          in_toto.StatementInTotoV1,
+			PredicateType: a.PredicateType,
+			Subject:       f.Subject,
+		},
+		Predicate: f.Predicate,
+	}
+}
+
+// Runner executes an analyzer against stored assets and publishes its signed findings.
+type Runner struct {
+	Analyzer Analyzer
+	Signer   *dsse.EnvelopeSigner
+	// Assets is the store from which the rebuild's assets are read.
+	Assets rebuild.AssetStore
+	// Output is the store to which signed findings are written.
+	Output rebuild.AssetStore
+}
+
+// Run analyzes the target and writes the signed finding to the output store.
+func (r Runner) Run(ctx context.Context, t rebuild.Target) (*dsse.Envelope, error) {
+	f, err := r.Analyzer.Func(ctx, Input{Target: t, Assets: r.Assets})
+	if err != nil {
+		return nil, errors.Wrapf(err, "running %s", r.Analyzer.Name)
+	}
+	s := r.Analyzer.Statement(f)
+	b, err := json.Marshal(s)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshalling statement")
+	}
+	env, err := r.Signer.SignPayload(ctx, s.StatementHeader.Type, b)
+	if err != nil {
+		return nil, errors.Wrap(err, "signing payload")
+	}
+	w, err := r.Output.Writer(ctx, r.Analyzer.Asset().For(t))
+	if err != nil {
+		return nil, errors.Wrap(err, "creating writer")
+	}
+	defer w.Close()
+	if err := json.NewEncoder(w).Encode(env); err != nil {
+		return nil, errors.Wrap(err, "writing envelope")
+	}
+	if err := w.Close(); err != nil {
+		return nil, errors.Wrap(err, "closing writer")
+	}
+	return env, nil
+}
+
+// Request is a single request to an analyzer endpoint.
+type Request struct {
+	Ecosystem rebuild.Ecosystem `form:",required"`
+	Package   string            `form:",required"`
+	Version   string            `form:",required"`
+	Artifact  string            `form:",required"`
+	// RunID identifies the run whose assets are analyzed.
+	RunID string `form:",required"`
+}
+
+var _ schema.Message = Request{}
+
+func (req Request) Validate() error { return nil }
+
+// Target returns the rebuild target to be analyzed.
+func (req Request) Target() rebuild.Target {
+	return rebuild.Target{Ecosystem: req.Ecosystem, Package: req.Package, Version: req.Version, Artifact: req.Artifact}
+}
+
+// ParseObjectName translates the name of a stored rebuild asset into the asset and the ID of the run that produced it.
+//
+// The name is expected to be relative to the store prefix and of the form
+// <ecosystem>/<package>/<version>/<artifact>/<runID>/<asset>.
+func ParseObjectName(name string) (rebuild.Asset, string, error) {
+	parts := strings.Split(strings.TrimPrefix(name, "/"), "/")
+	// NOTE: Scoped npm packages contain a slash so the package may span multiple parts.
+	if len(parts) < 6 {
+		return rebuild.Asset{}, "", errors.Errorf("malformed object name: %s", name)
+	}
+	n := len(parts)
+	t := rebuild.Target{
+		Ecosystem: rebuild.Ecosystem(parts[0]),
+		Package:   strings.Join(parts[1:n-4], "/"),
+		Version:   parts[n-4],
+		Artifact:  parts[n-3],
+	}
+	a := rebuild.Asset{Type: rebuild.AssetType(parts[n-1]), Target: t}
+	if string(a.Type) == t.Artifact {
+		a.Type = rebuild.RebuildAsset
+	}
+	return a, parts[n-2], nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analyzer
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+)
+
+func TestParseObjectName(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		object    string
+		wantAsset rebuild.Asset
+		wantRunID string
+		wantErr   bool
+	}{
+		{
+			name:      "netlog",
+			object:    "pypi/absl-py/2.0.0/absl_py-2.0.0-py3-none-any.whl/run-1/netlog.json",
+			wantAsset: rebuild.ProxyNetlogAsset.For(rebuild.Target{Ecosystem: rebuild.PyPI, Package: "absl-py", Version: "2.0.0", Artifact: "absl_py-2.0.0-py3-none-any.whl"}),
+			wantRunID: "run-1",
+		},
+		{
+			name:      "scoped npm artifact",
+			object:    "npm/@scope/pkg/1.0.0/scope-pkg-1.0.0.tgz/run-1/scope-pkg-1.0.0.tgz",
+			wantAsset: rebuild.RebuildAsset.For(rebuild.Target{Ecosystem: rebuild.NPM, Package: "@scope/pkg", Version: "1.0.0", Artifact: "scope-pkg-1.0.0.tgz"}),
+			wantRunID: "run-1",
+		},
+		{
+			name:    "too short",
+			object:  "npm/pkg/1.0.0/netlog.json",
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			a, runID, err := ParseObjectName(tc.object)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("ParseObjectName() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.wantAsset, a); diff != "" {
+				t.Errorf("ParseObjectName() asset mismatch (-want +got):\n%s", diff)
+			}
+			if runID != tc.wantRunID {
+				t.Errorf("ParseObjectName() runID = %s, want %s", runID, tc.wantRunID)
+			}
+		})
+	}
+}
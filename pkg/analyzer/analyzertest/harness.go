@@ -0,0 +1,98 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package analyzertest provides an in-memory harness for testing analyzers without cloud dependencies.
+package analyzertest
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/google/oss-rebuild/pkg/analyzer"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/in-toto/in-toto-golang/in_toto"
+	"github.com/pkg/errors"
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+)
+
+// FakeSigner is a dsse.Signer that produces a constant signature.
+type FakeSigner struct{}
+
+func (FakeSigner) Sign(ctx context.Context, data []byte) ([]byte, error) {
+	return []byte("just trust me"), nil
+}
+
+func (FakeSigner) KeyID() (string, error) {
+	return "fake", nil
+}
+
+// Harness runs an analyzer against in-memory assets.
+type Harness struct {
+	Analyzer analyzer.Analyzer
+	Assets   *rebuild.FilesystemAssetStore
+	Output   *rebuild.FilesystemAssetStore
+}
+
+// NewHarness creates a Harness with empty asset stores.
+func NewHarness(a analyzer.Analyzer) *Harness {
+	return &Harness{
+		Analyzer: a,
+		Assets:   rebuild.NewFilesystemAssetStore(memfs.New()),
+		Output:   rebuild.NewFilesystemAssetStore(memfs.New()),
+	}
+}
+
+// Put stores the content as the target's asset.
+func (h *Harness) Put(ctx context.Context, a rebuild.Asset, content []byte) error {
+	w, err := h.Assets.Writer(ctx, a)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	if _, err := w.Write(content); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// Run executes the analyzer and returns the statement from the published envelope.
+func (h *Harness) Run(ctx context.Context, t rebuild.Target) (*in_toto.Statement, error) {
+	signer, err := dsse.NewEnvelopeSigner(FakeSigner{})
+	if err != nil {
+		return nil, errors.Wrap(err, "creating signer")
+	}
+	r := analyzer.Runner{Analyzer: h.Analyzer, Signer: signer, Assets: h.Assets, Output: h.Output}
+	if _, err := r.Run(ctx, t); err != nil {
+		return nil, err
+	}
+	rc, err := h.Output.Reader(ctx, h.Analyzer.Asset().For(t))
+	if err != nil {
+		return nil, errors.Wrap(err, "reading envelope")
+	}
+	defer rc.Close()
+	var env dsse.Envelope
+	if err := json.NewDecoder(rc).Decode(&env); err != nil {
+		return nil, errors.Wrap(err, "decoding envelope")
+	}
+	payload, err := env.DecodeB64Payload()
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding payload")
+	}
+	var s in_toto.Statement
+	if err := json.Unmarshal(payload, &s); err != nil {
+		return nil, errors.Wrap(err, "decoding statement")
+	}
+	return &s, nil
+}
@@ -0,0 +1,127 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analyzer
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/pkg/errors"
+)
+
+// Order returns the analyzers sorted such that each follows the analyzers whose outputs it requires.
+func Order(analyzers []Analyzer) ([]Analyzer, error) {
+	producers := make(map[rebuild.AssetType]int)
+	for i, a := range analyzers {
+		producers[a.Asset()] = i
+	}
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(analyzers))
+	var ordered []Analyzer
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case visited:
+			return nil
+		case visiting:
+			return errors.Errorf("analyzer dependency cycle at %s", analyzers[i].Name)
+		}
+		state[i] = visiting
+		for _, req := range analyzers[i].Requires {
+			if j, ok := producers[req]; ok {
+				if err := visit(j); err != nil {
+					return err
+				}
+			}
+		}
+		state[i] = visited
+		ordered = append(ordered, analyzers[i])
+		return nil
+	}
+	for i := range analyzers {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+// Subscriber fans out rebuild notifications to analyzers in dependency order.
+type Subscriber struct {
+	Analyzers []Analyzer
+	// Assets is the store in which both rebuild assets and analyzer outputs are found.
+	Assets rebuild.AssetStore
+	// Enqueue schedules the analyzer to run against the target.
+	Enqueue func(context.Context, Analyzer, rebuild.Target) error
+	// PollInterval is the delay between checks for a required asset. Defaults to 10 seconds.
+	PollInterval time.Duration
+	// Timeout bounds the wait for each analyzer's required assets. Defaults to 10 minutes.
+	Timeout time.Duration
+}
+
+const (
+	defaultPollInterval = 10 * time.Second
+	defaultAwaitTimeout = 10 * time.Minute
+)
+
+// Dispatch enqueues each analyzer for the target once all of its required assets are available.
+func (s Subscriber) Dispatch(ctx context.Context, t rebuild.Target) error {
+	ordered, err := Order(s.Analyzers)
+	if err != nil {
+		return err
+	}
+	for _, a := range ordered {
+		for _, req := range a.Requires {
+			if err := s.await(ctx, req.For(t)); err != nil {
+				return errors.Wrapf(err, "awaiting %s for %s", req, a.Name)
+			}
+		}
+		if err := s.Enqueue(ctx, a, t); err != nil {
+			return errors.Wrapf(err, "enqueueing %s", a.Name)
+		}
+	}
+	return nil
+}
+
+// await polls until the asset is available or the timeout elapses.
+func (s Subscriber) await(ctx context.Context, a rebuild.Asset) error {
+	timeout, interval := s.Timeout, s.PollInterval
+	if timeout == 0 {
+		timeout = defaultAwaitTimeout
+	}
+	if interval == 0 {
+		interval = defaultPollInterval
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for {
+		r, err := s.Assets.Reader(ctx, a)
+		if err == nil {
+			return r.Close()
+		} else if !errors.Is(err, rebuild.ErrAssetNotFound) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
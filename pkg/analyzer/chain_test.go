@@ -0,0 +1,132 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analyzer
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+)
+
+func names(analyzers []Analyzer) []string {
+	var ret []string
+	for _, a := range analyzers {
+		ret = append(ret, a.Name)
+	}
+	return ret
+}
+
+func TestOrder(t *testing.T) {
+	comparison := Analyzer{Name: "comparison", Requires: []rebuild.AssetType{rebuild.RebuildAsset}}
+	classifier := Analyzer{Name: "diff-classifier", Requires: []rebuild.AssetType{rebuild.AttestationBundleAsset, comparison.Asset()}}
+	network := Analyzer{Name: "network", Requires: []rebuild.AssetType{rebuild.ProxyNetlogAsset}}
+	t.Run("Dependencies", func(t *testing.T) {
+		got, err := Order([]Analyzer{classifier, network, comparison})
+		if err != nil {
+			t.Fatalf("Order() error = %v", err)
+		}
+		want := []string{"comparison", "diff-classifier", "network"}
+		if diff := cmp.Diff(want, names(got)); diff != "" {
+			t.Errorf("Order() mismatch (-want +got):\n%s", diff)
+		}
+	})
+	t.Run("Cycle", func(t *testing.T) {
+		a := Analyzer{Name: "a", Requires: []rebuild.AssetType{"b.intoto.jsonl"}}
+		b := Analyzer{Name: "b", Requires: []rebuild.AssetType{"a.intoto.jsonl"}}
+		if _, err := Order([]Analyzer{a, b}); err == nil {
+			t.Error("Order() expected cycle error")
+		}
+	})
+}
+
+// delayedStore reports assets as missing for the first misses reads.
+type delayedStore struct {
+	rebuild.AssetStore
+	misses int
+}
+
+func (s *delayedStore) Reader(ctx context.Context, a rebuild.Asset) (io.ReadCloser, error) {
+	if s.misses > 0 {
+		s.misses--
+		return nil, rebuild.ErrAssetNotFound
+	}
+	return io.NopCloser(strings.NewReader("")), nil
+}
+
+func TestSubscriberDispatch(t *testing.T) {
+	ctx := context.Background()
+	target := rebuild.Target{Ecosystem: rebuild.NPM, Package: "pkg", Version: "1.0.0", Artifact: "pkg-1.0.0.tgz"}
+	store := rebuild.NewFilesystemAssetStore(memfs.New())
+	first := Analyzer{Name: "first"}
+	second := Analyzer{Name: "second", Requires: []rebuild.AssetType{first.Asset()}}
+	var enqueued []string
+	s := Subscriber{
+		Analyzers: []Analyzer{second, first},
+		Assets:    store,
+		Enqueue: func(ctx context.Context, a Analyzer, t rebuild.Target) error {
+			enqueued = append(enqueued, a.Name)
+			// Simulate the analyzer publishing its output.
+			w, err := store.Writer(ctx, a.Asset().For(t))
+			if err != nil {
+				return err
+			}
+			return w.Close()
+		},
+		PollInterval: time.Millisecond,
+		Timeout:      time.Second,
+	}
+	if err := s.Dispatch(ctx, target); err != nil {
+		t.Fatalf("Dispatch() error = %v", err)
+	}
+	if diff := cmp.Diff([]string{"first", "second"}, enqueued); diff != "" {
+		t.Errorf("Dispatch() order mismatch (-want +got):\n%s", diff)
+	}
+	t.Run("Timeout", func(t *testing.T) {
+		s := Subscriber{
+			Analyzers:    []Analyzer{{Name: "blocked", Requires: []rebuild.AssetType{rebuild.ProxyNetlogAsset}}},
+			Assets:       rebuild.NewFilesystemAssetStore(memfs.New()),
+			Enqueue:      func(context.Context, Analyzer, rebuild.Target) error { return nil },
+			PollInterval: time.Millisecond,
+			Timeout:      10 * time.Millisecond,
+		}
+		if err := s.Dispatch(ctx, target); err == nil {
+			t.Error("Dispatch() expected timeout error")
+		}
+	})
+	t.Run("DefaultTimeout", func(t *testing.T) {
+		var enqueued []string
+		s := Subscriber{
+			Analyzers: []Analyzer{{Name: "delayed", Requires: []rebuild.AssetType{rebuild.ProxyNetlogAsset}}},
+			Assets:    &delayedStore{AssetStore: rebuild.NewFilesystemAssetStore(memfs.New()), misses: 3},
+			Enqueue: func(_ context.Context, a Analyzer, _ rebuild.Target) error {
+				enqueued = append(enqueued, a.Name)
+				return nil
+			},
+			PollInterval: time.Millisecond,
+		}
+		if err := s.Dispatch(ctx, target); err != nil {
+			t.Fatalf("Dispatch() error = %v", err)
+		}
+		if diff := cmp.Diff([]string{"delayed"}, enqueued); diff != "" {
+			t.Errorf("Dispatch() mismatch (-want +got):\n%s", diff)
+		}
+	})
+}
@@ -0,0 +1,233 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package network provides analyses of the network activity recorded during a rebuild.
+package network
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/google/oss-rebuild/internal/netclassify"
+	"github.com/google/oss-rebuild/pkg/analyzer"
+	"github.com/google/oss-rebuild/pkg/proxy/netlog"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/pkg/registry/cratesio"
+	"github.com/google/oss-rebuild/pkg/registry/npm"
+	"github.com/google/oss-rebuild/pkg/registry/pypi"
+	"github.com/in-toto/in-toto-golang/in_toto"
+	"github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/common"
+	"github.com/pkg/errors"
+)
+
+// DownloadDigestsPredicateType is the in-toto predicate type of a download digest verification finding.
+const DownloadDigestsPredicateType = "https://docs.oss-rebuild.dev/analyzers/DownloadDigests@v0.1"
+
+var (
+	npmTarballRegex = regexp.MustCompile(`^https://registry\.npmjs\.org/(?P<package>(?:@[^/]+/)?[^/]+)/-/(?P<file>[^/]+)\.tgz$`)
+	crateFileRegex  = regexp.MustCompile(`^https://static\.crates\.io/crates/(?P<package>[^/]+)/(?:(?P<file>[^/]+)\.crate|(?P<version>[^/]+)/download)$`)
+)
+
+// Registries provides the package registries against which downloads are verified.
+type Registries struct {
+	NPM      npm.Registry
+	PyPI     pypi.Registry
+	CratesIO cratesio.Registry
+}
+
+// DownloadMismatch describes a downloaded artifact whose content does not match registry metadata.
+type DownloadMismatch struct {
+	URL       string `json:"url"`
+	Algorithm string `json:"algorithm"`
+	Observed  string `json:"observed"`
+	Expected  string `json:"expected"`
+}
+
+// DownloadDigestsPredicate summarizes the verification of a rebuild's downloads.
+type DownloadDigestsPredicate struct {
+	// Verified are the URLs of downloads whose content matched registry metadata.
+	Verified []string `json:"verified"`
+	// Mismatched are the downloads whose content did not match registry metadata.
+	Mismatched []DownloadMismatch `json:"mismatched"`
+	// Unpublished are the URLs of registry downloads absent from registry metadata.
+	Unpublished []string `json:"unpublished"`
+}
+
+// errUnpublished indicates a registry download has no corresponding registry metadata.
+var errUnpublished = errors.New("artifact not published")
+
+// registryDigest is a digest published by a registry for a downloadable artifact.
+type registryDigest struct {
+	Algorithm string
+	Value     string
+}
+
+// VerifyDownloads compares the content digests of registry artifacts recorded in log against those published by the registries.
+//
+// Downloads not originating from a supported registry are ignored. Downloads
+// absent from the registry's metadata are reported as unpublished.
+func VerifyDownloads(ctx context.Context, log *netlog.NetworkActivityLog, regs Registries) (*DownloadDigestsPredicate, error) {
+	p := &DownloadDigestsPredicate{Verified: []string{}, Mismatched: []DownloadMismatch{}, Unpublished: []string{}}
+	for _, dl := range log.HTTPDownloads {
+		url := dl.URL()
+		expected, err := lookupDigest(ctx, url, regs)
+		if errors.Is(err, errUnpublished) {
+			p.Unpublished = append(p.Unpublished, url)
+			continue
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "looking up digest for %s", url)
+		} else if expected == nil {
+			continue
+		}
+		if observed := dl.Digests[expected.Algorithm]; observed != expected.Value {
+			p.Mismatched = append(p.Mismatched, DownloadMismatch{URL: url, Algorithm: expected.Algorithm, Observed: observed, Expected: expected.Value})
+		} else {
+			p.Verified = append(p.Verified, url)
+		}
+	}
+	return p, nil
+}
+
+// lookupDigest returns the registry-provided digest for the artifact at url or nil if url is not a known registry artifact.
+func lookupDigest(ctx context.Context, url string, regs Registries) (*registryDigest, error) {
+	switch {
+	case regs.NPM != nil && npmTarballRegex.MatchString(url):
+		matches := npmTarballRegex.FindStringSubmatch(url)
+		pkg := matches[npmTarballRegex.SubexpIndex("package")]
+		file := matches[npmTarballRegex.SubexpIndex("file")]
+		_, unscoped, _ := strings.Cut(pkg, "/")
+		if unscoped == "" {
+			unscoped = pkg
+		}
+		version, found := strings.CutPrefix(file, unscoped+"-")
+		if !found {
+			return nil, nil
+		}
+		v, err := regs.NPM.Version(ctx, pkg, version)
+		if err != nil {
+			return nil, err
+		}
+		return npmDigest(v.Dist)
+	case regs.PyPI != nil && strings.HasPrefix(url, "https://files.pythonhosted.org/"):
+		purl, err := netclassify.ClassifyURL(url)
+		if err != nil {
+			// Unparseable and metadata files are not verified.
+			return nil, nil
+		}
+		name, version, _ := strings.Cut(strings.TrimPrefix(purl, "pkg:pypi/"), "@")
+		r, err := regs.PyPI.Release(ctx, name, version)
+		if err != nil {
+			return nil, err
+		}
+		file := url[strings.LastIndex(url, "/")+1:]
+		for _, a := range r.Artifacts {
+			if a.Filename == file {
+				return &registryDigest{Algorithm: "sha256", Value: a.Digests.SHA256}, nil
+			}
+		}
+		return nil, errors.Wrapf(errUnpublished, "%s not found in release", file)
+	case regs.CratesIO != nil && crateFileRegex.MatchString(url):
+		matches := crateFileRegex.FindStringSubmatch(url)
+		pkg := matches[crateFileRegex.SubexpIndex("package")]
+		version := matches[crateFileRegex.SubexpIndex("version")]
+		if file := matches[crateFileRegex.SubexpIndex("file")]; file != "" {
+			// NOTE: Versions may contain hyphens so the package name is removed
+			// rather than splitting at the last hyphen.
+			var found bool
+			version, found = strings.CutPrefix(file, pkg+"-")
+			if !found {
+				return nil, nil
+			}
+		}
+		v, err := regs.CratesIO.Version(ctx, pkg, version)
+		if err != nil {
+			return nil, err
+		}
+		return &registryDigest{Algorithm: "sha256", Value: v.Checksum}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// integrityStrength ranks the Subresource Integrity algorithms recorded in netlogs.
+var integrityStrength = map[string]int{"sha256": 1, "sha512": 2}
+
+// npmDigest returns the strongest digest available from the npm dist metadata.
+func npmDigest(d npm.Dist) (*registryDigest, error) {
+	// Integrity uses the Subresource Integrity format: a space-separated list
+	// of "<alg>-<base64 digest>" entries, each optionally suffixed by "?<opts>".
+	var best *registryDigest
+	for _, entry := range strings.Fields(d.SHA512) {
+		alg, b64, found := strings.Cut(entry, "-")
+		if !found || integrityStrength[alg] == 0 {
+			continue
+		}
+		if best != nil && integrityStrength[alg] <= integrityStrength[best.Algorithm] {
+			continue
+		}
+		b64, _, _ = strings.Cut(b64, "?")
+		b, err := base64.StdEncoding.DecodeString(b64)
+		if err != nil {
+			return nil, errors.Wrap(err, "decoding integrity")
+		}
+		best = &registryDigest{Algorithm: alg, Value: hex.EncodeToString(b)}
+	}
+	if best != nil {
+		return best, nil
+	}
+	if d.SHA1 != "" {
+		return &registryDigest{Algorithm: "sha1", Value: d.SHA1}, nil
+	}
+	return nil, errors.New("no digest in dist metadata")
+}
+
+// DownloadDigestsAnalyzer verifies the registry downloads recorded in a rebuild's netlog.
+func DownloadDigestsAnalyzer(regs Registries) analyzer.Analyzer {
+	return analyzer.Analyzer{
+		Name:          "download-digests",
+		PredicateType: DownloadDigestsPredicateType,
+		Requires:      []rebuild.AssetType{rebuild.ProxyNetlogAsset},
+		Func: func(ctx context.Context, in analyzer.Input) (*analyzer.Finding, error) {
+			r, err := in.Assets.Reader(ctx, rebuild.ProxyNetlogAsset.For(in.Target))
+			if err != nil {
+				return nil, errors.Wrap(err, "reading netlog")
+			}
+			defer r.Close()
+			h := sha256.New()
+			var log netlog.NetworkActivityLog
+			if err := json.NewDecoder(io.TeeReader(r, h)).Decode(&log); err != nil {
+				return nil, errors.Wrap(err, "decoding netlog")
+			}
+			// Drain any trailing content so the digest covers the full asset.
+			if _, err := io.Copy(h, r); err != nil {
+				return nil, errors.Wrap(err, "reading netlog")
+			}
+			p, err := VerifyDownloads(ctx, &log, regs)
+			if err != nil {
+				return nil, err
+			}
+			subject := in_toto.Subject{
+				Name:   string(rebuild.ProxyNetlogAsset),
+				Digest: common.DigestSet{"sha256": hex.EncodeToString(h.Sum(nil))},
+			}
+			return &analyzer.Finding{Subject: []in_toto.Subject{subject}, Predicate: p}, nil
+		},
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package network
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/pkg/analyzer/analyzertest"
+	"github.com/google/oss-rebuild/pkg/proxy/netlog"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/pkg/registry/cratesio"
+	"github.com/google/oss-rebuild/pkg/registry/npm"
+	"github.com/google/oss-rebuild/pkg/registry/pypi"
+)
+
+type fakeNPM struct {
+	npm.Registry
+	versions map[string]*npm.NPMVersion
+}
+
+func (r fakeNPM) Version(ctx context.Context, pkg, version string) (*npm.NPMVersion, error) {
+	return r.versions[pkg+"@"+version], nil
+}
+
+type fakePyPI struct {
+	pypi.Registry
+	releases map[string]*pypi.Release
+}
+
+func (r fakePyPI) Release(ctx context.Context, pkg, version string) (*pypi.Release, error) {
+	return r.releases[pkg+"@"+version], nil
+}
+
+type fakeCratesIO struct {
+	cratesio.Registry
+	versions map[string]*cratesio.CrateVersion
+}
+
+func (r fakeCratesIO) Version(ctx context.Context, pkg, version string) (*cratesio.CrateVersion, error) {
+	return r.versions[pkg+"@"+version], nil
+}
+
+// sha512Hex is the SHA-512 digest of the empty string.
+const sha512Hex = "cf83e1357eefb8bdf1542850d66d8007d620e4050b5715dc83f4a921d36ce9ce47d0d13c5d85f2b0ff8318d2877eec2f63b931bd47417a81a538327af927da3e"
+
+func mustHexToB64(s string) string {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+func TestVerifyDownloads(t *testing.T) {
+	regs := Registries{
+		NPM: fakeNPM{versions: map[string]*npm.NPMVersion{
+			"@scope/pkg@1.0.0": {Dist: npm.Dist{SHA512: "sha512-" + mustHexToB64(sha512Hex)}},
+			"legacy@0.1.0":     {Dist: npm.Dist{SHA1: "aaaa"}},
+			"multi@2.0.0":      {Dist: npm.Dist{SHA512: "sha1-" + mustHexToB64("aaaa") + " sha512-" + mustHexToB64(sha512Hex) + "?opt sha256-" + mustHexToB64("abcd")}},
+		}},
+		PyPI: fakePyPI{releases: map[string]*pypi.Release{
+			"absl_py@2.0.0": {Artifacts: []pypi.Artifact{{Filename: "absl_py-2.0.0-py3-none-any.whl", Digests: pypi.Digests{SHA256: "abcd"}}}},
+		}},
+		CratesIO: fakeCratesIO{versions: map[string]*cratesio.CrateVersion{
+			"serde@1.0.150":           {Version: cratesio.Version{Checksum: "1234"}},
+			"serde-json@1.0.0-beta.1": {Version: cratesio.Version{Checksum: "9abc"}},
+		}},
+	}
+	log := &netlog.NetworkActivityLog{HTTPDownloads: []netlog.HTTPDownloadLog{
+		{Scheme: "https", Host: "registry.npmjs.org", Path: "/@scope/pkg/-/pkg-1.0.0.tgz", Digests: map[string]string{"sha512": sha512Hex}},
+		{Scheme: "https", Host: "registry.npmjs.org", Path: "/legacy/-/legacy-0.1.0.tgz", Digests: map[string]string{"sha1": "bbbb"}},
+		{Scheme: "https", Host: "registry.npmjs.org", Path: "/multi/-/multi-2.0.0.tgz", Digests: map[string]string{"sha1": "aaaa", "sha256": "abcd", "sha512": sha512Hex}},
+		{Scheme: "https", Host: "files.pythonhosted.org", Path: "/packages/bb/23/8e140b6e813c65a8b4be429efbda3ff81fe1b08a5cca0f7b4f316b827ab0/absl_py-2.0.0-py3-none-any.whl", Digests: map[string]string{"sha256": "abcd"}},
+		{Scheme: "https", Host: "files.pythonhosted.org", Path: "/packages/cc/34/9f251c7f924d76b9c5cf530fceb4ba4ef92c2e1c6f24c49d1e7e1f6a8cd4/absl_py-2.0.0.tar.gz", Digests: map[string]string{"sha256": "eeee"}},
+		{Scheme: "https", Host: "static.crates.io", Path: "/crates/serde/serde-1.0.150.crate", Digests: map[string]string{"sha256": "5678"}},
+		{Scheme: "https", Host: "static.crates.io", Path: "/crates/serde-json/serde-json-1.0.0-beta.1.crate", Digests: map[string]string{"sha256": "9abc"}},
+		{Scheme: "https", Host: "static.crates.io", Path: "/crates/serde-json/1.0.0-beta.1/download", Digests: map[string]string{"sha256": "9abc"}},
+		{Scheme: "https", Host: "example.com", Path: "/unrelated.tgz", Digests: map[string]string{"sha256": "ffff"}},
+	}}
+	got, err := VerifyDownloads(context.Background(), log, regs)
+	if err != nil {
+		t.Fatalf("VerifyDownloads() error = %v", err)
+	}
+	want := &DownloadDigestsPredicate{
+		Verified: []string{
+			"https://registry.npmjs.org/@scope/pkg/-/pkg-1.0.0.tgz",
+			"https://registry.npmjs.org/multi/-/multi-2.0.0.tgz",
+			"https://files.pythonhosted.org/packages/bb/23/8e140b6e813c65a8b4be429efbda3ff81fe1b08a5cca0f7b4f316b827ab0/absl_py-2.0.0-py3-none-any.whl",
+			"https://static.crates.io/crates/serde-json/serde-json-1.0.0-beta.1.crate",
+			"https://static.crates.io/crates/serde-json/1.0.0-beta.1/download",
+		},
+		Mismatched: []DownloadMismatch{
+			{URL: "https://registry.npmjs.org/legacy/-/legacy-0.1.0.tgz", Algorithm: "sha1", Observed: "bbbb", Expected: "aaaa"},
+			{URL: "https://static.crates.io/crates/serde/serde-1.0.150.crate", Algorithm: "sha256", Observed: "5678", Expected: "1234"},
+		},
+		Unpublished: []string{
+			"https://files.pythonhosted.org/packages/cc/34/9f251c7f924d76b9c5cf530fceb4ba4ef92c2e1c6f24c49d1e7e1f6a8cd4/absl_py-2.0.0.tar.gz",
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("VerifyDownloads() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestDownloadDigestsAnalyzer(t *testing.T) {
+	ctx := context.Background()
+	target := rebuild.Target{Ecosystem: rebuild.CratesIO, Package: "foo", Version: "0.1.0", Artifact: "foo-0.1.0.crate"}
+	regs := Registries{CratesIO: fakeCratesIO{versions: map[string]*cratesio.CrateVersion{
+		"serde@1.0.150": {Version: cratesio.Version{Checksum: "1234"}},
+	}}}
+	h := analyzertest.NewHarness(DownloadDigestsAnalyzer(regs))
+	log := netlog.NetworkActivityLog{HTTPDownloads: []netlog.HTTPDownloadLog{
+		{Scheme: "https", Host: "static.crates.io", Path: "/crates/serde/1.0.150/download", Digests: map[string]string{"sha256": "5678"}},
+	}}
+	content, err := json.Marshal(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Put(ctx, rebuild.ProxyNetlogAsset.For(target), content); err != nil {
+		t.Fatal(err)
+	}
+	s, err := h.Run(ctx, target)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if s.PredicateType != DownloadDigestsPredicateType {
+		t.Errorf("PredicateType = %s, want %s", s.PredicateType, DownloadDigestsPredicateType)
+	}
+	wantDigest := fmt.Sprintf("%x", sha256.Sum256(content))
+	if len(s.Subject) != 1 || s.Subject[0].Name != "netlog.json" || s.Subject[0].Digest["sha256"] != wantDigest {
+		t.Errorf("Subject = %+v, want netlog.json with sha256 %s", s.Subject, wantDigest)
+	}
+	var p DownloadDigestsPredicate
+	if err := json.Unmarshal(must(json.Marshal(s.Predicate)), &p); err != nil {
+		t.Fatal(err)
+	}
+	want := DownloadDigestsPredicate{
+		Verified:    []string{},
+		Mismatched:  []DownloadMismatch{{URL: "https://static.crates.io/crates/serde/1.0.150/download", Algorithm: "sha256", Observed: "5678", Expected: "1234"}},
+		Unpublished: []string{},
+	}
+	if diff := cmp.Diff(want, p); diff != "" {
+		t.Errorf("Predicate mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func must[T any](t T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
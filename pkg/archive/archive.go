@@ -22,9 +22,10 @@ import (
 	"slices"
 
 	"github.com/pkg/errors"
+	"github.com/ulikunitz/xz"
 )
 
-var AllStabilizers = slices.Concat(AllZipStabilizers, AllTarStabilizers, AllGzipStabilizers)
+var AllStabilizers = slices.Concat(AllZipStabilizers, AllTarStabilizers, AllGzipStabilizers, AllGemStabilizers)
 
 // Stabilize selects and applies the default stabilization routine for the given archive format.
 func Stabilize(dst io.Writer, src io.Reader, f Format) error {
@@ -64,11 +65,30 @@ func StabilizeWithOpts(dst io.Writer, src io.Reader, f Format, opts StabilizeOpt
 		if err != nil {
 			return errors.Wrap(err, "stabilizing tar.gz")
 		}
+	case TarXzFormat:
+		xzr, err := xz.NewReader(src)
+		if err != nil {
+			return errors.Wrap(err, "initializing xz reader")
+		}
+		xzw, err := xz.NewWriter(dst)
+		if err != nil {
+			return errors.Wrap(err, "initializing xz writer")
+		}
+		defer xzw.Close()
+		err = StabilizeTar(tar.NewReader(xzr), tar.NewWriter(xzw), opts)
+		if err != nil {
+			return errors.Wrap(err, "stabilizing tar.xz")
+		}
 	case TarFormat:
 		err := StabilizeTar(tar.NewReader(src), tar.NewWriter(dst), opts)
 		if err != nil {
 			return errors.Wrap(err, "stabilizing tar")
 		}
+	case GemFormat:
+		err := StabilizeGem(tar.NewReader(src), tar.NewWriter(dst), opts)
+		if err != nil {
+			return errors.Wrap(err, "stabilizing gem")
+		}
 	case RawFormat:
 		if _, err := io.Copy(dst, src); err != nil {
 			return errors.Wrap(err, "copying raw")
@@ -99,6 +119,14 @@ func NewContentSummary(src io.Reader, f Format) (*ContentSummary, error) {
 		}
 		defer gzr.Close()
 		return NewContentSummaryFromTar(tar.NewReader(gzr))
+	case TarXzFormat:
+		xzr, err := xz.NewReader(src)
+		if err != nil {
+			return nil, errors.Wrap(err, "initializing xz reader")
+		}
+		return NewContentSummaryFromTar(tar.NewReader(xzr))
+	case GemFormat:
+		return NewContentSummaryFromGem(tar.NewReader(src))
 	default:
 		return nil, errors.New("unsupported archive type")
 	}
@@ -41,3 +41,24 @@ func TgzFile(entries []archive.TarEntry) (*bytes.Buffer, error) {
 	}
 	return zbuf, nil
 }
+
+// GemFile returns a gem with the given specification YAML and data entries.
+// Any extra entries are appended to the outer archive.
+func GemFile(metadata string, data []archive.TarEntry, extra ...archive.TarEntry) (*bytes.Buffer, error) {
+	dbuf, err := TgzFile(data)
+	if err != nil {
+		return nil, err
+	}
+	mbuf := new(bytes.Buffer)
+	w := gzip.NewWriter(mbuf)
+	if _, err := w.Write([]byte(metadata)); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return TarFile(append([]archive.TarEntry{
+		{Header: &tar.Header{Name: "metadata.gz", Typeflag: tar.TypeReg, Mode: 0444}, Body: mbuf.Bytes()},
+		{Header: &tar.Header{Name: "data.tar.gz", Typeflag: tar.TypeReg, Mode: 0444}, Body: dbuf.Bytes()},
+	}, extra...))
+}
@@ -15,6 +15,11 @@
 // Package archive provides common types and functions for archive processing.
 package archive
 
+import (
+	"fmt"
+	"slices"
+)
+
 // Format represents the archive types of packages.
 type Format int
 
@@ -25,11 +30,51 @@ const (
 	TarFormat
 	ZipFormat
 	RawFormat
+	// GemFormat is a RubyGems package: a tar containing gzipped metadata and data.
+	GemFormat
+	// TarXzFormat is an xz-compressed tar archive.
+	TarXzFormat
 )
 
+// StabilizerName returns the name of the provided stabilizer.
+func StabilizerName(s any) string {
+	switch s := s.(type) {
+	case TarArchiveStabilizer:
+		return s.Name
+	case TarEntryStabilizer:
+		return s.Name
+	case ZipArchiveStabilizer:
+		return s.Name
+	case ZipEntryStabilizer:
+		return s.Name
+	case GemStabilizer:
+		return s.Name
+	case GzipStabilizer:
+		return s.Name
+	default:
+		return fmt.Sprintf("%T", s)
+	}
+}
+
 // StabilizeOpts aggregates stabilizers to be used in stabilization.
 type StabilizeOpts struct {
 	Stabilizers []any
+	// Log, if non-nil, records the stabilizers that modified each entry.
+	Log StabilizationLog
+}
+
+// ArchiveScope is the StabilizationLog key used for modifications to
+// archive-level properties such as entry order or compression metadata.
+const ArchiveScope = "<archive>"
+
+// StabilizationLog maps entry paths to the names of the stabilizers that modified them.
+type StabilizationLog map[string][]string
+
+func (l StabilizationLog) record(entry, stabilizer string) {
+	if l == nil || slices.Contains(l[entry], stabilizer) {
+		return
+	}
+	l[entry] = append(l[entry], stabilizer)
 }
 
 // ContentSummary is a summary of rebuild-relevant features of an archive.
@@ -0,0 +1,247 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	gemMetadata  = "metadata.gz"
+	gemData      = "data.tar.gz"
+	gemChecksums = "checksums.yaml.gz"
+)
+
+// GemArchive represents the top-level contents of a gem.
+type GemArchive struct {
+	// Metadata is the decompressed gem specification YAML.
+	Metadata []byte
+	// Files are the top-level entries besides the metadata and data.
+	Files []*TarEntry
+}
+
+type GemStabilizer struct {
+	Name string
+	Func func(*GemArchive)
+}
+
+var AllGemStabilizers = []any{
+	StableGemDate,
+	StableGemChecksums,
+	StableGemSignatures,
+}
+
+var gemDateRE = regexp.MustCompile(`(?m)^date: .*$`)
+
+var StableGemDate = GemStabilizer{
+	Name: "gem-date",
+	Func: func(g *GemArchive) {
+		// NOTE: RubyGems records the build date truncated to the day unless
+		// SOURCE_DATE_EPOCH is set.
+		g.Metadata = gemDateRE.ReplaceAll(g.Metadata, []byte("date: 1980-01-02 00:00:00.000000000 Z"))
+	},
+}
+
+var StableGemChecksums = GemStabilizer{
+	Name: "gem-checksums",
+	Func: func(g *GemArchive) {
+		// NOTE: The checksums cover the compressed metadata and data so are
+		// invalidated by the stabilization of those entries.
+		g.Files = slices.DeleteFunc(g.Files, func(e *TarEntry) bool {
+			return e.Name == gemChecksums
+		})
+	},
+}
+
+var StableGemSignatures = GemStabilizer{
+	Name: "gem-signatures",
+	Func: func(g *GemArchive) {
+		g.Files = slices.DeleteFunc(g.Files, func(e *TarEntry) bool {
+			return strings.HasSuffix(e.Name, ".sig")
+		})
+	},
+}
+
+func readTarEntries(tr *tar.Reader) ([]*TarEntry, error) {
+	var ents []*TarEntry
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return ents, nil
+		} else if err != nil {
+			return nil, err
+		}
+		buf, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		ents = append(ents, &TarEntry{header, buf})
+	}
+}
+
+// StabilizeGem strips volatile metadata and re-writes the provided gem in a standard form.
+//
+// The stabilizers are applied to both the outer archive and to data.tar.gz.
+func StabilizeGem(tr *tar.Reader, tw *tar.Writer, opts StabilizeOpts) error {
+	defer tw.Close()
+	ents, err := readTarEntries(tr)
+	if err != nil {
+		return err
+	}
+	var g GemArchive
+	var metadata, data *TarEntry
+	for _, e := range ents {
+		switch e.Name {
+		case gemMetadata:
+			metadata = e
+		case gemData:
+			data = e
+		default:
+			g.Files = append(g.Files, e)
+		}
+	}
+	if metadata == nil || data == nil {
+		return errors.New("missing gem metadata or data")
+	}
+	gzr, err := gzip.NewReader(bytes.NewReader(metadata.Body))
+	if err != nil {
+		return errors.Wrap(err, "initializing metadata gzip reader")
+	}
+	if g.Metadata, err = io.ReadAll(gzr); err != nil {
+		return errors.Wrap(err, "reading metadata")
+	}
+	for _, s := range opts.Stabilizers {
+		switch s.(type) {
+		case GemStabilizer:
+			stabilizer := s.(GemStabilizer)
+			beforeMetadata, beforeFiles := g.Metadata, tarEntryNames(g.Files)
+			stabilizer.Func(&g)
+			if opts.Log != nil && !bytes.Equal(beforeMetadata, g.Metadata) {
+				opts.Log.record(gemMetadata, stabilizer.Name)
+			}
+			if opts.Log != nil && !slices.Equal(beforeFiles, tarEntryNames(g.Files)) {
+				opts.Log.record(ArchiveScope, stabilizer.Name)
+			}
+		}
+	}
+	{
+		var buf bytes.Buffer
+		gzw, err := NewStabilizedGzipWriter(gzr, &buf, opts)
+		if err != nil {
+			return errors.Wrap(err, "initializing metadata gzip writer")
+		}
+		if _, err := gzw.Write(g.Metadata); err != nil {
+			return errors.Wrap(err, "writing metadata")
+		}
+		if err := gzw.Close(); err != nil {
+			return errors.Wrap(err, "writing metadata")
+		}
+		metadata.Body = buf.Bytes()
+		metadata.Size = int64(buf.Len())
+	}
+	{
+		var buf bytes.Buffer
+		dataOpts := StabilizeOpts{Stabilizers: opts.Stabilizers}
+		if opts.Log != nil {
+			dataOpts.Log = make(StabilizationLog)
+		}
+		if err := StabilizeWithOpts(&buf, bytes.NewReader(data.Body), TarGzFormat, dataOpts); err != nil {
+			return errors.Wrap(err, "stabilizing data")
+		}
+		for entry, names := range dataOpts.Log {
+			key := gemData
+			if entry != ArchiveScope {
+				key += "/" + entry
+			}
+			for _, name := range names {
+				opts.Log.record(key, name)
+			}
+		}
+		data.Body = buf.Bytes()
+		data.Size = int64(buf.Len())
+	}
+	f := TarArchive{Files: append([]*TarEntry{metadata, data}, g.Files...)}
+	stabilizeTarArchive(&f, opts)
+	for _, ent := range f.Files {
+		if err := ent.WriteTo(tw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewContentSummaryFromGem returns a ContentSummary for a gem.
+//
+// The contents of data.tar.gz are summarized as entries beneath "data.tar.gz/"
+// and the metadata is summarized in its decompressed form.
+func NewContentSummaryFromGem(tr *tar.Reader) (*ContentSummary, error) {
+	ents, err := readTarEntries(tr)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading gem")
+	}
+	type file struct{ name, hash string }
+	var files []file
+	var crlf int
+	for _, e := range ents {
+		switch e.Name {
+		case gemMetadata:
+			gzr, err := gzip.NewReader(bytes.NewReader(e.Body))
+			if err != nil {
+				return nil, errors.Wrap(err, "initializing metadata gzip reader")
+			}
+			b, err := io.ReadAll(gzr)
+			if err != nil {
+				return nil, errors.Wrap(err, "reading metadata")
+			}
+			h := sha256.Sum256(b)
+			files = append(files, file{e.Name, hex.EncodeToString(h[:])})
+		case gemData:
+			gzr, err := gzip.NewReader(bytes.NewReader(e.Body))
+			if err != nil {
+				return nil, errors.Wrap(err, "initializing data gzip reader")
+			}
+			cs, err := NewContentSummaryFromTar(tar.NewReader(gzr))
+			if err != nil {
+				return nil, errors.Wrap(err, "summarizing data")
+			}
+			for i, name := range cs.Files {
+				files = append(files, file{gemData + "/" + name, cs.FileHashes[i]})
+			}
+			crlf += cs.CRLFCount
+		default:
+			h := sha256.Sum256(e.Body)
+			files = append(files, file{e.Name, hex.EncodeToString(h[:])})
+		}
+	}
+	// NOTE: Diff requires the files to be sorted.
+	sort.Slice(files, func(i, j int) bool { return files[i].name < files[j].name })
+	cs := ContentSummary{Files: make([]string, 0), FileHashes: make([]string, 0), CRLFCount: crlf}
+	for _, f := range files {
+		cs.Files = append(cs.Files, f.name)
+		cs.FileHashes = append(cs.FileHashes, f.hash)
+	}
+	return &cs, nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package archive_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/pkg/archive"
+	"github.com/google/oss-rebuild/pkg/archive/archivetest"
+)
+
+func gemSpec(date string) string {
+	return "--- !ruby/object:Gem::Specification\nname: foo\ndate: " + date + "\nrubygems_version: 3.5.3\n"
+}
+
+func TestStabilizeGem(t *testing.T) {
+	build := func(date string, mtime time.Time, extra ...archive.TarEntry) []byte {
+		data := []archive.TarEntry{
+			{Header: &tar.Header{Name: "lib/foo.rb", Typeflag: tar.TypeReg, Mode: 0644, ModTime: mtime}, Body: []byte("module Foo; end\n")},
+			{Header: &tar.Header{Name: "README.md", Typeflag: tar.TypeReg, Mode: 0644, ModTime: mtime}, Body: []byte("# foo\n")},
+		}
+		buf, err := archivetest.GemFile(gemSpec(date), data, extra...)
+		if err != nil {
+			t.Fatalf("GemFile() error = %v", err)
+		}
+		return buf.Bytes()
+	}
+	upstream := build("2024-04-05 00:00:00.000000000 Z", time.Date(2024, 4, 5, 1, 2, 3, 0, time.UTC),
+		archive.TarEntry{Header: &tar.Header{Name: "checksums.yaml.gz", Typeflag: tar.TypeReg}, Body: []byte("checksums")},
+		archive.TarEntry{Header: &tar.Header{Name: "data.tar.gz.sig", Typeflag: tar.TypeReg}, Body: []byte("signature")},
+	)
+	rebuild := build("2024-10-17 00:00:00.000000000 Z", time.Date(2024, 10, 17, 4, 5, 6, 0, time.UTC),
+		archive.TarEntry{Header: &tar.Header{Name: "checksums.yaml.gz", Typeflag: tar.TypeReg}, Body: []byte("other checksums")},
+	)
+	stabilize := func(b []byte, log archive.StabilizationLog) []byte {
+		var out bytes.Buffer
+		opts := archive.StabilizeOpts{Stabilizers: archive.AllStabilizers, Log: log}
+		if err := archive.StabilizeWithOpts(&out, bytes.NewReader(b), archive.GemFormat, opts); err != nil {
+			t.Fatalf("StabilizeWithOpts() error = %v", err)
+		}
+		return out.Bytes()
+	}
+	log := make(archive.StabilizationLog)
+	up, rb := stabilize(upstream, log), stabilize(rebuild, nil)
+	if !bytes.Equal(up, rb) {
+		t.Error("stabilized gems differ")
+	}
+	for _, want := range []struct{ entry, stabilizer string }{
+		{"metadata.gz", "gem-date"},
+		{archive.ArchiveScope, "gem-checksums"},
+		{archive.ArchiveScope, "gem-signatures"},
+		{"data.tar.gz", "tar-file-order"},
+		{"data.tar.gz/lib/foo.rb", "tar-time"},
+	} {
+		found := false
+		for _, name := range log[want.entry] {
+			found = found || name == want.stabilizer
+		}
+		if !found {
+			t.Errorf("log missing %s for %s: %v", want.stabilizer, want.entry, log)
+		}
+	}
+	cs, err := archive.NewContentSummary(bytes.NewReader(up), archive.GemFormat)
+	if err != nil {
+		t.Fatalf("NewContentSummary() error = %v", err)
+	}
+	if diff := cmp.Diff([]string{"data.tar.gz/README.md", "data.tar.gz/lib/foo.rb", "metadata.gz"}, cs.Files); diff != "" {
+		t.Errorf("ContentSummary files mismatch (-want +got):\n%s", diff)
+	}
+}
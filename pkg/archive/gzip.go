@@ -1,9 +1,14 @@
 package archive
 
 import (
+	"bufio"
+	"bytes"
 	"compress/gzip"
 	"io"
+	"reflect"
 	"time"
+
+	"github.com/pkg/errors"
 )
 
 // defaultCompression enables us to configure the default compression value
@@ -23,7 +28,13 @@ func NewStabilizedGzipWriter(gr *gzip.Reader, w io.Writer, opts StabilizeOpts) (
 	for _, s := range opts.Stabilizers {
 		switch s.(type) {
 		case GzipStabilizer:
-			s.(GzipStabilizer).Func(&mh)
+			stabilizer := s.(GzipStabilizer)
+			before, compression := header, mh.Compression
+			before.Extra = bytes.Clone(header.Extra)
+			stabilizer.Func(&mh)
+			if opts.Log != nil && (!reflect.DeepEqual(before, header) || compression != mh.Compression) {
+				opts.Log.record(ArchiveScope, stabilizer.Name)
+			}
 		}
 	}
 	gw, err := gzip.NewWriterLevel(w, mh.Compression)
@@ -83,3 +94,74 @@ var StableGzipMisc = GzipStabilizer{
 		h.OS = 255 // unknown
 	},
 }
+
+// StableGzipMaxCompression applies the maximum compression level, matching
+// the deterministic re-compression applied to npm build outputs.
+//
+// NOTE: This is not included in AllGzipStabilizers since StableGzipCompression
+// already normalizes the compression level for comparison purposes.
+var StableGzipMaxCompression = GzipStabilizer{
+	Name: "gzip-max-compression",
+	Func: func(h *MutableGzipHeader) {
+		h.Compression = gzip.BestCompression
+	},
+}
+
+// GzipMetadata describes the parameters with which a gzip stream was produced.
+type GzipMetadata struct {
+	gzip.Header
+	// Level is the compression level indicated by the XFL header flag.
+	// Only gzip.BestCompression and gzip.BestSpeed are distinguishable so all
+	// other levels are reported as gzip.DefaultCompression.
+	Level int
+	// Members is the number of concatenated gzip streams.
+	Members int
+}
+
+const (
+	gzipID1 = 0x1f
+	gzipID2 = 0x8b
+	// xflBest and xflFastest are the XFL values written for deflate streams.
+	xflBest    = 0x2
+	xflFastest = 0x4
+)
+
+// ReadGzipMetadata reads the header of each gzip member in r.
+// The returned metadata reflects the header of the first member.
+func ReadGzipMetadata(r io.Reader) (*GzipMetadata, error) {
+	br := bufio.NewReader(r)
+	// NOTE: The XFL byte is not exposed by gzip.Reader so read it from the raw header.
+	raw, err := br.Peek(10)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading gzip header")
+	}
+	if raw[0] != gzipID1 || raw[1] != gzipID2 {
+		return nil, gzip.ErrHeader
+	}
+	md := &GzipMetadata{Level: gzip.DefaultCompression}
+	switch raw[8] {
+	case xflBest:
+		md.Level = gzip.BestCompression
+	case xflFastest:
+		md.Level = gzip.BestSpeed
+	}
+	gr, err := gzip.NewReader(br)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading gzip header")
+	}
+	md.Header = gr.Header
+	gr.Multistream(false)
+	for {
+		if _, err := io.Copy(io.Discard, gr); err != nil {
+			return nil, errors.Wrap(err, "reading gzip member")
+		}
+		md.Members++
+		if err := gr.Reset(br); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, errors.Wrap(err, "reading gzip header")
+		}
+		gr.Multistream(false)
+	}
+	return md, nil
+}
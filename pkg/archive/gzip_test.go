@@ -118,3 +118,27 @@ func TestNewStabilizedGzipWriter(t *testing.T) {
 		})
 	}
 }
+
+func TestReadGzipMetadata(t *testing.T) {
+	header := gzip.Header{Name: "pkg.tar", ModTime: time.Unix(1000, 0), OS: 3}
+	buf := &bytes.Buffer{}
+	gw := must(gzip.NewWriterLevel(buf, gzip.BestCompression))
+	gw.Header = header
+	must(gw.Write([]byte("hello")))
+	orDie(gw.Close())
+	// Append a second member.
+	gw = must(gzip.NewWriterLevel(buf, gzip.BestSpeed))
+	must(gw.Write([]byte("world")))
+	orDie(gw.Close())
+	got, err := ReadGzipMetadata(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &GzipMetadata{Header: header, Level: gzip.BestCompression, Members: 2}
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(time.Time{})); diff != "" {
+		t.Errorf("ReadGzipMetadata() mismatch (-want +got):\n%s", diff)
+	}
+	if _, err := ReadGzipMetadata(bytes.NewReader([]byte("not gzip data"))); err == nil {
+		t.Error("ReadGzipMetadata() on non-gzip data succeeded, want error")
+	}
+}
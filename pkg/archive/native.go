@@ -0,0 +1,257 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package archive
+
+import (
+	"bytes"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"encoding/binary"
+	"io"
+)
+
+// NativeBinaryOpts configures the stabilization of native binaries.
+type NativeBinaryOpts struct {
+	// Strict limits stabilization to identifiers and timestamps within the
+	// binary's headers: ELF build IDs, PE timestamps and checksums, and
+	// Mach-O UUIDs. When unset, non-semantic section metadata is also cleared:
+	// ELF .comment and .gnu_debuglink sections, PE debug directories, and
+	// Mach-O code signatures.
+	//
+	// NOTE: In neither mode are code or data sections modified, so semantic
+	// changes are never masked.
+	Strict bool
+}
+
+// StabilizeNativeBinary returns a copy of content with non-semantic metadata
+// cleared if it is an ELF, PE, or Mach-O binary. The boolean result reports
+// whether content was recognized as a binary and modified.
+func StabilizeNativeBinary(content []byte, opts NativeBinaryOpts) ([]byte, bool) {
+	var stabilize func([]byte, NativeBinaryOpts) bool
+	switch {
+	case bytes.HasPrefix(content, []byte(elf.ELFMAG)):
+		stabilize = stabilizeELF
+	case bytes.HasPrefix(content, []byte("MZ")):
+		stabilize = stabilizePE
+	case len(content) >= 4 && isMachOMagic(content[:4]):
+		stabilize = stabilizeMachO
+	default:
+		return content, false
+	}
+	out := bytes.Clone(content)
+	if !stabilize(out, opts) {
+		return content, false
+	}
+	return out, true
+}
+
+// zero clears b[off:off+n], returning whether the range was in bounds.
+func zero(b []byte, off, n uint64) bool {
+	if off > uint64(len(b)) || n > uint64(len(b))-off {
+		return false
+	}
+	clear(b[off : off+n])
+	return true
+}
+
+func stabilizeELF(b []byte, opts NativeBinaryOpts) bool {
+	f, err := elf.NewFile(bytes.NewReader(b))
+	if err != nil {
+		return false
+	}
+	var changed bool
+	for _, s := range f.Sections {
+		if s.Type == elf.SHT_NOBITS {
+			continue
+		}
+		switch {
+		case s.Type == elf.SHT_NOTE:
+			changed = stabilizeELFNotes(b, s, f.ByteOrder) || changed
+		case !opts.Strict && (s.Name == ".comment" || s.Name == ".gnu_debuglink"):
+			changed = zero(b, s.Offset, s.Size) || changed
+		}
+	}
+	return changed
+}
+
+const (
+	ntGNUBuildID = 3
+	ntGoBuildID  = 4
+)
+
+// stabilizeELFNotes clears the descriptors of GNU and Go build ID notes.
+func stabilizeELFNotes(b []byte, s *elf.Section, bo binary.ByteOrder) bool {
+	if s.Offset > uint64(len(b)) || s.Size > uint64(len(b))-s.Offset {
+		return false
+	}
+	notes := b[s.Offset : s.Offset+s.Size]
+	align := func(n uint32) uint64 { return (uint64(n) + 3) &^ 3 }
+	var changed bool
+	for off := uint64(0); off+12 <= uint64(len(notes)); {
+		namesz, descsz, typ := bo.Uint32(notes[off:]), bo.Uint32(notes[off+4:]), bo.Uint32(notes[off+8:])
+		nameOff := off + 12
+		descOff := nameOff + align(namesz)
+		if descOff+uint64(descsz) > uint64(len(notes)) {
+			break
+		}
+		name := string(bytes.TrimRight(notes[nameOff:nameOff+uint64(namesz)], "\x00"))
+		if (name == "GNU" && typ == ntGNUBuildID) || (name == "Go" && typ == ntGoBuildID) {
+			clear(notes[descOff : descOff+uint64(descsz)])
+			changed = true
+		}
+		off = descOff + align(descsz)
+	}
+	return changed
+}
+
+const (
+	peDebugDirectory   = 6
+	peDebugEntrySize   = 28
+	peDebugCodeView    = 2
+	peCheckSumOffset   = 64
+	peCoffHeaderOffset = 4
+)
+
+func stabilizePE(b []byte, opts NativeBinaryOpts) bool {
+	f, err := pe.NewFile(bytes.NewReader(b))
+	if err != nil || len(b) < 0x40 {
+		return false
+	}
+	signature := uint64(binary.LittleEndian.Uint32(b[0x3c:]))
+	coff := signature + peCoffHeaderOffset
+	changed := zero(b, coff+4, 4) // TimeDateStamp
+	optional := coff + uint64(binary.Size(pe.FileHeader{}))
+	if f.OptionalHeader != nil {
+		changed = zero(b, optional+peCheckSumOffset, 4) || changed
+	}
+	if opts.Strict {
+		return changed
+	}
+	var dir pe.DataDirectory
+	switch oh := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		if oh.NumberOfRvaAndSizes > peDebugDirectory {
+			dir = oh.DataDirectory[peDebugDirectory]
+		}
+	case *pe.OptionalHeader64:
+		if oh.NumberOfRvaAndSizes > peDebugDirectory {
+			dir = oh.DataDirectory[peDebugDirectory]
+		}
+	}
+	start, ok := peFileOffset(f, dir.VirtualAddress)
+	if !ok {
+		return changed
+	}
+	for off := start; off+peDebugEntrySize <= start+uint64(dir.Size) && off+peDebugEntrySize <= uint64(len(b)); off += peDebugEntrySize {
+		zero(b, off+4, 4) // TimeDateStamp
+		if binary.LittleEndian.Uint32(b[off+12:]) == peDebugCodeView {
+			// NOTE: The RSDS record's GUID and age identify the matching PDB.
+			raw := uint64(binary.LittleEndian.Uint32(b[off+24:]))
+			if raw+24 <= uint64(len(b)) && string(b[raw:raw+4]) == "RSDS" {
+				zero(b, raw+4, 20)
+			}
+		}
+		changed = true
+	}
+	return changed
+}
+
+// peFileOffset translates a relative virtual address to a file offset.
+func peFileOffset(f *pe.File, rva uint32) (uint64, bool) {
+	if rva == 0 {
+		return 0, false
+	}
+	for _, s := range f.Sections {
+		if rva >= s.VirtualAddress && rva < s.VirtualAddress+s.Size {
+			return uint64(rva - s.VirtualAddress + s.Offset), true
+		}
+	}
+	return 0, false
+}
+
+const (
+	machoLoadUUID          = 0x1b
+	machoLoadCodeSignature = 0x1d
+)
+
+func isMachOMagic(b []byte) bool {
+	for _, bo := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		switch bo.Uint32(b) {
+		case macho.Magic32, macho.Magic64:
+			return true
+		}
+	}
+	return false
+}
+
+func stabilizeMachO(b []byte, opts NativeBinaryOpts) bool {
+	f, err := macho.NewFile(bytes.NewReader(b))
+	if err != nil {
+		return false
+	}
+	bo := f.ByteOrder
+	off := uint64(binary.Size(macho.FileHeader{}))
+	if f.Magic == macho.Magic64 {
+		off += 4 // reserved
+	}
+	var changed bool
+	for i := uint32(0); i < f.Ncmd && off+8 <= uint64(len(b)); i++ {
+		cmd, size := bo.Uint32(b[off:]), uint64(bo.Uint32(b[off+4:]))
+		switch {
+		case cmd == machoLoadUUID:
+			changed = zero(b, off+8, 16) || changed
+		case cmd == machoLoadCodeSignature && !opts.Strict && off+16 <= uint64(len(b)):
+			dataoff, datasize := uint64(bo.Uint32(b[off+8:])), uint64(bo.Uint32(b[off+12:]))
+			changed = zero(b, dataoff, datasize) || changed
+		}
+		if size < 8 {
+			break
+		}
+		off += size
+	}
+	return changed
+}
+
+// NewNativeBinaryStabilizers returns tar and zip stabilizers that clear
+// non-semantic metadata from native binaries within an archive.
+func NewNativeBinaryStabilizers(opts NativeBinaryOpts) []any {
+	return []any{
+		TarEntryStabilizer{
+			Name: "tar-native-binaries",
+			Func: func(e *TarEntry) {
+				if body, ok := StabilizeNativeBinary(e.Body, opts); ok {
+					e.Body = body
+				}
+			},
+		},
+		ZipEntryStabilizer{
+			Name: "zip-native-binaries",
+			Func: func(zf *MutableZipFile) {
+				f, err := zf.Open()
+				if err != nil {
+					return
+				}
+				content, err := io.ReadAll(f)
+				if err != nil {
+					return
+				}
+				if body, ok := StabilizeNativeBinary(content, opts); ok {
+					zf.SetContent(body)
+				}
+			},
+		},
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package archive
+
+import (
+	"bytes"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"encoding/binary"
+	"os"
+	"runtime"
+	"testing"
+)
+
+func TestStabilizeNativeBinaryELF(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("requires an ELF test binary")
+	}
+	exe := must(os.ReadFile(must(os.Executable())))
+	for _, strict := range []bool{true, false} {
+		got, ok := StabilizeNativeBinary(exe, NativeBinaryOpts{Strict: strict})
+		if !ok {
+			t.Fatalf("StabilizeNativeBinary(strict=%v) did not modify binary", strict)
+		}
+		f := must(elf.NewFile(bytes.NewReader(got)))
+		s := f.Section(".note.go.buildid")
+		if s == nil {
+			t.Skip("test binary lacks a Go build ID")
+		}
+		data := must(s.Data())
+		// The note's descriptor follows the 12 byte header and 4 byte aligned "Go\x00" name.
+		if desc := data[16:]; len(bytes.Trim(desc, "\x00")) != 0 {
+			t.Errorf("StabilizeNativeBinary(strict=%v) build ID = %q, want zeroed", strict, desc)
+		}
+		text := f.Section(".text")
+		if !bytes.Equal(must(text.Data()), must(must(elf.NewFile(bytes.NewReader(exe))).Section(".text").Data())) {
+			t.Errorf("StabilizeNativeBinary(strict=%v) modified .text", strict)
+		}
+	}
+}
+
+func makePE(t *testing.T, timestamp, checksum uint32) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	dos := make([]byte, 0x40)
+	copy(dos, "MZ")
+	binary.LittleEndian.PutUint32(dos[0x3c:], 0x40)
+	buf.Write(dos)
+	buf.WriteString("PE\x00\x00")
+	oh := pe.OptionalHeader64{Magic: 0x20b, CheckSum: checksum, NumberOfRvaAndSizes: 16}
+	fh := pe.FileHeader{Machine: pe.IMAGE_FILE_MACHINE_AMD64, TimeDateStamp: timestamp, SizeOfOptionalHeader: uint16(binary.Size(oh))}
+	orDie(binary.Write(&buf, binary.LittleEndian, fh))
+	orDie(binary.Write(&buf, binary.LittleEndian, oh))
+	return buf.Bytes()
+}
+
+func TestStabilizeNativeBinaryPE(t *testing.T) {
+	got, ok := StabilizeNativeBinary(makePE(t, 0x5f5e1000, 0x1234), NativeBinaryOpts{Strict: true})
+	if !ok {
+		t.Fatal("StabilizeNativeBinary() did not modify binary")
+	}
+	f := must(pe.NewFile(bytes.NewReader(got)))
+	if f.TimeDateStamp != 0 {
+		t.Errorf("TimeDateStamp = %x, want 0", f.TimeDateStamp)
+	}
+	if cs := f.OptionalHeader.(*pe.OptionalHeader64).CheckSum; cs != 0 {
+		t.Errorf("CheckSum = %x, want 0", cs)
+	}
+}
+
+func TestStabilizeNativeBinaryMachO(t *testing.T) {
+	var buf bytes.Buffer
+	orDie(binary.Write(&buf, binary.LittleEndian, macho.FileHeader{Magic: macho.Magic64, Cpu: macho.CpuAmd64, Type: macho.TypeExec, Ncmd: 1, Cmdsz: 24}))
+	orDie(binary.Write(&buf, binary.LittleEndian, uint32(0))) // reserved
+	orDie(binary.Write(&buf, binary.LittleEndian, []uint32{machoLoadUUID, 24}))
+	buf.Write(bytes.Repeat([]byte{0xab}, 16))
+	got, ok := StabilizeNativeBinary(buf.Bytes(), NativeBinaryOpts{Strict: true})
+	if !ok {
+		t.Fatal("StabilizeNativeBinary() did not modify binary")
+	}
+	if uuid := got[len(got)-16:]; !bytes.Equal(uuid, make([]byte, 16)) {
+		t.Errorf("UUID = %x, want zeroed", uuid)
+	}
+}
+
+func TestStabilizeNativeBinaryOther(t *testing.T) {
+	for _, input := range []string{"plain text", "MZ but not a PE", elf.ELFMAG + "truncated"} {
+		if got, ok := StabilizeNativeBinary([]byte(input), NativeBinaryOpts{}); ok || string(got) != input {
+			t.Errorf("StabilizeNativeBinary(%q) = %q, %v; want unmodified", input, got, ok)
+		}
+	}
+}
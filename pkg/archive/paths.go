@@ -0,0 +1,118 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package archive
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// BuildPathPlaceholder is substituted for build paths embedded in file contents.
+const BuildPathPlaceholder = "/__BUILD_PATH__"
+
+// DefaultBuildPathRoots are common locations in which packages are built.
+//
+// NOTE: Temporary directories are excluded as paths under them may be
+// legitimately embedded in upstream artifacts.
+var DefaultBuildPathRoots = []string{"/src", "/workspace"}
+
+// pathChars are the characters considered to continue a path component.
+const pathChars = `A-Za-z0-9._-`
+
+// BuildPathRewriter replaces absolute build paths in text with BuildPathPlaceholder.
+type BuildPathRewriter struct {
+	re *regexp.Regexp
+}
+
+// NewBuildPathRewriter returns a rewriter for the provided build path roots.
+//
+// Roots are absolute paths which are matched only as whole path components
+// such that "/src" matches "/src/index.js" but not "/usr/src" or "/srcs".
+// A trailing "/*" matches any single component e.g. "/tmp/*" matches
+// temporary directories like "/tmp/tmpa1b2c3".
+func NewBuildPathRewriter(roots []string) *BuildPathRewriter {
+	if len(roots) == 0 {
+		return &BuildPathRewriter{}
+	}
+	var alts []string
+	for _, root := range roots {
+		if base, ok := strings.CutSuffix(root, "/*"); ok {
+			alts = append(alts, regexp.QuoteMeta(base)+"/["+pathChars+"]+")
+		} else {
+			alts = append(alts, regexp.QuoteMeta(root))
+		}
+	}
+	// NOTE: RE2 lacks lookaround so the delimiting characters are captured and restored.
+	pattern := `(^|[^/` + pathChars + `])(?:` + strings.Join(alts, "|") + `)($|[^` + pathChars + `])`
+	return &BuildPathRewriter{re: regexp.MustCompile(pattern)}
+}
+
+// Rewrite returns the content with build paths replaced and whether any were found.
+// Content that does not appear to be text is returned unmodified.
+func (r *BuildPathRewriter) Rewrite(content []byte) ([]byte, bool) {
+	if r.re == nil || !isText(content) || !r.re.Match(content) {
+		return content, false
+	}
+	// NOTE: Matches consume the following delimiter so adjacent paths sharing a
+	// single delimiter (e.g. "/src:/src") require a second pass.
+	repl := []byte("${1}" + BuildPathPlaceholder + "${2}")
+	for r.re.Match(content) {
+		content = r.re.ReplaceAll(content, repl)
+	}
+	return content, true
+}
+
+func isText(content []byte) bool {
+	return bytes.IndexByte(content, 0) == -1 && utf8.Valid(content)
+}
+
+// NewBuildPathStabilizers returns tar and zip stabilizers that normalize
+// build paths under the provided roots embedded in text file contents.
+func NewBuildPathStabilizers(roots []string) []any {
+	r := NewBuildPathRewriter(roots)
+	return []any{
+		TarEntryStabilizer{
+			Name: "tar-build-paths",
+			Func: func(e *TarEntry) {
+				body, ok := r.Rewrite(e.Body)
+				if !ok {
+					return
+				}
+				e.Body = body
+				e.Size = int64(len(body))
+			},
+		},
+		ZipEntryStabilizer{
+			Name: "zip-build-paths",
+			Func: func(zf *MutableZipFile) {
+				f, err := zf.Open()
+				if err != nil {
+					// NOTE: Unreadable entries are left for the writer to report.
+					return
+				}
+				content, err := io.ReadAll(f)
+				if err != nil {
+					return
+				}
+				if body, ok := r.Rewrite(content); ok {
+					zf.SetContent(body)
+				}
+			},
+		},
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBuildPathRewriter(t *testing.T) {
+	r := NewBuildPathRewriter(DefaultBuildPathRoots)
+	for _, tc := range []struct {
+		name    string
+		input   string
+		want    string
+		changed bool
+	}{
+		{
+			name:    "source map",
+			input:   `{"sources":["/src/lib/index.ts","webpack:///src/other.ts"]}`,
+			want:    `{"sources":["/__BUILD_PATH__/lib/index.ts","webpack:///src/other.ts"]}`,
+			changed: true,
+		},
+		{
+			name:    "bare root",
+			input:   "cwd=/workspace\n",
+			want:    "cwd=/__BUILD_PATH__\n",
+			changed: true,
+		},
+		{
+			name:  "tmpdir",
+			input: "File \"/tmp/pip-build-a1b2c3/setup.py\", line 1",
+			want:  "File \"/tmp/pip-build-a1b2c3/setup.py\", line 1",
+		},
+		{
+			name:    "adjacent",
+			input:   "PATH=/src:/workspace:/tmp/x",
+			want:    "PATH=/__BUILD_PATH__:/__BUILD_PATH__:/tmp/x",
+			changed: true,
+		},
+		{
+			name:  "nested and partial components",
+			input: "/usr/src/linux /srcs/foo /workspaces src/index.js",
+			want:  "/usr/src/linux /srcs/foo /workspaces src/index.js",
+		},
+		{
+			name:  "binary",
+			input: "\x00/src/index.js",
+			want:  "\x00/src/index.js",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, changed := r.Rewrite([]byte(tc.input))
+			if string(got) != tc.want || changed != tc.changed {
+				t.Errorf("Rewrite(%q) = %q, %v; want %q, %v", tc.input, got, changed, tc.want, tc.changed)
+			}
+		})
+	}
+}
+
+func TestBuildPathRewriterWildcard(t *testing.T) {
+	r := NewBuildPathRewriter([]string{"/tmp/*"})
+	for input, want := range map[string]string{
+		"File \"/tmp/pip-build-a1b2c3/setup.py\", line 1": "File \"/__BUILD_PATH__/setup.py\", line 1",
+		"cd /tmp/x && ls /tmp":                            "cd /__BUILD_PATH__ && ls /tmp",
+		"/var/tmp/x":                                      "/var/tmp/x",
+	} {
+		if got, _ := r.Rewrite([]byte(input)); string(got) != want {
+			t.Errorf("Rewrite(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestBuildPathStabilizers(t *testing.T) {
+	input := []*TarEntry{
+		{&tar.Header{Name: "package/index.js.map", Typeflag: tar.TypeReg, Size: 21, Mode: 0644}, []byte(`{"file":"/src/index"}`)},
+		{&tar.Header{Name: "package/index.js", Typeflag: tar.TypeReg, Size: 4, Mode: 0644}, []byte(`x=1;`)},
+	}
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range input {
+		orDie(e.WriteTo(tw))
+	}
+	orDie(tw.Close())
+	var out bytes.Buffer
+	opts := StabilizeOpts{Stabilizers: NewBuildPathStabilizers([]string{"/src"})}
+	orDie(StabilizeTar(tar.NewReader(&buf), tar.NewWriter(&out), opts))
+	tr := tar.NewReader(&out)
+	var got []string
+	for {
+		if _, err := tr.Next(); err != nil {
+			break
+		}
+		got = append(got, string(must(io.ReadAll(tr))))
+	}
+	want := []string{`{"file":"/__BUILD_PATH__/index"}`, `x=1;`}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("StabilizeTar() mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -21,8 +21,10 @@ import (
 	"encoding/hex"
 	"io"
 	"io/fs"
+	"maps"
 	"os"
 	"path/filepath"
+	"reflect"
 	"slices"
 	"strings"
 	"time"
@@ -48,6 +50,27 @@ func (e TarEntry) WriteTo(tw *tar.Writer) error {
 	return nil
 }
 
+// clone returns a copy of the entry that does not share mutable state.
+func (e TarEntry) clone() TarEntry {
+	h := *e.Header
+	h.PAXRecords = maps.Clone(h.PAXRecords)
+	h.Xattrs = maps.Clone(h.Xattrs)
+	return TarEntry{Header: &h, Body: e.Body}
+}
+
+// equal returns whether the entries have identical headers and contents.
+func (e TarEntry) equal(other *TarEntry) bool {
+	return reflect.DeepEqual(*e.Header, *other.Header) && bytes.Equal(e.Body, other.Body)
+}
+
+func tarEntryNames(ents []*TarEntry) []string {
+	var names []string
+	for _, e := range ents {
+		names = append(names, e.Name)
+	}
+	return names
+}
+
 type TarArchive struct {
 	Files []*TarEntry
 }
@@ -154,22 +177,41 @@ func StabilizeTar(tr *tar.Reader, tw *tar.Writer, opts StabilizeOpts) error {
 		ents = append(ents, &TarEntry{header, buf[:]})
 	}
 	f := TarArchive{Files: ents}
+	stabilizeTarArchive(&f, opts)
+	for _, ent := range f.Files {
+		if err := ent.WriteTo(tw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stabilizeTarArchive applies the tar stabilizers in opts to the archive.
+func stabilizeTarArchive(f *TarArchive, opts StabilizeOpts) {
 	for _, s := range opts.Stabilizers {
 		switch s.(type) {
 		case TarArchiveStabilizer:
-			s.(TarArchiveStabilizer).Func(&f)
+			stabilizer := s.(TarArchiveStabilizer)
+			before := tarEntryNames(f.Files)
+			stabilizer.Func(f)
+			if opts.Log != nil && !slices.Equal(before, tarEntryNames(f.Files)) {
+				opts.Log.record(ArchiveScope, stabilizer.Name)
+			}
 		case TarEntryStabilizer:
+			stabilizer := s.(TarEntryStabilizer)
 			for _, ent := range f.Files {
-				s.(TarEntryStabilizer).Func(ent)
+				if opts.Log == nil {
+					stabilizer.Func(ent)
+					continue
+				}
+				before := ent.clone()
+				stabilizer.Func(ent)
+				if !before.equal(ent) {
+					opts.Log.record(before.Name, stabilizer.Name)
+				}
 			}
 		}
 	}
-	for _, ent := range f.Files {
-		if err := ent.WriteTo(tw); err != nil {
-			return err
-		}
-	}
-	return nil
 }
 
 // ExtractOptions provides options modifying ExtractTar behavior.
@@ -22,6 +22,7 @@ import (
 	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/ulikunitz/xz"
 )
 
 var epoch = time.UnixMilli(0)
@@ -104,3 +105,58 @@ func TestStabilizeTar(t *testing.T) {
 		})
 	}
 }
+
+func TestStabilizeTarXz(t *testing.T) {
+	makeTarXz := func(modTime time.Time) []byte {
+		var buf bytes.Buffer
+		xzw := must(xz.NewWriter(&buf))
+		tw := tar.NewWriter(xzw)
+		orDie(tw.WriteHeader(&tar.Header{Name: "foo/bin", Typeflag: tar.TypeReg, Size: 3, Mode: 0755, ModTime: modTime}))
+		must(tw.Write([]byte("bin")))
+		orDie(tw.Close())
+		orDie(xzw.Close())
+		return buf.Bytes()
+	}
+	var first, second bytes.Buffer
+	if err := Stabilize(&first, bytes.NewReader(makeTarXz(time.Now())), TarXzFormat); err != nil {
+		t.Fatalf("Stabilize() = %v, want nil", err)
+	}
+	if err := Stabilize(&second, bytes.NewReader(makeTarXz(time.Now().Add(time.Hour))), TarXzFormat); err != nil {
+		t.Fatalf("Stabilize() = %v, want nil", err)
+	}
+	if !bytes.Equal(first.Bytes(), second.Bytes()) {
+		t.Errorf("Stabilize() outputs differ for archives differing only in mtime")
+	}
+	cs, err := NewContentSummary(bytes.NewReader(first.Bytes()), TarXzFormat)
+	if err != nil {
+		t.Fatalf("NewContentSummary() = %v, want nil", err)
+	}
+	if diff := cmp.Diff([]string{"foo/bin"}, cs.Files); diff != "" {
+		t.Errorf("NewContentSummary() files mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestStabilizeTarLog(t *testing.T) {
+	var input bytes.Buffer
+	{
+		tw := tar.NewWriter(&input)
+		for _, entry := range []*TarEntry{
+			{&tar.Header{Name: "b", Typeflag: tar.TypeReg, Size: 1, Mode: 0777, Uid: 10}, []byte("b")},
+			{&tar.Header{Name: "a", Typeflag: tar.TypeReg, Size: 1, Mode: 0644}, []byte("a")},
+		} {
+			orDie(entry.WriteTo(tw))
+		}
+		orDie(tw.Close())
+	}
+	log := StabilizationLog{}
+	opts := StabilizeOpts{Stabilizers: []any{StableTarFileOrder, StableTarFileMode, StableTarOwners}, Log: log}
+	orDie(StabilizeTar(tar.NewReader(&input), tar.NewWriter(io.Discard), opts))
+	want := StabilizationLog{
+		ArchiveScope: {"tar-file-order"},
+		"a":          {"tar-file-mode"},
+		"b":          {"tar-owners"},
+	}
+	if diff := cmp.Diff(want, log); diff != "" {
+		t.Errorf("StabilizeTar() log mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"io"
+	"reflect"
 	"slices"
 	"strings"
 	"time"
@@ -124,6 +125,14 @@ func (mr MutableZipReader) WriteTo(zw *zip.Writer) error {
 	return nil
 }
 
+func zipEntryNames(files []*MutableZipFile) []string {
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
 type ZipArchiveStabilizer struct {
 	Name string
 	Func func(*MutableZipReader)
@@ -220,10 +229,25 @@ func StabilizeZip(zr *zip.Reader, zw *zip.Writer, opts StabilizeOpts) error {
 	for _, s := range opts.Stabilizers {
 		switch s.(type) {
 		case ZipArchiveStabilizer:
-			s.(ZipArchiveStabilizer).Func(&mr)
+			stabilizer := s.(ZipArchiveStabilizer)
+			names, comment := zipEntryNames(mr.File), mr.Comment
+			stabilizer.Func(&mr)
+			if opts.Log != nil && (!slices.Equal(names, zipEntryNames(mr.File)) || comment != mr.Comment) {
+				opts.Log.record(ArchiveScope, stabilizer.Name)
+			}
 		case ZipEntryStabilizer:
+			stabilizer := s.(ZipEntryStabilizer)
 			for _, mf := range mr.File {
-				s.(ZipEntryStabilizer).Func(mf)
+				if opts.Log == nil {
+					stabilizer.Func(mf)
+					continue
+				}
+				before := *mf
+				before.Extra = bytes.Clone(mf.Extra)
+				stabilizer.Func(mf)
+				if !reflect.DeepEqual(before.FileHeader, mf.FileHeader) || !bytes.Equal(before.mutContent, mf.mutContent) || (before.mutContent == nil) != (mf.mutContent == nil) {
+					opts.Log.record(before.Name, stabilizer.Name)
+				}
 			}
 		}
 	}
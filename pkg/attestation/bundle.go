@@ -0,0 +1,167 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package attestation provides access to published OSS Rebuild attestation bundles.
+package attestation
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"slices"
+
+	"github.com/google/oss-rebuild/internal/verifier"
+	"github.com/google/oss-rebuild/pkg/archive"
+	"github.com/in-toto/in-toto-golang/in_toto"
+	"github.com/pkg/errors"
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+)
+
+type VerifiedEnvelope struct {
+	Raw     *dsse.Envelope
+	Payload *in_toto.ProvenanceStatementSLSA1
+}
+
+type Bundle struct {
+	envelopes []VerifiedEnvelope
+}
+
+func decodeEnvelopePayload(e *dsse.Envelope) (*in_toto.ProvenanceStatementSLSA1, error) {
+	if e.Payload == "" {
+		return nil, errors.New("empty payload")
+	}
+	b, err := base64.StdEncoding.DecodeString(e.Payload)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding base64 payload")
+	}
+	var decoded in_toto.ProvenanceStatementSLSA1
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		return nil, errors.Wrap(err, "unmarshaling payload")
+	}
+	return &decoded, nil
+}
+
+// NewBundle decodes and verifies the JSONL-encoded envelopes of an attestation bundle.
+func NewBundle(ctx context.Context, data []byte, verifier *dsse.EnvelopeVerifier) (*Bundle, error) {
+	d := json.NewDecoder(bytes.NewBuffer(data))
+	var envelopes []VerifiedEnvelope
+	for {
+		var env dsse.Envelope
+		if err := d.Decode(&env); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, errors.Wrap(err, "decoding envelope")
+		}
+		if _, err := verifier.Verify(ctx, &env); err != nil {
+			return nil, errors.Wrap(err, "verifying envelope")
+		}
+		payload, err := decodeEnvelopePayload(&env)
+		if err != nil {
+			return nil, errors.Wrap(err, "decoding payload")
+		}
+		envelopes = append(envelopes, VerifiedEnvelope{
+			Raw:     &env,
+			Payload: payload,
+		})
+	}
+	return &Bundle{envelopes: envelopes}, nil
+}
+
+func (b *Bundle) Payloads() []*in_toto.ProvenanceStatementSLSA1 {
+	result := make([]*in_toto.ProvenanceStatementSLSA1, len(b.envelopes))
+	for i, env := range b.envelopes {
+		result[i] = env.Payload
+	}
+	return result
+}
+
+func (b *Bundle) attestation(buildType string) *in_toto.ProvenanceStatementSLSA1 {
+	for _, env := range b.envelopes {
+		if env.Payload.Predicate.BuildDefinition.BuildType == buildType {
+			return env.Payload
+		}
+	}
+	return nil
+}
+
+func (b *Bundle) RebuildAttestation() (*in_toto.ProvenanceStatementSLSA1, error) {
+	if att := b.attestation(verifier.RebuildBuildType); att != nil {
+		return att, nil
+	}
+	return nil, errors.New("no rebuild attestation found")
+}
+
+func (b *Bundle) EquivalenceAttestation() (*in_toto.ProvenanceStatementSLSA1, error) {
+	if att := b.attestation(verifier.ArtifactEquivalenceBuildType); att != nil {
+		return att, nil
+	}
+	return nil, errors.New("no equivalence attestation found")
+}
+
+func (b *Bundle) StabilizationAttestation() (*in_toto.ProvenanceStatementSLSA1, error) {
+	if att := b.attestation(verifier.StabilizationBuildType); att != nil {
+		return att, nil
+	}
+	return nil, errors.New("no stabilization attestation found")
+}
+
+// Stabilizers returns the sorted names of the stabilizers recorded as having
+// modified either the rebuild or the upstream artifact.
+func (b *Bundle) Stabilizers() ([]string, error) {
+	att, err := b.StabilizationAttestation()
+	if err != nil {
+		return nil, err
+	}
+	var content []byte
+	for _, bp := range att.Predicate.RunDetails.Byproducts {
+		if bp.Name == "stabilizations.json" {
+			content = bp.Content
+		}
+	}
+	if content == nil {
+		return nil, errors.New("stabilizations.json byproduct not found")
+	}
+	var record verifier.StabilizationRecord
+	if err := json.Unmarshal(content, &record); err != nil {
+		return nil, errors.Wrap(err, "decoding stabilizations.json")
+	}
+	var names []string
+	for _, log := range []archive.StabilizationLog{record.Rebuild, record.Upstream} {
+		for _, ss := range log {
+			for _, s := range ss {
+				if !slices.Contains(names, s) {
+					names = append(names, s)
+				}
+			}
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+func (b *Bundle) Byproduct(name string) ([]byte, error) {
+	att, err := b.RebuildAttestation()
+	if err != nil {
+		return nil, errors.Wrap(err, "getting rebuild attestation")
+	}
+	for _, b := range att.Predicate.RunDetails.Byproducts {
+		if b.Name == name {
+			return b.Content, nil
+		}
+	}
+	return nil, errors.Errorf("byproduct %q not found", name)
+}
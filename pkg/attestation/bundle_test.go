@@ -0,0 +1,123 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package attestation
+
+import (
+	"context"
+	"crypto"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/internal/verifier"
+	"github.com/in-toto/in-toto-golang/in_toto"
+	slsa1 "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v1"
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+)
+
+type trustAll struct{}
+
+func (v *trustAll) Verify(ctx context.Context, data, sig []byte) error { return nil }
+func (v *trustAll) KeyID() (string, error)                             { return "", nil }
+func (v *trustAll) Public() crypto.PublicKey                           { return nil }
+
+func envelopeLine(t *testing.T, buildType string, byproducts ...slsa1.ResourceDescriptor) string {
+	t.Helper()
+	stmt := in_toto.ProvenanceStatementSLSA1{
+		StatementHeader: in_toto.StatementHeader{Type: in_toto.StatementInTotoV1, PredicateType: slsa1.PredicateSLSAProvenance},
+		Predicate: slsa1.ProvenancePredicate{
+			BuildDefinition: slsa1.ProvenanceBuildDefinition{BuildType: buildType},
+			RunDetails:      slsa1.ProvenanceRunDetails{Byproducts: byproducts},
+		},
+	}
+	payload, err := json.Marshal(stmt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, err := json.Marshal(dsse.Envelope{
+		PayloadType: in_toto.PayloadType,
+		Payload:     base64.StdEncoding.EncodeToString(payload),
+		Signatures:  []dsse.Signature{{Sig: "c2ln"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(env)
+}
+
+func TestNewBundle(t *testing.T) {
+	ctx := context.Background()
+	v, err := dsse.NewEnvelopeVerifier(&trustAll{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := strings.Join([]string{
+		envelopeLine(t, "https://docs.oss-rebuild.dev/builds/ArtifactEquivalence@v0.1"),
+		envelopeLine(t, verifier.RebuildBuildType, slsa1.ResourceDescriptor{Name: "Dockerfile", Content: []byte("FROM alpine")}),
+	}, "\n")
+	b, err := NewBundle(ctx, []byte(data), v)
+	if err != nil {
+		t.Fatalf("NewBundle() error = %v", err)
+	}
+	if got := len(b.Payloads()); got != 2 {
+		t.Errorf("len(Payloads()) = %d, want 2", got)
+	}
+	dockerfile, err := b.Byproduct("Dockerfile")
+	if err != nil {
+		t.Fatalf("Byproduct() error = %v", err)
+	}
+	if string(dockerfile) != "FROM alpine" {
+		t.Errorf("Byproduct() = %q, want %q", dockerfile, "FROM alpine")
+	}
+	if _, err := b.Byproduct("missing"); err == nil {
+		t.Error("Byproduct() expected error for missing byproduct")
+	}
+	if _, err := NewBundle(ctx, []byte("{"), v); err == nil {
+		t.Error("NewBundle() expected error for malformed input")
+	}
+}
+
+func TestBundleStabilizers(t *testing.T) {
+	ctx := context.Background()
+	v, err := dsse.NewEnvelopeVerifier(&trustAll{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := `{"rebuild":{"<archive>":["tar-file-order"],"package/package.json":["tar-time"]},"upstream":{"<archive>":["tar-file-order","gzip-compression"]}}`
+	data := strings.Join([]string{
+		envelopeLine(t, verifier.ArtifactEquivalenceBuildType),
+		envelopeLine(t, verifier.StabilizationBuildType, slsa1.ResourceDescriptor{Name: "stabilizations.json", Content: []byte(record)}),
+	}, "\n")
+	b, err := NewBundle(ctx, []byte(data), v)
+	if err != nil {
+		t.Fatalf("NewBundle() error = %v", err)
+	}
+	if _, err := b.EquivalenceAttestation(); err != nil {
+		t.Errorf("EquivalenceAttestation() error = %v", err)
+	}
+	if _, err := b.RebuildAttestation(); err == nil {
+		t.Error("RebuildAttestation() expected error for missing attestation")
+	}
+	got, err := b.Stabilizers()
+	if err != nil {
+		t.Fatalf("Stabilizers() error = %v", err)
+	}
+	want := []string{"gzip-compression", "tar-file-order", "tar-time"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Stabilizers() returned diff (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package verify
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"slices"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+)
+
+// TrustBundlePayloadType is the DSSE payload type of a signed TrustBundle.
+const TrustBundlePayloadType = "application/vnd.oss-rebuild.trust-bundle+json"
+
+// Roles that may be granted to a TrustedKey.
+const (
+	// RoleAttestation permits a key to sign attestation bundles.
+	RoleAttestation = "attestation"
+	// RoleRoot permits a key to sign TrustBundles.
+	RoleRoot = "root"
+)
+
+// TrustBundle is a versioned set of keys trusted to sign OSS Rebuild artifacts.
+//
+// Bundles are distributed as DSSE envelopes signed by the bundle's root keys
+// so they can be fetched once, pinned, and used for offline verification.
+type TrustBundle struct {
+	// Version increases with each published bundle to prevent rollback.
+	Version int          `json:"version"`
+	Keys    []TrustedKey `json:"keys"`
+}
+
+// TrustedKey is a public key and the conditions under which it is trusted.
+type TrustedKey struct {
+	// ID is the key ID reported in signatures made by the key.
+	ID string `json:"id"`
+	// PublicKey is the PEM-encoded PKIX public key.
+	PublicKey string   `json:"public_key"`
+	Roles     []string `json:"roles"`
+	// NotBefore and NotAfter, if provided, bound the period during which the key is trusted.
+	NotBefore *time.Time `json:"not_before,omitempty"`
+	NotAfter  *time.Time `json:"not_after,omitempty"`
+}
+
+// ValidAt returns whether the key is trusted at the provided time.
+func (k TrustedKey) ValidAt(t time.Time) bool {
+	return (k.NotBefore == nil || !t.Before(*k.NotBefore)) && (k.NotAfter == nil || t.Before(*k.NotAfter))
+}
+
+// Verifiers returns verifiers for the keys granted role that are valid at the provided time.
+func (b TrustBundle) Verifiers(at time.Time, role string) ([]dsse.Verifier, error) {
+	var verifiers []dsse.Verifier
+	for _, k := range b.Keys {
+		if !slices.Contains(k.Roles, role) || !k.ValidAt(at) {
+			continue
+		}
+		v, err := newPublicKeyVerifier(k)
+		if err != nil {
+			return nil, errors.Wrapf(err, "loading key %s", k.ID)
+		}
+		verifiers = append(verifiers, v)
+	}
+	if len(verifiers) == 0 {
+		return nil, errors.Errorf("no %s keys valid at %s", role, at.Format(time.RFC3339))
+	}
+	return verifiers, nil
+}
+
+// ParseTrustBundle verifies and decodes the signed TrustBundle in data.
+//
+// The envelope must be signed by a root key of trusted. If trusted is nil,
+// the bundle's own root keys are used, in which case the caller is
+// responsible for pinning the bundle obtained from a trusted source.
+func ParseTrustBundle(ctx context.Context, data []byte, trusted *TrustBundle, at time.Time) (*TrustBundle, error) {
+	var env dsse.Envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, errors.Wrap(err, "decoding envelope")
+	}
+	if env.PayloadType != TrustBundlePayloadType {
+		return nil, errors.Errorf("unexpected payload type: %s", env.PayloadType)
+	}
+	payload, err := base64.StdEncoding.DecodeString(env.Payload)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding payload")
+	}
+	var b TrustBundle
+	if err := json.Unmarshal(payload, &b); err != nil {
+		return nil, errors.Wrap(err, "decoding trust bundle")
+	}
+	root := &b
+	if trusted != nil {
+		if b.Version < trusted.Version {
+			return nil, errors.Errorf("trust bundle version %d precedes trusted version %d", b.Version, trusted.Version)
+		}
+		root = trusted
+	}
+	verifiers, err := root.Verifiers(at, RoleRoot)
+	if err != nil {
+		return nil, err
+	}
+	ev, err := dsse.NewEnvelopeVerifier(verifiers...)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating EnvelopeVerifier")
+	}
+	if _, err := ev.Verify(ctx, &env); err != nil {
+		return nil, errors.Wrap(err, "verifying trust bundle")
+	}
+	return &b, nil
+}
+
+// ParsePinnedTrustBundle verifies and decodes the signed TrustBundle in data
+// against the previously pinned signed TrustBundle.
+//
+// data must be signed by a root key of the pinned bundle and must not precede
+// its version. If pinned is empty, no bundle has yet been trusted and data is
+// trusted to authorize its own root keys. On success, data should replace the
+// pinned bundle.
+func ParsePinnedTrustBundle(ctx context.Context, data, pinned []byte, at time.Time) (*TrustBundle, error) {
+	var trusted *TrustBundle
+	if len(pinned) != 0 {
+		var err error
+		trusted, err = ParseTrustBundle(ctx, pinned, nil, at)
+		if err != nil {
+			return nil, errors.Wrap(err, "loading pinned trust bundle")
+		}
+	}
+	return ParseTrustBundle(ctx, data, trusted, at)
+}
+
+// publicKeyVerifier verifies signatures made by a TrustedKey.
+type publicKeyVerifier struct {
+	id  string
+	pub *ecdsa.PublicKey
+}
+
+func newPublicKeyVerifier(k TrustedKey) (*publicKeyVerifier, error) {
+	blk, _ := pem.Decode([]byte(k.PublicKey))
+	if blk == nil {
+		return nil, errors.New("failed to decode PEM public key")
+	}
+	pub, err := x509.ParsePKIXPublicKey(blk.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse PEM public key")
+	}
+	// TODO: Support more key types as necessary.
+	ecKey, ok := pub.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, errors.Errorf("unsupported key type: %T", pub)
+	}
+	return &publicKeyVerifier{id: k.ID, pub: ecKey}, nil
+}
+
+func (v *publicKeyVerifier) Verify(ctx context.Context, data, sig []byte) error {
+	digest := sha256.Sum256(data)
+	if !ecdsa.VerifyASN1(v.pub, digest[:], sig) {
+		return errors.New("signature verification failed")
+	}
+	return nil
+}
+
+func (v *publicKeyVerifier) KeyID() (string, error)   { return v.id, nil }
+func (v *publicKeyVerifier) Public() crypto.PublicKey { return v.pub }
+
+var _ dsse.Verifier = (*publicKeyVerifier)(nil)
@@ -0,0 +1,301 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package verify
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/google/oss-rebuild/internal/verifier"
+	"github.com/in-toto/in-toto-golang/in_toto"
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+)
+
+type testSigner struct {
+	id   string
+	priv *ecdsa.PrivateKey
+}
+
+func newTestSigner(t *testing.T, id string) *testSigner {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testSigner{id: id, priv: priv}
+}
+
+func (s *testSigner) Sign(ctx context.Context, data []byte) ([]byte, error) {
+	digest := sha256.Sum256(data)
+	return ecdsa.SignASN1(rand.Reader, s.priv, digest[:])
+}
+
+func (s *testSigner) KeyID() (string, error) { return s.id, nil }
+
+func (s *testSigner) key(t *testing.T, roles ...string) TrustedKey {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(&s.priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return TrustedKey{ID: s.id, PublicKey: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), Roles: roles}
+}
+
+func sign(t *testing.T, payloadType string, payload any, signers ...dsse.Signer) []byte {
+	t.Helper()
+	body, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	es, err := dsse.NewEnvelopeSigner(signers...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, err := es.SignPayload(context.Background(), payloadType, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestParseTrustBundle(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+	root := newTestSigner(t, "root")
+	next := newTestSigner(t, "next")
+	initial := TrustBundle{Version: 1, Keys: []TrustedKey{root.key(t, RoleRoot)}}
+	expired := root.key(t, RoleRoot)
+	expired.NotAfter = &past
+	pending := root.key(t, RoleRoot)
+	pending.NotBefore = &future
+	for _, tc := range []struct {
+		name    string
+		data    []byte
+		trusted *TrustBundle
+		wantErr bool
+	}{
+		{
+			name: "SelfSigned",
+			data: sign(t, TrustBundlePayloadType, initial, root),
+		},
+		{
+			name:    "SignedByNonRoot",
+			data:    sign(t, TrustBundlePayloadType, TrustBundle{Version: 1, Keys: []TrustedKey{root.key(t, RoleAttestation)}}, root),
+			wantErr: true,
+		},
+		{
+			name:    "WrongPayloadType",
+			data:    sign(t, in_toto.PayloadType, initial, root),
+			wantErr: true,
+		},
+		{
+			name:    "Rotation",
+			data:    sign(t, TrustBundlePayloadType, TrustBundle{Version: 2, Keys: []TrustedKey{next.key(t, RoleRoot)}}, root),
+			trusted: &initial,
+		},
+		{
+			name:    "UntrustedSigner",
+			data:    sign(t, TrustBundlePayloadType, TrustBundle{Version: 2, Keys: []TrustedKey{next.key(t, RoleRoot)}}, next),
+			trusted: &initial,
+			wantErr: true,
+		},
+		{
+			name:    "Rollback",
+			data:    sign(t, TrustBundlePayloadType, TrustBundle{Version: 1}, root),
+			trusted: &TrustBundle{Version: 2, Keys: []TrustedKey{root.key(t, RoleRoot)}},
+			wantErr: true,
+		},
+		{
+			name:    "ExpiredKey",
+			data:    sign(t, TrustBundlePayloadType, TrustBundle{Version: 1, Keys: []TrustedKey{expired}}, root),
+			wantErr: true,
+		},
+		{
+			name:    "NotYetValidKey",
+			data:    sign(t, TrustBundlePayloadType, TrustBundle{Version: 1, Keys: []TrustedKey{pending}}, root),
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseTrustBundle(context.Background(), tc.data, tc.trusted, now)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("ParseTrustBundle() expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseTrustBundle() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestParsePinnedTrustBundle(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	root := newTestSigner(t, "root")
+	next := newTestSigner(t, "next")
+	v1 := sign(t, TrustBundlePayloadType, TrustBundle{Version: 1, Keys: []TrustedKey{root.key(t, RoleRoot)}}, root)
+	v2 := sign(t, TrustBundlePayloadType, TrustBundle{Version: 2, Keys: []TrustedKey{root.key(t, RoleRoot)}}, root)
+	for _, tc := range []struct {
+		name    string
+		data    []byte
+		pinned  []byte
+		wantErr bool
+	}{
+		{
+			name: "FirstUse",
+			data: v1,
+		},
+		{
+			name:   "Update",
+			data:   v2,
+			pinned: v1,
+		},
+		{
+			name:    "Rollback",
+			data:    v1,
+			pinned:  v2,
+			wantErr: true,
+		},
+		{
+			name:    "UnpinnedRoot",
+			data:    sign(t, TrustBundlePayloadType, TrustBundle{Version: 3, Keys: []TrustedKey{next.key(t, RoleRoot)}}, next),
+			pinned:  v1,
+			wantErr: true,
+		},
+		{
+			name:    "MalformedPin",
+			data:    v1,
+			pinned:  []byte("{}"),
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParsePinnedTrustBundle(context.Background(), tc.data, tc.pinned, now)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("ParsePinnedTrustBundle() expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParsePinnedTrustBundle() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifyBundleWithTrustBundle(t *testing.T) {
+	finished := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	before, after := finished.Add(-time.Hour), finished.Add(time.Hour)
+	signer := newTestSigner(t, "attestation")
+	other := newTestSigner(t, "other")
+	anonymous := newTestSigner(t, "")
+	retired := signer.key(t, RoleAttestation)
+	retired.NotAfter = &after
+	expired := signer.key(t, RoleAttestation)
+	expired.NotAfter = &before
+	pending := signer.key(t, RoleAttestation)
+	pending.NotBefore = &after
+	stmt := func(finishedOn *time.Time) in_toto.ProvenanceStatementSLSA1 {
+		var s in_toto.ProvenanceStatementSLSA1
+		s.Type = in_toto.StatementInTotoV1
+		s.Predicate.BuildDefinition.BuildType = verifier.RebuildBuildType
+		s.Predicate.RunDetails.BuildMetadata.FinishedOn = finishedOn
+		return s
+	}
+	for _, tc := range []struct {
+		name    string
+		data    []byte
+		bundle  TrustBundle
+		wantErr bool
+	}{
+		{
+			name:   "Trusted",
+			data:   sign(t, in_toto.PayloadType, stmt(&finished), signer),
+			bundle: TrustBundle{Keys: []TrustedKey{signer.key(t, RoleAttestation)}},
+		},
+		{
+			name:   "RetiredAfterBuild",
+			data:   sign(t, in_toto.PayloadType, stmt(&finished), signer),
+			bundle: TrustBundle{Keys: []TrustedKey{retired}},
+		},
+		{
+			name:    "ExpiredBeforeBuild",
+			data:    sign(t, in_toto.PayloadType, stmt(&finished), signer),
+			bundle:  TrustBundle{Keys: []TrustedKey{expired}},
+			wantErr: true,
+		},
+		{
+			name:    "NotYetValidAtBuild",
+			data:    sign(t, in_toto.PayloadType, stmt(&finished), signer),
+			bundle:  TrustBundle{Keys: []TrustedKey{pending}},
+			wantErr: true,
+		},
+		{
+			name:    "NoFinishTime",
+			data:    sign(t, in_toto.PayloadType, stmt(nil), signer),
+			bundle:  TrustBundle{Keys: []TrustedKey{signer.key(t, RoleAttestation)}},
+			wantErr: true,
+		},
+		{
+			name:    "RootOnly",
+			data:    sign(t, in_toto.PayloadType, stmt(&finished), signer),
+			bundle:  TrustBundle{Keys: []TrustedKey{signer.key(t, RoleRoot)}},
+			wantErr: true,
+		},
+		{
+			name:    "UnknownKey",
+			data:    sign(t, in_toto.PayloadType, stmt(&finished), signer),
+			bundle:  TrustBundle{Keys: []TrustedKey{other.key(t, RoleAttestation)}},
+			wantErr: true,
+		},
+		{
+			name:    "MissingKeyID",
+			data:    sign(t, in_toto.PayloadType, stmt(&finished), anonymous),
+			bundle:  TrustBundle{Keys: []TrustedKey{anonymous.key(t, RoleAttestation)}},
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := VerifyBundle(context.Background(), tc.data, Options{TrustBundle: &tc.bundle})
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("VerifyBundle() expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerifyBundle() error = %v", err)
+			}
+			if got := len(b.Payloads()); got != 1 {
+				t.Errorf("len(Payloads()) = %d, want 1", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package verify provides signature verification for OSS Rebuild attestation bundles.
+package verify
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/json"
+	"io"
+	"slices"
+
+	kms "cloud.google.com/go/kms/apiv1"
+	"cloud.google.com/go/kms/apiv1/kmspb"
+	"github.com/google/oss-rebuild/pkg/attestation"
+	"github.com/google/oss-rebuild/pkg/kmsdsse"
+	"github.com/pkg/errors"
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+	"google.golang.org/api/option"
+)
+
+// OSSRebuildKey is the KMS key version used to sign published OSS Rebuild attestations.
+const OSSRebuildKey = "projects/oss-rebuild/locations/global/keyRings/ring/cryptoKeys/signing-key/cryptoKeyVersions/1"
+
+// Options configures how bundle signatures are verified.
+type Options struct {
+	// KeyVersion is the Cloud KMS key version to verify against.
+	// Defaults to OSSRebuildKey when no Verifiers or TrustBundle are provided.
+	KeyVersion string
+	// Verifiers, if provided, are used in place of a KMS verifier.
+	Verifiers []dsse.Verifier
+	// TrustBundle, if provided, supplies the attestation keys in place of a
+	// KMS verifier, allowing verification offline. Only supported by VerifyBundle.
+	TrustBundle *TrustBundle
+	// TrustAll disables signature verification.
+	TrustAll bool
+	// ClientOptions are applied to the KMS client used for verification.
+	ClientOptions []option.ClientOption
+}
+
+// NewEnvelopeVerifier constructs the envelope verifier described by opts.
+func NewEnvelopeVerifier(ctx context.Context, opts Options) (*dsse.EnvelopeVerifier, error) {
+	verifiers := opts.Verifiers
+	switch {
+	case opts.TrustAll:
+		verifiers = []dsse.Verifier{&TrustAllVerifier{}}
+	case len(verifiers) == 0 && opts.TrustBundle != nil:
+		// NOTE: Trust bundle keys are selected by when each bundle was signed.
+		return nil, errors.New("trust bundles are only supported by VerifyBundle")
+	case len(verifiers) == 0:
+		key := opts.KeyVersion
+		if key == "" {
+			key = OSSRebuildKey
+		}
+		v, err := NewKMSVerifier(ctx, key, opts.ClientOptions...)
+		if err != nil {
+			return nil, err
+		}
+		verifiers = []dsse.Verifier{v}
+	}
+	ev, err := dsse.NewEnvelopeVerifier(verifiers...)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating EnvelopeVerifier")
+	}
+	return ev, nil
+}
+
+// VerifyBundle verifies and decodes the JSONL-encoded attestation bundle in data.
+func VerifyBundle(ctx context.Context, data []byte, opts Options) (*attestation.Bundle, error) {
+	if opts.TrustBundle != nil && !opts.TrustAll && len(opts.Verifiers) == 0 {
+		return verifyWithTrustBundle(ctx, data, opts.TrustBundle)
+	}
+	ev, err := NewEnvelopeVerifier(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return attestation.NewBundle(ctx, data, ev)
+}
+
+// verifyWithTrustBundle verifies the attestation bundle in data against the
+// attestation keys of tb that were valid when the attested build finished.
+//
+// Each envelope must carry a signature whose key ID names the key that verified it.
+func verifyWithTrustBundle(ctx context.Context, data []byte, tb *TrustBundle) (*attestation.Bundle, error) {
+	untrusted, err := dsse.NewEnvelopeVerifier(&TrustAllVerifier{})
+	if err != nil {
+		return nil, errors.Wrap(err, "creating EnvelopeVerifier")
+	}
+	// NOTE: The bundle is decoded before verification only to determine when it
+	// was signed. It is not returned until every envelope has been verified.
+	bundle, err := attestation.NewBundle(ctx, data, untrusted)
+	if err != nil {
+		return nil, err
+	}
+	att, err := bundle.RebuildAttestation()
+	if err != nil {
+		return nil, err
+	}
+	finished := att.Predicate.RunDetails.BuildMetadata.FinishedOn
+	if finished == nil {
+		return nil, errors.New("rebuild attestation has no finish time")
+	}
+	verifiers, err := tb.Verifiers(*finished, RoleAttestation)
+	if err != nil {
+		return nil, errors.Wrap(err, "loading trust bundle keys")
+	}
+	ev, err := dsse.NewEnvelopeVerifier(verifiers...)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating EnvelopeVerifier")
+	}
+	d := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var env dsse.Envelope
+		if err := d.Decode(&env); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, errors.Wrap(err, "decoding envelope")
+		}
+		accepted, err := ev.Verify(ctx, &env)
+		if err != nil {
+			return nil, errors.Wrap(err, "verifying envelope")
+		}
+		// NOTE: dsse accepts signatures without a key ID from any verifier.
+		if !slices.ContainsFunc(accepted, func(k dsse.AcceptedKey) bool { return k.Sig.KeyID != "" && k.Sig.KeyID == k.KeyID }) {
+			return nil, errors.New("verifying envelope: no signature identifies its key")
+		}
+	}
+	return bundle, nil
+}
+
+// NewKMSVerifier creates a verifier for the provided Cloud KMS key version.
+func NewKMSVerifier(ctx context.Context, cryptoKeyVersion string, opts ...option.ClientOption) (dsse.Verifier, error) {
+	kc, err := kms.NewKeyManagementClient(ctx, opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating KMS client")
+	}
+	ckv, err := kc.GetCryptoKeyVersion(ctx, &kmspb.GetCryptoKeyVersionRequest{Name: cryptoKeyVersion})
+	if err != nil {
+		return nil, errors.Wrap(err, "fetching CryptoKeyVersion")
+	}
+	kmsVerifier, err := kmsdsse.NewCloudKMSSignerVerifier(ctx, kc, ckv)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating Cloud KMS verifier")
+	}
+	return kmsVerifier, nil
+}
+
+// TrustAllVerifier is a dsse.Verifier that accepts all signatures.
+type TrustAllVerifier struct{}
+
+func (v *TrustAllVerifier) Verify(ctx context.Context, data, sig []byte) error { return nil }
+func (v *TrustAllVerifier) KeyID() (string, error)                             { return "", nil }
+func (v *TrustAllVerifier) Public() crypto.PublicKey                           { return nil }
@@ -0,0 +1,73 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package verify
+
+import (
+	"context"
+	"crypto"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/in-toto/in-toto-golang/in_toto"
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+)
+
+type rejectAll struct{}
+
+func (v *rejectAll) Verify(ctx context.Context, data, sig []byte) error { return errors.New("bad sig") }
+func (v *rejectAll) KeyID() (string, error)                             { return "reject", nil }
+func (v *rejectAll) Public() crypto.PublicKey                           { return nil }
+
+func TestVerifyBundle(t *testing.T) {
+	payload, err := json.Marshal(in_toto.ProvenanceStatementSLSA1{StatementHeader: in_toto.StatementHeader{Type: in_toto.StatementInTotoV1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(dsse.Envelope{
+		PayloadType: in_toto.PayloadType,
+		Payload:     base64.StdEncoding.EncodeToString(payload),
+		Signatures:  []dsse.Signature{{KeyID: "reject", Sig: "c2ln"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name    string
+		opts    Options
+		wantErr bool
+	}{
+		{name: "TrustAll", opts: Options{TrustAll: true}},
+		{name: "TrustAllOverridesVerifiers", opts: Options{TrustAll: true, Verifiers: []dsse.Verifier{&rejectAll{}}}},
+		{name: "RejectingVerifier", opts: Options{Verifiers: []dsse.Verifier{&rejectAll{}}}, wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := VerifyBundle(context.Background(), data, tc.opts)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("VerifyBundle() expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerifyBundle() error = %v", err)
+			}
+			if got := len(b.Payloads()); got != 1 {
+				t.Errorf("len(Payloads()) = %d, want 1", got)
+			}
+		})
+	}
+}
@@ -23,10 +23,28 @@ import (
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/storage/memory"
 	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
 	"github.com/pkg/errors"
 	"gopkg.in/yaml.v3"
 )
 
+// SchemaVersion is the most recent build definition schema version supported by this service.
+//
+// Definitions that use features introduced by a later schema must declare
+// that version so older services reject them instead of silently misrendering.
+const SchemaVersion = 1
+
+// ErrUnsupportedSchemaVersion indicates a build definition requires a newer service.
+var ErrUnsupportedSchemaVersion = errors.New("unsupported build definition schema version")
+
+// BuildDefinition is the serialized form of a build definition.
+type BuildDefinition struct {
+	schema.StrategyOneOf `yaml:",inline"`
+	// SchemaVersion is the minimum schema version required to interpret the definition.
+	// If unset, the definition is assumed compatible with all services.
+	SchemaVersion int `yaml:"schema_version,omitempty"`
+}
+
 // BuildDefinitionSet represents a collection of build definitions.
 type BuildDefinitionSet interface {
 	Get(ctx context.Context, target rebuild.Target) (rebuild.Strategy, error)
@@ -51,10 +69,17 @@ func (s *FilesystemBuildDefinitionSet) Get(ctx context.Context, t rebuild.Target
 		return nil, errors.Wrap(err, "reading build definition")
 	}
 	defer r.Close()
-	var strategy rebuild.Strategy
-	if err := yaml.NewDecoder(r).Decode(strategy); err != nil {
+	var def BuildDefinition
+	if err := yaml.NewDecoder(r).Decode(&def); err != nil {
 		return nil, errors.Wrap(err, "parsing build definition")
 	}
+	if def.SchemaVersion > SchemaVersion {
+		return nil, errors.Wrapf(ErrUnsupportedSchemaVersion, "definition requires schema version %d but this service supports up to %d", def.SchemaVersion, SchemaVersion)
+	}
+	strategy, err := def.Strategy()
+	if err != nil {
+		return nil, errors.Wrap(err, "reading build definition strategy")
+	}
 	return strategy, nil
 }
 
@@ -0,0 +1,80 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builddef
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+)
+
+func TestFilesystemBuildDefinitionSetGet(t *testing.T) {
+	target := rebuild.Target{Ecosystem: rebuild.PyPI, Package: "absl-py", Version: "2.0.0", Artifact: "absl_py-2.0.0-py3-none-any.whl"}
+	path := "pypi/absl-py/2.0.0/absl_py-2.0.0-py3-none-any.whl/build.yaml"
+	hint := `rebuild_location_hint:
+  location:
+    repo: "https://github.com/abseil/abseil-py"
+    ref: "37dad4d356ca9e13f1c533ad6309631b397a2b6b"
+    dir: ""
+`
+	for _, tc := range []struct {
+		name    string
+		content string
+		want    rebuild.Strategy
+		wantErr error
+	}{
+		{
+			name:    "NoDefinition",
+			content: "",
+			want:    nil,
+		},
+		{
+			name:    "Unversioned",
+			content: hint,
+			want:    &rebuild.LocationHint{Location: rebuild.Location{Repo: "https://github.com/abseil/abseil-py", Ref: "37dad4d356ca9e13f1c533ad6309631b397a2b6b"}},
+		},
+		{
+			name:    "SupportedVersion",
+			content: "schema_version: 1\n" + hint,
+			want:    &rebuild.LocationHint{Location: rebuild.Location{Repo: "https://github.com/abseil/abseil-py", Ref: "37dad4d356ca9e13f1c533ad6309631b397a2b6b"}},
+		},
+		{
+			name:    "FutureVersion",
+			content: "schema_version: 99\n" + hint,
+			wantErr: ErrUnsupportedSchemaVersion,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fs := memfs.New()
+			if tc.content != "" {
+				if err := util.WriteFile(fs, path, []byte(tc.content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := NewFilesystemBuildDefinitionSet(fs).Get(context.Background(), target)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("Get() error = %v, want %v", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Get() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
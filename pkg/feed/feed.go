@@ -0,0 +1,174 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package feed provides a signed, append-only log of rebuild verdicts.
+//
+// The feed is partitioned by day. Each partition consists of a JSONL file of
+// verdict entries and a DSSE-signed in-toto statement attesting to its digest:
+//
+//	verdicts/2024-01-02.jsonl
+//	verdicts/2024-01-02.jsonl.intoto
+package feed
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"path"
+	"sort"
+	"time"
+
+	gcs "cloud.google.com/go/storage"
+	"github.com/in-toto/in-toto-golang/in_toto"
+	"github.com/pkg/errors"
+)
+
+// PredicateType is the in-toto predicate type of a feed partition statement.
+const PredicateType = "https://docs.oss-rebuild.dev/feeds/VerdictFeed@v0.1"
+
+// DateFormat is the format of a feed partition's date.
+const DateFormat = time.DateOnly
+
+// ErrPartitionExists is returned when attempting to overwrite a published partition.
+var ErrPartitionExists = errors.New("feed partition already exists")
+
+// Entry is a single rebuild verdict in the feed.
+type Entry struct {
+	Ecosystem       string    `json:"ecosystem"`
+	Package         string    `json:"package"`
+	Version         string    `json:"version"`
+	Artifact        string    `json:"artifact"`
+	Success         bool      `json:"success"`
+	Message         string    `json:"message,omitempty"`
+	ExecutorVersion string    `json:"executor_version,omitempty"`
+	RunID           string    `json:"run_id,omitempty"`
+	Created         time.Time `json:"created"`
+	// CanonicalPackage is the current name of a renamed package, if it differs from Package.
+	CanonicalPackage string `json:"canonical_package,omitempty"`
+}
+
+// Predicate summarizes the contents of a feed partition.
+type Predicate struct {
+	Date      string `json:"date"`
+	Count     int    `json:"count"`
+	Successes int    `json:"successes"`
+}
+
+// Partition is the encoded feed content for a single day.
+type Partition struct {
+	Date time.Time
+	Data []byte
+	Predicate
+}
+
+// Name returns the object name of the partition's entries.
+func (p Partition) Name() string {
+	return path.Join("verdicts", p.Date.Format(DateFormat)+".jsonl")
+}
+
+// StatementName returns the object name of the partition's signed statement.
+func (p Partition) StatementName() string {
+	return p.Name() + ".intoto"
+}
+
+// Statement returns the in-toto statement attesting to the partition's content.
+func (p Partition) Statement() *in_toto.Statement {
+	sum := sha256.Sum256(p.Data)
+	return &in_toto.Statement{
+		StatementHeader: in_toto.StatementHeader{
+			Type:          in_toto.StatementInTotoV1,
+			PredicateType: PredicateType,
+			Subject:       []in_toto.Subject{{Name: p.Name(), Digest: map[string]string{"sha256": hex.EncodeToString(sum[:])}}},
+		},
+		Predicate: p.Predicate,
+	}
+}
+
+// NewPartition encodes the entries for the day containing date.
+//
+// Entries are ordered by creation time for stable output.
+func NewPartition(date time.Time, entries []Entry) (*Partition, error) {
+	sorted := make([]Entry, len(entries))
+	copy(sorted, entries)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Created.Before(sorted[j].Created) })
+	p := Partition{Date: date.UTC().Truncate(24 * time.Hour)}
+	p.Predicate.Date = p.Date.Format(DateFormat)
+	buf := new(bytes.Buffer)
+	enc := json.NewEncoder(buf)
+	for _, e := range sorted {
+		if err := enc.Encode(e); err != nil {
+			return nil, errors.Wrap(err, "encoding entry")
+		}
+		p.Count++
+		if e.Success {
+			p.Successes++
+		}
+	}
+	p.Data = buf.Bytes()
+	return &p, nil
+}
+
+// Store persists feed objects.
+type Store interface {
+	Exists(ctx context.Context, name string) (bool, error)
+	Write(ctx context.Context, name string, data []byte) error
+}
+
+// GCSStore stores feed objects in a GCS bucket.
+type GCSStore struct {
+	Bucket *gcs.BucketHandle
+}
+
+func (s GCSStore) Exists(ctx context.Context, name string) (bool, error) {
+	_, err := s.Bucket.Object(name).Attrs(ctx)
+	if err == gcs.ErrObjectNotExist {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s GCSStore) Write(ctx context.Context, name string, data []byte) error {
+	w := s.Bucket.Object(name).NewWriter(ctx)
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return errors.Wrap(err, "writing object")
+	}
+	return errors.Wrap(w.Close(), "closing object")
+}
+
+var _ Store = GCSStore{}
+
+// Publish writes the partition and its signed statement to the store.
+//
+// The statement marks a partition as published: Entries are written first so
+// a published statement always refers to available content and a partially
+// written partition can be retried.
+func Publish(ctx context.Context, s Store, p *Partition, envelope []byte) error {
+	if exists, err := s.Exists(ctx, p.StatementName()); err != nil {
+		return errors.Wrap(err, "checking statement")
+	} else if exists {
+		return ErrPartitionExists
+	}
+	if err := s.Write(ctx, p.Name(), p.Data); err != nil {
+		return errors.Wrap(err, "writing entries")
+	}
+	if err := s.Write(ctx, p.StatementName(), envelope); err != nil {
+		return errors.Wrap(err, "writing statement")
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package feed
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type memoryStore map[string][]byte
+
+func (s memoryStore) Exists(ctx context.Context, name string) (bool, error) {
+	_, ok := s[name]
+	return ok, nil
+}
+
+func (s memoryStore) Write(ctx context.Context, name string, data []byte) error {
+	s[name] = data
+	return nil
+}
+
+func TestPublish(t *testing.T) {
+	ctx := context.Background()
+	day := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	p, err := NewPartition(day, []Entry{{Ecosystem: "npm", Package: "a", Success: true}, {Ecosystem: "npm", Package: "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Predicate != (Predicate{Date: "2024-01-02", Count: 2, Successes: 1}) {
+		t.Errorf("Predicate = %+v", p.Predicate)
+	}
+	stmt := p.Statement()
+	if stmt.PredicateType != PredicateType || len(stmt.Subject) != 1 || stmt.Subject[0].Name != "verdicts/2024-01-02.jsonl" {
+		t.Errorf("Unexpected statement: %+v", stmt.StatementHeader)
+	}
+	t.Run("RetryPartialWrite", func(t *testing.T) {
+		s := memoryStore{p.Name(): []byte("partial")}
+		if err := Publish(ctx, s, p, []byte("envelope")); err != nil {
+			t.Fatalf("Publish() error = %v", err)
+		}
+		if string(s[p.Name()]) != string(p.Data) || string(s[p.StatementName()]) != "envelope" {
+			t.Errorf("Unexpected store contents: %v", s)
+		}
+	})
+	t.Run("Published", func(t *testing.T) {
+		s := memoryStore{p.StatementName(): []byte("envelope")}
+		if err := Publish(ctx, s, p, []byte("other")); !errors.Is(err, ErrPartitionExists) {
+			t.Errorf("Publish() error = %v, want ErrPartitionExists", err)
+		}
+	})
+}
@@ -9,6 +9,7 @@ import (
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
+	"errors"
 	"log"
 	"math/big"
 	"time"
@@ -79,3 +80,18 @@ func ToJKS(cert *x509.Certificate) ([]byte, error) {
 	}
 	return jksBuf.Bytes(), nil
 }
+
+// LoadCA parses a PEM-encoded CA certificate and private key.
+func LoadCA(certPEM, keyPEM []byte) (*tls.Certificate, error) {
+	ca, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	if ca.Leaf, err = x509.ParseCertificate(ca.Certificate[0]); err != nil {
+		return nil, err
+	}
+	if !ca.Leaf.IsCA {
+		return nil, errors.New("certificate is not a CA")
+	}
+	return &ca, nil
+}
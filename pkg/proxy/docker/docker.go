@@ -593,10 +593,15 @@ func (d *ContainerTruststorePatcher) proxyRequest(clientConn, serverConn net.Con
 			if err != nil {
 				log.Fatalf("Failed to get network for request %s: %s", req.URL.Path, err)
 			}
-			log.Printf("Modifying network from %s to %s", network, d.networkOverride)
-			newBody, err = setNetwork(newBody, d.networkOverride)
-			if err != nil {
-				log.Fatalf("Failed to set network for request %s: %s", req.URL.Path, err)
+			// NOTE: Containers explicitly isolated from the network need no proxying.
+			if network == "none" {
+				log.Printf("Retaining network %s", network)
+			} else {
+				log.Printf("Modifying network from %s to %s", network, d.networkOverride)
+				newBody, err = setNetwork(newBody, d.networkOverride)
+				if err != nil {
+					log.Fatalf("Failed to set network for request %s: %s", req.URL.Path, err)
+				}
 			}
 		}
 		req.ContentLength = int64(len(newBody))
@@ -1,11 +1,19 @@
 package netlog
 
 import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"hash"
+	"io"
 	"net"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/elazarl/goproxy"
+	"github.com/google/oss-rebuild/internal/scrub"
 )
 
 type HTTPRequestLog struct {
@@ -13,42 +21,150 @@ type HTTPRequestLog struct {
 	Scheme string
 	Host   string
 	Path   string
+	// Phase is the build phase active when the request was made.
+	Phase string `json:",omitempty"`
+}
+
+// HTTPDownloadLog records the content digests of a successfully fetched resource.
+type HTTPDownloadLog struct {
+	Scheme string
+	Host   string
+	Path   string
+	// Digests are hex-encoded content hashes keyed by in-toto algorithm name.
+	Digests map[string]string
+}
+
+// URL returns the URL from which the resource was downloaded.
+func (d HTTPDownloadLog) URL() string {
+	return d.Scheme + "://" + d.Host + d.Path
+}
+
+// PhaseLog records the start of a build phase.
+type PhaseLog struct {
+	Name  string
+	Start time.Time
 }
 
 type NetworkActivityLog struct {
-	HTTPRequests []HTTPRequestLog
+	HTTPRequests  []HTTPRequestLog
+	HTTPDownloads []HTTPDownloadLog
+	// Redactions is the number of credentials removed from logged paths.
+	Redactions int `json:",omitempty"`
+	// Phases are the build phases entered, in order.
+	Phases []PhaseLog `json:",omitempty"`
+}
+
+// EnterPhase records the start of the named build phase.
+func (l *NetworkActivityLog) EnterPhase(name string, start time.Time) {
+	l.Phases = append(l.Phases, PhaseLog{Name: name, Start: start})
+}
+
+// CurrentPhase returns the name of the most recently entered build phase.
+func (l *NetworkActivityLog) CurrentPhase() string {
+	if len(l.Phases) == 0 {
+		return ""
+	}
+	return l.Phases[len(l.Phases)-1].Name
+}
+
+// normalizeURL populates the URL of raw HTTP requests and returns the host with any standard port removed.
+func normalizeURL(req *http.Request) string {
+	// Schema-less requests will be raw HTTP requests with relative URLs.
+	if req.URL.Scheme == "" {
+		req.URL.Scheme = "http"
+		req.URL.Host = req.Host
+	}
+	// Only retain non-standard port numbers from Host.
+	host, port, err := net.SplitHostPort(req.URL.Host)
+	if err != nil || !((port == "80" && req.URL.Scheme == "http") || (port == "443" && req.URL.Scheme == "https")) {
+		host = req.URL.Host
+	}
+	return host
+}
+
+// digestingReader hashes the content read through it and reports the digests once fully consumed.
+type digestingReader struct {
+	io.ReadCloser
+	hashes map[string]hash.Hash
+	done   func(map[string]string)
+}
+
+func (r *digestingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	for _, h := range r.hashes {
+		h.Write(p[:n])
+	}
+	if err == io.EOF && r.done != nil {
+		digests := make(map[string]string, len(r.hashes))
+		for name, h := range r.hashes {
+			digests[name] = hex.EncodeToString(h.Sum(nil))
+		}
+		r.done(digests)
+		r.done = nil
+	}
+	return n, err
 }
 
 func CaptureActivityLog(t *goproxy.ProxyHttpServer, mx *sync.Mutex) *NetworkActivityLog {
 	httpReqs := make(chan HTTPRequestLog, 10)
+	httpDownloads := make(chan HTTPDownloadLog, 10)
+	netlog := new(NetworkActivityLog)
 	t.OnRequest().DoFunc(func(req *http.Request, ctx *goproxy.ProxyCtx) (*http.Request, *http.Response) {
-		// Schema-less requests will be raw HTTP requests with relative URLs.
-		if req.URL.Scheme == "" {
-			req.URL.Scheme = "http"
-			req.URL.Host = req.Host
-		}
-		// Only retain non-standard port numbers from Host.
-		host, port, err := net.SplitHostPort(req.URL.Host)
-		if err != nil || !((port == "80" && req.URL.Scheme == "http") || (port == "443" && req.URL.Scheme == "https")) {
-			host = req.URL.Host
-		}
+		host := normalizeURL(req)
+		// NOTE: The phase is read at request time rather than when the log is
+		// appended so requests racing a phase change are correctly attributed.
+		mx.Lock()
+		phase := netlog.CurrentPhase()
+		mx.Unlock()
 		httpReqs <- HTTPRequestLog{
 			Method: req.Method,
 			Scheme: req.URL.Scheme,
 			Host:   host,
 			Path:   req.URL.Path,
+			Phase:  phase,
 		}
 		return req, nil
 	})
-	netlog := new(NetworkActivityLog)
-	// Initialize slice to avoid serializing as null.
+	t.OnResponse().DoFunc(func(resp *http.Response, ctx *goproxy.ProxyCtx) *http.Response {
+		if resp == nil || resp.StatusCode != http.StatusOK || ctx.Req == nil || ctx.Req.Method != http.MethodGet {
+			return resp
+		}
+		dl := HTTPDownloadLog{
+			Scheme: ctx.Req.URL.Scheme,
+			Host:   normalizeURL(ctx.Req),
+			Path:   ctx.Req.URL.Path,
+		}
+		// NOTE: Only bodies read to completion are recorded so truncated
+		// transfers are not mistaken for complete artifacts.
+		resp.Body = &digestingReader{
+			ReadCloser: resp.Body,
+			hashes:     map[string]hash.Hash{"sha1": sha1.New(), "sha256": sha256.New(), "sha512": sha512.New()},
+			done: func(digests map[string]string) {
+				dl.Digests = digests
+				httpDownloads <- dl
+			},
+		}
+		return resp
+	})
+	// Initialize slices to avoid serializing as null.
 	netlog.HTTPRequests = []HTTPRequestLog{}
+	netlog.HTTPDownloads = []HTTPDownloadLog{}
 	go func() {
 		for {
 			select {
 			case httpReq := <-httpReqs:
+				var n int
+				httpReq.Path, n = scrub.String(httpReq.Path)
 				mx.Lock()
 				netlog.HTTPRequests = append(netlog.HTTPRequests, httpReq)
+				netlog.Redactions += n
+				mx.Unlock()
+			case httpDownload := <-httpDownloads:
+				var n int
+				httpDownload.Path, n = scrub.String(httpDownload.Path)
+				mx.Lock()
+				netlog.HTTPDownloads = append(netlog.HTTPDownloads, httpDownload)
+				netlog.Redactions += n
 				mx.Unlock()
 			}
 		}
@@ -15,6 +15,7 @@ import (
 	"net/url"
 	"regexp"
 	"sync"
+	"time"
 
 	"github.com/elazarl/goproxy"
 	"github.com/google/oss-rebuild/internal/proxy/handshake"
@@ -93,6 +94,8 @@ type TransparentProxyService struct {
 	Ca     *tls.Certificate
 	Policy *policy.Policy
 	Mode   PolicyMode
+	// PhasePolicies are the policies applied upon entering each build phase.
+	PhasePolicies map[string]*policy.Policy
 
 	mx            *sync.Mutex
 	networkLog    *netlog.NetworkActivityLog
@@ -103,6 +106,9 @@ type TransparentProxyService struct {
 type TransparentProxyServiceOpts struct {
 	Policy      *policy.Policy
 	SkipLogging bool
+	// PhasePolicies, if provided, replace Policy when the corresponding build
+	// phase is entered via the admin endpoint.
+	PhasePolicies map[string]*policy.Policy
 }
 
 // NewTransparentProxyService creates a new TransparentProxyService.
@@ -119,12 +125,13 @@ func NewTransparentProxyService(p *goproxy.ProxyHttpServer, ca *tls.Certificate,
 		networkLog = netlog.CaptureActivityLog(p, m)
 	}
 	return TransparentProxyService{
-		Proxy:      p,
-		Ca:         ca,
-		Mode:       mode,
-		Policy:     opts.Policy,
-		mx:         m,
-		networkLog: networkLog,
+		Proxy:         p,
+		Ca:            ca,
+		Mode:          mode,
+		Policy:        opts.Policy,
+		PhasePolicies: opts.PhasePolicies,
+		mx:            m,
+		networkLog:    networkLog,
 	}
 }
 
@@ -259,6 +266,7 @@ func (t *TransparentProxyService) ServeAdmin(addr string) {
 		}
 	})
 	mux.HandleFunc("/policy", t.policyHandler)
+	mux.HandleFunc("/phase", t.phaseHandler)
 	server := &http.Server{
 		Addr:    addr,
 		Handler: mux,
@@ -305,6 +313,42 @@ func (t *TransparentProxyService) policyHandler(w http.ResponseWriter, r *http.R
 	}
 }
 
+// phaseHandler handles requests to the /phase endpoint.
+//
+// A PUT request with the "name" query parameter enters the named build phase,
+// applying the corresponding policy from PhasePolicies if one is configured.
+// When PhasePolicies are configured, only those phases may be entered.
+func (t *TransparentProxyService) phaseHandler(w http.ResponseWriter, r *http.Request) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	switch r.Method {
+	case http.MethodGet:
+		if err := json.NewEncoder(w).Encode(map[string]string{"phase": t.networkLog.CurrentPhase()}); err != nil {
+			log.Printf("Failed to marshal phase: %v", err)
+			http.Error(w, "Internal Error", http.StatusInternalServerError)
+		}
+	case http.MethodPut:
+		name := r.URL.Query().Get("name")
+		if name == "" {
+			http.Error(w, "Bad request received. Expected name parameter", http.StatusBadRequest)
+			return
+		}
+		p, ok := t.PhasePolicies[name]
+		if !ok && len(t.PhasePolicies) > 0 {
+			http.Error(w, fmt.Sprintf("Unknown phase: %s", name), http.StatusBadRequest)
+			return
+		}
+		if ok {
+			t.Policy = p
+		}
+		log.Printf("Entering phase %s", name)
+		t.networkLog.EnterPhase(name, time.Now())
+	default:
+		log.Printf("Invalid method type received in request: %v", r.Method)
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
 // Check that the requested url is allowed by the network policy.
 func (proxy TransparentProxyService) ApplyNetworkPolicy(req *http.Request, ctx *goproxy.ProxyCtx) (*http.Request, *http.Response) {
 	if proxy.Mode == DisabledMode {
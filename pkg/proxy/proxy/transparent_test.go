@@ -251,3 +251,98 @@ func TestPolicyEndpoint(t *testing.T) {
 		})
 	}
 }
+
+func TestPhaseEndpoint(t *testing.T) {
+	deps := &policy.Policy{AnyOf: []policy.Rule{policy.URLMatchRule{HostMatch: policy.SuffixMatch, PathMatch: policy.PrefixMatch}}}
+	build := &policy.Policy{}
+	initial := &policy.Policy{}
+	tests := []struct {
+		name       string
+		phases     map[string]*policy.Policy
+		method     string
+		phase      string
+		wantResp   int
+		wantPolicy *policy.Policy
+		wantPhases []string
+	}{
+		{
+			name:       "PUT applies phase policy",
+			phases:     map[string]*policy.Policy{"deps": deps, "build": build},
+			method:     http.MethodPut,
+			phase:      "build",
+			wantResp:   http.StatusOK,
+			wantPolicy: build,
+			wantPhases: []string{"build"},
+		},
+		{
+			name:       "PUT unknown phase returns StatusBadRequest",
+			phases:     map[string]*policy.Policy{"deps": deps, "build": build},
+			method:     http.MethodPut,
+			phase:      "test",
+			wantResp:   http.StatusBadRequest,
+			wantPolicy: initial,
+		},
+		{
+			name:       "PUT without phase policies records phase",
+			method:     http.MethodPut,
+			phase:      "deps",
+			wantResp:   http.StatusOK,
+			wantPolicy: initial,
+			wantPhases: []string{"deps"},
+		},
+		{
+			name:       "PUT without name returns StatusBadRequest",
+			method:     http.MethodPut,
+			wantResp:   http.StatusBadRequest,
+			wantPolicy: initial,
+		},
+		{
+			name:       "GET does not change phase",
+			phases:     map[string]*policy.Policy{"deps": deps},
+			method:     http.MethodGet,
+			phase:      "deps",
+			wantResp:   http.StatusOK,
+			wantPolicy: initial,
+		},
+		{
+			name:       "POST request returns StatusMethodNotAllowed",
+			method:     http.MethodPost,
+			phase:      "deps",
+			wantResp:   http.StatusMethodNotAllowed,
+			wantPolicy: initial,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			proxyService := NewTransparentProxyService(NewTransparentProxyServer(false), nil, "enforce", TransparentProxyServiceOpts{
+				Policy:        initial,
+				PhasePolicies: tc.phases,
+				SkipLogging:   true,
+			})
+			server := httptest.NewServer(http.HandlerFunc(proxyService.phaseHandler))
+			defer server.Close()
+			req, err := http.NewRequest(tc.method, server.URL+"/phase?name="+tc.phase, nil)
+			if err != nil {
+				t.Fatalf("Error creating request: %v", err)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Error making request: %v", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tc.wantResp {
+				t.Errorf("Request returned unexpected response code. got %v, want %v", resp.StatusCode, tc.wantResp)
+			}
+			if proxyService.Policy != tc.wantPolicy {
+				t.Errorf("Policy = %v, want %v", proxyService.Policy, tc.wantPolicy)
+			}
+			var gotPhases []string
+			for _, p := range proxyService.networkLog.Phases {
+				gotPhases = append(gotPhases, p.Name)
+			}
+			if !reflect.DeepEqual(gotPhases, tc.wantPhases) {
+				t.Errorf("Phases = %v, want %v", gotPhases, tc.wantPhases)
+			}
+		})
+	}
+}
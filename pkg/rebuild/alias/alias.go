@@ -0,0 +1,182 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package alias maps renamed or migrated packages to a canonical name.
+//
+// Packages are occasionally renamed upstream (e.g. npm scope moves, crate
+// renames, Maven groupId changes). Aliases allow the rebuild history of the
+// previous names to be associated with the canonical package.
+package alias
+
+import (
+	"context"
+	"io"
+	"sort"
+
+	"cloud.google.com/go/firestore"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/pkg/errors"
+	"google.golang.org/api/iterator"
+	"gopkg.in/yaml.v3"
+)
+
+// Alias records that a package was previously published under another name.
+type Alias struct {
+	Ecosystem rebuild.Ecosystem `yaml:"ecosystem" firestore:"ecosystem"`
+	// From is the previous package name.
+	From string `yaml:"from" firestore:"from"`
+	// To is the package name that superseded From.
+	To string `yaml:"to" firestore:"to"`
+}
+
+type key struct {
+	ecosystem rebuild.Ecosystem
+	pkg       string
+}
+
+// Table resolves package names to their canonical names.
+//
+// The zero value and nil Table contain no aliases.
+type Table struct {
+	canonical map[key]string
+	aliases   map[key][]string
+}
+
+// NewTable creates a Table from the provided aliases.
+//
+// Chains of renames are followed to the most recent name. Cycles and names
+// aliased to more than one package are rejected.
+func NewTable(aliases []Alias) (*Table, error) {
+	next := make(map[key]string)
+	for _, a := range aliases {
+		if a.Ecosystem == "" || a.From == "" || a.To == "" {
+			return nil, errors.Errorf("incomplete alias: %+v", a)
+		}
+		if a.From == a.To {
+			return nil, errors.Errorf("package aliased to itself: %s", a.From)
+		}
+		k := key{a.Ecosystem, a.From}
+		if to, ok := next[k]; ok && to != a.To {
+			return nil, errors.Errorf("%s aliased to both %s and %s", a.From, to, a.To)
+		}
+		next[k] = a.To
+	}
+	t := &Table{canonical: make(map[key]string), aliases: make(map[key][]string)}
+	for k := range next {
+		name, seen := k.pkg, map[string]bool{k.pkg: true}
+		for {
+			to, ok := next[key{k.ecosystem, name}]
+			if !ok {
+				break
+			}
+			if seen[to] {
+				return nil, errors.Errorf("alias cycle including %s", k.pkg)
+			}
+			seen[to] = true
+			name = to
+		}
+		t.canonical[k] = name
+		ck := key{k.ecosystem, name}
+		t.aliases[ck] = append(t.aliases[ck], k.pkg)
+	}
+	for _, names := range t.aliases {
+		sort.Strings(names)
+	}
+	return t, nil
+}
+
+// Canonical returns the canonical name of the package.
+func (t *Table) Canonical(ecosystem rebuild.Ecosystem, pkg string) string {
+	if t != nil {
+		if name, ok := t.canonical[key{ecosystem, pkg}]; ok {
+			return name
+		}
+	}
+	return pkg
+}
+
+// Names returns the canonical name of the package followed by all its aliases.
+func (t *Table) Names(ecosystem rebuild.Ecosystem, pkg string) []string {
+	canonical := t.Canonical(ecosystem, pkg)
+	names := []string{canonical}
+	if t != nil {
+		names = append(names, t.aliases[key{ecosystem, canonical}]...)
+	}
+	return names
+}
+
+// Target returns the target with its package replaced by the canonical name.
+func (t *Table) Target(target rebuild.Target) rebuild.Target {
+	target.Package = t.Canonical(target.Ecosystem, target.Package)
+	return target
+}
+
+// ReadYAML reads aliases from a YAML document of the form:
+//
+//	aliases:
+//	  - ecosystem: npm
+//	    from: old-name
+//	    to: "@scope/new-name"
+func ReadYAML(r io.Reader) ([]Alias, error) {
+	var doc struct {
+		Aliases []Alias `yaml:"aliases"`
+	}
+	d := yaml.NewDecoder(r)
+	d.KnownFields(true)
+	if err := d.Decode(&doc); err != nil && err != io.EOF {
+		return nil, errors.Wrap(err, "decoding aliases")
+	}
+	return doc.Aliases, nil
+}
+
+// Source provides the set of known aliases.
+type Source interface {
+	Aliases(ctx context.Context) ([]Alias, error)
+}
+
+// FirestoreSource reads aliases from the "package_aliases" Firestore collection.
+type FirestoreSource struct {
+	Client *firestore.Client
+}
+
+var _ Source = FirestoreSource{}
+
+func (s FirestoreSource) Aliases(ctx context.Context) ([]Alias, error) {
+	it := s.Client.Collection("package_aliases").Documents(ctx)
+	defer it.Stop()
+	var aliases []Alias
+	for {
+		doc, err := it.Next()
+		if err == iterator.Done {
+			break
+		} else if err != nil {
+			return nil, errors.Wrap(err, "listing aliases")
+		}
+		var a Alias
+		if err := doc.DataTo(&a); err != nil {
+			return nil, errors.Wrapf(err, "decoding %s", doc.Ref.ID)
+		}
+		aliases = append(aliases, a)
+	}
+	return aliases, nil
+}
+
+// Load creates a Table from the aliases provided by the source.
+func Load(ctx context.Context, s Source) (*Table, error) {
+	aliases, err := s.Aliases(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return NewTable(aliases)
+}
@@ -0,0 +1,138 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alias
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+)
+
+func TestNewTable(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		aliases       []Alias
+		pkg           string
+		wantCanonical string
+		wantNames     []string
+		wantErr       bool
+	}{
+		{
+			name:          "no aliases",
+			pkg:           "left-pad",
+			wantCanonical: "left-pad",
+			wantNames:     []string{"left-pad"},
+		},
+		{
+			name:          "renamed",
+			aliases:       []Alias{{Ecosystem: rebuild.NPM, From: "babel-core", To: "@babel/core"}},
+			pkg:           "babel-core",
+			wantCanonical: "@babel/core",
+			wantNames:     []string{"@babel/core", "babel-core"},
+		},
+		{
+			name:          "canonical",
+			aliases:       []Alias{{Ecosystem: rebuild.NPM, From: "babel-core", To: "@babel/core"}},
+			pkg:           "@babel/core",
+			wantCanonical: "@babel/core",
+			wantNames:     []string{"@babel/core", "babel-core"},
+		},
+		{
+			name: "chain",
+			aliases: []Alias{
+				{Ecosystem: rebuild.NPM, From: "a", To: "b"},
+				{Ecosystem: rebuild.NPM, From: "b", To: "c"},
+			},
+			pkg:           "a",
+			wantCanonical: "c",
+			wantNames:     []string{"c", "a", "b"},
+		},
+		{
+			name:          "other ecosystem",
+			aliases:       []Alias{{Ecosystem: rebuild.CratesIO, From: "a", To: "b"}},
+			pkg:           "a",
+			wantCanonical: "a",
+			wantNames:     []string{"a"},
+		},
+		{
+			name: "cycle",
+			aliases: []Alias{
+				{Ecosystem: rebuild.NPM, From: "a", To: "b"},
+				{Ecosystem: rebuild.NPM, From: "b", To: "a"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "conflict",
+			aliases: []Alias{
+				{Ecosystem: rebuild.NPM, From: "a", To: "b"},
+				{Ecosystem: rebuild.NPM, From: "a", To: "c"},
+			},
+			wantErr: true,
+		},
+		{
+			name:    "self",
+			aliases: []Alias{{Ecosystem: rebuild.NPM, From: "a", To: "a"}},
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			table, err := NewTable(tc.aliases)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("NewTable() expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewTable() error = %v", err)
+			}
+			if got := table.Canonical(rebuild.NPM, tc.pkg); got != tc.wantCanonical {
+				t.Errorf("Canonical() = %s, want %s", got, tc.wantCanonical)
+			}
+			if diff := cmp.Diff(tc.wantNames, table.Names(rebuild.NPM, tc.pkg)); diff != "" {
+				t.Errorf("Names() diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNilTable(t *testing.T) {
+	var table *Table
+	if got := table.Canonical(rebuild.NPM, "a"); got != "a" {
+		t.Errorf("Canonical() = %s, want a", got)
+	}
+	if diff := cmp.Diff([]string{"a"}, table.Names(rebuild.NPM, "a")); diff != "" {
+		t.Errorf("Names() diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestReadYAML(t *testing.T) {
+	got, err := ReadYAML(strings.NewReader(`
+aliases:
+  - ecosystem: cratesio
+    from: old
+    to: new
+`))
+	if err != nil {
+		t.Fatalf("ReadYAML() error = %v", err)
+	}
+	want := []Alias{{Ecosystem: rebuild.CratesIO, From: "old", To: "new"}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ReadYAML() diff (-want +got):\n%s", diff)
+	}
+}
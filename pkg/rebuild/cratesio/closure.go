@@ -0,0 +1,61 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cratesio
+
+import (
+	"fmt"
+
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pkg/errors"
+)
+
+// cargoLock is the subset of Cargo.lock describing the resolved packages.
+type cargoLock struct {
+	Packages []struct {
+		Name     string `toml:"name"`
+		Version  string `toml:"version"`
+		Source   string `toml:"source"`
+		Checksum string `toml:"checksum"`
+	} `toml:"package"`
+	// Metadata holds the checksums of lockfile version 1 keyed by
+	// "checksum <name> <version> (<source>)".
+	Metadata map[string]string `toml:"metadata"`
+}
+
+// ParseClosure parses the dependency closure from the Cargo.lock used in the build.
+func ParseClosure(b []byte) ([]rebuild.Dependency, error) {
+	var lock cargoLock
+	if err := toml.Unmarshal(b, &lock); err != nil {
+		return nil, errors.Wrap(err, "parsing Cargo.lock")
+	}
+	var deps []rebuild.Dependency
+	for _, p := range lock.Packages {
+		// NOTE: Packages without a source are members of the local workspace.
+		if p.Source == "" {
+			continue
+		}
+		d := rebuild.Dependency{Name: p.Name, Version: p.Version, URI: p.Source}
+		checksum := p.Checksum
+		if checksum == "" {
+			checksum = lock.Metadata[fmt.Sprintf("checksum %s %s (%s)", p.Name, p.Version, p.Source)]
+		}
+		if checksum != "" {
+			d.Digest = map[string]string{"sha256": checksum}
+		}
+		deps = append(deps, d)
+	}
+	return rebuild.NormalizeDependencies(deps), nil
+}
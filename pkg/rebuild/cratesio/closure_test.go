@@ -0,0 +1,82 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cratesio
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+)
+
+func TestParseClosure(t *testing.T) {
+	const registry = "registry+https://github.com/rust-lang/crates.io-index"
+	tests := []struct {
+		name string
+		lock string
+		want []rebuild.Dependency
+	}{
+		{
+			name: "v3",
+			lock: `version = 3
+
+[[package]]
+name = "foo"
+version = "0.1.0"
+dependencies = ["serde"]
+
+[[package]]
+name = "serde"
+version = "1.0.150"
+source = "registry+https://github.com/rust-lang/crates.io-index"
+checksum = "e326c9ec8042f1b5da33252c8a37e9ffbd2c9bef0155215b6e6c80c790e05f91"
+`,
+			want: []rebuild.Dependency{
+				{Name: "serde", Version: "1.0.150", URI: registry, Digest: map[string]string{"sha256": "e326c9ec8042f1b5da33252c8a37e9ffbd2c9bef0155215b6e6c80c790e05f91"}},
+			},
+		},
+		{
+			name: "v1",
+			lock: `[[package]]
+name = "serde"
+version = "1.0.150"
+source = "registry+https://github.com/rust-lang/crates.io-index"
+
+[[package]]
+name = "vendored"
+version = "0.2.0"
+source = "git+https://github.com/foo/vendored#0beec7b5ea3f0fdbc95d0dd47f3c5bc275da8a33"
+
+[metadata]
+"checksum serde 1.0.150 (registry+https://github.com/rust-lang/crates.io-index)" = "e326c9ec8042f1b5da33252c8a37e9ffbd2c9bef0155215b6e6c80c790e05f91"
+`,
+			want: []rebuild.Dependency{
+				{Name: "serde", Version: "1.0.150", URI: registry, Digest: map[string]string{"sha256": "e326c9ec8042f1b5da33252c8a37e9ffbd2c9bef0155215b6e6c80c790e05f91"}},
+				{Name: "vendored", Version: "0.2.0", URI: "git+https://github.com/foo/vendored#0beec7b5ea3f0fdbc95d0dd47f3c5bc275da8a33"},
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseClosure([]byte(tc.lock))
+			if err != nil {
+				t.Fatalf("ParseClosure() error = %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("ParseClosure() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
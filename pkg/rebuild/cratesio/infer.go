@@ -28,6 +28,8 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/go-git/go-billy/v5"
@@ -56,11 +58,47 @@ func getCargoTOML(tree *object.Tree, path string) (ct reg.CargoTOML, err error)
 	return
 }
 
+// rustVersionRE matches the numbered release channels that can be installed.
+// Named channels (e.g. "stable", "nightly-2024-01-01") are unsupported.
+var rustVersionRE = regexp.MustCompile(`^(\d+\.\d+)(\.\d+)?$`)
+
+// getRustToolchainPin returns the rust version pinned by a rust-toolchain.toml
+// or legacy rust-toolchain file applicable to dir.
+func getRustToolchainPin(tree *object.Tree, dir string) (pin, version string, err error) {
+	pin, content, err := rebuild.FindPinFile(tree, dir, "rust-toolchain.toml", "rust-toolchain")
+	if err != nil {
+		return "", "", err
+	}
+	channel := strings.TrimSpace(content)
+	// NOTE: The legacy rust-toolchain file may contain either a bare channel or TOML.
+	if pin == "rust-toolchain.toml" || strings.Contains(channel, "[toolchain]") {
+		var tc struct {
+			Toolchain struct {
+				Channel string `toml:"channel"`
+			} `toml:"toolchain"`
+		}
+		if err := toml.Unmarshal([]byte(content), &tc); err != nil {
+			return "", "", errors.Wrapf(err, "parsing %s", pin)
+		}
+		channel = tc.Toolchain.Channel
+	}
+	m := rustVersionRE.FindStringSubmatch(channel)
+	if m == nil {
+		return "", "", errors.Errorf("unsupported %s channel: %q", pin, channel)
+	}
+	if m[2] == "" {
+		// Resolve to the initial patch release so the version is valid semver.
+		return pin, m[1] + ".0", nil
+	}
+	return pin, channel, nil
+}
+
 func (Rebuilder) InferRepo(ctx context.Context, t rebuild.Target, mux rebuild.RegistryMux) (string, error) {
 	pmeta, err := mux.CratesIO.Crate(ctx, t.Package)
 	if err != nil {
 		return "", err
 	}
+	rebuild.RecordProvenance(ctx, "repo", rebuild.HeuristicRegistry)
 	return uri.CanonicalizeRepoURI(pmeta.Repository)
 }
 
@@ -97,7 +135,7 @@ func (Rebuilder) CloneRepo(ctx context.Context, t rebuild.Target, repoURI string
 	return
 }
 
-func inferRefAndDir(t rebuild.Target, vmeta *reg.CrateVersion, crateBytes []byte, rcfg *rebuild.RepoConfig) (ref, dir string, err error) {
+func inferRefAndDir(ctx context.Context, t rebuild.Target, vmeta *reg.CrateVersion, crateBytes []byte, rcfg *rebuild.RepoConfig) (ref, dir string, err error) {
 	// Determine git ref to rebuild.
 	cargoTOMLGuess := rcfg.RefMap[t.Version]
 	tagGuess, err := rebuild.FindTagMatch(t.Package, t.Version, rcfg.Repository)
@@ -130,6 +168,8 @@ func inferRefAndDir(t rebuild.Target, vmeta *reg.CrateVersion, crateBytes []byte
 				log.Printf("using registry ref: %s", cargoVCSGuess[:9])
 				ref = cargoVCSGuess
 				dir = filepath.Dir(newPath)
+				rebuild.RecordProvenance(ctx, "ref", "cargo_vcs_info")
+				rebuild.RecordProvenance(ctx, "dir", "cargo_toml_search")
 				return ref, dir, nil
 			}
 		} else if err == plumbing.ErrObjectNotFound {
@@ -148,6 +188,8 @@ func inferRefAndDir(t rebuild.Target, vmeta *reg.CrateVersion, crateBytes []byte
 				log.Printf("using tag heuristic ref: %s", tagGuess[:9])
 				ref = tagGuess
 				dir = filepath.Dir(newPath)
+				rebuild.RecordProvenance(ctx, "ref", rebuild.HeuristicTag)
+				rebuild.RecordProvenance(ctx, "dir", "cargo_toml_search")
 				return ref, dir, nil
 			}
 		} else if err == plumbing.ErrObjectNotFound {
@@ -166,6 +208,8 @@ func inferRefAndDir(t rebuild.Target, vmeta *reg.CrateVersion, crateBytes []byte
 				log.Printf("using git log heuristic ref: %s", cargoTOMLGuess[:9])
 				ref = cargoTOMLGuess
 				dir = filepath.Dir(newPath)
+				rebuild.RecordProvenance(ctx, "ref", "cargo_toml_history")
+				rebuild.RecordProvenance(ctx, "dir", "cargo_toml_search")
 				return ref, dir, nil
 			}
 		} else if err == plumbing.ErrObjectNotFound {
@@ -177,12 +221,42 @@ func inferRefAndDir(t rebuild.Target, vmeta *reg.CrateVersion, crateBytes []byte
 		fallthrough
 	default:
 		if cargoVCSGuess == "" && tagGuess == "" && cargoTOMLGuess == "" {
-			return "", "", errors.Errorf("no git ref")
+			return "", "", rebuild.ErrNoRef
 		}
-		return "", "", errors.Errorf("no valid git ref")
+		return "", "", rebuild.ErrNoValidRef
 	}
 }
 
+// upstreamLockfile returns the Cargo.lock included in the published crate, if any.
+func upstreamLockfile(ctx context.Context, t rebuild.Target, vmeta *reg.CrateVersion, crate []byte) (*ExplicitLockfile, error) {
+	topLevel := t.Package + "-" + vmeta.Version.Version
+	lockContent, err := getFileFromCrate(bytes.NewReader(crate), topLevel+"/Cargo.lock")
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "[INTERNAL] Failed to extract upstream Cargo.lock")
+	}
+	rebuild.RecordProvenance(ctx, "explicit_lockfile", "upstream_crate")
+	return &ExplicitLockfile{
+		LockfileBase64: base64.StdEncoding.EncodeToString(lockContent),
+	}, nil
+}
+
+// registryRustVersion returns the rust version to use absent a toolchain pin.
+func registryRustVersion(ctx context.Context, vmeta *reg.CrateVersion) (string, error) {
+	if vmeta.RustVersion != "" {
+		rebuild.RecordProvenance(ctx, "rust_version", rebuild.HeuristicRegistry)
+		return vmeta.RustVersion, nil
+	}
+	// NOTE: Give a week's margin to allow for toolchain upgrades. Maybe raise.
+	rustVersion, err := reg.RustVersionAt(vmeta.Updated.Add(-7 * 24 * time.Hour))
+	if err != nil {
+		return "", errors.New("rust version heuristic failed")
+	}
+	rebuild.RecordProvenance(ctx, "rust_version", "publish_time")
+	return rustVersion, nil
+}
+
 func (Rebuilder) InferStrategy(ctx context.Context, t rebuild.Target, mux rebuild.RegistryMux, rcfg *rebuild.RepoConfig, hint rebuild.Strategy) (rebuild.Strategy, error) {
 	name, version := t.Package, t.Version
 	vmeta, err := mux.CratesIO.Version(ctx, name, version)
@@ -205,13 +279,16 @@ func (Rebuilder) InferStrategy(ctx context.Context, t rebuild.Target, mux rebuil
 	}
 	if lh != nil && lh.Ref != "" {
 		ref = lh.Ref
+		rebuild.RecordProvenance(ctx, "ref", rebuild.HeuristicHint)
 		if lh.Dir != "" {
 			dir = lh.Dir
+			rebuild.RecordProvenance(ctx, "dir", rebuild.HeuristicHint)
 		} else {
 			dir = rcfg.Dir
+			rebuild.RecordProvenance(ctx, "dir", "cargo_toml_search")
 		}
 	} else {
-		ref, dir, err = inferRefAndDir(t, vmeta, b, rcfg)
+		ref, dir, err = inferRefAndDir(ctx, t, vmeta, b, rcfg)
 		if err != nil {
 			return nil, err
 		}
@@ -233,34 +310,109 @@ func (Rebuilder) InferStrategy(ctx context.Context, t rebuild.Target, mux rebuil
 	if ct.Version() != version && ct.Version() != reg.WorkspaceVersion {
 		return nil, errors.Errorf("mismatched version [expected=%s,actual=%s]", version, ct.Version())
 	}
-	topLevel := t.Package + "-" + vmeta.Version.Version
-	lockContent, err := getFileFromCrate(bytes.NewReader(b), topLevel+"/Cargo.lock")
-	var lock *ExplicitLockfile
-	if errors.Is(err, fs.ErrNotExist) {
-		lock = nil
-	} else if err != nil {
-		return nil, errors.Wrapf(err, "[INTERNAL] Failed to extract upstream Cargo.lock")
-	} else {
-		lock = &ExplicitLockfile{
-			LockfileBase64: base64.StdEncoding.EncodeToString(lockContent),
-		}
+	// NOTE: A pinned toolchain is preferred over the registry's rust_version as
+	// the latter is only the minimum supported version.
+	pin, rustVersion, err := getRustToolchainPin(tree, dir)
+	if err != nil && err != object.ErrFileNotFound {
+		log.Println("ignoring rust toolchain pin:", err.Error())
 	}
-	rustVersion := vmeta.RustVersion
-	if rustVersion == "" {
-		// NOTE: Give a week's margin to allow for toolchain upgrades. Maybe raise.
-		rustVersion, err = reg.RustVersionAt(vmeta.Updated.Add(-7 * 24 * time.Hour))
-		if err != nil {
-			return nil, errors.New("rust version heuristic failed")
-		}
+	if err == nil {
+		rebuild.RecordProvenance(ctx, "rust_version", pin)
+	} else if rustVersion, err = registryRustVersion(ctx, vmeta); err != nil {
+		return nil, err
+	}
+	loc := rebuild.Location{
+		Repo: rcfg.URI,
+		Ref:  ref,
+		Dir:  dir,
+	}
+	if reg.IsDistArtifact(t.Artifact) {
+		return inferDistBuild(ctx, t, mux, rcfg, loc, rustVersion)
+	}
+	lock, err := upstreamLockfile(ctx, t, vmeta, b)
+	if err != nil {
+		return nil, err
+	}
+	return &CratesIOCargoPackage{
+		Location:         loc,
+		RustVersion:      rustVersion,
+		ExplicitLockfile: lock,
+	}, nil
+}
+
+// distTargets are the cargo-dist target triples that can be rebuilt.
+//
+// NOTE: Rebuilds execute on x86_64 Linux hosts so cross-compiled targets are excluded.
+var distTargets = []string{"x86_64-unknown-linux-gnu", "x86_64-unknown-linux-musl"}
+
+// inferDistBuild infers a CargoDistBuild for a cargo-dist release artifact
+// built from the same source location as the crate.
+func inferDistBuild(ctx context.Context, t rebuild.Target, mux rebuild.RegistryMux, rcfg *rebuild.RepoConfig, loc rebuild.Location, rustVersion string) (rebuild.Strategy, error) {
+	rel, err := mux.CratesIO.DistRelease(ctx, t.Package, t.Version)
+	if err != nil {
+		return nil, errors.Wrap(err, "locating cargo-dist release")
+	}
+	a, ok := rel.Manifest.Artifacts[t.Artifact]
+	if !ok {
+		return nil, errors.Errorf("artifact not in dist manifest: %s", t.Artifact)
+	}
+	if a.Kind != reg.DistExecutableArchive {
+		return nil, errors.Errorf("unsupported dist artifact kind: %s", a.Kind)
+	}
+	if len(a.TargetTriples) != 1 {
+		return nil, errors.Errorf("expected one target triple [artifact=%s,got=%d]", t.Artifact, len(a.TargetTriples))
+	}
+	if !slices.Contains(distTargets, a.TargetTriples[0]) {
+		return nil, errors.Errorf("unsupported target triple: %s", a.TargetTriples[0])
+	}
+	if rel.Manifest.DistVersion == "" {
+		return nil, errors.New("dist manifest missing dist_version")
+	}
+	// NOTE: The release is expected to be built from the same commit as the crate.
+	if h, err := rcfg.Repository.ResolveRevision(plumbing.Revision("refs/tags/" + rel.Tag)); err == nil && h.String() != loc.Ref {
+		return nil, errors.Errorf("dist release tag does not match crate ref [tag=%s,ref=%s]", rel.Tag, loc.Ref)
+	}
+	rebuild.RecordProvenance(ctx, "dist_version", "dist_manifest")
+	rebuild.RecordProvenance(ctx, "target_triple", "dist_manifest")
+	return &CargoDistBuild{
+		Location:     loc,
+		RustVersion:  rustVersion,
+		DistVersion:  rel.Manifest.DistVersion,
+		TargetTriple: a.TargetTriples[0],
+	}, nil
+}
+
+var _ rebuild.SourceArchiveInferer = Rebuilder{}
+
+// InferSourceArchiveStrategy infers a strategy that repackages the published crate.
+func (Rebuilder) InferSourceArchiveStrategy(ctx context.Context, t rebuild.Target, mux rebuild.RegistryMux) (rebuild.Strategy, error) {
+	vmeta, err := mux.CratesIO.Version(ctx, t.Package, t.Version)
+	if err != nil {
+		return nil, errors.Wrapf(err, "[INTERNAL] Failed to fetch crate version")
+	}
+	r, err := mux.CratesIO.Artifact(ctx, t.Package, t.Version)
+	if err != nil {
+		return nil, errors.Wrapf(err, "[INTERNAL] Failed to fetch upstream crate")
+	}
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrapf(err, "[INTERNAL] Failed to read upstream crate")
+	}
+	lock, err := upstreamLockfile(ctx, t, vmeta, b)
+	if err != nil {
+		return nil, err
+	}
+	rustVersion, err := registryRustVersion(ctx, vmeta)
+	if err != nil {
+		return nil, err
 	}
+	rebuild.RecordProvenance(ctx, "source_archive", rebuild.HeuristicRegistry)
 	return &CratesIOCargoPackage{
-		Location: rebuild.Location{
-			Repo: rcfg.URI,
-			Ref:  ref,
-			Dir:  dir,
-		},
+		Location:         rebuild.Location{Dir: "."},
 		RustVersion:      rustVersion,
 		ExplicitLockfile: lock,
+		SourceArchive:    &rebuild.SourceArchive{URL: vmeta.DownloadURL, SHA256: vmeta.Checksum},
 	}, nil
 }
 
@@ -0,0 +1,68 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cratesio
+
+import (
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+func TestGetRustToolchainPin(t *testing.T) {
+	tests := []struct {
+		name        string
+		file        string
+		content     string
+		wantVersion string
+		wantErr     bool
+	}{
+		{"toml", "rust-toolchain.toml", "[toolchain]\nchannel = \"1.70.0\"\ncomponents = [\"rustfmt\"]\n", "1.70.0", false},
+		{"toml minor", "rust-toolchain.toml", "[toolchain]\nchannel = \"1.70\"\n", "1.70.0", false},
+		{"toml named channel", "rust-toolchain.toml", "[toolchain]\nchannel = \"stable\"\n", "", true},
+		{"legacy", "rust-toolchain", "1.65.0\n", "1.65.0", false},
+		{"legacy toml", "rust-toolchain", "[toolchain]\nchannel = \"1.65.0\"\n", "1.65.0", false},
+		{"legacy nightly", "rust-toolchain", "nightly-2024-01-01\n", "", true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo, err := git.Init(memory.NewStorage(), memfs.New())
+			if err != nil {
+				t.Fatal(err)
+			}
+			wt, _ := repo.Worktree()
+			if err := util.WriteFile(wt.Filesystem, tc.file, []byte(tc.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			wt.Add(tc.file)
+			h, err := wt.Commit("pin", &git.CommitOptions{Author: &object.Signature{Name: "Test Author", Email: "test@example.com"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			c, _ := repo.CommitObject(h)
+			tree, _ := c.Tree()
+			pin, version, err := getRustToolchainPin(tree, "crates/foo")
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("getRustToolchainPin() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if err == nil && (pin != tc.file || version != tc.wantVersion) {
+				t.Errorf("getRustToolchainPin() = (%q, %q), want (%q, %q)", pin, version, tc.file, tc.wantVersion)
+			}
+		})
+	}
+}
@@ -71,9 +71,11 @@ func (Rebuilder) Rebuild(ctx context.Context, t rebuild.Target, inst rebuild.Ins
 	if _, err := rebuild.ExecuteScript(ctx, fs.Root(), inst.Source); err != nil {
 		return errors.Wrap(err, "failed to execute strategy.Source")
 	}
+	rebuild.LogPhase(rebuild.PhaseDeps)
 	if _, err := rebuild.ExecuteScript(ctx, fs.Root(), inst.Deps); err != nil {
 		return errors.Wrap(err, "failed to execute strategy.Deps")
 	}
+	rebuild.LogPhase(rebuild.PhaseBuild)
 	if _, err := rebuild.ExecuteScript(ctx, fs.Root(), inst.Build); err != nil {
 		return errors.Wrap(err, "failed to execute strategy.Build")
 	}
@@ -98,6 +100,9 @@ var (
 )
 
 func (Rebuilder) Compare(ctx context.Context, t rebuild.Target, rb, up rebuild.Asset, assets rebuild.AssetStore, inst rebuild.Instructions) (msg error, err error) {
+	if reg.IsDistArtifact(t.Artifact) {
+		return compareDist(ctx, t, rb, up, assets)
+	}
 	csRB, csUP, err := rebuild.Summarize(ctx, t, rb, up, assets)
 	if err != nil {
 		return nil, errors.Wrapf(err, "summarizing assets")
@@ -179,10 +184,34 @@ func (Rebuilder) Compare(ctx context.Context, t rebuild.Target, rb, up rebuild.A
 	}
 }
 
+// compareDist compares a cargo-dist release artifact with its rebuild.
+func compareDist(ctx context.Context, t rebuild.Target, rb, up rebuild.Asset, assets rebuild.AssetStore) (msg error, err error) {
+	csRB, csUP, err := rebuild.Summarize(ctx, t, rb, up, assets)
+	if err != nil {
+		return nil, errors.Wrapf(err, "summarizing assets")
+	}
+	upOnly, diffs, rbOnly := csUP.Diff(csRB)
+	switch {
+	case len(upOnly) > 0 && len(rbOnly) > 0:
+		return verdictMismatchedFiles, nil
+	case len(upOnly) > 0:
+		return verdictUpstreamOnly, nil
+	case len(rbOnly) > 0:
+		return verdictRebuildOnly, nil
+	case len(diffs) > 0:
+		return verdictContentDiff, nil
+	default:
+		return nil, nil
+	}
+}
+
 // RebuildMany executes rebuilds for each provided rebuild.Input returning their rebuild.Verdicts.
 func RebuildMany(ctx context.Context, inputs []rebuild.Input, mux rebuild.RegistryMux) ([]rebuild.Verdict, error) {
 	for i := range inputs {
-		inputs[i].Target.Artifact = artifactName(inputs[i].Target)
+		// NOTE: A provided artifact selects a cargo-dist release artifact.
+		if inputs[i].Target.Artifact == "" {
+			inputs[i].Target.Artifact = artifactName(inputs[i].Target)
+		}
 	}
 	return rebuild.RebuildMany(ctx, Rebuilder{}, inputs, mux)
 }
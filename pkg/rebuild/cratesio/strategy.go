@@ -30,13 +30,25 @@ type CratesIOCargoPackage struct {
 	rebuild.Location
 	RustVersion      string            `json:"rust_version" yaml:"rust_version,omitempty"`
 	ExplicitLockfile *ExplicitLockfile `json:"explicit_lockfile" yaml:"explicit_lockfile,omitempty"`
+	// SourceArchive, if provided, is the published crate from which to build in place of Location.
+	SourceArchive *rebuild.SourceArchive `json:"source_archive,omitempty" yaml:"source_archive,omitempty"`
 }
 
 var _ rebuild.Strategy = &CratesIOCargoPackage{}
+var _ rebuild.ToolchainVariant = &CratesIOCargoPackage{}
+
+// WithToolchain returns a copy of the build using the given rust version.
+//
+// NOTE: The rust version only takes effect when the BuildEnv prefers a precise toolchain.
+func (b *CratesIOCargoPackage) WithToolchain(version string) rebuild.Strategy {
+	c := *b
+	c.RustVersion = version
+	return &c
+}
 
 // Generate generates the instructions for a CratesIOCargoPackage
 func (b *CratesIOCargoPackage) GenerateFor(t rebuild.Target, be rebuild.BuildEnv) (rebuild.Instructions, error) {
-	src, err := rebuild.BasicSourceSetup(b.Location, &be)
+	src, err := rebuild.SourceSetup(b.Location, b.SourceArchive, &be)
 	if err != nil {
 		return rebuild.Instructions{}, err
 	}
@@ -44,6 +56,10 @@ func (b *CratesIOCargoPackage) GenerateFor(t rebuild.Target, be rebuild.BuildEnv
 	// many of these predate sparse index support introduced in 1.68.0. Without this, the full index
 	// requires ~700MB and minutes to fetch.
 	deps, err := rebuild.PopulateTemplate(`
+{{if ne .SourceArchive nil -}}
+{{- /* NOTE: Restore the manifest normalized by 'cargo package' when the crate was published. */ -}}
+mv Cargo.toml.orig Cargo.toml
+{{end -}}
 {{if ne .ExplicitLockfile nil -}}
 echo '{{.ExplicitLockfile.LockfileBase64}}' | base64 -d > Cargo.lock
 {{end -}}
@@ -63,6 +79,65 @@ echo '{{.ExplicitLockfile.LockfileBase64}}' | base64 -d > Cargo.lock
 		CratesIOCargoPackage
 		BuildEnv rebuild.BuildEnv
 	}{*b, be})
+	if err != nil {
+		return rebuild.Instructions{}, err
+	}
+	// NOTE: The lockfile is used in place of 'cargo metadata' as it also records package checksums.
+	closure, err := rebuild.PopulateTemplate(`
+cat "$(dirname "$(/root/.cargo/bin/cargo locate-project --workspace --message-format plain --manifest-path {{.Location.Dir}}/Cargo.toml)")/Cargo.lock"
+`, b)
+	if err != nil {
+		return rebuild.Instructions{}, err
+	}
+	return rebuild.Instructions{
+		Location:      b.Location,
+		Source:        src,
+		Deps:          deps,
+		Build:         build,
+		SystemDeps:    []string{"git", "rustup"},
+		OutputPath:    path.Join("target", "package", t.Artifact),
+		SourceArchive: b.SourceArchive,
+		Closure:       closure,
+	}, nil
+}
+
+// CargoDistBuild aggregates the options controlling a cargo-dist build of a crate's release binaries.
+type CargoDistBuild struct {
+	rebuild.Location
+	RustVersion  string `json:"rust_version" yaml:"rust_version,omitempty"`
+	DistVersion  string `json:"dist_version" yaml:"dist_version,omitempty"`
+	TargetTriple string `json:"target_triple" yaml:"target_triple,omitempty"`
+}
+
+var _ rebuild.Strategy = &CargoDistBuild{}
+var _ rebuild.ToolchainVariant = &CargoDistBuild{}
+
+// WithToolchain returns a copy of the build using the given rust version.
+func (b *CargoDistBuild) WithToolchain(version string) rebuild.Strategy {
+	c := *b
+	c.RustVersion = version
+	return &c
+}
+
+// GenerateFor generates the instructions for a CargoDistBuild.
+//
+// NOTE: cargo-dist builds from the workspace root so Location.Dir is only
+// used to locate the crate, not as the working directory.
+func (b *CargoDistBuild) GenerateFor(t rebuild.Target, be rebuild.BuildEnv) (rebuild.Instructions, error) {
+	src, err := rebuild.BasicSourceSetup(b.Location, &be)
+	if err != nil {
+		return rebuild.Instructions{}, err
+	}
+	deps, err := rebuild.PopulateTemplate(`
+/usr/bin/rustup-init -y --profile minimal --default-toolchain {{.RustVersion}} --target {{.TargetTriple}}
+/root/.cargo/bin/cargo install cargo-dist --version {{.DistVersion}} --locked
+`, b)
+	if err != nil {
+		return rebuild.Instructions{}, err
+	}
+	build, err := rebuild.PopulateTemplate(`
+/root/.cargo/bin/cargo dist build --artifacts=local --target {{.TargetTriple}}
+`, b)
 	if err != nil {
 		return rebuild.Instructions{}, err
 	}
@@ -72,6 +147,6 @@ echo '{{.ExplicitLockfile.LockfileBase64}}' | base64 -d > Cargo.lock
 		Deps:       deps,
 		Build:      build,
 		SystemDeps: []string{"git", "rustup"},
-		OutputPath: path.Join("target", "package", t.Artifact),
+		OutputPath: path.Join("target", "distrib", t.Artifact),
 	}, nil
 }
@@ -22,6 +22,9 @@ import (
 )
 
 func TestCratesIOCargoPackage(t *testing.T) {
+	closure := func(dir string) string {
+		return `cat "$(dirname "$(/root/.cargo/bin/cargo locate-project --workspace --message-format plain --manifest-path ` + dir + `/Cargo.toml)")/Cargo.lock"`
+	}
 
 	defaultLocation := rebuild.Location{
 		Dir:  "the_dir",
@@ -48,6 +51,7 @@ func TestCratesIOCargoPackage(t *testing.T) {
 				Build:      `/root/.cargo/bin/cargo package --no-verify --package "path+file://$(readlink -f the_dir)"`,
 				SystemDeps: []string{"git", "rustup"},
 				OutputPath: "target/package/the_artifact",
+				Closure:    closure("the_dir"),
 			},
 		},
 		{
@@ -67,6 +71,7 @@ func TestCratesIOCargoPackage(t *testing.T) {
 				Build:      `/root/.cargo/bin/cargo package --no-verify --package "path+file://$(readlink -f the_dir)"`,
 				SystemDeps: []string{"git", "rustup"},
 				OutputPath: "target/package/the_artifact",
+				Closure:    closure("the_dir"),
 			},
 		},
 		{
@@ -87,6 +92,7 @@ func TestCratesIOCargoPackage(t *testing.T) {
 				Build:      `/root/.cargo/bin/cargo package --no-verify --package "path+file://$(readlink -f the_dir)"`,
 				SystemDeps: []string{"git", "rustup"},
 				OutputPath: "target/package/the_artifact",
+				Closure:    closure("the_dir"),
 			},
 		},
 		{
@@ -108,6 +114,29 @@ func TestCratesIOCargoPackage(t *testing.T) {
 				Build:      `/root/.cargo/bin/cargo package --no-verify --package "path+file://$(readlink -f the_dir)"`,
 				SystemDeps: []string{"git", "rustup"},
 				OutputPath: "target/package/the_artifact",
+				Closure:    closure("the_dir"),
+			},
+		},
+		{
+			"SourceArchive",
+			&CratesIOCargoPackage{
+				Location:      rebuild.Location{Dir: "."},
+				RustVersion:   "1.77.0",
+				SourceArchive: &rebuild.SourceArchive{URL: "https://crates.io/api/v1/crates/the_package/the_version/download", SHA256: "the_digest"},
+			},
+			rebuild.BuildEnv{},
+			rebuild.Instructions{
+				Location: rebuild.Location{Dir: "."},
+				Source: `wget -O /tmp/source.tar.gz 'https://crates.io/api/v1/crates/the_package/the_version/download'
+echo 'the_digest  /tmp/source.tar.gz' | sha256sum -c -
+tar xzf /tmp/source.tar.gz --strip-components=1
+rm /tmp/source.tar.gz`,
+				Deps:          "mv Cargo.toml.orig Cargo.toml\n",
+				Build:         `/root/.cargo/bin/cargo package --no-verify --package "path+file://$(readlink -f .)"`,
+				SystemDeps:    []string{"git", "rustup"},
+				OutputPath:    "target/package/the_artifact",
+				Closure:       closure("."),
+				SourceArchive: &rebuild.SourceArchive{URL: "https://crates.io/api/v1/crates/the_package/the_version/download", SHA256: "the_digest"},
 			},
 		},
 		{
@@ -124,6 +153,7 @@ func TestCratesIOCargoPackage(t *testing.T) {
 				Build:      `/root/.cargo/bin/cargo package --no-verify`,
 				SystemDeps: []string{"git", "rustup"},
 				OutputPath: "target/package/the_artifact",
+				Closure:    closure("the_dir"),
 			},
 		},
 	}
@@ -139,3 +169,34 @@ func TestCratesIOCargoPackage(t *testing.T) {
 		})
 	}
 }
+
+func TestCargoDistBuild(t *testing.T) {
+	loc := rebuild.Location{
+		Dir:  "the_dir",
+		Ref:  "the_ref",
+		Repo: "the_repo",
+	}
+	s := &CargoDistBuild{
+		Location:     loc,
+		RustVersion:  "1.84.0",
+		DistVersion:  "0.28.0",
+		TargetTriple: "x86_64-unknown-linux-gnu",
+	}
+	want := rebuild.Instructions{
+		Location: loc,
+		Source:   "git checkout --force 'the_ref'",
+		Deps: `/usr/bin/rustup-init -y --profile minimal --default-toolchain 1.84.0 --target x86_64-unknown-linux-gnu
+/root/.cargo/bin/cargo install cargo-dist --version 0.28.0 --locked`,
+		Build:      `/root/.cargo/bin/cargo dist build --artifacts=local --target x86_64-unknown-linux-gnu`,
+		SystemDeps: []string{"git", "rustup"},
+		OutputPath: "target/distrib/the_package-x86_64-unknown-linux-gnu.tar.xz",
+	}
+	target := rebuild.Target{Ecosystem: rebuild.CratesIO, Package: "the_package", Version: "the_version", Artifact: "the_package-x86_64-unknown-linux-gnu.tar.xz"}
+	inst, err := s.GenerateFor(target, rebuild.BuildEnv{HasRepo: true})
+	if err != nil {
+		t.Fatalf("CargoDistBuild.GenerateFor() failed unexpectedly: %v", err)
+	}
+	if diff := cmp.Diff(inst, want); diff != "" {
+		t.Errorf("CargoDistBuild.GenerateFor() returned diff (-got +want):\n%s", diff)
+	}
+}
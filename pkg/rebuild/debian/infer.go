@@ -89,6 +89,7 @@ func (Rebuilder) InferStrategy(ctx context.Context, t rebuild.Target, mux rebuil
 					}
 				}
 				p.Requirements = append(p.Requirements, deps...)
+				rebuild.RecordProvenance(ctx, "requirements", "dsc_build_depends")
 			}
 		}
 	}
@@ -17,6 +17,9 @@ package debian
 import (
 	"bytes"
 	"context"
+	"fmt"
+	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/go-git/go-billy/v5"
@@ -27,6 +30,106 @@ import (
 type Rebuilder struct{}
 
 var _ rebuild.Rebuilder = Rebuilder{}
+var _ rebuild.OutputLister = Rebuilder{}
+
+// buildArch is the architecture of the hosts on which rebuilds are executed.
+const buildArch = "amd64"
+
+// buildArches are the Package-List architecture restrictions satisfied by buildArch.
+var buildArches = []string{"any", "linux-any", "any-" + buildArch, buildArch}
+
+var binNMURegex = regexp.MustCompile(`\+b\d+$`)
+
+// SourceArtifact returns the .dsc artifact that identifies the source package target.
+func SourceArtifact(t rebuild.Target) (string, error) {
+	_, name, err := ParseComponent(t.Package)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s_%s.dsc", name, t.Version), nil
+}
+
+// IsSourceArtifact returns whether the artifact identifies a source package target.
+func IsSourceArtifact(artifact string) bool {
+	return strings.HasSuffix(artifact, ".dsc")
+}
+
+// Outputs returns the binary packages, including arch:all packages, built
+// from a source package target.
+func (Rebuilder) Outputs(ctx context.Context, t rebuild.Target, mux rebuild.RegistryMux) ([]rebuild.Target, error) {
+	if !IsSourceArtifact(t.Artifact) {
+		return nil, nil
+	}
+	if binNMURegex.MatchString(t.Version) {
+		return nil, errors.Errorf("binary-only upload must be rebuilt per binary package: %s", t.Version)
+	}
+	component, name, err := ParseComponent(t.Package)
+	if err != nil {
+		return nil, err
+	}
+	_, dsc, err := mux.Debian.DSC(ctx, component, name, t.Version)
+	if err != nil {
+		return nil, err
+	}
+	pkgs, err := dsc.PackageList()
+	if err != nil {
+		return nil, err
+	}
+	var outputs []rebuild.Target
+	for _, bp := range pkgs {
+		if bp.Type != "deb" {
+			continue
+		}
+		var arch string
+		switch {
+		case slices.Contains(bp.Arches, "all"):
+			arch = "all"
+		case slices.ContainsFunc(bp.Arches, func(a string) bool { return slices.Contains(buildArches, a) }):
+			arch = buildArch
+		default:
+			continue
+		}
+		o := t
+		o.Artifact = fmt.Sprintf("%s_%s_%s.deb", bp.Name, t.Version, arch)
+		outputs = append(outputs, o)
+	}
+	if len(outputs) == 0 {
+		return nil, errors.Errorf("no %s binary packages found for %s", buildArch, t.Artifact)
+	}
+	return outputs, nil
+}
+
+// siblingBuild collects the other binary packages built from the same source
+// package as additional outputs of the build.
+type siblingBuild struct {
+	rebuild.Strategy
+	siblings []string
+}
+
+func (s siblingBuild) GenerateFor(t rebuild.Target, be rebuild.BuildEnv) (rebuild.Instructions, error) {
+	inst, err := s.Strategy.GenerateFor(t, be)
+	if err != nil {
+		return inst, err
+	}
+	inst.AdditionalOutputs = append(inst.AdditionalOutputs, s.siblings...)
+	return inst, nil
+}
+
+// WithSiblings returns a strategy that, in addition to the artifact built by s,
+// collects the given binary packages built from the same source package.
+//
+// NOTE: The returned strategy is only suitable for execution and must not be
+// recorded in place of s.
+func WithSiblings(s rebuild.Strategy, siblings []rebuild.Target) rebuild.Strategy {
+	if len(siblings) == 0 {
+		return s
+	}
+	sb := siblingBuild{Strategy: s}
+	for _, t := range siblings {
+		sb.siblings = append(sb.siblings, t.Artifact)
+	}
+	return sb
+}
 
 // We expect target.Packge to be in the form "<component>/<name>".
 func ParseComponent(pkg string) (component, name string, err error) {
@@ -41,9 +144,11 @@ func (Rebuilder) Rebuild(ctx context.Context, t rebuild.Target, inst rebuild.Ins
 	if _, err := rebuild.ExecuteScript(ctx, fs.Root(), inst.Source); err != nil {
 		return errors.Wrap(err, "failed to execute strategy.Source")
 	}
+	rebuild.LogPhase(rebuild.PhaseDeps)
 	if _, err := rebuild.ExecuteScript(ctx, fs.Root(), inst.Deps); err != nil {
 		return errors.Wrap(err, "failed to execute strategy.Deps")
 	}
+	rebuild.LogPhase(rebuild.PhaseBuild)
 	if _, err := rebuild.ExecuteScript(ctx, fs.Root(), inst.Build); err != nil {
 		return errors.Wrap(err, "failed to execute strategy.Build")
 	}
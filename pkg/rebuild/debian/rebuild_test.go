@@ -0,0 +1,123 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package debian
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	debianreg "github.com/google/oss-rebuild/pkg/registry/debian"
+)
+
+type fakeRegistry struct {
+	debianreg.Registry
+	dsc *debianreg.DSC
+}
+
+func (r fakeRegistry) DSC(_ context.Context, component, name, version string) (string, *debianreg.DSC, error) {
+	return debianreg.PoolURL(component, name, name+"_"+version+".dsc"), r.dsc, nil
+}
+
+func TestOutputs(t *testing.T) {
+	dsc := &debianreg.DSC{Stanzas: []debianreg.ControlStanza{{Fields: map[string][]string{
+		"Package-List": {
+			"liblzma-dev deb libdevel optional arch=any",
+			"liblzma-doc deb doc optional arch=all",
+			"xz-utils deb utils important arch=linux-any",
+			"xz-utils-udeb udeb debian-installer optional arch=linux-any",
+			"xz-utils-hurd deb utils optional arch=hurd-any",
+		},
+	}}}}
+	mux := rebuild.RegistryMux{Debian: fakeRegistry{dsc: dsc}}
+	src := rebuild.Target{Ecosystem: rebuild.Debian, Package: "main/xz-utils", Version: "5.2.4-1", Artifact: "xz-utils_5.2.4-1.dsc"}
+	for _, tc := range []struct {
+		name    string
+		target  rebuild.Target
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:   "SourcePackage",
+			target: src,
+			want:   []string{"liblzma-dev_5.2.4-1_amd64.deb", "liblzma-doc_5.2.4-1_all.deb", "xz-utils_5.2.4-1_amd64.deb"},
+		},
+		{
+			name:   "BinaryPackage",
+			target: rebuild.Target{Ecosystem: rebuild.Debian, Package: "main/xz-utils", Version: "5.2.4-1", Artifact: "xz-utils_5.2.4-1_amd64.deb"},
+		},
+		{
+			name:    "BinNMU",
+			target:  rebuild.Target{Ecosystem: rebuild.Debian, Package: "main/xz-utils", Version: "5.2.4-1+b1", Artifact: "xz-utils_5.2.4-1+b1.dsc"},
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			outputs, err := Rebuilder{}.Outputs(context.Background(), tc.target, mux)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Outputs() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			var got []string
+			for _, o := range outputs {
+				if o.Package != tc.target.Package || o.Version != tc.target.Version {
+					t.Errorf("Outputs() returned unexpected target: %v", o)
+				}
+				got = append(got, o.Artifact)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Outputs() returned diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWithSiblings(t *testing.T) {
+	target := rebuild.Target{Ecosystem: rebuild.Debian, Package: "main/xz-utils", Version: "5.2.4-1", Artifact: "liblzma-dev_5.2.4-1_amd64.deb"}
+	strategy := &DebianPackage{
+		DSC:    FileWithChecksum{URL: "https://deb.debian.org/debian/pool/main/x/xz-utils/xz-utils_5.2.4-1.dsc", MD5: "abc"},
+		Native: FileWithChecksum{URL: "https://deb.debian.org/debian/pool/main/x/xz-utils/xz-utils_5.2.4.tar.xz", MD5: "def"},
+	}
+	siblings := []rebuild.Target{target, target}
+	siblings[0].Artifact = "liblzma-doc_5.2.4-1_all.deb"
+	siblings[1].Artifact = "xz-utils_5.2.4-1_amd64.deb"
+	for _, tc := range []struct {
+		name     string
+		siblings []rebuild.Target
+		want     []string
+	}{
+		{
+			name: "NoSiblings",
+		},
+		{
+			name:     "Siblings",
+			siblings: siblings,
+			want:     []string{"liblzma-doc_5.2.4-1_all.deb", "xz-utils_5.2.4-1_amd64.deb"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			inst, err := WithSiblings(strategy, tc.siblings).GenerateFor(target, rebuild.BuildEnv{})
+			if err != nil {
+				t.Fatalf("GenerateFor() error = %v", err)
+			}
+			if inst.OutputPath != target.Artifact {
+				t.Errorf("GenerateFor() OutputPath = %s, want %s", inst.OutputPath, target.Artifact)
+			}
+			if diff := cmp.Diff(tc.want, inst.AdditionalOutputs); diff != "" {
+				t.Errorf("GenerateFor() AdditionalOutputs diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,275 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gomod
+
+import (
+	"context"
+	"log"
+	"path"
+	"strings"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/storage"
+	"github.com/google/oss-rebuild/internal/uri"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	reg "github.com/google/oss-rebuild/pkg/registry/gomod"
+	"github.com/pkg/errors"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+)
+
+// repoRootElems is the number of module path elements naming the repo on
+// hosts whose layout is known.
+const repoRootElems = 3
+
+// repoFromModulePath derives the repo URL from the module path for well-known hosts.
+//
+// NOTE: Vanity import paths require resolving the go-import meta tag which
+// is not supported. The proxy's Origin metadata should cover most of these.
+func repoFromModulePath(mod string) (string, error) {
+	elems := strings.Split(mod, "/")
+	if len(elems) < repoRootElems {
+		return "", errors.Errorf("unsupported module path: %s", mod)
+	}
+	switch elems[0] {
+	case "github.com", "gitlab.com", "bitbucket.org":
+		return "https://" + strings.Join(elems[:repoRootElems], "/"), nil
+	case "golang.org":
+		if elems[1] == "x" {
+			return "https://go.googlesource.com/" + elems[2], nil
+		}
+	}
+	return "", errors.Errorf("unsupported module host: %s", elems[0])
+}
+
+// moduleSubdir returns the directory of the module relative to the repo root.
+func moduleSubdir(mod string, origin *reg.Origin) string {
+	if origin != nil && origin.Subdir != "" {
+		return origin.Subdir
+	}
+	elems := strings.Split(mod, "/")
+	if len(elems) <= repoRootElems {
+		return ""
+	}
+	return path.Join(elems[repoRootElems:]...)
+}
+
+// stripMajor removes any major version suffix from the module subdir.
+func stripMajor(subdir string) string {
+	// NOTE: The leading slash allows a bare major version dir e.g. "v2" to match.
+	prefix, _, ok := module.SplitPathVersion("/" + subdir)
+	if !ok {
+		return subdir
+	}
+	return strings.TrimPrefix(prefix, "/")
+}
+
+// dirCandidates returns the locations in which the module's go.mod may reside.
+//
+// A major version suffix can either be a subdirectory of the repo or exist
+// only in the module path e.g. a v2 module may be rooted at "v2/" or ".".
+func dirCandidates(subdir string) []string {
+	dirs := []string{subdir}
+	if prefix := stripMajor(subdir); prefix != subdir {
+		dirs = append(dirs, prefix)
+	}
+	for i, d := range dirs {
+		if d == "" {
+			dirs[i] = "."
+		}
+	}
+	return dirs
+}
+
+// tagName returns the git tag the go command associates with the module version.
+func tagName(subdir, version string) string {
+	// NOTE: Tags for modules in major version subdirectories omit the suffix.
+	prefix := stripMajor(subdir)
+	version = strings.TrimSuffix(version, "+incompatible")
+	if prefix == "" {
+		return version
+	}
+	return prefix + "/" + version
+}
+
+func resolveTag(repo *git.Repository, name string) (string, error) {
+	ref, err := repo.Tag(name)
+	if err != nil {
+		return "", err
+	}
+	if t, err := repo.TagObject(ref.Hash()); err == nil {
+		// Annotated tag. Use the Target pointer as the ref hash.
+		return t.Target.String(), nil
+	}
+	// Lightweight tag. Use the ref hash itself.
+	return ref.Hash().String(), nil
+}
+
+// findGoMod returns the dir containing the go.mod declaring mod.
+func findGoMod(c *object.Commit, mod string, dirs []string) (string, error) {
+	tree, err := c.Tree()
+	if err != nil {
+		return "", err
+	}
+	for _, dir := range dirs {
+		f, err := tree.File(path.Join(dir, "go.mod"))
+		if err == object.ErrFileNotFound {
+			continue
+		} else if err != nil {
+			return "", err
+		}
+		content, err := f.Contents()
+		if err != nil {
+			return "", err
+		}
+		if actual := modfile.ModulePath([]byte(content)); actual == mod {
+			return dir, nil
+		} else {
+			log.Printf("mismatched module path [expected=%s,actual=%s,dir=%s]", mod, actual, dir)
+		}
+	}
+	return "", errors.Errorf("go.mod not found [dirs=%v]", dirs)
+}
+
+func (Rebuilder) InferRepo(ctx context.Context, t rebuild.Target, mux rebuild.RegistryMux) (string, error) {
+	info, err := mux.GoMod.Info(ctx, t.Package, t.Version)
+	if err != nil {
+		return "", err
+	}
+	if info.Origin != nil && info.Origin.URL != "" {
+		rebuild.RecordProvenance(ctx, "repo", "origin")
+		return uri.CanonicalizeRepoURI(info.Origin.URL)
+	}
+	repo, err := repoFromModulePath(t.Package)
+	if err != nil {
+		return "", err
+	}
+	rebuild.RecordProvenance(ctx, "repo", "module_path")
+	return uri.CanonicalizeRepoURI(repo)
+}
+
+func (Rebuilder) CloneRepo(ctx context.Context, t rebuild.Target, repoURI string, fs billy.Filesystem, s storage.Storer) (r rebuild.RepoConfig, err error) {
+	r.URI = repoURI
+	r.Repository, err = rebuild.LoadRepo(ctx, t.Package, s, fs, git.CloneOptions{URL: r.URI})
+	switch err {
+	case nil:
+	case transport.ErrAuthenticationRequired:
+		err = errors.Errorf("Repo invalid or private")
+		return
+	default:
+		err = errors.Wrapf(err, "Clone failed [repo=%s]", r.URI)
+		return
+	}
+	r.Dir = "."
+	r.RefMap = make(map[string]string)
+	return
+}
+
+func inferRefAndDir(ctx context.Context, t rebuild.Target, info *reg.Info, rcfg *rebuild.RepoConfig) (ref, dir string, err error) {
+	subdir := moduleSubdir(t.Package, info.Origin)
+	dirs := dirCandidates(subdir)
+	type candidate struct {
+		heuristic string
+		ref       string
+	}
+	var candidates []candidate
+	if info.Origin != nil && info.Origin.Hash != "" {
+		candidates = append(candidates, candidate{"origin", info.Origin.Hash})
+	}
+	if tag, err := resolveTag(rcfg.Repository, tagName(subdir, t.Version)); err == nil {
+		candidates = append(candidates, candidate{rebuild.HeuristicTag, tag})
+	} else if err != git.ErrTagNotFound {
+		return "", "", errors.Wrapf(err, "[INTERNAL] tag heuristic error")
+	}
+	if module.IsPseudoVersion(t.Version) {
+		rev, err := module.PseudoVersionRev(t.Version)
+		if err != nil {
+			return "", "", errors.Wrapf(err, "parsing pseudo-version")
+		}
+		if h, err := rcfg.Repository.ResolveRevision(plumbing.Revision(rev)); err == nil {
+			candidates = append(candidates, candidate{"pseudo_version", h.String()})
+		} else {
+			log.Printf("pseudo-version ref not found in repo: %v", err)
+		}
+	}
+	if len(candidates) == 0 {
+		return "", "", rebuild.ErrNoRef
+	}
+	for _, cand := range candidates {
+		c, err := rcfg.Repository.CommitObject(plumbing.NewHash(cand.ref))
+		if err == plumbing.ErrObjectNotFound {
+			log.Printf("%s ref not found in repo", cand.heuristic)
+			continue
+		} else if err != nil {
+			return "", "", errors.Wrapf(err, "[INTERNAL] Failed ref resolve from %s [repo=%s,ref=%s]", cand.heuristic, rcfg.URI, cand.ref)
+		}
+		dir, err := findGoMod(c, t.Package, dirs)
+		if err != nil {
+			log.Printf("%s ref invalid: %v", cand.heuristic, err)
+			continue
+		}
+		log.Printf("using %s ref: %s", cand.heuristic, cand.ref[:9])
+		rebuild.RecordProvenance(ctx, "ref", cand.heuristic)
+		rebuild.RecordProvenance(ctx, "dir", "go_mod_search")
+		return cand.ref, dir, nil
+	}
+	return "", "", rebuild.ErrNoValidRef
+}
+
+func (Rebuilder) InferStrategy(ctx context.Context, t rebuild.Target, mux rebuild.RegistryMux, rcfg *rebuild.RepoConfig, hint rebuild.Strategy) (rebuild.Strategy, error) {
+	info, err := mux.GoMod.Info(ctx, t.Package, t.Version)
+	if err != nil {
+		return nil, errors.Wrapf(err, "[INTERNAL] Failed to fetch module info")
+	}
+	var ref, dir string
+	lh, ok := hint.(*rebuild.LocationHint)
+	if hint != nil && !ok {
+		return nil, errors.Errorf("unsupported hint type: %T", hint)
+	}
+	if lh != nil && lh.Ref != "" {
+		ref = lh.Ref
+		rebuild.RecordProvenance(ctx, "ref", rebuild.HeuristicHint)
+		if lh.Dir != "" {
+			dir = lh.Dir
+			rebuild.RecordProvenance(ctx, "dir", rebuild.HeuristicHint)
+		} else {
+			c, err := rcfg.Repository.CommitObject(plumbing.NewHash(ref))
+			if err != nil {
+				return nil, err
+			}
+			dir, err = findGoMod(c, t.Package, dirCandidates(moduleSubdir(t.Package, info.Origin)))
+			if err != nil {
+				return nil, err
+			}
+			rebuild.RecordProvenance(ctx, "dir", "go_mod_search")
+		}
+	} else {
+		ref, dir, err = inferRefAndDir(ctx, t, info, rcfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &GoModZip{
+		Location: rebuild.Location{
+			Repo: rcfg.URI,
+			Ref:  ref,
+			Dir:  dir,
+		},
+	}, nil
+}
@@ -0,0 +1,107 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gomod
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	reg "github.com/google/oss-rebuild/pkg/registry/gomod"
+)
+
+func TestRepoFromModulePath(t *testing.T) {
+	testCases := []struct {
+		mod     string
+		want    string
+		wantErr bool
+	}{
+		{mod: "github.com/google/go-cmp", want: "https://github.com/google/go-cmp"},
+		{mod: "github.com/google/go-github/v62", want: "https://github.com/google/go-github"},
+		{mod: "gitlab.com/group/project/sub", want: "https://gitlab.com/group/project"},
+		{mod: "golang.org/x/mod", want: "https://go.googlesource.com/mod"},
+		{mod: "gopkg.in/yaml.v3", wantErr: true},
+		{mod: "github.com/google", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.mod, func(t *testing.T) {
+			got, err := repoFromModulePath(tc.mod)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("repoFromModulePath() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("repoFromModulePath() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTagAndDirs(t *testing.T) {
+	testCases := []struct {
+		name     string
+		mod      string
+		origin   *reg.Origin
+		version  string
+		wantTag  string
+		wantDirs []string
+	}{
+		{
+			name:     "Root",
+			mod:      "github.com/google/go-cmp",
+			version:  "v0.6.0",
+			wantTag:  "v0.6.0",
+			wantDirs: []string{"."},
+		},
+		{
+			name:     "MajorVersion",
+			mod:      "github.com/google/go-github/v62",
+			version:  "v62.0.0",
+			wantTag:  "v62.0.0",
+			wantDirs: []string{"v62", "."},
+		},
+		{
+			name:     "Nested",
+			mod:      "github.com/aws/aws-sdk-go-v2/service/s3",
+			version:  "v1.58.0",
+			wantTag:  "service/s3/v1.58.0",
+			wantDirs: []string{"service/s3"},
+		},
+		{
+			name:     "Incompatible",
+			mod:      "github.com/Azure/go-autorest",
+			version:  "v14.2.0+incompatible",
+			wantTag:  "v14.2.0",
+			wantDirs: []string{"."},
+		},
+		{
+			name:     "OriginSubdir",
+			mod:      "example.com/tool/v2",
+			origin:   &reg.Origin{Subdir: "tool"},
+			version:  "v2.1.0",
+			wantTag:  "tool/v2.1.0",
+			wantDirs: []string{"tool"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			subdir := moduleSubdir(tc.mod, tc.origin)
+			if got := tagName(subdir, tc.version); got != tc.wantTag {
+				t.Errorf("tagName() = %q, want %q", got, tc.wantTag)
+			}
+			if diff := cmp.Diff(tc.wantDirs, dirCandidates(subdir)); diff != "" {
+				t.Errorf("dirCandidates() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gomod provides rebuild support for Go modules served by the module proxy.
+package gomod
+
+import (
+	"context"
+	"sort"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/google/oss-rebuild/internal/semver"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/pkg/errors"
+)
+
+// GetVersions returns the versions to be processed, most recent to least recent.
+func GetVersions(ctx context.Context, pkg string, mux rebuild.RegistryMux) (versions []string, err error) {
+	vs, err := mux.GoMod.Versions(ctx, pkg)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range vs {
+		// Omit pre-release versions.
+		// TODO: Support rebuilding pre-release versions.
+		if s, err := semver.New(v); err != nil || s.Prerelease != "" {
+			continue
+		}
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return semver.Cmp(versions[i], versions[j]) > 0
+	})
+	return versions, nil
+}
+
+// ArtifactName returns the name of the module zip for the target.
+//
+// NOTE: This matches the file name served by the module proxy.
+func ArtifactName(t rebuild.Target) string {
+	return t.Version + ".zip"
+}
+
+type Rebuilder struct{}
+
+var _ rebuild.Rebuilder = Rebuilder{}
+
+func (Rebuilder) Rebuild(ctx context.Context, t rebuild.Target, inst rebuild.Instructions, fs billy.Filesystem) error {
+	if _, err := rebuild.ExecuteScript(ctx, fs.Root(), inst.Source); err != nil {
+		return errors.Wrap(err, "failed to execute strategy.Source")
+	}
+	rebuild.LogPhase(rebuild.PhaseDeps)
+	if _, err := rebuild.ExecuteScript(ctx, fs.Root(), inst.Deps); err != nil {
+		return errors.Wrap(err, "failed to execute strategy.Deps")
+	}
+	rebuild.LogPhase(rebuild.PhaseBuild)
+	if _, err := rebuild.ExecuteScript(ctx, fs.Root(), inst.Build); err != nil {
+		return errors.Wrap(err, "failed to execute strategy.Build")
+	}
+	return nil
+}
+
+var (
+	verdictMismatchedFiles = errors.New("mismatched file(s) in upstream and rebuild")
+	verdictUpstreamOnly    = errors.New("file(s) found in upstream but not rebuild")
+	verdictRebuildOnly     = errors.New("file(s) found in rebuild but not upstream")
+	verdictContentDiff     = errors.New("content differences found")
+)
+
+func (Rebuilder) Compare(ctx context.Context, t rebuild.Target, rb, up rebuild.Asset, assets rebuild.AssetStore, _ rebuild.Instructions) (msg error, err error) {
+	csRB, csUP, err := rebuild.Summarize(ctx, t, rb, up, assets)
+	if err != nil {
+		return nil, errors.Wrapf(err, "summarizing assets")
+	}
+	upOnly, diffs, rbOnly := csUP.Diff(csRB)
+	switch {
+	case len(upOnly) > 0 && len(rbOnly) > 0:
+		return verdictMismatchedFiles, nil
+	case len(upOnly) > 0:
+		return verdictUpstreamOnly, nil
+	case len(rbOnly) > 0:
+		return verdictRebuildOnly, nil
+	case len(diffs) > 0:
+		return verdictContentDiff, nil
+	default:
+		return nil, nil
+	}
+}
+
+// RebuildMany executes rebuilds for each provided rebuild.Input returning their rebuild.Verdicts.
+func RebuildMany(ctx context.Context, inputs []rebuild.Input, mux rebuild.RegistryMux) ([]rebuild.Verdict, error) {
+	for i := range inputs {
+		inputs[i].Target.Artifact = ArtifactName(inputs[i].Target)
+	}
+	return rebuild.RebuildMany(ctx, Rebuilder{}, inputs, mux)
+}
+
+// RebuildRemote executes the given target strategy on a remote builder.
+func RebuildRemote(ctx context.Context, input rebuild.Input, id string, opts rebuild.RemoteOptions) error {
+	// NOTE: Module zips contain only source so no registry is consulted during the build.
+	opts.UseTimewarp = false
+	return rebuild.RebuildRemote(ctx, input, id, opts)
+}
@@ -0,0 +1,99 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gomod
+
+import (
+	"encoding/base64"
+
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+)
+
+// xmodVersion is the version of golang.org/x/mod used to create module zips.
+const xmodVersion = "v0.20.0"
+
+// modzipSource is a program creating a module zip using the same library as the go command.
+//
+// NOTE: The go command provides no subcommand to create a module zip from source.
+const modzipSource = `package main
+
+import (
+	"log"
+	"os"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/zip"
+)
+
+func main() {
+	if len(os.Args) != 5 {
+		log.Fatal("usage: modzip <module> <version> <dir> <output>")
+	}
+	f, err := os.Create(os.Args[4])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := zip.CreateFromDir(f, module.Version{Path: os.Args[1], Version: os.Args[2]}, os.Args[3]); err != nil {
+		log.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
+`
+
+// GoModZip aggregates the options controlling the creation of a Go module zip.
+type GoModZip struct {
+	rebuild.Location
+}
+
+var _ rebuild.Strategy = &GoModZip{}
+
+// GenerateFor generates the instructions for a GoModZip.
+func (b *GoModZip) GenerateFor(t rebuild.Target, be rebuild.BuildEnv) (rebuild.Instructions, error) {
+	src, err := rebuild.BasicSourceSetup(b.Location, &be)
+	if err != nil {
+		return rebuild.Instructions{}, err
+	}
+	deps, err := rebuild.PopulateTemplate(`
+mkdir /tmp/modzip
+echo '{{.Source}}' | base64 -d > /tmp/modzip/main.go
+(cd /tmp/modzip && go mod init modzip && go get golang.org/x/mod@{{.XModVersion}} && go build -o /usr/local/bin/modzip .)
+`, map[string]string{
+		"Source":      base64.StdEncoding.EncodeToString([]byte(modzipSource)),
+		"XModVersion": xmodVersion,
+	})
+	if err != nil {
+		return rebuild.Instructions{}, err
+	}
+	// NOTE: The zip is written outside the module dir so it is not itself included.
+	build, err := rebuild.PopulateTemplate(`
+/usr/local/bin/modzip '{{.Target.Package}}' '{{.Target.Version}}' '{{.Location.Dir}}' '/tmp/{{.Target.Artifact}}'
+mv '/tmp/{{.Target.Artifact}}' '{{.Target.Artifact}}'
+`, struct {
+		GoModZip
+		Target rebuild.Target
+	}{*b, t})
+	if err != nil {
+		return rebuild.Instructions{}, err
+	}
+	return rebuild.Instructions{
+		Location:   b.Location,
+		Source:     src,
+		Deps:       deps,
+		Build:      build,
+		SystemDeps: []string{"git", "go"},
+		OutputPath: t.Artifact,
+	}, nil
+}
@@ -0,0 +1,50 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gomod
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+)
+
+func TestGoModZip(t *testing.T) {
+	loc := rebuild.Location{
+		Dir:  "the_dir",
+		Ref:  "the_ref",
+		Repo: "the_repo",
+	}
+	target := rebuild.Target{Ecosystem: rebuild.GoMod, Package: "example.com/mod", Version: "v1.2.3", Artifact: "v1.2.3.zip"}
+	got, err := (&GoModZip{Location: loc}).GenerateFor(target, rebuild.BuildEnv{HasRepo: true})
+	if err != nil {
+		t.Fatalf("GenerateFor() error = %v", err)
+	}
+	want := rebuild.Instructions{
+		Location: loc,
+		Source:   "git checkout --force 'the_ref'",
+		Deps: "mkdir /tmp/modzip\n" +
+			"echo '" + base64.StdEncoding.EncodeToString([]byte(modzipSource)) + "' | base64 -d > /tmp/modzip/main.go\n" +
+			"(cd /tmp/modzip && go mod init modzip && go get golang.org/x/mod@v0.20.0 && go build -o /usr/local/bin/modzip .)",
+		Build: "/usr/local/bin/modzip 'example.com/mod' 'v1.2.3' 'the_dir' '/tmp/v1.2.3.zip'\n" +
+			"mv '/tmp/v1.2.3.zip' 'v1.2.3.zip'",
+		SystemDeps: []string{"git", "go"},
+		OutputPath: "v1.2.3.zip",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("GenerateFor() mismatch (-want +got):\n%s", diff)
+	}
+}
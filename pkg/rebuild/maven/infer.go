@@ -181,9 +181,9 @@ func doInference(ctx context.Context, t rebuild.Target, rcfg *RepoConfig) (Build
 		fallthrough
 	default:
 		if tagGuess == "" && pomXMLGuess == "" {
-			return cfg, errors.Errorf("no git ref")
+			return cfg, rebuild.ErrNoRef
 		}
-		return cfg, errors.Errorf("no valid git ref")
+		return cfg, rebuild.ErrNoValidRef
 	}
 	jdk, err := getJarJDK(name, version)
 	if err != nil {
@@ -0,0 +1,162 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package meta describes the rebuild capabilities of each supported ecosystem.
+package meta
+
+import (
+	"slices"
+
+	"github.com/google/oss-rebuild/pkg/archive"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/pkg/errors"
+)
+
+// Feature is a rebuild capability that may be supported by an ecosystem.
+type Feature string
+
+const (
+	// Smoketest indicates packages can be rebuilt by the smoketest endpoint.
+	Smoketest Feature = "smoketest"
+	// Attest indicates rebuilds can be executed and attested by the API.
+	Attest Feature = "attest"
+	// Infer indicates strategies can be inferred by the inference endpoint.
+	Infer Feature = "infer"
+	// Timewarp indicates registry requests are served by timewarp in remote rebuilds.
+	Timewarp Feature = "timewarp"
+)
+
+// InferenceTier describes the maturity of an ecosystem's strategy inference.
+type InferenceTier string
+
+const (
+	// InferenceStable inference is served by the inference endpoint and used in production runs.
+	InferenceStable InferenceTier = "stable"
+	// InferenceExperimental inference is only available to local rebuilds.
+	InferenceExperimental InferenceTier = "experimental"
+)
+
+// Capabilities describes the rebuild support available for an ecosystem.
+type Capabilities struct {
+	Ecosystem rebuild.Ecosystem
+	Features  []Feature
+	// ArtifactTypes are the file extensions of the artifacts that can be rebuilt.
+	ArtifactTypes []string
+	Inference     InferenceTier
+	// Stabilizers are the names of the stabilizers applied to the ecosystem's artifacts.
+	Stabilizers []string
+}
+
+// Supports returns whether the ecosystem supports the feature.
+func (c Capabilities) Supports(f Feature) bool {
+	return slices.Contains(c.Features, f)
+}
+
+// registry is the capabilities of each ecosystem in presentation order.
+var registry = []Capabilities{
+	{
+		Ecosystem:     rebuild.NPM,
+		Features:      []Feature{Smoketest, Attest, Infer, Timewarp},
+		ArtifactTypes: []string{".tgz"},
+		Inference:     InferenceStable,
+	},
+	{
+		Ecosystem:     rebuild.PyPI,
+		Features:      []Feature{Smoketest, Attest, Infer, Timewarp},
+		ArtifactTypes: []string{".whl", ".tar.gz"},
+		Inference:     InferenceStable,
+	},
+	{
+		Ecosystem:     rebuild.CratesIO,
+		Features:      []Feature{Smoketest, Attest, Infer},
+		ArtifactTypes: []string{".crate", ".tar.gz", ".tar.xz", ".zip"},
+		Inference:     InferenceStable,
+	},
+	{
+		Ecosystem:     rebuild.Maven,
+		Features:      []Feature{Smoketest},
+		ArtifactTypes: []string{".jar"},
+		Inference:     InferenceExperimental,
+	},
+	{
+		Ecosystem:     rebuild.Debian,
+		Features:      []Feature{Smoketest, Attest, Infer},
+		ArtifactTypes: []string{".deb", ".dsc"},
+		Inference:     InferenceStable,
+	},
+	{
+		Ecosystem:     rebuild.GoMod,
+		Features:      []Feature{Smoketest, Attest, Infer},
+		ArtifactTypes: []string{".zip"},
+		Inference:     InferenceStable,
+	},
+	{
+		Ecosystem:     rebuild.RubyGems,
+		Features:      []Feature{Smoketest, Attest, Infer},
+		ArtifactTypes: []string{".gem"},
+		Inference:     InferenceStable,
+	},
+}
+
+func withStabilizers(c Capabilities) Capabilities {
+	c.Stabilizers = nil
+	for _, s := range rebuild.StabilizeOpts(rebuild.Target{Ecosystem: c.Ecosystem}).Stabilizers {
+		c.Stabilizers = append(c.Stabilizers, archive.StabilizerName(s))
+	}
+	return c
+}
+
+// All returns the capabilities of every known ecosystem.
+func All() []Capabilities {
+	all := make([]Capabilities, len(registry))
+	for i, c := range registry {
+		all[i] = withStabilizers(c)
+	}
+	return all
+}
+
+// Lookup returns the capabilities of the given ecosystem.
+func Lookup(e rebuild.Ecosystem) (Capabilities, bool) {
+	for _, c := range registry {
+		if c.Ecosystem == e {
+			return withStabilizers(c), true
+		}
+	}
+	return Capabilities{}, false
+}
+
+// Ecosystems returns the known ecosystems supporting all of the given features.
+func Ecosystems(features ...Feature) []rebuild.Ecosystem {
+	var es []rebuild.Ecosystem
+	for _, c := range registry {
+		if !slices.ContainsFunc(features, func(f Feature) bool { return !c.Supports(f) }) {
+			es = append(es, c.Ecosystem)
+		}
+	}
+	return es
+}
+
+// Require returns an error if the ecosystem is unknown or does not support the feature.
+func Require(e rebuild.Ecosystem, f Feature) error {
+	for _, c := range registry {
+		if c.Ecosystem != e {
+			continue
+		}
+		if !c.Supports(f) {
+			return errors.Errorf("%s not yet supported for %s", f, e)
+		}
+		return nil
+	}
+	return errors.Errorf("unknown ecosystem: %s", e)
+}
@@ -0,0 +1,88 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package meta
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+)
+
+func TestEcosystems(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		features []Feature
+		want     []rebuild.Ecosystem
+	}{
+		{
+			name: "All",
+			want: []rebuild.Ecosystem{rebuild.NPM, rebuild.PyPI, rebuild.CratesIO, rebuild.Maven, rebuild.Debian, rebuild.GoMod, rebuild.RubyGems},
+		},
+		{
+			name:     "Attest",
+			features: []Feature{Attest},
+			want:     []rebuild.Ecosystem{rebuild.NPM, rebuild.PyPI, rebuild.CratesIO, rebuild.Debian, rebuild.GoMod, rebuild.RubyGems},
+		},
+		{
+			name:     "AttestWithTimewarp",
+			features: []Feature{Attest, Timewarp},
+			want:     []rebuild.Ecosystem{rebuild.NPM, rebuild.PyPI},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, Ecosystems(tc.features...)); diff != "" {
+				t.Errorf("Ecosystems() returned diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRequire(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		ecosystem rebuild.Ecosystem
+		feature   Feature
+		wantErr   string
+	}{
+		{name: "Supported", ecosystem: rebuild.NPM, feature: Attest},
+		{name: "Unsupported", ecosystem: rebuild.Maven, feature: Attest, wantErr: "attest not yet supported for maven"},
+		{name: "Unknown", ecosystem: "cran", feature: Attest, wantErr: "unknown ecosystem: cran"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Require(tc.ecosystem, tc.feature)
+			var got string
+			if err != nil {
+				got = err.Error()
+			}
+			if got != tc.wantErr {
+				t.Errorf("Require() = %q, want %q", got, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	c, ok := Lookup(rebuild.CratesIO)
+	if !ok {
+		t.Fatal("Lookup() did not find cratesio")
+	}
+	if len(c.Stabilizers) != len(rebuild.StabilizeOpts(rebuild.Target{Ecosystem: rebuild.CratesIO}).Stabilizers) {
+		t.Errorf("Lookup() returned %d stabilizers", len(c.Stabilizers))
+	}
+	if _, ok := Lookup("cran"); ok {
+		t.Error("Lookup() found unknown ecosystem")
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package npm
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/pkg/errors"
+)
+
+type lockEntry struct {
+	// Name is only populated for aliased packages.
+	Name         string               `json:"name"`
+	Version      string               `json:"version"`
+	Resolved     string               `json:"resolved"`
+	Integrity    string               `json:"integrity"`
+	Link         bool                 `json:"link"`
+	Dependencies map[string]lockEntry `json:"dependencies"`
+}
+
+// packageLock is the subset of package-lock.json describing the installed tree.
+type packageLock struct {
+	// Packages is keyed by install path and is present in lockfileVersion 2 and later.
+	Packages map[string]lockEntry `json:"packages"`
+	// Dependencies is keyed by name and is present in lockfileVersion 1 and 2.
+	Dependencies map[string]lockEntry `json:"dependencies"`
+}
+
+// sriDigest converts a Subresource Integrity string (e.g. "sha512-<base64>")
+// to a set of hex-encoded digests. Unrecognized entries are ignored.
+func sriDigest(integrity string) map[string]string {
+	digest := make(map[string]string)
+	for _, entry := range strings.Fields(integrity) {
+		alg, b64, ok := strings.Cut(entry, "-")
+		if !ok {
+			continue
+		}
+		b, err := base64.StdEncoding.DecodeString(b64)
+		if err != nil {
+			continue
+		}
+		digest[alg] = hex.EncodeToString(b)
+	}
+	if len(digest) == 0 {
+		return nil
+	}
+	return digest
+}
+
+func (e lockEntry) dependency(name string) rebuild.Dependency {
+	if e.Name != "" {
+		name = e.Name
+	}
+	return rebuild.Dependency{Name: name, Version: e.Version, URI: e.Resolved, Digest: sriDigest(e.Integrity)}
+}
+
+func collectLegacy(deps map[string]lockEntry, out []rebuild.Dependency) []rebuild.Dependency {
+	for name, e := range deps {
+		out = append(out, e.dependency(name))
+		out = collectLegacy(e.Dependencies, out)
+	}
+	return out
+}
+
+// ParseClosure parses the dependency closure from the package-lock.json
+// produced by installing the build's dependencies.
+func ParseClosure(b []byte) ([]rebuild.Dependency, error) {
+	var lock packageLock
+	if err := json.Unmarshal(b, &lock); err != nil {
+		return nil, errors.Wrap(err, "parsing package-lock.json")
+	}
+	var deps []rebuild.Dependency
+	if len(lock.Packages) > 0 {
+		for p, e := range lock.Packages {
+			// NOTE: Skip the root package and workspace members which are not installed from a registry.
+			idx := strings.LastIndex(p, "node_modules/")
+			if idx == -1 || e.Link || e.Version == "" {
+				continue
+			}
+			deps = append(deps, e.dependency(p[idx+len("node_modules/"):]))
+		}
+	} else {
+		deps = collectLegacy(lock.Dependencies, nil)
+	}
+	return rebuild.NormalizeDependencies(deps), nil
+}
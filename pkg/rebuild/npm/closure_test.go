@@ -0,0 +1,80 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package npm
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+)
+
+func TestParseClosure(t *testing.T) {
+	lodash := rebuild.Dependency{
+		Name:    "lodash",
+		Version: "4.17.21",
+		URI:     "https://registry.npmjs.org/lodash/-/lodash-4.17.21.tgz",
+		Digest:  map[string]string{"sha1": "679591c564c3bffaae8454cf0b3df370c3d6911c"},
+	}
+	tests := []struct {
+		name string
+		lock string
+		want []rebuild.Dependency
+	}{
+		{
+			name: "v3",
+			lock: `{
+				"lockfileVersion": 3,
+				"packages": {
+					"": {"name": "foo", "version": "1.0.0"},
+					"node_modules/lodash": {"version": "4.17.21", "resolved": "https://registry.npmjs.org/lodash/-/lodash-4.17.21.tgz", "integrity": "sha1-Z5WRxWTDv/quhFTPCz3zcMPWkRw="},
+					"node_modules/bar/node_modules/lodash": {"version": "4.17.21", "resolved": "https://registry.npmjs.org/lodash/-/lodash-4.17.21.tgz", "integrity": "sha1-Z5WRxWTDv/quhFTPCz3zcMPWkRw="},
+					"node_modules/local": {"resolved": "packages/local", "link": true}
+				}
+			}`,
+			want: []rebuild.Dependency{lodash},
+		},
+		{
+			name: "v1",
+			lock: `{
+				"lockfileVersion": 1,
+				"dependencies": {
+					"bar": {
+						"version": "1.0.0",
+						"resolved": "https://registry.npmjs.org/bar/-/bar-1.0.0.tgz",
+						"dependencies": {
+							"lodash": {"version": "4.17.21", "resolved": "https://registry.npmjs.org/lodash/-/lodash-4.17.21.tgz", "integrity": "sha1-Z5WRxWTDv/quhFTPCz3zcMPWkRw="}
+						}
+					}
+				}
+			}`,
+			want: []rebuild.Dependency{
+				{Name: "bar", Version: "1.0.0", URI: "https://registry.npmjs.org/bar/-/bar-1.0.0.tgz"},
+				lodash,
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseClosure([]byte(tc.lock))
+			if err != nil {
+				t.Fatalf("ParseClosure() error = %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("ParseClosure() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
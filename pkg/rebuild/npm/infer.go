@@ -16,8 +16,11 @@ package npm
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"path"
 	"path/filepath"
@@ -50,11 +53,30 @@ func getPackageJSON(tree *object.Tree, path string) (pkgJSON npmreg.PackageJSON,
 	return
 }
 
+// nodeVersionRE matches the fully-qualified node versions that can be
+// installed. Aliases and partial versions (e.g. "lts/*", "18") are unsupported.
+var nodeVersionRE = regexp.MustCompile(`^v?(\d+\.\d+\.\d+)$`)
+
+// getNodeVersionPin returns the node version pinned by a .nvmrc or
+// .node-version file applicable to dir.
+func getNodeVersionPin(tree *object.Tree, dir string) (pin, version string, err error) {
+	pin, content, err := rebuild.FindPinFile(tree, dir, ".nvmrc", ".node-version")
+	if err != nil {
+		return "", "", err
+	}
+	m := nodeVersionRE.FindStringSubmatch(strings.TrimSpace(content))
+	if m == nil {
+		return "", "", errors.Errorf("unsupported %s version: %q", pin, strings.TrimSpace(content))
+	}
+	return pin, m[1], nil
+}
+
 func (Rebuilder) InferRepo(ctx context.Context, t rebuild.Target, mux rebuild.RegistryMux) (string, error) {
 	vmeta, err := mux.NPM.Version(ctx, t.Package, t.Version)
 	if err != nil {
 		return "", err
 	}
+	rebuild.RecordProvenance(ctx, "repo", rebuild.HeuristicRegistry)
 	return uri.CanonicalizeRepoURI(vmeta.Repository.URL)
 }
 
@@ -86,17 +108,20 @@ func (Rebuilder) CloneRepo(ctx context.Context, t rebuild.Target, repoURI string
 	return
 }
 
-func inferFromRepo(t rebuild.Target, vmeta *npmreg.NPMVersion, rcfg *rebuild.RepoConfig) (ref, dir, versionOverride string, err error) {
+func inferFromRepo(ctx context.Context, t rebuild.Target, vmeta *npmreg.NPMVersion, rcfg *rebuild.RepoConfig) (ref, dir, versionOverride string, err error) {
 	// Determine dir for build.
 	if vmeta.Directory != "" {
 		if rcfg.Dir != "" && rcfg.Dir != vmeta.Directory {
 			log.Printf("package.json path disagreement [metadata=%s,heuristic=%s]\n", vmeta.Directory, rcfg.Dir)
 		}
 		dir = vmeta.Directory
+		rebuild.RecordProvenance(ctx, "dir", rebuild.HeuristicRegistry)
 	} else if rcfg.Dir != "" {
 		dir = rcfg.Dir
+		rebuild.RecordProvenance(ctx, "dir", "package_json_search")
 	} else {
 		dir = "."
+		rebuild.RecordProvenance(ctx, "dir", rebuild.HeuristicDefault)
 	}
 	// Determine git ref to rebuild.
 	registryRef := vmeta.GitHEAD
@@ -120,6 +145,8 @@ func inferFromRepo(t rebuild.Target, vmeta *npmreg.NPMVersion, rcfg *rebuild.Rep
 				log.Printf("using registry ref: %s", registryRef[:9])
 				ref = registryRef
 				dir = filepath.Dir(newPath)
+				rebuild.RecordProvenance(ctx, "ref", rebuild.HeuristicRegistry)
+				rebuild.RecordProvenance(ctx, "dir", "package_json_search")
 				return ref, dir, "", nil
 			}
 		} else if err == plumbing.ErrObjectNotFound {
@@ -140,6 +167,8 @@ func inferFromRepo(t rebuild.Target, vmeta *npmreg.NPMVersion, rcfg *rebuild.Rep
 				log.Printf("using tag heuristic ref: %s", tagGuess[:9])
 				ref = tagGuess
 				dir = filepath.Dir(newPath)
+				rebuild.RecordProvenance(ctx, "ref", rebuild.HeuristicTag)
+				rebuild.RecordProvenance(ctx, "dir", "package_json_search")
 				return ref, dir, "", nil
 			}
 		} else if err == plumbing.ErrObjectNotFound {
@@ -159,6 +188,8 @@ func inferFromRepo(t rebuild.Target, vmeta *npmreg.NPMVersion, rcfg *rebuild.Rep
 				log.Printf("using git log heuristic ref: %s", pkgJSONGuess[:9])
 				ref = pkgJSONGuess
 				dir = filepath.Dir(newPath)
+				rebuild.RecordProvenance(ctx, "ref", "package_json_history")
+				rebuild.RecordProvenance(ctx, "dir", "package_json_search")
 				return ref, dir, "", nil
 			}
 		} else if err == plumbing.ErrObjectNotFound {
@@ -173,36 +204,66 @@ func inferFromRepo(t rebuild.Target, vmeta *npmreg.NPMVersion, rcfg *rebuild.Rep
 			c, _ = rcfg.Repository.CommitObject(plumbing.NewHash(badVersionRef))
 			ref = badVersionRef
 			versionOverride = t.Version
+			rebuild.RecordProvenance(ctx, "ref", "version_override_recovery")
+			rebuild.RecordProvenance(ctx, "version_override", "version_override_recovery")
 			return ref, dir, versionOverride, nil
 		} else if registryRef == "" && tagGuess == "" && pkgJSONGuess == "" {
-			return "", "", "", errors.Errorf("no git ref")
+			return "", "", "", rebuild.ErrNoRef
 		} else {
-			return "", "", "", errors.Errorf("no valid git ref")
+			return "", "", "", rebuild.ErrNoValidRef
 		}
 	}
 }
 
-func (Rebuilder) InferStrategy(ctx context.Context, t rebuild.Target, mux rebuild.RegistryMux, rcfg *rebuild.RepoConfig, hint rebuild.Strategy) (rebuild.Strategy, error) {
-	name, version := t.Package, t.Version
-	vmeta, err := mux.NPM.Version(ctx, name, version)
-	if err != nil {
-		return nil, err
-	}
+// inferNPMVersion returns the NPM CLI version with which to pack the package.
+func inferNPMVersion(ctx context.Context, reg npmreg.Registry, t rebuild.Target, vmeta *npmreg.NPMVersion) (string, error) {
 	npmv := vmeta.NPMVersion
+	rebuild.RecordProvenance(ctx, "npm_version", rebuild.HeuristicRegistry)
 	if npmv == "" {
-		// TODO: Guess based on upload date.
-		return nil, errors.New("No NPM version")
+		// NOTE: Without a recorded version, use the NPM CLI release that was
+		// tagged latest when the package was published, subject to its engines.
+		pmeta, err := reg.Package(ctx, t.Package)
+		if err != nil {
+			return "", errors.Wrap(err, "[INTERNAL] fetching package metadata")
+		}
+		ut, ok := pmeta.UploadTimes[t.Version]
+		if !ok {
+			return "", errors.New("No NPM version")
+		}
+		rels, err := npmreg.KnownReleases()
+		if err != nil {
+			return "", errors.Wrap(err, "[INTERNAL] loading toolchain releases")
+		}
+		if npmv, err = PickNPMVersion(rels, vmeta.Engine("npm"), ut); err != nil {
+			return "", errors.Wrap(err, "No NPM version")
+		}
+		rebuild.RecordProvenance(ctx, "npm_version", "upload_time")
 	}
 	if s, err := semver.New(npmv); err != nil || s.Prerelease != "" || s.Build != "" {
-		return nil, errors.Errorf("Unsupported NPM version '%s'", npmv)
+		return "", errors.Errorf("Unsupported NPM version '%s'", npmv)
 	} else if s.Major < 5 {
 		// XXX: Upgrade all previous versions to 5.0.4 to fix incompatibilities.
 		npmv = "5.0.4"
+		rebuild.RecordProvenance(ctx, "npm_version", "minimum_upgrade")
 	} else if s.Major == 5 && (s.Minor == 4 || s.Minor == 5) {
 		// NOTE: Some versions of NPM 5 had issues with Node 9 and higher.
 		// Fix: https://github.com/npm/npm/commit/c851bb503a756b7cd48d12ef0e12f39e6f30c577
 		// Release: https://github.com/npm/npm/releases/tag/v5.6.0
 		npmv = "5.6.0"
+		rebuild.RecordProvenance(ctx, "npm_version", "minimum_upgrade")
+	}
+	return npmv, nil
+}
+
+func (Rebuilder) InferStrategy(ctx context.Context, t rebuild.Target, mux rebuild.RegistryMux, rcfg *rebuild.RepoConfig, hint rebuild.Strategy) (rebuild.Strategy, error) {
+	name, version := t.Package, t.Version
+	vmeta, err := mux.NPM.Version(ctx, name, version)
+	if err != nil {
+		return nil, err
+	}
+	npmv, err := inferNPMVersion(ctx, mux.NPM, t, vmeta)
+	if err != nil {
+		return nil, err
 	}
 	var ref, dir, override string
 	lh, ok := hint.(*rebuild.LocationHint)
@@ -211,13 +272,16 @@ func (Rebuilder) InferStrategy(ctx context.Context, t rebuild.Target, mux rebuil
 	}
 	if lh != nil && lh.Ref != "" {
 		ref = lh.Ref
+		rebuild.RecordProvenance(ctx, "ref", rebuild.HeuristicHint)
 		if lh.Dir != "" {
 			dir = lh.Dir
+			rebuild.RecordProvenance(ctx, "dir", rebuild.HeuristicHint)
 		} else {
 			dir = rcfg.Dir
+			rebuild.RecordProvenance(ctx, "dir", "package_json_search")
 		}
 	} else {
-		ref, dir, override, err = inferFromRepo(t, vmeta, rcfg)
+		ref, dir, override, err = inferFromRepo(ctx, t, vmeta, rcfg)
 		if err != nil {
 			return nil, err
 		}
@@ -246,9 +310,32 @@ func (Rebuilder) InferStrategy(ctx context.Context, t rebuild.Target, mux rebuil
 			}
 			// TODO: detect and install pnpm
 			// TODO: detect and install yarn
+			// NOTE: The registry records the node version used to publish so it is
+			// preferred over any version pinned in the repo.
+			nodeVersion := vmeta.NodeVersion
+			if nodeVersion != "" {
+				rebuild.RecordProvenance(ctx, "node_version", rebuild.HeuristicRegistry)
+			} else if pin, v, err := getNodeVersionPin(tree, dir); err == nil {
+				nodeVersion = v
+				rebuild.RecordProvenance(ctx, "node_version", pin)
+			} else {
+				if err != object.ErrFileNotFound {
+					log.Println("ignoring node version pin:", err.Error())
+				}
+				rels, err := npmreg.KnownReleases()
+				if err != nil {
+					return nil, errors.Wrap(err, "[INTERNAL] loading toolchain releases")
+				}
+				if nodeVersion, err = PickNodeVersion(rels, vmeta.Engine("node"), ut); err != nil {
+					return nil, errors.Wrap(err, "No node version")
+				}
+				rebuild.RecordProvenance(ctx, "node_version", "upload_time")
+			}
+			rebuild.RecordProvenance(ctx, "command", "package_json_scripts")
+			rebuild.RecordProvenance(ctx, "registry_time", rebuild.HeuristicRegistry)
 			return &NPMCustomBuild{
 				NPMVersion:      npmv,
-				NodeVersion:     vmeta.NodeVersion,
+				NodeVersion:     nodeVersion,
 				VersionOverride: override,
 				Command:         "build",
 				RegistryTime:    ut,
@@ -271,6 +358,38 @@ func (Rebuilder) InferStrategy(ctx context.Context, t rebuild.Target, mux rebuil
 	}, nil
 }
 
+var _ rebuild.SourceArchiveInferer = Rebuilder{}
+
+// InferSourceArchiveStrategy infers a strategy that repacks the published tarball.
+//
+// NOTE: Packing runs the package's prepack and prepare scripts so this may
+// reproduce artifacts whose contents were generated at publish time.
+func (Rebuilder) InferSourceArchiveStrategy(ctx context.Context, t rebuild.Target, mux rebuild.RegistryMux) (rebuild.Strategy, error) {
+	vmeta, err := mux.NPM.Version(ctx, t.Package, t.Version)
+	if err != nil {
+		return nil, err
+	}
+	npmv, err := inferNPMVersion(ctx, mux.NPM, t, vmeta)
+	if err != nil {
+		return nil, err
+	}
+	r, err := mux.NPM.Artifact(ctx, t.Package, t.Version)
+	if err != nil {
+		return nil, errors.Wrap(err, "[INTERNAL] fetching tarball")
+	}
+	defer r.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return nil, errors.Wrap(err, "[INTERNAL] reading tarball")
+	}
+	rebuild.RecordProvenance(ctx, "source_archive", rebuild.HeuristicRegistry)
+	return &NPMPackBuild{
+		Location:      rebuild.Location{Dir: "."},
+		NPMVersion:    npmv,
+		SourceArchive: &rebuild.SourceArchive{URL: vmeta.Dist.URL, SHA256: hex.EncodeToString(h.Sum(nil))},
+	}, nil
+}
+
 // findAndValidatePackageJSON ensures the package config has the expected name and version,
 // or finds a new version if necessary.
 func findAndValidatePackageJSON(repo *git.Repository, c *object.Commit, name, version, guess string) (string, error) {
@@ -91,6 +91,7 @@ func (Rebuilder) Rebuild(ctx context.Context, t rebuild.Target, inst rebuild.Ins
 	if _, err := rebuild.ExecuteScript(ctx, fs.Root(), inst.Source); err != nil {
 		return errors.Wrap(err, "failed to execute strategy.Source")
 	}
+	rebuild.LogPhase(rebuild.PhaseDeps)
 	if output, err := rebuild.ExecuteScript(ctx, fs.Root(), inst.Deps); err != nil {
 		switch {
 		case nodeFetchPat.FindString(output) != "":
@@ -99,6 +100,7 @@ func (Rebuilder) Rebuild(ctx context.Context, t rebuild.Target, inst rebuild.Ins
 			return errors.Wrap(err, "failed to execute strategy.Deps")
 		}
 	}
+	rebuild.LogPhase(rebuild.PhaseBuild)
 	if output, err := rebuild.ExecuteScript(ctx, fs.Root(), inst.Build); err != nil {
 		// Build failed. Let's try to figure out why.
 		switch {
@@ -16,24 +16,116 @@ package npm
 
 import (
 	"path"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/pkg/errors"
 )
 
+// recompressGzip returns a command to re-compress the tarball at path with fixed
+// gzip parameters such that the output does not depend on the zlib bundled with
+// the node version used to pack it. The original header is logged to retain the
+// initial compression metadata.
+//
+// NOTE: The system node installation is used regardless of the build's node
+// version so the compressor is constant across builds.
+func recompressGzip(path string) string {
+	const script = `const fs = require("fs"), zlib = require("zlib");` +
+		`const f = process.argv[1], b = fs.readFileSync(f);` +
+		`console.log("original gzip header: " + b.subarray(0, 10).toString("hex"));` +
+		`fs.writeFileSync(f, zlib.gzipSync(zlib.gunzipSync(b), {level: 9}));`
+	return "/usr/bin/node -e '" + script + "' " + path
+}
+
+var scriptNameRegex = regexp.MustCompile(`^[a-zA-Z0-9:_-]+$`)
+
+// ScriptPolicy controls the lifecycle scripts (e.g. prepare, prepublishOnly)
+// run when packing. A nil ScriptPolicy runs all scripts as npm does by default.
+//
+// NOTE: Publish-time scripts may be required to reproduce an artifact but
+// execute arbitrary code from the package.
+type ScriptPolicy struct {
+	// Ignore prevents npm from running lifecycle scripts.
+	Ignore bool `json:"ignore,omitempty" yaml:"ignore,omitempty"`
+	// Allow lists the scripts to run explicitly, in order, when scripts are ignored.
+	Allow []string `json:"allow,omitempty" yaml:"allow,omitempty"`
+}
+
+func (p *ScriptPolicy) validate() error {
+	if p == nil {
+		return nil
+	}
+	if len(p.Allow) > 0 && !p.Ignore {
+		return errors.New("allowed scripts require ignoring scripts")
+	}
+	for _, s := range p.Allow {
+		if !scriptNameRegex.MatchString(s) {
+			return errors.Errorf("invalid script name: %q", s)
+		}
+	}
+	return nil
+}
+
+// flags returns the npm flags applying the policy.
+func (p *ScriptPolicy) flags() string {
+	if p == nil || !p.Ignore {
+		return ""
+	}
+	return " --ignore-scripts"
+}
+
+// pack returns the npm command to pack the package under the policy.
+func (p *ScriptPolicy) pack() string {
+	var cmds []string
+	if p != nil {
+		for _, s := range p.Allow {
+			cmds = append(cmds, "npm run --if-present "+s)
+		}
+	}
+	return strings.Join(append(cmds, "npm pack"+p.flags()), " && ")
+}
+
+// String describes the policy as "all", "none", or the comma-separated allowed scripts.
+func (p *ScriptPolicy) String() string {
+	switch {
+	case p == nil || !p.Ignore:
+		return "all"
+	case len(p.Allow) == 0:
+		return "none"
+	default:
+		return strings.Join(p.Allow, ",")
+	}
+}
+
 type NPMPackBuild struct {
 	rebuild.Location
 	// NPMVersion is the version of the NPM CLI to use for the build.
 	NPMVersion string `json:"npm_version" yaml:"npm_version"`
 	// VersionOverride provides an alternative version value to apply to the package.json file.
 	VersionOverride string `json:"version_override" yaml:"version_override,omitempty"`
+	// RecompressGzip re-compresses the packed tarball with fixed gzip parameters.
+	RecompressGzip bool `json:"recompress_gzip,omitempty" yaml:"recompress_gzip,omitempty"`
+	// LifecycleScripts controls the lifecycle scripts run when packing.
+	LifecycleScripts *ScriptPolicy `json:"lifecycle_scripts,omitempty" yaml:"lifecycle_scripts,omitempty"`
+	// SourceArchive, if provided, is the published tarball from which to build in place of Location.
+	SourceArchive *rebuild.SourceArchive `json:"source_archive,omitempty" yaml:"source_archive,omitempty"`
 }
 
 var _ rebuild.Strategy = &NPMPackBuild{}
 
+// ScriptPolicy describes the lifecycle scripts permitted to run in the build.
+func (b *NPMPackBuild) ScriptPolicy() string {
+	return b.LifecycleScripts.String()
+}
+
 // GenerateFor generates the instructions for a NPMPackBuild.
 func (b *NPMPackBuild) GenerateFor(t rebuild.Target, be rebuild.BuildEnv) (rebuild.Instructions, error) {
-	src, err := rebuild.BasicSourceSetup(b.Location, &be)
+	if err := b.LifecycleScripts.validate(); err != nil {
+		return rebuild.Instructions{}, err
+	}
+	src, err := rebuild.SourceSetup(b.Location, b.SourceArchive, &be)
 	if err != nil {
 		return rebuild.Instructions{}, err
 	}
@@ -43,18 +135,26 @@ func (b *NPMPackBuild) GenerateFor(t rebuild.Target, be rebuild.BuildEnv) (rebui
 {{- /* NOTE: Use builtin npm for 'npm version' as it wasn't introduced until NPM v6. */ -}}
 PATH=/usr/bin:/bin:/usr/local/bin /usr/bin/npm version --prefix {{.Location.Dir}} --no-git-tag-version {{.VersionOverride}}
 {{end -}}
-/usr/bin/npx --package=npm@{{.NPMVersion}} -c '{{if ne .Location.Dir "."}}cd {{.Location.Dir}} && {{end}}npm pack'
-`, b)
+/usr/bin/npx --package=npm@{{.NPMVersion}} -c '{{if ne .Location.Dir "."}}cd {{.Location.Dir}} && {{end}}{{.Pack}}'
+`, struct {
+		*NPMPackBuild
+		Pack string
+	}{b, b.LifecycleScripts.pack()})
 	if err != nil {
 		return rebuild.Instructions{}, err
 	}
+	outputPath := path.Join(b.Location.Dir, t.Artifact)
+	if b.RecompressGzip {
+		build += "\n" + recompressGzip(outputPath)
+	}
 	return rebuild.Instructions{
-		Location:   b.Location,
-		SystemDeps: []string{"git", "npm"},
-		Source:     src,
-		Deps:       deps,
-		Build:      build,
-		OutputPath: path.Join(b.Location.Dir, t.Artifact),
+		Location:      b.Location,
+		SystemDeps:    []string{"git", "npm"},
+		Source:        src,
+		Deps:          deps,
+		Build:         build,
+		OutputPath:    outputPath,
+		SourceArchive: b.SourceArchive,
 	}, nil
 }
 
@@ -66,12 +166,31 @@ type NPMCustomBuild struct {
 	VersionOverride string    `json:"version_override,omitempty" yaml:"version_override,omitempty"`
 	Command         string    `json:"command" yaml:"command"`
 	RegistryTime    time.Time `json:"registry_time" yaml:"registry_time"`
+	RecompressGzip  bool      `json:"recompress_gzip,omitempty" yaml:"recompress_gzip,omitempty"`
+	// LifecycleScripts controls the lifecycle scripts run when installing and packing.
+	LifecycleScripts *ScriptPolicy `json:"lifecycle_scripts,omitempty" yaml:"lifecycle_scripts,omitempty"`
 }
 
 var _ rebuild.Strategy = &NPMCustomBuild{}
+var _ rebuild.ToolchainVariant = &NPMCustomBuild{}
+
+// WithToolchain returns a copy of the build using the given node version.
+func (b *NPMCustomBuild) WithToolchain(version string) rebuild.Strategy {
+	c := *b
+	c.NodeVersion = version
+	return &c
+}
+
+// ScriptPolicy describes the lifecycle scripts permitted to run in the build.
+func (b *NPMCustomBuild) ScriptPolicy() string {
+	return b.LifecycleScripts.String()
+}
 
 // GenerateFor generates the instructions for a NPMCustomBuild.
 func (b *NPMCustomBuild) GenerateFor(t rebuild.Target, be rebuild.BuildEnv) (rebuild.Instructions, error) {
+	if err := b.LifecycleScripts.validate(); err != nil {
+		return rebuild.Instructions{}, err
+	}
 	src, err := rebuild.BasicSourceSetup(b.Location, &be)
 	if err != nil {
 		return rebuild.Instructions{}, err
@@ -79,15 +198,19 @@ func (b *NPMCustomBuild) GenerateFor(t rebuild.Target, be rebuild.BuildEnv) (reb
 	buildAndEnv := struct {
 		*NPMCustomBuild
 		BuildEnv *rebuild.BuildEnv
+		Flags    string
+		Pack     string
 	}{
 		NPMCustomBuild: b,
 		BuildEnv:       &be,
+		Flags:          b.LifecycleScripts.flags(),
+		Pack:           b.LifecycleScripts.pack(),
 	}
 	deps, err := rebuild.PopulateTemplate(`
 /usr/bin/npm config --location-global set registry {{.BuildEnv.TimewarpURL "npm" .RegistryTime}}
 trap '/usr/bin/npm config --location-global delete registry' EXIT
 wget -O - https://unofficial-builds.nodejs.org/download/release/v{{.NodeVersion}}/node-v{{.NodeVersion}}-linux-x64-musl.tar.gz | tar xzf - --strip-components=1 -C /usr/local/
-/usr/local/bin/npx --package=npm@{{.NPMVersion}} -c '{{if ne .Location.Dir "."}}cd {{.Location.Dir}} && {{end}}npm install --force'
+/usr/local/bin/npx --package=npm@{{.NPMVersion}} -c '{{if ne .Location.Dir "."}}cd {{.Location.Dir}} && {{end}}npm install --force{{.Flags}}'
 `, buildAndEnv)
 	if err != nil {
 		return rebuild.Instructions{}, err
@@ -97,8 +220,16 @@ wget -O - https://unofficial-builds.nodejs.org/download/release/v{{.NodeVersion}
 {{- /* NOTE: Use builtin npm for 'npm version' as it wasn't introduced until NPM v6. */ -}}
 PATH=/usr/bin:/bin:/usr/local/bin /usr/bin/npm version --prefix {{.Location.Dir}} --no-git-tag-version {{.VersionOverride}}
 {{end -}}
-/usr/local/bin/npx --package=npm@{{.NPMVersion}} -c '{{if ne .Location.Dir "."}}cd {{.Location.Dir}} && {{end}}npm run {{.Command}}' && rm -rf node_modules && npm pack
-`, b)
+/usr/local/bin/npx --package=npm@{{.NPMVersion}} -c '{{if ne .Location.Dir "."}}cd {{.Location.Dir}} && {{end}}npm run {{.Command}}{{.Flags}}' && rm -rf node_modules && {{.Pack}}
+`, buildAndEnv)
+	if err != nil {
+		return rebuild.Instructions{}, err
+	}
+	outputPath := path.Join(b.Location.Dir, t.Artifact)
+	if b.RecompressGzip {
+		build += "\n" + recompressGzip(outputPath)
+	}
+	closure, err := rebuild.PopulateTemplate(`cat {{.Location.Dir}}/package-lock.json`, b)
 	if err != nil {
 		return rebuild.Instructions{}, err
 	}
@@ -108,6 +239,7 @@ PATH=/usr/bin:/bin:/usr/local/bin /usr/bin/npm version --prefix {{.Location.Dir}
 		Source:     src,
 		Deps:       deps,
 		Build:      build,
-		OutputPath: path.Join(b.Location.Dir, t.Artifact),
+		OutputPath: outputPath,
+		Closure:    closure,
 	}, nil
 }
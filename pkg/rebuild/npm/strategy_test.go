@@ -68,6 +68,23 @@ func TestNPMCustomBuild(t *testing.T) {
 				OutputPath: "the_dir/the_artifact",
 			},
 		},
+		{
+			"PackBuildRecompressGzip",
+			&NPMPackBuild{
+				Location:       defaultLocation,
+				NPMVersion:     "red",
+				RecompressGzip: true,
+			},
+			rebuild.Instructions{
+				Location:   defaultLocation,
+				SystemDeps: []string{"git", "npm"},
+				Source:     "git checkout --force 'the_ref'",
+				Deps:       "",
+				Build: `/usr/bin/npx --package=npm@red -c 'cd the_dir && npm pack'
+/usr/bin/node -e 'const fs = require("fs"), zlib = require("zlib");const f = process.argv[1], b = fs.readFileSync(f);console.log("original gzip header: " + b.subarray(0, 10).toString("hex"));fs.writeFileSync(f, zlib.gzipSync(zlib.gunzipSync(b), {level: 9}));' the_dir/the_artifact`,
+				OutputPath: "the_dir/the_artifact",
+			},
+		},
 		{
 			"PackBuildNoDir",
 			&NPMPackBuild{
@@ -92,6 +109,22 @@ func TestNPMCustomBuild(t *testing.T) {
 				OutputPath: "the_artifact",
 			},
 		},
+		{
+			"PackBuildAllowScripts",
+			&NPMPackBuild{
+				Location:         defaultLocation,
+				NPMVersion:       "red",
+				LifecycleScripts: &ScriptPolicy{Ignore: true, Allow: []string{"prepare", "prepublishOnly"}},
+			},
+			rebuild.Instructions{
+				Location:   defaultLocation,
+				SystemDeps: []string{"git", "npm"},
+				Source:     "git checkout --force 'the_ref'",
+				Deps:       "",
+				Build:      `/usr/bin/npx --package=npm@red -c 'cd the_dir && npm run --if-present prepare && npm run --if-present prepublishOnly && npm pack --ignore-scripts'`,
+				OutputPath: "the_dir/the_artifact",
+			},
+		},
 		{
 			"CustomBuildVersionOverride",
 			&NPMCustomBuild{
@@ -113,6 +146,7 @@ wget -O - https://unofficial-builds.nodejs.org/download/release/vblue/node-vblue
 				Build: `PATH=/usr/bin:/bin:/usr/local/bin /usr/bin/npm version --prefix the_dir --no-git-tag-version green
 /usr/local/bin/npx --package=npm@red -c 'cd the_dir && npm run yellow' && rm -rf node_modules && npm pack`,
 				OutputPath: "the_dir/the_artifact",
+				Closure:    "cat the_dir/package-lock.json",
 			},
 		},
 		{
@@ -135,6 +169,30 @@ wget -O - https://unofficial-builds.nodejs.org/download/release/vblue/node-vblue
 /usr/local/bin/npx --package=npm@red -c 'cd the_dir && npm install --force'`,
 				Build:      `/usr/local/bin/npx --package=npm@red -c 'cd the_dir && npm run yellow' && rm -rf node_modules && npm pack`,
 				OutputPath: "the_dir/the_artifact",
+				Closure:    "cat the_dir/package-lock.json",
+			},
+		},
+		{
+			"CustomBuildIgnoreScripts",
+			&NPMCustomBuild{
+				Location:         defaultLocation,
+				NPMVersion:       "red",
+				NodeVersion:      "blue",
+				Command:          "yellow",
+				RegistryTime:     time.Date(2006, time.January, 2, 3, 4, 5, 0, time.UTC),
+				LifecycleScripts: &ScriptPolicy{Ignore: true},
+			},
+			rebuild.Instructions{
+				Location:   defaultLocation,
+				SystemDeps: []string{"git", "npm"},
+				Source:     "git checkout --force 'the_ref'",
+				Deps: `/usr/bin/npm config --location-global set registry http://npm:2006-01-02T03:04:05Z@orange
+trap '/usr/bin/npm config --location-global delete registry' EXIT
+wget -O - https://unofficial-builds.nodejs.org/download/release/vblue/node-vblue-linux-x64-musl.tar.gz | tar xzf - --strip-components=1 -C /usr/local/
+/usr/local/bin/npx --package=npm@red -c 'cd the_dir && npm install --force --ignore-scripts'`,
+				Build:      `/usr/local/bin/npx --package=npm@red -c 'cd the_dir && npm run yellow --ignore-scripts' && rm -rf node_modules && npm pack --ignore-scripts`,
+				OutputPath: "the_dir/the_artifact",
+				Closure:    "cat the_dir/package-lock.json",
 			},
 		},
 		{
@@ -165,6 +223,7 @@ wget -O - https://unofficial-builds.nodejs.org/download/release/vblue/node-vblue
 /usr/local/bin/npx --package=npm@red -c 'npm install --force'`,
 				Build:      `/usr/local/bin/npx --package=npm@red -c 'npm run yellow' && rm -rf node_modules && npm pack`,
 				OutputPath: "the_artifact",
+				Closure:    "cat ./package-lock.json",
 			},
 		},
 	}
@@ -177,10 +236,37 @@ wget -O - https://unofficial-builds.nodejs.org/download/release/vblue/node-vblue
 			if diff := cmp.Diff(inst, tc.want); diff != "" {
 				t.Errorf("Strategy%v.GenerateFor() returned diff (-got +want):\n%s", tc.strategy, diff)
 			}
+			if err := rebuild.Lint(inst, "", rebuild.LintOptions{}).Err(); err != nil {
+				t.Errorf("Lint(Strategy%v.GenerateFor()) = %v", tc.strategy, err)
+			}
 		})
 	}
 }
 
+func TestScriptPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		policy  *ScriptPolicy
+		want    string
+		wantErr bool
+	}{
+		{policy: nil, want: "all"},
+		{policy: &ScriptPolicy{}, want: "all"},
+		{policy: &ScriptPolicy{Ignore: true}, want: "none"},
+		{policy: &ScriptPolicy{Ignore: true, Allow: []string{"prepare", "prepublishOnly"}}, want: "prepare,prepublishOnly"},
+		{policy: &ScriptPolicy{Allow: []string{"prepare"}}, wantErr: true},
+		{policy: &ScriptPolicy{Ignore: true, Allow: []string{"prepare; curl evil"}}, wantErr: true},
+	} {
+		b := &NPMPackBuild{Location: rebuild.Location{Dir: "."}, NPMVersion: "red", LifecycleScripts: tc.policy}
+		_, err := b.GenerateFor(rebuild.Target{Ecosystem: rebuild.NPM, Artifact: "the_artifact"}, rebuild.BuildEnv{HasRepo: true})
+		if (err != nil) != tc.wantErr {
+			t.Errorf("GenerateFor(%+v) error = %v, wantErr %t", tc.policy, err, tc.wantErr)
+		}
+		if got := b.ScriptPolicy(); !tc.wantErr && got != tc.want {
+			t.Errorf("ScriptPolicy(%+v) = %s, want %s", tc.policy, got, tc.want)
+		}
+	}
+}
+
 func TestNPMPackBuildYAML(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -251,3 +337,15 @@ npm_version: 8.19.3
 		})
 	}
 }
+
+func TestNPMCustomBuildWithToolchain(t *testing.T) {
+	orig := &NPMCustomBuild{NPMVersion: "8.19.4", NodeVersion: "16.20.2", Command: "build"}
+	got := orig.WithToolchain("20.11.1")
+	want := &NPMCustomBuild{NPMVersion: "8.19.4", NodeVersion: "20.11.1", Command: "build"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("WithToolchain() mismatch (-want +got):\n%s", diff)
+	}
+	if orig.NodeVersion != "16.20.2" {
+		t.Errorf("WithToolchain() modified the original strategy: NodeVersion = %s", orig.NodeVersion)
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package npm
+
+import (
+	"time"
+
+	"github.com/google/oss-rebuild/internal/semver"
+	npmreg "github.com/google/oss-rebuild/pkg/registry/npm"
+	"github.com/pkg/errors"
+)
+
+// engineRange parses an engines constraint.
+//
+// NOTE: npm ignores engines constraints it cannot parse (e.g. "node >= 0.8")
+// so they are treated as unconstrained.
+func engineRange(engines string) semver.Range {
+	if rng, err := semver.ParseRange(engines); err == nil {
+		return rng
+	}
+	rng, _ := semver.ParseRange("*")
+	return rng
+}
+
+// PickNodeVersion returns the node version with which to build a package published at t.
+//
+// The newest release available at t that satisfies the package's engines
+// constraint is selected, preferring LTS releases.
+func PickNodeVersion(rels *npmreg.ToolchainReleases, engines string, t time.Time) (string, error) {
+	rng := engineRange(engines)
+	var best, bestLTS string
+	for _, r := range rels.Node {
+		if r.Date.After(t) || !rng.Contains(r.Version) {
+			continue
+		}
+		if best == "" || semver.Cmp(r.Version, best) > 0 {
+			best = r.Version
+		}
+		if r.LTS && (bestLTS == "" || semver.Cmp(r.Version, bestLTS) > 0) {
+			bestLTS = r.Version
+		}
+	}
+	switch {
+	case bestLTS != "":
+		return bestLTS, nil
+	case best != "":
+		return best, nil
+	default:
+		return "", errors.Errorf("no node release satisfying %q available at %s", engines, t.Format(time.DateOnly))
+	}
+}
+
+// PickNPMVersion returns the npm CLI version with which to build a package published at t.
+//
+// The release tagged latest at t is selected unless it fails to satisfy the
+// package's engines constraint, in which case the newest satisfying release
+// tagged by t is used.
+func PickNPMVersion(rels *npmreg.ToolchainReleases, engines string, t time.Time) (string, error) {
+	rng := engineRange(engines)
+	latest, ok := npmreg.DistTagAt(rels.NPM, "latest", t)
+	if !ok {
+		return "", errors.Errorf("no npm release available at %s", t.Format(time.DateOnly))
+	}
+	if rng.Contains(latest) {
+		return latest, nil
+	}
+	var best string
+	for _, e := range rels.NPM {
+		if e.Time.After(t) || !rng.Contains(e.Version) {
+			continue
+		}
+		if best == "" || semver.Cmp(e.Version, best) > 0 {
+			best = e.Version
+		}
+	}
+	if best == "" {
+		return "", errors.Errorf("no npm release satisfying %q available at %s", engines, t.Format(time.DateOnly))
+	}
+	return best, nil
+}
@@ -0,0 +1,103 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package npm
+
+import (
+	"testing"
+	"time"
+
+	npmreg "github.com/google/oss-rebuild/pkg/registry/npm"
+)
+
+func day(s string) time.Time {
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+var testReleases = &npmreg.ToolchainReleases{
+	Node: []npmreg.NodeRelease{
+		{Version: "14.15.0", Date: day("2020-10-27"), NPM: "6.14.8", LTS: true},
+		{Version: "16.0.0", Date: day("2021-04-20"), NPM: "7.10.0"},
+		{Version: "16.13.0", Date: day("2021-10-26"), NPM: "8.1.0", LTS: true},
+		{Version: "17.0.0", Date: day("2021-10-19"), NPM: "8.1.0"},
+		{Version: "18.0.0", Date: day("2022-04-19"), NPM: "8.6.0"},
+	},
+	NPM: []npmreg.DistTagEvent{
+		{Tag: "latest", Version: "6.14.8", Time: day("2020-08-18")},
+		{Tag: "latest", Version: "7.0.0", Time: day("2020-10-13")},
+		{Tag: "latest", Version: "8.1.0", Time: day("2021-10-14")},
+	},
+}
+
+func TestPickNodeVersion(t *testing.T) {
+	testCases := []struct {
+		name    string
+		engines string
+		at      time.Time
+		want    string
+		wantErr bool
+	}{
+		{name: "PreferLTS", at: day("2021-12-01"), want: "16.13.0"},
+		{name: "NoLTSInRange", engines: ">=17", at: day("2022-06-01"), want: "18.0.0"},
+		{name: "UpperBound", engines: "^14.15.0", at: day("2022-06-01"), want: "14.15.0"},
+		{name: "NotYetReleased", at: day("2021-05-01"), want: "14.15.0"},
+		{name: "OnlyNonLTSAvailable", engines: ">=16", at: day("2021-05-01"), want: "16.0.0"},
+		{name: "EmptyIntersection", engines: ">=18", at: day("2021-12-01"), wantErr: true},
+		{name: "BeforeAllReleases", at: day("2019-01-01"), wantErr: true},
+		{name: "InvalidRange", engines: "node >= 0.4", at: day("2021-12-01"), want: "16.13.0"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := PickNodeVersion(testReleases, tc.engines, tc.at)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("PickNodeVersion() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("PickNodeVersion() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPickNPMVersion(t *testing.T) {
+	testCases := []struct {
+		name    string
+		engines string
+		at      time.Time
+		want    string
+		wantErr bool
+	}{
+		{name: "Latest", at: day("2021-01-01"), want: "7.0.0"},
+		{name: "LatestSatisfiesEngines", engines: ">=7", at: day("2021-12-01"), want: "8.1.0"},
+		{name: "EnginesExcludeLatest", engines: "<7", at: day("2021-12-01"), want: "6.14.8"},
+		{name: "EmptyIntersection", engines: ">=8", at: day("2021-01-01"), wantErr: true},
+		{name: "BeforeAllReleases", at: day("2020-01-01"), wantErr: true},
+		{name: "InvalidRange", engines: "npm >= 1.0", at: day("2021-01-01"), want: "7.0.0"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := PickNPMVersion(testReleases, tc.engines, tc.at)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("PickNPMVersion() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("PickNPMVersion() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
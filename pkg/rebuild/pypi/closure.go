@@ -0,0 +1,68 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pypi
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/pkg/errors"
+)
+
+// directURL is the PEP 610 record of the location from which a distribution was installed.
+type directURL struct {
+	URL         string `json:"url"`
+	ArchiveInfo *struct {
+		// Hash is the legacy "<alg>=<hex>" form of Hashes.
+		Hash   string            `json:"hash"`
+		Hashes map[string]string `json:"hashes"`
+	} `json:"archive_info"`
+}
+
+// inspectReport is the subset of the `pip inspect` report describing installed distributions.
+type inspectReport struct {
+	Installed []struct {
+		Metadata struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"metadata"`
+		DownloadInfo *directURL `json:"download_info"`
+	} `json:"installed"`
+}
+
+// ParseClosure parses the dependency closure from the `pip inspect` report
+// of the build's environment.
+func ParseClosure(b []byte) ([]rebuild.Dependency, error) {
+	var report inspectReport
+	if err := json.Unmarshal(b, &report); err != nil {
+		return nil, errors.Wrap(err, "parsing pip inspect report")
+	}
+	var deps []rebuild.Dependency
+	for _, dist := range report.Installed {
+		d := rebuild.Dependency{Name: dist.Metadata.Name, Version: dist.Metadata.Version}
+		if di := dist.DownloadInfo; di != nil {
+			d.URI = di.URL
+			if ai := di.ArchiveInfo; ai != nil {
+				d.Digest = ai.Hashes
+				if alg, hex, ok := strings.Cut(ai.Hash, "="); ok && len(d.Digest) == 0 {
+					d.Digest = map[string]string{alg: hex}
+				}
+			}
+		}
+		deps = append(deps, d)
+	}
+	return rebuild.NormalizeDependencies(deps), nil
+}
@@ -0,0 +1,53 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pypi
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+)
+
+func TestParseClosure(t *testing.T) {
+	report := `{
+		"version": "1",
+		"installed": [
+			{
+				"metadata": {"name": "wheel", "version": "0.43.0"},
+				"download_info": {"url": "https://files.pythonhosted.org/packages/wheel-0.43.0-py3-none-any.whl", "archive_info": {"hash": "sha256=abcd", "hashes": {"sha256": "abcd"}}}
+			},
+			{
+				"metadata": {"name": "build", "version": "1.2.1"},
+				"download_info": {"url": "https://files.pythonhosted.org/packages/build-1.2.1-py3-none-any.whl", "archive_info": {"hash": "sha256=1234"}}
+			},
+			{
+				"metadata": {"name": "pip", "version": "24.0"}
+			}
+		]
+	}`
+	got, err := ParseClosure([]byte(report))
+	if err != nil {
+		t.Fatalf("ParseClosure() error = %v", err)
+	}
+	want := []rebuild.Dependency{
+		{Name: "build", Version: "1.2.1", URI: "https://files.pythonhosted.org/packages/build-1.2.1-py3-none-any.whl", Digest: map[string]string{"sha256": "1234"}},
+		{Name: "pip", Version: "24.0"},
+		{Name: "wheel", Version: "0.43.0", URI: "https://files.pythonhosted.org/packages/wheel-0.43.0-py3-none-any.whl", Digest: map[string]string{"sha256": "abcd"}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ParseClosure() mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -83,11 +83,13 @@ func (Rebuilder) InferRepo(ctx context.Context, t rebuild.Target, mux rebuild.Re
 	// 1. link name is common source link name and it points to a known repo host
 	// 1.a prefer "Homepage" if it's a common repo host.
 	if repo := uri.FindCommonRepo(project.Homepage); repo != "" {
+		rebuild.RecordProvenance(ctx, "repo", "homepage")
 		return uri.CanonicalizeRepoURI(repo)
 	}
 	for name, url := range project.ProjectURLs {
 		if strings.ReplaceAll(strings.ToLower(name), " ", "") == "homepage" {
 			if repo := uri.FindCommonRepo(url); repo != "" {
+				rebuild.RecordProvenance(ctx, "repo", "homepage")
 				return uri.CanonicalizeRepoURI(repo)
 			}
 		}
@@ -95,17 +97,20 @@ func (Rebuilder) InferRepo(ctx context.Context, t rebuild.Target, mux rebuild.Re
 	// 1.b use other source links.
 	for _, url := range linksNamedSource {
 		if repo := uri.FindCommonRepo(url); repo != "" {
+			rebuild.RecordProvenance(ctx, "repo", "source_link")
 			return uri.CanonicalizeRepoURI(repo)
 		}
 	}
 	// 2. link name is common source link name but it doesn't point to a known repo host
 	if len(linksNamedSource) != 0 {
+		rebuild.RecordProvenance(ctx, "repo", "source_link")
 		return uri.CanonicalizeRepoURI(linksNamedSource[0])
 	}
 	// 3. first known repo host link found in the description
 	r := uri.FindCommonRepo(project.Description)
 	// TODO: Maybe revisit this sponsors logic?
 	if r != "" && !strings.Contains(r, "sponsors") {
+		rebuild.RecordProvenance(ctx, "repo", "description")
 		return uri.CanonicalizeRepoURI(r)
 	}
 	// 4. link name is not a common source link name, but points to known repo repo host
@@ -114,6 +119,7 @@ func (Rebuilder) InferRepo(ctx context.Context, t rebuild.Target, mux rebuild.Re
 			continue
 		}
 		if repo := uri.FindCommonRepo(url); repo != "" {
+			rebuild.RecordProvenance(ctx, "repo", "project_link")
 			return uri.CanonicalizeRepoURI(repo)
 		}
 	}
@@ -167,6 +173,26 @@ func extractPyProjectRequirements(ctx context.Context, tree *object.Tree) ([]str
 	return reqs, nil
 }
 
+// pythonVersionRE matches the CPython release versions that can be installed.
+// Other implementations and aliases (e.g. "pypy3.10", "system") are unsupported.
+var pythonVersionRE = re.MustCompile(`^(?:cpython-|python)?(3\.\d+(?:\.\d+)?)$`)
+
+// getPythonVersionPin returns the python version pinned by a .python-version
+// file applicable to dir.
+func getPythonVersionPin(tree *object.Tree, dir string) (pin, version string, err error) {
+	pin, content, err := rebuild.FindPinFile(tree, dir, ".python-version")
+	if err != nil {
+		return "", "", err
+	}
+	// NOTE: pyenv allows multiple versions, one per line, with the first preferred.
+	first, _, _ := strings.Cut(strings.TrimSpace(content), "\n")
+	m := pythonVersionRE.FindStringSubmatch(strings.TrimSpace(first))
+	if m == nil {
+		return "", "", errors.Errorf("unsupported %s version: %q", pin, strings.TrimSpace(first))
+	}
+	return pin, m[1], nil
+}
+
 func findGitRef(pkg string, version string, rcfg *rebuild.RepoConfig) (string, error) {
 	tagHeuristic, err := rebuild.FindTagMatch(pkg, version, rcfg.Repository)
 	log.Printf("Version: %s, tag hash: \"%s\"", version, tagHeuristic)
@@ -175,7 +201,7 @@ func findGitRef(pkg string, version string, rcfg *rebuild.RepoConfig) (string, e
 	}
 	// TODO: Look for the project.toml and check for version number.
 	if tagHeuristic == "" {
-		return "", errors.New("no git ref")
+		return "", rebuild.ErrNoRef
 	}
 	_, err = rcfg.Repository.CommitObject(plumbing.NewHash(tagHeuristic))
 	if err != nil {
@@ -249,10 +275,13 @@ func (Rebuilder) InferStrategy(ctx context.Context, t rebuild.Target, mux rebuil
 	}
 	if lh != nil && lh.Ref != "" {
 		ref = lh.Ref
+		rebuild.RecordProvenance(ctx, "ref", rebuild.HeuristicHint)
 		if lh.Dir != "" {
 			dir = lh.Dir
+			rebuild.RecordProvenance(ctx, "dir", rebuild.HeuristicHint)
 		} else {
 			dir = rcfg.Dir
+			rebuild.RecordProvenance(ctx, "dir", rebuild.HeuristicDefault)
 		}
 	} else {
 		ref, err = findGitRef(release.Name, version, rcfg)
@@ -260,6 +289,8 @@ func (Rebuilder) InferStrategy(ctx context.Context, t rebuild.Target, mux rebuil
 			return cfg, err
 		}
 		dir = rcfg.Dir
+		rebuild.RecordProvenance(ctx, "ref", rebuild.HeuristicTag)
+		rebuild.RecordProvenance(ctx, "dir", rebuild.HeuristicDefault)
 	}
 	a, err := FindPureWheel(release.Artifacts)
 	if err != nil {
@@ -282,6 +313,8 @@ func (Rebuilder) InferStrategy(ctx context.Context, t rebuild.Target, mux rebuil
 	if err != nil {
 		return cfg, err
 	}
+	rebuild.RecordProvenance(ctx, "requirements", "wheel_metadata")
+	var pythonVersion string
 	// Extract pyproject.toml requirements.
 	{
 		commit, err := rcfg.Repository.CommitObject(plumbing.NewHash(ref))
@@ -305,9 +338,16 @@ func (Rebuilder) InferStrategy(ctx context.Context, t rebuild.Target, mux rebuil
 			for _, newReq := range pyprojReqs {
 				if pkg := pkgname(newReq); !existing[pkg] {
 					reqs = append(reqs, newReq)
+					rebuild.RecordProvenance(ctx, "requirements", "wheel_metadata+pyproject")
 				}
 			}
 		}
+		if pin, v, err := getPythonVersionPin(tree, dir); err == nil {
+			pythonVersion = v
+			rebuild.RecordProvenance(ctx, "python_version", pin)
+		} else if err != object.ErrFileNotFound {
+			log.Println("ignoring python version pin:", err.Error())
+		}
 	}
 	return &PureWheelBuild{
 		Location: rebuild.Location{
@@ -315,7 +355,60 @@ func (Rebuilder) InferStrategy(ctx context.Context, t rebuild.Target, mux rebuil
 			Dir:  dir,
 			Ref:  ref,
 		},
-		Requirements: reqs,
+		Requirements:  reqs,
+		PythonVersion: pythonVersion,
+	}, nil
+}
+
+var _ rebuild.SourceArchiveInferer = Rebuilder{}
+
+// findSdist returns the source distribution from the given version's releases.
+func findSdist(artifacts []pypireg.Artifact) (*pypireg.Artifact, error) {
+	for _, a := range artifacts {
+		if a.PackageType == "sdist" && strings.HasSuffix(a.Filename, ".tar.gz") {
+			return &a, nil
+		}
+	}
+	return nil, errors.New("no sdist found")
+}
+
+// InferSourceArchiveStrategy infers a strategy that builds the wheel from the published sdist.
+func (Rebuilder) InferSourceArchiveStrategy(ctx context.Context, t rebuild.Target, mux rebuild.RegistryMux) (rebuild.Strategy, error) {
+	release, err := mux.PyPI.Release(ctx, t.Package, t.Version)
+	if err != nil {
+		return nil, err
+	}
+	sdist, err := findSdist(release.Artifacts)
+	if err != nil {
+		return nil, err
+	}
+	a, err := FindPureWheel(release.Artifacts)
+	if err != nil {
+		return nil, errors.Wrap(err, "finding pure wheel")
+	}
+	r, err := mux.PyPI.Artifact(ctx, t.Package, t.Version, a.Filename)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrapf(err, "[INTERNAL] Failed to read upstream artifact")
+	}
+	zr, err := zip.NewReader(bytes.NewReader(body), a.Size)
+	if err != nil {
+		return nil, errors.Wrapf(err, "[INTERNAL] Failed to initialize upstream zip reader")
+	}
+	reqs, err := inferRequirements(release.Name, t.Version, zr)
+	if err != nil {
+		return nil, err
+	}
+	rebuild.RecordProvenance(ctx, "requirements", "wheel_metadata")
+	rebuild.RecordProvenance(ctx, "source_archive", rebuild.HeuristicRegistry)
+	return &PureWheelBuild{
+		Location:      rebuild.Location{Dir: "."},
+		Requirements:  reqs,
+		SourceArchive: &rebuild.SourceArchive{URL: sdist.URL, SHA256: sdist.SHA256},
 	}, nil
 }
 
@@ -33,9 +33,11 @@ func (Rebuilder) Rebuild(ctx context.Context, t rebuild.Target, inst rebuild.Ins
 	if _, err := rebuild.ExecuteScript(ctx, projectfs.Root(), inst.Source); err != nil {
 		return errors.Wrap(err, "fetching source")
 	}
+	rebuild.LogPhase(rebuild.PhaseDeps)
 	if _, err := rebuild.ExecuteScript(ctx, projectfs.Root(), inst.Deps); err != nil {
 		return errors.Wrap(err, "configuring build deps")
 	}
+	rebuild.LogPhase(rebuild.PhaseBuild)
 	if _, err := rebuild.ExecuteScript(ctx, projectfs.Root(), inst.Build); err != nil {
 		return errors.Wrap(err, "executing build")
 	}
@@ -26,25 +26,55 @@ type PureWheelBuild struct {
 	rebuild.Location
 	Requirements []string  `json:"requirements"`
 	RegistryTime time.Time `json:"registry_time" yaml:"registry_time,omitempty"`
+	// PythonVersion is the CPython version with which to build. If empty, the
+	// system python3 is used.
+	PythonVersion string `json:"python_version,omitempty" yaml:"python_version,omitempty"`
+	// SourceArchive, if provided, is the published sdist from which to build in place of Location.
+	SourceArchive *rebuild.SourceArchive `json:"source_archive,omitempty" yaml:"source_archive,omitempty"`
 }
 
 var _ rebuild.Strategy = &PureWheelBuild{}
+var _ rebuild.ToolchainVariant = &PureWheelBuild{}
+
+// WithToolchain returns a copy of the build using the given python version.
+func (b *PureWheelBuild) WithToolchain(version string) rebuild.Strategy {
+	c := *b
+	c.PythonVersion = version
+	return &c
+}
+
+// uvVersion is the version of uv used to provision interpreters.
+//
+// NOTE: Each uv release resolves a requested python version to a fixed
+// interpreter build so pinning uv pins the interpreter.
+const uvVersion = "0.4.30"
 
 // GenerateFor generates the instructions for a PureWheelBuild.
 func (b *PureWheelBuild) GenerateFor(t rebuild.Target, be rebuild.BuildEnv) (rebuild.Instructions, error) {
-	src, err := rebuild.BasicSourceSetup(b.Location, &be)
+	src, err := rebuild.SourceSetup(b.Location, b.SourceArchive, &be)
 	if err != nil {
 		return rebuild.Instructions{}, err
 	}
 	buildAndEnv := struct {
 		*PureWheelBuild
-		BuildEnv *rebuild.BuildEnv
+		BuildEnv  *rebuild.BuildEnv
+		UVVersion string
 	}{
 		PureWheelBuild: b,
 		BuildEnv:       &be,
+		UVVersion:      uvVersion,
 	}
 	deps, err := rebuild.PopulateTemplate(`
+{{if .PythonVersion -}}
+{{- /* NOTE: uv is installed prior to timewarp as it provides the requested interpreter. */ -}}
+/usr/bin/python3 -m venv /uv
+/uv/bin/pip install uv=={{.UVVersion}}
+/uv/bin/uv venv --python-preference only-managed --python {{.PythonVersion}} /deps
+{{- /* NOTE: pip is seeded from the interpreter's bundled copy rather than the live index. */}}
+/deps/bin/python3 -m ensurepip
+{{else -}}
 /usr/bin/python3 -m venv /deps
+{{end -}}
 {{if not .RegistryTime.IsZero -}}
 export PIP_INDEX_URL={{.BuildEnv.TimewarpURL "pypi" .RegistryTime}}
 {{end -}}
@@ -61,11 +91,13 @@ export PIP_INDEX_URL={{.BuildEnv.TimewarpURL "pypi" .RegistryTime}}
 		return rebuild.Instructions{}, err
 	}
 	return rebuild.Instructions{
-		Location:   b.Location,
-		Source:     src,
-		Deps:       deps,
-		Build:      build,
-		SystemDeps: []string{"git", "python3"},
-		OutputPath: path.Join("dist", t.Artifact),
+		Location:      b.Location,
+		Source:        src,
+		Deps:          deps,
+		Build:         build,
+		SystemDeps:    []string{"git", "python3"},
+		OutputPath:    path.Join("dist", t.Artifact),
+		SourceArchive: b.SourceArchive,
+		Closure:       "/deps/bin/pip inspect",
 	}, nil
 }
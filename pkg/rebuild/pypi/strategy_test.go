@@ -50,6 +50,7 @@ func TestPureWheelBuild(t *testing.T) {
 				Build:      "/deps/bin/python3 -m build --wheel -n the_dir",
 				SystemDeps: []string{"git", "python3"},
 				OutputPath: "dist/the_artifact",
+				Closure:    "/deps/bin/pip inspect",
 			},
 		},
 		{
@@ -66,6 +67,7 @@ func TestPureWheelBuild(t *testing.T) {
 				Build:      "/deps/bin/python3 -m build --wheel -n the_dir",
 				SystemDeps: []string{"git", "python3"},
 				OutputPath: "dist/the_artifact",
+				Closure:    "/deps/bin/pip inspect",
 			},
 		},
 		{
@@ -84,6 +86,30 @@ export PIP_INDEX_URL=http://pypi:2006-01-02T03:04:05Z@orange
 				Build:      "/deps/bin/python3 -m build --wheel -n the_dir",
 				SystemDeps: []string{"git", "python3"},
 				OutputPath: "dist/the_artifact",
+				Closure:    "/deps/bin/pip inspect",
+			},
+		},
+		{
+			"WithPythonVersion",
+			&PureWheelBuild{
+				Location:      defaultLocation,
+				RegistryTime:  time.Date(2006, time.January, 2, 3, 4, 5, 0, time.UTC),
+				PythonVersion: "3.11",
+			},
+			rebuild.Instructions{
+				Location: defaultLocation,
+				Source:   "git checkout --force 'the_ref'",
+				Deps: `/usr/bin/python3 -m venv /uv
+/uv/bin/pip install uv==0.4.30
+/uv/bin/uv venv --python-preference only-managed --python 3.11 /deps
+/deps/bin/python3 -m ensurepip
+export PIP_INDEX_URL=http://pypi:2006-01-02T03:04:05Z@orange
+/deps/bin/pip install build
+`,
+				Build:      "/deps/bin/python3 -m build --wheel -n the_dir",
+				SystemDeps: []string{"git", "python3"},
+				OutputPath: "dist/the_artifact",
+				Closure:    "/deps/bin/pip inspect",
 			},
 		},
 	}
@@ -96,6 +122,9 @@ export PIP_INDEX_URL=http://pypi:2006-01-02T03:04:05Z@orange
 			if diff := cmp.Diff(inst, tc.want); diff != "" {
 				t.Errorf("Strategy%v.GenerateFor() returned diff (-got +want):\n%s", tc.strategy, diff)
 			}
+			if err := rebuild.Lint(inst, "", rebuild.LintOptions{}).Err(); err != nil {
+				t.Errorf("Lint(Strategy%v.GenerateFor()) = %v", tc.strategy, err)
+			}
 		})
 	}
 }
@@ -0,0 +1,50 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rebuild
+
+import (
+	"sort"
+)
+
+// Dependency is a package resolved for use in a build.
+type Dependency struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	// URI is the location from which the dependency was fetched, if known.
+	URI string `json:"uri,omitempty"`
+	// Digest is the hex-encoded digest of the fetched dependency keyed by algorithm, if known.
+	Digest map[string]string `json:"digest,omitempty"`
+}
+
+// NormalizeDependencies sorts deps and removes duplicate entries.
+func NormalizeDependencies(deps []Dependency) []Dependency {
+	seen := make(map[string]bool)
+	var out []Dependency
+	for _, d := range deps {
+		key := d.Name + "@" + d.Version + "@" + d.URI
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, d)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Name != out[j].Name {
+			return out[i].Name < out[j].Name
+		}
+		return out[i].Version < out[j].Version
+	})
+	return out
+}
@@ -18,10 +18,12 @@ package rebuild
 import (
 	"context"
 	"io"
+	"slices"
 	"strings"
 
 	"github.com/go-git/go-billy/v5"
 	"github.com/google/oss-rebuild/pkg/archive"
+	"github.com/google/oss-rebuild/pkg/registry/cratesio"
 	"github.com/pkg/errors"
 )
 
@@ -33,6 +35,9 @@ func UpstreamArtifactReader(ctx context.Context, t Target, mux RegistryMux) (io.
 	case PyPI:
 		return mux.PyPI.Artifact(ctx, t.Package, t.Version, t.Artifact)
 	case CratesIO:
+		if cratesio.IsDistArtifact(t.Artifact) {
+			return mux.CratesIO.DistArtifact(ctx, t.Package, t.Version, t.Artifact)
+		}
 		return mux.CratesIO.Artifact(ctx, t.Package, t.Version)
 	case Debian:
 		component, name, found := strings.Cut(t.Package, "/")
@@ -40,11 +45,41 @@ func UpstreamArtifactReader(ctx context.Context, t Target, mux RegistryMux) (io.
 			return nil, errors.Errorf("failed to parse debian component: %s", t.Package)
 		}
 		return mux.Debian.Artifact(ctx, component, name, t.Artifact)
+	case GoMod:
+		return mux.GoMod.Artifact(ctx, t.Package, t.Version)
+	case RubyGems:
+		return mux.RubyGems.Artifact(ctx, t.Package, t.Version)
 	default:
 		return nil, errors.New("unsupported ecosystem")
 	}
 }
 
+// buildPathRoots are the build paths normalized within each ecosystem's artifacts.
+var buildPathRoots = map[Ecosystem][]string{
+	NPM:      archive.DefaultBuildPathRoots,
+	PyPI:     archive.DefaultBuildPathRoots,
+	CratesIO: archive.DefaultBuildPathRoots,
+}
+
+// nativeBinaryOpts configures native binary stabilization for ecosystems
+// whose artifacts may contain compiled code.
+var nativeBinaryOpts = map[Ecosystem]archive.NativeBinaryOpts{
+	PyPI:     {Strict: true},
+	CratesIO: {Strict: true},
+}
+
+// StabilizeOpts returns the stabilization options to apply to the target's artifacts.
+func StabilizeOpts(t Target) archive.StabilizeOpts {
+	stabilizers := archive.AllStabilizers
+	if roots, ok := buildPathRoots[t.Ecosystem]; ok {
+		stabilizers = slices.Concat(stabilizers, archive.NewBuildPathStabilizers(roots))
+	}
+	if opts, ok := nativeBinaryOpts[t.Ecosystem]; ok {
+		stabilizers = slices.Concat(stabilizers, archive.NewNativeBinaryStabilizers(opts))
+	}
+	return archive.StabilizeOpts{Stabilizers: stabilizers}
+}
+
 // Stabilize the upstream and rebuilt artifacts.
 func Stabilize(ctx context.Context, t Target, mux RegistryMux, rbPath string, fs billy.Filesystem, assets AssetStore) (rb, up Asset, err error) {
 	{ // Stabilize rebuild
@@ -59,7 +94,7 @@ func Stabilize(ctx context.Context, t Target, mux RegistryMux, rbPath string, fs
 			return rb, up, errors.Wrapf(err, "[INTERNAL] Failed to find rebuilt artifact")
 		}
 		defer f.Close()
-		if err := archive.Stabilize(w, f, t.ArchiveType()); err != nil {
+		if err := archive.StabilizeWithOpts(w, f, t.ArchiveType(), StabilizeOpts(t)); err != nil {
 			return rb, up, errors.Wrapf(err, "[INTERNAL] Stabilize rebuild failed")
 		}
 	}
@@ -75,7 +110,7 @@ func Stabilize(ctx context.Context, t Target, mux RegistryMux, rbPath string, fs
 			return rb, up, errors.Wrapf(err, "[INTERNAL] Failed to fetch upstream artifact")
 		}
 		defer r.Close()
-		if err := archive.Stabilize(w, r, t.ArchiveType()); err != nil {
+		if err := archive.StabilizeWithOpts(w, r, t.ArchiveType(), StabilizeOpts(t)); err != nil {
 			return rb, up, errors.Wrapf(err, "[INTERNAL] Stabilize upstream failed")
 		}
 	}
@@ -27,4 +27,8 @@ const (
 	TimewarpID
 	RunID
 	GCSClientOptionsID
+	FieldProvenanceID
+	// PreferPreciseToolchainID, if true, requests that local builds install
+	// the exact toolchain version specified by the strategy.
+	PreferPreciseToolchainID
 )
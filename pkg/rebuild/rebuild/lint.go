@@ -0,0 +1,144 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rebuild
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// LintSeverity describes how a lint finding affects a build.
+type LintSeverity string
+
+const (
+	// LintWarning findings are recorded alongside the build.
+	LintWarning LintSeverity = "warning"
+	// LintError findings prevent the build from being executed.
+	LintError LintSeverity = "error"
+)
+
+// LintFinding is a dangerous pattern identified in a generated build.
+type LintFinding struct {
+	Rule     string
+	Severity LintSeverity
+	// Phase is the section of the build in which the pattern occurred.
+	Phase   string
+	Message string
+}
+
+func (f LintFinding) String() string {
+	return fmt.Sprintf("%s: %s [%s] %s", f.Severity, f.Phase, f.Rule, f.Message)
+}
+
+// LintFindings is the result of linting a build.
+type LintFindings []LintFinding
+
+// Err returns an error describing any LintError findings.
+func (fs LintFindings) Err() error {
+	var msgs []string
+	for _, f := range fs {
+		if f.Severity == LintError {
+			msgs = append(msgs, f.String())
+		}
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(msgs, "; "))
+}
+
+// LintOptions configures the rules applied by Lint.
+type LintOptions struct {
+	// Hermetic forbids network access in the build phase.
+	Hermetic bool
+}
+
+var (
+	pipeToShellRe = regexp.MustCompile(`\b(curl|wget)\b[^\n|;&]*\|\s*(sudo\s+)?(ba|da|z)?sh\b`)
+	fromRe        = regexp.MustCompile(`(?m)^FROM\s+(?:--platform=\S+\s+)?(\S+)`)
+	// secretArgRe matches assignments to credential-like variables e.g. in
+	// build args, ARG defaults, and exported or inline environment variables.
+	secretArgRe = regexp.MustCompile(`(?m)(^|[\s=])[A-Z0-9_]*(TOKEN|SECRET|PASSWORD|PASSWD|API_?KEY|CREDENTIALS?)[A-Z0-9_]*=[^=\s]`)
+	urlCredRe   = regexp.MustCompile(`\b[a-z][a-z0-9+.-]*://[^/\s:@]+:[^/\s@]+@`)
+	// timewarpUserinfoRe matches the ecosystem and registry time encoded as
+	// userinfo in URLs produced by BuildEnv.TimewarpURL.
+	timewarpUserinfoRe = regexp.MustCompile(`^http://[a-z]+:\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})@$`)
+	// networkRe matches commands which access the network unless explicitly run offline.
+	networkRe = regexp.MustCompile(`(?m)\b(curl|wget|git\s+(clone|fetch|pull)|npm\s+(install|ci|i)|yarn\s+(install|add)|pip3?\s+(install|download)|cargo\s+(build|fetch|update|install)|go\s+(get|mod\s+download)|apk\s+add|apt(-get)?\s+install)\b[^\n]*`)
+	offlineRe = regexp.MustCompile(`--(offline|frozen|no-index|prefer-offline)\b`)
+)
+
+// Lint checks the generated build for dangerous patterns.
+func Lint(inst Instructions, dockerfile string, opts LintOptions) LintFindings {
+	var fs LintFindings
+	phases := []struct {
+		name   string
+		script string
+	}{
+		{"source", inst.Source},
+		{"deps", inst.Deps},
+		{"build", inst.Build},
+	}
+	for _, p := range phases {
+		for _, m := range pipeToShellRe.FindAllString(p.script, -1) {
+			fs = append(fs, LintFinding{Rule: "pipe-to-shell", Severity: LintWarning, Phase: p.name, Message: fmt.Sprintf("unpinned script executed from the network: %q", m)})
+		}
+		if secretArgRe.MatchString(p.script) || hasURLCredential(p.script) {
+			// NOTE: The matched value is deliberately omitted to avoid propagating the secret.
+			fs = append(fs, LintFinding{Rule: "secret-arg", Severity: LintError, Phase: p.name, Message: "credential passed via command-line or environment"})
+		}
+	}
+	if opts.Hermetic {
+		for _, m := range networkRe.FindAllString(inst.Build, -1) {
+			if offlineRe.MatchString(m) {
+				continue
+			}
+			fs = append(fs, LintFinding{Rule: "hermetic-network", Severity: LintError, Phase: "build", Message: fmt.Sprintf("network access in hermetic build: %q", strings.TrimSpace(m))})
+		}
+	}
+	// NOTE: GCB step images are recorded by digest in BuildInfo so only the
+	// rebuild container's images need to be checked.
+	for _, m := range fromRe.FindAllStringSubmatch(dockerfile, -1) {
+		if image := m[1]; !isPinnedImage(image) {
+			fs = append(fs, LintFinding{Rule: "latest-tag", Severity: LintWarning, Phase: "container", Message: fmt.Sprintf("image is not pinned to a version: %s", image)})
+		}
+	}
+	return fs
+}
+
+// hasURLCredential returns whether the script contains a URL with embedded
+// credentials, excluding timewarp registry URLs.
+func hasURLCredential(script string) bool {
+	for _, m := range urlCredRe.FindAllString(script, -1) {
+		if !timewarpUserinfoRe.MatchString(m) {
+			return true
+		}
+	}
+	return false
+}
+
+// isPinnedImage returns whether the image reference names a specific tag or digest.
+func isPinnedImage(image string) bool {
+	if strings.Contains(image, "@") {
+		return true
+	}
+	// NOTE: Any colon preceding the final slash belongs to the registry host.
+	name := image[strings.LastIndex(image, "/")+1:]
+	_, tag, found := strings.Cut(name, ":")
+	return found && tag != "latest"
+}
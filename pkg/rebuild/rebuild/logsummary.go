@@ -0,0 +1,123 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rebuild
+
+import (
+	"bufio"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Phase is a stage of the rebuild process delimited in the build logs.
+type Phase string
+
+// Phases of a rebuild in the order they occur.
+const (
+	// PhaseSource covers repo cloning, inference, and source checkout.
+	PhaseSource Phase = "source"
+	// PhaseDeps covers installation of the build's dependencies.
+	PhaseDeps Phase = "deps"
+	// PhaseBuild covers the build itself along with artifact comparison.
+	PhaseBuild Phase = "build"
+	// PhaseUpload covers the upload of debug assets.
+	PhaseUpload Phase = "upload"
+	// phaseEnd marks the completion of the final phase.
+	phaseEnd Phase = "end"
+)
+
+const (
+	phaseMarker = "::phase:: "
+	errorMarker = "::error:: "
+)
+
+// LogPhase marks the start of phase p in the build logs.
+func LogPhase(p Phase) {
+	log.Printf("%s%s %s\n", phaseMarker, p, time.Now().UTC().Format(time.RFC3339Nano))
+}
+
+// logError records err as the terminal error in the build logs.
+func logError(err error) {
+	log.Printf("%s%s\n", errorMarker, err.Error())
+}
+
+// PhaseSummary describes the log output of a single Phase.
+type PhaseSummary struct {
+	Phase    Phase
+	Start    time.Time
+	Duration time.Duration
+	// Lines is the number of log lines emitted during the phase.
+	Lines int
+}
+
+// LogSummary is a structured description of a DebugLogsAsset.
+type LogSummary struct {
+	Phases []PhaseSummary
+	// Error is the terminal error block, if the rebuild failed.
+	Error string
+}
+
+// ParseLogs splits build logs into their constituent phases.
+//
+// Logs preceding the first phase marker are not attributed to any phase. A
+// phase without a subsequent marker is reported with zero Duration.
+func ParseLogs(r io.Reader) (LogSummary, error) {
+	var summary LogSummary
+	var current *PhaseSummary
+	var errLines []string
+	var inError bool
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	for s.Scan() {
+		line := s.Text()
+		if _, marker, ok := strings.Cut(line, phaseMarker); ok {
+			inError = false
+			name, ts, _ := strings.Cut(marker, " ")
+			start, err := time.Parse(time.RFC3339Nano, ts)
+			if err != nil {
+				return LogSummary{}, errors.Wrapf(err, "parsing %s marker time", name)
+			}
+			if current != nil {
+				current.Duration = start.Sub(current.Start)
+				summary.Phases = append(summary.Phases, *current)
+				current = nil
+			}
+			if Phase(name) != phaseEnd {
+				current = &PhaseSummary{Phase: Phase(name), Start: start}
+			}
+			continue
+		}
+		if _, msg, ok := strings.Cut(line, errorMarker); ok {
+			inError = true
+			errLines = []string{msg}
+		} else if inError {
+			errLines = append(errLines, line)
+		}
+		if current != nil {
+			current.Lines++
+		}
+	}
+	if err := s.Err(); err != nil {
+		return LogSummary{}, errors.Wrap(err, "reading logs")
+	}
+	if current != nil {
+		summary.Phases = append(summary.Phases, *current)
+	}
+	summary.Error = strings.Join(errLines, "\n")
+	return summary, nil
+}
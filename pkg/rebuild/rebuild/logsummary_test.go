@@ -0,0 +1,120 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rebuild
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+)
+
+func TestParseLogs(t *testing.T) {
+	at := func(sec int) time.Time { return time.Date(2024, 1, 1, 0, 0, sec, 0, time.UTC) }
+	for _, tc := range []struct {
+		name string
+		logs string
+		want LogSummary
+	}{
+		{
+			name: "success",
+			logs: `2024/01/01 00:00:00 preamble
+2024/01/01 00:00:00 ::phase:: source 2024-01-01T00:00:00Z
+2024/01/01 00:00:00 [foo] Cloning repo
+2024/01/01 00:00:02 ::phase:: deps 2024-01-01T00:00:02Z
+added 3 packages
+2024/01/01 00:00:05 ::phase:: build 2024-01-01T00:00:05Z
+2024/01/01 00:00:05 Executing build script: """sh -c npm pack"""
+foo-1.0.0.tgz
+2024/01/01 00:00:09 ::phase:: upload 2024-01-01T00:00:09Z
+2024/01/01 00:00:10 ::phase:: end 2024-01-01T00:00:10Z
+`,
+			want: LogSummary{
+				Phases: []PhaseSummary{
+					{Phase: PhaseSource, Start: at(0), Duration: 2 * time.Second, Lines: 1},
+					{Phase: PhaseDeps, Start: at(2), Duration: 3 * time.Second, Lines: 1},
+					{Phase: PhaseBuild, Start: at(5), Duration: 4 * time.Second, Lines: 2},
+					{Phase: PhaseUpload, Start: at(9), Duration: time.Second, Lines: 0},
+				},
+			},
+		},
+		{
+			name: "failure",
+			logs: `2024/01/01 00:00:00 ::phase:: source 2024-01-01T00:00:00Z
+2024/01/01 00:00:01 ::phase:: deps 2024-01-01T00:00:01Z
+npm ERR! code E404
+2024/01/01 00:00:03 ::error:: failed to execute strategy.Deps
+exit status 1
+2024/01/01 00:00:04 ::phase:: end 2024-01-01T00:00:04Z
+`,
+			want: LogSummary{
+				Phases: []PhaseSummary{
+					{Phase: PhaseSource, Start: at(0), Duration: time.Second, Lines: 0},
+					{Phase: PhaseDeps, Start: at(1), Duration: 3 * time.Second, Lines: 3},
+				},
+				Error: "failed to execute strategy.Deps\nexit status 1",
+			},
+		},
+		{
+			name: "truncated",
+			logs: `2024/01/01 00:00:00 ::phase:: source 2024-01-01T00:00:00Z
+2024/01/01 00:00:00 [foo] Cloning repo
+`,
+			want: LogSummary{
+				Phases: []PhaseSummary{
+					{Phase: PhaseSource, Start: at(0), Lines: 1},
+				},
+			},
+		},
+		{
+			name: "unmarked",
+			logs: "2024/01/01 00:00:00 [foo] Cloning repo\n",
+			want: LogSummary{},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseLogs(strings.NewReader(tc.logs))
+			if err != nil {
+				t.Fatalf("ParseLogs() error = %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("ParseLogs() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLogPhaseRoundtrip(t *testing.T) {
+	buf := new(bytes.Buffer)
+	reset := ScopedLogCapture(log.Default(), buf)
+	LogPhase(PhaseBuild)
+	logError(errors.New("build failed"))
+	LogPhase(phaseEnd)
+	reset()
+	got, err := ParseLogs(buf)
+	if err != nil {
+		t.Fatalf("ParseLogs() error = %v", err)
+	}
+	if len(got.Phases) != 1 || got.Phases[0].Phase != PhaseBuild || got.Phases[0].Lines != 1 {
+		t.Errorf("ParseLogs() phases = %+v, want single build phase with 1 line", got.Phases)
+	}
+	if got.Error != "build failed" {
+		t.Errorf("ParseLogs() error block = %q, want %q", got.Error, "build failed")
+	}
+}
@@ -21,6 +21,12 @@ type ManualStrategy struct {
 	Build      string   `json:"build" yaml:"build,omitempty"`
 	SystemDeps []string `json:"system_deps" yaml:"system_deps,omitempty"`
 	OutputPath string   `json:"output_path" yaml:"output_path,omitempty"`
+	// AdditionalOutputs are glob patterns matching further artifacts produced by the build.
+	AdditionalOutputs []string `json:"additional_outputs,omitempty" yaml:"additional_outputs,omitempty"`
+	// Closure is an optional command printing the build's resolved dependencies.
+	Closure string `json:"closure,omitempty" yaml:"closure,omitempty"`
+	// Platform is the platform on which to execute the build. Defaults to Linux.
+	Platform Platform `json:"platform,omitempty" yaml:"platform,omitempty"`
 }
 
 var _ Strategy = &ManualStrategy{}
@@ -32,11 +38,14 @@ func (s *ManualStrategy) GenerateFor(t Target, be BuildEnv) (Instructions, error
 		return Instructions{}, err
 	}
 	return Instructions{
-		Location:   s.Location,
-		Source:     src,
-		Deps:       s.Deps,
-		Build:      s.Build,
-		SystemDeps: s.SystemDeps,
-		OutputPath: s.OutputPath,
+		Location:          s.Location,
+		Source:            src,
+		Deps:              s.Deps,
+		Build:             s.Build,
+		SystemDeps:        s.SystemDeps,
+		OutputPath:        s.OutputPath,
+		Closure:           s.Closure,
+		Platform:          s.Platform,
+		AdditionalOutputs: s.AdditionalOutputs,
 	}, nil
 }
@@ -29,4 +29,10 @@ type BuildInfo struct {
 	BuildEnd    time.Time
 	BuildImages map[string]string
 	Steps       []*cloudbuild.BuildStep
+	// SyscallPolicyPacks are the versioned policy packs traced during the build, if monitored.
+	SyscallPolicyPacks []string `json:",omitempty"`
+	// Hermetic indicates the build phase was executed without network access.
+	Hermetic bool `json:",omitempty"`
+	// LintFindings are the non-fatal issues identified in the generated build.
+	LintFindings LintFindings `json:",omitempty"`
 }
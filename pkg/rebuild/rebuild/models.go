@@ -19,6 +19,7 @@ import (
 	"time"
 
 	"github.com/google/oss-rebuild/pkg/archive"
+	"github.com/google/oss-rebuild/pkg/registry/cratesio"
 )
 
 // Ecosystem represents a package ecosystem.
@@ -31,6 +32,8 @@ const (
 	CratesIO Ecosystem = "cratesio"
 	Maven    Ecosystem = "maven"
 	Debian   Ecosystem = "debian"
+	GoMod    Ecosystem = "gomod"
+	RubyGems Ecosystem = "rubygems"
 )
 
 // Target is a single target we might attempt to rebuild.
@@ -46,7 +49,22 @@ func (t Target) ArchiveType() archive.Format {
 	switch t.Ecosystem {
 	case Debian:
 		return archive.RawFormat
-	case CratesIO, NPM:
+	case CratesIO:
+		if !cratesio.IsDistArtifact(t.Artifact) {
+			return archive.TarGzFormat
+		}
+		// cargo-dist release artifacts.
+		switch {
+		case strings.HasSuffix(t.Artifact, ".zip"):
+			return archive.ZipFormat
+		case strings.HasSuffix(t.Artifact, ".tar.gz"):
+			return archive.TarGzFormat
+		case strings.HasSuffix(t.Artifact, ".tar.xz"):
+			return archive.TarXzFormat
+		default:
+			return archive.UnknownFormat
+		}
+	case NPM:
 		return archive.TarGzFormat
 	case PyPI:
 		switch {
@@ -65,10 +83,14 @@ func (t Target) ArchiveType() archive.Format {
 		case strings.HasSuffix(t.Artifact, ".tar.bz2"), strings.HasSuffix(t.Artifact, ".tbz"):
 			return archive.UnknownFormat // bzip2
 		case strings.HasSuffix(t.Artifact, ".tar.xz"):
-			return archive.UnknownFormat // xz
+			return archive.TarXzFormat
 		default:
 			return archive.UnknownFormat
 		}
+	case GoMod:
+		return archive.ZipFormat
+	case RubyGems:
+		return archive.GemFormat
 	case Maven:
 		if strings.HasSuffix(t.Artifact, ".jar") {
 			return archive.ZipFormat
@@ -108,5 +130,11 @@ type Verdict struct {
 	Target   Target
 	Message  string
 	Strategy Strategy
-	Timings  Timings
+	// Provenance records the heuristics used to infer the Strategy, if inferred.
+	Provenance FieldProvenance
+	Timings    Timings
+	// SecretFindings is the number of credentials redacted from the build logs.
+	SecretFindings int
+	// LogSummary describes the phases of the build logs.
+	LogSummary *LogSummary
 }
@@ -0,0 +1,50 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rebuild
+
+import (
+	"path"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// FindPinFile returns the name and contents of the toolchain pin file nearest
+// to dir within tree.
+//
+// Each of names is checked in dir, then in each parent directory up to the
+// repo root, so a pin at the root of a monorepo applies to all its packages.
+// Returns object.ErrFileNotFound if no pin file is present.
+func FindPinFile(tree *object.Tree, dir string, names ...string) (name, content string, err error) {
+	dir = path.Clean(dir)
+	for {
+		for _, n := range names {
+			f, err := tree.File(path.Join(dir, n))
+			if err == object.ErrFileNotFound {
+				continue
+			} else if err != nil {
+				return "", "", err
+			}
+			content, err := f.Contents()
+			if err != nil {
+				return "", "", err
+			}
+			return n, content, nil
+		}
+		if dir == "." || dir == "/" {
+			return "", "", object.ErrFileNotFound
+		}
+		dir = path.Dir(dir)
+	}
+}
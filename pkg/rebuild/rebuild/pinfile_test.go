@@ -0,0 +1,76 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rebuild
+
+import (
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+func createTree(files map[string]string) *object.Tree {
+	repo := must(git.Init(memory.NewStorage(), memfs.New()))
+	worktree := must(repo.Worktree())
+	for name, content := range files {
+		if err := util.WriteFile(worktree.Filesystem, name, []byte(content), 0644); err != nil {
+			panic(err)
+		}
+		must(worktree.Add(name))
+	}
+	hash := must(worktree.Commit("Test commit", &git.CommitOptions{
+		Author:    &object.Signature{Name: "Test Author", Email: "test@example.com"},
+		Committer: &object.Signature{Name: "Test Author", Email: "test@example.com"},
+	}))
+	return must(must(repo.CommitObject(hash)).Tree())
+}
+
+func TestFindPinFile(t *testing.T) {
+	tree := createTree(map[string]string{
+		".nvmrc":                    "root",
+		"pkgs/a/.node-version":      "a",
+		"pkgs/a/package.json":       "{}",
+		"pkgs/b/package.json":       "{}",
+		"pkgs/c/.nvmrc/placeholder": "",
+	})
+	tests := []struct {
+		dir         string
+		names       []string
+		wantName    string
+		wantContent string
+		wantErr     error
+	}{
+		{".", []string{".nvmrc"}, ".nvmrc", "root", nil},
+		{"pkgs/a", []string{".nvmrc", ".node-version"}, ".node-version", "a", nil},
+		{"pkgs/b", []string{".nvmrc", ".node-version"}, ".nvmrc", "root", nil},
+		{"pkgs/c", []string{".nvmrc"}, ".nvmrc", "root", nil},
+		{"pkgs/a/", []string{".nvmrc"}, ".nvmrc", "root", nil},
+		{"pkgs/b", []string{".python-version"}, "", "", object.ErrFileNotFound},
+	}
+	for _, tc := range tests {
+		t.Run(tc.dir, func(t *testing.T) {
+			name, content, err := FindPinFile(tree, tc.dir, tc.names...)
+			if err != tc.wantErr {
+				t.Fatalf("FindPinFile() error = %v, want %v", err, tc.wantErr)
+			}
+			if name != tc.wantName || content != tc.wantContent {
+				t.Errorf("FindPinFile() = (%q, %q), want (%q, %q)", name, content, tc.wantName, tc.wantContent)
+			}
+		})
+	}
+}
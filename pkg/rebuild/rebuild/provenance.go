@@ -0,0 +1,48 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rebuild
+
+import "context"
+
+// FieldProvenance maps the serialized name of each inferred strategy field to
+// the heuristic that produced its value.
+type FieldProvenance map[string]string
+
+// Heuristics shared across ecosystems.
+const (
+	// HeuristicHint denotes a value provided by the caller's LocationHint.
+	HeuristicHint = "hint"
+	// HeuristicRegistry denotes a value read directly from registry metadata.
+	HeuristicRegistry = "registry"
+	// HeuristicTag denotes a ref selected by matching the version to a git tag.
+	HeuristicTag = "tag"
+	// HeuristicDefault denotes a fixed fallback used when no other heuristic applied.
+	HeuristicDefault = "default"
+)
+
+// WithFieldProvenance returns a context in which RecordProvenance calls are
+// collected into the returned FieldProvenance.
+func WithFieldProvenance(ctx context.Context) (context.Context, FieldProvenance) {
+	fp := make(FieldProvenance)
+	return context.WithValue(ctx, FieldProvenanceID, fp), fp
+}
+
+// RecordProvenance notes the heuristic that produced the value of a field.
+// It is a no-op if the context is not collecting provenance.
+func RecordProvenance(ctx context.Context, field, heuristic string) {
+	if fp, ok := ctx.Value(FieldProvenanceID).(FieldProvenance); ok {
+		fp[field] = heuristic
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rebuild
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRecordProvenance(t *testing.T) {
+	// Recording without a collector is a no-op.
+	RecordProvenance(context.Background(), "ref", HeuristicTag)
+
+	ctx, fp := WithFieldProvenance(context.Background())
+	RecordProvenance(ctx, "ref", HeuristicTag)
+	RecordProvenance(ctx, "dir", HeuristicDefault)
+	// Later heuristics override earlier ones for the same field.
+	RecordProvenance(ctx, "ref", HeuristicHint)
+	want := FieldProvenance{"ref": HeuristicHint, "dir": HeuristicDefault}
+	if diff := cmp.Diff(want, fp); diff != "" {
+		t.Errorf("FieldProvenance mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -16,9 +16,18 @@ package rebuild
 
 import (
 	"context"
+	"log"
 
 	"github.com/go-git/go-billy/v5"
 	"github.com/go-git/go-git/v5/storage"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrNoRef is returned by InferStrategy when no candidate git ref is found.
+	ErrNoRef = errors.New("no git ref")
+	// ErrNoValidRef is returned by InferStrategy when no candidate git ref matches the package.
+	ErrNoValidRef = errors.New("no valid git ref")
 )
 
 // Rebuilder defines the operations used to rebuild an ecosystem's packages.
@@ -29,3 +38,38 @@ type Rebuilder interface {
 	Rebuild(context.Context, Target, Instructions, billy.Filesystem) error
 	Compare(context.Context, Target, Asset, Asset, AssetStore, Instructions) (error, error)
 }
+
+// SourceArchiveInferer is implemented by Rebuilders that can rebuild from a
+// package's published SourceArchive when no matching git ref can be found.
+type SourceArchiveInferer interface {
+	InferSourceArchiveStrategy(context.Context, Target, RegistryMux) (Strategy, error)
+}
+
+// InferStrategy infers the strategy for t using r, falling back to the
+// package's published source archive when no git ref can be found and r
+// supports it.
+//
+// NOTE: A hinted ref is never substituted with the source archive.
+func InferStrategy(ctx context.Context, r Rebuilder, t Target, mux RegistryMux, rcfg *RepoConfig, hint Strategy) (Strategy, error) {
+	s, err := r.InferStrategy(ctx, t, mux, rcfg, hint)
+	if !errors.Is(err, ErrNoRef) && !errors.Is(err, ErrNoValidRef) {
+		return s, err
+	}
+	sai, ok := r.(SourceArchiveInferer)
+	if !ok {
+		return nil, err
+	}
+	if lh, _ := hint.(*LocationHint); lh != nil && lh.Ref != "" {
+		return nil, err
+	}
+	log.Printf("Falling back to source archive for %s: %v", t.Package, err)
+	return sai.InferSourceArchiveStrategy(ctx, t, mux)
+}
+
+// OutputLister is implemented by Rebuilders whose targets may describe a build
+// that produces several artifacts, e.g. a source package.
+type OutputLister interface {
+	// Outputs returns the artifacts produced by building the target, or nil
+	// if the target is itself a single artifact.
+	Outputs(context.Context, Target, RegistryMux) ([]Target, error)
+}
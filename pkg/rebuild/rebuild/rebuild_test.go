@@ -0,0 +1,111 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rebuild
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+)
+
+// inferOnly is a Rebuilder whose InferStrategy returns a fixed result.
+type inferOnly struct {
+	Rebuilder
+	strategy Strategy
+	err      error
+}
+
+func (r inferOnly) InferStrategy(context.Context, Target, RegistryMux, *RepoConfig, Strategy) (Strategy, error) {
+	return r.strategy, r.err
+}
+
+// withSourceArchive is a Rebuilder that supports source archive inference.
+type withSourceArchive struct {
+	inferOnly
+}
+
+func (withSourceArchive) InferSourceArchiveStrategy(context.Context, Target, RegistryMux) (Strategy, error) {
+	return &ManualStrategy{Build: "from archive"}, nil
+}
+
+func TestInferStrategy(t *testing.T) {
+	fromRepo := &ManualStrategy{Build: "from repo"}
+	fromArchive := &ManualStrategy{Build: "from archive"}
+	target := Target{Ecosystem: NPM, Package: "pkg", Version: "1.0.0", Artifact: "pkg-1.0.0.tgz"}
+	tests := []struct {
+		name      string
+		rebuilder Rebuilder
+		hint      Strategy
+		want      Strategy
+		wantErr   error
+	}{
+		{
+			name:      "inferred from repo",
+			rebuilder: withSourceArchive{inferOnly{strategy: fromRepo}},
+			want:      fromRepo,
+		},
+		{
+			name:      "no ref",
+			rebuilder: withSourceArchive{inferOnly{err: ErrNoRef}},
+			want:      fromArchive,
+		},
+		{
+			name:      "no valid ref",
+			rebuilder: withSourceArchive{inferOnly{err: errors.Wrap(ErrNoValidRef, "inferring ref")}},
+			want:      fromArchive,
+		},
+		{
+			name:      "hint without ref",
+			rebuilder: withSourceArchive{inferOnly{err: ErrNoRef}},
+			hint:      &LocationHint{Location: Location{Repo: "https://github.com/foo/pkg"}},
+			want:      fromArchive,
+		},
+		{
+			name:      "hinted ref not substituted",
+			rebuilder: withSourceArchive{inferOnly{err: ErrNoValidRef}},
+			hint:      &LocationHint{Location: Location{Repo: "https://github.com/foo/pkg", Ref: "abcdef"}},
+			wantErr:   ErrNoValidRef,
+		},
+		{
+			name:      "source archive unsupported",
+			rebuilder: inferOnly{err: ErrNoRef},
+			wantErr:   ErrNoRef,
+		},
+		{
+			name:      "other error",
+			rebuilder: withSourceArchive{inferOnly{err: errors.New("clone failed")}},
+			wantErr:   errors.New("clone failed"),
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := InferStrategy(context.Background(), tc.rebuilder, target, RegistryMux{}, &RepoConfig{}, tc.hint)
+			if tc.wantErr != nil {
+				if err == nil || err.Error() != tc.wantErr.Error() && !errors.Is(err, tc.wantErr) {
+					t.Fatalf("InferStrategy() error = %v, want %v", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("InferStrategy() error = %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("InferStrategy() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
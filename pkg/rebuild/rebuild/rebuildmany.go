@@ -32,7 +32,10 @@ import (
 	"github.com/go-git/go-git/v5/storage/filesystem"
 	cacheinternal "github.com/google/oss-rebuild/internal/cache"
 	"github.com/google/oss-rebuild/internal/gitx"
+	"github.com/google/oss-rebuild/internal/scrub"
+	"github.com/google/oss-rebuild/internal/tracing"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // RepoConfig describes the repo currently being used.
@@ -103,6 +106,8 @@ func RebuildMany(ctx context.Context, rebuilder Rebuilder, inputs []Input, regis
 	var verdicts []Verdict
 	safeRebuildOne := func(input Input) {
 		t := input.Target
+		ctx, span := tracing.Start(ctx, "RebuildOne", attribute.String("version", t.Version), attribute.String("artifact", t.Artifact))
+		defer span.End()
 		defer func() {
 			if panicval := recover(); panicval != nil {
 				log.Printf("Rebuild panic: %v\n", panicval)
@@ -114,35 +119,62 @@ func RebuildMany(ctx context.Context, rebuilder Rebuilder, inputs []Input, regis
 		// Setup scoped logging.
 		logbuf := new(bytes.Buffer)
 		resetLogger := ScopedLogCapture(log.Default(), logbuf)
-		verdict, assets, err := RebuildOne(ctx, rebuilder, input, registry, &rcfg, fs, s, localAssets)
-		if err != nil {
-			verdict.Message = err.Error()
+		var results []Verdict
+		var assets []Asset
+		if outputs, err := listOutputs(ctx, rebuilder, t, registry); err != nil {
+			results = []Verdict{{Target: t, Message: err.Error()}}
+			logError(err)
+		} else if outputs != nil {
+			results, assets = RebuildOutputs(ctx, rebuilder, input, outputs, registry, &rcfg, fs, s, localAssets)
+		} else {
+			verdict, rbAssets, err := RebuildOne(ctx, rebuilder, input, registry, &rcfg, fs, s, localAssets)
+			if err != nil {
+				verdict.Message = err.Error()
+				logError(err)
+			}
+			results, assets = []Verdict{verdict}, rbAssets
+		}
+		if debugStorer != nil {
+			LogPhase(PhaseUpload)
+			uploadCtx, span := tracing.Start(ctx, "upload")
+			for _, asset := range assets {
+				if err := AssetCopy(uploadCtx, debugStorer, localAssets, asset); err != nil {
+					log.Printf("Failed to upload asset to debug storer: %v\n", err)
+				}
+			}
+			span.End()
 		}
-		verdicts = append(verdicts, verdict)
+		LogPhase(phaseEnd)
 		resetLogger()
 		{
-			asset := DebugLogsAsset.For(t)
-			w, err := localAssets.Writer(ctx, asset)
+			// NOTE: Logs may include output from user-controlled build scripts so
+			// credentials must be removed before they leave the builder.
+			logs, findings := scrub.Bytes(logbuf.Bytes())
+			if findings > 0 {
+				log.Printf("Redacted %d credential(s) from logs\n", findings)
+			}
+			summary, err := ParseLogs(bytes.NewReader(logs))
 			if err != nil {
-				log.Printf("Failed to create writer for log asset: %v\n", err)
-			} else {
-				defer w.Close()
-				if _, err := w.Write(logbuf.Bytes()); err != nil {
-					log.Printf("Failed to store logs: %v\n", err)
-				} else {
-					assets = append(assets, asset)
+				log.Printf("Failed to summarize logs: %v\n", err)
+			}
+			for i := range results {
+				results[i].SecretFindings = findings
+				if err == nil {
+					results[i].LogSummary = &summary
 				}
 			}
-			// Empty logbuf because we're about to do more in-memory file stuff.
-			logbuf.Reset()
-		}
-		if debugStorer != nil {
-			for _, asset := range assets {
+			asset := DebugLogsAsset.For(t)
+			if err := writeAsset(ctx, localAssets, asset, logs); err != nil {
+				log.Printf("Failed to store logs: %v\n", err)
+			} else if debugStorer != nil {
 				if err := AssetCopy(ctx, debugStorer, localAssets, asset); err != nil {
-					log.Printf("Failed to upload asset to debug storer: %v\n", err)
+					log.Printf("Failed to upload logs to debug storer: %v\n", err)
 				}
 			}
+			// Empty logbuf because we're about to do more in-memory file stuff.
+			logbuf.Reset()
 		}
+		verdicts = append(verdicts, results...)
 	}
 	for _, input := range inputs {
 		log.Printf("Rebuilding %s %s", input.Target.Package, input.Target.Version)
@@ -166,3 +198,28 @@ func RebuildMany(ctx context.Context, rebuilder Rebuilder, inputs []Input, regis
 	}
 	return verdicts, nil
 }
+
+// listOutputs returns the artifacts produced by building t, or nil if t is a single artifact.
+func listOutputs(ctx context.Context, rebuilder Rebuilder, t Target, mux RegistryMux) ([]Target, error) {
+	ol, ok := rebuilder.(OutputLister)
+	if !ok {
+		return nil, nil
+	}
+	outputs, err := ol.Outputs(ctx, t, mux)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing outputs")
+	}
+	return outputs, nil
+}
+
+func writeAsset(ctx context.Context, store AssetStore, a Asset, content []byte) error {
+	w, err := store.Writer(ctx, a)
+	if err != nil {
+		return errors.Wrap(err, "creating writer")
+	}
+	if _, err := w.Write(content); err != nil {
+		w.Close()
+		return errors.Wrap(err, "writing asset")
+	}
+	return w.Close()
+}
@@ -25,26 +25,91 @@ import (
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/storage"
 	"github.com/google/oss-rebuild/internal/gitx"
+	"github.com/google/oss-rebuild/internal/tracing"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // RebuildOne runs a rebuild for the given package artifact.
 // NOTE: err indicates a failed rebuild but the verdict and toUpload returns
 // will be valid regardless of its value.
 func RebuildOne(ctx context.Context, r Rebuilder, input Input, mux RegistryMux, rcfg *RepoConfig, fs billy.Filesystem, s storage.Storer, assets AssetStore) (verdict Verdict, toUpload []Asset, err error) {
+	var inst Instructions
+	verdict, inst, err = buildOne(ctx, r, input, mux, rcfg, fs, s)
+	if err != nil {
+		return
+	}
+	toUpload, err = compareOne(ctx, r, input.Target, inst, mux, fs, assets)
+	return
+}
+
+// RebuildOutputs runs a single build for the given target and compares each
+// of the artifacts it produces, returning a verdict for each output.
+func RebuildOutputs(ctx context.Context, r Rebuilder, input Input, outputs []Target, mux RegistryMux, rcfg *RepoConfig, fs billy.Filesystem, s storage.Storer, assets AssetStore) (verdicts []Verdict, toUpload []Asset) {
+	if len(outputs) == 0 {
+		return []Verdict{{Target: input.Target, Message: "no outputs found"}}, nil
+	}
+	// NOTE: The build is configured using the first output since strategies
+	// generate instructions for a specific artifact.
+	built := input
+	built.Target = outputs[0]
+	base, _, err := buildOne(ctx, r, built, mux, rcfg, fs, s)
+	for _, t := range outputs {
+		v := base
+		v.Target = t
+		if err != nil {
+			v.Message = err.Error()
+			verdicts = append(verdicts, v)
+			continue
+		}
+		cmpErr := func() error {
+			inst, err := v.Strategy.GenerateFor(t, localBuildEnv(ctx))
+			if err != nil {
+				return errors.Wrap(err, "failed to generate strategy")
+			}
+			uploads, err := compareOne(ctx, r, t, inst, mux, fs, assets)
+			toUpload = append(toUpload, uploads...)
+			return err
+		}()
+		if cmpErr != nil {
+			v.Message = cmpErr.Error()
+		}
+		verdicts = append(verdicts, v)
+	}
+	return verdicts, toUpload
+}
+
+// localBuildEnv returns the BuildEnv used for builds executed by this process.
+func localBuildEnv(ctx context.Context) BuildEnv {
+	rbenv := BuildEnv{HasRepo: true}
+	if tw, ok := ctx.Value(TimewarpID).(string); ok {
+		rbenv.TimewarpHost = tw
+	}
+	if precise, ok := ctx.Value(PreferPreciseToolchainID).(bool); ok {
+		rbenv.PreferPreciseToolchain = precise
+	}
+	return rbenv
+}
+
+// buildOne infers a strategy for the given target, if necessary, and executes its build.
+func buildOne(ctx context.Context, r Rebuilder, input Input, mux RegistryMux, rcfg *RepoConfig, fs billy.Filesystem, s storage.Storer) (verdict Verdict, inst Instructions, err error) {
 	verdict.Target = input.Target
 	t := input.Target
 	var repoURI string
+	LogPhase(PhaseSource)
+	var provenance FieldProvenance
+	ctx, provenance = WithFieldProvenance(ctx)
 	if input.Strategy != nil {
 		if hint, ok := input.Strategy.(*LocationHint); ok && hint != nil {
 			repoURI = hint.Repo
+			RecordProvenance(ctx, "repo", HeuristicHint)
 		} else {
-			var inst Instructions
-			inst, err = input.Strategy.GenerateFor(t, BuildEnv{})
+			var hintInst Instructions
+			hintInst, err = input.Strategy.GenerateFor(t, BuildEnv{})
 			if err != nil {
 				return
 			}
-			repoURI = inst.Location.Repo
+			repoURI = hintInst.Location.Repo
 		}
 	} else {
 		repoURI, err = r.InferRepo(ctx, t, mux)
@@ -61,7 +126,9 @@ func RebuildOne(ctx context.Context, r Rebuilder, input Input, mux RegistryMux,
 			util.RemoveAll(fs, fs.Root())
 		}
 		var newRepo RepoConfig
-		newRepo, err = r.CloneRepo(ctx, t, repoURI, fs, s)
+		cloneCtx, span := tracing.Start(ctx, "clone", attribute.String("repo", repoURI))
+		newRepo, err = r.CloneRepo(cloneCtx, t, repoURI, fs, s)
+		tracing.End(span, err)
 		if err != nil {
 			return
 		}
@@ -76,18 +143,22 @@ func RebuildOne(ctx context.Context, r Rebuilder, input Input, mux RegistryMux,
 	}
 	verdict.Timings.Source = time.Since(repoSetupStart)
 	inferenceStart := time.Now()
+	inferCtx, span := tracing.Start(ctx, "infer")
 	if lh, ok := input.Strategy.(*LocationHint); ok && lh != nil {
 		// If the input was a hint, include it in inference.
 		if lh.Ref == "" && lh.Dir != "" {
 			// TODO: For each ecosystem, allow ref inference to occur and validate dir.
 			err = errors.New("Dir without Ref is not yet supported.")
+			tracing.End(span, err)
 			return
 		}
 		log.Printf("[%s] LocationHint provided: %v, running inference...\n", t.Package, *lh)
-		verdict.Strategy, err = r.InferStrategy(ctx, t, mux, rcfg, lh)
+		verdict.Strategy, err = InferStrategy(inferCtx, r, t, mux, rcfg, lh)
 		if err != nil {
+			tracing.End(span, err)
 			return
 		}
+		verdict.Provenance = provenance
 	} else if input.Strategy != nil {
 		// If the input was a full strategy, skip inference.
 		log.Printf("[%s] Strategy provided, skipping inference.\n", t.Package)
@@ -95,27 +166,30 @@ func RebuildOne(ctx context.Context, r Rebuilder, input Input, mux RegistryMux,
 	} else {
 		// Otherwise, run full inference.
 		log.Printf("[%s] No strategy provided, running inference...\n", t.Package)
-		verdict.Strategy, err = r.InferStrategy(ctx, t, mux, rcfg, nil)
+		verdict.Strategy, err = InferStrategy(inferCtx, r, t, mux, rcfg, nil)
 		if err != nil {
+			tracing.End(span, err)
 			return
 		}
+		verdict.Provenance = provenance
 	}
+	span.End()
 	verdict.Timings.Infer = time.Since(inferenceStart)
-	rbenv := BuildEnv{HasRepo: true}
-	if tw, ok := ctx.Value(TimewarpID).(string); ok {
-		rbenv.TimewarpHost = tw
-	}
-	inst, err := verdict.Strategy.GenerateFor(t, rbenv)
+	inst, err = verdict.Strategy.GenerateFor(t, localBuildEnv(ctx))
 	if err != nil {
 		err = errors.Wrap(err, "failed to generate strategy")
 		return
 	}
 	buildStart := time.Now()
-	err = r.Rebuild(ctx, t, inst, fs)
+	buildCtx, span := tracing.Start(ctx, "build")
+	err = r.Rebuild(buildCtx, t, inst, fs)
+	tracing.End(span, err)
 	verdict.Timings.Build = time.Since(buildStart)
-	if err != nil {
-		return
-	}
+	return
+}
+
+// compareOne compares the built artifact for the given target against upstream.
+func compareOne(ctx context.Context, r Rebuilder, t Target, inst Instructions, mux RegistryMux, fs billy.Filesystem, assets AssetStore) (toUpload []Asset, err error) {
 	rbPath := inst.OutputPath
 	_, err = fs.Stat(rbPath)
 	if err != nil {
@@ -126,11 +200,13 @@ func RebuildOne(ctx context.Context, r Rebuilder, input Input, mux RegistryMux,
 		err = errors.Wrapf(err, "failed to stat artifact")
 		return
 	}
-	rb, up, err := Stabilize(ctx, t, mux, rbPath, fs, assets)
+	compareCtx, span := tracing.Start(ctx, "compare")
+	defer func() { tracing.End(span, err) }()
+	rb, up, err := Stabilize(compareCtx, t, mux, rbPath, fs, assets)
 	if err != nil {
 		return
 	}
-	cmpErr, err := r.Compare(ctx, t, rb, up, assets, inst)
+	cmpErr, err := r.Compare(compareCtx, t, rb, up, assets, inst)
 	if err == nil {
 		err = cmpErr
 	}
@@ -17,9 +17,9 @@ package rebuild
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"io"
-	"log"
 	"os"
 	"path"
 	"strings"
@@ -28,9 +28,10 @@ import (
 
 	"github.com/google/oss-rebuild/internal/gcb"
 	"github.com/google/oss-rebuild/internal/textwrap"
+	"github.com/google/oss-rebuild/internal/tracing"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
 	"google.golang.org/api/cloudbuild/v1"
-	"gopkg.in/yaml.v3"
 )
 
 // RemoteOptions provides the configuration to execute rebuilds on Cloud Build.
@@ -50,6 +51,27 @@ type RemoteOptions struct {
 	UseTimewarp       bool
 	UseNetworkProxy   bool
 	UseSyscallMonitor bool
+	// SyscallPolicyPacks are the names of the policy packs to trace when UseSyscallMonitor is set.
+	// If empty, the ecosystem defaults are used.
+	SyscallPolicyPacks []string
+	// Hermetic executes the build phase without network access.
+	// All network resources must be fetched by the preceding deps phase.
+	Hermetic bool
+	// WindowsWorkerPool is the resource name of the GCB private pool on which
+	// Windows builds are executed e.g. projects/p/locations/l/workerPools/w.
+	WindowsWorkerPool string
+	// WindowsDockerHost is the address of the Windows Docker daemon reachable
+	// from WindowsWorkerPool e.g. tcp://10.0.0.2:2375.
+	WindowsDockerHost string
+}
+
+// syscallPolicyPacks returns the policy packs to be used when monitoring the build of t.
+func syscallPolicyPacks(t Target, opts RemoteOptions) ([]*SyscallPolicyPack, error) {
+	names := opts.SyscallPolicyPacks
+	if len(names) == 0 {
+		names = DefaultSyscallPolicyPacks(t.Ecosystem)
+	}
+	return ResolveSyscallPolicyPacks(names)
 }
 
 type rebuildContainerArgs struct {
@@ -59,65 +81,6 @@ type rebuildContainerArgs struct {
 	UtilPrebuildBucket string
 }
 
-const policyYaml = `
-apiVersion: cilium.io/v1alpha1
-kind: TracingPolicy
-metadata:
-  name: "process-and-memory"
-spec:
-  kprobes:
-  - call: "security_file_permission"
-    syscall: false
-    return: true
-    args:
-    - index: 0
-      type: "file" # (struct file *) used for getting the path
-    - index: 1
-      type: "int" # 0x04 is MAY_READ, 0x02 is MAY_WRITE
-    returnArg:
-      index: 0
-      type: "int"
-    returnArgAction: "Post"
-  - call: "security_mmap_file"
-    syscall: false
-    return: true
-    args:
-    - index: 0
-      type: "file" # (struct file *) used for getting the path
-    - index: 1
-      type: "uint64" # the prot flags PROT_READ(0x01), PROT_WRITE(0x02), PROT_EXEC(0x04)
-    - index: 2
-      type: "uint32" # the mmap flags (i.e. MAP_SHARED, ...)
-    returnArg:
-      index: 0
-      type: "int"
-    returnArgAction: "Post"
-  - call: "security_path_truncate"
-    syscall: false
-    return: true
-    args:
-    - index: 0
-      type: "path" # (struct path *) used for getting the path
-    returnArg:
-      index: 0
-      type: "int"
-    returnArgAction: "Post"
-`
-
-var tetragonPolicyJSON string
-
-func init() {
-	var data any
-	if err := yaml.Unmarshal([]byte(policyYaml), &data); err != nil {
-		log.Fatalf("Malformed tetragon policy: %v", err)
-	}
-	b, err := json.Marshal(data)
-	if err != nil {
-		log.Fatalf("Converting tetragon policy to json: %v", err)
-	}
-	tetragonPolicyJSON = string(b)
-}
-
 var debuildContainerTpl = template.Must(
 	template.New(
 		"rebuild container",
@@ -155,6 +118,12 @@ var debuildContainerTpl = template.Must(
 				 ls
 				 ls /src/
 				 mkdir /out && cp /src/{{.Instructions.OutputPath}} /out/
+				{{- if .Instructions.AdditionalOutputs}}
+				 mkdir /out/extra && cp{{range .Instructions.AdditionalOutputs}} /src/{{.}}{{end}} /out/extra/
+				{{- end}}
+				{{- if .Instructions.Closure}}
+				 ({{.Instructions.Closure}}) > /out/closure.out || true
+				{{- end}}
 				EOF
 				WORKDIR "/src"
 				ENTRYPOINT ["/bin/sh","/build"]
@@ -194,12 +163,90 @@ var alpineContainerTpl = template.Must(
 				 set -eux
 				 {{.Instructions.Build | indent}}
 				 mkdir /out && cp /src/{{.Instructions.OutputPath}} /out/
+				{{- if .Instructions.AdditionalOutputs}}
+				 mkdir /out/extra && cp{{range .Instructions.AdditionalOutputs}} /src/{{.}}{{end}} /out/extra/
+				{{- end}}
+				{{- if .Instructions.Closure}}
+				 ({{.Instructions.Closure}}) > /out/closure.out || true
+				{{- end}}
 				EOF
 				WORKDIR "/src"
 				ENTRYPOINT ["/bin/sh","/build"]
 				`)[1:], // remove leading newline
 	))
 
+// windowsScript encodes a PowerShell script such that it can be written to
+// disk from a single Dockerfile instruction.
+//
+// NOTE: Windows containers do not support BuildKit and thus heredocs.
+func windowsScript(lines ...string) string {
+	script := strings.Join(append([]string{"$ErrorActionPreference = 'Stop'"}, lines...), "\r\n")
+	return base64.StdEncoding.EncodeToString([]byte(script))
+}
+
+type windowsContainerArgs struct {
+	Instructions
+	// Setup is the encoded script fetching the source and dependencies.
+	Setup string
+	// Build is the encoded script building and collecting the artifact.
+	Build string
+}
+
+func newWindowsContainerArgs(inst Instructions) windowsContainerArgs {
+	build := []string{
+		inst.Build,
+		"New-Item -ItemType Directory C:/out | Out-Null",
+		"Copy-Item C:/src/" + inst.OutputPath + " C:/out/",
+	}
+	if len(inst.AdditionalOutputs) > 0 {
+		build = append(build, "New-Item -ItemType Directory C:/out/extra | Out-Null")
+		for _, o := range inst.AdditionalOutputs {
+			build = append(build, "Copy-Item C:/src/"+o+" C:/out/extra/")
+		}
+	}
+	if inst.Closure != "" {
+		build = append(build, "try { [IO.File]::WriteAllText('C:/out/closure.out', ((& { "+inst.Closure+" }) | Out-String)) } catch {}")
+	}
+	return windowsContainerArgs{
+		Instructions: inst,
+		Setup:        windowsScript(inst.Source, inst.Deps),
+		Build:        windowsScript(build...),
+	}
+}
+
+// NOTE: Paths use forward slashes to avoid conflicting with the Dockerfile escape character.
+// NOTE: Timewarp is not provided on Windows so registry requests observe the present.
+var windowsContainerTpl = template.Must(
+	template.New(
+		"windows rebuild container",
+	).Parse(
+		textwrap.Dedent(`
+				FROM mcr.microsoft.com/dotnet/framework/sdk:4.8.1-windowsservercore-ltsc2022
+				SHELL ["powershell", "-NoProfile", "-Command", "$ErrorActionPreference = 'Stop'; $ProgressPreference = 'SilentlyContinue';"]
+				RUN Set-ExecutionPolicy Bypass -Scope Process -Force; [Net.ServicePointManager]::SecurityProtocol = [Net.SecurityProtocolType]::Tls12; iex ((New-Object Net.WebClient).DownloadString('https://community.chocolatey.org/install.ps1'))
+				RUN choco install -y --no-progress git{{range .Instructions.SystemDeps}} {{.}}{{end}}
+				RUN [IO.File]::WriteAllBytes('C:/setup.ps1', [Convert]::FromBase64String('{{.Setup}}')); New-Item -ItemType Directory C:/src | Out-Null; Set-Location C:/src; & C:/setup.ps1
+				RUN [IO.File]::WriteAllBytes('C:/build.ps1', [Convert]::FromBase64String('{{.Build}}'))
+				WORKDIR C:/src
+				ENTRYPOINT ["powershell", "-NoProfile", "-ExecutionPolicy", "Bypass", "-File", "C:/build.ps1"]
+				`)[1:], // remove leading newline
+	))
+
+// NOTE: Windows containers are built by a remote daemon which does not support BuildKit.
+var windowsBuildTpl = template.Must(
+	template.New(
+		"windows build",
+	).Parse(
+		textwrap.Dedent(`
+				#!/usr/bin/env bash
+				set -eux
+				cat <<'EOS' | DOCKER_BUILDKIT=0 docker build --tag=img -
+				{{.Dockerfile}}
+				EOS
+				docker run{{if .Hermetic}} --network=none{{end}} --name=container img
+				`)[1:], // remove leading newline
+	))
+
 var standardBuildTpl = template.Must(
 	template.New(
 		"standard build",
@@ -216,7 +263,7 @@ var standardBuildTpl = template.Must(
 				cat <<'EOS' | docker buildx build --tag=img -
 				{{.Dockerfile}}
 				EOS
-				docker run --name=container img
+				docker run{{if .Hermetic}} --network=none{{end}} --name=container img
 				{{- if .UseSyscallMonitor}}
 				docker kill tetragon
 				{{- end}}
@@ -256,6 +303,10 @@ var standardBuildTpl = template.Must(
 //     (host-defined "docker" group unknown within the container). Associating
 //     the proxy with a shadowed "docker" group does not seem to work. Changing
 //     ownership of the socket resolves this, albeit suboptimally.
+//   - Build phases: The image build (source and dependency fetching) and the
+//     container run (the build itself) are announced to the proxy as the
+//     "deps" and "build" phases so it may apply a policy specific to each and
+//     attribute the network activity of each in the netlog.
 //   - Docker build: To ensure the proxy cert is trusted during the build, each
 //     execution (i.e. RUN instruction) must be patched to mount the proxy
 //     certificate and add truststore env vars. This is currently done
@@ -309,13 +360,15 @@ var proxyBuildTpl = template.Must(
 				{{- end}}
 				docker exec build /bin/sh -euxc '
 					curl http://proxy:{{.CtrlPort}}/cert | tee /etc/ssl/certs/proxy.crt >> /etc/ssl/certs/ca-certificates.crt
+					curl -fX PUT "http://proxy:{{.CtrlPort}}/phase?name=deps"
 					export DOCKER_HOST=tcp://proxy:{{.DockerPort}} PROXYCERT=/etc/ssl/certs/proxy.crt
 					docker buildx create --name proxied --bootstrap --driver docker-container --driver-opt network=container:build
 					cat <<EOS | sed "s|^RUN|RUN --mount=type=bind,from=certs,dst=/etc/ssl/certs{{range .CertEnvVars}} --mount=type=secret,id=PROXYCERT,env={{.}}{{end}}|" | \
 						docker buildx build --builder proxied --build-context certs=/etc/ssl/certs --secret id=PROXYCERT --load --tag=img -
 					{{.Dockerfile}}
 				EOS
-					docker run --name=container img
+					curl -fX PUT "http://proxy:{{.CtrlPort}}/phase?name=build"
+					docker run{{if .Hermetic}} --network=none{{end}} --name=container img
 				'
 				{{- if .UseSyscallMonitor}}
 				docker kill tetragon
@@ -324,6 +377,9 @@ var proxyBuildTpl = template.Must(
 				`)[1:], // remove leading newline
 	))
 
+// sbomImage is the step image used to generate the rebuild container SBOM.
+const sbomImage = "docker.io/anchore/syft:v1.18.1"
+
 type upload struct {
 	From string
 	To   string
@@ -337,27 +393,63 @@ var assetUploadTpl = template.Must(
 				set -eux
 				wget https://{{.UtilPrebuildBucket}}.storage.googleapis.com/gsutil_writeonly
 				chmod +x gsutil_writeonly
+				touch /workspace/sbom.spdx.json
 				{{- range .Uploads}}
 				./gsutil_writeonly cp {{.From}} {{.To}}
 				{{- end}}
+				while read -r name; do
+				./gsutil_writeonly cp "/workspace/extra/$name" "{{.AdditionalOutputTo}}"
+				done < /workspace/outputs.txt
 				`)[1:], // remove leading newline
 	))
 
-func makeBuild(t Target, dockerfile string, opts RemoteOptions) (*cloudbuild.Build, error) {
+func makeBuild(t Target, dockerfile string, platform Platform, opts RemoteOptions) (*cloudbuild.Build, error) {
 	var buildScript bytes.Buffer
 	uploads := []upload{
 		{From: "/workspace/image.tgz", To: opts.RemoteMetadataStore.URL(ContainerImageAsset.For(t)).String()},
 		{From: path.Join("/workspace", t.Artifact), To: opts.RemoteMetadataStore.URL(RebuildAsset.For(t)).String()},
+		{From: "/workspace/closure.out", To: opts.RemoteMetadataStore.URL(DependencyClosureAsset.For(t)).String()},
+		{From: "/workspace/outputs.txt", To: opts.RemoteMetadataStore.URL(AdditionalOutputsAsset.For(t)).String()},
+		{From: "/workspace/sbom.spdx.json", To: opts.RemoteMetadataStore.URL(SBOMAsset.For(t)).String()},
 	}
+	// NOTE: Each additional output is stored as the artifact of its own Target
+	// so it can be compared against its upstream counterpart independently.
+	// The name is substituted by the upload script.
+	additionalOutput := Target{Ecosystem: t.Ecosystem, Package: t.Package, Version: t.Version, Artifact: "$name"}
+	var syscallPolicy string
 	if opts.UseSyscallMonitor {
+		packs, err := syscallPolicyPacks(t, opts)
+		if err != nil {
+			return nil, errors.Wrap(err, "selecting syscall policy packs")
+		}
+		syscallPolicy, err = TetragonPolicyJSON(packs)
+		if err != nil {
+			return nil, err
+		}
 		uploads = append(uploads, upload{From: "/workspace/tetragon.jsonl", To: opts.RemoteMetadataStore.URL(TetragonLogAsset.For(t)).String()})
 	}
-	if opts.UseNetworkProxy {
+	if platform == Windows {
+		// TODO: Support the network proxy and syscall monitor for Windows builds.
+		if opts.UseNetworkProxy || opts.UseSyscallMonitor {
+			return nil, errors.New("network proxy and syscall monitor are unsupported on windows")
+		}
+		if opts.WindowsWorkerPool == "" || opts.WindowsDockerHost == "" {
+			return nil, errors.New("windows builds require a worker pool and docker host")
+		}
+		err := windowsBuildTpl.Execute(&buildScript, map[string]any{
+			"Dockerfile": dockerfile,
+			"Hermetic":   opts.Hermetic,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "expanding windows build template")
+		}
+	} else if opts.UseNetworkProxy {
 		err := proxyBuildTpl.Execute(&buildScript, map[string]any{
 			"UtilPrebuildBucket": opts.UtilPrebuildBucket,
 			"Dockerfile":         dockerfile,
 			"UseSyscallMonitor":  opts.UseSyscallMonitor,
-			"SyscallPolicy":      tetragonPolicyJSON,
+			"Hermetic":           opts.Hermetic,
+			"SyscallPolicy":      syscallPolicy,
 			"HTTPPort":           "3128",
 			"TLSPort":            "3129",
 			"CtrlPort":           "3127",
@@ -390,7 +482,8 @@ func makeBuild(t Target, dockerfile string, opts RemoteOptions) (*cloudbuild.Bui
 		err := standardBuildTpl.Execute(&buildScript, map[string]any{
 			"Dockerfile":        dockerfile,
 			"UseSyscallMonitor": opts.UseSyscallMonitor,
-			"SyscallPolicy":     tetragonPolicyJSON,
+			"Hermetic":          opts.Hermetic,
+			"SyscallPolicy":     syscallPolicy,
 		})
 		if err != nil {
 			return nil, errors.Wrap(err, "expanding standard build template")
@@ -400,11 +493,12 @@ func makeBuild(t Target, dockerfile string, opts RemoteOptions) (*cloudbuild.Bui
 	err := assetUploadTpl.Execute(&assetUploadScript, map[string]any{
 		"UtilPrebuildBucket": opts.UtilPrebuildBucket,
 		"Uploads":            uploads,
+		"AdditionalOutputTo": opts.RemoteMetadataStore.URL(RebuildAsset.For(additionalOutput)).String(),
 	})
 	if err != nil {
 		return nil, errors.Wrap(err, "expanding asset upload template")
 	}
-	return &cloudbuild.Build{
+	build := &cloudbuild.Build{
 		LogsBucket:     opts.LogsBucket,
 		Options:        &cloudbuild.BuildOptions{Logging: "GCS_ONLY"},
 		ServiceAccount: opts.BuildServiceAccount,
@@ -417,16 +511,46 @@ func makeBuild(t Target, dockerfile string, opts RemoteOptions) (*cloudbuild.Bui
 				Name: "gcr.io/cloud-builders/docker",
 				Args: []string{"cp", "container:" + path.Join("/out", t.Artifact), path.Join("/workspace", t.Artifact)},
 			},
+			{
+				Name: "gcr.io/cloud-builders/docker",
+				// NOTE: Not all strategies report a dependency closure.
+				Script: "docker cp container:/out/closure.out /workspace/closure.out || touch /workspace/closure.out",
+			},
+			{
+				Name: "gcr.io/cloud-builders/docker",
+				// NOTE: Not all strategies declare additional outputs.
+				Script: "(docker cp container:/out/extra /workspace/extra || mkdir /workspace/extra) && ls /workspace/extra > /workspace/outputs.txt",
+			},
 			{
 				Name:   "gcr.io/cloud-builders/docker",
 				Script: "docker save img | gzip > /workspace/image.tgz",
 			},
+			{
+				Name: sbomImage,
+				// NOTE: The catalog covers the base image packages, installed
+				// toolchains, and prebuilt utilities present in the container.
+				Args: []string{"scan", "docker:img", "--output", "spdx-json=/workspace/sbom.spdx.json"},
+				// NOTE: The SBOM is informational so its failure does not fail the
+				// build. The upload step substitutes an empty placeholder.
+				AllowFailure: true,
+			},
 			{
 				Name:   "docker.io/library/alpine:3.19",
 				Script: assetUploadScript.String(),
 			},
 		},
-	}, nil
+	}
+	if platform == Windows {
+		// NOTE: The pool's workers execute the Linux step images and drive the
+		// Windows daemon remotely so the outputs are available to the upload step.
+		build.Options.Pool = &cloudbuild.PoolOption{Name: opts.WindowsWorkerPool}
+		for _, s := range build.Steps {
+			if s.Name == "gcr.io/cloud-builders/docker" || s.Name == sbomImage {
+				s.Env = append(s.Env, "DOCKER_HOST="+opts.WindowsDockerHost)
+			}
+		}
+	}
+	return build, nil
 }
 
 func doCloudBuild(ctx context.Context, client gcb.Client, build *cloudbuild.Build, opts RemoteOptions, bi *BuildInfo) error {
@@ -453,16 +577,23 @@ func doCloudBuild(ctx context.Context, client gcb.Client, build *cloudbuild.Buil
 }
 
 func MakeDockerfile(input Input, opts RemoteOptions) (string, error) {
+	dockerfile, _, err := makeDockerfile(input, opts)
+	return dockerfile, err
+}
+
+func makeDockerfile(input Input, opts RemoteOptions) (string, Instructions, error) {
 	env := BuildEnv{HasRepo: false, PreferPreciseToolchain: true}
 	if opts.UseTimewarp {
 		env.TimewarpHost = "localhost:8080"
 	}
 	instructions, err := input.Strategy.GenerateFor(input.Target, env)
 	if err != nil {
-		return "", errors.Wrap(err, "failed to generate strategy")
+		return "", Instructions{}, errors.Wrap(err, "failed to generate strategy")
 	}
 	dockerfile := new(bytes.Buffer)
-	if input.Target.Ecosystem == Debian {
+	if instructions.Platform == Windows {
+		err = windowsContainerTpl.Execute(dockerfile, newWindowsContainerArgs(instructions))
+	} else if input.Target.Ecosystem == Debian {
 		err = debuildContainerTpl.Execute(dockerfile, rebuildContainerArgs{
 			UseTimewarp:        opts.UseTimewarp,
 			UtilPrebuildBucket: opts.UtilPrebuildBucket,
@@ -476,19 +607,33 @@ func MakeDockerfile(input Input, opts RemoteOptions) (string, error) {
 		})
 	}
 	if err != nil {
-		return "", errors.Wrap(err, "populating template")
+		return "", Instructions{}, errors.Wrap(err, "populating template")
 	}
-	return dockerfile.String(), nil
+	return dockerfile.String(), instructions, nil
 }
 
 // RebuildRemote executes the given target strategy on a remote builder.
 func RebuildRemote(ctx context.Context, input Input, id string, opts RemoteOptions) error {
 	t := input.Target
-	bi := BuildInfo{Target: t, ID: id, Builder: os.Getenv("K_REVISION"), BuildStart: time.Now()}
-	dockerfile, err := MakeDockerfile(input, opts)
+	bi := BuildInfo{Target: t, ID: id, Builder: os.Getenv("K_REVISION"), BuildStart: time.Now(), Hermetic: opts.Hermetic}
+	if opts.UseSyscallMonitor {
+		packs, err := syscallPolicyPacks(t, opts)
+		if err != nil {
+			return errors.Wrap(err, "selecting syscall policy packs")
+		}
+		for _, p := range packs {
+			bi.SyscallPolicyPacks = append(bi.SyscallPolicyPacks, p.ID())
+		}
+	}
+	dockerfile, instructions, err := makeDockerfile(input, opts)
 	if err != nil {
 		return errors.Wrap(err, "creating dockerfile")
 	}
+	findings := Lint(instructions, dockerfile, LintOptions{Hermetic: opts.Hermetic})
+	if err := findings.Err(); err != nil {
+		return errors.Wrap(err, "linting build")
+	}
+	bi.LintFindings = findings
 	{
 		lw, err := opts.LocalMetadataStore.Writer(ctx, DockerfileAsset.For(t))
 		if err != nil {
@@ -504,11 +649,20 @@ func RebuildRemote(ctx context.Context, input Input, id string, opts RemoteOptio
 			return errors.Wrap(err, "writing Dockerfile")
 		}
 	}
-	build, err := makeBuild(t, dockerfile, opts)
+	build, err := makeBuild(t, dockerfile, instructions.Platform, opts)
 	if err != nil {
 		return errors.Wrap(err, "creating build")
 	}
-	buildErr := errors.Wrap(doCloudBuild(ctx, opts.GCBClient, build, opts, &bi), "performing build")
+	// NOTE: The trace context is attached to the build so its execution can be correlated with the request.
+	build.Tags = append(build.Tags, tracing.BuildTags(ctx)...)
+	// NOTE: The run is recorded so builds orphaned by a failed request can be identified.
+	if runID, ok := ctx.Value(RunID).(string); ok && runID != "" {
+		build.Tags = append(build.Tags, gcb.RunTag(runID), gcb.TargetTag(string(t.Ecosystem), t.Package, t.Version, t.Artifact))
+	}
+	buildCtx, span := tracing.Start(ctx, "gcb.build")
+	buildErr := errors.Wrap(doCloudBuild(buildCtx, opts.GCBClient, build, opts, &bi), "performing build")
+	span.SetAttributes(attribute.String("gcb.build_id", bi.BuildID))
+	tracing.End(span, buildErr)
 	// TODO: Maybe we should copy the GCB logs to the debug bucket to make them more accessible?
 	{
 		lw, err := opts.LocalMetadataStore.Writer(ctx, BuildInfoAsset.For(t))
@@ -527,3 +681,28 @@ func RebuildRemote(ctx context.Context, input Input, id string, opts RemoteOptio
 	}
 	return buildErr
 }
+
+// AdditionalOutputTargets returns the Targets of the additional outputs
+// collected by the remote rebuild of t. Each may be compared to upstream
+// using the RebuildAsset of its Target.
+func AdditionalOutputTargets(ctx context.Context, store AssetStore, t Target) ([]Target, error) {
+	r, err := store.Reader(ctx, AdditionalOutputsAsset.For(t))
+	if err != nil {
+		return nil, errors.Wrap(err, "opening additional outputs")
+	}
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading additional outputs")
+	}
+	var targets []Target
+	for _, name := range strings.Split(string(b), "\n") {
+		name = strings.TrimSpace(name)
+		// NOTE: The primary artifact may also be matched by the output patterns.
+		if name == "" || name == t.Artifact {
+			continue
+		}
+		targets = append(targets, Target{Ecosystem: t.Ecosystem, Package: t.Package, Version: t.Version, Artifact: name})
+	}
+	return targets, nil
+}
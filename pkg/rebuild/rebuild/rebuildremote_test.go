@@ -70,6 +70,113 @@ RUN cat <<'EOF' >/build
 EOF
 WORKDIR "/src"
 ENTRYPOINT ["/bin/sh","/build"]
+`,
+		},
+		{
+			name: "With Closure",
+			input: Input{
+				Target: Target{},
+				Strategy: &ManualStrategy{
+					Location:   Location{Repo: "github.com/example", Ref: "main", Dir: "/src"},
+					SystemDeps: []string{"git", "make"},
+					Deps:       "make deps ...",
+					Build:      "make build ...",
+					OutputPath: "output/foo.tgz",
+					Closure:    "cat deps.lock",
+				},
+			},
+			opts: RemoteOptions{
+				UseTimewarp: false,
+			},
+			expected: `#syntax=docker/dockerfile:1.4
+FROM docker.io/library/alpine:3.19
+RUN <<'EOF'
+ set -eux
+ apk add git make
+EOF
+RUN <<'EOF'
+ set -eux
+ mkdir /src && cd /src
+ git clone 'github.com/example' .
+ git checkout --force 'main'
+ make deps ...
+EOF
+RUN cat <<'EOF' >/build
+ set -eux
+ make build ...
+ mkdir /out && cp /src/output/foo.tgz /out/
+ (cat deps.lock) > /out/closure.out || true
+EOF
+WORKDIR "/src"
+ENTRYPOINT ["/bin/sh","/build"]
+`,
+		},
+		{
+			name: "With Additional Outputs",
+			input: Input{
+				Target: Target{},
+				Strategy: &ManualStrategy{
+					Location:          Location{Repo: "github.com/example", Ref: "main", Dir: "/src"},
+					SystemDeps:        []string{"git", "make"},
+					Deps:              "make deps ...",
+					Build:             "make build ...",
+					OutputPath:        "output/foo.tgz",
+					AdditionalOutputs: []string{"output/*.jar", "foo.buildinfo"},
+				},
+			},
+			opts: RemoteOptions{
+				UseTimewarp: false,
+			},
+			expected: `#syntax=docker/dockerfile:1.4
+FROM docker.io/library/alpine:3.19
+RUN <<'EOF'
+ set -eux
+ apk add git make
+EOF
+RUN <<'EOF'
+ set -eux
+ mkdir /src && cd /src
+ git clone 'github.com/example' .
+ git checkout --force 'main'
+ make deps ...
+EOF
+RUN cat <<'EOF' >/build
+ set -eux
+ make build ...
+ mkdir /out && cp /src/output/foo.tgz /out/
+ mkdir /out/extra && cp /src/output/*.jar /src/foo.buildinfo /out/extra/
+EOF
+WORKDIR "/src"
+ENTRYPOINT ["/bin/sh","/build"]
+`,
+		},
+		{
+			name: "Windows",
+			input: Input{
+				Target: Target{},
+				Strategy: &ManualStrategy{
+					Location:   Location{Repo: "github.com/example", Ref: "main", Dir: "/src"},
+					SystemDeps: []string{"visualstudio2022-workload-vctools"},
+					Deps:       "pip install build",
+					Build:      "python -m build --wheel",
+					OutputPath: "dist/foo-win_amd64.whl",
+					Closure:    "pip inspect",
+					Platform:   Windows,
+				},
+			},
+			expected: `FROM mcr.microsoft.com/dotnet/framework/sdk:4.8.1-windowsservercore-ltsc2022
+SHELL ["powershell", "-NoProfile", "-Command", "$ErrorActionPreference = 'Stop'; $ProgressPreference = 'SilentlyContinue';"]
+RUN Set-ExecutionPolicy Bypass -Scope Process -Force; [Net.ServicePointManager]::SecurityProtocol = [Net.SecurityProtocolType]::Tls12; iex ((New-Object Net.WebClient).DownloadString('https://community.chocolatey.org/install.ps1'))
+RUN choco install -y --no-progress git visualstudio2022-workload-vctools
+RUN [IO.File]::WriteAllBytes('C:/setup.ps1', [Convert]::FromBase64String('` + windowsScript("git clone 'github.com/example' .\ngit checkout --force 'main'", "pip install build") + `')); New-Item -ItemType Directory C:/src | Out-Null; Set-Location C:/src; & C:/setup.ps1
+RUN [IO.File]::WriteAllBytes('C:/build.ps1', [Convert]::FromBase64String('` + windowsScript(
+				"python -m build --wheel",
+				"New-Item -ItemType Directory C:/out | Out-Null",
+				"Copy-Item C:/src/dist/foo-win_amd64.whl C:/out/",
+				"try { [IO.File]::WriteAllText('C:/out/closure.out', ((& { pip inspect }) | Out-String)) } catch {}",
+			) + `'))
+WORKDIR C:/src
+ENTRYPOINT ["powershell", "-NoProfile", "-ExecutionPolicy", "Bypass", "-File", "C:/build.ps1"]
 `,
 		},
 		{
@@ -276,6 +383,7 @@ func TestMakeBuild(t *testing.T) {
 		name        string
 		target      Target
 		dockerfile  string
+		platform    Platform
 		opts        RemoteOptions
 		expected    *cloudbuild.Build
 		expectedErr bool
@@ -310,17 +418,177 @@ docker run --name=container img
 						Name: "gcr.io/cloud-builders/docker",
 						Args: []string{"cp", "container:/out/pkg-version.tgz", "/workspace/pkg-version.tgz"},
 					},
+					{
+						Name:   "gcr.io/cloud-builders/docker",
+						Script: "docker cp container:/out/closure.out /workspace/closure.out || touch /workspace/closure.out",
+					},
+					{
+						Name:   "gcr.io/cloud-builders/docker",
+						Script: "(docker cp container:/out/extra /workspace/extra || mkdir /workspace/extra) && ls /workspace/extra > /workspace/outputs.txt",
+					},
+					{
+						Name:   "gcr.io/cloud-builders/docker",
+						Script: "docker save img | gzip > /workspace/image.tgz",
+					},
+					{
+						Name:         "docker.io/anchore/syft:v1.18.1",
+						Args:         []string{"scan", "docker:img", "--output", "spdx-json=/workspace/sbom.spdx.json"},
+						AllowFailure: true,
+					},
+					{
+						Name: "docker.io/library/alpine:3.19",
+						Script: `set -eux
+wget https://test-bootstrap.storage.googleapis.com/gsutil_writeonly
+chmod +x gsutil_writeonly
+touch /workspace/sbom.spdx.json
+./gsutil_writeonly cp /workspace/image.tgz file:///npm/pkg/version/pkg-version.tgz/image.tgz
+./gsutil_writeonly cp /workspace/pkg-version.tgz file:///npm/pkg/version/pkg-version.tgz/pkg-version.tgz
+./gsutil_writeonly cp /workspace/closure.out file:///npm/pkg/version/pkg-version.tgz/closure.out
+./gsutil_writeonly cp /workspace/outputs.txt file:///npm/pkg/version/pkg-version.tgz/outputs.txt
+./gsutil_writeonly cp /workspace/sbom.spdx.json file:///npm/pkg/version/pkg-version.tgz/sbom.spdx.json
+while read -r name; do
+./gsutil_writeonly cp "/workspace/extra/$name" "file:///npm/pkg/version/$name/$name"
+done < /workspace/outputs.txt
+`,
+					},
+				},
+			},
+		},
+		{
+			name:       "windows build",
+			target:     Target{Ecosystem: NPM, Package: "pkg", Version: "version", Artifact: "pkg-version.tgz"},
+			dockerfile: "FROM mcr.microsoft.com/windows/servercore:ltsc2022",
+			platform:   Windows,
+			opts: RemoteOptions{
+				LogsBucket:          "test-logs-bucket",
+				BuildServiceAccount: "test-service-account",
+				UtilPrebuildBucket:  "test-bootstrap",
+				RemoteMetadataStore: NewFilesystemAssetStore(memfs.New()),
+				WindowsWorkerPool:   "projects/p/locations/l/workerPools/windows",
+				WindowsDockerHost:   "tcp://10.0.0.2:2375",
+			},
+			expected: &cloudbuild.Build{
+				LogsBucket:     "test-logs-bucket",
+				Options:        &cloudbuild.BuildOptions{Logging: "GCS_ONLY", Pool: &cloudbuild.PoolOption{Name: "projects/p/locations/l/workerPools/windows"}},
+				ServiceAccount: "test-service-account",
+				Steps: []*cloudbuild.BuildStep{
+					{
+						Name: "gcr.io/cloud-builders/docker",
+						Env:  []string{"DOCKER_HOST=tcp://10.0.0.2:2375"},
+						Script: `#!/usr/bin/env bash
+set -eux
+cat <<'EOS' | DOCKER_BUILDKIT=0 docker build --tag=img -
+FROM mcr.microsoft.com/windows/servercore:ltsc2022
+EOS
+docker run --name=container img
+`,
+					},
+					{
+						Name: "gcr.io/cloud-builders/docker",
+						Env:  []string{"DOCKER_HOST=tcp://10.0.0.2:2375"},
+						Args: []string{"cp", "container:/out/pkg-version.tgz", "/workspace/pkg-version.tgz"},
+					},
+					{
+						Name:   "gcr.io/cloud-builders/docker",
+						Env:    []string{"DOCKER_HOST=tcp://10.0.0.2:2375"},
+						Script: "docker cp container:/out/closure.out /workspace/closure.out || touch /workspace/closure.out",
+					},
+					{
+						Name:   "gcr.io/cloud-builders/docker",
+						Env:    []string{"DOCKER_HOST=tcp://10.0.0.2:2375"},
+						Script: "(docker cp container:/out/extra /workspace/extra || mkdir /workspace/extra) && ls /workspace/extra > /workspace/outputs.txt",
+					},
+					{
+						Name:   "gcr.io/cloud-builders/docker",
+						Env:    []string{"DOCKER_HOST=tcp://10.0.0.2:2375"},
+						Script: "docker save img | gzip > /workspace/image.tgz",
+					},
+					{
+						Name:         "docker.io/anchore/syft:v1.18.1",
+						Args:         []string{"scan", "docker:img", "--output", "spdx-json=/workspace/sbom.spdx.json"},
+						AllowFailure: true,
+						Env:          []string{"DOCKER_HOST=tcp://10.0.0.2:2375"},
+					},
+					{
+						Name: "docker.io/library/alpine:3.19",
+						Script: `set -eux
+wget https://test-bootstrap.storage.googleapis.com/gsutil_writeonly
+chmod +x gsutil_writeonly
+touch /workspace/sbom.spdx.json
+./gsutil_writeonly cp /workspace/image.tgz file:///npm/pkg/version/pkg-version.tgz/image.tgz
+./gsutil_writeonly cp /workspace/pkg-version.tgz file:///npm/pkg/version/pkg-version.tgz/pkg-version.tgz
+./gsutil_writeonly cp /workspace/closure.out file:///npm/pkg/version/pkg-version.tgz/closure.out
+./gsutil_writeonly cp /workspace/outputs.txt file:///npm/pkg/version/pkg-version.tgz/outputs.txt
+./gsutil_writeonly cp /workspace/sbom.spdx.json file:///npm/pkg/version/pkg-version.tgz/sbom.spdx.json
+while read -r name; do
+./gsutil_writeonly cp "/workspace/extra/$name" "file:///npm/pkg/version/$name/$name"
+done < /workspace/outputs.txt
+`,
+					},
+				},
+			},
+		},
+		{
+			name:       "hermetic standard build",
+			target:     Target{Ecosystem: NPM, Package: "pkg", Version: "version", Artifact: "pkg-version.tgz"},
+			dockerfile: "FROM docker.io/library/alpine:3.19",
+			opts: RemoteOptions{
+				LogsBucket:          "test-logs-bucket",
+				BuildServiceAccount: "test-service-account",
+				UtilPrebuildBucket:  "test-bootstrap",
+				RemoteMetadataStore: NewFilesystemAssetStore(memfs.New()),
+				Hermetic:            true,
+			},
+			expected: &cloudbuild.Build{
+				LogsBucket:     "test-logs-bucket",
+				Options:        &cloudbuild.BuildOptions{Logging: "GCS_ONLY"},
+				ServiceAccount: "test-service-account",
+				Steps: []*cloudbuild.BuildStep{
+					{
+						Name: "gcr.io/cloud-builders/docker",
+						Script: `#!/usr/bin/env bash
+set -eux
+cat <<'EOS' | docker buildx build --tag=img -
+FROM docker.io/library/alpine:3.19
+EOS
+docker run --network=none --name=container img
+`,
+					},
+					{
+						Name: "gcr.io/cloud-builders/docker",
+						Args: []string{"cp", "container:/out/pkg-version.tgz", "/workspace/pkg-version.tgz"},
+					},
+					{
+						Name:   "gcr.io/cloud-builders/docker",
+						Script: "docker cp container:/out/closure.out /workspace/closure.out || touch /workspace/closure.out",
+					},
+					{
+						Name:   "gcr.io/cloud-builders/docker",
+						Script: "(docker cp container:/out/extra /workspace/extra || mkdir /workspace/extra) && ls /workspace/extra > /workspace/outputs.txt",
+					},
 					{
 						Name:   "gcr.io/cloud-builders/docker",
 						Script: "docker save img | gzip > /workspace/image.tgz",
 					},
+					{
+						Name:         "docker.io/anchore/syft:v1.18.1",
+						Args:         []string{"scan", "docker:img", "--output", "spdx-json=/workspace/sbom.spdx.json"},
+						AllowFailure: true,
+					},
 					{
 						Name: "docker.io/library/alpine:3.19",
 						Script: `set -eux
 wget https://test-bootstrap.storage.googleapis.com/gsutil_writeonly
 chmod +x gsutil_writeonly
+touch /workspace/sbom.spdx.json
 ./gsutil_writeonly cp /workspace/image.tgz file:///npm/pkg/version/pkg-version.tgz/image.tgz
 ./gsutil_writeonly cp /workspace/pkg-version.tgz file:///npm/pkg/version/pkg-version.tgz/pkg-version.tgz
+./gsutil_writeonly cp /workspace/closure.out file:///npm/pkg/version/pkg-version.tgz/closure.out
+./gsutil_writeonly cp /workspace/outputs.txt file:///npm/pkg/version/pkg-version.tgz/outputs.txt
+./gsutil_writeonly cp /workspace/sbom.spdx.json file:///npm/pkg/version/pkg-version.tgz/sbom.spdx.json
+while read -r name; do
+./gsutil_writeonly cp "/workspace/extra/$name" "file:///npm/pkg/version/$name/$name"
+done < /workspace/outputs.txt
 `,
 					},
 				},
@@ -336,6 +604,7 @@ chmod +x gsutil_writeonly
 				UtilPrebuildBucket:  "test-bootstrap",
 				RemoteMetadataStore: NewFilesystemAssetStore(memfs.New()),
 				UseSyscallMonitor:   true,
+				SyscallPolicyPacks:  []string{"file-integrity"},
 			},
 			expected: &cloudbuild.Build{
 				LogsBucket:     "test-logs-bucket",
@@ -347,7 +616,7 @@ chmod +x gsutil_writeonly
 						Script: `#!/usr/bin/env bash
 set -eux
 touch /workspace/tetragon.jsonl
-echo '{"apiVersion":"cilium.io/v1alpha1","kind":"TracingPolicy","metadata":{"name":"process-and-memory"},"spec":{"kprobes":[{"args":[{"index":0,"type":"file"},{"index":1,"type":"int"}],"call":"security_file_permission","return":true,"returnArg":{"index":0,"type":"int"},"returnArgAction":"Post","syscall":false},{"args":[{"index":0,"type":"file"},{"index":1,"type":"uint64"},{"index":2,"type":"uint32"}],"call":"security_mmap_file","return":true,"returnArg":{"index":0,"type":"int"},"returnArgAction":"Post","syscall":false},{"args":[{"index":0,"type":"path"}],"call":"security_path_truncate","return":true,"returnArg":{"index":0,"type":"int"},"returnArgAction":"Post","syscall":false}]}}' > /workspace/tetragon_policy.yaml
+echo '{"apiVersion":"cilium.io/v1alpha1","kind":"TracingPolicy","metadata":{"name":"oss-rebuild"},"spec":{"kprobes":[{"args":[{"index":0,"type":"file"},{"index":1,"type":"int"}],"call":"security_file_permission","return":true,"returnArg":{"index":0,"type":"int"},"returnArgAction":"Post","syscall":false},{"args":[{"index":0,"type":"file"},{"index":1,"type":"uint64"},{"index":2,"type":"uint32"}],"call":"security_mmap_file","return":true,"returnArg":{"index":0,"type":"int"},"returnArgAction":"Post","syscall":false},{"args":[{"index":0,"type":"path"}],"call":"security_path_truncate","return":true,"returnArg":{"index":0,"type":"int"},"returnArgAction":"Post","syscall":false}]}}' > /workspace/tetragon_policy.yaml
 export TID=$(docker run --name=tetragon --detach --pid=host --cgroupns=host --privileged -v=/workspace/tetragon.jsonl:/workspace/tetragon.jsonl -v=/workspace/tetragon_policy.yaml:/workspace/tetragon_policy.yaml -v=/sys/kernel/btf/vmlinux:/var/lib/tetragon/btf quay.io/cilium/tetragon:v1.1.2 /usr/bin/tetragon --tracing-policy=/workspace/tetragon_policy.yaml --export-filename=/workspace/tetragon.jsonl)
 grep -q "Listening for events..." <(docker logs --follow $TID 2>&1) || (docker logs $TID && exit 1)
 cat <<'EOS' | docker buildx build --tag=img -
@@ -361,18 +630,38 @@ docker kill tetragon
 						Name: "gcr.io/cloud-builders/docker",
 						Args: []string{"cp", "container:/out/pkg-version.tgz", "/workspace/pkg-version.tgz"},
 					},
+					{
+						Name:   "gcr.io/cloud-builders/docker",
+						Script: "docker cp container:/out/closure.out /workspace/closure.out || touch /workspace/closure.out",
+					},
+					{
+						Name:   "gcr.io/cloud-builders/docker",
+						Script: "(docker cp container:/out/extra /workspace/extra || mkdir /workspace/extra) && ls /workspace/extra > /workspace/outputs.txt",
+					},
 					{
 						Name:   "gcr.io/cloud-builders/docker",
 						Script: "docker save img | gzip > /workspace/image.tgz",
 					},
+					{
+						Name:         "docker.io/anchore/syft:v1.18.1",
+						Args:         []string{"scan", "docker:img", "--output", "spdx-json=/workspace/sbom.spdx.json"},
+						AllowFailure: true,
+					},
 					{
 						Name: "docker.io/library/alpine:3.19",
 						Script: `set -eux
 wget https://test-bootstrap.storage.googleapis.com/gsutil_writeonly
 chmod +x gsutil_writeonly
+touch /workspace/sbom.spdx.json
 ./gsutil_writeonly cp /workspace/image.tgz file:///npm/pkg/version/pkg-version.tgz/image.tgz
 ./gsutil_writeonly cp /workspace/pkg-version.tgz file:///npm/pkg/version/pkg-version.tgz/pkg-version.tgz
+./gsutil_writeonly cp /workspace/closure.out file:///npm/pkg/version/pkg-version.tgz/closure.out
+./gsutil_writeonly cp /workspace/outputs.txt file:///npm/pkg/version/pkg-version.tgz/outputs.txt
+./gsutil_writeonly cp /workspace/sbom.spdx.json file:///npm/pkg/version/pkg-version.tgz/sbom.spdx.json
 ./gsutil_writeonly cp /workspace/tetragon.jsonl file:///npm/pkg/version/pkg-version.tgz/tetragon.jsonl
+while read -r name; do
+./gsutil_writeonly cp "/workspace/extra/$name" "file:///npm/pkg/version/$name/$name"
+done < /workspace/outputs.txt
 `,
 					},
 				},
@@ -429,12 +718,14 @@ docker exec --privileged build /bin/sh -euxc '
 '
 docker exec build /bin/sh -euxc '
 	curl http://proxy:3127/cert | tee /etc/ssl/certs/proxy.crt >> /etc/ssl/certs/ca-certificates.crt
+	curl -fX PUT "http://proxy:3127/phase?name=deps"
 	export DOCKER_HOST=tcp://proxy:3130 PROXYCERT=/etc/ssl/certs/proxy.crt
 	docker buildx create --name proxied --bootstrap --driver docker-container --driver-opt network=container:build
 	cat <<EOS | sed "s|^RUN|RUN --mount=type=bind,from=certs,dst=/etc/ssl/certs --mount=type=secret,id=PROXYCERT,env=PIP_CERT --mount=type=secret,id=PROXYCERT,env=CURL_CA_BUNDLE --mount=type=secret,id=PROXYCERT,env=NODE_EXTRA_CA_CERTS --mount=type=secret,id=PROXYCERT,env=CLOUDSDK_CORE_CUSTOM_CA_CERTS_FILE --mount=type=secret,id=PROXYCERT,env=NIX_SSL_CERT_FILE|" | \
 		docker buildx build --builder proxied --build-context certs=/etc/ssl/certs --secret id=PROXYCERT --load --tag=img -
 	FROM docker.io/library/alpine:3.19
 EOS
+	curl -fX PUT "http://proxy:3127/phase?name=build"
 	docker run --name=container img
 '
 curl http://proxy:3127/summary > /workspace/netlog.json
@@ -444,18 +735,38 @@ curl http://proxy:3127/summary > /workspace/netlog.json
 						Name: "gcr.io/cloud-builders/docker",
 						Args: []string{"cp", "container:/out/pkg-version.tgz", "/workspace/pkg-version.tgz"},
 					},
+					{
+						Name:   "gcr.io/cloud-builders/docker",
+						Script: "docker cp container:/out/closure.out /workspace/closure.out || touch /workspace/closure.out",
+					},
+					{
+						Name:   "gcr.io/cloud-builders/docker",
+						Script: "(docker cp container:/out/extra /workspace/extra || mkdir /workspace/extra) && ls /workspace/extra > /workspace/outputs.txt",
+					},
 					{
 						Name:   "gcr.io/cloud-builders/docker",
 						Script: "docker save img | gzip > /workspace/image.tgz",
 					},
+					{
+						Name:         "docker.io/anchore/syft:v1.18.1",
+						Args:         []string{"scan", "docker:img", "--output", "spdx-json=/workspace/sbom.spdx.json"},
+						AllowFailure: true,
+					},
 					{
 						Name: "docker.io/library/alpine:3.19",
 						Script: `set -eux
 wget https://test-bootstrap.storage.googleapis.com/gsutil_writeonly
 chmod +x gsutil_writeonly
+touch /workspace/sbom.spdx.json
 ./gsutil_writeonly cp /workspace/image.tgz file:///npm/pkg/version/pkg-version.tgz/image.tgz
 ./gsutil_writeonly cp /workspace/pkg-version.tgz file:///npm/pkg/version/pkg-version.tgz/pkg-version.tgz
+./gsutil_writeonly cp /workspace/closure.out file:///npm/pkg/version/pkg-version.tgz/closure.out
+./gsutil_writeonly cp /workspace/outputs.txt file:///npm/pkg/version/pkg-version.tgz/outputs.txt
+./gsutil_writeonly cp /workspace/sbom.spdx.json file:///npm/pkg/version/pkg-version.tgz/sbom.spdx.json
 ./gsutil_writeonly cp /workspace/netlog.json file:///npm/pkg/version/pkg-version.tgz/netlog.json
+while read -r name; do
+./gsutil_writeonly cp "/workspace/extra/$name" "file:///npm/pkg/version/$name/$name"
+done < /workspace/outputs.txt
 `,
 					},
 				},
@@ -464,7 +775,7 @@ chmod +x gsutil_writeonly
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			build, err := makeBuild(tc.target, tc.dockerfile, tc.opts)
+			build, err := makeBuild(tc.target, tc.dockerfile, tc.platform, tc.opts)
 			if (err != nil) != tc.expectedErr {
 				t.Errorf("Unexpected error: %v", err)
 			} else if diff := cmp.Diff(build, tc.expected); diff != "" {
@@ -480,3 +791,28 @@ func must[T any](t T, err error) T {
 	}
 	return t
 }
+
+func TestAdditionalOutputTargets(t *testing.T) {
+	ctx := context.Background()
+	target := Target{Ecosystem: Maven, Package: "org.example:lib", Version: "1.0.0", Artifact: "lib-1.0.0.jar"}
+	store := NewFilesystemAssetStore(memfs.New())
+	w, err := store.Writer(ctx, AdditionalOutputsAsset.For(target))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("lib-1.0.0-sources.jar\nlib-1.0.0.jar\nlib-1.0.0.pom\n")); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	got, err := AdditionalOutputTargets(ctx, store, target)
+	if err != nil {
+		t.Fatalf("AdditionalOutputTargets() error = %v", err)
+	}
+	want := []Target{
+		{Ecosystem: Maven, Package: "org.example:lib", Version: "1.0.0", Artifact: "lib-1.0.0-sources.jar"},
+		{Ecosystem: Maven, Package: "org.example:lib", Version: "1.0.0", Artifact: "lib-1.0.0.pom"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("AdditionalOutputTargets() mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -24,8 +24,10 @@ import (
 	"github.com/google/oss-rebuild/internal/httpx"
 	"github.com/google/oss-rebuild/pkg/registry/cratesio"
 	"github.com/google/oss-rebuild/pkg/registry/debian"
+	"github.com/google/oss-rebuild/pkg/registry/gomod"
 	"github.com/google/oss-rebuild/pkg/registry/npm"
 	"github.com/google/oss-rebuild/pkg/registry/pypi"
+	"github.com/google/oss-rebuild/pkg/registry/rubygems"
 )
 
 // RegistryMux offers a unified accessor for package registries.
@@ -34,6 +36,8 @@ type RegistryMux struct {
 	PyPI     pypi.Registry
 	CratesIO cratesio.Registry
 	Debian   debian.Registry
+	GoMod    gomod.Registry
+	RubyGems rubygems.Registry
 }
 
 // RegistryMuxWithCache returns a new RegistryMux with the provided cache wrapping each registry.
@@ -59,6 +63,16 @@ func RegistryMuxWithCache(registry RegistryMux, c cacheinternal.Cache) (Registry
 	} else {
 		return newmux, errors.New("unknown debian registry type")
 	}
+	if httpreg, ok := registry.GoMod.(gomod.HTTPRegistry); ok {
+		newmux.GoMod = gomod.HTTPRegistry{Client: httpx.NewCachedClient(httpreg.Client, c)}
+	} else {
+		return newmux, errors.New("unknown go module registry type")
+	}
+	if httpreg, ok := registry.RubyGems.(rubygems.HTTPRegistry); ok {
+		newmux.RubyGems = rubygems.HTTPRegistry{Client: httpx.NewCachedClient(httpreg.Client, c)}
+	} else {
+		return newmux, errors.New("unknown rubygems registry type")
+	}
 	return newmux, nil
 }
 
@@ -84,6 +98,12 @@ func warmCacheforArtifact(ctx context.Context, registry RegistryMux, t Target) {
 		}
 		registry.Debian.DSC(ctx, component, name, t.Version)
 		registry.Debian.Artifact(ctx, component, name, t.Artifact)
+	case GoMod:
+		registry.GoMod.Info(ctx, t.Package, t.Version)
+		registry.GoMod.Artifact(ctx, t.Package, t.Version)
+	case RubyGems:
+		registry.RubyGems.Version(ctx, t.Package, t.Version)
+		registry.RubyGems.Artifact(ctx, t.Package, t.Version)
 	}
 }
 
@@ -97,5 +117,9 @@ func warmCacheForPackage(ctx context.Context, registry RegistryMux, t Target) {
 		registry.CratesIO.Crate(ctx, t.Package)
 	case Debian:
 		// There is no Debian resource shared across versions.
+	case GoMod:
+		registry.GoMod.Versions(ctx, t.Package)
+	case RubyGems:
+		registry.RubyGems.Versions(ctx, t.Package)
 	}
 }
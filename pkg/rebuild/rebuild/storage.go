@@ -58,9 +58,17 @@ const (
 	ProxyNetlogAsset AssetType = "netlog.json"
 	// TetragonLogAsset is the log of all tetragon events.
 	TetragonLogAsset AssetType = "tetragon.jsonl"
+	// DependencyClosureAsset is the output of the strategy's dependency closure command.
+	DependencyClosureAsset AssetType = "closure.out"
+	// AdditionalOutputsAsset lists the file names of the additional outputs collected from the build.
+	AdditionalOutputsAsset AssetType = "outputs.txt"
+	// SBOMAsset is the SPDX SBOM describing the contents of the rebuild container.
+	SBOMAsset AssetType = "sbom.spdx.json"
 
 	// AttestationBundleAsset is the signed attestation bundle generated for a rebuild.
 	AttestationBundleAsset AssetType = "rebuild.intoto.jsonl"
+	// VEXAsset is the signed OpenVEX statement describing the rebuild result.
+	VEXAsset AssetType = "rebuild.openvex.intoto.json"
 
 	// BuildDef is the build definition, including strategy.
 	BuildDef AssetType = "build.yaml"
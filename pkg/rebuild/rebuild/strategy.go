@@ -36,6 +36,14 @@ type Location struct {
 	Dir  string `json:"dir" yaml:"dir,omitempty"`
 }
 
+// Platform is the operating system on which a rebuild is executed.
+type Platform string
+
+const (
+	Linux   Platform = "linux"
+	Windows Platform = "windows"
+)
+
 // Instructions represents the source, dependencies, and build steps to execute a rebuild.
 type Instructions struct {
 	// The location these instructions should be executed from.
@@ -46,6 +54,18 @@ type Instructions struct {
 	Build      string
 	// Where the generated artifact can be found.
 	OutputPath string
+	// AdditionalOutputs are glob patterns relative to the source root matching
+	// further artifacts produced by the build. Each match is collected as a
+	// separate artifact identified by its file name.
+	AdditionalOutputs []string
+	// SourceArchive is the archive from which sources were fetched, if not from Location.
+	SourceArchive *SourceArchive
+	// Closure, if provided, is a command run after the build that prints the
+	// build's resolved dependencies in the ecosystem's native format.
+	Closure string
+	// Platform is the platform on which to execute the build. Defaults to Linux.
+	// NOTE: Windows scripts are executed using PowerShell.
+	Platform Platform
 }
 
 // BuildEnv contains resources provided by the build environment that a strategy may use.
@@ -68,6 +88,14 @@ type Strategy interface {
 	GenerateFor(Target, BuildEnv) (Instructions, error)
 }
 
+// ToolchainVariant is implemented by Strategies that can be rebuilt with an
+// alternate version of their ecosystem's primary toolchain.
+type ToolchainVariant interface {
+	Strategy
+	// WithToolchain returns a copy of the strategy using the given toolchain version.
+	WithToolchain(version string) Strategy
+}
+
 // PopulateTemplate is a helper to execute a template string using a data object.
 func PopulateTemplate(tmpl string, data any) (string, error) {
 	tmpl = strings.TrimSpace(tmpl)
@@ -104,6 +132,36 @@ git checkout --force '{{.Ref}}'
 `, s)
 }
 
+// SourceArchiveCaveat identifies rebuilds from a SourceArchive.
+const SourceArchiveCaveat = "source-archive rebuild"
+
+// SourceArchive is a published archive of a package's sources (e.g. an sdist,
+// crate, or npm tarball) from which to rebuild in place of a git ref.
+//
+// NOTE: Source archives are published by the same party as the artifact so a
+// rebuild from one does not establish the artifact's correspondence with the
+// package's source repository.
+type SourceArchive struct {
+	// URL is the location of the gzipped tar archive.
+	URL string `json:"url" yaml:"url"`
+	// SHA256 is the hex-encoded digest of the archive.
+	SHA256 string `json:"sha256" yaml:"sha256"`
+}
+
+// SourceSetup provides the source setup script for a Location or, if
+// provided, a SourceArchive whose contents are extracted to the working dir.
+func SourceSetup(s Location, a *SourceArchive, env *BuildEnv) (string, error) {
+	if a == nil {
+		return BasicSourceSetup(s, env)
+	}
+	return PopulateTemplate(`
+wget -O /tmp/source.tar.gz '{{.URL}}'
+echo '{{.SHA256}}  /tmp/source.tar.gz' | sha256sum -c -
+tar xzf /tmp/source.tar.gz --strip-components=1
+rm /tmp/source.tar.gz
+`, a)
+}
+
 // ExecuteScript executes a single step of the strategy and returns the output regardless of error.
 func ExecuteScript(ctx context.Context, dir string, script string) (string, error) {
 	output := new(bytes.Buffer)
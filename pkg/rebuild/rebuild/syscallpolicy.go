@@ -0,0 +1,177 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rebuild
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// SyscallPolicyPack is a named, versioned set of Tetragon kprobes.
+type SyscallPolicyPack struct {
+	Name    string
+	Version string
+	// Kprobes is the YAML-encoded list of kprobe specs contributed to the TracingPolicy.
+	Kprobes string
+	kprobes []any
+}
+
+// ID returns the versioned identifier of the pack.
+func (p SyscallPolicyPack) ID() string {
+	return fmt.Sprintf("%s@%s", p.Name, p.Version)
+}
+
+// FileIntegrityPolicyPack traces file reads, writes, mmaps, and truncations.
+var FileIntegrityPolicyPack = &SyscallPolicyPack{
+	Name:    "file-integrity",
+	Version: "v1",
+	Kprobes: `
+- call: "security_file_permission"
+  syscall: false
+  return: true
+  args:
+  - index: 0
+    type: "file" # (struct file *) used for getting the path
+  - index: 1
+    type: "int" # 0x04 is MAY_READ, 0x02 is MAY_WRITE
+  returnArg:
+    index: 0
+    type: "int"
+  returnArgAction: "Post"
+- call: "security_mmap_file"
+  syscall: false
+  return: true
+  args:
+  - index: 0
+    type: "file" # (struct file *) used for getting the path
+  - index: 1
+    type: "uint64" # the prot flags PROT_READ(0x01), PROT_WRITE(0x02), PROT_EXEC(0x04)
+  - index: 2
+    type: "uint32" # the mmap flags (i.e. MAP_SHARED, ...)
+  returnArg:
+    index: 0
+    type: "int"
+  returnArgAction: "Post"
+- call: "security_path_truncate"
+  syscall: false
+  return: true
+  args:
+  - index: 0
+    type: "path" # (struct path *) used for getting the path
+  returnArg:
+    index: 0
+    type: "int"
+  returnArgAction: "Post"
+`,
+}
+
+// NetworkPolicyPack traces outbound TCP connections and socket teardown.
+var NetworkPolicyPack = &SyscallPolicyPack{
+	Name:    "network",
+	Version: "v1",
+	Kprobes: `
+- call: "tcp_connect"
+  syscall: false
+  args:
+  - index: 0
+    type: "sock"
+- call: "tcp_close"
+  syscall: false
+  args:
+  - index: 0
+    type: "sock"
+`,
+}
+
+// ExecTreePolicyPack traces program loads to reconstruct the process tree.
+//
+// NOTE: Tetragon emits process_exec and process_exit events by default. This
+// pack adds the binary credentials check to capture the loaded interpreter.
+var ExecTreePolicyPack = &SyscallPolicyPack{
+	Name:    "exec-tree",
+	Version: "v1",
+	Kprobes: `
+- call: "security_bprm_creds_for_exec"
+  syscall: false
+  args:
+  - index: 0
+    type: "linux_binprm" # (struct linux_binprm *) used for getting the binary path
+`,
+}
+
+// SyscallPolicyPacks are the available policy packs keyed by name.
+var SyscallPolicyPacks = map[string]*SyscallPolicyPack{
+	FileIntegrityPolicyPack.Name: FileIntegrityPolicyPack,
+	NetworkPolicyPack.Name:       NetworkPolicyPack,
+	ExecTreePolicyPack.Name:      ExecTreePolicyPack,
+}
+
+func init() {
+	for _, p := range SyscallPolicyPacks {
+		if err := yaml.Unmarshal([]byte(p.Kprobes), &p.kprobes); err != nil {
+			log.Fatalf("Malformed tetragon policy pack %s: %v", p.ID(), err)
+		}
+	}
+}
+
+// DefaultSyscallPolicyPacks returns the names of the policy packs to apply to an ecosystem when none are requested.
+func DefaultSyscallPolicyPacks(e Ecosystem) []string {
+	switch e {
+	case NPM, PyPI:
+		// Installs for these ecosystems commonly execute scripts that fetch from the network.
+		return []string{FileIntegrityPolicyPack.Name, NetworkPolicyPack.Name}
+	default:
+		return []string{FileIntegrityPolicyPack.Name}
+	}
+}
+
+// ResolveSyscallPolicyPacks looks up the named policy packs, ignoring duplicates.
+func ResolveSyscallPolicyPacks(names []string) ([]*SyscallPolicyPack, error) {
+	var packs []*SyscallPolicyPack
+	for _, name := range names {
+		p, ok := SyscallPolicyPacks[name]
+		if !ok {
+			return nil, errors.Errorf("unknown syscall policy pack: %s", name)
+		}
+		if !slices.Contains(packs, p) {
+			packs = append(packs, p)
+		}
+	}
+	return packs, nil
+}
+
+// TetragonPolicyJSON combines the provided packs into a single JSON-encoded TracingPolicy.
+func TetragonPolicyJSON(packs []*SyscallPolicyPack) (string, error) {
+	var kprobes []any
+	for _, p := range packs {
+		kprobes = append(kprobes, p.kprobes...)
+	}
+	policy := map[string]any{
+		"apiVersion": "cilium.io/v1alpha1",
+		"kind":       "TracingPolicy",
+		"metadata":   map[string]any{"name": "oss-rebuild"},
+		"spec":       map[string]any{"kprobes": kprobes},
+	}
+	b, err := json.Marshal(policy)
+	if err != nil {
+		return "", errors.Wrap(err, "converting tetragon policy to json")
+	}
+	return string(b), nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rebuild
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestResolveSyscallPolicyPacks(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		names   []string
+		want    []string
+		wantErr bool
+	}{
+		{name: "single", names: []string{"network"}, want: []string{"network@v1"}},
+		{name: "ordered", names: []string{"exec-tree", "file-integrity"}, want: []string{"exec-tree@v1", "file-integrity@v1"}},
+		{name: "duplicates", names: []string{"network", "network"}, want: []string{"network@v1"}},
+		{name: "unknown", names: []string{"network", "bogus"}, wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			packs, err := ResolveSyscallPolicyPacks(tc.names)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("ResolveSyscallPolicyPacks() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			var got []string
+			for _, p := range packs {
+				got = append(got, p.ID())
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("ResolveSyscallPolicyPacks() diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDefaultSyscallPolicyPacks(t *testing.T) {
+	if diff := cmp.Diff([]string{"file-integrity", "network"}, DefaultSyscallPolicyPacks(NPM)); diff != "" {
+		t.Errorf("DefaultSyscallPolicyPacks(NPM) diff (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"file-integrity"}, DefaultSyscallPolicyPacks(Debian)); diff != "" {
+		t.Errorf("DefaultSyscallPolicyPacks(Debian) diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestTetragonPolicyJSON(t *testing.T) {
+	got, err := TetragonPolicyJSON([]*SyscallPolicyPack{NetworkPolicyPack, ExecTreePolicyPack})
+	if err != nil {
+		t.Fatalf("TetragonPolicyJSON() error = %v", err)
+	}
+	var policy struct {
+		Kind string
+		Spec struct {
+			Kprobes []struct{ Call string }
+		}
+	}
+	if err := json.Unmarshal([]byte(got), &policy); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if policy.Kind != "TracingPolicy" {
+		t.Errorf("Kind = %q, want TracingPolicy", policy.Kind)
+	}
+	var calls []string
+	for _, k := range policy.Spec.Kprobes {
+		calls = append(calls, k.Call)
+	}
+	if diff := cmp.Diff([]string{"tcp_connect", "tcp_close", "security_bprm_creds_for_exec"}, calls); diff != "" {
+		t.Errorf("kprobe calls diff (-want +got):\n%s", diff)
+	}
+}
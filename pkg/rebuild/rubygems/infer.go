@@ -0,0 +1,239 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rubygems
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"log"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/storage"
+	"github.com/google/oss-rebuild/internal/uri"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	reg "github.com/google/oss-rebuild/pkg/registry/rubygems"
+	"github.com/pkg/errors"
+)
+
+// repoCandidates returns the URLs that may identify the gem's source repo, in order of preference.
+func repoCandidates(v *reg.GemVersion) []string {
+	var cands []string
+	for _, u := range []string{v.Metadata["source_code_uri"], v.SourceCodeURI, v.Metadata["homepage_uri"], v.HomepageURI} {
+		if u != "" {
+			cands = append(cands, u)
+		}
+	}
+	return cands
+}
+
+func (Rebuilder) InferRepo(ctx context.Context, t rebuild.Target, mux rebuild.RegistryMux) (string, error) {
+	vmeta, err := mux.RubyGems.Version(ctx, t.Package, t.Version)
+	if err != nil {
+		return "", err
+	}
+	for _, cand := range repoCandidates(vmeta) {
+		// NOTE: Homepages frequently point to documentation so only recognized
+		// repo hosts are accepted.
+		if repo := uri.FindCommonRepo(cand); repo != "" {
+			rebuild.RecordProvenance(ctx, "repo", rebuild.HeuristicRegistry)
+			return uri.CanonicalizeRepoURI(repo)
+		}
+	}
+	return "", errors.New("no repo found in gem metadata")
+}
+
+func (Rebuilder) CloneRepo(ctx context.Context, t rebuild.Target, repoURI string, fs billy.Filesystem, s storage.Storer) (r rebuild.RepoConfig, err error) {
+	r.URI = repoURI
+	r.Repository, err = rebuild.LoadRepo(ctx, t.Package, s, fs, git.CloneOptions{URL: r.URI, RecurseSubmodules: git.DefaultSubmoduleRecursionDepth})
+	switch err {
+	case nil:
+	case transport.ErrAuthenticationRequired:
+		err = errors.Errorf("Repo invalid or private")
+		return
+	default:
+		err = errors.Wrapf(err, "Clone failed [repo=%s]", r.URI)
+		return
+	}
+	r.Dir = "."
+	head, _ := r.Repository.Head()
+	if c, err := r.Repository.CommitObject(head.Hash()); err == nil {
+		if gemspec, err := findGemspec(c, t.Package); err != nil {
+			log.Printf("gemspec path heuristic failed [pkg=%s,repo=%s]: %s\n", t.Package, r.URI, err.Error())
+		} else {
+			r.Dir = path.Dir(gemspec)
+		}
+	}
+	r.RefMap = make(map[string]string)
+	return
+}
+
+// findGemspec returns the path of the gemspec for the named gem.
+//
+// A gemspec at the root of the repo is preferred over those in subdirectories.
+func findGemspec(c *object.Commit, name string) (string, error) {
+	tree, err := c.Tree()
+	if err != nil {
+		return "", err
+	}
+	want := name + ".gemspec"
+	if _, err := tree.File(want); err == nil {
+		return want, nil
+	} else if err != object.ErrFileNotFound {
+		return "", err
+	}
+	var matches []string
+	err = tree.Files().ForEach(func(f *object.File) error {
+		if path.Base(f.Name) == want {
+			matches = append(matches, f.Name)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(matches) == 0 {
+		return "", errors.Errorf("gemspec not found [name=%s]", want)
+	}
+	if len(matches) > 1 {
+		log.Printf("Multiple gemspec candidates [pkg=%s,ref=%s,matches=%v]\n", name, c.Hash.String(), matches)
+	}
+	return matches[0], nil
+}
+
+var rubygemsVersionRE = regexp.MustCompile(`(?m)^rubygems_version: (\S+)$`)
+
+// builtWith returns the RubyGems version recorded in the gem's metadata.
+func builtWith(gem io.Reader) (string, error) {
+	tr := tar.NewReader(gem)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return "", errors.New("metadata.gz not found")
+		} else if err != nil {
+			return "", err
+		}
+		if h.Name != "metadata.gz" {
+			continue
+		}
+		gzr, err := gzip.NewReader(tr)
+		if err != nil {
+			return "", errors.Wrap(err, "initializing metadata gzip reader")
+		}
+		b, err := io.ReadAll(gzr)
+		if err != nil {
+			return "", errors.Wrap(err, "reading metadata")
+		}
+		m := rubygemsVersionRE.FindSubmatch(b)
+		if m == nil {
+			return "", errors.New("rubygems_version not found")
+		}
+		return strings.Trim(string(m[1]), `"'`), nil
+	}
+}
+
+func inferRef(ctx context.Context, t rebuild.Target, rcfg *rebuild.RepoConfig) (ref, dir string, err error) {
+	tagGuess, err := rebuild.FindTagMatch(t.Package, t.Version, rcfg.Repository)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "[INTERNAL] tag heuristic error")
+	}
+	if tagGuess == "" {
+		return "", "", rebuild.ErrNoRef
+	}
+	c, err := rcfg.Repository.CommitObject(plumbing.NewHash(tagGuess))
+	if err == plumbing.ErrObjectNotFound {
+		log.Printf("tag heuristic ref not found in repo")
+		return "", "", rebuild.ErrNoValidRef
+	} else if err != nil {
+		return "", "", errors.Wrapf(err, "[INTERNAL] Failed ref resolve from tag [repo=%s,ref=%s]", rcfg.URI, tagGuess)
+	}
+	gemspec, err := findGemspec(c, t.Package)
+	if err != nil {
+		log.Printf("registry heuristic tag invalid: %v", err)
+		return "", "", rebuild.ErrNoValidRef
+	}
+	log.Printf("using tag heuristic ref: %s", tagGuess[:9])
+	rebuild.RecordProvenance(ctx, "ref", rebuild.HeuristicTag)
+	rebuild.RecordProvenance(ctx, "dir", "gemspec_search")
+	return tagGuess, path.Dir(gemspec), nil
+}
+
+func (Rebuilder) InferStrategy(ctx context.Context, t rebuild.Target, mux rebuild.RegistryMux, rcfg *rebuild.RepoConfig, hint rebuild.Strategy) (rebuild.Strategy, error) {
+	r, err := mux.RubyGems.Artifact(ctx, t.Package, t.Version)
+	if err != nil {
+		return nil, errors.Wrapf(err, "[INTERNAL] Failed to fetch upstream gem")
+	}
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrapf(err, "[INTERNAL] Failed to read upstream gem")
+	}
+	var ref, dir string
+	lh, ok := hint.(*rebuild.LocationHint)
+	if hint != nil && !ok {
+		return nil, errors.Errorf("unsupported hint type: %T", hint)
+	}
+	if lh != nil && lh.Ref != "" {
+		ref = lh.Ref
+		rebuild.RecordProvenance(ctx, "ref", rebuild.HeuristicHint)
+		if lh.Dir != "" {
+			dir = lh.Dir
+			rebuild.RecordProvenance(ctx, "dir", rebuild.HeuristicHint)
+		} else {
+			dir = rcfg.Dir
+			rebuild.RecordProvenance(ctx, "dir", "gemspec_search")
+		}
+	} else {
+		ref, dir, err = inferRef(ctx, t, rcfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	c, err := rcfg.Repository.CommitObject(plumbing.NewHash(ref))
+	if err != nil {
+		return nil, err
+	}
+	tree, _ := c.Tree()
+	gemspec := t.Package + ".gemspec"
+	if _, err := tree.File(path.Join(dir, gemspec)); err == object.ErrFileNotFound {
+		return nil, errors.Errorf("gemspec file not found [dir=%s]", dir)
+	} else if err != nil {
+		return nil, err
+	}
+	rubygemsVersion, err := builtWith(bytes.NewReader(b))
+	if err != nil {
+		log.Printf("rubygems version heuristic failed: %v", err)
+	} else {
+		rebuild.RecordProvenance(ctx, "rubygems_version", "gem_metadata")
+	}
+	return &GemBuild{
+		Location: rebuild.Location{
+			Repo: rcfg.URI,
+			Ref:  ref,
+			Dir:  dir,
+		},
+		Gemspec:         gemspec,
+		RubygemsVersion: rubygemsVersion,
+	}, nil
+}
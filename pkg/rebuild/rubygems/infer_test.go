@@ -0,0 +1,83 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rubygems
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/google/oss-rebuild/pkg/archive"
+	"github.com/google/oss-rebuild/pkg/archive/archivetest"
+)
+
+func TestFindGemspec(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   []string
+		want    string
+		wantErr bool
+	}{
+		{"root", []string{"foo.gemspec", "gems/foo/foo.gemspec"}, "foo.gemspec", false},
+		{"subdir", []string{"bar.gemspec", "gems/foo/foo.gemspec"}, "gems/foo/foo.gemspec", false},
+		{"missing", []string{"bar.gemspec"}, "", true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo, err := git.Init(memory.NewStorage(), memfs.New())
+			if err != nil {
+				t.Fatal(err)
+			}
+			wt, _ := repo.Worktree()
+			for _, f := range tc.files {
+				if err := util.WriteFile(wt.Filesystem, f, []byte("Gem::Specification.new"), 0644); err != nil {
+					t.Fatal(err)
+				}
+				wt.Add(f)
+			}
+			h, err := wt.Commit("gemspec", &git.CommitOptions{Author: &object.Signature{Name: "Test Author", Email: "test@example.com"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			c, _ := repo.CommitObject(h)
+			got, err := findGemspec(c, "foo")
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("findGemspec() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("findGemspec() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBuiltWith(t *testing.T) {
+	metadata := "--- !ruby/object:Gem::Specification\nname: foo\nrubygems_version: 3.5.3\nspecification_version: 4\n"
+	gem, err := archivetest.GemFile(metadata, []archive.TarEntry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := builtWith(bytes.NewReader(gem.Bytes()))
+	if err != nil {
+		t.Fatalf("builtWith() error = %v", err)
+	}
+	if got != "3.5.3" {
+		t.Errorf("builtWith() = %q, want %q", got, "3.5.3")
+	}
+}
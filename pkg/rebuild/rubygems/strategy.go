@@ -0,0 +1,69 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rubygems
+
+import (
+	"path"
+
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+)
+
+// GemBuild aggregates the options controlling a gem build.
+type GemBuild struct {
+	rebuild.Location
+	// Gemspec is the path of the gem specification relative to Location.Dir.
+	Gemspec string `json:"gemspec" yaml:"gemspec,omitempty"`
+	// RubygemsVersion is the RubyGems version with which to build. If empty,
+	// the system version is used.
+	RubygemsVersion string `json:"rubygems_version,omitempty" yaml:"rubygems_version,omitempty"`
+}
+
+var _ rebuild.Strategy = &GemBuild{}
+var _ rebuild.ToolchainVariant = &GemBuild{}
+
+// WithToolchain returns a copy of the build using the given RubyGems version.
+func (b *GemBuild) WithToolchain(version string) rebuild.Strategy {
+	c := *b
+	c.RubygemsVersion = version
+	return &c
+}
+
+// GenerateFor generates the instructions for a GemBuild.
+func (b *GemBuild) GenerateFor(t rebuild.Target, be rebuild.BuildEnv) (rebuild.Instructions, error) {
+	src, err := rebuild.BasicSourceSetup(b.Location, &be)
+	if err != nil {
+		return rebuild.Instructions{}, err
+	}
+	deps, err := rebuild.PopulateTemplate("{{if .RubygemsVersion}}gem update --system {{.RubygemsVersion}}{{end}}", b)
+	if err != nil {
+		return rebuild.Instructions{}, err
+	}
+	// NOTE: Gemspecs commonly enumerate their files relative to their own dir
+	// (e.g. using 'git ls-files') so the build must be run from there.
+	build, err := rebuild.PopulateTemplate(`
+(cd '{{.Location.Dir}}' && gem build '{{.Gemspec}}')
+`, b)
+	if err != nil {
+		return rebuild.Instructions{}, err
+	}
+	return rebuild.Instructions{
+		Location:   b.Location,
+		Source:     src,
+		Deps:       deps,
+		Build:      build,
+		SystemDeps: []string{"git", "ruby"},
+		OutputPath: path.Join(b.Location.Dir, t.Artifact),
+	}, nil
+}
@@ -0,0 +1,72 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rubygems
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+)
+
+func TestGemBuild(t *testing.T) {
+	loc := rebuild.Location{
+		Dir:  "the_dir",
+		Ref:  "the_ref",
+		Repo: "the_repo",
+	}
+	target := rebuild.Target{Ecosystem: rebuild.RubyGems, Package: "foo", Version: "1.2.3", Artifact: "foo-1.2.3.gem"}
+	tests := []struct {
+		name     string
+		strategy GemBuild
+		want     rebuild.Instructions
+	}{
+		{
+			name:     "System RubyGems",
+			strategy: GemBuild{Location: loc, Gemspec: "foo.gemspec"},
+			want: rebuild.Instructions{
+				Location:   loc,
+				Source:     "git checkout --force 'the_ref'",
+				Deps:       "",
+				Build:      "(cd 'the_dir' && gem build 'foo.gemspec')",
+				SystemDeps: []string{"git", "ruby"},
+				OutputPath: "the_dir/foo-1.2.3.gem",
+			},
+		},
+		{
+			name:     "Pinned RubyGems",
+			strategy: GemBuild{Location: loc, Gemspec: "foo.gemspec", RubygemsVersion: "3.5.3"},
+			want: rebuild.Instructions{
+				Location:   loc,
+				Source:     "git checkout --force 'the_ref'",
+				Deps:       "gem update --system 3.5.3",
+				Build:      "(cd 'the_dir' && gem build 'foo.gemspec')",
+				SystemDeps: []string{"git", "ruby"},
+				OutputPath: "the_dir/foo-1.2.3.gem",
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.strategy.GenerateFor(target, rebuild.BuildEnv{HasRepo: true})
+			if err != nil {
+				t.Fatalf("GenerateFor() error = %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("GenerateFor() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
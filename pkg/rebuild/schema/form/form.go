@@ -14,6 +14,20 @@ var (
 	ErrMissingRequired  = errors.New("missing required field")
 )
 
+// FieldError associates an error with the form field that caused it.
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Err.Error()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
 type fieldOptions struct {
 	name     string
 	required bool
@@ -92,7 +106,7 @@ func Unmarshal(v url.Values, out any) error {
 		urlval := v.Get(opt.name)
 		if urlval == "" {
 			if opt.required {
-				return ErrMissingRequired
+				return &FieldError{Field: opt.name, Err: ErrMissingRequired}
 			}
 			continue
 		}
@@ -108,7 +122,7 @@ func Unmarshal(v url.Values, out any) error {
 		default:
 			err := json.Unmarshal([]byte(urlval), value.Addr().Interface())
 			if err != nil {
-				return err
+				return &FieldError{Field: opt.name, Err: err}
 			}
 		}
 	}
@@ -17,13 +17,21 @@
 package schema
 
 import (
+	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/google/oss-rebuild/pkg/rebuild/cratesio"
 	"github.com/google/oss-rebuild/pkg/rebuild/debian"
+	"github.com/google/oss-rebuild/pkg/rebuild/gomod"
+	"github.com/google/oss-rebuild/pkg/rebuild/meta"
 	"github.com/google/oss-rebuild/pkg/rebuild/npm"
 	"github.com/google/oss-rebuild/pkg/rebuild/pypi"
 	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/pkg/rebuild/rubygems"
 	"github.com/pkg/errors"
 )
 
@@ -36,9 +44,14 @@ type StrategyOneOf struct {
 	NPMPackBuild         *npm.NPMPackBuild              `json:"npm_pack_build,omitempty" yaml:"npm_pack_build,omitempty"`
 	NPMCustomBuild       *npm.NPMCustomBuild            `json:"npm_custom_build,omitempty" yaml:"npm_custom_build,omitempty"`
 	CratesIOCargoPackage *cratesio.CratesIOCargoPackage `json:"cratesio_cargo_package,omitempty" yaml:"cratesio_cargo_package,omitempty"`
+	CargoDistBuild       *cratesio.CargoDistBuild       `json:"cratesio_cargo_dist_build,omitempty" yaml:"cratesio_cargo_dist_build,omitempty"`
 	DebianPackage        *debian.DebianPackage          `json:"debian_package,omitempty" yaml:"debian_package,omitempty"`
+	GoModZip             *gomod.GoModZip                `json:"gomod_zip,omitempty" yaml:"gomod_zip,omitempty"`
+	GemBuild             *rubygems.GemBuild             `json:"gem_build,omitempty" yaml:"gem_build,omitempty"`
 	ManualStrategy       *rebuild.ManualStrategy        `json:"manual,omitempty" yaml:"manual,omitempty"`
 	WorkflowStrategy     *rebuild.WorkflowStrategy      `json:"flow,omitempty" yaml:"flow,omitempty"`
+	// Provenance records the inference heuristic that produced each field of the strategy.
+	Provenance rebuild.FieldProvenance `json:"provenance,omitempty" yaml:"provenance,omitempty"`
 }
 
 // NewStrategyOneOf creates a StrategyOneOf from a rebuild.Strategy, using typecasting to put the strategy in the right place.
@@ -55,8 +68,14 @@ func NewStrategyOneOf(s rebuild.Strategy) StrategyOneOf {
 		oneof.NPMCustomBuild = t
 	case *cratesio.CratesIOCargoPackage:
 		oneof.CratesIOCargoPackage = t
+	case *cratesio.CargoDistBuild:
+		oneof.CargoDistBuild = t
 	case *debian.DebianPackage:
 		oneof.DebianPackage = t
+	case *gomod.GoModZip:
+		oneof.GoModZip = t
+	case *rubygems.GemBuild:
+		oneof.GemBuild = t
 	case *rebuild.ManualStrategy:
 		oneof.ManualStrategy = t
 	case *rebuild.WorkflowStrategy:
@@ -65,6 +84,20 @@ func NewStrategyOneOf(s rebuild.Strategy) StrategyOneOf {
 	return oneof
 }
 
+// Digest returns a short, stable identifier for the strategy.
+//
+// NOTE: Provenance is excluded since it describes how the strategy was
+// inferred rather than how the rebuild was executed.
+func (oneof StrategyOneOf) Digest() string {
+	oneof.Provenance = nil
+	b, err := json.Marshal(oneof)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:8])
+}
+
 // Strategy returns the strategy contained inside the oneof, or an error if the wrong number are present.
 func (oneof *StrategyOneOf) Strategy() (rebuild.Strategy, error) {
 	var num int
@@ -90,10 +123,22 @@ func (oneof *StrategyOneOf) Strategy() (rebuild.Strategy, error) {
 			num++
 			s = oneof.CratesIOCargoPackage
 		}
+		if oneof.CargoDistBuild != nil {
+			num++
+			s = oneof.CargoDistBuild
+		}
 		if oneof.DebianPackage != nil {
 			num++
 			s = oneof.DebianPackage
 		}
+		if oneof.GoModZip != nil {
+			num++
+			s = oneof.GoModZip
+		}
+		if oneof.GemBuild != nil {
+			num++
+			s = oneof.GemBuild
+		}
 		if oneof.ManualStrategy != nil {
 			num++
 			s = oneof.ManualStrategy
@@ -109,6 +154,30 @@ func (oneof *StrategyOneOf) Strategy() (rebuild.Strategy, error) {
 	return s, nil
 }
 
+// exclusive checks that no more than one strategy is set, naming each relative to field.
+func (oneof *StrategyOneOf) exclusive(field string) error {
+	if oneof == nil {
+		return nil
+	}
+	names := []string{"rebuild_location_hint", "pypi_pure_wheel_build", "npm_pack_build", "npm_custom_build", "cratesio_cargo_package", "cratesio_cargo_dist_build", "debian_package", "gomod_zip", "gem_build", "manual", "flow"}
+	for i, n := range names {
+		names[i] = field + "." + n
+	}
+	return Exclusive(names,
+		oneof.LocationHint != nil,
+		oneof.PureWheelBuild != nil,
+		oneof.NPMPackBuild != nil,
+		oneof.NPMCustomBuild != nil,
+		oneof.CratesIOCargoPackage != nil,
+		oneof.CargoDistBuild != nil,
+		oneof.DebianPackage != nil,
+		oneof.GoModZip != nil,
+		oneof.GemBuild != nil,
+		oneof.ManualStrategy != nil,
+		oneof.WorkflowStrategy != nil,
+	)
+}
+
 type Message interface {
 	Validate() error
 }
@@ -132,11 +201,20 @@ type SmoketestRequest struct {
 	Versions  []string          `form:",required"`
 	ID        string            `form:",required"`
 	Strategy  *StrategyOneOf    `form:""`
+	// ToolchainMatrix, if provided, lists alternate toolchain versions with
+	// which to additionally rebuild each version.
+	ToolchainMatrix []string `form:""`
 }
 
 var _ Message = SmoketestRequest{}
 
-func (SmoketestRequest) Validate() error { return nil }
+func (req SmoketestRequest) Validate() error {
+	return Validate(
+		Supports("ecosystem", req.Ecosystem, meta.Smoketest),
+		req.Strategy.exclusive("strategy"),
+		Check(req.Strategy == nil || len(req.Versions) == 1, "versions", "exactly one version required with strategy"),
+	)
+}
 
 // ToInputs converts a SmoketestRequest into rebuild.Input objects.
 func (req SmoketestRequest) ToInputs() ([]rebuild.Input, error) {
@@ -168,6 +246,21 @@ type Verdict struct {
 	Message       string
 	StrategyOneof StrategyOneOf
 	Timings       rebuild.Timings
+	// SecretFindings is the number of credentials redacted from the build and network logs.
+	SecretFindings int
+	// LogSummary describes the phases of the build logs.
+	LogSummary *rebuild.LogSummary
+	// Additional holds the verdicts for further artifacts produced by the same rebuild.
+	Additional []Verdict `json:",omitempty"`
+	// Matrix holds the outcome of each toolchain version attempted in matrix mode.
+	Matrix []MatrixVerdict `json:",omitempty"`
+}
+
+// MatrixVerdict is the outcome of a rebuild using an alternate toolchain version.
+type MatrixVerdict struct {
+	Toolchain string
+	// Message is empty when the rebuild achieved equivalence.
+	Message string
 }
 
 // SmoketestResponse is the result of a rebuild smoketest.
@@ -185,12 +278,158 @@ type RebuildPackageRequest struct {
 	ID                string            `form:",required"`
 	StrategyFromRepo  bool              `form:""`
 	UseSyscallMonitor bool              `form:""`
-	UseNetworkProxy   bool              `form:""`
+	// SyscallPolicyPacks selects the syscall monitor policy packs to apply.
+	// If empty, the ecosystem defaults are used.
+	SyscallPolicyPacks []string `form:""`
+	UseNetworkProxy    bool     `form:""`
+	// Hermetic executes the build phase without network access.
+	// Requires UseNetworkProxy through which the deps phase is fetched.
+	Hermetic bool `form:""`
+	// Callback, if provided, is the URL to which the completed Operation is POSTed.
+	// Only used by the async endpoint.
+	Callback string `form:""`
 }
 
 var _ Message = RebuildPackageRequest{}
 
-func (RebuildPackageRequest) Validate() error { return nil }
+func (req RebuildPackageRequest) Validate() error {
+	if err := Validate(
+		Supports("ecosystem", req.Ecosystem, meta.Attest),
+		Check(req.Ecosystem != rebuild.Debian || strings.TrimSpace(req.Artifact) != "", "artifact", "required for debian"),
+		Check(len(req.SyscallPolicyPacks) == 0 || req.UseSyscallMonitor, "syscallpolicypacks", "syscall policy packs require the syscall monitor"),
+		Check(!req.Hermetic || req.UseNetworkProxy, "hermetic", "hermetic builds require the network proxy"),
+		Check(req.Callback == "" || isHTTPSURL(req.Callback), "callback", "must be an https URL"),
+	); err != nil {
+		return err
+	}
+	if _, err := rebuild.ResolveSyscallPolicyPacks(req.SyscallPolicyPacks); err != nil {
+		return &ValidationError{Code: CodeInvalidValue, Field: "syscallpolicypacks", Message: err.Error()}
+	}
+	return nil
+}
+
+// Operation describes the state of a long-running request.
+type Operation struct {
+	ID      string   `json:"id" firestore:"id,omitempty"`
+	Done    bool     `json:"done" firestore:"done,omitempty"`
+	Verdict *Verdict `json:"verdict,omitempty" firestore:"verdict,omitempty"`
+	Error   string   `json:"error,omitempty" firestore:"error,omitempty"`
+	Created int64    `json:"created" firestore:"created,omitempty"`
+	Updated int64    `json:"updated" firestore:"updated,omitempty"`
+	// Deadline is the time, in Unix millis, after which an incomplete operation is abandoned.
+	Deadline int64 `json:"deadline" firestore:"deadline,omitempty"`
+}
+
+// GetOperationRequest is a request for the state of an Operation.
+type GetOperationRequest struct {
+	ID string `form:",required"`
+}
+
+var _ Message = GetOperationRequest{}
+
+func (GetOperationRequest) Validate() error { return nil }
+
+// PublishVerdictFeedRequest is a request to publish the verdict feed partition for a single day.
+type PublishVerdictFeedRequest struct {
+	// Date is the UTC day to publish in YYYY-MM-DD format.
+	Date string `form:",required"`
+}
+
+var _ Message = PublishVerdictFeedRequest{}
+
+func (req PublishVerdictFeedRequest) Validate() error {
+	d, err := time.Parse(time.DateOnly, req.Date)
+	if err != nil {
+		return &ValidationError{Code: CodeInvalidValue, Field: "date", Message: "must be formatted as YYYY-MM-DD"}
+	}
+	// NOTE: Only complete days are published to keep partitions immutable.
+	return Check(d.AddDate(0, 0, 1).Before(time.Now()), "date", "day has not yet completed")
+}
+
+// VerdictFeedPartition describes a published verdict feed partition.
+type VerdictFeedPartition struct {
+	Name      string
+	Count     int
+	Successes int
+}
+
+// CapabilitiesRequest is a request for the rebuild capabilities of each ecosystem.
+type CapabilitiesRequest struct {
+	// Ecosystem, if provided, limits the response to a single ecosystem.
+	Ecosystem rebuild.Ecosystem `form:""`
+}
+
+var _ Message = CapabilitiesRequest{}
+
+func (req CapabilitiesRequest) Validate() error {
+	if req.Ecosystem == "" {
+		return nil
+	}
+	return OneOf("ecosystem", req.Ecosystem, meta.Ecosystems()...)
+}
+
+// CapabilitiesResponse describes the rebuild capabilities of the requested ecosystems.
+type CapabilitiesResponse struct {
+	Ecosystems []meta.Capabilities
+}
+
+// PackageHistoryRequest is a request for the rebuild history of a single package across runs.
+type PackageHistoryRequest struct {
+	Ecosystem rebuild.Ecosystem `form:",required"`
+	Package   string            `form:",required"`
+	// Limit, if provided, bounds the number of entries returned.
+	Limit int `form:""`
+	// PageToken continues a previous request from the end of its last page.
+	PageToken string `form:""`
+}
+
+var _ Message = PackageHistoryRequest{}
+
+func (req PackageHistoryRequest) Validate() error {
+	return Validate(
+		OneOf("ecosystem", req.Ecosystem, meta.Ecosystems()...),
+		Required("package", req.Package),
+		Check(req.Limit >= 0, "limit", "must not be negative"),
+	)
+}
+
+// HistoryEntry is a single rebuild attempt within a package's history.
+type HistoryEntry struct {
+	Version         string
+	Artifact        string
+	RunID           string
+	ExecutorVersion string
+	Created         time.Time
+	Success         bool
+	Message         string
+	// StrategyDigest identifies the strategy used for the attempt.
+	StrategyDigest string
+	// StrategyChanged is set when the strategy differs from that of the
+	// previous attempt of the same artifact.
+	StrategyChanged bool
+	// VerdictChanged is set when the outcome differs from that of the previous
+	// attempt of the same artifact.
+	VerdictChanged bool
+	// AttestationURL locates the attestation bundle published by the attempt.
+	// It is only populated for the attempt whose bundle is currently published.
+	AttestationURL string `json:",omitempty"`
+	// Package is the previous name of the package under which the attempt was
+	// made. It is only populated for attempts made under an alias.
+	Package string `json:",omitempty"`
+}
+
+// PackageHistory is the chronological rebuild history of a package.
+type PackageHistory struct {
+	Ecosystem rebuild.Ecosystem
+	Package   string
+	// Entries are ordered from oldest to newest.
+	//
+	// NOTE: Change flags are computed against the entries of the same page so
+	// the oldest attempt of each artifact on a page never reports a change.
+	Entries []HistoryEntry
+	// NextPageToken is non-empty if older entries may be available.
+	NextPageToken string `json:",omitempty"`
+}
 
 // InferenceRequest is a single request to the inference endpoint.
 type InferenceRequest struct {
@@ -204,6 +443,12 @@ type InferenceRequest struct {
 var _ Message = InferenceRequest{}
 
 func (req InferenceRequest) Validate() error {
+	if err := Validate(
+		Supports("ecosystem", req.Ecosystem, meta.Infer),
+		req.StrategyHint.exclusive("strategyhint"),
+	); err != nil {
+		return err
+	}
 	if req.StrategyHint == nil {
 	} else if s, err := req.StrategyHint.Strategy(); err != nil {
 		return err
@@ -225,34 +470,59 @@ type CreateRunRequest struct {
 	BenchmarkName string `form:","`
 	BenchmarkHash string `form:","`
 	Type          string `form:","`
+	// ExternalID is a client-supplied identifier for the run. Requests sharing
+	// an ExternalID return the same Run.
+	ExternalID string `form:","`
+	// Labels are arbitrary key-value metadata associated with the run.
+	Labels map[string]string `form:","`
 }
 
 var _ Message = CreateRunRequest{}
 
+var labelKeyRE = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,62}$`)
+
+const maxLabelValueLen = 256
+
 // Validate parses the CreateRun form values into a CreateRunRequest.
 func (req CreateRunRequest) Validate() error {
 	if _, err := hex.DecodeString(req.BenchmarkHash); err != nil {
 		return errors.Wrap(err, "decoding hex hash")
 	}
+	for k, v := range req.Labels {
+		if !labelKeyRE.MatchString(k) {
+			return errors.Errorf("invalid label key: %q", k)
+		}
+		if len(v) > maxLabelValueLen {
+			return errors.Errorf("label value for %s exceeds %d characters", k, maxLabelValueLen)
+		}
+	}
 	return nil
 }
 
 // RebuildAttempt stores rebuild and execution metadata on a single smoketest run.
 type RebuildAttempt struct {
-	Ecosystem       string          `firestore:"ecosystem,omitempty"`
-	Package         string          `firestore:"package,omitempty"`
-	Version         string          `firestore:"version,omitempty"`
-	Artifact        string          `firestore:"artifact,omitempty"`
-	Success         bool            `firestore:"success,omitempty"`
-	Message         string          `firestore:"message,omitempty"`
-	Strategy        StrategyOneOf   `firestore:"strategyoneof,omitempty"`
-	Dockerfile      string          `firestore:"dockerfile,omitempty"`
-	Timings         rebuild.Timings `firestore:"timings,omitempty"`
-	ExecutorVersion string          `firestore:"executor_version,omitempty"`
-	RunID           string          `firestore:"run_id,omitempty"`
-	BuildID         string          `firestore:"build_id,omitempty"`
-	ObliviousID     string          `firestore:"oblivious_id,omitempty"`
-	Created         int64           `firestore:"created,omitempty"`
+	Ecosystem       string              `firestore:"ecosystem,omitempty"`
+	Package         string              `firestore:"package,omitempty"`
+	Version         string              `firestore:"version,omitempty"`
+	Artifact        string              `firestore:"artifact,omitempty"`
+	Success         bool                `firestore:"success,omitempty"`
+	Message         string              `firestore:"message,omitempty"`
+	Strategy        StrategyOneOf       `firestore:"strategyoneof,omitempty"`
+	Dockerfile      string              `firestore:"dockerfile,omitempty"`
+	Timings         rebuild.Timings     `firestore:"timings,omitempty"`
+	ExecutorVersion string              `firestore:"executor_version,omitempty"`
+	RunID           string              `firestore:"run_id,omitempty"`
+	BuildID         string              `firestore:"build_id,omitempty"`
+	ObliviousID     string              `firestore:"oblivious_id,omitempty"`
+	Created         int64               `firestore:"created,omitempty"`
+	SecretFindings  int                 `firestore:"secret_findings,omitempty"`
+	LogSummary      *rebuild.LogSummary `firestore:"log_summary,omitempty"`
+	// OverrideCaller is the identity of the caller that supplied the strategy in place of inference, if any.
+	OverrideCaller string `firestore:"override_caller,omitempty"`
+	// OverrideDigest is the digest of the caller-supplied strategy, if any.
+	OverrideDigest string `firestore:"override_digest,omitempty"`
+	// Cancelled is whether the request was abandoned by its caller before completion.
+	Cancelled bool `firestore:"cancelled,omitempty"`
 }
 
 // Run stores metadata on an execution grouping.
@@ -262,4 +532,7 @@ type Run struct {
 	BenchmarkHash string `firestore:"benchmark_hash,omitempty"`
 	Type          string `firestore:"run_type,omitempty"`
 	Created       int64  `firestore:"created,omitempty"`
+	// ExternalID is the client-supplied identifier for the run, if any.
+	ExternalID string            `firestore:"external_id,omitempty"`
+	Labels     map[string]string `firestore:"labels,omitempty"`
 }
@@ -151,6 +151,30 @@ cratesio_cargo_package:
     repo: the_repo
     ref: the_ref
     dir: the_dir
+`,
+	},
+	{
+		name: "CargoDistBuild",
+		strategy: &cratesio.CargoDistBuild{
+			Location: rebuild.Location{
+				Dir:  "the_dir",
+				Ref:  "the_ref",
+				Repo: "the_repo",
+			},
+			RustVersion:  "some_version",
+			DistVersion:  "0.28.0",
+			TargetTriple: "x86_64-unknown-linux-gnu",
+		},
+		jsonEncoded: `{"cratesio_cargo_dist_build":{"repo":"the_repo","ref":"the_ref","dir":"the_dir","rust_version":"some_version","dist_version":"0.28.0","target_triple":"x86_64-unknown-linux-gnu"}}`,
+		yamlEncoded: `
+cratesio_cargo_dist_build:
+  location:
+    repo: the_repo
+    ref: the_ref
+    dir: the_dir
+  rust_version: some_version
+  dist_version: 0.28.0
+  target_triple: x86_64-unknown-linux-gnu
 `,
 	},
 	{
@@ -381,6 +405,40 @@ func TestInferenceRequest_Validate(t *testing.T) {
 	}
 }
 
+func TestSmoketestRequest_Validate(t *testing.T) {
+	tests := []struct {
+		name string
+		req  SmoketestRequest
+		want error
+	}{
+		{
+			name: "valid request without strategy",
+			req:  SmoketestRequest{Ecosystem: rebuild.NPM, Package: "lodash", Versions: []string{"4.17.20", "4.17.21"}, ID: "run"},
+		},
+		{
+			name: "valid request with strategy",
+			req:  SmoketestRequest{Ecosystem: rebuild.NPM, Package: "lodash", Versions: []string{"4.17.21"}, ID: "run", Strategy: &StrategyOneOf{NPMPackBuild: &npm.NPMPackBuild{}}},
+		},
+		{
+			name: "conflicting strategies",
+			req:  SmoketestRequest{Ecosystem: rebuild.NPM, Package: "lodash", Versions: []string{"4.17.21"}, ID: "run", Strategy: &StrategyOneOf{NPMPackBuild: &npm.NPMPackBuild{}, NPMCustomBuild: &npm.NPMCustomBuild{}}},
+			want: &ValidationError{Code: CodeConflictingFields, Field: "strategy.npm_pack_build,strategy.npm_custom_build", Message: "fields are mutually exclusive"},
+		},
+		{
+			name: "strategy with multiple versions",
+			req:  SmoketestRequest{Ecosystem: rebuild.NPM, Package: "lodash", Versions: []string{"4.17.20", "4.17.21"}, ID: "run", Strategy: &StrategyOneOf{NPMPackBuild: &npm.NPMPackBuild{}}},
+			want: &ValidationError{Code: CodeInvalidValue, Field: "versions", Message: "exactly one version required with strategy"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, tt.req.Validate()); diff != "" {
+				t.Errorf("SmoketestRequest.Validate() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
 func TestInferenceRequest_LocationHint(t *testing.T) {
 	tests := []struct {
 		name   string
@@ -425,3 +483,105 @@ func TestInferenceRequest_LocationHint(t *testing.T) {
 		})
 	}
 }
+
+func TestRebuildPackageRequest_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     RebuildPackageRequest
+		wantErr bool
+	}{
+		{
+			name: "default policy packs",
+			req:  RebuildPackageRequest{Ecosystem: rebuild.NPM, Package: "lodash", Version: "4.17.21", ID: "id", UseSyscallMonitor: true},
+		},
+		{
+			name: "explicit policy packs",
+			req:  RebuildPackageRequest{Ecosystem: rebuild.NPM, Package: "lodash", Version: "4.17.21", ID: "id", UseSyscallMonitor: true, SyscallPolicyPacks: []string{"network", "exec-tree"}},
+		},
+		{
+			name:    "unknown policy pack",
+			req:     RebuildPackageRequest{Ecosystem: rebuild.NPM, Package: "lodash", Version: "4.17.21", ID: "id", UseSyscallMonitor: true, SyscallPolicyPacks: []string{"everything"}},
+			wantErr: true,
+		},
+		{
+			name:    "policy packs without monitor",
+			req:     RebuildPackageRequest{Ecosystem: rebuild.NPM, Package: "lodash", Version: "4.17.21", ID: "id", SyscallPolicyPacks: []string{"network"}},
+			wantErr: true,
+		},
+		{
+			name: "hermetic",
+			req:  RebuildPackageRequest{Ecosystem: rebuild.NPM, Package: "lodash", Version: "4.17.21", ID: "id", UseNetworkProxy: true, Hermetic: true},
+		},
+		{
+			name:    "hermetic without proxy",
+			req:     RebuildPackageRequest{Ecosystem: rebuild.NPM, Package: "lodash", Version: "4.17.21", ID: "id", Hermetic: true},
+			wantErr: true,
+		},
+		{
+			name:    "unsupported ecosystem",
+			req:     RebuildPackageRequest{Ecosystem: rebuild.Maven, Package: "junit:junit", Version: "4.13", ID: "id"},
+			wantErr: true,
+		},
+		{
+			name:    "debian without artifact",
+			req:     RebuildPackageRequest{Ecosystem: rebuild.Debian, Package: "main/xz-utils", Version: "5.4.1-0.2", ID: "id"},
+			wantErr: true,
+		},
+		{
+			name: "https callback",
+			req:  RebuildPackageRequest{Ecosystem: rebuild.NPM, Package: "lodash", Version: "4.17.21", ID: "id", Callback: "https://example.com/callback"},
+		},
+		{
+			name:    "http callback",
+			req:     RebuildPackageRequest{Ecosystem: rebuild.NPM, Package: "lodash", Version: "4.17.21", ID: "id", Callback: "http://metadata.google.internal/computeMetadata/v1/"},
+			wantErr: true,
+		},
+		{
+			name:    "callback without host",
+			req:     RebuildPackageRequest{Ecosystem: rebuild.NPM, Package: "lodash", Version: "4.17.21", ID: "id", Callback: "https:///callback"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.req.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("RebuildPackageRequest.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCreateRunRequest_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     CreateRunRequest
+		wantErr bool
+	}{
+		{
+			name: "labels",
+			req:  CreateRunRequest{BenchmarkHash: "abcd", ExternalID: "exp-1", Labels: map[string]string{"experiment": "timewarp", "prebuild_version": "v0.0.1"}},
+		},
+		{
+			name:    "invalid hash",
+			req:     CreateRunRequest{BenchmarkHash: "xyz"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid label key",
+			req:     CreateRunRequest{BenchmarkHash: "abcd", Labels: map[string]string{"Bad.Key": "v"}},
+			wantErr: true,
+		},
+		{
+			name:    "label value too long",
+			req:     CreateRunRequest{BenchmarkHash: "abcd", Labels: map[string]string{"k": strings.Repeat("v", 257)}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.req.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("CreateRunRequest.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/google/oss-rebuild/pkg/rebuild/meta"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+)
+
+// Validation error codes.
+const (
+	CodeMissingField      = "MISSING_FIELD"
+	CodeInvalidValue      = "INVALID_VALUE"
+	CodeConflictingFields = "CONFLICTING_FIELDS"
+	CodeMalformedRequest  = "MALFORMED_REQUEST"
+	CodeUnsupported       = "UNSUPPORTED"
+)
+
+// ValidationError is a machine-readable description of an invalid request.
+type ValidationError struct {
+	Code    string `json:"code"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e *ValidationError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("%s: %s", e.Code, e.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", e.Code, e.Field, e.Message)
+}
+
+// Validate returns the first failed check, if any.
+func Validate(checks ...error) error {
+	for _, err := range checks {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Required checks that the field is non-empty.
+func Required(field, value string) error {
+	if strings.TrimSpace(value) == "" {
+		return &ValidationError{Code: CodeMissingField, Field: field, Message: "required"}
+	}
+	return nil
+}
+
+// OneOf checks that the field's value is among those allowed.
+func OneOf[T ~string](field string, value T, allowed ...T) error {
+	if !slices.Contains(allowed, value) {
+		return &ValidationError{Code: CodeInvalidValue, Field: field, Message: fmt.Sprintf("unsupported value %q, want one of %v", value, allowed)}
+	}
+	return nil
+}
+
+// Supports checks that the field names a known ecosystem supporting the feature.
+func Supports(field string, e rebuild.Ecosystem, f meta.Feature) error {
+	if err := OneOf(field, e, meta.Ecosystems()...); err != nil {
+		return err
+	}
+	if err := meta.Require(e, f); err != nil {
+		return &ValidationError{Code: CodeUnsupported, Field: field, Message: err.Error()}
+	}
+	return nil
+}
+
+// Exclusive checks that no more than one of the named fields is set.
+func Exclusive(fields []string, set ...bool) error {
+	var found []string
+	for i, isSet := range set {
+		if isSet {
+			found = append(found, fields[i])
+		}
+	}
+	if len(found) > 1 {
+		return &ValidationError{Code: CodeConflictingFields, Field: strings.Join(found, ","), Message: "fields are mutually exclusive"}
+	}
+	return nil
+}
+
+// Check produces an invalid value error for field if cond is false.
+func Check(cond bool, field, message string) error {
+	if !cond {
+		return &ValidationError{Code: CodeInvalidValue, Field: field, Message: message}
+	}
+	return nil
+}
+
+// isHTTPSURL returns whether s is an absolute https URL.
+func isHTTPSURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && u.Scheme == "https" && u.Host != ""
+}
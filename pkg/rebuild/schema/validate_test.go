@@ -0,0 +1,77 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/pkg/rebuild/meta"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want *ValidationError
+	}{
+		{
+			name: "all pass",
+			err:  Validate(Required("package", "foo"), OneOf("ecosystem", rebuild.NPM, rebuild.NPM, rebuild.PyPI), Exclusive([]string{"a", "b"}, true, false)),
+		},
+		{
+			name: "missing",
+			err:  Validate(Required("package", " ")),
+			want: &ValidationError{Code: CodeMissingField, Field: "package", Message: "required"},
+		},
+		{
+			name: "not a member",
+			err:  Validate(OneOf("ecosystem", rebuild.Maven, rebuild.NPM)),
+			want: &ValidationError{Code: CodeInvalidValue, Field: "ecosystem", Message: `unsupported value "maven", want one of [npm]`},
+		},
+		{
+			name: "unsupported feature",
+			err:  Validate(Supports("ecosystem", rebuild.Maven, meta.Attest)),
+			want: &ValidationError{Code: CodeUnsupported, Field: "ecosystem", Message: "attest not yet supported for maven"},
+		},
+		{
+			name: "unknown ecosystem",
+			err:  Validate(Supports("ecosystem", "cran", meta.Attest)),
+			want: &ValidationError{Code: CodeInvalidValue, Field: "ecosystem", Message: `unsupported value "cran", want one of [npm pypi cratesio maven debian gomod rubygems]`},
+		},
+		{
+			name: "conflicting",
+			err:  Validate(Exclusive([]string{"a", "b", "c"}, true, false, true)),
+			want: &ValidationError{Code: CodeConflictingFields, Field: "a,c", Message: "fields are mutually exclusive"},
+		},
+		{
+			name: "first failure",
+			err:  Validate(nil, Check(false, "x", "bad"), Required("y", "")),
+			want: &ValidationError{Code: CodeInvalidValue, Field: "x", Message: "bad"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *ValidationError
+			if tt.err != nil {
+				got = tt.err.(*ValidationError)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Validate() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
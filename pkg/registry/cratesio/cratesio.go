@@ -50,6 +50,7 @@ type Version struct {
 	Version      string    `json:"num"`
 	RustVersion  string    `json:"rust_version"`
 	DownloadPath string    `json:"dl_path"`
+	Checksum     string    `json:"checksum"`
 	Created      time.Time `json:"created_at"`
 	Updated      time.Time `json:"updated_at"`
 	Yanked       bool      `json:"yanked"`
@@ -66,6 +67,8 @@ type Registry interface {
 	Crate(context.Context, string) (*Crate, error)
 	Version(context.Context, string, string) (*CrateVersion, error)
 	Artifact(context.Context, string, string) (io.ReadCloser, error)
+	DistRelease(context.Context, string, string) (*DistRelease, error)
+	DistArtifact(context.Context, string, string, string) (io.ReadCloser, error)
 }
 
 // HTTPRegistry is a Registry implementation that uses the crates.io HTTP API.
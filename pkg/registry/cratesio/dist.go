@@ -0,0 +1,151 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cratesio
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DistManifest is the dist-manifest.json published alongside a cargo-dist release.
+type DistManifest struct {
+	DistVersion     string                  `json:"dist_version"`
+	AnnouncementTag string                  `json:"announcement_tag"`
+	Artifacts       map[string]DistArtifact `json:"artifacts"`
+}
+
+// DistArtifact is an artifact described by a cargo-dist manifest.
+type DistArtifact struct {
+	Name          string   `json:"name"`
+	Kind          string   `json:"kind"`
+	TargetTriples []string `json:"target_triples"`
+}
+
+// DistExecutableArchive is the artifact kind of cargo-dist's prebuilt binary archives.
+const DistExecutableArchive = "executable-zip"
+
+// DistRelease is a GitHub release published by cargo-dist for a crate version.
+type DistRelease struct {
+	// Repo is the GitHub repository in "owner/name" form.
+	Repo     string
+	Tag      string
+	Manifest DistManifest
+}
+
+// distArchiveSuffixes are the archive formats cargo-dist uses for executable archives.
+var distArchiveSuffixes = []string{".tar.xz", ".tar.gz", ".zip"}
+
+// IsDistArtifact returns whether the artifact name refers to a cargo-dist
+// release artifact rather than the published crate.
+func IsDistArtifact(artifact string) bool {
+	if strings.HasSuffix(artifact, ".crate") {
+		return false
+	}
+	for _, suffix := range distArchiveSuffixes {
+		if strings.HasSuffix(artifact, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReleaseURL returns the download URL for an asset of a GitHub release.
+func ReleaseURL(repo, tag, asset string) string {
+	u := url.URL{Scheme: "https", Host: "github.com", Path: path.Join("/", repo, "releases", "download", tag, asset)}
+	return u.String()
+}
+
+// githubRepo returns the "owner/name" form of a GitHub repository URL.
+func githubRepo(repository string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(repository))
+	if err != nil {
+		return "", errors.Wrap(err, "parsing repository")
+	}
+	if u.Host != "github.com" && u.Host != "www.github.com" {
+		return "", errors.Errorf("unsupported release host: %s", u.Host)
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 2 {
+		return "", errors.Errorf("malformed GitHub repository: %s", repository)
+	}
+	return parts[0] + "/" + strings.TrimSuffix(parts[1], ".git"), nil
+}
+
+// distTags returns the release tags conventionally used by cargo-dist for a crate version.
+func distTags(pkg, version string) []string {
+	return []string{"v" + version, pkg + "-v" + version, version}
+}
+
+// DistRelease locates the cargo-dist release for the given crate version.
+func (r HTTPRegistry) DistRelease(ctx context.Context, pkg, version string) (*DistRelease, error) {
+	c, err := r.Crate(ctx, pkg)
+	if err != nil {
+		return nil, err
+	}
+	repo, err := githubRepo(c.Repository)
+	if err != nil {
+		return nil, err
+	}
+	for _, tag := range distTags(pkg, version) {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ReleaseURL(repo, tag, "dist-manifest.json"), nil)
+		resp, err := r.Client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusNotFound {
+			if resp.Body != nil {
+				resp.Body.Close()
+			}
+			continue
+		}
+		if resp.StatusCode != 200 {
+			return nil, errors.Errorf("fetching dist manifest: %s", resp.Status)
+		}
+		defer resp.Body.Close()
+		var m DistManifest
+		if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+			return nil, errors.Wrap(err, "decoding dist manifest")
+		}
+		return &DistRelease{Repo: repo, Tag: tag, Manifest: m}, nil
+	}
+	return nil, errors.Errorf("no cargo-dist release found for %s %s", pkg, version)
+}
+
+// DistArtifact provides a cargo-dist release artifact for a specific crate version.
+func (r HTTPRegistry) DistArtifact(ctx context.Context, pkg, version, artifact string) (io.ReadCloser, error) {
+	rel, err := r.DistRelease(ctx, pkg, version)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := rel.Manifest.Artifacts[artifact]; !ok {
+		return nil, errors.Errorf("artifact not in dist manifest: %s", artifact)
+	}
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ReleaseURL(rel.Repo, rel.Tag, artifact), nil)
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		return nil, errors.Errorf("fetching artifact: %s", resp.Status)
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,150 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cratesio
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/internal/httpx/httpxtest"
+)
+
+func TestHTTPRegistry_DistRelease(t *testing.T) {
+	crate := func() httpxtest.Call {
+		return httpxtest.Call{
+			URL: "https://crates.io/api/v1/crates/foo",
+			Response: &http.Response{
+				StatusCode: 200,
+				Body:       io.NopCloser(bytes.NewReader([]byte(`{"crate":{"id":"foo","repository":"https://github.com/bar/foo.git"},"versions":[]}`))),
+			},
+		}
+	}
+	manifest := `{
+		"dist_version": "0.28.0",
+		"announcement_tag": "foo-v1.2.3",
+		"artifacts": {
+			"foo-x86_64-unknown-linux-gnu.tar.xz": {
+				"name": "foo-x86_64-unknown-linux-gnu.tar.xz",
+				"kind": "executable-zip",
+				"target_triples": ["x86_64-unknown-linux-gnu"]
+			}
+		}
+	}`
+	testCases := []struct {
+		name        string
+		calls       []httpxtest.Call
+		expected    *DistRelease
+		expectedErr string
+	}{
+		{
+			name: "Version Tag",
+			calls: []httpxtest.Call{
+				crate(),
+				{URL: "https://github.com/bar/foo/releases/download/v1.2.3/dist-manifest.json", Response: &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewReader([]byte(manifest)))}},
+			},
+			expected: &DistRelease{
+				Repo: "bar/foo",
+				Tag:  "v1.2.3",
+				Manifest: DistManifest{
+					DistVersion:     "0.28.0",
+					AnnouncementTag: "foo-v1.2.3",
+					Artifacts: map[string]DistArtifact{
+						"foo-x86_64-unknown-linux-gnu.tar.xz": {Name: "foo-x86_64-unknown-linux-gnu.tar.xz", Kind: DistExecutableArchive, TargetTriples: []string{"x86_64-unknown-linux-gnu"}},
+					},
+				},
+			},
+		},
+		{
+			name: "Package Tag",
+			calls: []httpxtest.Call{
+				crate(),
+				{URL: "https://github.com/bar/foo/releases/download/v1.2.3/dist-manifest.json", Response: &http.Response{StatusCode: 404, Status: http.StatusText(404)}},
+				{URL: "https://github.com/bar/foo/releases/download/foo-v1.2.3/dist-manifest.json", Response: &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewReader([]byte(manifest)))}},
+			},
+			expected: &DistRelease{
+				Repo: "bar/foo",
+				Tag:  "foo-v1.2.3",
+				Manifest: DistManifest{
+					DistVersion:     "0.28.0",
+					AnnouncementTag: "foo-v1.2.3",
+					Artifacts: map[string]DistArtifact{
+						"foo-x86_64-unknown-linux-gnu.tar.xz": {Name: "foo-x86_64-unknown-linux-gnu.tar.xz", Kind: DistExecutableArchive, TargetTriples: []string{"x86_64-unknown-linux-gnu"}},
+					},
+				},
+			},
+		},
+		{
+			name: "No Release",
+			calls: []httpxtest.Call{
+				crate(),
+				{URL: "https://github.com/bar/foo/releases/download/v1.2.3/dist-manifest.json", Response: &http.Response{StatusCode: 404, Status: http.StatusText(404)}},
+				{URL: "https://github.com/bar/foo/releases/download/foo-v1.2.3/dist-manifest.json", Response: &http.Response{StatusCode: 404, Status: http.StatusText(404)}},
+				{URL: "https://github.com/bar/foo/releases/download/1.2.3/dist-manifest.json", Response: &http.Response{StatusCode: 404, Status: http.StatusText(404)}},
+			},
+			expectedErr: "no cargo-dist release found for foo 1.2.3",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockClient := &httpxtest.MockClient{
+				Calls: tc.calls,
+				URLValidator: func(expected, actual string) {
+					if diff := cmp.Diff(expected, actual); diff != "" {
+						t.Fatalf("URL mismatch (-want +got):\n%s", diff)
+					}
+				},
+			}
+			actual, err := HTTPRegistry{Client: mockClient}.DistRelease(context.Background(), "foo", "1.2.3")
+			if tc.expectedErr != "" {
+				if err == nil || err.Error() != tc.expectedErr {
+					t.Errorf("Error mismatch: got %v, want %v", err, tc.expectedErr)
+				}
+			} else if err != nil {
+				t.Fatalf("DistRelease() failed unexpectedly: %v", err)
+			}
+			if diff := cmp.Diff(tc.expected, actual); diff != "" {
+				t.Errorf("DistRelease mismatch (-want +got):\n%s", diff)
+			}
+			if mockClient.CallCount() != len(tc.calls) {
+				t.Errorf("Expected %d calls, got %d", len(tc.calls), mockClient.CallCount())
+			}
+		})
+	}
+}
+
+func TestIsDistArtifact(t *testing.T) {
+	tests := []struct {
+		artifact string
+		want     bool
+	}{
+		{"foo-0.1.0.crate", false},
+		{"foo-x86_64-unknown-linux-gnu.tar.xz", true},
+		{"foo-x86_64-unknown-linux-musl.tar.gz", true},
+		{"foo-x86_64-pc-windows-msvc.zip", true},
+		{"foo-installer.sh", false},
+		{"foo-x86_64-unknown-linux-gnu.tar.xz.sha256", false},
+		{"dist-manifest.json", false},
+		{"", false},
+	}
+	for _, test := range tests {
+		if got := IsDistArtifact(test.artifact); got != test.want {
+			t.Errorf("IsDistArtifact(%q) = %v, want %v", test.artifact, got, test.want)
+		}
+	}
+}
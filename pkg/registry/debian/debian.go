@@ -40,6 +40,41 @@ type DSC struct {
 	Stanzas []ControlStanza
 }
 
+// BinaryPackage is an entry in a source package's Package-List.
+type BinaryPackage struct {
+	Name string
+	// Type is the package type, either "deb" or "udeb".
+	Type string
+	// Arches are the architecture restrictions e.g. "any" or "all".
+	Arches []string
+}
+
+// PackageList returns the binary packages built from the source package.
+func (d *DSC) PackageList() ([]BinaryPackage, error) {
+	for _, stanza := range d.Stanzas {
+		values, ok := stanza.Fields["Package-List"]
+		if !ok {
+			continue
+		}
+		var pkgs []BinaryPackage
+		for _, value := range values {
+			elems := strings.Fields(value)
+			if len(elems) < 4 {
+				return nil, errors.Errorf("unexpected Package-List element: %s", value)
+			}
+			bp := BinaryPackage{Name: elems[0], Type: elems[1]}
+			for _, kv := range elems[4:] {
+				if arches, found := strings.CutPrefix(kv, "arch="); found {
+					bp.Arches = strings.Split(arches, ",")
+				}
+			}
+			pkgs = append(pkgs, bp)
+		}
+		return pkgs, nil
+	}
+	return nil, errors.New("no Package-List found in .dsc")
+}
+
 // Registry is a debian package registry.
 type Registry interface {
 	Artifact(context.Context, string, string, string) (io.ReadCloser, error)
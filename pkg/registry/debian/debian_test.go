@@ -17,6 +17,7 @@ package debian
 import (
 	"bytes"
 	"context"
+	"errors"
 	"io"
 	"net/http"
 	"testing"
@@ -320,6 +321,56 @@ RLpmHHG1JOVdOA==
 	}
 }
 
+func TestDSC_PackageList(t *testing.T) {
+	testCases := []struct {
+		name        string
+		dsc         *DSC
+		expected    []BinaryPackage
+		expectedErr error
+	}{
+		{
+			name: "Success",
+			dsc: &DSC{Stanzas: []ControlStanza{
+				{Fields: map[string][]string{"Hash": {"SHA256"}}},
+				{Fields: map[string][]string{
+					"Source": {"xz-utils"},
+					"Package-List": {
+						"liblzma-dev deb libdevel optional arch=any",
+						"liblzma-doc deb doc optional arch=all",
+						"xz-utils-udeb udeb debian-installer optional arch=linux-any,kfreebsd-any profile=!noudeb",
+					},
+				}},
+			}},
+			expected: []BinaryPackage{
+				{Name: "liblzma-dev", Type: "deb", Arches: []string{"any"}},
+				{Name: "liblzma-doc", Type: "deb", Arches: []string{"all"}},
+				{Name: "xz-utils-udeb", Type: "udeb", Arches: []string{"linux-any", "kfreebsd-any"}},
+			},
+		},
+		{
+			name:        "Missing",
+			dsc:         &DSC{Stanzas: []ControlStanza{{Fields: map[string][]string{"Source": {"xz-utils"}}}}},
+			expectedErr: errors.New("no Package-List found in .dsc"),
+		},
+		{
+			name:        "Malformed",
+			dsc:         &DSC{Stanzas: []ControlStanza{{Fields: map[string][]string{"Package-List": {"liblzma-dev deb"}}}}},
+			expectedErr: errors.New("unexpected Package-List element: liblzma-dev deb"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := tc.dsc.PackageList()
+			if (err == nil) != (tc.expectedErr == nil) || (err != nil && err.Error() != tc.expectedErr.Error()) {
+				t.Fatalf("Error mismatch: got %v, want %v", err, tc.expectedErr)
+			}
+			if diff := cmp.Diff(tc.expected, actual); diff != "" {
+				t.Errorf("PackageList mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
 func must[T any](t T, err error) T {
 	if err != nil {
 		panic(err)
@@ -0,0 +1,164 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gomod provides interfaces for interacting with the Go module proxy.
+package gomod
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/oss-rebuild/internal/httpx"
+	"github.com/google/oss-rebuild/internal/urlx"
+	"github.com/pkg/errors"
+	"golang.org/x/mod/module"
+)
+
+var proxyURL = urlx.MustParse("https://proxy.golang.org")
+
+// Origin describes the VCS source from which the proxy obtained a module version.
+type Origin struct {
+	VCS    string `json:"VCS"`
+	URL    string `json:"URL"`
+	Subdir string `json:"Subdir"`
+	Ref    string `json:"Ref"`
+	Hash   string `json:"Hash"`
+}
+
+// Info is the @v/<version>.info result.
+type Info struct {
+	Version string    `json:"Version"`
+	Time    time.Time `json:"Time"`
+	// Origin is only reported for versions fetched by recent proxy releases.
+	Origin *Origin `json:"Origin"`
+}
+
+// Registry is a Go module proxy.
+type Registry interface {
+	Versions(context.Context, string) ([]string, error)
+	Info(context.Context, string, string) (*Info, error)
+	GoMod(context.Context, string, string) ([]byte, error)
+	Artifact(context.Context, string, string) (io.ReadCloser, error)
+}
+
+// HTTPRegistry is a Registry implementation that uses the proxy.golang.org HTTP API.
+type HTTPRegistry struct {
+	Client httpx.BasicClient
+}
+
+// ArtifactURL returns the location of the module zip for the given module version.
+func ArtifactURL(mod, version string) (string, error) {
+	return endpoint(mod, version, ".zip")
+}
+
+// endpoint returns the URL of the proxy resource for a module version.
+//
+// NOTE: Module paths and versions are case-encoded by the proxy protocol
+// e.g. "github.com/Azure/sdk" is served from "github.com/!azure/sdk".
+func endpoint(mod, version, ext string) (string, error) {
+	escMod, err := module.EscapePath(mod)
+	if err != nil {
+		return "", errors.Wrap(err, "escaping module path")
+	}
+	if version == "" {
+		return proxyURL.JoinPath(escMod, "@v", "list").String(), nil
+	}
+	escVersion, err := module.EscapeVersion(version)
+	if err != nil {
+		return "", errors.Wrap(err, "escaping module version")
+	}
+	return proxyURL.JoinPath(escMod, "@v", escVersion+ext).String(), nil
+}
+
+func (r HTTPRegistry) get(ctx context.Context, u string) (io.ReadCloser, error) {
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		resp.Body.Close()
+		return nil, errors.Errorf("go module proxy error: %s", resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// Versions returns the tagged versions of the given module known to the proxy.
+func (r HTTPRegistry) Versions(ctx context.Context, mod string) ([]string, error) {
+	u, err := endpoint(mod, "", "")
+	if err != nil {
+		return nil, err
+	}
+	body, err := r.get(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	var versions []string
+	s := bufio.NewScanner(body)
+	for s.Scan() {
+		if v := strings.TrimSpace(s.Text()); v != "" {
+			versions = append(versions, v)
+		}
+	}
+	return versions, s.Err()
+}
+
+// Info returns the metadata for the given module version.
+func (r HTTPRegistry) Info(ctx context.Context, mod, version string) (*Info, error) {
+	u, err := endpoint(mod, version, ".info")
+	if err != nil {
+		return nil, err
+	}
+	body, err := r.get(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	var i Info
+	if err := json.NewDecoder(body).Decode(&i); err != nil {
+		return nil, err
+	}
+	return &i, nil
+}
+
+// GoMod returns the go.mod file for the given module version.
+func (r HTTPRegistry) GoMod(ctx context.Context, mod, version string) ([]byte, error) {
+	u, err := endpoint(mod, version, ".mod")
+	if err != nil {
+		return nil, err
+	}
+	body, err := r.get(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+// Artifact returns the module zip for the given module version.
+func (r HTTPRegistry) Artifact(ctx context.Context, mod, version string) (io.ReadCloser, error) {
+	u, err := ArtifactURL(mod, version)
+	if err != nil {
+		return nil, err
+	}
+	return r.get(ctx, u)
+}
+
+var _ Registry = &HTTPRegistry{}
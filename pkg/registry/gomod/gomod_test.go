@@ -0,0 +1,120 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gomod
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/internal/httpx/httpxtest"
+)
+
+func mockClient(t *testing.T, calls ...httpxtest.Call) *httpxtest.MockClient {
+	return &httpxtest.MockClient{
+		Calls: calls,
+		URLValidator: func(expected, actual string) {
+			if diff := cmp.Diff(expected, actual); diff != "" {
+				t.Fatalf("URL mismatch (-want +got):\n%s", diff)
+			}
+		},
+	}
+}
+
+func respond(status int, body string) *http.Response {
+	return &http.Response{StatusCode: status, Status: http.StatusText(status), Body: io.NopCloser(bytes.NewReader([]byte(body)))}
+}
+
+func TestHTTPRegistry_Versions(t *testing.T) {
+	client := mockClient(t, httpxtest.Call{
+		URL:      "https://proxy.golang.org/github.com/!burnt!sushi/toml/@v/list",
+		Response: respond(200, "v1.0.0\nv1.1.0\n"),
+	})
+	got, err := HTTPRegistry{Client: client}.Versions(context.Background(), "github.com/BurntSushi/toml")
+	if err != nil {
+		t.Fatalf("Versions() error = %v", err)
+	}
+	if diff := cmp.Diff([]string{"v1.0.0", "v1.1.0"}, got); diff != "" {
+		t.Errorf("Versions() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestHTTPRegistry_Info(t *testing.T) {
+	testCases := []struct {
+		name     string
+		version  string
+		call     httpxtest.Call
+		expected *Info
+		wantErr  bool
+	}{
+		{
+			name:    "With origin",
+			version: "v0.20.0",
+			call: httpxtest.Call{
+				URL:      "https://proxy.golang.org/golang.org/x/mod/@v/v0.20.0.info",
+				Response: respond(200, `{"Version":"v0.20.0","Time":"2024-08-05T15:26:11Z","Origin":{"VCS":"git","URL":"https://go.googlesource.com/mod","Ref":"refs/tags/v0.20.0","Hash":"3afcd3e4dd8c9ce3ad7a5e0b62ba0af0a3e1e4c3"}}`),
+			},
+			expected: &Info{
+				Version: "v0.20.0",
+				Time:    time.Date(2024, 8, 5, 15, 26, 11, 0, time.UTC),
+				Origin:  &Origin{VCS: "git", URL: "https://go.googlesource.com/mod", Ref: "refs/tags/v0.20.0", Hash: "3afcd3e4dd8c9ce3ad7a5e0b62ba0af0a3e1e4c3"},
+			},
+		},
+		{
+			name:    "Without origin",
+			version: "v0.1.0",
+			call: httpxtest.Call{
+				URL:      "https://proxy.golang.org/golang.org/x/mod/@v/v0.1.0.info",
+				Response: respond(200, `{"Version":"v0.1.0","Time":"2019-05-01T00:00:00Z"}`),
+			},
+			expected: &Info{Version: "v0.1.0", Time: time.Date(2019, 5, 1, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:    "Not found",
+			version: "v9.9.9",
+			call: httpxtest.Call{
+				URL:      "https://proxy.golang.org/golang.org/x/mod/@v/v9.9.9.info",
+				Response: respond(404, "not found"),
+			},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := mockClient(t, tc.call)
+			got, err := HTTPRegistry{Client: client}.Info(context.Background(), "golang.org/x/mod", tc.version)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Info() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.expected, got); diff != "" {
+				t.Errorf("Info() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestArtifactURL(t *testing.T) {
+	got, err := ArtifactURL("github.com/Azure/go-autorest", "v14.2.0+incompatible")
+	if err != nil {
+		t.Fatalf("ArtifactURL() error = %v", err)
+	}
+	if want := "https://proxy.golang.org/github.com/!azure/go-autorest/@v/v14.2.0+incompatible.zip"; got != want {
+		t.Errorf("ArtifactURL() = %q, want %q", got, want)
+	}
+}
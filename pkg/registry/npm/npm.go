@@ -20,10 +20,12 @@ import (
 	"io"
 	"net/http"
 	"net/url"
-	"path"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/google/oss-rebuild/internal/httpx"
+	"github.com/google/oss-rebuild/internal/semver"
 	"github.com/google/oss-rebuild/internal/urlx"
 	"github.com/pkg/errors"
 )
@@ -69,6 +71,19 @@ type NPMVersion struct {
 	RawRepository json.RawMessage `json:"repository"`
 	Repository
 	Scripts map[string]string `json:"scripts"`
+	// RawEngines is the runtime constraints declared by the package.
+	RawEngines json.RawMessage `json:"engines"`
+}
+
+// Engine returns the version range of the named engine declared by the package, if any.
+//
+// NOTE: Some early packages declare engines as a list of strings. These are ignored.
+func (v *NPMVersion) Engine(name string) string {
+	var engines map[string]string
+	if err := json.Unmarshal(v.RawEngines, &engines); err != nil {
+		return ""
+	}
+	return engines[name]
 }
 
 type PackageJSON struct {
@@ -77,13 +92,73 @@ type PackageJSON struct {
 	Scripts map[string]string `json:"scripts"`
 }
 
+// DistTagEvent records a version being assigned a dist-tag.
+type DistTagEvent struct {
+	Tag     string    `json:"tag"`
+	Version string    `json:"version"`
+	Time    time.Time `json:"time"`
+}
+
+// LatestHistory reconstructs the sequence of versions assigned the "latest" dist-tag.
+//
+// NOTE: The registry does not retain dist-tag history so it is approximated
+// from upload times, treating each stable release that exceeds all those
+// preceding it as tagged on publication. Releases to maintenance lines are
+// assumed to have been published under other tags.
+func (p *NPMPackage) LatestHistory() []DistTagEvent {
+	var versions []string
+	for v := range p.Versions {
+		if s, err := semver.New(v); err != nil || s.Prerelease != "" {
+			continue
+		}
+		if _, ok := p.UploadTimes[v]; ok {
+			versions = append(versions, v)
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return p.UploadTimes[versions[i]].Before(p.UploadTimes[versions[j]])
+	})
+	var history []DistTagEvent
+	for _, v := range versions {
+		if len(history) == 0 || semver.Cmp(v, history[len(history)-1].Version) > 0 {
+			history = append(history, DistTagEvent{Tag: "latest", Version: v, Time: p.UploadTimes[v]})
+		}
+	}
+	return history
+}
+
+// DistTagAt returns the version assigned tag at time t according to history.
+func DistTagAt(history []DistTagEvent, tag string, t time.Time) (string, bool) {
+	var version string
+	var found time.Time
+	for _, e := range history {
+		if e.Tag == tag && !e.Time.After(t) && !e.Time.Before(found) {
+			version, found = e.Version, e.Time
+		}
+	}
+	return version, version != ""
+}
+
 var registryURL = urlx.MustParse("https://registry.npmjs.org")
 
+// packageURL returns the registry URL for pkg with the given path elements appended.
+//
+// NOTE: The scope separator of a scoped package (e.g. "@scope/name") is
+// escaped so the registry resolves the name as a single path segment.
+func packageURL(pkg string, elems ...string) string {
+	segs := []string{url.PathEscape(pkg)}
+	for _, e := range elems {
+		segs = append(segs, url.PathEscape(e))
+	}
+	return registryURL.String() + "/" + strings.Join(segs, "/")
+}
+
 // Registry is an npm package registry.
 type Registry interface {
 	Package(context.Context, string) (*NPMPackage, error)
 	Version(context.Context, string, string) (*NPMVersion, error)
 	Artifact(context.Context, string, string) (io.ReadCloser, error)
+	DistTagHistory(context.Context, string) ([]DistTagEvent, error)
 }
 
 // HTTPRegistry is a Registry implementation that uses the npmjs.org HTTP API.
@@ -93,11 +168,7 @@ type HTTPRegistry struct {
 
 // Package returns the package metadata for the given package.
 func (r HTTPRegistry) Package(ctx context.Context, pkg string) (*NPMPackage, error) {
-	pathURL, err := url.Parse(path.Join("/", pkg))
-	if err != nil {
-		return nil, err
-	}
-	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, registryURL.ResolveReference(pathURL).String(), nil)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, packageURL(pkg), nil)
 	resp, err := r.Client.Do(req)
 	if err != nil {
 		return nil, err
@@ -126,11 +197,7 @@ func (r HTTPRegistry) Package(ctx context.Context, pkg string) (*NPMPackage, err
 
 // Version returns the package metadata for the given package version.
 func (r HTTPRegistry) Version(ctx context.Context, pkg, version string) (*NPMVersion, error) {
-	pathURL, err := url.Parse(path.Join("/", pkg, version))
-	if err != nil {
-		return nil, err
-	}
-	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, registryURL.ResolveReference(pathURL).String(), nil)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, packageURL(pkg, version), nil)
 	resp, err := r.Client.Do(req)
 	if err != nil {
 		return nil, err
@@ -171,4 +238,13 @@ func (r HTTPRegistry) Artifact(ctx context.Context, pkg, version string) (io.Rea
 	return resp.Body, nil
 }
 
+// DistTagHistory returns the reconstructed "latest" dist-tag history for the given package.
+func (r HTTPRegistry) DistTagHistory(ctx context.Context, pkg string) ([]DistTagEvent, error) {
+	p, err := r.Package(ctx, pkg)
+	if err != nil {
+		return nil, err
+	}
+	return p.LatestHistory(), nil
+}
+
 var _ Registry = &HTTPRegistry{}
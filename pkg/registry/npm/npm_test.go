@@ -17,13 +17,16 @@ package npm
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"io"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/oss-rebuild/internal/httpx/httpxtest"
+	"github.com/google/oss-rebuild/internal/semver"
 )
 
 func TestHTTPRegistry_Package(t *testing.T) {
@@ -98,6 +101,22 @@ func TestHTTPRegistry_Package(t *testing.T) {
 			},
 			expectedErr: errors.New("npm registry error: Not Found"),
 		},
+		{
+			name: "Scoped package",
+			pkg:  "@types/node",
+			call: httpxtest.Call{
+				URL: "https://registry.npmjs.org/@types%2Fnode",
+				Response: &http.Response{
+					StatusCode: 200,
+					Body:       io.NopCloser(bytes.NewReader([]byte(`{"name":"@types/node","dist-tags":{"latest":"20.0.0"},"versions":{"20.0.0":{"version":"20.0.0"}}}`))),
+				},
+			},
+			expected: &NPMPackage{
+				Name:     "@types/node",
+				DistTags: DistTags{Latest: "20.0.0"},
+				Versions: map[string]Release{"20.0.0": {Version: "20.0.0"}},
+			},
+		},
 		{
 			name: "JSON Decode Error",
 			pkg:  "bad-json-package",
@@ -164,6 +183,22 @@ func TestHTTPRegistry_Version(t *testing.T) {
 				Repository: Repository{Type: "git", URL: "https://github.com/expressjs/express"},
 			},
 		},
+		{
+			name:    "Scoped package",
+			pkg:     "@types/node",
+			version: "20.0.0",
+			call: httpxtest.Call{
+				URL: "https://registry.npmjs.org/@types%2Fnode/20.0.0",
+				Response: &http.Response{
+					StatusCode: 200,
+					Body:       io.NopCloser(bytes.NewReader([]byte(`{"name":"@types/node","version":"20.0.0"}`))),
+				},
+			},
+			expected: &NPMVersion{
+				Name:    "@types/node",
+				Version: "20.0.0",
+			},
+		},
 		{
 			name:    "Legacy repository format",
 			pkg:     "express",
@@ -350,3 +385,96 @@ func must[T any](t T, err error) T {
 	}
 	return t
 }
+
+func TestLatestHistory(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2020, 1, d, 0, 0, 0, 0, time.UTC) }
+	p := &NPMPackage{
+		Name: "npm",
+		Versions: map[string]Release{
+			"6.0.0":        {Version: "6.0.0"},
+			"6.1.0":        {Version: "6.1.0"},
+			"7.0.0-beta.1": {Version: "7.0.0-beta.1"},
+			"7.0.0":        {Version: "7.0.0"},
+			"6.14.0":       {Version: "6.14.0"},
+			"7.1.0":        {Version: "7.1.0"},
+		},
+		UploadTimes: map[string]time.Time{
+			"created":      day(1),
+			"6.0.0":        day(1),
+			"6.1.0":        day(2),
+			"7.0.0-beta.1": day(3),
+			"7.0.0":        day(4),
+			"6.14.0":       day(5),
+			"7.1.0":        day(6),
+		},
+	}
+	history := p.LatestHistory()
+	want := []DistTagEvent{
+		{Tag: "latest", Version: "6.0.0", Time: day(1)},
+		{Tag: "latest", Version: "6.1.0", Time: day(2)},
+		{Tag: "latest", Version: "7.0.0", Time: day(4)},
+		{Tag: "latest", Version: "7.1.0", Time: day(6)},
+	}
+	if diff := cmp.Diff(want, history); diff != "" {
+		t.Errorf("LatestHistory() mismatch (-want +got):\n%s", diff)
+	}
+	for _, tc := range []struct {
+		at     time.Time
+		want   string
+		wantOK bool
+	}{
+		{at: day(1).Add(-time.Hour), wantOK: false},
+		{at: day(3), want: "6.1.0", wantOK: true},
+		{at: day(5), want: "7.0.0", wantOK: true},
+		{at: day(7), want: "7.1.0", wantOK: true},
+	} {
+		got, ok := DistTagAt(history, "latest", tc.at)
+		if got != tc.want || ok != tc.wantOK {
+			t.Errorf("DistTagAt(%v) = %q, %v; want %q, %v", tc.at, got, ok, tc.want, tc.wantOK)
+		}
+	}
+}
+
+func TestKnownReleases(t *testing.T) {
+	rels, err := KnownReleases()
+	if err != nil {
+		t.Fatalf("KnownReleases() error = %v", err)
+	}
+	if len(rels.Node) == 0 || len(rels.NPM) == 0 {
+		t.Fatalf("KnownReleases() empty timeline: %d node, %d npm", len(rels.Node), len(rels.NPM))
+	}
+	for i, r := range rels.Node {
+		if _, err := semver.New(r.Version); err != nil || r.NPM == "" {
+			t.Errorf("invalid node release: %+v", r)
+		}
+		if i > 0 && r.Date.Before(rels.Node[i-1].Date) {
+			t.Errorf("node release out of order: %s", r.Version)
+		}
+	}
+	for i, e := range rels.NPM {
+		if e.Tag != "latest" {
+			t.Errorf("unexpected npm dist-tag: %+v", e)
+		}
+		if i > 0 && e.Time.Before(rels.NPM[i-1].Time) {
+			t.Errorf("npm release out of order: %s", e.Version)
+		}
+	}
+}
+
+func TestEngine(t *testing.T) {
+	testCases := []struct {
+		raw  string
+		want string
+	}{
+		{raw: `{"node": ">=14"}`, want: ">=14"},
+		{raw: `{"npm": ">=7"}`, want: ""},
+		{raw: `["node >= 0.4"]`, want: ""},
+		{raw: ``, want: ""},
+	}
+	for _, tc := range testCases {
+		v := &NPMVersion{RawEngines: json.RawMessage(tc.raw)}
+		if got := v.Engine("node"); got != tc.want {
+			t.Errorf("Engine(%q) = %q, want %q", tc.raw, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package npm
+
+import (
+	_ "embed"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+//go:generate go run ../../../tools/update_node_releases -output releases.json
+
+// NodeRelease is a release of the Node.js runtime.
+type NodeRelease struct {
+	Version string    `json:"version"`
+	Date    time.Time `json:"date"`
+	// NPM is the version of the npm CLI bundled with the release.
+	NPM string `json:"npm"`
+	LTS bool   `json:"lts"`
+}
+
+// ToolchainReleases is the release timeline of the node and npm toolchains.
+type ToolchainReleases struct {
+	// Node is ordered by release date.
+	Node []NodeRelease `json:"node"`
+	// NPM is the history of the npm CLI's "latest" dist-tag.
+	NPM []DistTagEvent `json:"npm"`
+}
+
+//go:embed releases.json
+var releasesJSON []byte
+
+var loadReleases = sync.OnceValues(func() (*ToolchainReleases, error) {
+	var r ToolchainReleases
+	if err := json.Unmarshal(releasesJSON, &r); err != nil {
+		return nil, errors.Wrap(err, "parsing releases.json")
+	}
+	return &r, nil
+})
+
+// KnownReleases returns the toolchain release timeline embedded at build time.
+//
+// NOTE: The timeline is refreshed by running `go generate` in this package.
+func KnownReleases() (*ToolchainReleases, error) {
+	return loadReleases()
+}
@@ -0,0 +1,132 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package rubygems provides interfaces for interacting with the rubygems.org API.
+package rubygems
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/oss-rebuild/internal/httpx"
+	"github.com/google/oss-rebuild/internal/urlx"
+	"github.com/pkg/errors"
+)
+
+var registryURL = urlx.MustParse("https://rubygems.org")
+
+// RubyPlatform is the platform of gems containing no native code.
+const RubyPlatform = "ruby"
+
+// Version is an entry of the /api/v1/versions/<name>.json result.
+type Version struct {
+	Number     string    `json:"number"`
+	Platform   string    `json:"platform"`
+	Prerelease bool      `json:"prerelease"`
+	SHA        string    `json:"sha"`
+	Created    time.Time `json:"created_at"`
+}
+
+// GemVersion is the /api/v2/rubygems/<name>/versions/<version>.json result.
+type GemVersion struct {
+	Name            string            `json:"name"`
+	Version         string            `json:"version"`
+	Platform        string            `json:"platform"`
+	SHA             string            `json:"sha"`
+	GemURI          string            `json:"gem_uri"`
+	SourceCodeURI   string            `json:"source_code_uri"`
+	HomepageURI     string            `json:"homepage_uri"`
+	Metadata        map[string]string `json:"metadata"`
+	RubyVersion     string            `json:"ruby_version"`
+	RubygemsVersion string            `json:"rubygems_version"`
+	Created         time.Time         `json:"created_at"`
+}
+
+// Registry is a RubyGems package registry.
+type Registry interface {
+	Versions(context.Context, string) ([]Version, error)
+	Version(context.Context, string, string) (*GemVersion, error)
+	Artifact(context.Context, string, string) (io.ReadCloser, error)
+}
+
+// HTTPRegistry is a Registry implementation that uses the rubygems.org HTTP API.
+type HTTPRegistry struct {
+	Client httpx.BasicClient
+}
+
+// ArtifactName returns the file name of the gem for the given version and platform.
+func ArtifactName(name, version, platform string) string {
+	if platform == "" || platform == RubyPlatform {
+		return fmt.Sprintf("%s-%s.gem", name, version)
+	}
+	return fmt.Sprintf("%s-%s-%s.gem", name, version, platform)
+}
+
+// ArtifactURL returns the location of the given gem file.
+func ArtifactURL(artifact string) string {
+	return registryURL.JoinPath("gems", artifact).String()
+}
+
+func (r HTTPRegistry) get(ctx context.Context, u string) (io.ReadCloser, error) {
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		resp.Body.Close()
+		return nil, errors.Errorf("rubygems registry error: %s", resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// Versions returns all versions of the given gem, most recent first.
+func (r HTTPRegistry) Versions(ctx context.Context, name string) ([]Version, error) {
+	body, err := r.get(ctx, registryURL.JoinPath("api/v1/versions", url.PathEscape(name)+".json").String())
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	var vs []Version
+	if err := json.NewDecoder(body).Decode(&vs); err != nil {
+		return nil, err
+	}
+	return vs, nil
+}
+
+// Version returns the metadata for the given version of a gem.
+func (r HTTPRegistry) Version(ctx context.Context, name, version string) (*GemVersion, error) {
+	body, err := r.get(ctx, registryURL.JoinPath("api/v2/rubygems", url.PathEscape(name), "versions", url.PathEscape(version)+".json").String())
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	var v GemVersion
+	if err := json.NewDecoder(body).Decode(&v); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// Artifact returns the pure-ruby gem for the given version.
+func (r HTTPRegistry) Artifact(ctx context.Context, name, version string) (io.ReadCloser, error) {
+	return r.get(ctx, ArtifactURL(ArtifactName(name, version, RubyPlatform)))
+}
+
+var _ Registry = &HTTPRegistry{}
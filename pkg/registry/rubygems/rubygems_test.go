@@ -0,0 +1,119 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rubygems
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/internal/httpx/httpxtest"
+)
+
+func mockClient(t *testing.T, calls ...httpxtest.Call) *httpxtest.MockClient {
+	return &httpxtest.MockClient{
+		Calls: calls,
+		URLValidator: func(expected, actual string) {
+			if diff := cmp.Diff(expected, actual); diff != "" {
+				t.Fatalf("URL mismatch (-want +got):\n%s", diff)
+			}
+		},
+	}
+}
+
+func respond(status int, body string) *http.Response {
+	return &http.Response{StatusCode: status, Status: http.StatusText(status), Body: io.NopCloser(bytes.NewReader([]byte(body)))}
+}
+
+func TestHTTPRegistry_Versions(t *testing.T) {
+	client := mockClient(t, httpxtest.Call{
+		URL:      "https://rubygems.org/api/v1/versions/rake.json",
+		Response: respond(200, `[{"number":"13.2.1","platform":"ruby","prerelease":false,"sha":"abc","created_at":"2024-04-05T22:53:18.414Z"}]`),
+	})
+	got, err := HTTPRegistry{Client: client}.Versions(context.Background(), "rake")
+	if err != nil {
+		t.Fatalf("Versions() error = %v", err)
+	}
+	want := []Version{{Number: "13.2.1", Platform: "ruby", SHA: "abc", Created: time.Date(2024, 4, 5, 22, 53, 18, 414000000, time.UTC)}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Versions() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestHTTPRegistry_Version(t *testing.T) {
+	testCases := []struct {
+		name     string
+		call     httpxtest.Call
+		expected *GemVersion
+		wantErr  bool
+	}{
+		{
+			name: "Success",
+			call: httpxtest.Call{
+				URL:      "https://rubygems.org/api/v2/rubygems/rake/versions/13.2.1.json",
+				Response: respond(200, `{"name":"rake","version":"13.2.1","platform":"ruby","sha":"abc","gem_uri":"https://rubygems.org/gems/rake-13.2.1.gem","source_code_uri":"https://github.com/ruby/rake/tree/v13.2.1","metadata":{"source_code_uri":"https://github.com/ruby/rake/tree/v13.2.1"},"rubygems_version":"3.5.3","created_at":"2024-04-05T22:53:18.414Z"}`),
+			},
+			expected: &GemVersion{
+				Name:            "rake",
+				Version:         "13.2.1",
+				Platform:        "ruby",
+				SHA:             "abc",
+				GemURI:          "https://rubygems.org/gems/rake-13.2.1.gem",
+				SourceCodeURI:   "https://github.com/ruby/rake/tree/v13.2.1",
+				Metadata:        map[string]string{"source_code_uri": "https://github.com/ruby/rake/tree/v13.2.1"},
+				RubygemsVersion: "3.5.3",
+				Created:         time.Date(2024, 4, 5, 22, 53, 18, 414000000, time.UTC),
+			},
+		},
+		{
+			name: "Not found",
+			call: httpxtest.Call{
+				URL:      "https://rubygems.org/api/v2/rubygems/rake/versions/13.2.1.json",
+				Response: respond(404, "This version could not be found."),
+			},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := HTTPRegistry{Client: mockClient(t, tc.call)}.Version(context.Background(), "rake", "13.2.1")
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Version() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.expected, got); diff != "" {
+				t.Errorf("Version() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestArtifactName(t *testing.T) {
+	for _, tc := range []struct {
+		platform string
+		want     string
+	}{
+		{"ruby", "nokogiri-1.16.0.gem"},
+		{"", "nokogiri-1.16.0.gem"},
+		{"x86_64-linux", "nokogiri-1.16.0-x86_64-linux.gem"},
+	} {
+		if got := ArtifactName("nokogiri", "1.16.0", tc.platform); got != tc.want {
+			t.Errorf("ArtifactName(%q) = %q, want %q", tc.platform, got, tc.want)
+		}
+	}
+}
@@ -45,6 +45,22 @@ func (ps *PackageSet) Hash(h hash.Hash) []byte {
 type Metadata struct {
 	Count   int
 	Updated time.Time
+	// Provenance describes how the PackageSet was constructed.
+	// Combined sets retain the provenance of each of their inputs.
+	Provenance []Provenance `json:",omitempty"`
+}
+
+// Provenance describes the generation of a PackageSet from its data sources.
+type Provenance struct {
+	// Generator is the name of the generator that produced the set.
+	Generator string
+	// ToolVersion identifies the build of the generating tool.
+	ToolVersion string `json:",omitempty"`
+	// Parameters are the inputs used to select packages (e.g. query limits).
+	Parameters map[string]string `json:",omitempty"`
+	// Snapshots are the points in time at which each data source was read, keyed by source.
+	Snapshots map[string]time.Time `json:",omitempty"`
+	Generated time.Time
 }
 
 // Package corresponds to one or more versions of a package to rebuild.
@@ -63,4 +79,16 @@ type Package struct {
 	Name      string
 	Versions  []string
 	Artifacts []string
+	// Digests are the hex-encoded SHA256 digests of the upstream artifacts at generation time.
+	// When present, they correspond to Versions by index.
+	Digests []string `json:",omitempty"`
+}
+
+// Digest returns the upstream artifact digest recorded for the version, if any.
+func (p Package) Digest(version string) (string, bool) {
+	i := slices.Index(p.Versions, version)
+	if i == -1 || i >= len(p.Digests) || p.Digests[i] == "" {
+		return "", false
+	}
+	return p.Digests[i], true
 }
@@ -0,0 +1,36 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package benchmark
+
+import "testing"
+
+func TestPackageDigest(t *testing.T) {
+	p := Package{Ecosystem: "cratesio", Name: "serde", Versions: []string{"1.0.2", "1.0.1", "1.0.0"}, Digests: []string{"aaaa", ""}}
+	for _, tc := range []struct {
+		version string
+		want    string
+		wantOK  bool
+	}{
+		{"1.0.2", "aaaa", true},
+		{"1.0.1", "", false},
+		{"1.0.0", "", false},
+		{"0.9.0", "", false},
+	} {
+		got, ok := p.Digest(tc.version)
+		if got != tc.want || ok != tc.wantOK {
+			t.Errorf("Digest(%q) = %q, %v, want %q, %v", tc.version, got, ok, tc.want, tc.wantOK)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package benchmark
+
+import (
+	"slices"
+
+	"github.com/google/oss-rebuild/pkg/rebuild/alias"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+)
+
+// Combine merges the provided PackageSets, deduplicating package versions.
+//
+// Package order is preserved from the first set in which each package appears
+// and the provenance of all inputs is retained.
+func Combine(sets ...PackageSet) PackageSet {
+	ps := PackageSet{Metadata: mergeMetadata(sets)}
+	idx := make(map[[2]string]int)
+	for _, s := range sets {
+		for _, p := range s.Packages {
+			key := [2]string{p.Ecosystem, p.Name}
+			i, ok := idx[key]
+			if !ok {
+				idx[key] = len(ps.Packages)
+				ps.Packages = append(ps.Packages, Package{Ecosystem: p.Ecosystem, Name: p.Name})
+				i = len(ps.Packages) - 1
+			}
+			existing := &ps.Packages[i]
+			for vi, v := range p.Versions {
+				if slices.Contains(existing.Versions, v) {
+					continue
+				}
+				appendVersion(existing, p, vi)
+			}
+		}
+	}
+	ps.Count = countVersions(ps)
+	return ps
+}
+
+// Subtract returns the package versions of base that are absent from all other sets.
+//
+// Package order is preserved from base and the provenance of all inputs is retained.
+func Subtract(base PackageSet, sets ...PackageSet) PackageSet {
+	excluded := make(map[[3]string]bool)
+	for _, s := range sets {
+		for k := range versionKeys(s) {
+			excluded[k] = true
+		}
+	}
+	ps := filterVersions(base, func(k [3]string) bool { return !excluded[k] })
+	ps.Metadata = mergeMetadata(append([]PackageSet{base}, sets...))
+	ps.Count = countVersions(ps)
+	return ps
+}
+
+// Intersect returns the package versions present in every provided set.
+//
+// Package order is preserved from the first set and the provenance of all inputs is retained.
+func Intersect(sets ...PackageSet) PackageSet {
+	if len(sets) == 0 {
+		return PackageSet{}
+	}
+	var others []map[[3]string]bool
+	for _, s := range sets[1:] {
+		others = append(others, versionKeys(s))
+	}
+	ps := filterVersions(sets[0], func(k [3]string) bool {
+		for _, o := range others {
+			if !o[k] {
+				return false
+			}
+		}
+		return true
+	})
+	ps.Metadata = mergeMetadata(sets)
+	ps.Count = countVersions(ps)
+	return ps
+}
+
+// Canonicalize deduplicates the versions of aliased packages.
+//
+// A version published under both a package's canonical name and a previous
+// name is retained only under the canonical name. Versions published only
+// under a previous name retain that name since no such version exists under
+// the canonical name upstream.
+func Canonicalize(ps PackageSet, t *alias.Table) PackageSet {
+	published := versionKeys(ps)
+	seen := make(map[[3]string]bool)
+	out := filterVersions(ps, func(k [3]string) bool {
+		canonical := [3]string{k[0], t.Canonical(rebuild.Ecosystem(k[0]), k[1]), k[2]}
+		if canonical != k && published[canonical] {
+			return false
+		}
+		if seen[canonical] {
+			return false
+		}
+		seen[canonical] = true
+		return true
+	})
+	out.Metadata = ps.Metadata
+	return Combine(out)
+}
+
+// mergeMetadata returns the latest update time and all provenance of the provided sets.
+func mergeMetadata(sets []PackageSet) Metadata {
+	var md Metadata
+	for _, s := range sets {
+		if s.Updated.After(md.Updated) {
+			md.Updated = s.Updated
+		}
+		md.Provenance = append(md.Provenance, s.Provenance...)
+	}
+	return md
+}
+
+func versionKeys(ps PackageSet) map[[3]string]bool {
+	keys := make(map[[3]string]bool)
+	for _, p := range ps.Packages {
+		for _, v := range p.Versions {
+			keys[[3]string{p.Ecosystem, p.Name, v}] = true
+		}
+	}
+	return keys
+}
+
+// filterVersions returns the packages of ps with only the versions for which keep returns true.
+//
+// Packages with no remaining versions are omitted.
+func filterVersions(ps PackageSet, keep func([3]string) bool) PackageSet {
+	var out PackageSet
+	for _, p := range ps.Packages {
+		filtered := Package{Ecosystem: p.Ecosystem, Name: p.Name}
+		for vi, v := range p.Versions {
+			if !keep([3]string{p.Ecosystem, p.Name, v}) {
+				continue
+			}
+			appendVersion(&filtered, p, vi)
+		}
+		if len(filtered.Versions) > 0 {
+			out.Packages = append(out.Packages, filtered)
+		}
+	}
+	return out
+}
+
+// appendVersion appends the version of p at index vi to dst.
+//
+// NOTE: Artifacts and Digests, when present, correspond to Versions by index
+// so versions lacking a value are padded with "" once any version has one.
+func appendVersion(dst *Package, p Package, vi int) {
+	n := len(dst.Versions)
+	dst.Versions = append(dst.Versions, p.Versions[vi])
+	dst.Artifacts = appendAligned(dst.Artifacts, n, p.Artifacts, vi)
+	dst.Digests = appendAligned(dst.Digests, n, p.Digests, vi)
+}
+
+// appendAligned appends src[i] to dst at index n, padding dst with "" as required.
+func appendAligned(dst []string, n int, src []string, i int) []string {
+	var v string
+	if i < len(src) {
+		v = src[i]
+	}
+	if v == "" {
+		if len(dst) > 0 {
+			dst = padTo(dst, n+1)
+		}
+		return dst
+	}
+	return append(padTo(dst, n), v)
+}
+
+func padTo(s []string, n int) []string {
+	for len(s) < n {
+		s = append(s, "")
+	}
+	return s
+}
+
+func countVersions(ps PackageSet) int {
+	var n int
+	for _, p := range ps.Packages {
+		n += len(p.Versions)
+	}
+	return n
+}
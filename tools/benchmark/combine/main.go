@@ -0,0 +1,98 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package main combines multiple rebuild benchmark files into one.
+//
+// By default, the union of the benchmarks is produced. The --subtract and
+// --intersect modes instead produce the package versions of the first
+// benchmark absent from the rest or present in all, respectively.
+//
+// When --aliases is provided, versions published under both a renamed
+// package's previous and canonical names are deduplicated, retaining the
+// canonical name. Versions published only under a previous name keep it.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/google/oss-rebuild/pkg/rebuild/alias"
+	"github.com/google/oss-rebuild/tools/benchmark"
+)
+
+var (
+	output    = flag.String("output", "", "the file to which the combined benchmark should be written. defaults to stdout")
+	subtract  = flag.Bool("subtract", false, "whether to output the package versions of the first benchmark that are absent from the others")
+	intersect = flag.Bool("intersect", false, "whether to output the package versions present in all benchmarks")
+	aliases   = flag.String("aliases", "", "a YAML alias table with which to resolve renamed packages to their canonical names")
+)
+
+func main() {
+	flag.Parse()
+	if flag.NArg() < 2 {
+		log.Fatal("Usage: combine [--output <file>] [--subtract|--intersect] [--aliases <file>] <benchmark.json> <benchmark.json>...")
+	}
+	if *subtract && *intersect {
+		log.Fatal("--subtract and --intersect are mutually exclusive")
+	}
+	var table *alias.Table
+	if *aliases != "" {
+		f, err := os.Open(*aliases)
+		if err != nil {
+			log.Fatalf("error opening %s: %v", *aliases, err)
+		}
+		as, err := alias.ReadYAML(f)
+		f.Close()
+		if err != nil {
+			log.Fatalf("error reading %s: %v", *aliases, err)
+		}
+		table, err = alias.NewTable(as)
+		if err != nil {
+			log.Fatalf("error building alias table: %v", err)
+		}
+	}
+	var sets []benchmark.PackageSet
+	for _, f := range flag.Args() {
+		ps, err := benchmark.ReadBenchmark(f)
+		if err != nil {
+			log.Fatalf("error reading %s: %v", f, err)
+		}
+		sets = append(sets, ps)
+	}
+	var ps benchmark.PackageSet
+	switch {
+	case *subtract:
+		ps = benchmark.Subtract(sets[0], sets[1:]...)
+	case *intersect:
+		ps = benchmark.Intersect(sets...)
+	default:
+		ps = benchmark.Combine(sets...)
+	}
+	if table != nil {
+		ps = benchmark.Canonicalize(ps, table)
+	}
+	out, err := json.MarshalIndent(ps, "", "  ")
+	if err != nil {
+		log.Fatalf("error marshalling PackageSet: %v", err)
+	}
+	if *output == "" {
+		os.Stdout.Write(append(out, '\n'))
+		return
+	}
+	if err := os.WriteFile(*output, out, 0664); err != nil {
+		log.Fatalf("error writing %s: %v", *output, err)
+	}
+}
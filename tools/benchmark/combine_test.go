@@ -0,0 +1,192 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package benchmark
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/pkg/rebuild/alias"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+)
+
+func TestCombine(t *testing.T) {
+	older := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	a := PackageSet{
+		Metadata: Metadata{
+			Count:      2,
+			Updated:    older,
+			Provenance: []Provenance{{Generator: "npm_top_500.json", Snapshots: map[string]time.Time{"deps.dev": older}, Generated: older}},
+		},
+		Packages: []Package{{Ecosystem: "npm", Name: "lodash", Versions: []string{"4.17.21", "4.17.20"}}},
+	}
+	b := PackageSet{
+		Metadata: Metadata{
+			Count:      3,
+			Updated:    newer,
+			Provenance: []Provenance{{Generator: "pypi_top_250_pure.json", Parameters: map[string]string{"limit": "1500"}, Generated: newer}},
+		},
+		Packages: []Package{
+			{Ecosystem: "pypi", Name: "absl-py", Versions: []string{"2.0.0"}},
+			{Ecosystem: "npm", Name: "lodash", Versions: []string{"4.17.21", "3.10.1"}},
+		},
+	}
+	want := PackageSet{
+		Metadata: Metadata{
+			Count:      4,
+			Updated:    newer,
+			Provenance: append(append([]Provenance{}, a.Provenance...), b.Provenance...),
+		},
+		Packages: []Package{
+			{Ecosystem: "npm", Name: "lodash", Versions: []string{"4.17.21", "4.17.20", "3.10.1"}},
+			{Ecosystem: "pypi", Name: "absl-py", Versions: []string{"2.0.0"}},
+		},
+	}
+	if diff := cmp.Diff(want, Combine(a, b)); diff != "" {
+		t.Errorf("Combine() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestCombineMixedDigests(t *testing.T) {
+	unpinned := PackageSet{Packages: []Package{{Ecosystem: "cratesio", Name: "serde", Versions: []string{"1.0.0"}}}}
+	pinned := PackageSet{Packages: []Package{{Ecosystem: "cratesio", Name: "serde", Versions: []string{"1.0.1", "1.0.2"}, Artifacts: []string{"serde-1.0.1.crate", "serde-1.0.2.crate"}, Digests: []string{"d1", "d2"}}}}
+	for _, tc := range []struct {
+		name string
+		sets []PackageSet
+		want Package
+	}{
+		{
+			name: "unpinned first",
+			sets: []PackageSet{unpinned, pinned},
+			want: Package{Ecosystem: "cratesio", Name: "serde", Versions: []string{"1.0.0", "1.0.1", "1.0.2"}, Artifacts: []string{"", "serde-1.0.1.crate", "serde-1.0.2.crate"}, Digests: []string{"", "d1", "d2"}},
+		},
+		{
+			name: "pinned first",
+			sets: []PackageSet{pinned, unpinned},
+			want: Package{Ecosystem: "cratesio", Name: "serde", Versions: []string{"1.0.1", "1.0.2", "1.0.0"}, Artifacts: []string{"serde-1.0.1.crate", "serde-1.0.2.crate", ""}, Digests: []string{"d1", "d2", ""}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Combine(tc.sets...)
+			if diff := cmp.Diff([]Package{tc.want}, got.Packages); diff != "" {
+				t.Errorf("Combine() mismatch (-want +got):\n%s", diff)
+			}
+			if d, ok := got.Packages[0].Digest("1.0.0"); ok {
+				t.Errorf("Digest(1.0.0) = %q, want none", d)
+			}
+			if d, _ := got.Packages[0].Digest("1.0.1"); d != "d1" {
+				t.Errorf("Digest(1.0.1) = %q, want d1", d)
+			}
+		})
+	}
+	// Filtering a padded package retains the alignment of the kept versions.
+	got := Subtract(Combine(unpinned, pinned), PackageSet{Packages: []Package{{Ecosystem: "cratesio", Name: "serde", Versions: []string{"1.0.1"}}}})
+	want := []Package{{Ecosystem: "cratesio", Name: "serde", Versions: []string{"1.0.0", "1.0.2"}, Artifacts: []string{"", "serde-1.0.2.crate"}, Digests: []string{"", "d2"}}}
+	if diff := cmp.Diff(want, got.Packages); diff != "" {
+		t.Errorf("Subtract() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestSubtractIntersect(t *testing.T) {
+	older := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	a := PackageSet{
+		Metadata: Metadata{
+			Count:      4,
+			Updated:    older,
+			Provenance: []Provenance{{Generator: "npm_top_500.json", Generated: older}},
+		},
+		Packages: []Package{
+			{Ecosystem: "npm", Name: "lodash", Versions: []string{"4.17.21", "4.17.20"}, Artifacts: []string{"lodash-4.17.21.tgz", "lodash-4.17.20.tgz"}, Digests: []string{"aaaa", "bbbb"}},
+			{Ecosystem: "npm", Name: "react", Versions: []string{"19.0.0"}},
+			{Ecosystem: "pypi", Name: "absl-py", Versions: []string{"2.0.0"}},
+		},
+	}
+	b := PackageSet{
+		Metadata: Metadata{
+			Count:      3,
+			Updated:    newer,
+			Provenance: []Provenance{{Generator: "npm_top_2500.json", Generated: newer}},
+		},
+		Packages: []Package{
+			{Ecosystem: "npm", Name: "lodash", Versions: []string{"4.17.21", "3.10.1"}},
+			{Ecosystem: "npm", Name: "absl-py", Versions: []string{"2.0.0"}},
+		},
+	}
+	provenance := append(append([]Provenance{}, a.Provenance...), b.Provenance...)
+	for _, tc := range []struct {
+		name string
+		got  PackageSet
+		want PackageSet
+	}{
+		{
+			name: "subtract",
+			got:  Subtract(a, b),
+			want: PackageSet{
+				Metadata: Metadata{Count: 3, Updated: newer, Provenance: provenance},
+				Packages: []Package{
+					{Ecosystem: "npm", Name: "lodash", Versions: []string{"4.17.20"}, Artifacts: []string{"lodash-4.17.20.tgz"}, Digests: []string{"bbbb"}},
+					{Ecosystem: "npm", Name: "react", Versions: []string{"19.0.0"}},
+					{Ecosystem: "pypi", Name: "absl-py", Versions: []string{"2.0.0"}},
+				},
+			},
+		},
+		{
+			name: "intersect",
+			got:  Intersect(a, b),
+			want: PackageSet{
+				Metadata: Metadata{Count: 1, Updated: newer, Provenance: provenance},
+				Packages: []Package{
+					{Ecosystem: "npm", Name: "lodash", Versions: []string{"4.17.21"}, Artifacts: []string{"lodash-4.17.21.tgz"}, Digests: []string{"aaaa"}},
+				},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, tc.got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCanonicalize(t *testing.T) {
+	table, err := alias.NewTable([]alias.Alias{{Ecosystem: rebuild.NPM, From: "left-pad", To: "@left-pad/left-pad"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := PackageSet{
+		Metadata: Metadata{Count: 4},
+		Packages: []Package{
+			{Ecosystem: "npm", Name: "left-pad", Versions: []string{"1.0.0", "1.1.0"}, Digests: []string{"sha256:a", "sha256:b"}},
+			{Ecosystem: "npm", Name: "lodash", Versions: []string{"4.17.21"}},
+			{Ecosystem: "npm", Name: "@left-pad/left-pad", Versions: []string{"1.1.0", "2.0.0"}, Digests: []string{"sha256:b", "sha256:c"}},
+		},
+	}
+	want := PackageSet{
+		Metadata: Metadata{Count: 4},
+		Packages: []Package{
+			{Ecosystem: "npm", Name: "left-pad", Versions: []string{"1.0.0"}, Digests: []string{"sha256:a"}},
+			{Ecosystem: "npm", Name: "lodash", Versions: []string{"4.17.21"}},
+			{Ecosystem: "npm", Name: "@left-pad/left-pad", Versions: []string{"1.1.0", "2.0.0"}, Digests: []string{"sha256:b", "sha256:c"}},
+		},
+	}
+	got := Canonicalize(in, table)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Canonicalize() returned diff (-want +got):\n%s", diff)
+	}
+}
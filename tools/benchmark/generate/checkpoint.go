@@ -0,0 +1,115 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"cloud.google.com/go/bigquery"
+	"google.golang.org/api/iterator"
+)
+
+// checkpoint persists intermediate generator results so an interrupted run can resume.
+//
+// A nil checkpoint disables persistence.
+type checkpoint struct {
+	dir string
+}
+
+func (c *checkpoint) path(key string) string {
+	return filepath.Join(c.dir, url.PathEscape(key)+".json")
+}
+
+// clear removes all persisted results.
+func (c *checkpoint) clear() error {
+	if c == nil {
+		return nil
+	}
+	return os.RemoveAll(c.dir)
+}
+
+// cached returns the result persisted under key, calling fetch and persisting its result if none exists.
+func cached[T any](c *checkpoint, key string, fetch func() (T, error)) (T, error) {
+	var v T
+	if c == nil {
+		return fetch()
+	}
+	b, err := os.ReadFile(c.path(key))
+	if err == nil {
+		if err := json.Unmarshal(b, &v); err != nil {
+			return v, fmt.Errorf("decoding checkpoint %s: %v", key, err)
+		}
+		return v, nil
+	} else if !os.IsNotExist(err) {
+		return v, fmt.Errorf("reading checkpoint %s: %v", key, err)
+	}
+	v, err = fetch()
+	if err != nil {
+		return v, err
+	}
+	b, err = json.Marshal(v)
+	if err != nil {
+		return v, fmt.Errorf("encoding checkpoint %s: %v", key, err)
+	}
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return v, fmt.Errorf("creating checkpoint dir: %v", err)
+	}
+	// NOTE: Write then rename so an interruption never leaves a partial checkpoint.
+	tmp := c.path(key) + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return v, fmt.Errorf("writing checkpoint %s: %v", key, err)
+	}
+	if err := os.Rename(tmp, c.path(key)); err != nil {
+		return v, fmt.Errorf("writing checkpoint %s: %v", key, err)
+	}
+	return v, nil
+}
+
+// queryRows runs the query to completion and returns all resulting rows.
+func queryRows[T any](ctx context.Context, query *bigquery.Query) ([]T, error) {
+	j, err := query.Run(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("running query: %v", err)
+	}
+	s, err := j.Wait(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("waiting for query: %v", err)
+	}
+	if s.Err() != nil {
+		return nil, fmt.Errorf("query failed: %v", s.Err())
+	}
+	it, err := j.Read(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reading query results: %v", err)
+	}
+	var rows []T
+	for {
+		var row T
+		err := it.Next(&row)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading row: %v", err)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
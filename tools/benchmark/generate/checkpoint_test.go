@@ -0,0 +1,61 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestCached(t *testing.T) {
+	cp := &checkpoint{dir: filepath.Join(t.TempDir(), "bench")}
+	var calls int
+	fetch := func() ([]string, error) {
+		calls++
+		return []string{"a", "b"}, nil
+	}
+	for range 2 {
+		got, err := cached(cp, "crate-a/b", fetch)
+		if err != nil {
+			t.Fatalf("cached() error = %v", err)
+		}
+		if !slices.Equal(got, []string{"a", "b"}) {
+			t.Errorf("cached() = %v", got)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("fetch called %d times, want 1", calls)
+	}
+	// Failed fetches are not persisted and are retried.
+	if _, err := cached(cp, "failing", func() (int, error) { return 0, errors.New("transient") }); err == nil {
+		t.Error("cached() expected error")
+	}
+	if got, err := cached(cp, "failing", func() (int, error) { return 1, nil }); err != nil || got != 1 {
+		t.Errorf("cached() = %v, %v after retry", got, err)
+	}
+	if err := cp.clear(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(cp.dir); !os.IsNotExist(err) {
+		t.Errorf("checkpoint dir not removed: %v", err)
+	}
+	var disabled *checkpoint
+	if _, err := cached(disabled, "key", fetch); err != nil || calls != 2 {
+		t.Errorf("cached() with nil checkpoint: calls=%d err=%v", calls, err)
+	}
+}
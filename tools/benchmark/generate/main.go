@@ -27,27 +27,34 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"runtime/debug"
 	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"cloud.google.com/go/bigquery"
 	"github.com/google/oss-rebuild/pkg/registry/cratesio"
+	"github.com/google/oss-rebuild/pkg/registry/rubygems"
 	"github.com/google/oss-rebuild/tools/benchmark"
-	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 )
 
 var (
-	outputDir = flag.String("output-dir", "", "directory to which generated files should be written")
-	project   = flag.String("project", bigquery.DetectProjectID, "if provided, the project to use to run bigquery jobs")
-	only      = flag.String("only", "", "if provided, the only benchmark to generate")
+	outputDir     = flag.String("output-dir", "", "directory to which generated files should be written")
+	project       = flag.String("project", bigquery.DetectProjectID, "if provided, the project to use to run bigquery jobs")
+	only          = flag.String("only", "", "if provided, the only benchmark to generate")
+	checkpointDir = flag.String("checkpoint-dir", "", "if provided, the directory in which intermediate results are stored to allow interrupted generation to resume")
+	update        = flag.Bool("update", false, "whether to merge generated results into the existing benchmark files rather than replacing them")
+	pinDigests    = flag.Bool("pin-digests", false, "whether to record upstream artifact digests for generators that support it")
+	seed          = flag.Int64("seed", 0, "if provided, the seed used by sampled benchmarks. defaults to one derived from the data snapshot")
 )
 
 // A RebuildBenchmark is a file associated with a PackageSet.
 type RebuildBenchmark struct {
 	Filename  string
-	Generator func(context.Context) benchmark.PackageSet
+	Generator func(context.Context, *checkpoint) (benchmark.PackageSet, error)
 }
 
 var all = []RebuildBenchmark{
@@ -55,9 +62,17 @@ var all = []RebuildBenchmark{
 	debianTop500,
 	pypiTop250Pure,
 	pypiTop1250Pure,
+	pypiStratified500Pure,
 	npmTop500,
 	npmTop2500,
 	mavenTop500,
+	rubygemsTop500,
+	gomodTop500,
+	depsDevSample("npm", "NPM"),
+	depsDevSample("pypi", "PYPI"),
+	depsDevSample("cratesio", "CARGO"),
+	depsDevSample("maven", "MAVEN"),
+	depsDevSample("gomod", "GO"),
 }
 
 const (
@@ -67,75 +82,99 @@ const (
 
 var cratesioTop2000 = RebuildBenchmark{
 	Filename: "cratesio_top_2000.json",
-	Generator: func(ctx context.Context) (ps benchmark.PackageSet) {
-		client := http.DefaultClient
-		now := time.Now()
+	Generator: func(ctx context.Context, cp *checkpoint) (ps benchmark.PackageSet, err error) {
+		now, err := cached(cp, "now", func() (time.Time, error) { return time.Now(), nil })
+		if err != nil {
+			return ps, err
+		}
 		ageThreshold := now.Add(-1 * maxAge)
-		crates := make(chan cratesio.Metadata, 100)
-		// Get download-ordered crates from crates.io.
-		go func() {
-			for page := 1; len(ps.Packages) < maxPackages; page++ {
-				url := fmt.Sprintf("https://crates.io/api/v1/crates?page=%d&per_page=100&sort=downloads", page)
-				req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-				if err != nil {
-					log.Fatalf("error creating request for download-ordered page %d: %v", page, err)
-				}
-				resp, err := client.Do(req)
-				if err != nil {
-					log.Fatalf("error fetching download-ordered page %d: %v", page, err)
-				}
-				if resp.StatusCode != 200 {
-					log.Fatalf("error from registry fetching download-ordered page %d: %s", page, resp.Status)
-				}
-				var ms struct {
-					Metadata []cratesio.Metadata `json:"crates"`
-				}
-				if derr := json.NewDecoder(resp.Body).Decode(&ms); derr != nil {
-					log.Fatalf("decoding error on download-ordered page %d: %v", page, err)
-				}
-				for _, m := range ms.Metadata {
-					crates <- m
-				}
+		registry := cratesio.HTTPRegistry{Client: http.DefaultClient}
+		type candidate struct {
+			Version  string
+			Checksum string
+		}
+		for page := 1; len(ps.Packages) < maxPackages; page++ {
+			// Get download-ordered crates from crates.io.
+			crates, err := cached(cp, fmt.Sprintf("page-%d", page), func() ([]cratesio.Metadata, error) {
+				return cratesPage(ctx, page)
+			})
+			if err != nil {
+				return ps, fmt.Errorf("fetching download-ordered page %d: %v", page, err)
 			}
-		}()
-		// Select crates with versions that satisfy our criteria.
-		for m := range crates {
-			if len(ps.Packages) >= maxPackages {
+			if len(crates) == 0 {
 				break
 			}
-			pmeta, err := cratesio.HTTPRegistry{Client: http.DefaultClient}.Crate(ctx, m.Name)
-			if err != nil {
-				log.Fatalf("error fetching package metadata for %s: %v", m.Name, err)
-			}
-			var versions []string
-			for _, v := range pmeta.Versions {
-				if len(versions) >= 5 {
+			// Select crates with versions that satisfy our criteria.
+			for _, m := range crates {
+				if len(ps.Packages) >= maxPackages {
 					break
 				}
-				isTooOld := v.Created.Before(ageThreshold)
-				// NOTE: Assuming versions are valid SemVer, hyphen detects prerelease.
-				isPrerelease := strings.ContainsRune(v.Version, '-')
-				if v.Yanked || isPrerelease || isTooOld {
+				versions, err := cached(cp, "crate-versions-"+m.Name, func() ([]candidate, error) {
+					pmeta, err := registry.Crate(ctx, m.Name)
+					if err != nil {
+						return nil, err
+					}
+					var versions []candidate
+					for _, v := range pmeta.Versions {
+						if len(versions) >= 5 {
+							break
+						}
+						isTooOld := v.Created.Before(ageThreshold)
+						// NOTE: Assuming versions are valid SemVer, hyphen detects prerelease.
+						isPrerelease := strings.ContainsRune(v.Version, '-')
+						if v.Yanked || isPrerelease || isTooOld {
+							continue
+						}
+						versions = append(versions, candidate{Version: v.Version, Checksum: v.Checksum})
+					}
+					return versions, nil
+				})
+				if err != nil {
+					return ps, fmt.Errorf("fetching package metadata for %s: %v", m.Name, err)
+				}
+				if len(versions) == 0 {
+					log.Printf("No valid candidate versions for pkg %s", m.Name)
 					continue
 				}
-				versions = append(versions, v.Version)
-			}
-			if len(versions) == 0 {
-				log.Printf("No valid candidate versions for pkg %s", m.Name)
-				continue
-			}
-			ps.Count += len(versions)
-			pkg := benchmark.Package{Name: m.Name, Ecosystem: "cratesio", Versions: versions}
-			ps.Packages = append(ps.Packages, pkg)
-			if len(ps.Packages)%500 == 0 {
-				log.Printf("Added %d out of %d", len(ps.Packages), maxPackages)
+				ps.Count += len(versions)
+				pkg := benchmark.Package{Name: m.Name, Ecosystem: "cratesio"}
+				for _, v := range versions {
+					pkg.Versions = append(pkg.Versions, v.Version)
+					if *pinDigests {
+						pkg.Digests = append(pkg.Digests, v.Checksum)
+					}
+				}
+				ps.Packages = append(ps.Packages, pkg)
+				if len(ps.Packages)%500 == 0 {
+					log.Printf("Added %d out of %d", len(ps.Packages), maxPackages)
+				}
 			}
 		}
 		ps.Updated = now
+		ps.Provenance = []benchmark.Provenance{{
+			Parameters: map[string]string{"max_age": maxAge.String(), "max_packages": strconv.Itoa(maxPackages), "max_versions": "5", "pin_digests": strconv.FormatBool(*pinDigests)},
+			Snapshots:  map[string]time.Time{"https://crates.io/api/v1/crates": now},
+		}}
 		return
 	},
 }
 
+// cratesPage fetches a page of crates ordered by download count.
+func cratesPage(ctx context.Context, page int) ([]cratesio.Metadata, error) {
+	resp, err := get(ctx, fmt.Sprintf("https://crates.io/api/v1/crates?page=%d&per_page=100&sort=downloads", page))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Close()
+	var ms struct {
+		Metadata []cratesio.Metadata `json:"crates"`
+	}
+	if err := json.NewDecoder(resp).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("decoding: %v", err)
+	}
+	return ms.Metadata, nil
+}
+
 func get(ctx context.Context, url string) (io.ReadCloser, error) {
 	client := http.DefaultClient
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
@@ -154,58 +193,85 @@ func get(ctx context.Context, url string) (io.ReadCloser, error) {
 
 var debianTop500 = RebuildBenchmark{
 	Filename: "debian_top_500.json",
-	Generator: func(ctx context.Context) (ps benchmark.PackageSet) {
+	Generator: func(ctx context.Context, cp *checkpoint) (ps benchmark.PackageSet, err error) {
 		var (
 			// The "all" arch is included in other arch indices.
 			archs         = []string{"amd64"}
 			popularityURL = "https://popcon.debian.org/by_inst"
 			repositoryURL = "https://deb.debian.org/debian"
 		)
-		resp, err := get(ctx, popularityURL)
+		now, err := cached(cp, "now", func() (time.Time, error) { return time.Now(), nil })
 		if err != nil {
-			log.Fatalf("error fetching popularity: %v", err)
-		}
-		b := bufio.NewScanner(resp)
-		var popularPackages []string
-		// Lines beginning with '#' are comments. Here are the column names and first row:
-		// #rank name                            inst  vote   old recent no-files (maintainer)
-		// 1     adduser                        248240 227236  4237 16731    36 (Debian Adduser Developers)
-		for b.Scan() {
-			line := strings.TrimSpace(b.Text())
-			if len(line) == 0 || line[0] == '#' {
-				continue
-			}
-			f := strings.Fields(line)
-			if len(f) < 2 {
-				continue
+			return ps, err
+		}
+		popularPackages, err := cached(cp, "popularity", func() ([]string, error) {
+			resp, err := get(ctx, popularityURL)
+			if err != nil {
+				return nil, err
+			}
+			defer resp.Close()
+			b := bufio.NewScanner(resp)
+			var popularPackages []string
+			// Lines beginning with '#' are comments. Here are the column names and first row:
+			// #rank name                            inst  vote   old recent no-files (maintainer)
+			// 1     adduser                        248240 227236  4237 16731    36 (Debian Adduser Developers)
+			for b.Scan() {
+				line := strings.TrimSpace(b.Text())
+				if len(line) == 0 || line[0] == '#' {
+					continue
+				}
+				f := strings.Fields(line)
+				if len(f) < 2 {
+					continue
+				}
+				// We only care about the package name.
+				popularPackages = append(popularPackages, f[1])
 			}
-			// We only care about the package name.
-			popularPackages = append(popularPackages, f[1])
+			return popularPackages, b.Err()
+		})
+		if err != nil {
+			return ps, fmt.Errorf("fetching popularity: %v", err)
 		}
 		// Mapping from package name to a set of strings like "component/sourceName/version/artifact" where package name matches the artifact.
 		var elementsRegex = regexp.MustCompile(`^(?P<component>[^/]+)\/(?P<sourceName>[^/]+)\/(?P<version>[^/]+)\/(?P<artifact>[^/]+)$`)
 		repo := map[string]map[string]bool{}
 		for _, arch := range archs {
-			index, err := get(ctx, repositoryURL+fmt.Sprintf("/indices/files/arch-%s.files", arch))
+			archRepo, err := cached(cp, "index-"+arch, func() (map[string]map[string]bool, error) {
+				index, err := get(ctx, repositoryURL+fmt.Sprintf("/indices/files/arch-%s.files", arch))
+				if err != nil {
+					return nil, err
+				}
+				defer index.Close()
+				archRepo := map[string]map[string]bool{}
+				b := bufio.NewScanner(index)
+				// Each line in the index is a relative path from the repository root. Files included are *.dsc, *.tar.gz, and *.deb.
+				// And example would be:
+				// ./pool/contrib/a/alex4/alex4_1.1-10+b2_amd64.deb
+				packagePathRegex := regexp.MustCompile(`^\.\/pool\/(?P<component>[^/]+)\/[^/]+\/(?P<sourceName>[^/]+)\/(?P<packageName>[^_]+)_(?P<version>[^_]+)_[^_]+\.deb$`)
+				for b.Scan() {
+					if matches := packagePathRegex.FindStringSubmatch(strings.TrimSpace(b.Text())); matches != nil {
+						component := matches[packagePathRegex.SubexpIndex("component")]
+						sourceName := matches[packagePathRegex.SubexpIndex("sourceName")]
+						packageName := matches[packagePathRegex.SubexpIndex("packageName")]
+						version := matches[packagePathRegex.SubexpIndex("version")]
+						artifact := filepath.Base(b.Text())
+						if _, ok := archRepo[packageName]; !ok {
+							archRepo[packageName] = map[string]bool{}
+						}
+						archRepo[packageName][fmt.Sprintf("%s/%s/%s/%s", component, sourceName, version, artifact)] = true
+					}
+				}
+				return archRepo, b.Err()
+			})
 			if err != nil {
-				log.Fatalf("error fetching arch %s index: %v", arch, err)
+				return ps, fmt.Errorf("fetching arch %s index: %v", arch, err)
 			}
-			b = bufio.NewScanner(index)
-			// Each line in the index is a relative path from the repository root. Files included are *.dsc, *.tar.gz, and *.deb.
-			// And example would be:
-			// ./pool/contrib/a/alex4/alex4_1.1-10+b2_amd64.deb
-			packagePathRegex := regexp.MustCompile(`^\.\/pool\/(?P<component>[^/]+)\/[^/]+\/(?P<sourceName>[^/]+)\/(?P<packageName>[^_]+)_(?P<version>[^_]+)_[^_]+\.deb$`)
-			for b.Scan() {
-				if matches := packagePathRegex.FindStringSubmatch(strings.TrimSpace(b.Text())); matches != nil {
-					component := matches[packagePathRegex.SubexpIndex("component")]
-					sourceName := matches[packagePathRegex.SubexpIndex("sourceName")]
-					packageName := matches[packagePathRegex.SubexpIndex("packageName")]
-					version := matches[packagePathRegex.SubexpIndex("version")]
-					artifact := filepath.Base(b.Text())
-					if _, ok := repo[packageName]; !ok {
-						repo[packageName] = map[string]bool{}
-					}
-					repo[packageName][fmt.Sprintf("%s/%s/%s/%s", component, sourceName, version, artifact)] = true
+			for packageName, elements := range archRepo {
+				if _, ok := repo[packageName]; !ok {
+					repo[packageName] = map[string]bool{}
+				}
+				for e := range elements {
+					repo[packageName][e] = true
 				}
 			}
 		}
@@ -241,7 +307,7 @@ var debianTop500 = RebuildBenchmark{
 					}
 					artifacts = append(artifacts, Artifact{Version: version, Name: artifact})
 				} else {
-					log.Fatalf("Unexpected artifact format %s", a)
+					return ps, fmt.Errorf("unexpected artifact format %s", a)
 				}
 			}
 			if packageComponent == "" || packageSourceName == "" {
@@ -255,15 +321,22 @@ var debianTop500 = RebuildBenchmark{
 			ps.Count += 1
 		next:
 		}
-		ps.Updated = time.Now()
+		ps.Updated = now
+		ps.Provenance = []benchmark.Provenance{{
+			Parameters: map[string]string{"archs": strings.Join(archs, ","), "max_packages": "500"},
+			Snapshots:  map[string]time.Time{popularityURL: now, repositoryURL: now},
+		}}
 		return
 	},
 }
 
 var pypiTop250Pure = RebuildBenchmark{
 	Filename: "pypi_top_250_pure.json",
-	Generator: func(ctx context.Context) (ps benchmark.PackageSet) {
-		now := time.Now()
+	Generator: func(ctx context.Context, cp *checkpoint) (ps benchmark.PackageSet, err error) {
+		now, err := cached(cp, "now", func() (time.Time, error) { return time.Now(), nil })
+		if err != nil {
+			return ps, err
+		}
 		// Calculate last Wednesday.
 		// Rationale: Wednesday is the least likely day of the week to be a holiday
 		// and has the most actual user traffic to PyPI (versus, say, CI).
@@ -272,7 +345,7 @@ var pypiTop250Pure = RebuildBenchmark{
 		}
 		client, err := bigquery.NewClient(ctx, *project, option.WithQuotaProject(*project))
 		if err != nil {
-			log.Fatal(err.Error())
+			return ps, fmt.Errorf("creating bigquery client: %v", err)
 		}
 		query := client.Query(`
 SELECT
@@ -281,7 +354,7 @@ SELECT
   file.version as Version,
   file.filename as Filename
 FROM
-  ` + "`" + `bigquery-public-data.pypi.file_downloads` + "`" + `
+  ` + "`" + pypiDownloadsTable + "`" + `
 WHERE
   TIMESTAMP_TRUNC(timestamp, DAY) = TIMESTAMP("` + lastWednesday.Format(time.DateOnly) + `")
 GROUP BY
@@ -290,49 +363,19 @@ ORDER BY
   Downloads DESC
 LIMIT 1500
 `)
-		pkgs := make(chan struct {
+		type row struct {
 			Downloads int64
 			Project   string
 			Version   string
 			Filename  string
-		}, 100)
+		}
 		// Get download-ordered package versions from PyPI's download table.
-		go func() {
-			j, err := query.Run(ctx)
-			if err != nil {
-				log.Fatal(err.Error())
-			}
-			s, err := j.Wait(ctx)
-			if err != nil {
-				log.Fatal(err.Error())
-			}
-			if s.Err() != nil {
-				log.Fatal(s.Err().Error())
-			}
-			it, err := j.Read(ctx)
-			if err != nil {
-				log.Fatal(err.Error())
-			}
-			var entry struct {
-				Downloads int64
-				Project   string
-				Version   string
-				Filename  string
-			}
-			for {
-				err := it.Next(&entry)
-				if err == iterator.Done {
-					break
-				}
-				if err != nil {
-					log.Fatal(err.Error())
-				}
-				pkgs <- entry
-			}
-			close(pkgs)
-		}()
+		pkgs, err := cached(cp, "rows", func() ([]row, error) { return queryRows[row](ctx, query) })
+		if err != nil {
+			return ps, fmt.Errorf("querying packages: %v", err)
+		}
 		// Select packages with versions that satisfy our criteria.
-		for p := range pkgs {
+		for _, p := range pkgs {
 			if strings.ContainsRune(p.Version, '-') {
 				// Non-release version.
 				continue
@@ -366,14 +409,22 @@ LIMIT 1500
 			ps.Count += len(psp.Versions)
 		}
 		ps.Updated = now
+		ps.Provenance = []benchmark.Provenance{{
+			Parameters: map[string]string{"download_limit": "1500", "max_packages": "250", "max_versions": "5"},
+			// The downloads table is partitioned by day.
+			Snapshots: map[string]time.Time{pypiDownloadsTable: time.Date(lastWednesday.Year(), lastWednesday.Month(), lastWednesday.Day(), 0, 0, 0, 0, time.UTC)},
+		}}
 		return
 	},
 }
 
 var pypiTop1250Pure = RebuildBenchmark{
 	Filename: "pypi_top_1250_pure.json",
-	Generator: func(ctx context.Context) (ps benchmark.PackageSet) {
-		now := time.Now()
+	Generator: func(ctx context.Context, cp *checkpoint) (ps benchmark.PackageSet, err error) {
+		now, err := cached(cp, "now", func() (time.Time, error) { return time.Now(), nil })
+		if err != nil {
+			return ps, err
+		}
 		// Calculate last Wednesday.
 		// Rationale: Wednesday is the least likely day of the week to be a holiday
 		// and has the most actual user traffic to PyPI (versus, say, CI).
@@ -382,7 +433,7 @@ var pypiTop1250Pure = RebuildBenchmark{
 		}
 		client, err := bigquery.NewClient(ctx, *project, option.WithQuotaProject(*project))
 		if err != nil {
-			log.Fatal(err.Error())
+			return ps, fmt.Errorf("creating bigquery client: %v", err)
 		}
 		query := client.Query(`
 SELECT
@@ -391,7 +442,7 @@ SELECT
   file.version as Version,
   file.filename as Filename
 FROM
-  ` + "`" + `bigquery-public-data.pypi.file_downloads` + "`" + `
+  ` + "`" + pypiDownloadsTable + "`" + `
 WHERE
   TIMESTAMP_TRUNC(timestamp, DAY) = TIMESTAMP("` + lastWednesday.Format(time.DateOnly) + `")
 GROUP BY
@@ -400,49 +451,19 @@ ORDER BY
   Downloads DESC
 LIMIT 150000
 `)
-		pkgs := make(chan struct {
+		type row struct {
 			Downloads int64
 			Project   string
 			Version   string
 			Filename  string
-		}, 100)
+		}
 		// Get download-ordered package versions from PyPI's download table.
-		go func() {
-			j, err := query.Run(ctx)
-			if err != nil {
-				log.Fatal(err.Error())
-			}
-			s, err := j.Wait(ctx)
-			if err != nil {
-				log.Fatal(err.Error())
-			}
-			if s.Err() != nil {
-				log.Fatal(s.Err().Error())
-			}
-			it, err := j.Read(ctx)
-			if err != nil {
-				log.Fatal(err.Error())
-			}
-			var entry struct {
-				Downloads int64
-				Project   string
-				Version   string
-				Filename  string
-			}
-			for {
-				err := it.Next(&entry)
-				if err == iterator.Done {
-					break
-				}
-				if err != nil {
-					log.Fatal(err.Error())
-				}
-				pkgs <- entry
-			}
-			close(pkgs)
-		}()
+		pkgs, err := cached(cp, "rows", func() ([]row, error) { return queryRows[row](ctx, query) })
+		if err != nil {
+			return ps, fmt.Errorf("querying packages: %v", err)
+		}
 		// Select packages with versions that satisfy our criteria.
-		for p := range pkgs {
+		for _, p := range pkgs {
 			if strings.ContainsRune(p.Version, '-') {
 				// Non-release version.
 				continue
@@ -476,17 +497,30 @@ LIMIT 150000
 			ps.Count += len(psp.Versions)
 		}
 		ps.Updated = now
+		ps.Provenance = []benchmark.Provenance{{
+			Parameters: map[string]string{"download_limit": "150000", "max_packages": "1250", "max_versions": "2"},
+			// The downloads table is partitioned by day.
+			Snapshots: map[string]time.Time{pypiDownloadsTable: time.Date(lastWednesday.Year(), lastWednesday.Month(), lastWednesday.Day(), 0, 0, 0, 0, time.UTC)},
+		}}
 		return
 	},
 }
 
 var npmTop500 = RebuildBenchmark{
 	Filename: "npm_top_500.json",
-	Generator: func(ctx context.Context) (ps benchmark.PackageSet) {
-		now := time.Now()
+	Generator: func(ctx context.Context, cp *checkpoint) (ps benchmark.PackageSet, err error) {
+		now, err := cached(cp, "now", func() (time.Time, error) { return time.Now(), nil })
+		if err != nil {
+			return ps, err
+		}
 		client, err := bigquery.NewClient(ctx, *project, option.WithQuotaProject(*project))
 		if err != nil {
-			log.Fatal(err.Error())
+			return ps, fmt.Errorf("creating bigquery client: %v", err)
+		}
+		// NOTE: The snapshot is checkpointed so a resumed run queries the same data.
+		snapshot, err := cached(cp, "snapshot", func() (time.Time, error) { return latestDepsDevSnapshot(ctx, client) })
+		if err != nil {
+			return ps, err
 		}
 		query := client.Query(`
 SELECT
@@ -501,19 +535,9 @@ FROM (
     T.` + "`" + `To` + "`" + `.Version AS Version
   FROM
     ` + "`" + `bigquery-public-data.deps_dev_v1.DependencyGraphEdges` + "`" + ` T
-  INNER JOIN (
-    SELECT
-      Time
-    FROM
-      ` + "`" + `bigquery-public-data.deps_dev_v1.Snapshots` + "`" + `
-    ORDER BY
-      Time DESC
-    LIMIT
-      1) S
-  ON
-    S.Time = T.SnapshotAt
   WHERE
-    T.System = "NPM"
+    T.SnapshotAt = @snapshot
+    AND T.System = "NPM"
   GROUP BY
     T.` + "`" + `From` + "`" + `.Name,
     T.` + "`" + `From` + "`" + `.Version,
@@ -526,47 +550,19 @@ ORDER BY
   Downloads DESC
 LIMIT 2500
 `)
-		pkgs := make(chan struct {
+		query.Parameters = []bigquery.QueryParameter{{Name: "snapshot", Value: snapshot}}
+		type row struct {
 			Downloads int64
 			Package   string
 			Version   string
-		}, 100)
+		}
 		// Get download-ordered package versions from deps.dev's dependency table.
-		go func() {
-			j, err := query.Run(ctx)
-			if err != nil {
-				log.Fatal(err.Error())
-			}
-			s, err := j.Wait(ctx)
-			if err != nil {
-				log.Fatal(err.Error())
-			}
-			if s.Err() != nil {
-				log.Fatal(s.Err().Error())
-			}
-			it, err := j.Read(ctx)
-			if err != nil {
-				log.Fatal(err.Error())
-			}
-			var entry struct {
-				Downloads int64
-				Package   string
-				Version   string
-			}
-			for {
-				err := it.Next(&entry)
-				if err == iterator.Done {
-					break
-				}
-				if err != nil {
-					log.Fatal(err.Error())
-				}
-				pkgs <- entry
-			}
-			close(pkgs)
-		}()
+		pkgs, err := cached(cp, "rows", func() ([]row, error) { return queryRows[row](ctx, query) })
+		if err != nil {
+			return ps, fmt.Errorf("querying packages: %v", err)
+		}
 		// Select packages with versions that satisfy our criteria.
-		for p := range pkgs {
+		for _, p := range pkgs {
 			if strings.ContainsRune(p.Version, '-') {
 				// Non-release version.
 				continue
@@ -596,17 +592,29 @@ LIMIT 2500
 			ps.Count += len(psp.Versions)
 		}
 		ps.Updated = now
+		ps.Provenance = []benchmark.Provenance{{
+			Parameters: map[string]string{"edge_limit": "2500", "max_packages": "500", "max_versions": "5"},
+			Snapshots:  map[string]time.Time{depsDevSnapshotsTable: snapshot},
+		}}
 		return
 	},
 }
 
 var npmTop2500 = RebuildBenchmark{
 	Filename: "npm_top_2500.json",
-	Generator: func(ctx context.Context) (ps benchmark.PackageSet) {
-		now := time.Now()
+	Generator: func(ctx context.Context, cp *checkpoint) (ps benchmark.PackageSet, err error) {
+		now, err := cached(cp, "now", func() (time.Time, error) { return time.Now(), nil })
+		if err != nil {
+			return ps, err
+		}
 		client, err := bigquery.NewClient(ctx, *project, option.WithQuotaProject(*project))
 		if err != nil {
-			log.Fatal(err.Error())
+			return ps, fmt.Errorf("creating bigquery client: %v", err)
+		}
+		// NOTE: The snapshot is checkpointed so a resumed run queries the same data.
+		snapshot, err := cached(cp, "snapshot", func() (time.Time, error) { return latestDepsDevSnapshot(ctx, client) })
+		if err != nil {
+			return ps, err
 		}
 		query := client.Query(`
 SELECT
@@ -621,19 +629,9 @@ FROM (
     T.` + "`" + `To` + "`" + `.Version AS Version
   FROM
     ` + "`" + `bigquery-public-data.deps_dev_v1.DependencyGraphEdges` + "`" + ` T
-  INNER JOIN (
-    SELECT
-      Time
-    FROM
-      ` + "`" + `bigquery-public-data.deps_dev_v1.Snapshots` + "`" + `
-    ORDER BY
-      Time DESC
-    LIMIT
-      1) S
-  ON
-    S.Time = T.SnapshotAt
   WHERE
-    T.System = "NPM"
+    T.SnapshotAt = @snapshot
+    AND T.System = "NPM"
   GROUP BY
     T.` + "`" + `From` + "`" + `.Name,
     T.` + "`" + `From` + "`" + `.Version,
@@ -646,47 +644,19 @@ ORDER BY
   Downloads DESC
 LIMIT 10000
 `)
-		pkgs := make(chan struct {
+		query.Parameters = []bigquery.QueryParameter{{Name: "snapshot", Value: snapshot}}
+		type row struct {
 			Downloads int64
 			Package   string
 			Version   string
-		}, 100)
+		}
 		// Get download-ordered package versions from deps.dev's dependency table.
-		go func() {
-			j, err := query.Run(ctx)
-			if err != nil {
-				log.Fatal(err.Error())
-			}
-			s, err := j.Wait(ctx)
-			if err != nil {
-				log.Fatal(err.Error())
-			}
-			if s.Err() != nil {
-				log.Fatal(s.Err().Error())
-			}
-			it, err := j.Read(ctx)
-			if err != nil {
-				log.Fatal(err.Error())
-			}
-			var entry struct {
-				Downloads int64
-				Package   string
-				Version   string
-			}
-			for {
-				err := it.Next(&entry)
-				if err == iterator.Done {
-					break
-				}
-				if err != nil {
-					log.Fatal(err.Error())
-				}
-				pkgs <- entry
-			}
-			close(pkgs)
-		}()
+		pkgs, err := cached(cp, "rows", func() ([]row, error) { return queryRows[row](ctx, query) })
+		if err != nil {
+			return ps, fmt.Errorf("querying packages: %v", err)
+		}
 		// Select packages with versions that satisfy our criteria.
-		for p := range pkgs {
+		for _, p := range pkgs {
 			if strings.ContainsRune(p.Version, '-') {
 				// Non-release version.
 				continue
@@ -716,17 +686,29 @@ LIMIT 10000
 			ps.Count += len(psp.Versions)
 		}
 		ps.Updated = now
+		ps.Provenance = []benchmark.Provenance{{
+			Parameters: map[string]string{"edge_limit": "10000", "max_packages": "2500", "max_versions": "5"},
+			Snapshots:  map[string]time.Time{depsDevSnapshotsTable: snapshot},
+		}}
 		return
 	},
 }
 
 var mavenTop500 = RebuildBenchmark{
 	Filename: "maven_top_500.json",
-	Generator: func(ctx context.Context) (ps benchmark.PackageSet) {
-		now := time.Now()
+	Generator: func(ctx context.Context, cp *checkpoint) (ps benchmark.PackageSet, err error) {
+		now, err := cached(cp, "now", func() (time.Time, error) { return time.Now(), nil })
+		if err != nil {
+			return ps, err
+		}
 		client, err := bigquery.NewClient(ctx, *project, option.WithQuotaProject(*project))
 		if err != nil {
-			log.Fatal(err.Error())
+			return ps, fmt.Errorf("creating bigquery client: %v", err)
+		}
+		// NOTE: The snapshot is checkpointed so a resumed run queries the same data.
+		snapshot, err := cached(cp, "snapshot", func() (time.Time, error) { return latestDepsDevSnapshot(ctx, client) })
+		if err != nil {
+			return ps, err
 		}
 		query := client.Query(`
 SELECT
@@ -741,19 +723,9 @@ FROM (
     T.` + "`" + `To` + "`" + `.Version AS Version
   FROM
     ` + "`" + `bigquery-public-data.deps_dev_v1.DependencyGraphEdges` + "`" + ` T
-  INNER JOIN (
-    SELECT
-      Time
-    FROM
-      ` + "`" + `bigquery-public-data.deps_dev_v1.Snapshots` + "`" + `
-    ORDER BY
-      Time DESC
-    LIMIT
-      1) S
-  ON
-    S.Time = T.SnapshotAt
   WHERE
-    T.System = "MAVEN"
+    T.SnapshotAt = @snapshot
+    AND T.System = "MAVEN"
   GROUP BY
     T.` + "`" + `From` + "`" + `.Name,
     T.` + "`" + `From` + "`" + `.Version,
@@ -766,49 +738,212 @@ ORDER BY
   Downloads DESC
 LIMIT 2500
 `)
-		pkgs := make(chan struct {
+		query.Parameters = []bigquery.QueryParameter{{Name: "snapshot", Value: snapshot}}
+		type row struct {
 			Downloads int64
 			Package   string
 			Version   string
-		}, 100)
+		}
 		// Get download-ordered package versions from deps.dev's dependency table.
-		go func() {
-			j, err := query.Run(ctx)
-			if err != nil {
-				log.Fatal(err.Error())
+		pkgs, err := cached(cp, "rows", func() ([]row, error) { return queryRows[row](ctx, query) })
+		if err != nil {
+			return ps, fmt.Errorf("querying packages: %v", err)
+		}
+		// Select packages with versions that satisfy our criteria.
+		for _, p := range pkgs {
+			if strings.ContainsRune(p.Version, '-') {
+				// Non-release version.
+				continue
 			}
-			s, err := j.Wait(ctx)
-			if err != nil {
-				log.Fatal(err.Error())
+			idx := -1
+			for i, psp := range ps.Packages {
+				if psp.Name == p.Package {
+					idx = i
+					break
+				}
+			}
+			if idx == -1 {
+				if len(ps.Packages) >= 500 {
+					// If we're already at the max project count, skip.
+					continue
+				}
+				ps.Packages = append(ps.Packages, benchmark.Package{Name: p.Package, Ecosystem: "maven"})
+				idx = len(ps.Packages) - 1
 			}
-			if s.Err() != nil {
-				log.Fatal(s.Err().Error())
+			psp := &ps.Packages[idx]
+			if len(psp.Versions) >= 5 {
+				continue
 			}
-			it, err := j.Read(ctx)
+			psp.Versions = append(psp.Versions, p.Version)
+		}
+		for _, psp := range ps.Packages {
+			ps.Count += len(psp.Versions)
+		}
+		ps.Updated = now
+		ps.Provenance = []benchmark.Provenance{{
+			Parameters: map[string]string{"edge_limit": "2500", "max_packages": "500", "max_versions": "5"},
+			Snapshots:  map[string]time.Time{depsDevSnapshotsTable: snapshot},
+		}}
+		return
+	},
+}
+
+var rubygemsTop500 = RebuildBenchmark{
+	Filename: "rubygems_top_500.json",
+	Generator: func(ctx context.Context, cp *checkpoint) (ps benchmark.PackageSet, err error) {
+		now, err := cached(cp, "now", func() (time.Time, error) { return time.Now(), nil })
+		if err != nil {
+			return ps, err
+		}
+		ageThreshold := now.Add(-1 * maxAge)
+		registry := rubygems.HTTPRegistry{Client: http.DefaultClient}
+		type candidate struct {
+			Version string
+			SHA     string
+		}
+		for page := 1; len(ps.Packages) < 500; page++ {
+			// Get download-ordered gems from rubygems.org.
+			gems, err := cached(cp, fmt.Sprintf("page-%d", page), func() ([]string, error) {
+				return gemsPage(ctx, page)
+			})
 			if err != nil {
-				log.Fatal(err.Error())
+				return ps, fmt.Errorf("fetching download-ordered page %d: %v", page, err)
 			}
-			var entry struct {
-				Downloads int64
-				Package   string
-				Version   string
+			if len(gems) == 0 {
+				break
 			}
-			for {
-				err := it.Next(&entry)
-				if err == iterator.Done {
+			// Select gems with versions that satisfy our criteria.
+			for _, name := range gems {
+				if len(ps.Packages) >= 500 {
 					break
 				}
+				versions, err := cached(cp, "gem-"+name, func() ([]candidate, error) {
+					vs, err := registry.Versions(ctx, name)
+					if err != nil {
+						return nil, err
+					}
+					var versions []candidate
+					for _, v := range vs {
+						if len(versions) >= 5 {
+							break
+						}
+						// NOTE: Platform-specific gems contain native code and are excluded.
+						if v.Prerelease || v.Platform != rubygems.RubyPlatform || v.Created.Before(ageThreshold) {
+							continue
+						}
+						versions = append(versions, candidate{Version: v.Number, SHA: v.SHA})
+					}
+					return versions, nil
+				})
 				if err != nil {
-					log.Fatal(err.Error())
+					return ps, fmt.Errorf("fetching package metadata for %s: %v", name, err)
 				}
-				pkgs <- entry
+				if len(versions) == 0 {
+					log.Printf("No valid candidate versions for pkg %s", name)
+					continue
+				}
+				ps.Count += len(versions)
+				pkg := benchmark.Package{Name: name, Ecosystem: "rubygems"}
+				for _, v := range versions {
+					pkg.Versions = append(pkg.Versions, v.Version)
+					if *pinDigests {
+						pkg.Digests = append(pkg.Digests, v.SHA)
+					}
+				}
+				ps.Packages = append(ps.Packages, pkg)
 			}
-			close(pkgs)
-		}()
+		}
+		ps.Updated = now
+		ps.Provenance = []benchmark.Provenance{{
+			Parameters: map[string]string{"max_age": maxAge.String(), "max_packages": "500", "max_versions": "5", "pin_digests": strconv.FormatBool(*pinDigests)},
+			Snapshots:  map[string]time.Time{"https://rubygems.org/stats": now},
+		}}
+		return
+	},
+}
+
+var gemStatsRegex = regexp.MustCompile(`href="/gems/([^"/?]+)"`)
+
+// gemsPage fetches a page of gems ordered by download count.
+func gemsPage(ctx context.Context, page int) ([]string, error) {
+	resp, err := get(ctx, fmt.Sprintf("https://rubygems.org/stats?page=%d", page))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Close()
+	body, err := io.ReadAll(resp)
+	if err != nil {
+		return nil, fmt.Errorf("reading: %v", err)
+	}
+	// NOTE: The stats page has no JSON equivalent so gem links are extracted from the HTML.
+	var names []string
+	for _, m := range gemStatsRegex.FindAllStringSubmatch(string(body), -1) {
+		if !slices.Contains(names, m[1]) {
+			names = append(names, m[1])
+		}
+	}
+	return names, nil
+}
+
+var gomodTop500 = RebuildBenchmark{
+	Filename: "gomod_top_500.json",
+	Generator: func(ctx context.Context, cp *checkpoint) (ps benchmark.PackageSet, err error) {
+		now, err := cached(cp, "now", func() (time.Time, error) { return time.Now(), nil })
+		if err != nil {
+			return ps, err
+		}
+		client, err := bigquery.NewClient(ctx, *project, option.WithQuotaProject(*project))
+		if err != nil {
+			return ps, fmt.Errorf("creating bigquery client: %v", err)
+		}
+		// NOTE: The snapshot is checkpointed so a resumed run queries the same data.
+		snapshot, err := cached(cp, "snapshot", func() (time.Time, error) { return latestDepsDevSnapshot(ctx, client) })
+		if err != nil {
+			return ps, err
+		}
+		query := client.Query(`
+SELECT
+  COUNT(*) AS Downloads,
+  Name AS Package,
+  Version
+FROM (
+  SELECT
+    T.` + "`" + `From` + "`" + `.Name AS FName,
+    T.` + "`" + `From` + "`" + `.Version AS FVersion,
+    T.` + "`" + `To` + "`" + `.Name AS Name,
+    T.` + "`" + `To` + "`" + `.Version AS Version
+  FROM
+    ` + "`" + `bigquery-public-data.deps_dev_v1.DependencyGraphEdges` + "`" + ` T
+  WHERE
+    T.SnapshotAt = @snapshot
+    AND T.System = "GO"
+  GROUP BY
+    T.` + "`" + `From` + "`" + `.Name,
+    T.` + "`" + `From` + "`" + `.Version,
+    T.` + "`" + `To` + "`" + `.Name,
+    T.` + "`" + `To` + "`" + `.Version)
+GROUP BY
+  Name,
+  Version
+ORDER BY
+  Downloads DESC
+LIMIT 2500
+`)
+		query.Parameters = []bigquery.QueryParameter{{Name: "snapshot", Value: snapshot}}
+		type row struct {
+			Downloads int64
+			Package   string
+			Version   string
+		}
+		// Get download-ordered package versions from deps.dev's dependency table.
+		pkgs, err := cached(cp, "rows", func() ([]row, error) { return queryRows[row](ctx, query) })
+		if err != nil {
+			return ps, fmt.Errorf("querying packages: %v", err)
+		}
 		// Select packages with versions that satisfy our criteria.
-		for p := range pkgs {
+		for _, p := range pkgs {
 			if strings.ContainsRune(p.Version, '-') {
-				// Non-release version.
+				// Non-release or pseudo-version.
 				continue
 			}
 			idx := -1
@@ -823,7 +958,7 @@ LIMIT 2500
 					// If we're already at the max project count, skip.
 					continue
 				}
-				ps.Packages = append(ps.Packages, benchmark.Package{Name: p.Package, Ecosystem: "maven"})
+				ps.Packages = append(ps.Packages, benchmark.Package{Name: p.Package, Ecosystem: "gomod"})
 				idx = len(ps.Packages) - 1
 			}
 			psp := &ps.Packages[idx]
@@ -836,38 +971,119 @@ LIMIT 2500
 			ps.Count += len(psp.Versions)
 		}
 		ps.Updated = now
+		ps.Provenance = []benchmark.Provenance{{
+			Parameters: map[string]string{"edge_limit": "2500", "max_packages": "500", "max_versions": "5"},
+			Snapshots:  map[string]time.Time{depsDevSnapshotsTable: snapshot},
+		}}
 		return
 	},
 }
 
+const (
+	depsDevSnapshotsTable = "bigquery-public-data.deps_dev_v1.Snapshots"
+	pypiDownloadsTable    = "bigquery-public-data.pypi.file_downloads"
+)
+
+// latestDepsDevSnapshot returns the time of the most recent deps.dev snapshot.
+func latestDepsDevSnapshot(ctx context.Context, client *bigquery.Client) (time.Time, error) {
+	it, err := client.Query("SELECT MAX(Time) AS Time FROM `" + depsDevSnapshotsTable + "`").Read(ctx)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("querying snapshots: %v", err)
+	}
+	var row struct{ Time time.Time }
+	if err := it.Next(&row); err != nil {
+		return time.Time{}, fmt.Errorf("reading snapshot: %v", err)
+	}
+	return row.Time, nil
+}
+
+// toolVersion identifies the build of this binary from its embedded build info.
+func toolVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	version := info.Main.Version
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" {
+			version += "+" + s.Value
+		}
+	}
+	return version
+}
+
+// generate runs the benchmark's generator and writes the result to the output directory.
+func generate(ctx context.Context, b RebuildBenchmark) error {
+	var cp *checkpoint
+	if *checkpointDir != "" {
+		cp = &checkpoint{dir: filepath.Join(*checkpointDir, strings.TrimSuffix(b.Filename, filepath.Ext(b.Filename)))}
+	}
+	ps, err := b.Generator(ctx, cp)
+	if err != nil {
+		return err
+	}
+	for i := range ps.Provenance {
+		ps.Provenance[i].Generator = b.Filename
+		ps.Provenance[i].ToolVersion = toolVersion()
+		ps.Provenance[i].Generated = ps.Updated
+	}
+	path := filepath.Join(*outputDir, b.Filename)
+	if *update {
+		ps, err = mergeExisting(path, ps)
+		if err != nil {
+			return err
+		}
+	}
+	out, err := json.MarshalIndent(ps, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling PackageSet: %v", err)
+	}
+	if err := os.WriteFile(path, out, 0664); err != nil {
+		return fmt.Errorf("writing output: %v", err)
+	}
+	// Intermediate results are only needed until the output is written.
+	return cp.clear()
+}
+
+// mergeExisting adds the generated PackageSet to that stored at path.
+//
+// Existing package versions are retained so previously benchmarked targets
+// remain comparable and the provenance of each refresh is recorded.
+func mergeExisting(path string, ps benchmark.PackageSet) (benchmark.PackageSet, error) {
+	existing, err := benchmark.ReadBenchmark(path)
+	if os.IsNotExist(err) {
+		return ps, nil
+	} else if err != nil {
+		return ps, fmt.Errorf("reading existing benchmark: %v", err)
+	}
+	return benchmark.Combine(existing, ps), nil
+}
+
 func main() {
 	flag.Parse()
 	ctx := context.Background()
-	todo := make(chan any, len(all))
-	done := make(chan any)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var failed []string
 	for _, b := range all {
 		if *only != "" && *only != b.Filename {
 			log.Printf("Skipping %s", b.Filename)
 			continue
 		}
 		log.Printf("Generating %s...", b.Filename)
-		todo <- nil
-		go func(b *RebuildBenchmark) {
-			ps := b.Generator(ctx)
-			out, err := json.MarshalIndent(ps, "", "  ")
-			if err != nil {
-				log.Fatalf("error marshalling PackageSet for %s: %v", b.Filename, err)
-			}
-			path := filepath.Join(*outputDir, b.Filename)
-			if err := os.WriteFile(path, out, 0664); err != nil {
-				log.Fatalf("error writing %s: %v", b.Filename, err)
-			}
-			done <- nil
-		}(&b)
+		wg.Add(1)
+		go func(b RebuildBenchmark) {
+			defer wg.Done()
+			if err := generate(ctx, b); err != nil {
+				log.Printf("error generating %s: %v", b.Filename, err)
+				mu.Lock()
+				failed = append(failed, b.Filename)
+				mu.Unlock()
+			}
+		}(b)
 	}
-	close(todo)
-	for range todo {
-		<-done
+	wg.Wait()
+	if len(failed) > 0 {
+		log.Fatalf("Failed to generate: %s", strings.Join(failed, ", "))
 	}
-	return
 }
@@ -0,0 +1,84 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/tools/benchmark"
+)
+
+func TestMergeExisting(t *testing.T) {
+	older := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	generated := benchmark.PackageSet{
+		Metadata: benchmark.Metadata{
+			Count:      2,
+			Updated:    newer,
+			Provenance: []benchmark.Provenance{{Generator: "npm_top_500.json", Generated: newer}},
+		},
+		Packages: []benchmark.Package{
+			{Ecosystem: "npm", Name: "lodash", Versions: []string{"4.17.22"}},
+			{Ecosystem: "npm", Name: "react", Versions: []string{"19.0.0"}},
+		},
+	}
+	path := filepath.Join(t.TempDir(), "npm_top_500.json")
+	// A missing file yields the generated set unchanged.
+	got, err := mergeExisting(path, generated)
+	if err != nil {
+		t.Fatalf("mergeExisting() error = %v", err)
+	}
+	if diff := cmp.Diff(generated, got); diff != "" {
+		t.Errorf("mergeExisting() mismatch (-want +got):\n%s", diff)
+	}
+	existing := benchmark.PackageSet{
+		Metadata: benchmark.Metadata{
+			Count:      2,
+			Updated:    older,
+			Provenance: []benchmark.Provenance{{Generator: "npm_top_500.json", Generated: older}},
+		},
+		Packages: []benchmark.Package{{Ecosystem: "npm", Name: "lodash", Versions: []string{"4.17.21", "4.17.20"}}},
+	}
+	b, err := json.Marshal(existing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err = mergeExisting(path, generated)
+	if err != nil {
+		t.Fatalf("mergeExisting() error = %v", err)
+	}
+	want := benchmark.PackageSet{
+		Metadata: benchmark.Metadata{
+			Count:      4,
+			Updated:    newer,
+			Provenance: []benchmark.Provenance{{Generator: "npm_top_500.json", Generated: older}, {Generator: "npm_top_500.json", Generated: newer}},
+		},
+		Packages: []benchmark.Package{
+			{Ecosystem: "npm", Name: "lodash", Versions: []string{"4.17.21", "4.17.20", "4.17.22"}},
+			{Ecosystem: "npm", Name: "react", Versions: []string{"19.0.0"}},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mergeExisting() mismatch (-want +got):\n%s", diff)
+	}
+}
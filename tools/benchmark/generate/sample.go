@@ -0,0 +1,136 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/google/oss-rebuild/tools/benchmark"
+	"google.golang.org/api/option"
+)
+
+// deciles is the number of popularity strata into which sampled benchmarks are divided.
+const deciles = 10
+
+// decileSample selects up to size candidates uniformly at random from each popularity decile.
+//
+// Candidates must be ordered by descending popularity. The selection is
+// deterministic for a given seed and candidate order and is returned in
+// decile order along with the number selected from each decile.
+func decileSample[T any](ranked []T, size int, seed int64) ([]T, []int) {
+	rng := rand.New(rand.NewSource(seed))
+	var selected []T
+	counts := make([]int, deciles)
+	for d := 0; d < deciles; d++ {
+		lo, hi := d*len(ranked)/deciles, (d+1)*len(ranked)/deciles
+		perm := rng.Perm(hi - lo)
+		for _, i := range perm[:min(size, len(perm))] {
+			selected = append(selected, ranked[lo+i])
+		}
+		counts[d] = min(size, len(perm))
+	}
+	return selected, counts
+}
+
+// depsDevSample returns a benchmark of a random sample of the packages of a
+// deps.dev system, stratified by popularity decile.
+//
+// Popularity is measured by the number of dependent packages so, unlike the
+// top-N benchmarks, the sample extends into the long tail of each ecosystem.
+// NOTE: Packages with no dependents are absent from the dependency graph and
+// so are not sampled.
+func depsDevSample(ecosystem, system string) RebuildBenchmark {
+	const decileSize = 50
+	return RebuildBenchmark{
+		Filename: fmt.Sprintf("%s_sample_%d.json", ecosystem, deciles*decileSize),
+		Generator: func(ctx context.Context, cp *checkpoint) (ps benchmark.PackageSet, err error) {
+			now, err := cached(cp, "now", func() (time.Time, error) { return time.Now(), nil })
+			if err != nil {
+				return ps, err
+			}
+			client, err := bigquery.NewClient(ctx, *project, option.WithQuotaProject(*project))
+			if err != nil {
+				return ps, fmt.Errorf("creating bigquery client: %v", err)
+			}
+			// NOTE: The snapshot is checkpointed so a resumed run queries the same data.
+			snapshot, err := cached(cp, "snapshot", func() (time.Time, error) { return latestDepsDevSnapshot(ctx, client) })
+			if err != nil {
+				return ps, err
+			}
+			// NOTE: Ties are broken by name so the ranking, and thus the sample, is reproducible.
+			query := client.Query(`
+SELECT
+  Name AS Package,
+  ARRAY_AGG(Version ORDER BY Dependents DESC, Version LIMIT 1)[OFFSET(0)] AS Version,
+  SUM(Dependents) AS Dependents
+FROM (
+  SELECT
+    T.` + "`" + `To` + "`" + `.Name AS Name,
+    T.` + "`" + `To` + "`" + `.Version AS Version,
+    COUNT(DISTINCT T.` + "`" + `From` + "`" + `.Name) AS Dependents
+  FROM
+    ` + "`" + `bigquery-public-data.deps_dev_v1.DependencyGraphEdges` + "`" + ` T
+  WHERE
+    T.SnapshotAt = @snapshot
+    AND T.System = @system
+    AND STRPOS(T.` + "`" + `To` + "`" + `.Version, "-") = 0
+  GROUP BY
+    Name,
+    Version)
+GROUP BY
+  Name
+ORDER BY
+  Dependents DESC,
+  Package
+`)
+			query.Parameters = []bigquery.QueryParameter{{Name: "snapshot", Value: snapshot}, {Name: "system", Value: system}}
+			type row struct {
+				Package    string
+				Version    string
+				Dependents int64
+			}
+			// Get dependent-ordered packages from deps.dev's dependency table.
+			rows, err := cached(cp, "rows", func() ([]row, error) { return queryRows[row](ctx, query) })
+			if err != nil {
+				return ps, fmt.Errorf("querying packages: %v", err)
+			}
+			// NOTE: Absent an explicit seed, the seed is derived from the snapshot so regeneration is reproducible.
+			s := *seed
+			if s == 0 {
+				s = snapshot.Unix()
+			}
+			selected, counts := decileSample(rows, decileSize, s)
+			for _, r := range selected {
+				ps.Packages = append(ps.Packages, benchmark.Package{Name: r.Package, Ecosystem: ecosystem, Versions: []string{r.Version}})
+			}
+			ps.Count = len(selected)
+			ps.Updated = now
+			params := map[string]string{"candidates": strconv.Itoa(len(rows)), "stratum": "dependents_decile", "stratum_size": strconv.Itoa(decileSize), "seed": strconv.FormatInt(s, 10)}
+			for d, n := range counts {
+				params["count_decile_"+strconv.Itoa(d+1)] = strconv.Itoa(n)
+			}
+			ps.Provenance = []benchmark.Provenance{{
+				Parameters: params,
+				Snapshots:  map[string]time.Time{depsDevSnapshotsTable: snapshot},
+			}}
+			return
+		},
+	}
+}
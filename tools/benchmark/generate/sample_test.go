@@ -0,0 +1,57 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDecileSample(t *testing.T) {
+	var ranked []int
+	for i := 0; i < 95; i++ {
+		ranked = append(ranked, i)
+	}
+	got, counts := decileSample(ranked, 3, 42)
+	if diff := cmp.Diff([]int{3, 3, 3, 3, 3, 3, 3, 3, 3, 3}, counts); diff != "" {
+		t.Errorf("decileSample() counts mismatch (-want +got):\n%s", diff)
+	}
+	if len(got) != 30 {
+		t.Fatalf("len(decileSample()) = %d, want 30", len(got))
+	}
+	// Each selection falls within its decile's range of ranks.
+	for i, v := range got {
+		d := i / 3
+		if lo, hi := d*95/10, (d+1)*95/10; v < lo || v >= hi {
+			t.Errorf("decileSample()[%d] = %d, want in decile [%d, %d)", i, v, lo, hi)
+		}
+	}
+	again, _ := decileSample(ranked, 3, 42)
+	if diff := cmp.Diff(got, again); diff != "" {
+		t.Errorf("decileSample() not reproducible (-first +second):\n%s", diff)
+	}
+	if other, _ := decileSample(ranked, 3, 7); cmp.Equal(got, other) {
+		t.Errorf("decileSample() returned identical samples for different seeds")
+	}
+	// Small deciles are exhausted rather than padded.
+	got, counts = decileSample(ranked[:15], 3, 42)
+	if diff := cmp.Diff([]int{1, 2, 1, 2, 1, 2, 1, 2, 1, 2}, counts); diff != "" {
+		t.Errorf("decileSample() counts mismatch (-want +got):\n%s", diff)
+	}
+	if len(got) != 15 {
+		t.Errorf("len(decileSample()) = %d, want 15", len(got))
+	}
+}
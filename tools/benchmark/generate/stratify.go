@@ -0,0 +1,228 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/google/oss-rebuild/pkg/registry/pypi"
+	"github.com/google/oss-rebuild/tools/benchmark"
+	"google.golang.org/api/option"
+)
+
+// stratifiedSample selects up to size candidates from each of the provided strata.
+//
+// Candidates are considered in order and classify is only called while some
+// stratum remains unfilled. Candidates classified into an unlisted stratum,
+// or for which classify reports false, are skipped.
+func stratifiedSample[T any](candidates []T, strata []string, size int, classify func(T) (string, bool, error)) ([]T, map[string]int, error) {
+	counts := make(map[string]int)
+	for _, s := range strata {
+		counts[s] = 0
+	}
+	var selected []T
+	remaining := len(strata)
+	for _, c := range candidates {
+		if remaining == 0 {
+			break
+		}
+		s, ok, err := classify(c)
+		if err != nil {
+			return nil, nil, err
+		}
+		n, listed := counts[s]
+		if !ok || !listed || n >= size {
+			continue
+		}
+		counts[s]++
+		if counts[s] == size {
+			remaining--
+		}
+		selected = append(selected, c)
+	}
+	return selected, counts, nil
+}
+
+// pythonBuildSystems are the strata into which PyPI packages are sampled.
+var pythonBuildSystems = []string{"setuptools", "hatchling", "poetry", "flit", "other"}
+
+// wheelBuildSystem returns the build system that generated a wheel from the contents of its WHEEL file.
+func wheelBuildSystem(wheel []byte) (string, bool) {
+	s := bufio.NewScanner(bytes.NewReader(wheel))
+	for s.Scan() {
+		generator, found := strings.CutPrefix(s.Text(), "Generator:")
+		if !found {
+			continue
+		}
+		name, _, _ := strings.Cut(strings.TrimSpace(generator), " ")
+		switch name {
+		case "bdist_wheel", "setuptools":
+			return "setuptools", true
+		case "hatchling":
+			return "hatchling", true
+		case "poetry", "poetry-core":
+			return "poetry", true
+		case "flit", "flit_core":
+			return "flit", true
+		default:
+			return "other", true
+		}
+	}
+	return "", false
+}
+
+// fetchWheelBuildSystem downloads the wheel and returns the build system that generated it.
+func fetchWheelBuildSystem(ctx context.Context, registry pypi.Registry, project, version, filename string) (string, bool, error) {
+	r, err := registry.Artifact(ctx, project, version, filename)
+	if err != nil {
+		return "", false, err
+	}
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return "", false, fmt.Errorf("reading wheel: %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		// NOTE: Malformed wheels are excluded rather than failing generation.
+		return "", false, nil
+	}
+	for _, f := range zr.File {
+		if !strings.HasSuffix(f.Name, ".dist-info/WHEEL") {
+			continue
+		}
+		fr, err := f.Open()
+		if err != nil {
+			return "", false, nil
+		}
+		wheel, err := io.ReadAll(fr)
+		fr.Close()
+		if err != nil {
+			return "", false, nil
+		}
+		system, ok := wheelBuildSystem(wheel)
+		return system, ok, nil
+	}
+	return "", false, nil
+}
+
+var pypiStratified500Pure = RebuildBenchmark{
+	Filename: "pypi_stratified_500_pure.json",
+	Generator: func(ctx context.Context, cp *checkpoint) (ps benchmark.PackageSet, err error) {
+		const (
+			candidateLimit = 20000
+			stratumSize    = 100
+		)
+		now, err := cached(cp, "now", func() (time.Time, error) { return time.Now(), nil })
+		if err != nil {
+			return ps, err
+		}
+		// See pypiTop250Pure for the rationale behind sampling a Wednesday.
+		lastWednesday := now.AddDate(0, 0, -1)
+		for ; lastWednesday.Weekday() != time.Wednesday; lastWednesday = lastWednesday.AddDate(0, 0, -1) {
+		}
+		day := time.Date(lastWednesday.Year(), lastWednesday.Month(), lastWednesday.Day(), 0, 0, 0, 0, time.UTC)
+		client, err := bigquery.NewClient(ctx, *project, option.WithQuotaProject(*project))
+		if err != nil {
+			return ps, fmt.Errorf("creating bigquery client: %v", err)
+		}
+		query := client.Query(`
+SELECT
+  COUNT(*) AS Downloads,
+  file.project as Project,
+  file.version as Version,
+  file.filename as Filename
+FROM
+  ` + "`" + pypiDownloadsTable + "`" + `
+WHERE
+  TIMESTAMP_TRUNC(timestamp, DAY) = TIMESTAMP("` + day.Format(time.DateOnly) + `")
+  AND ENDS_WITH(file.filename, "none-any.whl")
+GROUP BY
+  file.project, file.version, file.filename
+ORDER BY
+  Downloads DESC
+LIMIT ` + strconv.Itoa(candidateLimit) + `
+`)
+		type row struct {
+			Downloads int64
+			Project   string
+			Version   string
+			Filename  string
+		}
+		// Get download-ordered pure wheels from PyPI's download table.
+		rows, err := cached(cp, "rows", func() ([]row, error) { return queryRows[row](ctx, query) })
+		if err != nil {
+			return ps, fmt.Errorf("querying packages: %v", err)
+		}
+		// Retain the most downloaded release of each project.
+		var candidates []row
+		seen := make(map[string]bool)
+		for _, r := range rows {
+			if seen[r.Project] || strings.ContainsRune(r.Version, '-') {
+				continue
+			}
+			seen[r.Project] = true
+			candidates = append(candidates, r)
+		}
+		// NOTE: The shuffle is seeded by the sampled day so the selection is reproducible.
+		seed := day.Unix()
+		rand.New(rand.NewSource(seed)).Shuffle(len(candidates), func(i, j int) {
+			candidates[i], candidates[j] = candidates[j], candidates[i]
+		})
+		registry := pypi.HTTPRegistry{Client: http.DefaultClient}
+		selected, counts, err := stratifiedSample(candidates, pythonBuildSystems, stratumSize, func(r row) (string, bool, error) {
+			type classification struct {
+				System string
+				OK     bool
+			}
+			c, err := cached(cp, "wheel-"+r.Project, func() (classification, error) {
+				system, ok, err := fetchWheelBuildSystem(ctx, registry, r.Project, r.Version, r.Filename)
+				return classification{system, ok}, err
+			})
+			if err != nil {
+				return "", false, fmt.Errorf("classifying %s: %v", r.Filename, err)
+			}
+			return c.System, c.OK, nil
+		})
+		if err != nil {
+			return ps, err
+		}
+		for _, r := range selected {
+			ps.Packages = append(ps.Packages, benchmark.Package{Name: r.Project, Ecosystem: "pypi", Versions: []string{r.Version}})
+		}
+		ps.Count = len(selected)
+		ps.Updated = now
+		params := map[string]string{"candidate_limit": strconv.Itoa(candidateLimit), "stratum": "build_system", "stratum_size": strconv.Itoa(stratumSize), "seed": strconv.FormatInt(seed, 10)}
+		for s, n := range counts {
+			params["count_"+s] = strconv.Itoa(n)
+		}
+		ps.Provenance = []benchmark.Provenance{{
+			Parameters: params,
+			Snapshots:  map[string]time.Time{pypiDownloadsTable: day},
+		}}
+		return
+	},
+}
@@ -0,0 +1,65 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestStratifiedSample(t *testing.T) {
+	strata := map[string]string{"a": "x", "b": "y", "c": "x", "d": "z", "e": "x", "f": "y", "g": "", "h": "y"}
+	var classified []string
+	classify := func(c string) (string, bool, error) {
+		classified = append(classified, c)
+		s := strata[c]
+		return s, s != "", nil
+	}
+	got, counts, err := stratifiedSample([]string{"a", "b", "c", "d", "g", "e", "f", "h"}, []string{"x", "y"}, 2, classify)
+	if err != nil {
+		t.Fatalf("stratifiedSample() error = %v", err)
+	}
+	if diff := cmp.Diff([]string{"a", "b", "c", "f"}, got); diff != "" {
+		t.Errorf("stratifiedSample() mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(map[string]int{"x": 2, "y": 2}, counts); diff != "" {
+		t.Errorf("stratifiedSample() counts mismatch (-want +got):\n%s", diff)
+	}
+	// Classification stops once all strata are full.
+	if diff := cmp.Diff([]string{"a", "b", "c", "d", "g", "e", "f"}, classified); diff != "" {
+		t.Errorf("classified mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestWheelBuildSystem(t *testing.T) {
+	for _, tc := range []struct {
+		wheel  string
+		want   string
+		wantOK bool
+	}{
+		{"Wheel-Version: 1.0\nGenerator: bdist_wheel (0.41.2)\nRoot-Is-Purelib: true\n", "setuptools", true},
+		{"Wheel-Version: 1.0\nGenerator: hatchling 1.18.0\n", "hatchling", true},
+		{"Wheel-Version: 1.0\nGenerator: poetry-core 1.6.1\n", "poetry", true},
+		{"Wheel-Version: 1.0\nGenerator: flit 3.9.0\n", "flit", true},
+		{"Wheel-Version: 1.0\nGenerator: maturin (1.4.0)\n", "other", true},
+		{"Wheel-Version: 1.0\n", "", false},
+	} {
+		got, ok := wheelBuildSystem([]byte(tc.wheel))
+		if got != tc.want || ok != tc.wantOK {
+			t.Errorf("wheelBuildSystem(%q) = %q, %v, want %q, %v", tc.wheel, got, ok, tc.want, tc.wantOK)
+		}
+	}
+}
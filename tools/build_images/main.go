@@ -0,0 +1,59 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package main builds the project's service images and writes their digests.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/google/oss-rebuild/build/container"
+)
+
+var (
+	images     = flag.String("images", strings.Join(container.Services(), ","), "comma-separated list of images to build")
+	repository = flag.String("repository", "", "the repository with which to tag the images e.g. us-docker.pkg.dev/project/repo")
+	push       = flag.Bool("push", false, "whether to push the images to the repository and record their registry digests")
+	digests    = flag.String("digests", "", "the path to which the digests manifest should be written. Defaults to stdout")
+)
+
+func main() {
+	flag.Parse()
+	if *push && *repository == "" {
+		log.Fatal("--push requires --repository")
+	}
+	ctx := context.Background()
+	m, err := container.BuildGraph(ctx, strings.Split(*images, ","), container.Options{Repository: *repository, Push: *push})
+	if err != nil {
+		log.Fatal(err)
+	}
+	out := os.Stdout
+	if *digests != "" {
+		out, err = os.Create(*digests)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer out.Close()
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(m); err != nil {
+		log.Fatal(err)
+	}
+}
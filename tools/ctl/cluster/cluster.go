@@ -0,0 +1,136 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cluster groups failed rebuilds by the similarity of their failures.
+package cluster
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/google/oss-rebuild/tools/ctl/rundex"
+)
+
+// DefaultThreshold is the minimum similarity for a failure to join a cluster.
+const DefaultThreshold = 0.6
+
+// Cluster is a set of failures similar to its Exemplar.
+type Cluster struct {
+	// Exemplar is the first failure assigned to the cluster.
+	Exemplar rundex.Rebuild
+	Members  []rundex.Rebuild
+}
+
+// Size returns the number of failures in the cluster.
+func (c *Cluster) Size() int {
+	return len(c.Members)
+}
+
+var (
+	hexRE    = regexp.MustCompile(`\b[0-9a-fA-F]{7,}\b`)
+	numberRE = regexp.MustCompile(`[0-9]+`)
+)
+
+// failureText returns the text characterizing a failure: its verdict and, if
+// present, the terminal error block of its logs.
+func failureText(r rundex.Rebuild) string {
+	text := r.Message
+	if r.LogSummary != nil && r.LogSummary.Error != "" {
+		text += "\n" + r.LogSummary.Error
+	}
+	return text
+}
+
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '<' && r != '>' && r != '_'
+	})
+}
+
+// Tokens returns the normalized tokens of a failure.
+//
+// Target-specific details such as the package name, hashes, and numbers are
+// replaced with placeholders so failures of distinct targets with the same
+// cause share tokens.
+func Tokens(r rundex.Rebuild) map[string]bool {
+	text := hexRE.ReplaceAllString(failureText(r), " <hex> ")
+	text = numberRE.ReplaceAllString(text, " <n> ")
+	pkgWords := make(map[string]bool)
+	for _, w := range words(r.Package) {
+		pkgWords[w] = true
+	}
+	tokens := make(map[string]bool)
+	for _, w := range words(text) {
+		if pkgWords[w] {
+			w = "<pkg>"
+		}
+		tokens[w] = true
+	}
+	return tokens
+}
+
+// Similarity returns the Jaccard similarity of two token sets.
+func Similarity(a, b map[string]bool) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	var shared int
+	for t := range a {
+		if b[t] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// Failures clusters the unsuccessful rebuilds by the similarity of their failures.
+//
+// Each failure joins the cluster whose exemplar it most resembles provided
+// their similarity meets threshold, otherwise it becomes the exemplar of a new
+// cluster. Clusters are returned largest first.
+func Failures(rebuilds map[string]rundex.Rebuild, threshold float64) []*Cluster {
+	var failed []rundex.Rebuild
+	for _, r := range rebuilds {
+		if !r.Success {
+			failed = append(failed, r)
+		}
+	}
+	// NOTE: Sort to make exemplar selection deterministic.
+	slices.SortFunc(failed, func(a, b rundex.Rebuild) int {
+		return strings.Compare(a.ID(), b.ID())
+	})
+	var clusters []*Cluster
+	var exemplarTokens []map[string]bool
+	for _, r := range failed {
+		tokens := Tokens(r)
+		best, bestSim := -1, 0.
+		for i, et := range exemplarTokens {
+			if sim := Similarity(tokens, et); sim >= threshold && (best == -1 || sim > bestSim) {
+				best, bestSim = i, sim
+			}
+		}
+		if best == -1 {
+			clusters = append(clusters, &Cluster{Exemplar: r})
+			exemplarTokens = append(exemplarTokens, tokens)
+			best = len(clusters) - 1
+		}
+		clusters[best].Members = append(clusters[best].Members, r)
+	}
+	slices.SortStableFunc(clusters, func(a, b *Cluster) int {
+		return b.Size() - a.Size()
+	})
+	return clusters
+}
@@ -0,0 +1,76 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/tools/ctl/rundex"
+)
+
+func failure(pkg, version, msg, errBlock string) rundex.Rebuild {
+	var rb rundex.Rebuild
+	rb.Ecosystem = "npm"
+	rb.Package = pkg
+	rb.Version = version
+	rb.Artifact = pkg + "-" + version + ".tgz"
+	rb.Message = msg
+	if errBlock != "" {
+		rb.LogSummary = &rebuild.LogSummary{Error: errBlock}
+	}
+	return rb
+}
+
+func TestTokens(t *testing.T) {
+	a := Tokens(failure("left-pad", "1.3.0", "mismatched version [expected=1.3.0,actual=1.2.9]", ""))
+	b := Tokens(failure("right-pad", "2.0.1", "mismatched version [expected=2.0.1,actual=2.0.0]", ""))
+	if diff := cmp.Diff(a, b); diff != "" {
+		t.Errorf("Tokens() of equivalent failures differ (-a +b):\n%s", diff)
+	}
+	c := Tokens(failure("left-pad", "1.3.0", "Clone failed [repo=https://github.com/stevemao/left-pad]", ""))
+	if sim := Similarity(a, c); sim >= DefaultThreshold {
+		t.Errorf("Similarity() of distinct failures = %v, want < %v", sim, DefaultThreshold)
+	}
+}
+
+func TestFailures(t *testing.T) {
+	rebuilds := map[string]rundex.Rebuild{}
+	add := func(r rundex.Rebuild) { rebuilds[r.ID()] = r }
+	add(failure("a", "1.0.0", "rebuild failure: failed to execute strategy.Build", "npm ERR! code ELIFECYCLE\nnpm ERR! errno 1\nnpm ERR! a@1.0.0 build: `tsc`"))
+	add(failure("b", "2.1.0", "rebuild failure: failed to execute strategy.Build", "npm ERR! code ELIFECYCLE\nnpm ERR! errno 2\nnpm ERR! b@2.1.0 build: `tsc`"))
+	add(failure("c", "0.0.1", "rebuild failure: failed to execute strategy.Build", "npm ERR! code ELIFECYCLE\nnpm ERR! errno 1\nnpm ERR! c@0.0.1 build: `tsc`"))
+	add(failure("d", "3.0.0", "Clone failed [repo=https://github.com/foo/d]", ""))
+	success := failure("e", "1.0.0", "", "")
+	success.Success = true
+	add(success)
+	clusters := Failures(rebuilds, DefaultThreshold)
+	var got [][]string
+	for _, c := range clusters {
+		var ids []string
+		for _, m := range c.Members {
+			ids = append(ids, m.Package)
+		}
+		got = append(got, ids)
+	}
+	want := [][]string{{"a", "b", "c"}, {"d"}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Failures() mismatch (-want +got):\n%s", diff)
+	}
+	if clusters[0].Exemplar.Package != "a" {
+		t.Errorf("Failures() exemplar = %s, want a", clusters[0].Exemplar.Package)
+	}
+}
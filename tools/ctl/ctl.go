@@ -28,26 +28,54 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"slices"
 	"sort"
 	"strings"
+	"text/tabwriter"
 	"time"
 
+	kms "cloud.google.com/go/kms/apiv1"
+	"cloud.google.com/go/kms/apiv1/kmspb"
+	gcs "cloud.google.com/go/storage"
 	"github.com/cheggaaa/pb"
 	"github.com/go-git/go-billy/v5/osfs"
 	"github.com/google/oss-rebuild/internal/api"
+	"github.com/google/oss-rebuild/internal/api/apiservice"
 	"github.com/google/oss-rebuild/internal/api/inferenceservice"
+	"github.com/google/oss-rebuild/internal/gcb"
 	"github.com/google/oss-rebuild/internal/oauth"
 	"github.com/google/oss-rebuild/internal/taskqueue"
+	"github.com/google/oss-rebuild/internal/verifier"
+	"github.com/google/oss-rebuild/pkg/attestation"
+	"github.com/google/oss-rebuild/pkg/attestation/verify"
+	"github.com/google/oss-rebuild/pkg/kmsdsse"
+	"github.com/google/oss-rebuild/pkg/rebuild/meta"
 	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
 	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+	cratesreg "github.com/google/oss-rebuild/pkg/registry/cratesio"
+	debianreg "github.com/google/oss-rebuild/pkg/registry/debian"
+	gomodreg "github.com/google/oss-rebuild/pkg/registry/gomod"
+	npmreg "github.com/google/oss-rebuild/pkg/registry/npm"
+	pypireg "github.com/google/oss-rebuild/pkg/registry/pypi"
+	rubygemsreg "github.com/google/oss-rebuild/pkg/registry/rubygems"
 	"github.com/google/oss-rebuild/tools/benchmark"
+	"github.com/google/oss-rebuild/tools/ctl/cluster"
+	"github.com/google/oss-rebuild/tools/ctl/drift"
+	"github.com/google/oss-rebuild/tools/ctl/flaky"
 	"github.com/google/oss-rebuild/tools/ctl/ide"
 	"github.com/google/oss-rebuild/tools/ctl/localfiles"
+	"github.com/google/oss-rebuild/tools/ctl/notify"
+	"github.com/google/oss-rebuild/tools/ctl/repair"
 	"github.com/google/oss-rebuild/tools/ctl/rundex"
+	"github.com/in-toto/in-toto-golang/in_toto"
 	"github.com/pkg/errors"
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
 	"github.com/spf13/cobra"
+	"google.golang.org/api/cloudbuild/v1"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
 	"gopkg.in/yaml.v3"
 )
 
@@ -149,7 +177,7 @@ var tui = &cobra.Command{
 }
 
 var getResults = &cobra.Command{
-	Use:   "get-results -project <ID> -run <ID> [-bench <benchmark.json>] [-filter <verdict>] [-sample N] [-format=summary|bench]",
+	Use:   "get-results -project <ID> -run <ID> [-bench <benchmark.json>] [-filter <verdict>] [-sample N] [-format=summary|bench|clusters]",
 	Short: "Analyze rebuild results",
 	Args:  cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
@@ -170,6 +198,15 @@ var getResults = &cobra.Command{
 			log.Fatal(err)
 		}
 		log.Printf("Fetched %d rebuilds", len(rebuilds))
+		if *excludeFlaky {
+			tagged, err := (&flaky.FirestoreTagger{Client: fireClient.Client}).Tagged(cmd.Context())
+			if err != nil {
+				log.Fatal(err)
+			}
+			if excluded := flaky.Exclude(rebuilds, tagged); len(excluded) > 0 {
+				log.Printf("Excluded %d flaky targets", len(excluded))
+			}
+		}
 		byCount := rundex.GroupRebuilds(rebuilds)
 		if len(byCount) == 0 {
 			log.Println("No results")
@@ -181,13 +218,20 @@ var getResults = &cobra.Command{
 			for _, vg := range byCount {
 				fmt.Printf(" %4d - %s (example: %s)\n", vg.Count, vg.Msg[:min(len(vg.Msg), 1000)], vg.Examples[0].ID())
 			}
-			successes := 0
-			for _, r := range rebuilds {
-				if r.Success {
-					successes++
+			successes := successCount(rebuilds)
+			fmt.Printf("%d succeeded of %d  (%2.1f%%)\n", successes, len(rebuilds), 100.*float64(successes)/float64(len(rebuilds)))
+		case "clusters":
+			clusters := cluster.Failures(rebuilds, *clusterThreshold)
+			log.Printf("Failure clusters (%d failures):", len(rebuilds)-successCount(rebuilds))
+			for _, c := range clusters {
+				ex := c.Exemplar
+				fmt.Printf(" %4d - %s (exemplar: %s)\n", c.Size(), ex.Message[:min(len(ex.Message), 1000)], ex.ID())
+				if ex.LogSummary != nil && ex.LogSummary.Error != "" {
+					for _, line := range strings.Split(ex.LogSummary.Error, "\n") {
+						fmt.Printf("        | %s\n", line)
+					}
 				}
 			}
-			fmt.Printf("%d succeeded of %d  (%2.1f%%)\n", successes, len(rebuilds), 100.*float64(successes)/float64(len(rebuilds)))
 		case "bench":
 			var ps benchmark.PackageSet
 			if *sample > 0 && *sample < len(rebuilds) {
@@ -233,6 +277,32 @@ var getResults = &cobra.Command{
 	},
 }
 
+func successCount(rebuilds map[string]rundex.Rebuild) int {
+	var n int
+	for _, r := range rebuilds {
+		if r.Success {
+			n++
+		}
+	}
+	return n
+}
+
+// parseLabels parses a comma-separated list of key=value pairs.
+func parseLabels(s string) (map[string]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	labels := make(map[string]string)
+	for _, kv := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || k == "" {
+			return nil, errors.Errorf("malformed label: %q", kv)
+		}
+		labels[k] = v
+	}
+	return labels, nil
+}
+
 func isCloudRun(u *url.URL) bool {
 	return strings.HasSuffix(u.Host, ".run.app")
 }
@@ -280,10 +350,16 @@ var runBenchmark = &cobra.Command{
 			run = time.Now().UTC().Format(time.RFC3339)
 		} else {
 			stub := api.Stub[schema.CreateRunRequest, schema.Run](client, *apiURL.JoinPath("runs"))
+			runLabels, err := parseLabels(*labels)
+			if err != nil {
+				log.Fatal(errors.Wrap(err, "parsing labels"))
+			}
 			resp, err := stub(ctx, schema.CreateRunRequest{
 				BenchmarkName: filepath.Base(args[1]),
 				BenchmarkHash: hex.EncodeToString(set.Hash(sha256.New())),
 				Type:          string(mode),
+				ExternalID:    *externalID,
+				Labels:        runLabels,
 			})
 			if err != nil {
 				log.Fatal(errors.Wrap(err, "creating run"))
@@ -349,7 +425,7 @@ var runBenchmark = &cobra.Command{
 }
 
 var runOne = &cobra.Command{
-	Use:   "run-one smoketest|attest --api <URI> --ecosystem <ecosystem> --package <name> --version <version> [--artifact <name>] [--strategy <strategy.yaml>] [--strategy-from-repo]",
+	Use:   "run-one smoketest|attest --api <URI> --ecosystem <ecosystem> --package <name> --version <version> [--artifact <name>] [--strategy <strategy.yaml>] [--strategy-from-repo] [--toolchain-matrix <versions> [--def-dir <dir>]]",
 	Short: "Run benchmark",
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
@@ -399,30 +475,46 @@ var runOne = &cobra.Command{
 				}
 			}
 		}
+		var syscallPolicyPacks []string
+		if *syscallPolicy != "" {
+			syscallPolicyPacks = strings.Split(*syscallPolicy, ",")
+		}
 		var verdicts []schema.Verdict
 		{
 			if mode == benchmark.SmoketestMode {
+				var matrix []string
+				if *toolchainMatrix != "" {
+					matrix = strings.Split(*toolchainMatrix, ",")
+				}
 				stub := api.Stub[schema.SmoketestRequest, schema.SmoketestResponse](client, *apiURL.JoinPath("smoketest"))
 				resp, err := stub(ctx, schema.SmoketestRequest{
-					Ecosystem: rebuild.Ecosystem(*ecosystem),
-					Package:   *pkg,
-					Versions:  []string{*version},
-					Strategy:  strategy,
+					Ecosystem:       rebuild.Ecosystem(*ecosystem),
+					Package:         *pkg,
+					Versions:        []string{*version},
+					Strategy:        strategy,
+					ToolchainMatrix: matrix,
 				})
 				if err != nil {
 					log.Fatal(errors.Wrap(err, "running smoketest"))
 				}
 				verdicts = resp.Verdicts
+				if len(matrix) > 0 && *defDir != "" {
+					if err := saveMatrixStrategies(ctx, *defDir, verdicts); err != nil {
+						log.Fatal(errors.Wrap(err, "saving build definitions"))
+					}
+				}
 			} else {
 				stub := api.Stub[schema.RebuildPackageRequest, schema.Verdict](client, *apiURL.JoinPath("rebuild"))
 				resp, err := stub(ctx, schema.RebuildPackageRequest{
-					Ecosystem:         rebuild.Ecosystem(*ecosystem),
-					Package:           *pkg,
-					Version:           *version,
-					Artifact:          *artifact,
-					UseNetworkProxy:   *useNetworkProxy,
-					UseSyscallMonitor: *useSyscallMonitor,
-					ID:                time.Now().UTC().Format(time.RFC3339),
+					Ecosystem:          rebuild.Ecosystem(*ecosystem),
+					Package:            *pkg,
+					Version:            *version,
+					Artifact:           *artifact,
+					UseNetworkProxy:    *useNetworkProxy,
+					UseSyscallMonitor:  *useSyscallMonitor,
+					SyscallPolicyPacks: syscallPolicyPacks,
+					Hermetic:           *hermetic,
+					ID:                 time.Now().UTC().Format(time.RFC3339),
 				})
 				if err != nil {
 					log.Fatal(errors.Wrap(err, "running attest"))
@@ -440,13 +532,48 @@ var runOne = &cobra.Command{
 	},
 }
 
+// saveMatrixStrategies writes the strategy of each successful matrix rebuild to the build definitions in dir.
+func saveMatrixStrategies(ctx context.Context, dir string, verdicts []schema.Verdict) error {
+	fs, err := osfs.New("/").Chroot(dir)
+	if err != nil {
+		return errors.Wrap(err, "creating asset store in build def dir")
+	}
+	buildDefs := rebuild.NewFilesystemAssetStore(fs)
+	for _, v := range verdicts {
+		if v.Message != "" || len(v.Matrix) == 0 {
+			continue
+		}
+		s := v.StrategyOneof
+		s.Provenance = nil
+		w, err := buildDefs.Writer(ctx, rebuild.BuildDef.For(v.Target))
+		if err != nil {
+			return errors.Wrap(err, "opening build definition")
+		}
+		if err := yaml.NewEncoder(w).Encode(&s); err != nil {
+			w.Close()
+			return errors.Wrap(err, "writing build definition")
+		}
+		if err := w.Close(); err != nil {
+			return errors.Wrap(err, "writing build definition")
+		}
+		log.Printf("Saved build definition for %s@%s to %s", v.Target.Package, v.Target.Version, buildDefs.URL(rebuild.BuildDef.For(v.Target)).Path)
+	}
+	return nil
+}
+
 var listRuns = &cobra.Command{
-	Use:   "list-runs -project <ID> [ -bench <benchmark.json> ]",
+	Use:   "list-runs -project <ID> [ -bench <benchmark.json> ] [ -external-id <ID> ] [ -labels <key=value,...> ]",
 	Short: "List runs",
 	Args:  cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
 		ctx := context.Background()
 		var opts rundex.FetchRunsOpts
+		opts.ExternalID = *externalID
+		var err error
+		opts.Labels, err = parseLabels(*labels)
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "parsing labels"))
+		}
 		if *bench != "" {
 			log.Printf("Extracting benchmark %s...\n", filepath.Base(*bench))
 			set, err := benchmark.ReadBenchmark(*bench)
@@ -469,7 +596,19 @@ var listRuns = &cobra.Command{
 		}
 		var count int
 		for _, r := range runs {
-			fmt.Printf("  %s [bench=%s hash=%s]\n", r.ID, r.BenchmarkName, r.BenchmarkHash)
+			fmt.Printf("  %s [bench=%s hash=%s]", r.ID, r.BenchmarkName, r.BenchmarkHash)
+			if r.ExternalID != "" {
+				fmt.Printf(" external_id=%s", r.ExternalID)
+			}
+			keys := make([]string, 0, len(r.Labels))
+			for k := range r.Labels {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				fmt.Printf(" %s=%s", k, r.Labels[k])
+			}
+			fmt.Println()
 			count++
 		}
 		switch count {
@@ -564,6 +703,758 @@ var infer = &cobra.Command{
 	},
 }
 
+var viewAttestations = &cobra.Command{
+	Use:   "view-attestations --ecosystem <ecosystem> --package <name> [--version <version>] [--attestation-bucket <bucket>] [--verify] [--project <ID>] [--format summary|payload]",
+	Short: "View published attestation bundles for a package",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		if *ecosystem == "" || *pkg == "" {
+			log.Fatal("ecosystem and package must be provided")
+		}
+		verifier, err := verify.NewEnvelopeVerifier(ctx, verify.Options{TrustAll: !*verifySignatures})
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "creating verifier"))
+		}
+		// Keep the latest attempt for each target to display alongside its attestation.
+		attempts := make(map[rebuild.Target]rundex.Rebuild)
+		if *project != "" {
+			client, err := rundex.NewFirestore(ctx, *project)
+			if err != nil {
+				log.Fatal(errors.Wrap(err, "creating firestore client"))
+			}
+			q := client.Client.CollectionGroup("attempts").Where("ecosystem", "==", *ecosystem).Where("package", "==", *pkg)
+			if *version != "" {
+				q = q.Where("version", "==", *version)
+			}
+			all := make(chan rundex.Rebuild)
+			cerr := rundex.DoQuery(ctx, q, rundex.NewRebuildFromFirestore, all)
+			for r := range all {
+				if prev, ok := attempts[r.Target()]; !ok || prev.Created.Before(r.Created) {
+					attempts[r.Target()] = r
+				}
+			}
+			if err := <-cerr; err != nil {
+				log.Fatal(errors.Wrap(err, "querying attempts"))
+			}
+		}
+		gcsClient, err := gcs.NewClient(ctx, option.WithoutAuthentication())
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "initializing GCS client"))
+		}
+		bucket := gcsClient.Bucket(*attestationBucket)
+		base := path.Join(*ecosystem, *pkg)
+		prefix := base + "/"
+		if *version != "" {
+			prefix = path.Join(base, *version) + "/"
+		}
+		it := bucket.Objects(ctx, &gcs.Query{Prefix: prefix})
+		var count int
+		for {
+			obj, err := it.Next()
+			if err == iterator.Done {
+				break
+			}
+			if err != nil {
+				log.Fatal(errors.Wrap(err, "listing objects"))
+			}
+			// Bundles are stored at <ecosystem>/<package>/<version>/<artifact>/rebuild.intoto.jsonl
+			parts := strings.Split(strings.TrimPrefix(obj.Name, base+"/"), "/")
+			if len(parts) != 3 || parts[2] != string(rebuild.AttestationBundleAsset) {
+				continue
+			}
+			t := rebuild.Target{Ecosystem: rebuild.Ecosystem(*ecosystem), Package: *pkg, Version: parts[0], Artifact: parts[1]}
+			r, err := bucket.Object(obj.Name).NewReader(ctx)
+			if err != nil {
+				log.Fatal(errors.Wrap(err, "opening attestation bundle"))
+			}
+			b, err := io.ReadAll(r)
+			r.Close()
+			if err != nil {
+				log.Fatal(errors.Wrap(err, "reading attestation bundle"))
+			}
+			bundle, err := attestation.NewBundle(ctx, b, verifier)
+			if err != nil {
+				log.Fatal(errors.Wrapf(err, "loading bundle %s", obj.Name))
+			}
+			count++
+			fmt.Printf("%s %s [updated=%s]\n", t.Version, t.Artifact, obj.Updated.Format(time.RFC3339))
+			if a, ok := attempts[t]; ok {
+				fmt.Printf("  rundex: success=%t run=%s created=%s\n", a.Success, a.RunID, a.Created.Format(time.RFC3339))
+				if a.Message != "" {
+					fmt.Printf("  rundex: %s\n", a.Message[:min(len(a.Message), 200)])
+				}
+			}
+			switch *format {
+			case "", "summary":
+				for _, p := range bundle.Payloads() {
+					fmt.Printf("  - %s\n", p.Predicate.BuildDefinition.BuildType)
+					for _, s := range p.Subject {
+						fmt.Printf("      subject: %s sha256:%s\n", s.Name, s.Digest["sha256"])
+					}
+					md := p.Predicate.RunDetails.BuildMetadata
+					if md.StartedOn != nil && md.FinishedOn != nil {
+						fmt.Printf("      built: %s (%s)\n", md.StartedOn.Format(time.RFC3339), md.FinishedOn.Sub(*md.StartedOn))
+					}
+				}
+			case "payload":
+				enc := json.NewEncoder(cmd.OutOrStdout())
+				enc.SetIndent("", "  ")
+				for _, p := range bundle.Payloads() {
+					if err := enc.Encode(p); err != nil {
+						log.Fatal(errors.Wrap(err, "encoding payload"))
+					}
+				}
+			default:
+				log.Fatalf("Unknown --format type: %s", *format)
+			}
+		}
+		switch count {
+		case 0:
+			fmt.Println("No attestations found")
+		case 1:
+			fmt.Println("1 attestation found")
+		default:
+			fmt.Printf("%d attestations found\n", count)
+		}
+	},
+}
+
+var attestations = &cobra.Command{
+	Use:   "attestations",
+	Short: "Manage published attestation bundles",
+}
+
+var repairAttestations = &cobra.Command{
+	Use:   "repair [--attestation-bucket <bucket>] [--prefix <prefix>] [--signing-key-version <key>] [--previous-key-versions <key>,...] [--project <ID> --metadata-bucket <bucket> --debug-storage <bucket>] [--apply]",
+	Short: "Re-sign, migrate, and regenerate published attestation bundles",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		key := *signingKeyVersion
+		if key == "" {
+			key = verify.OSSRebuildKey
+		}
+		kc, err := kms.NewKeyManagementClient(ctx)
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "creating KMS client"))
+		}
+		ckv, err := kc.GetCryptoKeyVersion(ctx, &kmspb.GetCryptoKeyVersionRequest{Name: key})
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "fetching CryptoKeyVersion"))
+		}
+		sv, err := kmsdsse.NewCloudKMSSignerVerifier(ctx, kc, ckv)
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "creating Cloud KMS signer"))
+		}
+		ev, err := dsse.NewEnvelopeVerifier(sv)
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "creating envelope verifier"))
+		}
+		var prev *dsse.EnvelopeVerifier
+		if *previousKeyVersions != "" {
+			var vs []dsse.Verifier
+			for _, name := range strings.Split(*previousKeyVersions, ",") {
+				ckv, err := kc.GetCryptoKeyVersion(ctx, &kmspb.GetCryptoKeyVersionRequest{Name: name})
+				if err != nil {
+					log.Fatal(errors.Wrapf(err, "fetching previous CryptoKeyVersion %s", name))
+				}
+				v, err := kmsdsse.NewCloudKMSSignerVerifier(ctx, kc, ckv)
+				if err != nil {
+					log.Fatal(errors.Wrapf(err, "creating Cloud KMS verifier for %s", name))
+				}
+				vs = append(vs, v)
+			}
+			prev, err = dsse.NewEnvelopeVerifier(vs...)
+			if err != nil {
+				log.Fatal(errors.Wrap(err, "creating previous key verifier"))
+			}
+		}
+		var attestor *verifier.Attestor
+		if *apply {
+			es, err := dsse.NewEnvelopeSigner(sv)
+			if err != nil {
+				log.Fatal(errors.Wrap(err, "creating envelope signer"))
+			}
+			// NOTE: An empty RunID stores assets at the published bundle path.
+			store, err := rebuild.NewGCSStore(context.WithValue(ctx, rebuild.RunID, ""), "gs://"+*attestationBucket)
+			if err != nil {
+				log.Fatal(errors.Wrap(err, "creating attestation store"))
+			}
+			attestor = &verifier.Attestor{Store: store, Signer: verifier.InTotoEnvelopeSigner{EnvelopeSigner: es}, AllowOverwrite: true}
+		}
+		// NOTE: Bundles that cannot be repaired can be regenerated from the
+		// stored metadata of their most recent successful rebuild.
+		var regenerate func(rebuild.Target) ([]*in_toto.ProvenanceStatementSLSA1, error)
+		if *project != "" {
+			if *metadataBucket == "" || *debugStorage == "" {
+				log.Fatal("--project requires --metadata-bucket and --debug-storage")
+			}
+			client, err := rundex.NewFirestore(ctx, *project)
+			if err != nil {
+				log.Fatal(errors.Wrap(err, "creating firestore client"))
+			}
+			regclient := http.DefaultClient
+			mux := rebuild.RegistryMux{
+				Debian:   debianreg.HTTPRegistry{Client: regclient},
+				CratesIO: cratesreg.HTTPRegistry{Client: regclient},
+				GoMod:    gomodreg.HTTPRegistry{Client: regclient},
+				NPM:      npmreg.HTTPRegistry{Client: regclient},
+				PyPI:     pypireg.HTTPRegistry{Client: regclient},
+				RubyGems: rubygemsreg.HTTPRegistry{Client: regclient},
+			}
+			regenerate = func(t rebuild.Target) ([]*in_toto.ProvenanceStatementSLSA1, error) {
+				attempt, err := client.LatestSuccess(ctx, t)
+				if err != nil {
+					return nil, err
+				}
+				strategy, err := attempt.Strategy.Strategy()
+				if err != nil {
+					return nil, errors.Wrap(err, "parsing strategy")
+				}
+				upstreamURI, err := apiservice.UpstreamURL(ctx, mux, t)
+				if err != nil {
+					return nil, errors.Wrap(err, "locating upstream artifact")
+				}
+				build, err := rebuild.DebugStoreFromContext(context.WithValue(context.WithValue(ctx, rebuild.DebugStoreID, *debugStorage), rebuild.RunID, attempt.RunID))
+				if err != nil {
+					return nil, errors.Wrap(err, "creating debug asset store")
+				}
+				outputs, err := rebuild.NewGCSStore(context.WithValue(ctx, rebuild.RunID, attempt.ObliviousID), "gs://"+*metadataBucket)
+				if err != nil {
+					return nil, errors.Wrap(err, "creating metadata store")
+				}
+				return repair.Regenerate(ctx, t, repair.Metadata{
+					ID:          attempt.ObliviousID,
+					Strategy:    strategy,
+					UpstreamURI: upstreamURI,
+					Build:       build,
+					Outputs:     outputs,
+				})
+			}
+		}
+		gcsClient, err := gcs.NewClient(ctx)
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "initializing GCS client"))
+		}
+		bucket := gcsClient.Bucket(*attestationBucket)
+		it := bucket.Objects(ctx, &gcs.Query{Prefix: *prefix})
+		var healthy, repaired, regenerated, unrecoverable int
+		for {
+			obj, err := it.Next()
+			if err == iterator.Done {
+				break
+			}
+			if err != nil {
+				log.Fatal(errors.Wrap(err, "listing objects"))
+			}
+			t, ok := repair.ParseObjectName(obj.Name)
+			if !ok {
+				continue
+			}
+			r, err := bucket.Object(obj.Name).NewReader(ctx)
+			if err != nil {
+				log.Fatal(errors.Wrap(err, "opening attestation bundle"))
+			}
+			b, err := io.ReadAll(r)
+			r.Close()
+			if err != nil {
+				log.Fatal(errors.Wrap(err, "reading attestation bundle"))
+			}
+			res := repair.Inspect(ctx, t, b, ev, prev)
+			switch {
+			case len(res.Issues) == 0:
+				healthy++
+				if *verbose {
+					fmt.Printf("OK %s\n", obj.Name)
+				}
+			case res.Unrecoverable != "" && regenerate == nil:
+				unrecoverable++
+				fmt.Printf("UNRECOVERABLE %s %v: %s\n", obj.Name, res.Issues, res.Unrecoverable)
+			case res.Unrecoverable != "":
+				stmts, err := regenerate(t)
+				if err != nil {
+					unrecoverable++
+					fmt.Printf("UNRECOVERABLE %s %v: %s: regenerating: %v\n", obj.Name, res.Issues, res.Unrecoverable, err)
+					continue
+				}
+				regenerated++
+				if attestor == nil {
+					fmt.Printf("REGENERABLE %s %v\n", obj.Name, res.Issues)
+					continue
+				}
+				if err := attestor.PublishBundle(ctx, t, stmts...); err != nil {
+					log.Fatal(errors.Wrapf(err, "publishing %s", obj.Name))
+				}
+				fmt.Printf("REGENERATED %s %v\n", obj.Name, res.Issues)
+			case attestor == nil:
+				repaired++
+				fmt.Printf("REPAIRABLE %s %v\n", obj.Name, res.Issues)
+			default:
+				if err := attestor.PublishBundle(ctx, t, res.Statements...); err != nil {
+					log.Fatal(errors.Wrapf(err, "publishing %s", obj.Name))
+				}
+				repaired++
+				fmt.Printf("REPAIRED %s %v\n", obj.Name, res.Issues)
+			}
+		}
+		repairVerb, regenerateVerb := "repairable", "regenerable"
+		if *apply {
+			repairVerb, regenerateVerb = "repaired", "regenerated"
+		}
+		fmt.Printf("%d healthy, %d %s, %d %s, %d unrecoverable\n", healthy, repaired, repairVerb, regenerated, regenerateVerb, unrecoverable)
+	},
+}
+
+var history = &cobra.Command{
+	Use:   "history --api <URI> --ecosystem <ecosystem> --package <name> [--format timeline|json]",
+	Short: "Show the rebuild history of a package across runs",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if *ecosystem == "" || *pkg == "" {
+			log.Fatal("ecosystem and package must be provided")
+		}
+		if *apiUri == "" {
+			log.Fatal("API endpoint not provided")
+		}
+		apiURL, err := url.Parse(*apiUri)
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "parsing API endpoint"))
+		}
+		var client *http.Client
+		if isCloudRun(apiURL) {
+			// If the api is on Cloud Run, we need to use an authorized client.
+			apiURL.Scheme = "https"
+			client, err = oauth.AuthorizedUserIDClient(cmd.Context())
+			if err != nil {
+				log.Fatal(errors.Wrap(err, "creating authorized HTTP client"))
+			}
+		} else {
+			client = http.DefaultClient
+		}
+		stub := api.Stub[schema.PackageHistoryRequest, schema.PackageHistory](client, *apiURL.JoinPath("history"))
+		req := schema.PackageHistoryRequest{Ecosystem: rebuild.Ecosystem(*ecosystem), Package: *pkg}
+		var resp *schema.PackageHistory
+		var entries []schema.HistoryEntry
+		for {
+			resp, err = stub(cmd.Context(), req)
+			if err != nil {
+				log.Fatal(errors.Wrap(err, "fetching history"))
+			}
+			// NOTE: Pages proceed from newest to oldest.
+			entries = append(resp.Entries, entries...)
+			if resp.NextPageToken == "" {
+				break
+			}
+			req.PageToken = resp.NextPageToken
+		}
+		resp.Entries = entries
+		switch *format {
+		case "", "timeline":
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			for _, e := range resp.Entries {
+				verdict := "FAIL"
+				if e.Success {
+					verdict = "OK"
+				}
+				var changes []string
+				if e.VerdictChanged {
+					changes = append(changes, "verdict changed")
+				}
+				if e.StrategyChanged {
+					changes = append(changes, "strategy changed")
+				}
+				detail := e.Message
+				if e.AttestationURL != "" {
+					detail = e.AttestationURL
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", e.Created.Format(time.RFC3339), e.RunID, e.Version, verdict, e.StrategyDigest, strings.Join(changes, ", "), detail)
+			}
+			w.Flush()
+		case "json":
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(resp); err != nil {
+				log.Fatal(errors.Wrap(err, "encoding history"))
+			}
+		default:
+			log.Fatalf("Unsupported format: %s", *format)
+		}
+	},
+}
+
+var capabilities = &cobra.Command{
+	Use:   "capabilities [--ecosystem <ecosystem>] [--api <URI>] [--format table|json]",
+	Short: "Show the rebuild capabilities of each ecosystem",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		req := schema.CapabilitiesRequest{Ecosystem: rebuild.Ecosystem(*ecosystem)}
+		var resp *schema.CapabilitiesResponse
+		if *apiUri != "" {
+			apiURL, err := url.Parse(*apiUri)
+			if err != nil {
+				log.Fatal(errors.Wrap(err, "parsing API endpoint"))
+			}
+			var client *http.Client
+			if isCloudRun(apiURL) {
+				// If the api is on Cloud Run, we need to use an authorized client.
+				apiURL.Scheme = "https"
+				client, err = oauth.AuthorizedUserIDClient(cmd.Context())
+				if err != nil {
+					log.Fatal(errors.Wrap(err, "creating authorized HTTP client"))
+				}
+			} else {
+				client = http.DefaultClient
+			}
+			stub := api.Stub[schema.CapabilitiesRequest, schema.CapabilitiesResponse](client, *apiURL.JoinPath("capabilities"))
+			resp, err = stub(cmd.Context(), req)
+			if err != nil {
+				log.Fatal(errors.Wrap(err, "fetching capabilities"))
+			}
+		} else {
+			if err := req.Validate(); err != nil {
+				log.Fatal(err)
+			}
+			resp = &schema.CapabilitiesResponse{Ecosystems: meta.All()}
+			if req.Ecosystem != "" {
+				c, _ := meta.Lookup(req.Ecosystem)
+				resp.Ecosystems = []meta.Capabilities{c}
+			}
+		}
+		switch *format {
+		case "", "table":
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "ECOSYSTEM\tFEATURES\tARTIFACTS\tINFERENCE\tSTABILIZERS")
+			for _, c := range resp.Ecosystems {
+				var features []string
+				for _, f := range c.Features {
+					features = append(features, string(f))
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\n", c.Ecosystem, strings.Join(features, ","), strings.Join(c.ArtifactTypes, ","), c.Inference, len(c.Stabilizers))
+			}
+			w.Flush()
+		case "json":
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(resp); err != nil {
+				log.Fatal(errors.Wrap(err, "encoding capabilities"))
+			}
+		default:
+			log.Fatalf("Unsupported format: %s", *format)
+		}
+	},
+}
+
+var firestoreIndexes = &cobra.Command{
+	Use:   "firestore-indexes",
+	Short: "Print the Firestore composite indexes required by rundex queries",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		b, err := rundex.IndexesJSON()
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "encoding indexes"))
+		}
+		cmd.OutOrStdout().Write(append(b, '\n'))
+	},
+}
+
+var notifyOwners = &cobra.Command{
+	Use:   "notify-owners --project <ID> --subscriptions <file> [--debug-storage <bucket>] [--send]",
+	Short: "Notify opted-in package owners of persistent upstream rebuild failures",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		if *project == "" {
+			log.Fatal("project not provided")
+		}
+		if *subscriptionsPath == "" {
+			log.Fatal("subscriptions not provided")
+		}
+		f, err := os.Open(*subscriptionsPath)
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "opening subscriptions"))
+		}
+		subs, err := notify.LoadSubscriptions(f)
+		f.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+		client, err := rundex.NewFirestore(ctx, *project)
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "creating firestore client"))
+		}
+		n := &notify.Notifier{
+			History:     client,
+			Ledger:      &notify.FirestoreLedger{Client: client.Client},
+			MinFailures: *minFailures,
+			MaxNotices:  *maxNotices,
+			Interval:    *noticeInterval,
+			DryRun:      !*sendNotices,
+		}
+		if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+			n.GitHub = &notify.GitHubIssues{Client: http.DefaultClient, Token: token}
+		}
+		if *smtpAddr != "" {
+			n.Email = &notify.SMTPMailer{Addr: *smtpAddr, From: *smtpFrom}
+		}
+		if *debugStorage != "" {
+			ctx = context.WithValue(ctx, rebuild.DebugStoreID, *debugStorage)
+			n.Diff = notify.DebugAssetDiff
+		}
+		results, err := n.Run(ctx, subs)
+		for _, r := range results {
+			fmt.Fprintf(cmd.OutOrStdout(), "%s\t%s\n", r.Finding.Key(), r.Ref)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+var gcBuilds = &cobra.Command{
+	Use:   "gc-builds --project <ID> --run <ID> [--cancel]",
+	Short: "Cancel in-progress Cloud Build builds whose rebuild requests have been abandoned",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		if *project == "" {
+			log.Fatal("project not provided")
+		}
+		if *runFlag == "" {
+			log.Fatal("run not provided")
+		}
+		client, err := rundex.NewFirestore(ctx, *project)
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "creating firestore client"))
+		}
+		rebuilds, err := client.FetchRebuilds(ctx, &rundex.FetchRebuildRequest{Runs: []string{*runFlag}})
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "fetching rebuilds"))
+		}
+		recorded := make(map[string]time.Time)
+		for _, r := range rebuilds {
+			recorded[gcb.TargetTag(r.Ecosystem, r.Package, r.Version, r.Artifact)] = r.Created
+		}
+		svc, err := cloudbuild.NewService(ctx)
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "creating cloudbuild service"))
+		}
+		// NOTE: Only builds in the global region are listed so those run in
+		// regional worker pools must be cancelled separately.
+		call := svc.Projects.Builds.List(*project).Filter(`status="PENDING" OR status="QUEUED" OR status="WORKING"`)
+		var active []*cloudbuild.Build
+		err = call.Pages(ctx, func(resp *cloudbuild.ListBuildsResponse) error {
+			active = append(active, resp.Builds...)
+			return nil
+		})
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "listing builds"))
+		}
+		orphaned, err := gcb.Orphaned(active, *runFlag, recorded)
+		if err != nil {
+			log.Fatal(err)
+		}
+		var failed int
+		for _, b := range orphaned {
+			fmt.Fprintf(cmd.OutOrStdout(), "%s\t%s\t%s\n", b.Id, b.CreateTime, strings.Join(b.Tags, ","))
+			if !*cancelBuilds {
+				continue
+			}
+			if _, err := svc.Projects.Builds.Cancel(*project, b.Id, &cloudbuild.CancelBuildRequest{}).Context(ctx).Do(); err != nil {
+				log.Println(errors.Wrapf(err, "cancelling build %s", b.Id))
+				failed++
+			}
+		}
+		if !*cancelBuilds {
+			log.Printf("Found %d orphaned builds. Re-run with --cancel to cancel them.", len(orphaned))
+		} else if failed > 0 {
+			log.Fatalf("Failed to cancel %d of %d orphaned builds", failed, len(orphaned))
+		}
+	},
+}
+
+var detectFlaky = &cobra.Command{
+	Use:   "detect-flaky --project <ID> --run <ID>,<ID>[,...] [--bench <benchmark.json>] [--tag]",
+	Short: "Report targets whose verdicts oscillate across runs with identical strategies",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		req, err := buildFetchRebuildRequest(*bench, *runFlag, "", "", false)
+		if err != nil {
+			log.Fatal(err)
+		}
+		client, err := rundex.NewFirestore(ctx, *project)
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "creating firestore client"))
+		}
+		var attempts []rundex.Rebuild
+		// NOTE: FetchRebuilds deduplicates across runs so each run is fetched separately.
+		for _, run := range req.Runs {
+			runReq := *req
+			runReq.Runs = []string{run}
+			rebuilds, err := client.FetchRebuilds(ctx, &runReq)
+			if err != nil {
+				log.Fatal(errors.Wrapf(err, "fetching rebuilds for %s", run))
+			}
+			for _, r := range rebuilds {
+				attempts = append(attempts, r)
+			}
+		}
+		criteria := flaky.Criteria{MinAttempts: *minAttempts, MinTransitions: *minTransitions}
+		targets := flaky.Group(attempts)
+		findings := flaky.Detect(targets, criteria)
+		w := cmd.OutOrStdout()
+		for _, f := range findings {
+			fmt.Fprintf(w, "%s\t%s\tsuccess=%d mismatch=%d failure=%d transitions=%d\n", f.Target.ID, f.Target.StrategyHash, f.Counts[flaky.Success], f.Counts[flaky.Mismatch], f.Counts[flaky.Failure], f.Transitions)
+			if *verbose {
+				for _, a := range f.Target.Attempts {
+					fmt.Fprintf(w, "  %s\t%s\t%s\n", a.RunID, flaky.OutcomeOf(a), truncate(a.Message, 200))
+				}
+			}
+		}
+		log.Printf("Found %d flaky targets among %d attempts", len(findings), len(attempts))
+		tagger := &flaky.FirestoreTagger{Client: client.Client}
+		tagged, err := tagger.Tagged(ctx)
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "reading flaky tags"))
+		}
+		stable := flaky.Stabilized(targets, findings, tagged, criteria)
+		for _, id := range stable {
+			fmt.Fprintf(w, "%s\tSTABILIZED\n", id)
+		}
+		tagVerb, clearVerb := "to tag", "to clear"
+		if *tagFlaky {
+			now := time.Now()
+			for _, f := range findings {
+				if err := tagger.Tag(ctx, f.Target.ID, flaky.NewRecord(f, now)); err != nil {
+					log.Fatal(errors.Wrapf(err, "tagging %s", f.Target.ID))
+				}
+			}
+			for _, id := range stable {
+				if err := tagger.Untag(ctx, id); err != nil {
+					log.Fatal(errors.Wrapf(err, "clearing tag for %s", id))
+				}
+			}
+			tagVerb, clearVerb = "tagged", "cleared"
+		}
+		log.Printf("%d %s, %d %s", len(findings), tagVerb, len(stable), clearVerb)
+	},
+}
+
+// truncate returns at most the first n runes of s.
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
+
+// detectDrift is intended to run periodically following a smoketest run of a
+// fixed canary benchmark (e.g. "run-bench smoketest canary.json"). Since the
+// canary targets and their strategies are unchanged between runs, changes in
+// verdicts or rebuilt artifact digests indicate that the rebuild environment
+// has drifted. The command exits non-zero when drift is detected.
+var detectDrift = &cobra.Command{
+	Use:   "detect-drift --project <ID> --bench <canary.json> [--run <current>,<previous>] [--debug-storage <bucket>] [--webhook <URL>]",
+	Short: "Report canary targets whose rebuild outcomes changed since the previous canary run",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		if *project == "" {
+			log.Fatal("project not provided")
+		}
+		if *bench == "" {
+			log.Fatal("bench not provided")
+		}
+		set, err := benchmark.ReadBenchmark(*bench)
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "reading benchmark file"))
+		}
+		client, err := rundex.NewFirestore(ctx, *project)
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "creating firestore client"))
+		}
+		var runs []string
+		if *runFlag != "" {
+			runs = strings.Split(*runFlag, ",")
+		} else {
+			found, err := client.FetchRuns(ctx, rundex.FetchRunsOpts{BenchmarkHash: hex.EncodeToString(set.Hash(sha256.New()))})
+			if err != nil {
+				log.Fatal(errors.Wrap(err, "fetching runs"))
+			}
+			sort.Slice(found, func(i, j int) bool { return found[i].Created.After(found[j].Created) })
+			for _, r := range found[:min(len(found), 2)] {
+				runs = append(runs, r.ID)
+			}
+		}
+		if len(runs) != 2 {
+			log.Fatalf("Expected a current and previous run, found %d", len(runs))
+		}
+		if *debugStorage != "" {
+			ctx = context.WithValue(ctx, rebuild.DebugStoreID, *debugStorage)
+		}
+		observe := func(run string) map[string]drift.Observation {
+			rebuilds, err := client.FetchRebuilds(ctx, &rundex.FetchRebuildRequest{Runs: []string{run}, Bench: &set})
+			if err != nil {
+				log.Fatal(errors.Wrapf(err, "fetching rebuilds for %s", run))
+			}
+			var store rebuild.AssetStore
+			if *debugStorage != "" {
+				store, err = rebuild.DebugStoreFromContext(context.WithValue(ctx, rebuild.RunID, run))
+				if err != nil {
+					log.Fatal(errors.Wrap(err, "creating debug asset store"))
+				}
+			}
+			obs := make(map[string]drift.Observation, len(rebuilds))
+			for id, r := range rebuilds {
+				var digest string
+				if store != nil {
+					digest, err = drift.Digest(ctx, store, r.Target())
+					if err != nil {
+						log.Fatal(errors.Wrapf(err, "computing digest for %s", id))
+					}
+				}
+				obs[id] = drift.Observe(r, digest)
+			}
+			return obs
+		}
+		current, previous := observe(runs[0]), observe(runs[1])
+		changes := drift.Compare(previous, current)
+		var report strings.Builder
+		fmt.Fprintf(&report, "Canary %s: %d of %d targets changed between runs %s and %s\n", filepath.Base(*bench), len(changes), len(previous), runs[1], runs[0])
+		fmt.Fprintf(&report, "Executor versions: %s -> %s\n", strings.Join(drift.Versions(previous), ","), strings.Join(drift.Versions(current), ","))
+		for _, c := range changes {
+			switch c.Kind {
+			case drift.OutcomeChanged:
+				fmt.Fprintf(&report, "%s\t%s\t%s -> %s\t%s\n", c.ID, c.Kind, c.Previous.Outcome, c.Current.Outcome, truncate(c.Current.Message, 200))
+			case drift.DigestChanged:
+				fmt.Fprintf(&report, "%s\t%s\t%s -> %s\n", c.ID, c.Kind, c.Previous.Digest, c.Current.Digest)
+			default:
+				fmt.Fprintf(&report, "%s\t%s\n", c.ID, c.Kind)
+			}
+		}
+		io.WriteString(cmd.OutOrStdout(), report.String())
+		if len(changes) == 0 {
+			return
+		}
+		if *webhook != "" {
+			body, err := json.Marshal(map[string]string{"text": report.String()})
+			if err != nil {
+				log.Fatal(errors.Wrap(err, "marshalling alert"))
+			}
+			resp, err := http.Post(*webhook, "application/json", strings.NewReader(string(body)))
+			if err != nil {
+				log.Fatal(errors.Wrap(err, "sending alert"))
+			}
+			resp.Body.Close()
+			if resp.StatusCode/100 != 2 {
+				log.Fatalf("sending alert: %s", resp.Status)
+			}
+		}
+		log.Fatalf("Detected drift in %d canary targets", len(changes))
+	},
+}
+
 var (
 	// Shared
 	apiUri         = flag.String("api", "", "OSS Rebuild API endpoint URI")
@@ -580,20 +1471,49 @@ var (
 	async          = flag.Bool("async", false, "true if this benchmark should run asynchronously")
 	taskQueuePath  = flag.String("task-queue", "", "the path identifier of the task queue to use")
 	taskQueueEmail = flag.String("task-queue-email", "", "the email address of the serivce account Cloud Tasks should authorize as")
+	externalID     = flag.String("external-id", "", "a client-supplied run identifier. runs created with an existing identifier are reused")
+	labels         = flag.String("labels", "", "comma-separated key=value labels associated with the run")
 	// run-one
 	strategyPath      = flag.String("strategy", "", "the strategy file to use")
 	useNetworkProxy   = flag.Bool("use-network-proxy", false, "request the newtwork proxy")
 	useSyscallMonitor = flag.Bool("use-syscall-monitor", false, "request the newtwork proxy")
+	syscallPolicy     = flag.String("syscall-policy", "", "comma-separated syscall monitor policy packs to apply. defaults to the ecosystem's packs")
+	hermetic          = flag.Bool("hermetic", false, "run the build phase without network access. requires --use-network-proxy")
+	toolchainMatrix   = flag.String("toolchain-matrix", "", "comma-separated toolchain versions with which to additionally rebuild in smoketest mode")
 	// get-results
-	runFlag      = flag.String("run", "", "the run(s) from which to fetch results")
-	bench        = flag.String("bench", "", "a path to a benchmark file. if provided, only results from that benchmark will be fetched")
-	format       = flag.String("format", "", "format of the output, options are command specific")
-	prefix       = flag.String("prefix", "", "filter results to those matching this prefix ")
-	pattern      = flag.String("pattern", "", "filter results to those matching this regex pattern")
-	sample       = flag.Int("sample", -1, "if provided, only N results will be displayed")
-	project      = flag.String("project", "", "the project from which to fetch the Firestore data")
-	clean        = flag.Bool("clean", false, "whether to apply normalization heuristics to group similar verdicts")
-	debugStorage = flag.String("debug-storage", "", "the gcs bucket to find debug logs and artifacts")
+	runFlag          = flag.String("run", "", "the run(s) from which to fetch results")
+	bench            = flag.String("bench", "", "a path to a benchmark file. if provided, only results from that benchmark will be fetched")
+	format           = flag.String("format", "", "format of the output, options are command specific")
+	prefix           = flag.String("prefix", "", "filter results to those matching this prefix ")
+	pattern          = flag.String("pattern", "", "filter results to those matching this regex pattern")
+	sample           = flag.Int("sample", -1, "if provided, only N results will be displayed")
+	project          = flag.String("project", "", "the project from which to fetch the Firestore data")
+	clean            = flag.Bool("clean", false, "whether to apply normalization heuristics to group similar verdicts")
+	debugStorage     = flag.String("debug-storage", "", "the gcs bucket to find debug logs and artifacts")
+	excludeFlaky     = flag.Bool("exclude-flaky", false, "whether to exclude targets tagged as flaky")
+	clusterThreshold = flag.Float64("cluster-threshold", cluster.DefaultThreshold, "the minimum similarity (0-1) for failures to be clustered together with --format=clusters")
+	// detect-flaky
+	minAttempts    = flag.Int("min-attempts", 3, "the number of attempts with the same strategy required to evaluate a target")
+	minTransitions = flag.Int("min-transitions", 2, "the number of success/non-success changes required to consider a target flaky")
+	tagFlaky       = flag.Bool("tag", false, "whether to update flaky target tags in Firestore. otherwise, changes are only reported")
+	// detect-drift
+	webhook = flag.String("webhook", "", "a URL to which a JSON {\"text\": <report>} alert is posted when drift is detected")
+	// gc-builds
+	cancelBuilds = flag.Bool("cancel", false, "whether to cancel orphaned builds. otherwise, they are only reported")
+	// view-attestations
+	attestationBucket   = flag.String("attestation-bucket", "google-rebuild-attestations", "the gcs bucket where attestation bundles are published")
+	verifySignatures    = flag.Bool("verify", true, "whether to verify attestation signatures")
+	signingKeyVersion   = flag.String("signing-key-version", "", "the Cloud KMS key version with which bundles are expected to be signed. defaults to the OSS Rebuild key")
+	previousKeyVersions = flag.String("previous-key-versions", "", "comma-separated Cloud KMS key versions whose signatures are trusted for re-signing. bundles signed by any other key are unrecoverable")
+	apply               = flag.Bool("apply", false, "whether to publish repaired bundles. otherwise, repairs are only reported")
+	// notify-owners
+	subscriptionsPath = flag.String("subscriptions", "", "a YAML file listing the packages whose owners opted in to notifications")
+	minFailures       = flag.Int("min-failures", 3, "the number of consecutive upstream failures required before notifying")
+	maxNotices        = flag.Int("max-notices", 10, "the maximum number of notices to send per invocation")
+	noticeInterval    = flag.Duration("notice-interval", time.Minute, "the minimum time between notices")
+	sendNotices       = flag.Bool("send", false, "whether to send notices. otherwise, notices are only logged")
+	smtpAddr          = flag.String("smtp-addr", "", "the host:port of the SMTP server used to email notices")
+	smtpFrom          = flag.String("smtp-from", "", "the address from which to email notices")
 	//TUI
 	benchmarkDir = flag.String("benchmark-dir", "", "a directory with benchmarks to work with")
 	defDir       = flag.String("def-dir", "", "tui will make edits to strategies in this manual build definition repo")
@@ -608,15 +1528,21 @@ func init() {
 	runBenchmark.Flags().AddGoFlag(flag.Lookup("async"))
 	runBenchmark.Flags().AddGoFlag(flag.Lookup("task-queue"))
 	runBenchmark.Flags().AddGoFlag(flag.Lookup("task-queue-email"))
+	runBenchmark.Flags().AddGoFlag(flag.Lookup("external-id"))
+	runBenchmark.Flags().AddGoFlag(flag.Lookup("labels"))
 
 	runOne.Flags().AddGoFlag(flag.Lookup("api"))
 	runOne.Flags().AddGoFlag(flag.Lookup("strategy"))
 	runOne.Flags().AddGoFlag(flag.Lookup("use-network-proxy"))
 	runOne.Flags().AddGoFlag(flag.Lookup("use-syscall-monitor"))
+	runOne.Flags().AddGoFlag(flag.Lookup("syscall-policy"))
+	runOne.Flags().AddGoFlag(flag.Lookup("hermetic"))
 	runOne.Flags().AddGoFlag(flag.Lookup("ecosystem"))
 	runOne.Flags().AddGoFlag(flag.Lookup("package"))
 	runOne.Flags().AddGoFlag(flag.Lookup("version"))
 	runOne.Flags().AddGoFlag(flag.Lookup("artifact"))
+	runOne.Flags().AddGoFlag(flag.Lookup("toolchain-matrix"))
+	runOne.Flags().AddGoFlag(flag.Lookup("def-dir"))
 
 	getResults.Flags().AddGoFlag(flag.Lookup("run"))
 	getResults.Flags().AddGoFlag(flag.Lookup("bench"))
@@ -626,6 +1552,26 @@ func init() {
 	getResults.Flags().AddGoFlag(flag.Lookup("project"))
 	getResults.Flags().AddGoFlag(flag.Lookup("clean"))
 	getResults.Flags().AddGoFlag(flag.Lookup("format"))
+	getResults.Flags().AddGoFlag(flag.Lookup("exclude-flaky"))
+	getResults.Flags().AddGoFlag(flag.Lookup("cluster-threshold"))
+
+	detectFlaky.Flags().AddGoFlag(flag.Lookup("project"))
+	detectFlaky.Flags().AddGoFlag(flag.Lookup("run"))
+	detectFlaky.Flags().AddGoFlag(flag.Lookup("bench"))
+	detectFlaky.Flags().AddGoFlag(flag.Lookup("min-attempts"))
+	detectFlaky.Flags().AddGoFlag(flag.Lookup("min-transitions"))
+	detectFlaky.Flags().AddGoFlag(flag.Lookup("tag"))
+	detectFlaky.Flags().AddGoFlag(flag.Lookup("v"))
+
+	detectDrift.Flags().AddGoFlag(flag.Lookup("project"))
+	detectDrift.Flags().AddGoFlag(flag.Lookup("run"))
+	detectDrift.Flags().AddGoFlag(flag.Lookup("bench"))
+	detectDrift.Flags().AddGoFlag(flag.Lookup("debug-storage"))
+	detectDrift.Flags().AddGoFlag(flag.Lookup("webhook"))
+
+	gcBuilds.Flags().AddGoFlag(flag.Lookup("project"))
+	gcBuilds.Flags().AddGoFlag(flag.Lookup("run"))
+	gcBuilds.Flags().AddGoFlag(flag.Lookup("cancel"))
 
 	tui.Flags().AddGoFlag(flag.Lookup("project"))
 	tui.Flags().AddGoFlag(flag.Lookup("debug-storage"))
@@ -637,6 +1583,8 @@ func init() {
 
 	listRuns.Flags().AddGoFlag(flag.Lookup("project"))
 	listRuns.Flags().AddGoFlag(flag.Lookup("bench"))
+	listRuns.Flags().AddGoFlag(flag.Lookup("external-id"))
+	listRuns.Flags().AddGoFlag(flag.Lookup("labels"))
 
 	infer.Flags().AddGoFlag(flag.Lookup("api"))
 	infer.Flags().AddGoFlag(flag.Lookup("format"))
@@ -645,12 +1593,59 @@ func init() {
 	infer.Flags().AddGoFlag(flag.Lookup("version"))
 	infer.Flags().AddGoFlag(flag.Lookup("artifact"))
 
+	history.Flags().AddGoFlag(flag.Lookup("api"))
+	history.Flags().AddGoFlag(flag.Lookup("ecosystem"))
+	history.Flags().AddGoFlag(flag.Lookup("package"))
+	history.Flags().AddGoFlag(flag.Lookup("format"))
+
+	capabilities.Flags().AddGoFlag(flag.Lookup("api"))
+	capabilities.Flags().AddGoFlag(flag.Lookup("ecosystem"))
+	capabilities.Flags().AddGoFlag(flag.Lookup("format"))
+
+	viewAttestations.Flags().AddGoFlag(flag.Lookup("ecosystem"))
+	viewAttestations.Flags().AddGoFlag(flag.Lookup("package"))
+	viewAttestations.Flags().AddGoFlag(flag.Lookup("version"))
+	viewAttestations.Flags().AddGoFlag(flag.Lookup("attestation-bucket"))
+	viewAttestations.Flags().AddGoFlag(flag.Lookup("verify"))
+	viewAttestations.Flags().AddGoFlag(flag.Lookup("project"))
+	viewAttestations.Flags().AddGoFlag(flag.Lookup("format"))
+
+	repairAttestations.Flags().AddGoFlag(flag.Lookup("attestation-bucket"))
+	repairAttestations.Flags().AddGoFlag(flag.Lookup("prefix"))
+	repairAttestations.Flags().AddGoFlag(flag.Lookup("signing-key-version"))
+	repairAttestations.Flags().AddGoFlag(flag.Lookup("previous-key-versions"))
+	repairAttestations.Flags().AddGoFlag(flag.Lookup("project"))
+	repairAttestations.Flags().AddGoFlag(flag.Lookup("metadata-bucket"))
+	repairAttestations.Flags().AddGoFlag(flag.Lookup("debug-storage"))
+	repairAttestations.Flags().AddGoFlag(flag.Lookup("apply"))
+	repairAttestations.Flags().AddGoFlag(flag.Lookup("v"))
+	attestations.AddCommand(repairAttestations)
+
+	notifyOwners.Flags().AddGoFlag(flag.Lookup("project"))
+	notifyOwners.Flags().AddGoFlag(flag.Lookup("debug-storage"))
+	notifyOwners.Flags().AddGoFlag(flag.Lookup("subscriptions"))
+	notifyOwners.Flags().AddGoFlag(flag.Lookup("min-failures"))
+	notifyOwners.Flags().AddGoFlag(flag.Lookup("max-notices"))
+	notifyOwners.Flags().AddGoFlag(flag.Lookup("notice-interval"))
+	notifyOwners.Flags().AddGoFlag(flag.Lookup("send"))
+	notifyOwners.Flags().AddGoFlag(flag.Lookup("smtp-addr"))
+	notifyOwners.Flags().AddGoFlag(flag.Lookup("smtp-from"))
+
 	rootCmd.AddCommand(runBenchmark)
 	rootCmd.AddCommand(runOne)
 	rootCmd.AddCommand(getResults)
 	rootCmd.AddCommand(tui)
 	rootCmd.AddCommand(listRuns)
 	rootCmd.AddCommand(infer)
+	rootCmd.AddCommand(history)
+	rootCmd.AddCommand(capabilities)
+	rootCmd.AddCommand(viewAttestations)
+	rootCmd.AddCommand(firestoreIndexes)
+	rootCmd.AddCommand(attestations)
+	rootCmd.AddCommand(notifyOwners)
+	rootCmd.AddCommand(detectFlaky)
+	rootCmd.AddCommand(gcBuilds)
+	rootCmd.AddCommand(detectDrift)
 }
 
 func main() {
@@ -0,0 +1,133 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package drift detects changes in rebuild outcomes between runs of a fixed canary set.
+//
+// Canary runs rebuild the same targets with unchanged strategies, so any
+// differences between consecutive runs are attributable to the rebuild
+// environment (e.g. base image updates or prebuild releases).
+package drift
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"sort"
+
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/tools/ctl/flaky"
+	"github.com/google/oss-rebuild/tools/ctl/rundex"
+	"github.com/pkg/errors"
+)
+
+// Observation is the result of rebuilding a single canary target.
+type Observation struct {
+	ID              string
+	Outcome         flaky.Outcome
+	Message         string
+	ExecutorVersion string
+	// Digest is the hex-encoded SHA256 of the rebuilt artifact, if available.
+	Digest string
+}
+
+// Observe creates the Observation for a rebuild attempt.
+func Observe(r rundex.Rebuild, digest string) Observation {
+	return Observation{
+		ID:              r.ID(),
+		Outcome:         flaky.OutcomeOf(r),
+		Message:         r.Message,
+		ExecutorVersion: r.ExecutorVersion,
+		Digest:          digest,
+	}
+}
+
+// Digest returns the digest of the rebuilt artifact stored for the target.
+// An empty digest is returned if no artifact was stored.
+func Digest(ctx context.Context, store rebuild.AssetStore, t rebuild.Target) (string, error) {
+	r, err := store.Reader(ctx, rebuild.DebugRebuildAsset.For(t))
+	if errors.Is(err, rebuild.ErrAssetNotFound) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", errors.Wrap(err, "reading rebuilt artifact")
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Kind is the type of change observed for a target.
+type Kind string
+
+const (
+	// OutcomeChanged indicates the target's verdict differs between runs.
+	OutcomeChanged Kind = "outcome"
+	// DigestChanged indicates the target was rebuilt with the same verdict
+	// but produced a different artifact.
+	DigestChanged Kind = "digest"
+	// Missing indicates the target has no result in the current run.
+	Missing Kind = "missing"
+	// Added indicates the target has no result in the previous run.
+	Added Kind = "added"
+)
+
+// Change describes the difference in a target's results between runs.
+type Change struct {
+	ID       string
+	Kind     Kind
+	Previous *Observation
+	Current  *Observation
+}
+
+// Compare returns the changes between the previous and current observations, keyed by target ID.
+func Compare(previous, current map[string]Observation) []Change {
+	var changes []Change
+	for id, prev := range previous {
+		prev := prev
+		cur, ok := current[id]
+		switch {
+		case !ok:
+			changes = append(changes, Change{ID: id, Kind: Missing, Previous: &prev})
+		case prev.Outcome != cur.Outcome:
+			changes = append(changes, Change{ID: id, Kind: OutcomeChanged, Previous: &prev, Current: &cur})
+		case prev.Digest != "" && cur.Digest != "" && prev.Digest != cur.Digest:
+			changes = append(changes, Change{ID: id, Kind: DigestChanged, Previous: &prev, Current: &cur})
+		}
+	}
+	for id, cur := range current {
+		cur := cur
+		if _, ok := previous[id]; !ok {
+			changes = append(changes, Change{ID: id, Kind: Added, Current: &cur})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].ID < changes[j].ID })
+	return changes
+}
+
+// Versions returns the distinct executor versions among the observations.
+func Versions(obs map[string]Observation) []string {
+	seen := make(map[string]bool)
+	var versions []string
+	for _, o := range obs {
+		if o.ExecutorVersion != "" && !seen[o.ExecutorVersion] {
+			seen[o.ExecutorVersion] = true
+			versions = append(versions, o.ExecutorVersion)
+		}
+	}
+	sort.Strings(versions)
+	return versions
+}
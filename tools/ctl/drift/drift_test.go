@@ -0,0 +1,105 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drift
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/tools/ctl/flaky"
+)
+
+func TestCompare(t *testing.T) {
+	success := func(id, digest string) Observation {
+		return Observation{ID: id, Outcome: flaky.Success, Digest: digest}
+	}
+	failure := func(id string) Observation {
+		return Observation{ID: id, Outcome: flaky.Failure}
+	}
+	for _, tc := range []struct {
+		name     string
+		previous map[string]Observation
+		current  map[string]Observation
+		want     []Kind
+	}{
+		{
+			name:     "unchanged",
+			previous: map[string]Observation{"a": success("a", "d1"), "b": failure("b")},
+			current:  map[string]Observation{"a": success("a", "d1"), "b": failure("b")},
+		},
+		{
+			name:     "outcome changed",
+			previous: map[string]Observation{"a": success("a", "d1")},
+			current:  map[string]Observation{"a": failure("a")},
+			want:     []Kind{OutcomeChanged},
+		},
+		{
+			name:     "digest changed",
+			previous: map[string]Observation{"a": success("a", "d1")},
+			current:  map[string]Observation{"a": success("a", "d2")},
+			want:     []Kind{DigestChanged},
+		},
+		{
+			name:     "digest unavailable",
+			previous: map[string]Observation{"a": success("a", "")},
+			current:  map[string]Observation{"a": success("a", "d2")},
+		},
+		{
+			name:     "missing and added",
+			previous: map[string]Observation{"a": success("a", "d1")},
+			current:  map[string]Observation{"b": success("b", "d1")},
+			want:     []Kind{Missing, Added},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []Kind
+			for _, c := range Compare(tc.previous, tc.current) {
+				got = append(got, c.Kind)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Compare() kinds diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDigest(t *testing.T) {
+	ctx := context.Background()
+	store := rebuild.NewFilesystemAssetStore(memfs.New())
+	target := rebuild.Target{Ecosystem: rebuild.NPM, Package: "pkg", Version: "1.0.0", Artifact: "pkg-1.0.0.tgz"}
+	if got, err := Digest(ctx, store, target); err != nil || got != "" {
+		t.Fatalf("Digest() = %q, %v, want empty digest", got, err)
+	}
+	w, err := store.Writer(ctx, rebuild.DebugRebuildAsset.For(target))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Digest(ctx, store, target)
+	if err != nil {
+		t.Fatalf("Digest() error = %v", err)
+	}
+	if want := "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"; got != want {
+		t.Errorf("Digest() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,265 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package flaky identifies targets whose verdicts oscillate across runs.
+package flaky
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+	"github.com/google/oss-rebuild/tools/ctl/rundex"
+	"github.com/pkg/errors"
+	"google.golang.org/api/iterator"
+)
+
+// Outcome is the coarse verdict of a rebuild attempt.
+type Outcome string
+
+const (
+	Success  Outcome = "success"
+	Mismatch Outcome = "mismatch"
+	Failure  Outcome = "failure"
+)
+
+// OutcomeOf classifies a rebuild attempt.
+func OutcomeOf(r rundex.Rebuild) Outcome {
+	switch {
+	case r.Success:
+		return Success
+	case strings.Contains(r.Message, "content mismatch"):
+		return Mismatch
+	default:
+		return Failure
+	}
+}
+
+// StrategyHash returns a stable digest of the strategy used by an attempt.
+func StrategyHash(s schema.StrategyOneOf) string {
+	return s.Digest()
+}
+
+// Target is the attempt history of a single target under a single strategy.
+type Target struct {
+	ID           string
+	StrategyHash string
+	// Attempts are ordered from oldest to newest.
+	Attempts []rundex.Rebuild
+}
+
+// Counts returns the number of attempts with each Outcome.
+func (t Target) Counts() map[Outcome]int {
+	counts := make(map[Outcome]int)
+	for _, a := range t.Attempts {
+		counts[OutcomeOf(a)]++
+	}
+	return counts
+}
+
+// Transitions returns the number of times consecutive attempts changed
+// between success and non-success.
+func (t Target) Transitions() int {
+	var n int
+	for i := 1; i < len(t.Attempts); i++ {
+		if t.Attempts[i].Success != t.Attempts[i-1].Success {
+			n++
+		}
+	}
+	return n
+}
+
+// Group collates attempts by target and strategy.
+func Group(attempts []rundex.Rebuild) []Target {
+	byKey := make(map[string]*Target)
+	for _, a := range attempts {
+		id, sh := a.ID(), StrategyHash(a.Strategy)
+		key := id + "@" + sh
+		t, ok := byKey[key]
+		if !ok {
+			t = &Target{ID: id, StrategyHash: sh}
+			byKey[key] = t
+		}
+		t.Attempts = append(t.Attempts, a)
+	}
+	targets := make([]Target, 0, len(byKey))
+	for _, t := range byKey {
+		sort.SliceStable(t.Attempts, func(i, j int) bool { return t.Attempts[i].Created.Before(t.Attempts[j].Created) })
+		targets = append(targets, *t)
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		if targets[i].ID != targets[j].ID {
+			return targets[i].ID < targets[j].ID
+		}
+		return targets[i].StrategyHash < targets[j].StrategyHash
+	})
+	return targets
+}
+
+// Criteria determine whether a Target is flaky.
+type Criteria struct {
+	// MinAttempts is the number of attempts required to evaluate a Target.
+	MinAttempts int
+	// MinTransitions is the number of success/non-success changes required.
+	MinTransitions int
+}
+
+// Finding describes a flaky Target.
+type Finding struct {
+	Target      Target
+	Counts      map[Outcome]int
+	Transitions int
+}
+
+// Detect returns the Findings for targets whose verdicts oscillate.
+func Detect(targets []Target, c Criteria) []Finding {
+	var findings []Finding
+	for _, t := range targets {
+		if len(t.Attempts) < max(c.MinAttempts, 2) {
+			continue
+		}
+		counts := t.Counts()
+		if counts[Success] == 0 || counts[Success] == len(t.Attempts) {
+			continue
+		}
+		if n := t.Transitions(); n >= max(c.MinTransitions, 1) {
+			findings = append(findings, Finding{Target: t, Counts: counts, Transitions: n})
+		}
+	}
+	return findings
+}
+
+// Stabilized returns the IDs of tagged targets that were evaluated against c
+// and were not found to be flaky.
+func Stabilized(targets []Target, findings []Finding, tagged map[string]Record, c Criteria) []string {
+	flaky := make(map[string]bool)
+	for _, f := range findings {
+		flaky[f.Target.ID] = true
+	}
+	var stable []string
+	for _, t := range targets {
+		if _, ok := tagged[t.ID]; !ok || flaky[t.ID] {
+			continue
+		}
+		if len(t.Attempts) < max(c.MinAttempts, 2) || slices.Contains(stable, t.ID) {
+			continue
+		}
+		stable = append(stable, t.ID)
+	}
+	return stable
+}
+
+// Record is the persisted tag for a flaky target.
+type Record struct {
+	Ecosystem    string    `firestore:"ecosystem,omitempty"`
+	Package      string    `firestore:"package,omitempty"`
+	Version      string    `firestore:"version,omitempty"`
+	Artifact     string    `firestore:"artifact,omitempty"`
+	StrategyHash string    `firestore:"strategy_hash,omitempty"`
+	Runs         []string  `firestore:"runs,omitempty"`
+	Successes    int       `firestore:"successes,omitempty"`
+	Mismatches   int       `firestore:"mismatches,omitempty"`
+	Failures     int       `firestore:"failures,omitempty"`
+	Transitions  int       `firestore:"transitions,omitempty"`
+	Updated      time.Time `firestore:"updated,omitempty"`
+}
+
+// NewRecord creates the Record for a Finding.
+func NewRecord(f Finding, now time.Time) Record {
+	latest := f.Target.Attempts[len(f.Target.Attempts)-1]
+	r := Record{
+		Ecosystem:    latest.Ecosystem,
+		Package:      latest.Package,
+		Version:      latest.Version,
+		Artifact:     latest.Artifact,
+		StrategyHash: f.Target.StrategyHash,
+		Successes:    f.Counts[Success],
+		Mismatches:   f.Counts[Mismatch],
+		Failures:     f.Counts[Failure],
+		Transitions:  f.Transitions,
+		Updated:      now,
+	}
+	for _, a := range f.Target.Attempts {
+		r.Runs = append(r.Runs, a.RunID)
+	}
+	return r
+}
+
+// Tagger persists and retrieves flaky target tags.
+type Tagger interface {
+	Tag(ctx context.Context, id string, r Record) error
+	Untag(ctx context.Context, id string) error
+	Tagged(ctx context.Context) (map[string]Record, error)
+}
+
+// FirestoreTagger tags flaky targets in the "flaky_targets" Firestore collection.
+type FirestoreTagger struct {
+	Client *firestore.Client
+}
+
+var _ Tagger = &FirestoreTagger{}
+
+// NOTE: Package names may contain slashes which are not permitted in document IDs.
+func escapeID(id string) string   { return strings.ReplaceAll(id, "/", "%2F") }
+func unescapeID(id string) string { return strings.ReplaceAll(id, "%2F", "/") }
+
+// Tag stores the Record for the target ID, replacing any existing tag.
+func (t *FirestoreTagger) Tag(ctx context.Context, id string, r Record) error {
+	_, err := t.Client.Collection("flaky_targets").Doc(escapeID(id)).Set(ctx, r)
+	return err
+}
+
+// Untag removes the tag for the target ID, if any.
+func (t *FirestoreTagger) Untag(ctx context.Context, id string) error {
+	_, err := t.Client.Collection("flaky_targets").Doc(escapeID(id)).Delete(ctx)
+	return err
+}
+
+// Tagged returns all tagged targets keyed by target ID.
+func (t *FirestoreTagger) Tagged(ctx context.Context) (map[string]Record, error) {
+	tagged := make(map[string]Record)
+	it := t.Client.Collection("flaky_targets").Documents(ctx)
+	defer it.Stop()
+	for {
+		doc, err := it.Next()
+		if err == iterator.Done {
+			break
+		} else if err != nil {
+			return nil, errors.Wrap(err, "listing flaky targets")
+		}
+		var r Record
+		if err := doc.DataTo(&r); err != nil {
+			return nil, errors.Wrapf(err, "decoding %s", doc.Ref.ID)
+		}
+		tagged[unescapeID(doc.Ref.ID)] = r
+	}
+	return tagged, nil
+}
+
+// Exclude removes the tagged targets from rebuilds, returning the excluded IDs.
+func Exclude(rebuilds map[string]rundex.Rebuild, tagged map[string]Record) []string {
+	var excluded []string
+	for id := range rebuilds {
+		if _, ok := tagged[id]; ok {
+			delete(rebuilds, id)
+			excluded = append(excluded, id)
+		}
+	}
+	sort.Strings(excluded)
+	return excluded
+}
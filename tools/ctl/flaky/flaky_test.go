@@ -0,0 +1,124 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flaky
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/pkg/rebuild/npm"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+	"github.com/google/oss-rebuild/tools/ctl/rundex"
+)
+
+func attempt(pkg, run string, created int, strategy string, success bool, msg string) rundex.Rebuild {
+	var rb rundex.Rebuild
+	rb.Ecosystem = "npm"
+	rb.Package = pkg
+	rb.Version = "1.0.0"
+	rb.Artifact = pkg + "-1.0.0.tgz"
+	rb.RunID = run
+	rb.Success = success
+	rb.Message = msg
+	rb.Strategy = schema.NewStrategyOneOf(&npm.NPMPackBuild{Location: rebuild.Location{Ref: strategy}})
+	rb.Created = time.Unix(int64(created), 0)
+	return rb
+}
+
+func TestDetect(t *testing.T) {
+	const mismatch = "rebuild content mismatch"
+	attempts := []rundex.Rebuild{
+		// Oscillates under a single strategy.
+		attempt("flip", "run-3", 3, "abc", true, ""),
+		attempt("flip", "run-1", 1, "abc", true, ""),
+		attempt("flip", "run-2", 2, "abc", false, mismatch),
+		attempt("flip", "run-4", 4, "abc", false, "build failed"),
+		// Changes outcome only when the strategy changes.
+		attempt("fixed", "run-1", 1, "abc", false, mismatch),
+		attempt("fixed", "run-2", 2, "abc", false, mismatch),
+		attempt("fixed", "run-3", 3, "def", true, ""),
+		attempt("fixed", "run-4", 4, "def", true, ""),
+		// Consistently failing with differing messages.
+		attempt("broken", "run-1", 1, "abc", false, mismatch),
+		attempt("broken", "run-2", 2, "abc", false, "build failed"),
+		attempt("broken", "run-3", 3, "abc", false, mismatch),
+	}
+	findings := Detect(Group(attempts), Criteria{MinAttempts: 3, MinTransitions: 2})
+	if len(findings) != 1 {
+		t.Fatalf("Detect() = %d findings, want 1: %+v", len(findings), findings)
+	}
+	f := findings[0]
+	if f.Target.ID != "npm!flip!1.0.0!flip-1.0.0.tgz" {
+		t.Errorf("Detect() target = %s", f.Target.ID)
+	}
+	if diff := cmp.Diff(map[Outcome]int{Success: 2, Mismatch: 1, Failure: 1}, f.Counts); diff != "" {
+		t.Errorf("Detect() counts mismatch (-want +got):\n%s", diff)
+	}
+	if f.Transitions != 3 {
+		t.Errorf("Detect() transitions = %d, want 3", f.Transitions)
+	}
+	rec := NewRecord(f, time.Time{})
+	if diff := cmp.Diff([]string{"run-1", "run-2", "run-3", "run-4"}, rec.Runs); diff != "" {
+		t.Errorf("NewRecord() runs mismatch (-want +got):\n%s", diff)
+	}
+	if got := Detect(Group(attempts), Criteria{MinAttempts: 5, MinTransitions: 2}); len(got) != 0 {
+		t.Errorf("Detect() with MinAttempts=5 = %+v, want none", got)
+	}
+}
+
+func TestExclude(t *testing.T) {
+	rebuilds := map[string]rundex.Rebuild{"a": {}, "b": {}, "c": {}}
+	excluded := Exclude(rebuilds, map[string]Record{"b": {}, "d": {}})
+	if diff := cmp.Diff([]string{"b"}, excluded); diff != "" {
+		t.Errorf("Exclude() mismatch (-want +got):\n%s", diff)
+	}
+	if _, ok := rebuilds["b"]; ok || len(rebuilds) != 2 {
+		t.Errorf("Exclude() remaining = %v", rebuilds)
+	}
+}
+
+func TestStabilized(t *testing.T) {
+	const mismatch = "rebuild content mismatch"
+	attempts := []rundex.Rebuild{
+		// Still oscillating.
+		attempt("flip", "run-1", 1, "abc", true, ""),
+		attempt("flip", "run-2", 2, "abc", false, mismatch),
+		attempt("flip", "run-3", 3, "abc", true, ""),
+		// Consistently succeeding.
+		attempt("stable", "run-1", 1, "abc", true, ""),
+		attempt("stable", "run-2", 2, "abc", true, ""),
+		attempt("stable", "run-3", 3, "abc", true, ""),
+		// Too few attempts to evaluate.
+		attempt("sparse", "run-3", 3, "abc", true, ""),
+		// Consistently succeeding but never tagged.
+		attempt("untagged", "run-1", 1, "abc", true, ""),
+		attempt("untagged", "run-2", 2, "abc", true, ""),
+		attempt("untagged", "run-3", 3, "abc", true, ""),
+	}
+	tagged := map[string]Record{
+		"npm!flip!1.0.0!flip-1.0.0.tgz":     {},
+		"npm!stable!1.0.0!stable-1.0.0.tgz": {},
+		"npm!sparse!1.0.0!sparse-1.0.0.tgz": {},
+		"npm!gone!1.0.0!gone-1.0.0.tgz":     {},
+	}
+	c := Criteria{MinAttempts: 3, MinTransitions: 2}
+	targets := Group(attempts)
+	got := Stabilized(targets, Detect(targets, c), tagged, c)
+	if diff := cmp.Diff([]string{"npm!stable!1.0.0!stable-1.0.0.tgz"}, got); diff != "" {
+		t.Errorf("Stabilized() mismatch (-want +got):\n%s", diff)
+	}
+}
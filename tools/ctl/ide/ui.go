@@ -40,8 +40,10 @@ import (
 	"github.com/google/oss-rebuild/pkg/rebuild/schema"
 	cratesreg "github.com/google/oss-rebuild/pkg/registry/cratesio"
 	debianreg "github.com/google/oss-rebuild/pkg/registry/debian"
+	gomodreg "github.com/google/oss-rebuild/pkg/registry/gomod"
 	npmreg "github.com/google/oss-rebuild/pkg/registry/npm"
 	pypireg "github.com/google/oss-rebuild/pkg/registry/pypi"
+	rubygemsreg "github.com/google/oss-rebuild/pkg/registry/rubygems"
 	"github.com/google/oss-rebuild/tools/benchmark"
 	"github.com/google/oss-rebuild/tools/ctl/localfiles"
 	"github.com/google/oss-rebuild/tools/ctl/rundex"
@@ -135,8 +137,10 @@ func newExplorer(ctx context.Context, app *tview.Application, firestore rundex.R
 		mux: rebuild.RegistryMux{
 			Debian:   debianreg.HTTPRegistry{Client: regclient},
 			CratesIO: cratesreg.HTTPRegistry{Client: regclient},
+			GoMod:    gomodreg.HTTPRegistry{Client: regclient},
 			NPM:      npmreg.HTTPRegistry{Client: regclient},
 			PyPI:     pypireg.HTTPRegistry{Client: regclient},
+			RubyGems: rubygemsreg.HTTPRegistry{Client: regclient},
 		},
 	}
 	e.tree.SetRoot(e.root).SetCurrentNode(e.root)
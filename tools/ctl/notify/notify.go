@@ -0,0 +1,377 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package notify alerts opted-in package owners to persistent rebuild failures
+// that likely stem from nondeterminism in their release process.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/google/oss-rebuild/pkg/archive"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/tools/ctl/rundex"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Cause is a class of rebuild failure attributable to the upstream release process.
+type Cause struct {
+	// Name is a stable identifier used to deduplicate notifications.
+	Name string
+	// Verdict is the rebuild verdict message associated with the cause.
+	Verdict string
+	// Summary describes the problem to the package owner.
+	Summary string
+	// Suggestions are concrete remediations the owner may apply.
+	Suggestions []string
+}
+
+// Causes are the failure classes considered probable upstream nondeterminism.
+//
+// NOTE: Verdicts are matched against the messages produced by the ecosystem
+// Compare implementations so changes there must be reflected here.
+var Causes = []Cause{
+	{
+		Name:    "ds-store",
+		Verdict: ".DS_STORE file(s) found in upstream but not rebuild",
+		Summary: "The published artifact contains macOS .DS_Store files that are not present in source control.",
+		Suggestions: []string{
+			"Publish from a clean checkout, for example in CI.",
+			"Exclude .DS_Store files from the package (e.g. via .npmignore or MANIFEST.in).",
+		},
+	},
+	{
+		Name:    "line-endings",
+		Verdict: "Excess CRLF line endings found in upstream",
+		Summary: "The published artifact contains CRLF line endings where source control contains LF.",
+		Suggestions: []string{
+			"Publish from an environment that does not convert line endings on checkout.",
+			"Add a .gitattributes file pinning line endings (e.g. `* text=auto eol=lf`).",
+		},
+	},
+	{
+		Name:    "missing-dist",
+		Verdict: "dist/ file(s) found in upstream but not rebuild",
+		Summary: "The published artifact contains dist/ outputs that are not produced by the declared build scripts.",
+		Suggestions: []string{
+			"Generate dist/ from a `prepare` or `prepack` script so it is rebuilt at publish time.",
+			"Ensure any local build steps used before publishing are captured in the repository.",
+		},
+	},
+	{
+		Name:    "hidden-files",
+		Verdict: "hidden file(s) found in upstream but not rebuild",
+		Summary: "The published artifact contains local dotfiles that are not present in source control.",
+		Suggestions: []string{
+			"Publish from a clean checkout, for example in CI.",
+			"Restrict published files with an allowlist (e.g. the package.json `files` field).",
+		},
+	},
+}
+
+// Classify returns the upstream Cause for the verdict message, if any.
+func Classify(msg string) (Cause, bool) {
+	for _, c := range Causes {
+		if strings.Contains(msg, c.Verdict) {
+			return c, true
+		}
+	}
+	return Cause{}, false
+}
+
+// Subscription is a package whose owners have opted in to notifications.
+type Subscription struct {
+	Ecosystem string `yaml:"ecosystem"`
+	Package   string `yaml:"package"`
+	// GitHubRepo is the "owner/name" repository in which to file issues.
+	GitHubRepo string `yaml:"github_repo,omitempty"`
+	// Email is the address to which to send notices if no GitHubRepo is provided.
+	Email string `yaml:"email,omitempty"`
+}
+
+// LoadSubscriptions reads a YAML list of Subscriptions.
+func LoadSubscriptions(r io.Reader) ([]Subscription, error) {
+	var subs []Subscription
+	if err := yaml.NewDecoder(r).Decode(&subs); err != nil {
+		return nil, errors.Wrap(err, "decoding subscriptions")
+	}
+	for _, s := range subs {
+		if s.Ecosystem == "" || s.Package == "" {
+			return nil, errors.Errorf("subscription missing ecosystem or package: %+v", s)
+		}
+		if s.GitHubRepo == "" && s.Email == "" {
+			return nil, errors.Errorf("subscription for %s/%s has no contact", s.Ecosystem, s.Package)
+		}
+	}
+	return subs, nil
+}
+
+// DiffSummary describes the differences between the upstream and rebuilt artifacts.
+type DiffSummary struct {
+	UpstreamOnly []string
+	RebuildOnly  []string
+	Changed      []string
+	UpstreamCRLF int
+	RebuildCRLF  int
+}
+
+// maxDiffEntries bounds the number of files listed in each category of a DiffSummary.
+const maxDiffEntries = 20
+
+// NewDiffSummary compares the content of the upstream and rebuilt artifacts.
+func NewDiffSummary(up, rb *archive.ContentSummary) *DiffSummary {
+	upOnly, diffs, rbOnly := up.Diff(rb)
+	truncate := func(s []string) []string {
+		if len(s) > maxDiffEntries {
+			return append(s[:maxDiffEntries:maxDiffEntries], "...")
+		}
+		return s
+	}
+	return &DiffSummary{
+		UpstreamOnly: truncate(upOnly),
+		RebuildOnly:  truncate(rbOnly),
+		Changed:      truncate(diffs),
+		UpstreamCRLF: up.CRLFCount,
+		RebuildCRLF:  rb.CRLFCount,
+	}
+}
+
+// Finding is a persistent upstream failure for a subscribed package.
+type Finding struct {
+	Subscription Subscription
+	Cause        Cause
+	// Failures are the attempts exhibiting the cause, newest first.
+	Failures []rundex.Rebuild
+	Diff     *DiffSummary
+}
+
+// Key identifies the Finding for deduplication.
+func (f Finding) Key() string {
+	return strings.Join([]string{f.Subscription.Ecosystem, f.Subscription.Package, f.Cause.Name}, "!")
+}
+
+// Find returns the Finding for a package's attempt history, newest first, if
+// its most recent minFailures attempts all failed with the same upstream cause.
+func Find(sub Subscription, history []rundex.Rebuild, minFailures int) (*Finding, bool) {
+	if len(history) < minFailures || minFailures <= 0 {
+		return nil, false
+	}
+	recent := history[:minFailures]
+	cause, ok := Classify(recent[0].Message)
+	if !ok {
+		return nil, false
+	}
+	for _, r := range recent {
+		if r.Success || !strings.Contains(r.Message, cause.Verdict) {
+			return nil, false
+		}
+	}
+	return &Finding{Subscription: sub, Cause: cause, Failures: recent}, true
+}
+
+var noticeTmpl = template.Must(template.New("notice").Parse(
+	`OSS Rebuild (https://github.com/google/oss-rebuild) independently rebuilds open source packages from source to verify that published artifacts match.
+
+The {{.Subscription.Package}} package ({{.Subscription.Ecosystem}}) has failed to rebuild for the {{len .Failures}} most recent attempts:
+
+{{range .Failures}}- {{.Version}} ({{.Artifact}}): {{.Message}}
+{{end}}
+{{.Cause.Summary}}
+{{with .Diff}}
+Differences between the published and rebuilt artifacts:
+{{with .UpstreamOnly}}
+Only in the published artifact:
+{{range .}}- {{.}}
+{{end}}{{end}}{{with .RebuildOnly}}
+Only in the rebuilt artifact:
+{{range .}}- {{.}}
+{{end}}{{end}}{{with .Changed}}
+Differing content:
+{{range .}}- {{.}}
+{{end}}{{end}}{{if ne .UpstreamCRLF .RebuildCRLF}}
+CRLF line endings: {{.UpstreamCRLF}} published, {{.RebuildCRLF}} rebuilt
+{{end}}{{end}}
+Suggested fixes:
+
+{{range .Cause.Suggestions}}- {{.}}
+{{end}}
+You are receiving this because this package was opted in to OSS Rebuild notifications. This notice will not be repeated for the same issue.
+`))
+
+// Render produces the title and body of the notice for a Finding.
+func Render(f Finding) (title, body string, err error) {
+	var b bytes.Buffer
+	if err := noticeTmpl.Execute(&b, f); err != nil {
+		return "", "", errors.Wrap(err, "rendering notice")
+	}
+	title = "OSS Rebuild: published artifacts for " + f.Subscription.Package + " cannot be reproduced from source"
+	return title, b.String(), nil
+}
+
+// Sender delivers a notice, returning a reference to the delivered message.
+type Sender interface {
+	Send(ctx context.Context, sub Subscription, title, body string) (ref string, err error)
+}
+
+// Record tracks a delivered notification.
+type Record struct {
+	Ecosystem string    `firestore:"ecosystem,omitempty"`
+	Package   string    `firestore:"package,omitempty"`
+	Cause     string    `firestore:"cause,omitempty"`
+	Ref       string    `firestore:"ref,omitempty"`
+	Sent      time.Time `firestore:"sent,omitempty"`
+}
+
+// Ledger tracks delivered notifications to avoid duplicates.
+type Ledger interface {
+	Notified(ctx context.Context, key string) (bool, error)
+	Record(ctx context.Context, key string, r Record) error
+}
+
+// History provides the attempt history for packages.
+type History interface {
+	QueryRebuilds(context.Context, rundex.RebuildQuery) (*rundex.RebuildPage, error)
+}
+
+// DiffFunc computes the DiffSummary for a failed attempt.
+type DiffFunc func(context.Context, rundex.Rebuild) (*DiffSummary, error)
+
+// Notifier files notices for subscribed packages with persistent upstream failures.
+type Notifier struct {
+	History History
+	Ledger  Ledger
+	// GitHub and Email deliver notices to subscriptions with the corresponding contact.
+	GitHub Sender
+	Email  Sender
+	// Diff, if provided, attaches a DiffSummary of the most recent failure.
+	Diff DiffFunc
+	// MinFailures is the number of consecutive failures required to notify.
+	MinFailures int
+	// MaxNotices bounds the notices sent in a single invocation.
+	MaxNotices int
+	// Interval is the minimum time between notices.
+	Interval time.Duration
+	// DryRun logs notices rather than sending them.
+	DryRun bool
+}
+
+// Result is the outcome of processing a Finding.
+type Result struct {
+	Finding Finding
+	Ref     string
+}
+
+// Run evaluates each subscription, sending at most MaxNotices new notices.
+func (n *Notifier) Run(ctx context.Context, subs []Subscription) ([]Result, error) {
+	var results []Result
+	var last time.Time
+	for _, sub := range subs {
+		if len(results) >= n.MaxNotices {
+			log.Printf("Reached notice limit (%d), deferring remaining subscriptions", n.MaxNotices)
+			break
+		}
+		history, err := n.history(ctx, sub)
+		if err != nil {
+			return results, err
+		}
+		f, ok := Find(sub, history, n.MinFailures)
+		if !ok {
+			continue
+		}
+		if notified, err := n.Ledger.Notified(ctx, f.Key()); err != nil {
+			return results, errors.Wrap(err, "checking ledger")
+		} else if notified {
+			continue
+		}
+		if n.Diff != nil {
+			if f.Diff, err = n.Diff(ctx, f.Failures[0]); err != nil {
+				log.Printf("Failed to summarize diff for %s: %v", f.Key(), err)
+			}
+		}
+		title, body, err := Render(*f)
+		if err != nil {
+			return results, err
+		}
+		if n.DryRun {
+			log.Printf("[dry-run] Would notify %s:\n%s\n\n%s", f.Key(), title, body)
+			results = append(results, Result{Finding: *f})
+			continue
+		}
+		sender := n.Email
+		if sub.GitHubRepo != "" {
+			sender = n.GitHub
+		}
+		if sender == nil {
+			return results, errors.Errorf("no sender configured for %s", f.Key())
+		}
+		if wait := n.Interval - time.Since(last); !last.IsZero() && wait > 0 {
+			select {
+			case <-ctx.Done():
+				return results, ctx.Err()
+			case <-time.After(wait):
+			}
+		}
+		ref, err := sender.Send(ctx, sub, title, body)
+		if err != nil {
+			return results, errors.Wrapf(err, "notifying %s", f.Key())
+		}
+		last = time.Now()
+		rec := Record{Ecosystem: sub.Ecosystem, Package: sub.Package, Cause: f.Cause.Name, Ref: ref, Sent: last}
+		if err := n.Ledger.Record(ctx, f.Key(), rec); err != nil {
+			return results, errors.Wrapf(err, "recording notice %s", ref)
+		}
+		results = append(results, Result{Finding: *f, Ref: ref})
+	}
+	return results, nil
+}
+
+func (n *Notifier) history(ctx context.Context, sub Subscription) ([]rundex.Rebuild, error) {
+	page, err := n.History.QueryRebuilds(ctx, rundex.RebuildQuery{
+		Ecosystem:     sub.Ecosystem,
+		PackagePrefix: sub.Package,
+		Limit:         max(n.MinFailures, 1) * 4,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "querying history for %s/%s", sub.Ecosystem, sub.Package)
+	}
+	var history []rundex.Rebuild
+	for _, r := range page.Rebuilds {
+		if r.Package == sub.Package {
+			history = append(history, r)
+		}
+	}
+	return history, nil
+}
+
+// DebugAssetDiff is a DiffFunc that compares the stabilized artifacts stored
+// in the debug store identified by the rebuild.DebugStoreID context value.
+func DebugAssetDiff(ctx context.Context, r rundex.Rebuild) (*DiffSummary, error) {
+	assets, err := rebuild.DebugStoreFromContext(context.WithValue(ctx, rebuild.RunID, r.RunID))
+	if err != nil {
+		return nil, errors.Wrap(err, "creating debug asset store")
+	}
+	t := r.Target()
+	csRB, csUP, err := rebuild.Summarize(ctx, t, rebuild.DebugRebuildAsset.For(t), rebuild.DebugUpstreamAsset.For(t), assets)
+	if err != nil {
+		return nil, err
+	}
+	return NewDiffSummary(csUP, csRB), nil
+}
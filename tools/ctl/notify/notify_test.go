@@ -0,0 +1,243 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+	"github.com/google/oss-rebuild/tools/ctl/rundex"
+)
+
+func attempt(pkg, version string, success bool, msg string) rundex.Rebuild {
+	return rundex.Rebuild{RebuildAttempt: schema.RebuildAttempt{
+		Ecosystem: "npm",
+		Package:   pkg,
+		Version:   version,
+		Artifact:  pkg + "-" + version + ".tgz",
+		Success:   success,
+		Message:   msg,
+	}}
+}
+
+const (
+	crlf      = "Excess CRLF line endings found in upstream"
+	dsStore   = ".DS_STORE file(s) found in upstream but not rebuild"
+	unrelated = "npm install: incompatible Node version"
+)
+
+func TestFind(t *testing.T) {
+	sub := Subscription{Ecosystem: "npm", Package: "foo", GitHubRepo: "foo/foo"}
+	for _, tc := range []struct {
+		name    string
+		history []rundex.Rebuild
+		want    string
+	}{
+		{
+			name:    "repeated upstream cause",
+			history: []rundex.Rebuild{attempt("foo", "3", false, crlf), attempt("foo", "2", false, crlf), attempt("foo", "1", true, "")},
+			want:    "line-endings",
+		},
+		{
+			name:    "too few failures",
+			history: []rundex.Rebuild{attempt("foo", "2", false, crlf), attempt("foo", "1", true, "")},
+		},
+		{
+			name:    "mixed causes",
+			history: []rundex.Rebuild{attempt("foo", "2", false, crlf), attempt("foo", "1", false, dsStore)},
+		},
+		{
+			name:    "not upstream",
+			history: []rundex.Rebuild{attempt("foo", "2", false, unrelated), attempt("foo", "1", false, unrelated)},
+		},
+		{
+			name:    "insufficient history",
+			history: []rundex.Rebuild{attempt("foo", "1", false, crlf)},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f, ok := Find(sub, tc.history, 2)
+			var got string
+			if ok {
+				got = f.Cause.Name
+			}
+			if got != tc.want {
+				t.Errorf("Find() cause = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	cause, _ := Classify(crlf)
+	f := Finding{
+		Subscription: Subscription{Ecosystem: "npm", Package: "foo"},
+		Cause:        cause,
+		Failures:     []rundex.Rebuild{attempt("foo", "2", false, crlf)},
+		Diff:         &DiffSummary{Changed: []string{"package/index.js"}, UpstreamCRLF: 4},
+	}
+	title, body, err := Render(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(title, "foo") {
+		t.Errorf("Render() title missing package: %q", title)
+	}
+	for _, want := range []string{"- 2 (foo-2.tgz): " + crlf, "- package/index.js", "CRLF line endings: 4 published, 0 rebuilt", cause.Suggestions[0]} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Render() body missing %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestLoadSubscriptions(t *testing.T) {
+	subs, err := LoadSubscriptions(strings.NewReader(`
+- ecosystem: npm
+  package: "@scope/foo"
+  github_repo: scope/foo
+- ecosystem: pypi
+  package: bar
+  email: owner@example.com
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Subscription{
+		{Ecosystem: "npm", Package: "@scope/foo", GitHubRepo: "scope/foo"},
+		{Ecosystem: "pypi", Package: "bar", Email: "owner@example.com"},
+	}
+	if diff := cmp.Diff(want, subs); diff != "" {
+		t.Errorf("LoadSubscriptions() mismatch (-want +got):\n%s", diff)
+	}
+	if _, err := LoadSubscriptions(strings.NewReader("- {ecosystem: npm, package: foo}")); err == nil {
+		t.Error("LoadSubscriptions() without contact succeeded, want error")
+	}
+}
+
+type fakeHistory []rundex.Rebuild
+
+func (h fakeHistory) QueryRebuilds(_ context.Context, q rundex.RebuildQuery) (*rundex.RebuildPage, error) {
+	var page rundex.RebuildPage
+	for _, r := range h {
+		if r.Ecosystem == q.Ecosystem && strings.HasPrefix(r.Package, q.PackagePrefix) && len(page.Rebuilds) < q.Limit {
+			page.Rebuilds = append(page.Rebuilds, r)
+		}
+	}
+	return &page, nil
+}
+
+type fakeLedger map[string]Record
+
+func (l fakeLedger) Notified(_ context.Context, key string) (bool, error) {
+	_, ok := l[key]
+	return ok, nil
+}
+
+func (l fakeLedger) Record(_ context.Context, key string, r Record) error {
+	l[key] = r
+	return nil
+}
+
+type fakeSender struct{ sent []string }
+
+func (s *fakeSender) Send(_ context.Context, sub Subscription, _, _ string) (string, error) {
+	s.sent = append(s.sent, sub.Package)
+	return "ref-" + sub.Package, nil
+}
+
+func TestNotifierRun(t *testing.T) {
+	history := fakeHistory{
+		attempt("foo", "2", false, crlf),
+		attempt("foo", "1", false, crlf),
+		attempt("foo-extra", "1", false, crlf),
+		attempt("bar", "2", false, dsStore),
+		attempt("bar", "1", false, dsStore),
+		attempt("baz", "2", false, dsStore),
+		attempt("baz", "1", false, dsStore),
+		attempt("qux", "1", false, dsStore),
+	}
+	subs := []Subscription{
+		{Ecosystem: "npm", Package: "foo", GitHubRepo: "foo/foo"},
+		{Ecosystem: "npm", Package: "qux", Email: "qux@example.com"},
+		{Ecosystem: "npm", Package: "bar", Email: "bar@example.com"},
+		{Ecosystem: "npm", Package: "baz", GitHubRepo: "baz/baz"},
+	}
+	ledger := fakeLedger{"npm!foo!line-endings": Record{}}
+	github, email := &fakeSender{}, &fakeSender{}
+	n := &Notifier{
+		History:     history,
+		Ledger:      ledger,
+		GitHub:      github,
+		Email:       email,
+		MinFailures: 2,
+		MaxNotices:  1,
+	}
+	results, err := n.Run(context.Background(), subs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// foo was previously notified, qux hasn't failed repeatedly, and baz exceeds the limit.
+	if len(results) != 1 || results[0].Ref != "ref-bar" {
+		t.Errorf("Run() = %+v, want single notice for bar", results)
+	}
+	if diff := cmp.Diff([]string{"bar"}, email.sent); diff != "" {
+		t.Errorf("email sends mismatch (-want +got):\n%s", diff)
+	}
+	if len(github.sent) != 0 {
+		t.Errorf("unexpected github sends: %v", github.sent)
+	}
+	if _, ok := ledger["npm!bar!ds-store"]; !ok {
+		t.Error("Run() did not record notice for bar")
+	}
+	// A subsequent run skips bar and proceeds to baz.
+	results, err = n.Run(context.Background(), subs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Ref != "ref-baz" {
+		t.Errorf("Run() = %+v, want single notice for baz", results)
+	}
+}
+
+func TestGitHubIssues(t *testing.T) {
+	var got map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/foo/bar/issues" || r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"html_url": "https://github.com/foo/bar/issues/1"}`))
+	}))
+	defer srv.Close()
+	g := &GitHubIssues{Client: srv.Client(), Token: "token", BaseURL: srv.URL}
+	ref, err := g.Send(context.Background(), Subscription{GitHubRepo: "foo/bar"}, "title", "body")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ref != "https://github.com/foo/bar/issues/1" {
+		t.Errorf("Send() = %q", ref)
+	}
+	if diff := cmp.Diff(map[string]string{"title": "title", "body": "body"}, got); diff != "" {
+		t.Errorf("issue mismatch (-want +got):\n%s", diff)
+	}
+}
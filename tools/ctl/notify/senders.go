@@ -0,0 +1,125 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"strings"
+
+	"cloud.google.com/go/firestore"
+	"github.com/google/oss-rebuild/internal/httpx"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// GitHubIssues files notices as issues in the subscription's GitHub repository.
+type GitHubIssues struct {
+	Client httpx.BasicClient
+	Token  string
+	// BaseURL is the GitHub API endpoint, defaulting to https://api.github.com.
+	BaseURL string
+}
+
+var _ Sender = &GitHubIssues{}
+
+// Send creates an issue and returns its URL.
+func (g *GitHubIssues) Send(ctx context.Context, sub Subscription, title, body string) (string, error) {
+	if strings.Count(sub.GitHubRepo, "/") != 1 {
+		return "", errors.Errorf("malformed github repo: %s", sub.GitHubRepo)
+	}
+	base := g.BaseURL
+	if base == "" {
+		base = "https://api.github.com"
+	}
+	payload, err := json.Marshal(map[string]string{"title": title, "body": body})
+	if err != nil {
+		return "", errors.Wrap(err, "marshalling issue")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, base+"/repos/"+sub.GitHubRepo+"/issues", bytes.NewReader(payload))
+	if err != nil {
+		return "", errors.Wrap(err, "creating request")
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+g.Token)
+	resp, err := g.Client.Do(req)
+	if err != nil {
+		return "", errors.Wrap(err, "creating issue")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return "", errors.Errorf("creating issue: %s", resp.Status)
+	}
+	var issue struct {
+		HTMLURL string `json:"html_url"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&issue); err != nil {
+		return "", errors.Wrap(err, "decoding issue")
+	}
+	return issue.HTMLURL, nil
+}
+
+// SMTPMailer emails notices to the subscription's address.
+type SMTPMailer struct {
+	// Addr is the host:port of the SMTP server.
+	Addr string
+	From string
+	Auth smtp.Auth
+}
+
+var _ Sender = &SMTPMailer{}
+
+// Send emails the notice and returns the recipient as a reference.
+func (m *SMTPMailer) Send(_ context.Context, sub Subscription, title, body string) (string, error) {
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s", m.From, sub.Email, title, body)
+	if err := smtp.SendMail(m.Addr, m.Auth, m.From, []string{sub.Email}, []byte(msg)); err != nil {
+		return "", errors.Wrap(err, "sending mail")
+	}
+	return "mailto:" + sub.Email, nil
+}
+
+// FirestoreLedger tracks notifications in the "notifications" Firestore collection.
+type FirestoreLedger struct {
+	Client *firestore.Client
+}
+
+var _ Ledger = &FirestoreLedger{}
+
+func (l *FirestoreLedger) doc(key string) *firestore.DocumentRef {
+	// NOTE: Package names may contain slashes which are not permitted in document IDs.
+	return l.Client.Collection("notifications").Doc(strings.ReplaceAll(key, "/", "%2F"))
+}
+
+// Notified returns whether a notification was previously recorded for the key.
+func (l *FirestoreLedger) Notified(ctx context.Context, key string) (bool, error) {
+	_, err := l.doc(key).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Record stores the notification, failing if one already exists for the key.
+func (l *FirestoreLedger) Record(ctx context.Context, key string, r Record) error {
+	_, err := l.doc(key).Create(ctx, r)
+	return err
+}
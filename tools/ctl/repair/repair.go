@@ -0,0 +1,225 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package repair identifies published attestation bundles in need of
+// re-signing or migration and reconstructs their contents.
+package repair
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/google/oss-rebuild/internal/verifier"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/in-toto/in-toto-golang/in_toto"
+	"github.com/pkg/errors"
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+)
+
+// Issue is a class of defect found in a published bundle.
+type Issue string
+
+const (
+	// Malformed bundles cannot be decoded.
+	Malformed Issue = "malformed"
+	// UntrustedSignature bundles are signed by a previous key rather than the current key.
+	UntrustedSignature Issue = "untrusted_signature"
+	// UnverifiedSignature bundles contain an envelope not signed by any known key.
+	UnverifiedSignature Issue = "unverified_signature"
+	// OutdatedSchema bundles contain statements in a superseded format.
+	OutdatedSchema Issue = "outdated_schema"
+	// MissingStatement bundles lack a statement required of all bundles.
+	MissingStatement Issue = "missing_statement"
+)
+
+// requiredBuildTypes are the statements expected in every published bundle.
+var requiredBuildTypes = []string{
+	verifier.RebuildBuildType,
+	verifier.ArtifactEquivalenceBuildType,
+}
+
+// migration updates a statement to the current schema, returning whether
+// any change was made.
+type migration func(*in_toto.ProvenanceStatementSLSA1) bool
+
+// migrations are applied in order to every statement in a bundle.
+var migrations = []migration{
+	// The in-toto v0.1 statement type was used prior to adopting SLSA v1.
+	func(s *in_toto.ProvenanceStatementSLSA1) bool {
+		if s.Type != in_toto.StatementInTotoV01 {
+			return false
+		}
+		s.Type = in_toto.StatementInTotoV1
+		return true
+	},
+}
+
+// Result describes the state of a single bundle.
+type Result struct {
+	Target rebuild.Target
+	Issues []Issue
+	// Statements are the repaired contents of the bundle to be re-signed.
+	// Only populated when the bundle is repairable.
+	Statements []*in_toto.ProvenanceStatementSLSA1
+	// Unrecoverable describes why the bundle cannot be repaired, if so.
+	Unrecoverable string
+}
+
+// NeedsRepair returns whether the bundle has issues that can be fixed by
+// publishing Statements.
+func (r Result) NeedsRepair() bool {
+	return r.Unrecoverable == "" && len(r.Issues) > 0
+}
+
+func (r *Result) add(i Issue) {
+	for _, existing := range r.Issues {
+		if existing == i {
+			return
+		}
+	}
+	r.Issues = append(r.Issues, i)
+}
+
+func (r *Result) fail(i Issue, reason string) Result {
+	r.add(i)
+	r.Unrecoverable = reason
+	r.Statements = nil
+	return *r
+}
+
+// Inspect checks the JSONL-encoded bundle data for t against the current
+// signing key and schema.
+//
+// Envelopes not signed by the current key are only repairable if signed by
+// one of the previous keys. Otherwise their contents cannot be trusted and
+// the bundle is unrecoverable. previous may be nil if no keys were retired.
+func Inspect(ctx context.Context, t rebuild.Target, data []byte, current, previous *dsse.EnvelopeVerifier) Result {
+	r := Result{Target: t}
+	d := json.NewDecoder(bytes.NewBuffer(data))
+	for {
+		var env dsse.Envelope
+		if err := d.Decode(&env); err == io.EOF {
+			break
+		} else if err != nil {
+			return r.fail(Malformed, errors.Wrap(err, "decoding envelope").Error())
+		}
+		if _, err := current.Verify(ctx, &env); err != nil {
+			if previous == nil {
+				return r.fail(UnverifiedSignature, "not signed by the current key")
+			}
+			if _, err := previous.Verify(ctx, &env); err != nil {
+				return r.fail(UnverifiedSignature, "not signed by the current or a previous key")
+			}
+			r.add(UntrustedSignature)
+		}
+		if env.Payload == "" {
+			return r.fail(Malformed, "empty payload")
+		}
+		b, err := base64.StdEncoding.DecodeString(env.Payload)
+		if err != nil {
+			return r.fail(Malformed, errors.Wrap(err, "decoding base64 payload").Error())
+		}
+		stmt := new(in_toto.ProvenanceStatementSLSA1)
+		if err := json.Unmarshal(b, stmt); err != nil {
+			return r.fail(Malformed, errors.Wrap(err, "unmarshaling payload").Error())
+		}
+		for _, m := range migrations {
+			if m(stmt) {
+				r.add(OutdatedSchema)
+			}
+		}
+		r.Statements = append(r.Statements, stmt)
+	}
+	if len(r.Statements) == 0 {
+		return r.fail(Malformed, "empty bundle")
+	}
+	for _, bt := range requiredBuildTypes {
+		var found bool
+		for _, s := range r.Statements {
+			found = found || s.Predicate.BuildDefinition.BuildType == bt
+		}
+		if !found {
+			return r.fail(MissingStatement, "no statement with buildType "+bt)
+		}
+	}
+	if len(r.Issues) == 0 {
+		r.Statements = nil
+	}
+	return r
+}
+
+// ParseObjectName returns the Target associated with a bundle's object name.
+//
+// Bundles are stored at <ecosystem>/<package>/<version>/<artifact>/rebuild.intoto.jsonl
+// where package may itself contain slashes.
+func ParseObjectName(name string) (rebuild.Target, bool) {
+	parts := strings.Split(name, "/")
+	if len(parts) < 5 || parts[len(parts)-1] != string(rebuild.AttestationBundleAsset) {
+		return rebuild.Target{}, false
+	}
+	n := len(parts)
+	return rebuild.Target{
+		Ecosystem: rebuild.Ecosystem(parts[0]),
+		Package:   strings.Join(parts[1:n-3], "/"),
+		Version:   parts[n-3],
+		Artifact:  parts[n-2],
+	}, true
+}
+
+// Metadata is the stored record of a successful rebuild from which its
+// bundle can be regenerated.
+type Metadata struct {
+	// ID is the invocation ID of the rebuild.
+	ID string
+	// Strategy is the strategy used to execute the rebuild.
+	Strategy rebuild.Strategy
+	// UpstreamURI is the location of the upstream artifact.
+	UpstreamURI string
+	// Build contains the Dockerfile and build info of the rebuild.
+	Build rebuild.AssetStore
+	// Outputs contains the rebuilt artifact.
+	Outputs rebuild.LocatableAssetStore
+}
+
+// Regenerate reconstructs the statements of the bundle for t from m.
+//
+// NOTE: Build observations and manual build definitions are not recorded
+// alongside the rebuild so are omitted from the regenerated statements.
+func Regenerate(ctx context.Context, t rebuild.Target, m Metadata) ([]*in_toto.ProvenanceStatementSLSA1, error) {
+	hashes := []crypto.Hash{crypto.SHA256}
+	if t.Ecosystem == rebuild.NPM {
+		hashes = append(hashes, crypto.SHA512)
+	}
+	rb, up, err := verifier.SummarizeArtifacts(ctx, m.Outputs, t, m.UpstreamURI, hashes)
+	if err != nil {
+		return nil, errors.Wrap(err, "comparing artifacts")
+	}
+	if !bytes.Equal(rb.StabilizedHash.Sum(nil), up.StabilizedHash.Sum(nil)) {
+		return nil, errors.New("rebuild content mismatch")
+	}
+	eqStmt, buildStmt, err := verifier.CreateAttestations(ctx, rebuild.Input{Target: t}, m.Strategy, m.ID, rb, up, m.Build, rebuild.Location{}, verifier.BuildObservations{})
+	if err != nil {
+		return nil, errors.Wrap(err, "creating attestations")
+	}
+	stabilizationStmt, err := verifier.CreateStabilizationAttestation(t, m.ID, rb, up)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating stabilization attestation")
+	}
+	return []*in_toto.ProvenanceStatementSLSA1{eqStmt, buildStmt, stabilizationStmt}, nil
+}
@@ -0,0 +1,313 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repair
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/internal/httpx/httpxtest"
+	"github.com/google/oss-rebuild/internal/verifier"
+	"github.com/google/oss-rebuild/pkg/archive"
+	"github.com/google/oss-rebuild/pkg/archive/archivetest"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/in-toto/in-toto-golang/in_toto"
+	slsa1 "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v1"
+	"github.com/pkg/errors"
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+)
+
+// fakeKey signs data with a keyed digest.
+type fakeKey struct{ id string }
+
+func (k fakeKey) sum(data []byte) []byte {
+	h := sha256.Sum256(append([]byte(k.id), data...))
+	return h[:]
+}
+
+func (k fakeKey) Sign(ctx context.Context, data []byte) ([]byte, error) { return k.sum(data), nil }
+func (k fakeKey) Verify(ctx context.Context, data, sig []byte) error {
+	if !bytes.Equal(k.sum(data), sig) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+func (k fakeKey) KeyID() (string, error)   { return k.id, nil }
+func (k fakeKey) Public() crypto.PublicKey { return nil }
+
+func statement(typ, buildType string) *in_toto.ProvenanceStatementSLSA1 {
+	return &in_toto.ProvenanceStatementSLSA1{
+		StatementHeader: in_toto.StatementHeader{Type: typ, PredicateType: slsa1.PredicateSLSAProvenance},
+		Predicate: slsa1.ProvenancePredicate{
+			BuildDefinition: slsa1.ProvenanceBuildDefinition{BuildType: buildType},
+		},
+	}
+}
+
+func bundle(t *testing.T, key fakeKey, stmts ...*in_toto.ProvenanceStatementSLSA1) []byte {
+	t.Helper()
+	es, err := dsse.NewEnvelopeSigner(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := verifier.InTotoEnvelopeSigner{EnvelopeSigner: es}
+	buf := new(bytes.Buffer)
+	e := json.NewEncoder(buf)
+	for _, s := range stmts {
+		env, err := signer.SignStatement(context.Background(), s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := e.Encode(env); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestInspect(t *testing.T) {
+	ctx := context.Background()
+	current, old, unknown := fakeKey{"current"}, fakeKey{"old"}, fakeKey{"unknown"}
+	ev, err := dsse.NewEnvelopeVerifier(current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev, err := dsse.NewEnvelopeVerifier(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := rebuild.Target{Ecosystem: rebuild.NPM, Package: "foo", Version: "1.0.0", Artifact: "foo-1.0.0.tgz"}
+	rb := func(typ string) *in_toto.ProvenanceStatementSLSA1 { return statement(typ, verifier.RebuildBuildType) }
+	eq := func(typ string) *in_toto.ProvenanceStatementSLSA1 {
+		return statement(typ, verifier.ArtifactEquivalenceBuildType)
+	}
+	for _, tc := range []struct {
+		name     string
+		data     []byte
+		previous *dsse.EnvelopeVerifier
+		want     []Issue
+		repair   bool
+		wantLen  int
+	}{
+		{
+			name: "healthy",
+			data: bundle(t, current, eq(in_toto.StatementInTotoV1), rb(in_toto.StatementInTotoV1)),
+		},
+		{
+			name:     "old key",
+			data:     bundle(t, old, eq(in_toto.StatementInTotoV1), rb(in_toto.StatementInTotoV1)),
+			previous: prev,
+			want:     []Issue{UntrustedSignature},
+			repair:   true,
+			wantLen:  2,
+		},
+		{
+			name: "old key without previous keys",
+			data: bundle(t, old, eq(in_toto.StatementInTotoV1), rb(in_toto.StatementInTotoV1)),
+			want: []Issue{UnverifiedSignature},
+		},
+		{
+			name:     "unknown key",
+			data:     bundle(t, unknown, eq(in_toto.StatementInTotoV1), rb(in_toto.StatementInTotoV1)),
+			previous: prev,
+			want:     []Issue{UnverifiedSignature},
+		},
+		{
+			name:     "mixed keys",
+			data:     append(bundle(t, current, eq(in_toto.StatementInTotoV1)), bundle(t, unknown, rb(in_toto.StatementInTotoV1))...),
+			previous: prev,
+			want:     []Issue{UnverifiedSignature},
+		},
+		{
+			name:     "tampered payload",
+			data:     bytes.Replace(bundle(t, old, eq(in_toto.StatementInTotoV1), rb(in_toto.StatementInTotoV1)), []byte(`"payload":"`), []byte(`"payload":"e30K`), 1),
+			previous: prev,
+			want:     []Issue{UnverifiedSignature},
+		},
+		{
+			name:    "outdated schema",
+			data:    bundle(t, current, eq(in_toto.StatementInTotoV01), rb(in_toto.StatementInTotoV1)),
+			want:    []Issue{OutdatedSchema},
+			repair:  true,
+			wantLen: 2,
+		},
+		{
+			name:     "missing rebuild statement",
+			data:     bundle(t, old, eq(in_toto.StatementInTotoV1)),
+			previous: prev,
+			want:     []Issue{UntrustedSignature, MissingStatement},
+		},
+		{
+			name: "malformed",
+			data: []byte("{not json"),
+			want: []Issue{Malformed},
+		},
+		{
+			name: "empty",
+			data: nil,
+			want: []Issue{Malformed},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Inspect(ctx, target, tc.data, ev, tc.previous)
+			if diff := cmp.Diff(tc.want, got.Issues); diff != "" {
+				t.Errorf("Inspect() issues mismatch (-want +got):\n%s", diff)
+			}
+			if got.NeedsRepair() != tc.repair {
+				t.Errorf("NeedsRepair() = %t, want %t (unrecoverable: %q)", got.NeedsRepair(), tc.repair, got.Unrecoverable)
+			}
+			if len(got.Statements) != tc.wantLen {
+				t.Fatalf("len(Statements) = %d, want %d", len(got.Statements), tc.wantLen)
+			}
+			for _, s := range got.Statements {
+				if s.Type != in_toto.StatementInTotoV1 {
+					t.Errorf("statement type = %s, want %s", s.Type, in_toto.StatementInTotoV1)
+				}
+			}
+			if tc.repair {
+				// Re-signed statements must pass inspection.
+				again := Inspect(ctx, target, bundle(t, current, got.Statements...), ev, nil)
+				if len(again.Issues) != 0 {
+					t.Errorf("Inspect() of repaired bundle issues = %v, want none", again.Issues)
+				}
+			}
+		})
+	}
+}
+
+func TestParseObjectName(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		want rebuild.Target
+		ok   bool
+	}{
+		{
+			name: "npm/foo/1.0.0/foo-1.0.0.tgz/rebuild.intoto.jsonl",
+			want: rebuild.Target{Ecosystem: rebuild.NPM, Package: "foo", Version: "1.0.0", Artifact: "foo-1.0.0.tgz"},
+			ok:   true,
+		},
+		{
+			name: "npm/@scope/foo/1.0.0/scope-foo-1.0.0.tgz/rebuild.intoto.jsonl",
+			want: rebuild.Target{Ecosystem: rebuild.NPM, Package: "@scope/foo", Version: "1.0.0", Artifact: "scope-foo-1.0.0.tgz"},
+			ok:   true,
+		},
+		{name: "npm/foo/1.0.0/foo-1.0.0.tgz/other.json"},
+		{name: "npm/1.0.0/foo-1.0.0.tgz/rebuild.intoto.jsonl"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := ParseObjectName(tc.name)
+			if ok != tc.ok {
+				t.Fatalf("ParseObjectName() ok = %t, want %t", ok, tc.ok)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("ParseObjectName() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRegenerate(t *testing.T) {
+	target := rebuild.Target{Ecosystem: rebuild.NPM, Package: "foo", Version: "1.0.0", Artifact: "foo-1.0.0.tgz"}
+	upstreamURI := "https://registry.npmjs.org/foo/-/foo-1.0.0.tgz"
+	tgz := func(modified time.Time, body string) []byte {
+		t.Helper()
+		b, err := archivetest.TgzFile([]archive.TarEntry{
+			{Header: &tar.Header{Name: "package/index.js", Typeflag: tar.TypeReg, Size: int64(len(body)), Mode: 0644, ModTime: modified}, Body: []byte(body)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b.Bytes()
+	}
+	upstream := tgz(time.Date(1985, 10, 26, 8, 15, 0, 0, time.UTC), "module.exports = 1")
+	for _, tc := range []struct {
+		name    string
+		rebuilt []byte
+		wantErr bool
+	}{
+		{
+			name:    "exact match",
+			rebuilt: upstream,
+		},
+		{
+			name:    "stabilized match",
+			rebuilt: tgz(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), "module.exports = 1"),
+		},
+		{
+			name:    "content mismatch",
+			rebuilt: tgz(time.Date(1985, 10, 26, 8, 15, 0, 0, time.UTC), "module.exports = 2"),
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.WithValue(context.Background(), rebuild.HTTPBasicClientID, &httpxtest.MockClient{
+				Calls: []httpxtest.Call{
+					{URL: upstreamURI, Response: &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(upstream))}},
+				},
+			})
+			build := rebuild.NewFilesystemAssetStore(memfs.New())
+			outputs := rebuild.NewFilesystemAssetStore(memfs.New())
+			write := func(s rebuild.AssetStore, a rebuild.Asset, data []byte) {
+				w, err := s.Writer(ctx, a)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := w.Write(data); err != nil {
+					t.Fatal(err)
+				}
+				if err := w.Close(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			bi, err := json.Marshal(rebuild.BuildInfo{Target: target, ID: "id", BuildID: "build-id"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			write(build, rebuild.DockerfileAsset.For(target), []byte("FROM alpine:3.19\n"))
+			write(build, rebuild.BuildInfoAsset.For(target), bi)
+			write(outputs, rebuild.RebuildAsset.For(target), tc.rebuilt)
+			strategy := &rebuild.ManualStrategy{Location: rebuild.Location{Repo: "https://github.com/foo/foo", Ref: "0beec7b5ea3f0fdbc95d0dd47f3c5bc275da8a33"}, Build: "npm pack", OutputPath: "foo-1.0.0.tgz"}
+			stmts, err := Regenerate(ctx, target, Metadata{ID: "id", Strategy: strategy, UpstreamURI: upstreamURI, Build: build, Outputs: outputs})
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("Regenerate() error = nil, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Regenerate() error = %v", err)
+			}
+			key := fakeKey{"current"}
+			ev, err := dsse.NewEnvelopeVerifier(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Regenerated statements must pass inspection once signed.
+			if got := Inspect(ctx, target, bundle(t, key, stmts...), ev, nil); len(got.Issues) != 0 {
+				t.Errorf("Inspect() of regenerated bundle issues = %v, want none", got.Issues)
+			}
+		})
+	}
+}
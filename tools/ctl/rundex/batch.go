@@ -0,0 +1,246 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rundex
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Write is a single document write.
+type Write struct {
+	Doc  *firestore.DocumentRef
+	Data any
+}
+
+// CommitFunc commits a batch of writes, returning the result of each write.
+type CommitFunc func(context.Context, []Write) []error
+
+// FirestoreCommitter commits batches of writes using a Firestore BulkWriter.
+func FirestoreCommitter(client *firestore.Client) CommitFunc {
+	return func(ctx context.Context, writes []Write) []error {
+		errs := make([]error, len(writes))
+		jobs := make([]*firestore.BulkWriterJob, len(writes))
+		bw := client.BulkWriter(ctx)
+		for i, w := range writes {
+			jobs[i], errs[i] = bw.Set(w.Doc, w.Data)
+		}
+		bw.End()
+		for i, j := range jobs {
+			if j != nil {
+				_, errs[i] = j.Results()
+			}
+		}
+		return errs
+	}
+}
+
+// BatchWriterOpts configures a BatchWriter.
+type BatchWriterOpts struct {
+	// MaxBatch is the maximum number of writes committed together.
+	MaxBatch int
+	// MaxDelay bounds the time a write may be buffered before it is committed.
+	MaxDelay time.Duration
+	// MaxAttempts is the number of times a write failing due to contention is attempted.
+	MaxAttempts int
+	// Backoff is the delay before the first retry, doubled for each subsequent retry.
+	Backoff time.Duration
+}
+
+// DefaultBatchWriterOpts uses Firestore's limit of 500 writes per batch.
+var DefaultBatchWriterOpts = BatchWriterOpts{
+	MaxBatch:    500,
+	MaxDelay:    time.Second,
+	MaxAttempts: 5,
+	Backoff:     100 * time.Millisecond,
+}
+
+// ErrWriterClosed is returned for writes made after the BatchWriter is closed.
+var ErrWriterClosed = errors.New("batch writer closed")
+
+// BatchWriter coalesces document writes from concurrent callers into batches.
+//
+// NOTE: Batches are committed one at a time to limit the write rate to
+// Firestore, so a single BatchWriter should be shared across a process.
+type BatchWriter struct {
+	commit   CommitFunc
+	opts     BatchWriterOpts
+	mu       sync.Mutex
+	pending  []*PendingWrite
+	timer    *time.Timer
+	closed   bool
+	inflight int
+	idle     *sync.Cond
+	commitMu sync.Mutex
+}
+
+// NewBatchWriter returns a BatchWriter that commits batches using commit.
+func NewBatchWriter(commit CommitFunc, opts BatchWriterOpts) *BatchWriter {
+	if opts.MaxBatch <= 0 {
+		opts.MaxBatch = DefaultBatchWriterOpts.MaxBatch
+	}
+	if opts.MaxDelay <= 0 {
+		opts.MaxDelay = DefaultBatchWriterOpts.MaxDelay
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 1
+	}
+	w := &BatchWriter{commit: commit, opts: opts}
+	w.idle = sync.NewCond(&w.mu)
+	return w
+}
+
+// PendingWrite is a buffered write awaiting commit.
+type PendingWrite struct {
+	write Write
+	done  chan struct{}
+	err   error
+}
+
+func (p *PendingWrite) finish(err error) {
+	p.err = err
+	close(p.done)
+}
+
+// Wait blocks until the write is committed, returning its result.
+func (p *PendingWrite) Wait(ctx context.Context) error {
+	select {
+	case <-p.done:
+		return p.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Set buffers a write of data to doc.
+func (w *BatchWriter) Set(doc *firestore.DocumentRef, data any) *PendingWrite {
+	p := &PendingWrite{write: Write{Doc: doc, Data: data}, done: make(chan struct{})}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		p.finish(ErrWriterClosed)
+		return p
+	}
+	w.pending = append(w.pending, p)
+	if len(w.pending) >= w.opts.MaxBatch {
+		w.flushLocked()
+	} else if w.timer == nil {
+		w.timer = time.AfterFunc(w.opts.MaxDelay, func() {
+			w.mu.Lock()
+			defer w.mu.Unlock()
+			w.flushLocked()
+		})
+	}
+	return p
+}
+
+func (w *BatchWriter) flushLocked() {
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+	if len(w.pending) == 0 {
+		return
+	}
+	batch := w.pending
+	w.pending = nil
+	w.inflight++
+	go func() {
+		w.commitMu.Lock()
+		w.commitBatch(batch)
+		w.commitMu.Unlock()
+		w.mu.Lock()
+		w.inflight--
+		w.idle.Broadcast()
+		w.mu.Unlock()
+	}()
+}
+
+// isContention returns whether the write failed due to contention or throttling.
+func isContention(err error) bool {
+	switch status.Code(err) {
+	case codes.Aborted, codes.ResourceExhausted, codes.Unavailable:
+		return true
+	default:
+		return false
+	}
+}
+
+func (w *BatchWriter) commitBatch(batch []*PendingWrite) {
+	backoff := w.opts.Backoff
+	for attempt := 1; ; attempt++ {
+		writes := make([]Write, len(batch))
+		for i, p := range batch {
+			writes[i] = p.write
+		}
+		errs := w.commit(context.Background(), writes)
+		var retry []*PendingWrite
+		for i, p := range batch {
+			var err error
+			if i < len(errs) {
+				err = errs[i]
+			} else {
+				err = errors.New("missing commit result")
+			}
+			if err != nil && isContention(err) && attempt < w.opts.MaxAttempts {
+				retry = append(retry, p)
+				continue
+			}
+			p.finish(err)
+		}
+		if len(retry) == 0 {
+			return
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+		batch = retry
+	}
+}
+
+// Flush commits all buffered writes and waits for outstanding batches.
+func (w *BatchWriter) Flush(ctx context.Context) error {
+	w.mu.Lock()
+	w.flushLocked()
+	w.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		w.mu.Lock()
+		for w.inflight > 0 {
+			w.idle.Wait()
+		}
+		w.mu.Unlock()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "awaiting batch commits")
+	}
+}
+
+// Close rejects subsequent writes and flushes those buffered.
+func (w *BatchWriter) Close(ctx context.Context) error {
+	w.mu.Lock()
+	w.closed = true
+	w.mu.Unlock()
+	return w.Flush(ctx)
+}
@@ -0,0 +1,145 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rundex
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeCommitter records committed batches and returns the errors queued for each write.
+type fakeCommitter struct {
+	mu      sync.Mutex
+	batches [][]any
+	results map[any][]error
+}
+
+func (f *fakeCommitter) commit(_ context.Context, writes []Write) []error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var batch []any
+	errs := make([]error, len(writes))
+	for i, w := range writes {
+		batch = append(batch, w.Data)
+		if rs := f.results[w.Data]; len(rs) > 0 {
+			errs[i], f.results[w.Data] = rs[0], rs[1:]
+		}
+	}
+	f.batches = append(f.batches, batch)
+	return errs
+}
+
+func TestBatchWriter(t *testing.T) {
+	ctx := context.Background()
+	contention := status.Error(codes.Aborted, "too much contention")
+	permanent := errors.New("permission denied")
+	for _, tc := range []struct {
+		name        string
+		opts        BatchWriterOpts
+		writes      []string
+		results     map[any][]error
+		wantBatches [][]any
+		wantErrs    []error
+	}{
+		{
+			name:        "SplitsBatches",
+			opts:        BatchWriterOpts{MaxBatch: 2, MaxDelay: time.Hour},
+			writes:      []string{"a", "b", "c"},
+			wantBatches: [][]any{{"a", "b"}, {"c"}},
+			wantErrs:    []error{nil, nil, nil},
+		},
+		{
+			name:        "RetriesContention",
+			opts:        BatchWriterOpts{MaxBatch: 2, MaxDelay: time.Hour, MaxAttempts: 3},
+			writes:      []string{"a", "b"},
+			results:     map[any][]error{"a": {contention, contention}},
+			wantBatches: [][]any{{"a", "b"}, {"a"}, {"a"}},
+			wantErrs:    []error{nil, nil},
+		},
+		{
+			name:        "ExhaustsAttempts",
+			opts:        BatchWriterOpts{MaxBatch: 1, MaxDelay: time.Hour, MaxAttempts: 2},
+			writes:      []string{"a"},
+			results:     map[any][]error{"a": {contention, contention}},
+			wantBatches: [][]any{{"a"}, {"a"}},
+			wantErrs:    []error{contention},
+		},
+		{
+			name:        "PermanentError",
+			opts:        BatchWriterOpts{MaxBatch: 2, MaxDelay: time.Hour, MaxAttempts: 3},
+			writes:      []string{"a", "b"},
+			results:     map[any][]error{"b": {permanent}},
+			wantBatches: [][]any{{"a", "b"}},
+			wantErrs:    []error{nil, permanent},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f := &fakeCommitter{results: tc.results}
+			w := NewBatchWriter(f.commit, tc.opts)
+			var pending []*PendingWrite
+			for _, d := range tc.writes {
+				pending = append(pending, w.Set(nil, d))
+			}
+			if err := w.Close(ctx); err != nil {
+				t.Fatalf("Close() failed: %v", err)
+			}
+			var errs []error
+			for _, p := range pending {
+				errs = append(errs, p.Wait(ctx))
+			}
+			if diff := cmp.Diff(tc.wantBatches, f.batches); diff != "" {
+				t.Errorf("committed batches diff (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantErrs, errs, cmp.Comparer(func(a, b error) bool { return a == b })); diff != "" {
+				t.Errorf("write results diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestBatchWriterMaxDelay(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	f := &fakeCommitter{}
+	w := NewBatchWriter(f.commit, BatchWriterOpts{MaxBatch: 500, MaxDelay: 10 * time.Millisecond})
+	if err := w.Set(nil, "a").Wait(ctx); err != nil {
+		t.Fatalf("Wait() failed: %v", err)
+	}
+	if diff := cmp.Diff([][]any{{"a"}}, f.batches); diff != "" {
+		t.Errorf("committed batches diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestBatchWriterClosed(t *testing.T) {
+	ctx := context.Background()
+	f := &fakeCommitter{}
+	w := NewBatchWriter(f.commit, DefaultBatchWriterOpts)
+	if err := w.Close(ctx); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	if err := w.Set(nil, "a").Wait(ctx); err != ErrWriterClosed {
+		t.Errorf("Wait() = %v, want %v", err, ErrWriterClosed)
+	}
+	if len(f.batches) != 0 {
+		t.Errorf("unexpected commits: %v", f.batches)
+	}
+}
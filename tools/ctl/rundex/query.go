@@ -0,0 +1,253 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rundex
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/pkg/errors"
+	"google.golang.org/api/iterator"
+)
+
+// IndexField is a single field of a composite index.
+type IndexField struct {
+	FieldPath string `json:"fieldPath"`
+	Order     string `json:"order"`
+}
+
+// Index is a Firestore composite index.
+type Index struct {
+	CollectionGroup string       `json:"collectionGroup"`
+	QueryScope      string       `json:"queryScope"`
+	Fields          []IndexField `json:"fields"`
+}
+
+func attemptsIndex(fields ...IndexField) Index {
+	return Index{CollectionGroup: "attempts", QueryScope: "COLLECTION_GROUP", Fields: fields}
+}
+
+var (
+	asc  = func(f string) IndexField { return IndexField{FieldPath: f, Order: "ASCENDING"} }
+	desc = func(f string) IndexField { return IndexField{FieldPath: f, Order: "DESCENDING"} }
+)
+
+// Indexes are the composite indexes required to serve each RebuildQuery shape.
+var Indexes = []Index{
+	attemptsIndex(asc("run_id"), desc("created")),
+	attemptsIndex(asc("run_id"), asc("success"), desc("created")),
+	attemptsIndex(asc("success"), desc("created")),
+	attemptsIndex(asc("ecosystem"), desc("created")),
+	attemptsIndex(asc("ecosystem"), asc("success"), desc("created")),
+	attemptsIndex(asc("ecosystem"), asc("package"), desc("created")),
+	attemptsIndex(asc("ecosystem"), asc("success"), asc("package"), desc("created")),
+	attemptsIndex(asc("run_id"), asc("ecosystem"), asc("package"), desc("created")),
+	attemptsIndex(asc("run_id"), asc("ecosystem"), asc("success"), asc("package"), desc("created")),
+	attemptsIndex(asc("run_id"), asc("ecosystem"), desc("created")),
+	attemptsIndex(asc("run_id"), asc("ecosystem"), asc("success"), desc("created")),
+}
+
+// IndexesJSON returns the Indexes in the firestore.indexes.json format accepted by the Firebase CLI.
+func IndexesJSON() ([]byte, error) {
+	return json.MarshalIndent(struct {
+		Indexes        []Index `json:"indexes"`
+		FieldOverrides []any   `json:"fieldOverrides"`
+	}{Indexes, []any{}}, "", "  ")
+}
+
+// RebuildQuery describes a server-side query over rebuild attempts.
+type RebuildQuery struct {
+	// Run restricts results to a single run.
+	Run string
+	// Success restricts results to successful rebuilds.
+	//
+	// NOTE: Failed attempts omit the success field so only successes can be
+	// selected server-side. Use Failure to filter failures from each page.
+	Success bool
+	Failure bool
+	// Ecosystem restricts results to a single ecosystem.
+	Ecosystem string
+	// PackagePrefix restricts results to packages with the given prefix. Requires Ecosystem.
+	//
+	// NOTE: Results are then ordered by package, and only newest first within
+	// each package, so the attempts of an exact match precede all others.
+	PackagePrefix string
+	// Since and Until bound the creation time of results. Unsupported with PackagePrefix.
+	Since, Until time.Time
+	// Limit is the maximum number of results to return per page.
+	Limit int
+	// PageToken continues a previous query from the end of its last page.
+	PageToken string
+}
+
+// RebuildPage is a single page of RebuildQuery results.
+type RebuildPage struct {
+	Rebuilds []Rebuild
+	// NextPageToken is non-empty if more results may be available.
+	NextPageToken string
+}
+
+func (q RebuildQuery) validate() error {
+	switch {
+	case q.Success && q.Failure:
+		return errors.New("only provide one of success and failure")
+	case q.PackagePrefix != "" && q.Ecosystem == "":
+		return errors.New("package prefix requires an ecosystem")
+	case q.PackagePrefix != "" && (!q.Since.IsZero() || !q.Until.IsZero()):
+		return errors.New("package prefix cannot be combined with time bounds")
+	case q.Limit <= 0:
+		return errors.New("limit must be positive")
+	}
+	return nil
+}
+
+// index returns the composite index fields required to serve the query.
+func (q RebuildQuery) index() []IndexField {
+	var fields []IndexField
+	if q.Run != "" {
+		fields = append(fields, asc("run_id"))
+	}
+	if q.Ecosystem != "" {
+		fields = append(fields, asc("ecosystem"))
+	}
+	if q.Success {
+		fields = append(fields, asc("success"))
+	}
+	if q.PackagePrefix != "" {
+		fields = append(fields, asc("package"))
+	}
+	return append(fields, desc("created"))
+}
+
+// hasIndex returns whether one of the Indexes serves the query.
+func (q RebuildQuery) hasIndex() bool {
+	want := q.index()
+	if len(want) == 1 {
+		// Single-field indexes are maintained automatically.
+		return true
+	}
+	for _, idx := range Indexes {
+		if slices.Equal(idx.Fields, want) {
+			return true
+		}
+	}
+	return false
+}
+
+func (q RebuildQuery) build(c *firestore.Client) firestore.Query {
+	fq := c.CollectionGroup("attempts").Query
+	if q.Run != "" {
+		fq = fq.Where("run_id", "==", q.Run)
+	}
+	if q.Ecosystem != "" {
+		fq = fq.Where("ecosystem", "==", q.Ecosystem)
+	}
+	if q.Success {
+		fq = fq.Where("success", "==", true)
+	}
+	if q.PackagePrefix != "" {
+		// NOTE: U+F8FF sorts after all other code points used in package names.
+		// Firestore requires the range field to be ordered first.
+		fq = fq.Where("package", ">=", q.PackagePrefix).Where("package", "<", q.PackagePrefix+"\uf8ff").OrderBy("package", firestore.Asc)
+	}
+	if !q.Since.IsZero() {
+		fq = fq.Where("created", ">=", q.Since.UnixMilli())
+	}
+	if !q.Until.IsZero() {
+		fq = fq.Where("created", "<", q.Until.UnixMilli())
+	}
+	return fq.OrderBy("created", firestore.Desc).Limit(q.Limit)
+}
+
+// QueryRebuilds returns a page of rebuild attempts matching the query, newest
+// first. Queries with a PackagePrefix are first ordered by package.
+func (f *FirestoreClient) QueryRebuilds(ctx context.Context, q RebuildQuery) (*RebuildPage, error) {
+	if err := q.validate(); err != nil {
+		return nil, err
+	}
+	if !q.hasIndex() {
+		return nil, errors.Errorf("no index for query shape %v", q.index())
+	}
+	fq := q.build(f.Client)
+	if q.PageToken != "" {
+		path, err := base64.RawURLEncoding.DecodeString(q.PageToken)
+		if err != nil {
+			return nil, errors.Wrap(err, "decoding page token")
+		}
+		last, err := f.Client.Doc(string(path)).Get(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "fetching page cursor")
+		}
+		fq = fq.StartAfter(last)
+	}
+	iter := fq.Documents(ctx)
+	defer iter.Stop()
+	var page RebuildPage
+	var n int
+	var last *firestore.DocumentSnapshot
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		} else if err != nil {
+			return nil, errors.Wrap(err, "query error")
+		}
+		n++
+		last = doc
+		r := NewRebuildFromFirestore(doc)
+		if q.Failure && r.Success {
+			continue
+		}
+		r.Message = CleanVerdict(r.Message)
+		page.Rebuilds = append(page.Rebuilds, r)
+	}
+	if n == q.Limit && last != nil {
+		page.NextPageToken = base64.RawURLEncoding.EncodeToString([]byte(last.Ref.Path))
+	}
+	return &page, nil
+}
+
+// LatestSuccess returns the most recent successful attempt for t.
+//
+// NOTE: Attempts are ordered client-side so the query can be served by the
+// automatic single-field index on success.
+func (f *FirestoreClient) LatestSuccess(ctx context.Context, t rebuild.Target) (*Rebuild, error) {
+	q := f.Client.Collection("ecosystem").Doc(string(t.Ecosystem)).Collection("packages").Doc(strings.ReplaceAll(t.Package, "/", "!")).Collection("versions").Doc(t.Version).Collection("artifacts").Doc(t.Artifact).Collection("attempts").Where("success", "==", true)
+	iter := q.Documents(ctx)
+	defer iter.Stop()
+	var latest *Rebuild
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		} else if err != nil {
+			return nil, errors.Wrap(err, "query error")
+		}
+		r := NewRebuildFromFirestore(doc)
+		if latest == nil || r.Created.After(latest.Created) {
+			latest = &r
+		}
+	}
+	if latest == nil {
+		return nil, errors.Errorf("no successful attempt for %s", t.Artifact)
+	}
+	return latest, nil
+}
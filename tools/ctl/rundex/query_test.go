@@ -0,0 +1,77 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rundex
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestRebuildQueryIndexes(t *testing.T) {
+	// Every valid query shape must be served by a defined index.
+	for _, run := range []string{"", "run-1"} {
+		for _, eco := range []string{"", "npm"} {
+			for _, prefix := range []string{"", "@types/"} {
+				for _, success := range []bool{false, true} {
+					q := RebuildQuery{Run: run, Ecosystem: eco, PackagePrefix: prefix, Success: success, Limit: 10}
+					if q.validate() != nil {
+						continue
+					}
+					if !q.hasIndex() {
+						t.Errorf("No index for %+v: %v", q, q.index())
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestRebuildQueryValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		q       RebuildQuery
+		wantErr bool
+	}{
+		{name: "Valid", q: RebuildQuery{Run: "run-1", Failure: true, Limit: 10}},
+		{name: "TimeBounded", q: RebuildQuery{Ecosystem: "npm", Since: time.Now().Add(-time.Hour), Limit: 10}},
+		{name: "NoLimit", q: RebuildQuery{Run: "run-1"}, wantErr: true},
+		{name: "SuccessAndFailure", q: RebuildQuery{Success: true, Failure: true, Limit: 10}, wantErr: true},
+		{name: "PrefixWithoutEcosystem", q: RebuildQuery{PackagePrefix: "abc", Limit: 10}, wantErr: true},
+		{name: "PrefixWithTimeBounds", q: RebuildQuery{Ecosystem: "npm", PackagePrefix: "abc", Until: time.Now(), Limit: 10}, wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.q.validate(); (err != nil) != tc.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestIndexesJSON(t *testing.T) {
+	b, err := IndexesJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Indexes []Index `json:"indexes"`
+	}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Indexes) != len(Indexes) {
+		t.Errorf("IndexesJSON() has %d indexes, want %d", len(got.Indexes), len(Indexes))
+	}
+}
@@ -126,7 +126,10 @@ func DoQuery[T any](ctx context.Context, q firestore.Query, fn func(*firestore.D
 	return ret
 }
 
-func cleanVerdict(m string) string {
+// CleanVerdict normalizes a verdict message so that similar failures share a message.
+//
+// Messages not matching any known failure are returned unchanged.
+func CleanVerdict(m string) string {
 	switch {
 	// Generic
 	case strings.HasPrefix(m, `mismatched version `):
@@ -206,6 +209,28 @@ type FetchRebuildRequest struct {
 type FetchRunsOpts struct {
 	IDs           []string
 	BenchmarkHash string
+	ExternalID    string
+	// Labels, if provided, must all be present on the returned Runs.
+	Labels map[string]string
+}
+
+// matches returns whether r satisfies the filters in opts.
+func (opts FetchRunsOpts) matches(r Run) bool {
+	if len(opts.IDs) != 0 && !slices.Contains(opts.IDs, r.ID) {
+		return false
+	}
+	if opts.BenchmarkHash != "" && r.BenchmarkHash != opts.BenchmarkHash {
+		return false
+	}
+	if opts.ExternalID != "" && r.ExternalID != opts.ExternalID {
+		return false
+	}
+	for k, v := range opts.Labels {
+		if got, ok := r.Labels[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
 }
 
 type Reader interface {
@@ -269,7 +294,7 @@ func filterRebuilds(all <-chan Rebuild, req *FetchRebuildRequest) map[string]Reb
 	}
 	if req.Opts.Clean {
 		p = p.Do(func(in Rebuild, out chan<- Rebuild) {
-			in.Message = cleanVerdict(in.Message)
+			in.Message = CleanVerdict(in.Message)
 			out <- in
 		})
 	}
@@ -315,11 +340,17 @@ func (f *FirestoreClient) FetchRuns(ctx context.Context, opts FetchRunsOpts) ([]
 	if opts.BenchmarkHash != "" {
 		q = q.Where("benchmark_hash", "==", opts.BenchmarkHash)
 	}
+	if opts.ExternalID != "" {
+		q = q.Where("external_id", "==", opts.ExternalID)
+	}
+	for k, v := range opts.Labels {
+		q = q.WherePath(firestore.FieldPath{"labels", k}, "==", v)
+	}
 	runs := make(chan Run)
 	cerr := DoQuery(ctx, q, NewRunFromFirestore, runs)
 	var runSlice []Run
 	for r := range runs {
-		if len(opts.IDs) != 0 && !slices.Contains(opts.IDs, r.ID) {
+		if !opts.matches(r) {
 			continue
 		}
 		runSlice = append(runSlice, r)
@@ -372,10 +403,7 @@ func (f *LocalClient) FetchRuns(ctx context.Context, opts FetchRunsOpts) ([]Run,
 		if err := json.NewDecoder(file).Decode(&r); err != nil {
 			return errors.Wrap(err, "decoding run file")
 		}
-		if len(opts.IDs) != 0 && !slices.Contains(opts.IDs, r.ID) {
-			return nil
-		}
-		if opts.BenchmarkHash != "" && r.BenchmarkHash != opts.BenchmarkHash {
+		if !opts.matches(r) {
 			return nil
 		}
 		runs = append(runs, r)
@@ -0,0 +1,66 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rundex
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+)
+
+func TestLocalFetchRuns(t *testing.T) {
+	ctx := context.Background()
+	c := NewLocalClient(memfs.New())
+	for _, r := range []schema.Run{
+		{ID: "run-1", BenchmarkHash: "aa", ExternalID: "exp-1", Labels: map[string]string{"experiment": "timewarp", "prebuild": "v1"}},
+		{ID: "run-2", BenchmarkHash: "aa", Labels: map[string]string{"experiment": "timewarp", "prebuild": "v2"}},
+		{ID: "run-3", BenchmarkHash: "bb"},
+	} {
+		if err := c.WriteRun(ctx, FromRun(r)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tc := range []struct {
+		name string
+		opts FetchRunsOpts
+		want []string
+	}{
+		{name: "all", want: []string{"run-1", "run-2", "run-3"}},
+		{name: "benchmark", opts: FetchRunsOpts{BenchmarkHash: "aa"}, want: []string{"run-1", "run-2"}},
+		{name: "external ID", opts: FetchRunsOpts{ExternalID: "exp-1"}, want: []string{"run-1"}},
+		{name: "label", opts: FetchRunsOpts{Labels: map[string]string{"experiment": "timewarp"}}, want: []string{"run-1", "run-2"}},
+		{name: "labels", opts: FetchRunsOpts{Labels: map[string]string{"experiment": "timewarp", "prebuild": "v2"}}, want: []string{"run-2"}},
+		{name: "no match", opts: FetchRunsOpts{Labels: map[string]string{"experiment": "other"}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			runs, err := c.FetchRuns(ctx, tc.opts)
+			if err != nil {
+				t.Fatalf("FetchRuns() error = %v", err)
+			}
+			var got []string
+			for _, r := range runs {
+				got = append(got, r.ID)
+			}
+			sort.Strings(got)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("FetchRuns() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,284 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dataset derives tabular datasets from rebuild attempts and attestations.
+package dataset
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+	"github.com/google/oss-rebuild/tools/ctl/rundex"
+	"github.com/google/oss-rebuild/tools/export/parquet"
+	"github.com/pkg/errors"
+)
+
+// Attestation is a published attestation bundle.
+type Attestation struct {
+	Target  rebuild.Target
+	URL     string
+	Updated time.Time
+	Size    int64
+}
+
+// Table is a named set of rows sharing a set of columns.
+//
+// The first column of every table is the ecosystem by which it is partitioned.
+type Table struct {
+	Name    string
+	Columns []parquet.Column
+	Rows    [][]any
+}
+
+func columns(names string, types ...parquet.Type) []parquet.Column {
+	var cols []parquet.Column
+	for i, n := range strings.Fields(names) {
+		cols = append(cols, parquet.Column{Name: n, Type: types[i]})
+	}
+	return cols
+}
+
+const (
+	str = parquet.String
+	i64 = parquet.Int64
+	ts  = parquet.Timestamp
+	bln = parquet.Boolean
+)
+
+var (
+	targetColumns      = columns("ecosystem package version artifact attempts successes first_attempt last_attempt latest_success latest_run_id attested", str, str, str, str, i64, i64, ts, ts, bln, str, bln)
+	verdictColumns     = columns("ecosystem package version artifact run_id build_id executor_version created success message category strategy_digest strategy_type infer_ms build_ms", str, str, str, str, str, str, str, ts, bln, str, str, str, str, i64, i64)
+	strategyColumns    = columns("ecosystem strategy_digest strategy_type field value", str, str, str, str, str)
+	categoryColumns    = columns("ecosystem category attempts targets", str, str, i64, i64)
+	attestationColumns = columns("ecosystem package version artifact url updated size", str, str, str, str, str, ts, i64)
+)
+
+// Category returns the mismatch category of a failed attempt's message.
+//
+// Messages are normalized using the same heuristics as ctl's --clean option
+// and truncated to their first line.
+func Category(msg string) string {
+	c, _, _ := strings.Cut(rundex.CleanVerdict(msg), "\n")
+	return strings.TrimSpace(c)
+}
+
+// Flatten returns the type of the strategy and its fields keyed by their dotted path.
+//
+// NOTE: Provenance is excluded as it describes inference rather than the build.
+func Flatten(s schema.StrategyOneOf) (string, map[string]string, error) {
+	s.Provenance = nil
+	b, err := json.Marshal(s)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "marshalling strategy")
+	}
+	var m map[string]any
+	if err := json.Unmarshal(b, &m); err != nil {
+		return "", nil, errors.Wrap(err, "unmarshalling strategy")
+	}
+	if len(m) != 1 {
+		return "", nil, errors.Errorf("expected exactly one strategy, got %d", len(m))
+	}
+	var typ string
+	for k := range m {
+		typ = k
+	}
+	fields := make(map[string]string)
+	flatten("", m[typ], fields)
+	return typ, fields, nil
+}
+
+func flatten(prefix string, v any, out map[string]string) {
+	join := func(k string) string {
+		if prefix == "" {
+			return k
+		}
+		return prefix + "." + k
+	}
+	switch t := v.(type) {
+	case map[string]any:
+		for k, e := range t {
+			flatten(join(k), e, out)
+		}
+	case []any:
+		for i, e := range t {
+			flatten(join(strconv.Itoa(i)), e, out)
+		}
+	case string:
+		out[prefix] = t
+	case nil:
+	default:
+		out[prefix] = fmt.Sprint(t)
+	}
+}
+
+// Build derives the exported tables from rebuild attempts and published attestations.
+//
+// The tables produced are:
+//   - targets: one row per artifact summarizing its attempts
+//   - verdicts: one row per attempt
+//   - strategies: the flattened fields of each distinct strategy
+//   - categories: the count of failed attempts and targets in each mismatch category
+//   - attestations: one row per published attestation bundle
+func Build(attempts []schema.RebuildAttempt, attestations []Attestation) []Table {
+	attempts = append([]schema.RebuildAttempt(nil), attempts...)
+	sort.SliceStable(attempts, func(i, j int) bool { return attempts[i].Created < attempts[j].Created })
+	attested := make(map[rebuild.Target]bool)
+	attestationTable := Table{Name: "attestations", Columns: attestationColumns}
+	for _, a := range attestations {
+		attested[a.Target] = true
+		t := a.Target
+		attestationTable.Rows = append(attestationTable.Rows, []any{string(t.Ecosystem), t.Package, t.Version, t.Artifact, a.URL, a.Updated, a.Size})
+	}
+	type target struct {
+		rebuild.Target
+		attempts, successes int64
+		first, last         time.Time
+		latest              schema.RebuildAttempt
+	}
+	targets := make(map[rebuild.Target]*target)
+	type strategyKey struct{ ecosystem, digest string }
+	seenStrategies := make(map[strategyKey]bool)
+	type categoryKey struct{ ecosystem, category string }
+	categoryAttempts := make(map[categoryKey]int64)
+	categoryTargets := make(map[categoryKey]map[rebuild.Target]bool)
+	verdictTable := Table{Name: "verdicts", Columns: verdictColumns}
+	strategyTable := Table{Name: "strategies", Columns: strategyColumns}
+	for _, a := range attempts {
+		t := rebuild.Target{Ecosystem: rebuild.Ecosystem(a.Ecosystem), Package: a.Package, Version: a.Version, Artifact: a.Artifact}
+		created := time.UnixMilli(a.Created).UTC()
+		var category string
+		if !a.Success {
+			category = Category(a.Message)
+			k := categoryKey{a.Ecosystem, category}
+			categoryAttempts[k]++
+			if categoryTargets[k] == nil {
+				categoryTargets[k] = make(map[rebuild.Target]bool)
+			}
+			categoryTargets[k][t] = true
+		}
+		var strategyType string
+		digest := a.Strategy.Digest()
+		if typ, fields, err := Flatten(a.Strategy); err == nil {
+			strategyType = typ
+			if k := (strategyKey{a.Ecosystem, digest}); !seenStrategies[k] {
+				seenStrategies[k] = true
+				var names []string
+				for f := range fields {
+					names = append(names, f)
+				}
+				sort.Strings(names)
+				for _, f := range names {
+					strategyTable.Rows = append(strategyTable.Rows, []any{a.Ecosystem, digest, typ, f, fields[f]})
+				}
+			}
+		} else {
+			// NOTE: Attempts that failed before inference completed have no strategy.
+			digest = ""
+		}
+		verdictTable.Rows = append(verdictTable.Rows, []any{
+			a.Ecosystem, a.Package, a.Version, a.Artifact, a.RunID, a.BuildID, a.ExecutorVersion,
+			created, a.Success, a.Message, category, digest, strategyType,
+			a.Timings.Infer.Milliseconds(), a.Timings.Build.Milliseconds(),
+		})
+		tg, ok := targets[t]
+		if !ok {
+			tg = &target{Target: t, first: created}
+			targets[t] = tg
+		}
+		tg.attempts++
+		if a.Success {
+			tg.successes++
+		}
+		tg.last = created
+		tg.latest = a
+	}
+	targetTable := Table{Name: "targets", Columns: targetColumns}
+	for _, tg := range targets {
+		targetTable.Rows = append(targetTable.Rows, []any{
+			string(tg.Ecosystem), tg.Package, tg.Version, tg.Artifact, tg.attempts, tg.successes,
+			tg.first, tg.last, tg.latest.Success, tg.latest.RunID, attested[tg.Target],
+		})
+	}
+	sortRows(targetTable.Rows, 4)
+	categoryTable := Table{Name: "categories", Columns: categoryColumns}
+	for k, n := range categoryAttempts {
+		categoryTable.Rows = append(categoryTable.Rows, []any{k.ecosystem, k.category, n, int64(len(categoryTargets[k]))})
+	}
+	sortRows(categoryTable.Rows, 2)
+	sortRows(attestationTable.Rows, 4)
+	return []Table{targetTable, verdictTable, strategyTable, categoryTable, attestationTable}
+}
+
+// sortRows orders rows by their first n columns, all of which must be strings.
+func sortRows(rows [][]any, n int) {
+	sort.Slice(rows, func(i, j int) bool {
+		for c := 0; c < n; c++ {
+			if a, b := rows[i][c].(string), rows[j][c].(string); a != b {
+				return a < b
+			}
+		}
+		return false
+	})
+}
+
+// WriteDir writes each table beneath dir as a Hive-style partitioned dataset.
+//
+// Each table is written to <dir>/<table>/ecosystem=<ecosystem>/part-0.parquet.
+// NOTE: The ecosystem column is omitted from the files themselves since
+// readers of partitioned datasets reject columns duplicating a partition key.
+func WriteDir(dir string, tables []Table) error {
+	for _, t := range tables {
+		partitions := make(map[string][][]any)
+		for _, row := range t.Rows {
+			eco := row[0].(string)
+			partitions[eco] = append(partitions[eco], row[1:])
+		}
+		for eco, rows := range partitions {
+			pdir := filepath.Join(dir, t.Name, "ecosystem="+eco)
+			if err := os.MkdirAll(pdir, 0755); err != nil {
+				return errors.Wrap(err, "creating partition directory")
+			}
+			if err := writeFile(filepath.Join(pdir, "part-0.parquet"), t.Columns[1:], rows); err != nil {
+				return errors.Wrapf(err, "writing %s partition %s", t.Name, eco)
+			}
+		}
+	}
+	return nil
+}
+
+func writeFile(path string, cols []parquet.Column, rows [][]any) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := parquet.NewWriter(f, cols)
+	for _, row := range rows {
+		if err := w.Write(row...); err != nil {
+			return err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,148 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dataset
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/pkg/rebuild/npm"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+)
+
+func TestFlatten(t *testing.T) {
+	s := schema.NewStrategyOneOf(&npm.NPMCustomBuild{
+		Location:    rebuild.Location{Repo: "https://github.com/left-pad/left-pad", Ref: "abc", Dir: "pkg"},
+		NPMVersion:  "10.0.0",
+		NodeVersion: "20.0.0",
+		Command:     "build",
+	})
+	s.Provenance = rebuild.FieldProvenance{"ref": "tag-match"}
+	typ, fields, err := Flatten(s)
+	if err != nil {
+		t.Fatalf("Flatten() error = %v", err)
+	}
+	if typ != "npm_custom_build" {
+		t.Errorf("Flatten() type = %s, want npm_custom_build", typ)
+	}
+	want := map[string]string{
+		"repo":         "https://github.com/left-pad/left-pad",
+		"ref":          "abc",
+		"dir":          "pkg",
+		"npm_version":  "10.0.0",
+		"node_version": "20.0.0",
+		"command":      "build",
+	}
+	for k, v := range want {
+		if fields[k] != v {
+			t.Errorf("Flatten() field %s = %q, want %q", k, fields[k], v)
+		}
+	}
+	if _, ok := fields["provenance"]; ok {
+		t.Errorf("Flatten() included provenance")
+	}
+	if _, _, err := Flatten(schema.StrategyOneOf{}); err == nil {
+		t.Errorf("Flatten() of empty strategy succeeded")
+	}
+}
+
+func TestBuild(t *testing.T) {
+	day := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	pack := schema.NewStrategyOneOf(&npm.NPMPackBuild{Location: rebuild.Location{Repo: "https://github.com/left-pad/left-pad", Ref: "abc"}, NPMVersion: "6.0.0"})
+	leftPad := rebuild.Target{Ecosystem: rebuild.NPM, Package: "left-pad", Version: "1.3.0", Artifact: "left-pad-1.3.0.tgz"}
+	attempts := []schema.RebuildAttempt{
+		{Ecosystem: "npm", Package: "left-pad", Version: "1.3.0", Artifact: "left-pad-1.3.0.tgz", RunID: "run-2", Success: true, Strategy: pack, Created: day.Add(2 * time.Hour).UnixMilli()},
+		{Ecosystem: "npm", Package: "left-pad", Version: "1.3.0", Artifact: "left-pad-1.3.0.tgz", RunID: "run-1", Message: "content differences found", Strategy: pack, Created: day.Add(time.Hour).UnixMilli()},
+		{Ecosystem: "pypi", Package: "absl-py", Version: "2.0.0", Artifact: "absl_py-2.0.0-py3-none-any.whl", RunID: "run-1", Message: "mismatched version 1.0.0\ndetails", Created: day.UnixMilli()},
+	}
+	attestations := []Attestation{{Target: leftPad, URL: "gs://attestations/npm/left-pad/1.3.0/left-pad-1.3.0.tgz/rebuild.intoto.jsonl", Updated: day.Add(3 * time.Hour), Size: 42}}
+	tables := Build(attempts, attestations)
+	got := make(map[string][][]any)
+	for _, tbl := range tables {
+		for _, row := range tbl.Rows {
+			if len(row) != len(tbl.Columns) {
+				t.Errorf("%s row has %d values, want %d", tbl.Name, len(row), len(tbl.Columns))
+			}
+		}
+		got[tbl.Name] = tbl.Rows
+	}
+	wantTargets := [][]any{
+		{"npm", "left-pad", "1.3.0", "left-pad-1.3.0.tgz", int64(2), int64(1), day.Add(time.Hour), day.Add(2 * time.Hour), true, "run-2", true},
+		{"pypi", "absl-py", "2.0.0", "absl_py-2.0.0-py3-none-any.whl", int64(1), int64(0), day, day, false, "run-1", false},
+	}
+	if diff := cmp.Diff(wantTargets, got["targets"]); diff != "" {
+		t.Errorf("targets diff (-want +got):\n%s", diff)
+	}
+	wantCategories := [][]any{
+		{"npm", "content differences found", int64(1), int64(1)},
+		{"pypi", "wrong package version in manifest", int64(1), int64(1)},
+	}
+	if diff := cmp.Diff(wantCategories, got["categories"]); diff != "" {
+		t.Errorf("categories diff (-want +got):\n%s", diff)
+	}
+	var gotRuns, gotDigests []string
+	for _, row := range got["verdicts"] {
+		gotRuns = append(gotRuns, row[4].(string))
+		gotDigests = append(gotDigests, row[11].(string))
+	}
+	if diff := cmp.Diff([]string{"run-1", "run-1", "run-2"}, gotRuns); diff != "" {
+		t.Errorf("verdict order diff (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"", pack.Digest(), pack.Digest()}, gotDigests); diff != "" {
+		t.Errorf("verdict strategy digests diff (-want +got):\n%s", diff)
+	}
+	// NOTE: The strategy is shared by both npm attempts so is only exported once.
+	var gotFields []string
+	for _, row := range got["strategies"] {
+		if row[1] != pack.Digest() || row[2] != "npm_pack_build" {
+			t.Errorf("unexpected strategy row: %v", row)
+		}
+		gotFields = append(gotFields, row[3].(string))
+	}
+	if diff := cmp.Diff([]string{"dir", "npm_version", "ref", "repo", "version_override"}, gotFields); diff != "" {
+		t.Errorf("strategy fields diff (-want +got):\n%s", diff)
+	}
+	if len(got["attestations"]) != 1 {
+		t.Errorf("got %d attestation rows, want 1", len(got["attestations"]))
+	}
+}
+
+func TestWriteDir(t *testing.T) {
+	dir := t.TempDir()
+	tables := Build([]schema.RebuildAttempt{
+		{Ecosystem: "npm", Package: "left-pad", Version: "1.3.0", Artifact: "left-pad-1.3.0.tgz", Success: true},
+		{Ecosystem: "pypi", Package: "absl-py", Version: "2.0.0", Artifact: "absl_py-2.0.0-py3-none-any.whl"},
+	}, nil)
+	if err := WriteDir(dir, tables); err != nil {
+		t.Fatalf("WriteDir() error = %v", err)
+	}
+	for _, p := range []string{
+		"targets/ecosystem=npm/part-0.parquet",
+		"targets/ecosystem=pypi/part-0.parquet",
+		"verdicts/ecosystem=npm/part-0.parquet",
+		"categories/ecosystem=pypi/part-0.parquet",
+	} {
+		if _, err := os.Stat(filepath.Join(dir, p)); err != nil {
+			t.Errorf("missing partition %s: %v", p, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "categories/ecosystem=npm")); err == nil {
+		t.Errorf("unexpected empty partition categories/ecosystem=npm")
+	}
+}
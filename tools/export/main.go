@@ -0,0 +1,164 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// export produces datasets of rebuild results for offline analysis.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"strings"
+	"time"
+
+	gcs "cloud.google.com/go/storage"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+	"github.com/google/oss-rebuild/tools/ctl/repair"
+	"github.com/google/oss-rebuild/tools/ctl/rundex"
+	"github.com/google/oss-rebuild/tools/export/dataset"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"google.golang.org/api/iterator"
+)
+
+var rootCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export OSS-Rebuild data for offline analysis",
+}
+
+func fetchAttempts(ctx context.Context, client *rundex.FirestoreClient, eco string, since time.Time) ([]schema.RebuildAttempt, error) {
+	q := client.Client.CollectionGroup("attempts").Query
+	if eco != "" {
+		q = q.Where("ecosystem", "==", eco)
+	}
+	if !since.IsZero() {
+		q = q.Where("created", ">=", since.UnixMilli())
+	}
+	out := make(chan rundex.Rebuild)
+	cerr := rundex.DoQuery(ctx, q, rundex.NewRebuildFromFirestore, out)
+	var attempts []schema.RebuildAttempt
+	for r := range out {
+		attempts = append(attempts, r.RebuildAttempt)
+	}
+	if err := <-cerr; err != nil {
+		return nil, errors.Wrap(err, "querying attempts")
+	}
+	return attempts, nil
+}
+
+func fetchAttestations(ctx context.Context, bucket *gcs.BucketHandle, eco string) ([]dataset.Attestation, error) {
+	var prefix string
+	if eco != "" {
+		prefix = eco + "/"
+	}
+	it := bucket.Objects(ctx, &gcs.Query{Prefix: prefix})
+	var attestations []dataset.Attestation
+	for {
+		obj, err := it.Next()
+		if err == iterator.Done {
+			break
+		} else if err != nil {
+			return nil, errors.Wrap(err, "listing objects")
+		}
+		t, ok := repair.ParseObjectName(obj.Name)
+		if !ok {
+			continue
+		}
+		attestations = append(attestations, dataset.Attestation{
+			Target:  t,
+			URL:     "gs://" + obj.Bucket + "/" + obj.Name,
+			Updated: obj.Updated,
+			Size:    obj.Size,
+		})
+	}
+	return attestations, nil
+}
+
+var exportParquet = &cobra.Command{
+	Use:   "parquet --project <ID> --output-dir <dir> [--attestation-bucket <bucket>] [--ecosystem <ecosystem>] [--since <YYYY-MM-DD>]",
+	Short: "Export targets, verdicts, strategies, mismatch categories, and attestations as partitioned Parquet datasets",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		if *project == "" {
+			log.Fatal("project not provided")
+		}
+		if *outputDir == "" {
+			log.Fatal("output-dir not provided")
+		}
+		var sinceTime time.Time
+		if *since != "" {
+			var err error
+			sinceTime, err = time.Parse(time.DateOnly, *since)
+			if err != nil {
+				log.Fatal(errors.Wrap(err, "parsing since"))
+			}
+		}
+		client, err := rundex.NewFirestore(ctx, *project)
+		if err != nil {
+			log.Fatal(err)
+		}
+		attempts, err := fetchAttempts(ctx, client, *ecosystem, sinceTime)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Fetched %d attempts", len(attempts))
+		var attestations []dataset.Attestation
+		if *attestationBucket != "" {
+			gcsClient, err := gcs.NewClient(ctx)
+			if err != nil {
+				log.Fatal(errors.Wrap(err, "creating gcs client"))
+			}
+			attestations, err = fetchAttestations(ctx, gcsClient.Bucket(*attestationBucket), *ecosystem)
+			if err != nil {
+				log.Fatal(err)
+			}
+			log.Printf("Fetched %d attestations", len(attestations))
+		}
+		tables := dataset.Build(attempts, attestations)
+		if err := dataset.WriteDir(*outputDir, tables); err != nil {
+			log.Fatal(errors.Wrap(err, "writing datasets"))
+		}
+		var names []string
+		for _, t := range tables {
+			names = append(names, t.Name)
+		}
+		log.Printf("Wrote %s to %s", strings.Join(names, ", "), *outputDir)
+	},
+}
+
+var (
+	project           = flag.String("project", "", "the project from which to fetch the Firestore data")
+	attestationBucket = flag.String("attestation-bucket", "google-rebuild-attestations", "the gcs bucket where attestation bundles are published. if empty, attestations are not exported")
+	outputDir         = flag.String("output-dir", "", "the directory to which the datasets will be written")
+	ecosystem         = flag.String("ecosystem", "", "if provided, only data from this ecosystem will be exported")
+	since             = flag.String("since", "", "if provided, only attempts created on or after this day (YYYY-MM-DD) will be exported")
+)
+
+func init() {
+	exportParquet.Flags().AddGoFlag(flag.Lookup("project"))
+	exportParquet.Flags().AddGoFlag(flag.Lookup("attestation-bucket"))
+	exportParquet.Flags().AddGoFlag(flag.Lookup("output-dir"))
+	exportParquet.Flags().AddGoFlag(flag.Lookup("ecosystem"))
+	exportParquet.Flags().AddGoFlag(flag.Lookup("since"))
+
+	rootCmd.AddCommand(exportParquet)
+}
+
+func main() {
+	flag.Parse()
+	if err := rootCmd.Execute(); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,243 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package parquet writes flat tables in the Apache Parquet format.
+//
+// Only the subset of the format needed for exporting rebuild data is
+// supported: a flat schema of required columns written as a single,
+// uncompressed row group with PLAIN-encoded values.
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const magic = "PAR1"
+
+// Type is the type of a column's values.
+type Type int
+
+const (
+	// Boolean columns hold bool values.
+	Boolean Type = iota
+	// Int64 columns hold int64 values.
+	Int64
+	// String columns hold UTF-8 string values.
+	String
+	// Timestamp columns hold time.Time values stored with millisecond precision.
+	Timestamp
+)
+
+// Physical types, converted types, and enums from the Parquet thrift definition.
+const (
+	physicalBoolean   = 0
+	physicalInt64     = 2
+	physicalByteArray = 6
+
+	convertedUTF8            = 0
+	convertedTimestampMillis = 9
+
+	repetitionRequired = 0
+	encodingPlain      = 0
+	encodingRLE        = 3
+	pageTypeData       = 0
+	codecUncompressed  = 0
+)
+
+func (t Type) physical() int32 {
+	switch t {
+	case Boolean:
+		return physicalBoolean
+	case String:
+		return physicalByteArray
+	default:
+		return physicalInt64
+	}
+}
+
+func (t Type) converted() (int32, bool) {
+	switch t {
+	case String:
+		return convertedUTF8, true
+	case Timestamp:
+		return convertedTimestampMillis, true
+	default:
+		return 0, false
+	}
+}
+
+// Column describes a single column of a table.
+type Column struct {
+	Name string
+	Type Type
+}
+
+// Writer buffers the rows of a table and writes them as a Parquet file on Close.
+type Writer struct {
+	w       io.Writer
+	columns []Column
+	values  []*bytes.Buffer
+	bits    [][]bool
+	rows    int
+}
+
+// NewWriter returns a Writer that writes a table with the provided columns to w.
+func NewWriter(w io.Writer, columns []Column) *Writer {
+	values := make([]*bytes.Buffer, len(columns))
+	for i := range values {
+		values[i] = new(bytes.Buffer)
+	}
+	return &Writer{w: w, columns: columns, values: values, bits: make([][]bool, len(columns))}
+}
+
+// Write appends a row to the table.
+//
+// The row must contain one value per column of the type corresponding to the
+// column's Type.
+func (w *Writer) Write(row ...any) error {
+	if len(row) != len(w.columns) {
+		return errors.Errorf("row has %d values, want %d", len(row), len(w.columns))
+	}
+	for i, c := range w.columns {
+		var ok bool
+		switch c.Type {
+		case Boolean:
+			_, ok = row[i].(bool)
+		case Int64:
+			_, ok = row[i].(int64)
+		case String:
+			_, ok = row[i].(string)
+		case Timestamp:
+			_, ok = row[i].(time.Time)
+		}
+		if !ok {
+			return errors.Errorf("unexpected value for column %s: %T", c.Name, row[i])
+		}
+	}
+	for i, c := range w.columns {
+		switch c.Type {
+		case Boolean:
+			w.bits[i] = append(w.bits[i], row[i].(bool))
+		case Int64:
+			binary.Write(w.values[i], binary.LittleEndian, row[i].(int64))
+		case String:
+			v := row[i].(string)
+			binary.Write(w.values[i], binary.LittleEndian, uint32(len(v)))
+			w.values[i].WriteString(v)
+		case Timestamp:
+			binary.Write(w.values[i], binary.LittleEndian, row[i].(time.Time).UnixMilli())
+		}
+	}
+	w.rows++
+	return nil
+}
+
+// Close writes the buffered table to the underlying writer.
+func (w *Writer) Close() error {
+	var out bytes.Buffer
+	out.WriteString(magic)
+	chunks := make([]columnChunk, len(w.columns))
+	for i, c := range w.columns {
+		data := w.values[i].Bytes()
+		if c.Type == Boolean {
+			data = packBits(w.bits[i])
+		}
+		var header bytes.Buffer
+		writeStruct(&header, func(s *structWriter) {
+			s.i32(1, pageTypeData)
+			s.i32(2, int32(len(data)))
+			s.i32(3, int32(len(data)))
+			s.structField(5, func(s *structWriter) {
+				s.i32(1, int32(w.rows))
+				s.i32(2, encodingPlain)
+				s.i32(3, encodingRLE)
+				s.i32(4, encodingRLE)
+			})
+		})
+		chunks[i] = columnChunk{offset: int64(out.Len()), size: int64(header.Len() + len(data))}
+		out.Write(header.Bytes())
+		out.Write(data)
+	}
+	var footer bytes.Buffer
+	writeStruct(&footer, func(s *structWriter) {
+		s.i32(1, 1)
+		s.list(2, typeStruct, len(w.columns)+1, func(b *bytes.Buffer, i int) {
+			writeStruct(b, func(s *structWriter) {
+				if i == 0 {
+					s.binary(4, []byte("schema"))
+					s.i32(5, int32(len(w.columns)))
+					return
+				}
+				c := w.columns[i-1]
+				s.i32(1, c.Type.physical())
+				s.i32(3, repetitionRequired)
+				s.binary(4, []byte(c.Name))
+				if ct, ok := c.Type.converted(); ok {
+					s.i32(6, ct)
+				}
+			})
+		})
+		s.i64(3, int64(w.rows))
+		s.list(4, typeStruct, 1, func(b *bytes.Buffer, _ int) {
+			writeStruct(b, func(s *structWriter) {
+				var total int64
+				s.list(1, typeStruct, len(w.columns), func(b *bytes.Buffer, i int) {
+					c, chunk := w.columns[i], chunks[i]
+					total += chunk.size
+					writeStruct(b, func(s *structWriter) {
+						s.i64(2, chunk.offset)
+						s.structField(3, func(s *structWriter) {
+							s.i32(1, c.Type.physical())
+							s.list(2, typeI32, 1, func(b *bytes.Buffer, _ int) { writeVarint(b, zigzag(encodingPlain)) })
+							s.list(3, typeBinary, 1, func(b *bytes.Buffer, _ int) { writeBinary(b, []byte(c.Name)) })
+							s.i32(4, codecUncompressed)
+							s.i64(5, int64(w.rows))
+							s.i64(6, chunk.size)
+							s.i64(7, chunk.size)
+							s.i64(9, chunk.offset)
+						})
+					})
+				})
+				s.i64(2, total)
+				s.i64(3, int64(w.rows))
+			})
+		})
+		s.binary(6, []byte("oss-rebuild"))
+	})
+	out.Write(footer.Bytes())
+	binary.Write(&out, binary.LittleEndian, uint32(footer.Len()))
+	out.WriteString(magic)
+	_, err := w.w.Write(out.Bytes())
+	return err
+}
+
+type columnChunk struct {
+	offset, size int64
+}
+
+// packBits encodes booleans using PLAIN encoding, one bit per value starting from the least significant bit.
+func packBits(bs []bool) []byte {
+	out := make([]byte, (len(bs)+7)/8)
+	for i, b := range bs {
+		if b {
+			out[i/8] |= 1 << (i % 8)
+		}
+	}
+	return out
+}
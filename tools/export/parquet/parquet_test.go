@@ -0,0 +1,142 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// compactReader decodes the subset of the thrift compact protocol produced by structWriter.
+type compactReader struct {
+	b []byte
+	p int
+}
+
+func (r *compactReader) byte() byte {
+	r.p++
+	return r.b[r.p-1]
+}
+
+func (r *compactReader) varint() uint64 {
+	v, n := binary.Uvarint(r.b[r.p:])
+	r.p += n
+	return v
+}
+
+func (r *compactReader) zigzag() int64 {
+	v := r.varint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *compactReader) value(typ byte) any {
+	switch typ {
+	case typeI32, typeI64:
+		return r.zigzag()
+	case typeBinary:
+		n := int(r.varint())
+		r.p += n
+		return string(r.b[r.p-n : r.p])
+	case typeList:
+		h := r.byte()
+		n, elem := int(h>>4), h&0xf
+		if n == 15 {
+			n = int(r.varint())
+		}
+		var l []any
+		for i := 0; i < n; i++ {
+			l = append(l, r.value(elem))
+		}
+		return l
+	case typeStruct:
+		return r.structure()
+	}
+	panic("unsupported type")
+}
+
+func (r *compactReader) structure() map[int64]any {
+	m := make(map[int64]any)
+	var last int64
+	for h := r.byte(); h != 0; h = r.byte() {
+		if delta := int64(h >> 4); delta != 0 {
+			last += delta
+		} else {
+			last = r.zigzag()
+		}
+		m[last] = r.value(h & 0xf)
+	}
+	return m
+}
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, []Column{{"name", String}, {"count", Int64}, {"success", Boolean}, {"created", Timestamp}})
+	created := time.UnixMilli(1704153600000)
+	if err := w.Write("left-pad", int64(3), true, created); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := w.Write("absl-py", int64(-1), false, created.Add(time.Second)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := w.Write("extra"); err == nil {
+		t.Errorf("Write() with missing values succeeded")
+	}
+	if err := w.Write("left-pad", 3, true, created); err == nil {
+		t.Errorf("Write() with mistyped value succeeded")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	b := buf.Bytes()
+	if string(b[:4]) != magic || string(b[len(b)-4:]) != magic {
+		t.Fatalf("missing magic bytes")
+	}
+	footerLen := int(binary.LittleEndian.Uint32(b[len(b)-8:]))
+	r := compactReader{b: b, p: len(b) - 8 - footerLen}
+	meta := r.structure()
+	if r.p != len(b)-8 {
+		t.Errorf("footer length mismatch: decoded %d bytes, want %d", r.p-(len(b)-8-footerLen), footerLen)
+	}
+	if meta[3] != int64(2) {
+		t.Errorf("num_rows = %v, want 2", meta[3])
+	}
+	var names []any
+	for _, e := range meta[2].([]any) {
+		names = append(names, e.(map[int64]any)[4])
+	}
+	if diff := cmp.Diff([]any{"schema", "name", "count", "success", "created"}, names); diff != "" {
+		t.Errorf("schema names diff (-want +got):\n%s", diff)
+	}
+	var values [][]byte
+	for _, c := range meta[4].([]any)[0].(map[int64]any)[1].([]any) {
+		md := c.(map[int64]any)[3].(map[int64]any)
+		pr := compactReader{b: b, p: int(md[9].(int64))}
+		header := pr.structure()
+		values = append(values, b[pr.p:pr.p+int(header[3].(int64))])
+	}
+	want := [][]byte{
+		[]byte("\x08\x00\x00\x00left-pad\x07\x00\x00\x00absl-py"),
+		binary.LittleEndian.AppendUint64(binary.LittleEndian.AppendUint64(nil, 3), ^uint64(0)),
+		{0x01},
+		binary.LittleEndian.AppendUint64(binary.LittleEndian.AppendUint64(nil, 1704153600000), 1704153601000),
+	}
+	if diff := cmp.Diff(want, values); diff != "" {
+		t.Errorf("column values diff (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Type identifiers of the thrift compact protocol.
+const (
+	typeI32    = 5
+	typeI64    = 6
+	typeBinary = 8
+	typeList   = 9
+	typeStruct = 12
+)
+
+func zigzag(v int64) uint64 {
+	return uint64((v << 1) ^ (v >> 63))
+}
+
+func writeVarint(b *bytes.Buffer, v uint64) {
+	b.Write(binary.AppendUvarint(nil, v))
+}
+
+func writeBinary(b *bytes.Buffer, v []byte) {
+	writeVarint(b, uint64(len(v)))
+	b.Write(v)
+}
+
+// structWriter encodes the fields of a thrift struct using the compact protocol.
+type structWriter struct {
+	b    *bytes.Buffer
+	last int16
+}
+
+// writeStruct encodes the fields written by fn followed by the struct terminator.
+func writeStruct(b *bytes.Buffer, fn func(*structWriter)) {
+	fn(&structWriter{b: b})
+	b.WriteByte(0)
+}
+
+func (s *structWriter) field(id int16, typ byte) {
+	if delta := id - s.last; delta > 0 && delta <= 15 {
+		s.b.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		s.b.WriteByte(typ)
+		writeVarint(s.b, zigzag(int64(id)))
+	}
+	s.last = id
+}
+
+func (s *structWriter) i32(id int16, v int32) {
+	s.field(id, typeI32)
+	writeVarint(s.b, zigzag(int64(v)))
+}
+
+func (s *structWriter) i64(id int16, v int64) {
+	s.field(id, typeI64)
+	writeVarint(s.b, zigzag(v))
+}
+
+func (s *structWriter) binary(id int16, v []byte) {
+	s.field(id, typeBinary)
+	writeBinary(s.b, v)
+}
+
+func (s *structWriter) structField(id int16, fn func(*structWriter)) {
+	s.field(id, typeStruct)
+	writeStruct(s.b, fn)
+}
+
+// list encodes a list of n elements of type elem, each of which is written by fn.
+func (s *structWriter) list(id int16, elem byte, n int, fn func(b *bytes.Buffer, i int)) {
+	s.field(id, typeList)
+	if n < 15 {
+		s.b.WriteByte(byte(n)<<4 | elem)
+	} else {
+		s.b.WriteByte(0xf0 | elem)
+		writeVarint(s.b, uint64(n))
+	}
+	for i := 0; i < n; i++ {
+		fn(s.b, i)
+	}
+}
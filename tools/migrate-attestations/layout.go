@@ -0,0 +1,91 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/pkg/errors"
+)
+
+// Layout maps the assets of a rebuild target to object paths in the attestation bucket.
+type Layout interface {
+	// Path returns the object path of the named asset for t.
+	Path(t rebuild.Target, name string) string
+	// Parse returns the target and asset name stored at the object path p.
+	Parse(p string) (rebuild.Target, string, error)
+}
+
+// layouts are the supported attestation bucket layouts by schema version.
+var layouts = map[string]Layout{
+	"v1": v1Layout{},
+	"v2": v2Layout{},
+}
+
+// v1Layout stores assets at <ecosystem>/<package>/<version>/<artifact>/<name>.
+//
+// Package names containing "/" span multiple path segments so the package is
+// recovered as the segments between the ecosystem and the version.
+type v1Layout struct{}
+
+func (v1Layout) Path(t rebuild.Target, name string) string {
+	return path.Join(string(t.Ecosystem), t.Package, t.Version, t.Artifact, name)
+}
+
+func (v1Layout) Parse(p string) (rebuild.Target, string, error) {
+	parts := strings.Split(p, "/")
+	if len(parts) < 5 {
+		return rebuild.Target{}, "", errors.Errorf("unexpected v1 path: %s", p)
+	}
+	n := len(parts)
+	t := rebuild.Target{
+		Ecosystem: rebuild.Ecosystem(parts[0]),
+		Package:   strings.Join(parts[1:n-3], "/"),
+		Version:   parts[n-3],
+		Artifact:  parts[n-2],
+	}
+	return t, parts[n-1], nil
+}
+
+// v2Layout stores assets at <ecosystem>/<escaped package>/<version>/<artifact>/<name>.
+//
+// Escaping the package into a single segment ensures a package prefix does not
+// match the assets of other packages sharing a scope or path prefix.
+type v2Layout struct{}
+
+func (v2Layout) Path(t rebuild.Target, name string) string {
+	return path.Join(string(t.Ecosystem), url.PathEscape(t.Package), t.Version, t.Artifact, name)
+}
+
+func (v2Layout) Parse(p string) (rebuild.Target, string, error) {
+	parts := strings.Split(p, "/")
+	if len(parts) != 5 {
+		return rebuild.Target{}, "", errors.Errorf("unexpected v2 path: %s", p)
+	}
+	pkg, err := url.PathUnescape(parts[1])
+	if err != nil {
+		return rebuild.Target{}, "", errors.Wrapf(err, "unescaping package in %s", p)
+	}
+	t := rebuild.Target{
+		Ecosystem: rebuild.Ecosystem(parts[0]),
+		Package:   pkg,
+		Version:   parts[2],
+		Artifact:  parts[3],
+	}
+	return t, parts[4], nil
+}
@@ -0,0 +1,82 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+)
+
+func TestLayouts(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		layout string
+		target rebuild.Target
+		want   string
+	}{
+		{
+			name:   "v1",
+			layout: "v1",
+			target: rebuild.Target{Ecosystem: rebuild.PyPI, Package: "absl-py", Version: "2.0.0", Artifact: "absl_py-2.0.0-py3-none-any.whl"},
+			want:   "pypi/absl-py/2.0.0/absl_py-2.0.0-py3-none-any.whl/rebuild.intoto.jsonl",
+		},
+		{
+			name:   "v1 scoped package",
+			layout: "v1",
+			target: rebuild.Target{Ecosystem: rebuild.NPM, Package: "@types/node", Version: "20.0.0", Artifact: "types-node-20.0.0.tgz"},
+			want:   "npm/@types/node/20.0.0/types-node-20.0.0.tgz/rebuild.intoto.jsonl",
+		},
+		{
+			name:   "v2",
+			layout: "v2",
+			target: rebuild.Target{Ecosystem: rebuild.PyPI, Package: "absl-py", Version: "2.0.0", Artifact: "absl_py-2.0.0-py3-none-any.whl"},
+			want:   "pypi/absl-py/2.0.0/absl_py-2.0.0-py3-none-any.whl/rebuild.intoto.jsonl",
+		},
+		{
+			name:   "v2 scoped package",
+			layout: "v2",
+			target: rebuild.Target{Ecosystem: rebuild.NPM, Package: "@types/node", Version: "20.0.0", Artifact: "types-node-20.0.0.tgz"},
+			want:   "npm/@types%2Fnode/20.0.0/types-node-20.0.0.tgz/rebuild.intoto.jsonl",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			l := layouts[tc.layout]
+			got := l.Path(tc.target, string(rebuild.AttestationBundleAsset))
+			if got != tc.want {
+				t.Errorf("Path() = %s, want %s", got, tc.want)
+			}
+			target, name, err := l.Parse(got)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if diff := cmp.Diff(tc.target, target); diff != "" {
+				t.Errorf("Parse() target diff (-want +got):\n%s", diff)
+			}
+			if name != string(rebuild.AttestationBundleAsset) {
+				t.Errorf("Parse() name = %s, want %s", name, rebuild.AttestationBundleAsset)
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for name, l := range layouts {
+		if _, _, err := l.Parse("npm/pkg/rebuild.intoto.jsonl"); err == nil {
+			t.Errorf("%s Parse() expected error", name)
+		}
+	}
+}
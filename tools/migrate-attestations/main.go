@@ -0,0 +1,263 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package main implements a tool to migrate attestation bundles between bucket layouts.
+//
+// Migration proceeds in two phases to support a dual-publish window:
+//
+//  1. Copy: Each bundle in the source layout is verified and written to its
+//     path in the destination layout. Existing destination objects are left in
+//     place if identical. Both paths are served during the window.
+//  2. Finalize (--finalize): Once consumers have moved to the destination
+//     layout, source objects are deleted after their destination copy has been
+//     verified.
+//
+// A JSON report describing the outcome for each bundle is written on completion.
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/google/oss-rebuild/pkg/attestation"
+	"github.com/google/oss-rebuild/pkg/attestation/verify"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/pkg/errors"
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+	"google.golang.org/api/iterator"
+)
+
+var (
+	bucket     = flag.String("bucket", "", "the attestation bucket to migrate")
+	prefix     = flag.String("prefix", "", "restrict the migration to source objects with this prefix e.g. npm/")
+	from       = flag.String("from", "v1", "the layout version of the source objects")
+	to         = flag.String("to", "v2", "the layout version to which objects are migrated")
+	finalize   = flag.Bool("finalize", false, "delete source objects whose destination copy is verified, ending the dual-publish window")
+	dryRun     = flag.Bool("dry-run", false, "report the actions to be taken without modifying the bucket")
+	verifyFlag = flag.Bool("verify", true, "whether to verify attestation signatures using the default OSS Rebuild keys")
+	reportPath = flag.String("report", "", "path to which the migration report is written. defaults to stdout")
+)
+
+// status is the outcome of migrating a single object.
+type status string
+
+const (
+	statusCopied    status = "copied"
+	statusPresent   status = "present"
+	statusFinalized status = "finalized"
+	statusSkipped   status = "skipped"
+	statusFailed    status = "failed"
+)
+
+// entry describes the migration of a single object.
+type entry struct {
+	Source string `json:"source"`
+	Dest   string `json:"dest,omitempty"`
+	Digest string `json:"sha256,omitempty"`
+	Status status `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// report summarizes a migration run.
+type report struct {
+	Bucket   string         `json:"bucket"`
+	From     string         `json:"from"`
+	To       string         `json:"to"`
+	Finalize bool           `json:"finalize"`
+	DryRun   bool           `json:"dry_run"`
+	Started  time.Time      `json:"started"`
+	Finished time.Time      `json:"finished"`
+	Counts   map[status]int `json:"counts"`
+	Entries  []entry        `json:"entries"`
+}
+
+type migrator struct {
+	bucket   *storage.BucketHandle
+	from, to Layout
+	verifier *dsse.EnvelopeVerifier
+	finalize bool
+	dryRun   bool
+}
+
+// read returns the contents of the object and verifies they contain a validly signed bundle.
+func (m *migrator) read(ctx context.Context, o *storage.ObjectHandle) ([]byte, error) {
+	r, err := o.NewReader(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "opening object")
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading object")
+	}
+	if _, err := attestation.NewBundle(ctx, data, m.verifier); err != nil {
+		return nil, errors.Wrap(err, "verifying bundle")
+	}
+	return data, nil
+}
+
+// migrate moves the source object to its destination layout path.
+func (m *migrator) migrate(ctx context.Context, attrs *storage.ObjectAttrs) entry {
+	e := entry{Source: attrs.Name}
+	t, name, err := m.from.Parse(attrs.Name)
+	if err != nil || name != string(rebuild.AttestationBundleAsset) {
+		e.Status = statusSkipped
+		return e
+	}
+	e.Dest = m.to.Path(t, name)
+	fail := func(err error) entry {
+		e.Status = statusFailed
+		e.Error = err.Error()
+		return e
+	}
+	if e.Dest == e.Source {
+		e.Status = statusSkipped
+		return e
+	}
+	src := m.bucket.Object(attrs.Name).If(storage.Conditions{GenerationMatch: attrs.Generation})
+	data, err := m.read(ctx, src)
+	if err != nil {
+		return fail(errors.Wrap(err, "reading source"))
+	}
+	digest := sha256.Sum256(data)
+	e.Digest = hex.EncodeToString(digest[:])
+	dst := m.bucket.Object(e.Dest)
+	switch existing, err := m.read(ctx, dst); {
+	case err == nil && !bytes.Equal(existing, data):
+		return fail(errors.New("destination exists with different contents"))
+	case err == nil:
+		e.Status = statusPresent
+	case errors.Is(err, storage.ErrObjectNotExist):
+		if m.dryRun {
+			e.Status = statusCopied
+			break
+		}
+		w := dst.If(storage.Conditions{DoesNotExist: true}).NewWriter(ctx)
+		w.ContentType = attrs.ContentType
+		if _, err := w.Write(data); err != nil {
+			w.Close()
+			return fail(errors.Wrap(err, "writing destination"))
+		}
+		if err := w.Close(); err != nil {
+			return fail(errors.Wrap(err, "writing destination"))
+		}
+		written, err := m.read(ctx, dst)
+		if err != nil {
+			return fail(errors.Wrap(err, "reading destination"))
+		}
+		if !bytes.Equal(written, data) {
+			return fail(errors.New("destination contents differ from source"))
+		}
+		e.Status = statusCopied
+	default:
+		return fail(errors.Wrap(err, "reading destination"))
+	}
+	if m.finalize {
+		if !m.dryRun {
+			if err := src.Delete(ctx); err != nil {
+				return fail(errors.Wrap(err, "deleting source"))
+			}
+		}
+		e.Status = statusFinalized
+	}
+	return e
+}
+
+func main() {
+	flag.Parse()
+	ctx := context.Background()
+	if *bucket == "" {
+		log.Fatal("--bucket must be provided")
+	}
+	fromLayout, ok := layouts[*from]
+	if !ok {
+		log.Fatalf("Unknown source layout: %s", *from)
+	}
+	toLayout, ok := layouts[*to]
+	if !ok {
+		log.Fatalf("Unknown destination layout: %s", *to)
+	}
+	if *from == *to {
+		log.Fatal("Source and destination layouts must differ")
+	}
+	verifier, err := verify.NewEnvelopeVerifier(ctx, verify.Options{TrustAll: !*verifyFlag})
+	if err != nil {
+		log.Fatal(errors.Wrap(err, "creating verifier"))
+	}
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		log.Fatal(errors.Wrap(err, "creating GCS client"))
+	}
+	defer client.Close()
+	m := &migrator{
+		bucket:   client.Bucket(*bucket),
+		from:     fromLayout,
+		to:       toLayout,
+		verifier: verifier,
+		finalize: *finalize,
+		dryRun:   *dryRun,
+	}
+	rep := report{
+		Bucket:   *bucket,
+		From:     *from,
+		To:       *to,
+		Finalize: *finalize,
+		DryRun:   *dryRun,
+		Started:  time.Now().UTC(),
+		Counts:   make(map[status]int),
+	}
+	it := m.bucket.Objects(ctx, &storage.Query{Prefix: *prefix, Projection: storage.ProjectionNoACL})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		} else if err != nil {
+			log.Fatal(errors.Wrap(err, "listing objects"))
+		}
+		e := m.migrate(ctx, attrs)
+		if e.Status == statusFailed {
+			log.Printf("Failed to migrate %s: %s\n", e.Source, e.Error)
+		}
+		rep.Counts[e.Status]++
+		rep.Entries = append(rep.Entries, e)
+	}
+	rep.Finished = time.Now().UTC()
+	out := os.Stdout
+	if *reportPath != "" {
+		f, err := os.Create(*reportPath)
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "creating report"))
+		}
+		defer f.Close()
+		out = f
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(rep); err != nil {
+		log.Fatal(errors.Wrap(err, "writing report"))
+	}
+	log.Printf("Migrated %s -> %s: %v\n", *from, *to, rep.Counts)
+	if rep.Counts[statusFailed] > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// update_node_releases regenerates the node and npm release timeline used to
+// select toolchain versions for npm rebuilds.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/oss-rebuild/internal/semver"
+	npmreg "github.com/google/oss-rebuild/pkg/registry/npm"
+	"github.com/pkg/errors"
+)
+
+var output = flag.String("output", "releases.json", "the file to which the release timeline will be written")
+
+const nodeIndexURL = "https://nodejs.org/dist/index.json"
+
+// nodeIndexEntry is a release listed in the nodejs.org distribution index.
+type nodeIndexEntry struct {
+	Version string `json:"version"`
+	Date    string `json:"date"`
+	NPM     string `json:"npm"`
+	// LTS is either false or the codename of the LTS line.
+	LTS any `json:"lts"`
+}
+
+func nodeReleases(ctx context.Context) ([]npmreg.NodeRelease, error) {
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, nodeIndexURL, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, errors.Errorf("nodejs.org error: %v", resp.Status)
+	}
+	var index []nodeIndexEntry
+	if err := json.NewDecoder(resp.Body).Decode(&index); err != nil {
+		return nil, errors.Wrap(err, "decoding index")
+	}
+	var rels []npmreg.NodeRelease
+	for _, e := range index {
+		// NOTE: Some early releases did not bundle npm.
+		if e.NPM == "" {
+			continue
+		}
+		date, err := time.Parse(time.DateOnly, e.Date)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing date for %s", e.Version)
+		}
+		lts, _ := e.LTS.(string)
+		rels = append(rels, npmreg.NodeRelease{
+			Version: strings.TrimPrefix(e.Version, "v"),
+			Date:    date,
+			NPM:     e.NPM,
+			LTS:     lts != "",
+		})
+	}
+	sort.SliceStable(rels, func(i, j int) bool {
+		if !rels[i].Date.Equal(rels[j].Date) {
+			return rels[i].Date.Before(rels[j].Date)
+		}
+		return semver.Cmp(rels[i].Version, rels[j].Version) < 0
+	})
+	return rels, nil
+}
+
+func main() {
+	flag.Parse()
+	ctx := context.Background()
+	node, err := nodeReleases(ctx)
+	if err != nil {
+		log.Fatalf("fetching node releases: %v", err)
+	}
+	npm, err := npmreg.HTTPRegistry{Client: http.DefaultClient}.DistTagHistory(ctx, "npm")
+	if err != nil {
+		log.Fatalf("fetching npm history: %v", err)
+	}
+	b, err := json.MarshalIndent(npmreg.ToolchainReleases{Node: node, NPM: npm}, "", "  ")
+	if err != nil {
+		log.Fatalf("encoding releases: %v", err)
+	}
+	if err := os.WriteFile(*output, append(b, '\n'), 0644); err != nil {
+		log.Fatalf("writing releases: %v", err)
+	}
+	log.Printf("Wrote %d node and %d npm releases to %s", len(node), len(npm), *output)
+}